@@ -0,0 +1,43 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// logScanResult adds the result of a collection scan to the activity log, in case
+// items were added or removed.
+func (cr *CollectionRepo) logScanResult(c *Collection, before []Item) {
+	if cr.repo == nil {
+		return
+	}
+	previous := make(map[string]bool, len(before))
+	for _, i := range before {
+		previous[i.ID()] = true
+	}
+	var added int
+	for _, i := range c.Items {
+		if previous[i.ID()] {
+			delete(previous, i.ID())
+			continue
+		}
+		added++
+	}
+	removed := len(previous)
+	if added == 0 && removed == 0 {
+		return
+	}
+
+	entry := model.ActivityLogEntry{
+		Name:     fmt.Sprintf("Collection %s scanned", c.Name),
+		Overview: fmt.Sprintf("%d items, %d added, %d removed", len(c.Items), added, removed),
+		Type:     "LibraryScan",
+		Severity: "Information",
+	}
+	if err := cr.repo.AddActivityLogEntry(context.Background(), entry); err != nil {
+		log.Printf("Failed to add activity log entry %q: %s", entry.Name, err)
+	}
+}
@@ -0,0 +1,133 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// Types of artwork that can be uploaded.
+const (
+	ArtworkPoster = "poster"
+	ArtworkFanart = "fanart"
+	ArtworkBanner = "banner"
+	ArtworkLogo   = "logo"
+)
+
+var ErrArtworkNotSupported = errors.New("artwork type not supported for item")
+
+// UploadedArtwork returns the filename of artwork uploaded for an item, false if none has been uploaded.
+func (cr *CollectionRepo) UploadedArtwork(itemID, artworkType string) (string, bool) {
+	cr.artworkMu.RLock()
+	defer cr.artworkMu.RUnlock()
+	filename, ok := cr.artwork[itemID+"-"+artworkType]
+	return filename, ok
+}
+
+// ArtworkFilename returns the filename uploaded artwork of an item is stored as. Artwork is stored
+// in the thumbnail directory as "<itemid>-<type>.<ext>". Without thumbnail directory it is stored
+// next to the video, replacing the current image of that type or using its Kodi name otherwise,
+// e.g. "casablanca-poster.jpg". The next scan picks up artwork stored next to the video.
+func (cr *CollectionRepo) ArtworkFilename(c *Collection, i Item, artworkType, ext string) (string, error) {
+	switch artworkType {
+	case ArtworkPoster, ArtworkFanart, ArtworkBanner, ArtworkLogo:
+	default:
+		return "", fmt.Errorf("unknown artwork type %s", artworkType)
+	}
+	if cr.thumbnailDir != "" {
+		return path.Join(cr.thumbnailDir, i.ID()+"-"+artworkType+"."+ext), nil
+	}
+
+	current := map[string]string{
+		ArtworkPoster: i.Poster(),
+		ArtworkFanart: i.Fanart(),
+		ArtworkBanner: i.Banner(),
+		ArtworkLogo:   i.Logo(),
+	}[artworkType]
+	if current != "" && strings.EqualFold(path.Ext(current), "."+ext) {
+		return path.Join(c.ItemDirectory(i), current), nil
+	}
+	switch v := i.(type) {
+	case *Movie:
+		if artworkType == ArtworkLogo {
+			return "", ErrArtworkNotSupported
+		}
+		return path.Join(c.ItemDirectory(v), strings.TrimSuffix(v.fileName, path.Ext(v.fileName))+"-"+artworkType+"."+ext), nil
+	case *Show:
+		if artworkType == ArtworkLogo {
+			return path.Join(c.ItemDirectory(v), "clearlogo."+ext), nil
+		}
+		return path.Join(c.ItemDirectory(v), artworkType+"."+ext), nil
+	case *Episode:
+		if artworkType != ArtworkPoster {
+			return "", ErrArtworkNotSupported
+		}
+		return path.Join(c.ItemDirectory(v), strings.TrimSuffix(v.fileName, path.Ext(v.fileName))+"-thumb."+ext), nil
+	}
+	return "", ErrArtworkNotSupported
+}
+
+// StoreArtwork writes uploaded artwork of an item to filename, as returned by ArtworkFilename.
+// From now on it is used instead of the images found by the scanner.
+func (cr *CollectionRepo) StoreArtwork(itemID, artworkType, filename string, data []byte) error {
+	if cr.thumbnailDir != "" {
+		if err := os.MkdirAll(cr.thumbnailDir, 0755); err != nil {
+			return err
+		}
+	}
+	// Write to a temporary file first so the current image is never served partially written
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	key := itemID + "-" + artworkType
+	cr.artworkMu.Lock()
+	previous := cr.artwork[key]
+	cr.artwork[key] = filename
+	delete(cr.artworkFiles, previous)
+	cr.artworkFiles[filename] = struct{}{}
+	cr.artworkMu.Unlock()
+
+	// Remove artwork uploaded before with another extension
+	if previous != "" && previous != filename && cr.thumbnailDir != "" && path.Dir(previous) == path.Clean(cr.thumbnailDir) {
+		os.Remove(previous)
+	}
+	return nil
+}
+
+// loadArtwork finds the artwork uploaded before in the thumbnail directory.
+func (cr *CollectionRepo) loadArtwork() {
+	if cr.thumbnailDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(cr.thumbnailDir)
+	if err != nil {
+		return
+	}
+	cr.artworkMu.Lock()
+	defer cr.artworkMu.Unlock()
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		itemID, artworkType, found := strings.Cut(name, "-")
+		if !found || e.IsDir() || !isImageExt.MatchString(strings.TrimPrefix(path.Ext(e.Name()), ".")) {
+			continue
+		}
+		switch artworkType {
+		case ArtworkPoster, ArtworkFanart, ArtworkBanner, ArtworkLogo:
+			filename := path.Join(cr.thumbnailDir, e.Name())
+			cr.artwork[itemID+"-"+artworkType] = filename
+			cr.artworkFiles[filename] = struct{}{}
+		}
+	}
+	if len(cr.artwork) > 0 {
+		log.Printf("Found %d uploaded images", len(cr.artwork))
+	}
+}
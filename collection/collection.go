@@ -1,6 +1,12 @@
 package collection
 
-import "slices"
+import (
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
 
 type Collection struct {
 	// Unique identifier for the collection. Hash of the collection name, or taken from configfile.
@@ -11,11 +17,31 @@ type Collection struct {
 	Type CollectionType
 	// Items in the collection, could be type movies or shows
 	Items []Item
-	// Directory where the collection is stored
-	Directory string
+	// Directories where the collection is stored, items of all directories are merged.
+	Directories []string
 	// BaseUrl   string
 	// HLS server URL for streaming content
 	HlsServer string
+	// Exclude holds globs of files and directories to skip when scanning, e.g. "*sample*".
+	Exclude []string
+	// SubtitleLanguages are the languages subtitles can be downloaded in, e.g. "en". Empty allows all languages.
+	SubtitleLanguages []string
+	// Providers are the metadata, image and subtitle providers used by the collection.
+	Providers ProviderConfig
+	// EpisodeOrder is the order of episodes of shows: "aired", "absolute" or "tvdb". Empty is the same as "aired".
+	EpisodeOrder string
+	// RefreshIntervalDays is the number of days after which metadata of items is refreshed, 0 disables refreshing.
+	RefreshIntervalDays int
+	// Images are the quality, size and format of images served from the collection.
+	Images ImageOptions
+	// DisplayNames are translations of the name by language, e.g. "nl": "Films".
+	DisplayNames map[string]string
+	// Etag changes when items are added, removed or changed.
+	Etag string
+	// LastUpdate is the time the items last changed.
+	LastUpdate time.Time
+	// DateLastMediaAdded is the time the most recent video was added.
+	DateLastMediaAdded time.Time
 }
 
 type CollectionType string
@@ -25,14 +51,73 @@ const (
 	CollectionTypeShows  CollectionType = "shows"
 )
 
-type Collections []Collection
+type Collections []*Collection
 
 func (c *Collection) GetHlsServer() string {
 	return c.HlsServer
 }
 
-func (c *Collection) GetDataDir() string {
-	return c.Directory
+// DisplayName returns the name of the collection in the first of the languages it has a
+// translation for. Languages are codes such as "nl" or "nl-BE", the latter also matches "nl".
+// Returns the name if none of the languages has a translation.
+func (c *Collection) DisplayName(languages []string) string {
+	for _, language := range languages {
+		language = strings.ToLower(language)
+		if name, ok := c.DisplayNames[language]; ok {
+			return name
+		}
+		if primary, _, found := strings.Cut(language, "-"); found {
+			if name, ok := c.DisplayNames[primary]; ok {
+				return name
+			}
+		}
+	}
+	return c.Name
+}
+
+// ItemDirectory returns the absolute directory of an item, based upon
+// the collection directory the item was found in.
+func (c *Collection) ItemDirectory(i Item) string {
+	root := itemRoot(i)
+	if root == "" && len(c.Directories) > 0 {
+		root = c.Directories[0]
+	}
+	return path.Join(root, i.Path())
+}
+
+// itemRoot returns the collection directory an item was found in.
+func itemRoot(i Item) string {
+	switch v := i.(type) {
+	case *Movie:
+		return v.root
+	case *Show:
+		return v.root
+	case *Season:
+		return v.root
+	case *Episode:
+		return v.root
+	}
+	return ""
+}
+
+// ResolvePath returns the absolute path of a file relative to the collection root.
+// The collection directories are checked in order, the first one having the file is used.
+// Returns an empty string if the file is a symlink pointing outside of the collection directories.
+func (c *Collection) ResolvePath(rel string) string {
+	rel = path.Clean("/" + rel)
+	for _, dir := range c.Directories {
+		p := path.Join(dir, rel)
+		if _, err := os.Stat(p); err == nil {
+			if _, ok := c.realPath(p); !ok {
+				return ""
+			}
+			return p
+		}
+	}
+	if len(c.Directories) > 0 {
+		return path.Join(c.Directories[0], rel)
+	}
+	return ""
 }
 
 // Return list of genres from collection.
@@ -6,10 +6,15 @@ package collection
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/erikbos/jellofin-server/collection/metadata"
 	"github.com/erikbos/jellofin-server/collection/search"
 	"github.com/erikbos/jellofin-server/database"
 	"github.com/erikbos/jellofin-server/idhash"
@@ -17,28 +22,218 @@ import (
 
 // CollectionRepo is a repository holding content collections.
 type CollectionRepo struct {
-	collections Collections
-	repo        database.Repository
-	bleveIndex  *search.Search
+	// collections is replaced, not changed, when collections are added at runtime.
+	collections   Collections
+	collectionsMu sync.RWMutex
+	repo          database.Repository
+	bleveIndex    *search.Search
+	// similarWeights are the weights used to score similar items.
+	similarWeights SimilarWeights
+	// scanWorkers is the number of directories that are scanned concurrently.
+	scanWorkers int
+	// metadataCache caches parsed metadata files.
+	metadataCache metadata.Cache
+	// thumbnailDir is the directory to store extracted episode thumbnails, empty disables extraction.
+	thumbnailDir string
+	// ffmpeg is the ffmpeg binary used to extract thumbnails.
+	ffmpeg string
+	// thumbnails holds the IDs of episodes that have an extracted thumbnail.
+	thumbnails map[string]bool
+	// thumbnailFailures holds the video files thumbnail extraction failed for, by filename.
+	thumbnailFailures map[string]thumbnailFailure
+	thumbnailsMu      sync.RWMutex
+	// providerIndex maps provider IDs (e.g. "imdb.tt0111161") to item IDs.
+	providerIndex   map[string][]string
+	providerIndexMu sync.RWMutex
+	// itemIndex maps item IDs to movies, shows, seasons and episodes.
+	itemIndex   map[string]itemIndexEntry
+	itemIndexMu sync.RWMutex
+	// fileIndex holds the absolute paths of all files found by the scanner.
+	fileIndex   map[string]struct{}
+	fileIndexMu sync.RWMutex
+	// episodeGuide provides the episodes of shows to determine missing episodes.
+	episodeGuide EpisodeGuide
+	// offline holds the collection directories that are currently not available.
+	offline map[string]struct{}
+	// checking holds the directories for which a health check is in progress.
+	checking  map[string]bool
+	offlineMu sync.RWMutex
+	// pending holds collections added at runtime, they are added by the background scanner.
+	pending   []Collection
+	pendingMu sync.Mutex
+	// sortArticles are removed from the start of names when sorting.
+	sortArticles []string
+	// sortNames holds the sort name overrides by item ID.
+	sortNames   map[string]string
+	sortNamesMu sync.RWMutex
+	// artwork maps item ID and artwork type to the filename of uploaded artwork.
+	artwork map[string]string
+	// artworkFiles holds the filenames of uploaded artwork.
+	artworkFiles map[string]struct{}
+	artworkMu    sync.RWMutex
+	// providerIDs holds the provider ID overrides by item ID.
+	providerIDs   map[string]map[string]string
+	providerIDsMu sync.RWMutex
+	// locks holds the fields locked in the metadata editor by item ID.
+	locks   map[string]ItemLock
+	locksMu sync.RWMutex
+	// intros holds the detected intros by episode ID.
+	intros   map[string]IntroSegment
+	introsMu sync.RWMutex
+	// hidden holds the IDs of items hidden for all users, trashed the IDs of items moved to the trash
+	// that have not been removed by a scan yet.
+	hidden   map[string]struct{}
+	trashed  map[string]time.Time
+	hiddenMu sync.RWMutex
+	// snapshotFile is the file the scanned items are stored in, empty disables the snapshot.
+	snapshotFile string
+	// savedEtags are the collection etags at the time the snapshot was stored.
+	savedEtags string
+	snapshotMu sync.Mutex
+	// snapshotItems holds the items restored from the snapshot by directory, until the
+	// first scan after startup has validated them.
+	snapshotItems map[string]snapshotItem
+	// idCollisions holds the items that got a different ID by collection ID.
+	idCollisions   map[string][]IDCollision
+	idCollisionsMu sync.Mutex
 }
 
 type Options struct {
-	Collections []Collection
+	Collections Collections
 	Repo        database.Repository
+	// SimilarWeights overrides the default weights of the similar items scoring model.
+	SimilarWeights *SimilarWeights
+	// ScanWorkers is the number of directories that are scanned concurrently, defaults to number of CPUs.
+	ScanWorkers int
+	// MetadataCacheSize is the number of lazy loaded episode details kept in memory.
+	MetadataCacheSize int
+	// ThumbnailDir is the directory to store thumbnails extracted from episodes without thumb image.
+	ThumbnailDir string
+	// Ffmpeg is the ffmpeg binary used for thumbnail extraction, defaults to "ffmpeg".
+	Ffmpeg string
+	// EpisodeGuide provides the episodes of shows, if set missing episodes can be listed.
+	EpisodeGuide EpisodeGuide
+	// SortArticles are removed from the start of names when sorting, defaults to DefaultSortArticles.
+	SortArticles []string
+	// SnapshotFile is the file the scanned items are stored in, so they are available right
+	// after the next start. Empty disables the snapshot.
+	SnapshotFile string
 }
 
 // New creates a new CollectionRepo with the provided options.
 func New(options *Options) *CollectionRepo {
 	c := &CollectionRepo{
-		collections: options.Collections,
-		repo:        options.Repo,
-	}
+		collections:    options.Collections,
+		repo:           options.Repo,
+		similarWeights: DefaultSimilarWeights,
+		scanWorkers:    options.ScanWorkers,
+		thumbnailDir:   options.ThumbnailDir,
+		ffmpeg:         options.Ffmpeg,
+		thumbnails:     make(map[string]bool),
+		episodeGuide:   options.EpisodeGuide,
+		offline:        make(map[string]struct{}),
+		checking:       make(map[string]bool),
+		sortArticles:   options.SortArticles,
+		sortNames:      make(map[string]string),
+	}
+	c.snapshotFile = options.SnapshotFile
+	c.artwork = make(map[string]string)
+	c.artworkFiles = make(map[string]struct{})
+	c.thumbnailFailures = make(map[string]thumbnailFailure)
+	c.providerIDs = make(map[string]map[string]string)
+	c.locks = make(map[string]ItemLock)
+	c.intros = make(map[string]IntroSegment)
+	c.hidden = make(map[string]struct{})
+	c.trashed = make(map[string]time.Time)
+	c.idCollisions = make(map[string][]IDCollision)
+	if c.sortArticles == nil {
+		c.sortArticles = DefaultSortArticles
+	}
+	if c.ffmpeg == "" {
+		c.ffmpeg = "ffmpeg"
+	}
+	if options.SimilarWeights != nil {
+		c.similarWeights = *options.SimilarWeights
+	}
+	if c.scanWorkers <= 0 {
+		c.scanWorkers = runtime.NumCPU()
+	}
+	if options.Repo != nil {
+		c.metadataCache = &metadataCache{repo: options.Repo}
+	}
+	metadata.SetDetailsCacheSize(options.MetadataCacheSize)
 	return c
 }
 
 // AddCollection adds a new content collection to the repository.
 func (cr *CollectionRepo) AddCollection(name string, ID string,
-	collectiontype string, directory string, baseUrl string, hlsServer string) {
+	collectiontype string, directories []string, baseUrl string, hlsServer string, exclude []string,
+	subtitleLanguages []string, providers ProviderConfig, episodeOrder string, refreshIntervalDays int,
+	images ImageOptions, displayNames map[string]string) {
+
+	c, err := newCollection(name, ID, collectiontype, directories, hlsServer, exclude, subtitleLanguages, providers, episodeOrder, refreshIntervalDays, images, displayNames)
+	if err != nil {
+		log.Fatalf("%s, skipping", err)
+		return
+	}
+	log.Printf("Adding collection %s, id: %s, type: %s, directories: %s\n", c.Name, c.ID, c.Type, strings.Join(c.Directories, ", "))
+
+	cr.collectionsMu.Lock()
+	cr.collections = append(slices.Clip(cr.collections), &c)
+	cr.collectionsMu.Unlock()
+}
+
+// QueueCollection adds a content collection while the repository is in use. The collection
+// is added and scanned by the background scanner at the start of its next scan pass.
+// Collections with an ID that is already in use are ignored.
+func (cr *CollectionRepo) QueueCollection(name string, ID string,
+	collectiontype string, directories []string, hlsServer string, exclude []string,
+	subtitleLanguages []string, providers ProviderConfig, episodeOrder string, refreshIntervalDays int,
+	images ImageOptions, displayNames map[string]string) error {
+
+	c, err := newCollection(name, ID, collectiontype, directories, hlsServer, exclude, subtitleLanguages, providers, episodeOrder, refreshIntervalDays, images, displayNames)
+	if err != nil {
+		return err
+	}
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+	if cr.GetCollection(c.ID) != nil {
+		return nil
+	}
+	for _, p := range cr.pending {
+		if p.ID == c.ID {
+			return nil
+		}
+	}
+	log.Printf("Queueing collection %s, id: %s, type: %s, directories: %s\n", c.Name, c.ID, c.Type, strings.Join(c.Directories, ", "))
+	cr.pending = append(cr.pending, c)
+	return nil
+}
+
+// addPendingCollections adds the collections queued at runtime.
+func (cr *CollectionRepo) addPendingCollections() {
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+	if len(cr.pending) == 0 {
+		return
+	}
+	// Readers keep using the slice they got from GetCollections, so append to a copy
+	cr.collectionsMu.Lock()
+	collections := slices.Clip(cr.collections)
+	for _, c := range cr.pending {
+		log.Printf("Adding collection %s, id: %s", c.Name, c.ID)
+		collections = append(collections, &c)
+	}
+	cr.collections = collections
+	cr.collectionsMu.Unlock()
+	cr.pending = nil
+}
+
+// newCollection returns a collection, its ID is generated from the name if not provided.
+func newCollection(name string, ID string,
+	collectiontype string, directories []string, hlsServer string, exclude []string,
+	subtitleLanguages []string, providers ProviderConfig, episodeOrder string, refreshIntervalDays int,
+	images ImageOptions, displayNames map[string]string) (Collection, error) {
 
 	var ct CollectionType
 	switch collectiontype {
@@ -47,44 +242,101 @@ func (cr *CollectionRepo) AddCollection(name string, ID string,
 	case "shows":
 		ct = CollectionTypeShows
 	default:
-		log.Fatalf("Unknown collection type %s, skipping", collectiontype)
-		return
+		return Collection{}, fmt.Errorf("unknown collection type %s", collectiontype)
+	}
+	if err := providers.validate(); err != nil {
+		return Collection{}, fmt.Errorf("collection %s: %w", name, err)
+	}
+	if err := validEpisodeOrder(episodeOrder); err != nil {
+		return Collection{}, fmt.Errorf("collection %s: %w", name, err)
+	}
+	if refreshIntervalDays < 0 {
+		return Collection{}, fmt.Errorf("collection %s: negative refresh interval %d", name, refreshIntervalDays)
+	}
+	if err := images.validate(); err != nil {
+		return Collection{}, fmt.Errorf("collection %s: %w", name, err)
 	}
 
 	c := Collection{
-		Name:      name,
-		ID:        ID,
-		Type:      ct,
-		Directory: directory,
+		Name:        name,
+		ID:          ID,
+		Type:        ct,
+		Directories: directories,
 		// BaseUrl:   baseUrl,
-		HlsServer: hlsServer,
+		HlsServer:         hlsServer,
+		Exclude:           exclude,
+		SubtitleLanguages: subtitleLanguages,
+		Providers:         providers,
+		EpisodeOrder:      episodeOrder,
+	}
+	c.RefreshIntervalDays = refreshIntervalDays
+	c.Images = images
+	c.DisplayNames = make(map[string]string, len(displayNames))
+	for language, displayName := range displayNames {
+		c.DisplayNames[strings.ToLower(language)] = displayName
 	}
 	// If no collection ID is provided, generate one based upon the name.
 	if c.ID == "" {
 		c.ID = idhash.IdHash(c.Name)
 	}
-
-	log.Printf("Adding collection %s, id: %s, type: %s, directory: %s\n", c.Name, c.ID, c.Type, c.Directory)
-
-	cr.collections = append(cr.collections, c)
+	return c, nil
 }
 
 // Init starts scanning the repository for contents for the first time.
 func (cr *CollectionRepo) Init() {
 	log.Printf("Initializing collections..")
-	// scan all collections without delay
-	cr.updateCollections(0)
-	// Build search index
+	start := time.Now()
+	cr.loadSortNames()
+	cr.loadArtwork()
+	cr.loadProviderIDs()
+	cr.loadLocks()
+	cr.loadIntros()
+	cr.loadHiddenItems()
+	// skip collections on storage that is not available
+	cr.checkCollectionsHealth()
+	// use the items of the previous run, the first background scan validates them
+	restored := cr.restoreSnapshot()
+	// scan all other collections without delay
+	for _, c := range cr.GetCollections() {
+		if !restored[c.ID] {
+			cr.updateCollection(c, 0)
+		}
+	}
+	log.Printf("Initializing collections took %s", time.Since(start).Round(time.Millisecond))
+	if collisions := cr.IDCollisions(); len(collisions) != 0 {
+		log.Printf("Collections have %d items with an ID already used by another item, they got a different ID", len(collisions))
+	}
+	stats := cr.GetStatistics()
+	log.Printf("Collections have %d movies, %d shows, %d episodes, total size %d bytes (%d hardlinks counted once)",
+		stats.MovieCount, stats.ShowCount, stats.EpisodeCount, stats.TotalSize, stats.HardlinkCount)
+	// Build search, provider ID, item and file index
 	cr.BuildSearchIndex(context.Background())
+	cr.BuildProviderIndex()
+	cr.BuildItemIndex()
+	cr.BuildFileIndex()
+	cr.updateSnapshot()
 }
 
 // Background keeps scanning the repository for content changes continously.
 func (cr *CollectionRepo) Background(ctx context.Context) {
+	go cr.thumbnailBackground(ctx)
+	go cr.healthCheckBackground(ctx)
 	for {
-		// scan all collections with delay
-		cr.updateCollections(1500 * time.Millisecond)
-		// Rebuild search index to ensure any new items are included
+		cr.addPendingCollections()
+		// scan all collections with delay, except the first scan after restoring the
+		// snapshot as it only rescans directories that changed
+		scanInterval := 1500 * time.Millisecond
+		if cr.snapshotItems != nil {
+			scanInterval = 0
+		}
+		cr.updateCollections(scanInterval)
+		cr.snapshotItems = nil
+		// Rebuild indexes to ensure any new items are included
 		cr.BuildSearchIndex(ctx)
+		cr.BuildProviderIndex()
+		cr.BuildItemIndex()
+		cr.BuildFileIndex()
+		cr.updateSnapshot()
 	}
 }
 
@@ -92,30 +344,44 @@ func (cr *CollectionRepo) Background(ctx context.Context) {
 // - ScanInterval can be set as wait time between loading details of individual items.
 // This can be useful to avoid overloading the filesystem with too many requests.
 func (cr *CollectionRepo) updateCollections(scanInterval time.Duration) {
-	for i := range cr.collections {
-		c := &(cr.collections[i])
-		switch c.Type {
-		case CollectionTypeMovies:
-			cr.buildMovies(c, scanInterval)
-		case CollectionTypeShows:
-			cr.buildShows(c, scanInterval)
-		default:
-			log.Printf("Unknown collection type %s, skipping", c.Type)
-		}
+	start := time.Now()
+	for _, c := range cr.GetCollections() {
+		cr.updateCollection(c, scanInterval)
+	}
+	cr.forgetTrashed(start)
+}
+
+// updateCollection updates a collection with the latest content from file system.
+func (cr *CollectionRepo) updateCollection(c *Collection, scanInterval time.Duration) {
+	// Keep current items until storage is available again
+	if cr.CollectionOffline(c) {
+		return
+	}
+	before := c.Items
+	switch c.Type {
+	case CollectionTypeMovies:
+		cr.buildMovies(c, scanInterval)
+	case CollectionTypeShows:
+		cr.buildShows(c, scanInterval)
+	default:
+		log.Printf("Unknown collection type %s, skipping", c.Type)
 	}
+	cr.logScanResult(c, before)
+	cr.updateFreshness(c)
 }
 
 // GetCollections returns all collections in the repository.
 func (cr *CollectionRepo) GetCollections() Collections {
+	cr.collectionsMu.RLock()
+	defer cr.collectionsMu.RUnlock()
 	return cr.collections
 }
 
 // GetCollection returns a collection by its ID.
 func (cr *CollectionRepo) GetCollection(collectionID string) (c *Collection) {
-	for n := range cr.collections {
-		if cr.collections[n].ID == collectionID {
-			c = &(cr.collections[n])
-			return
+	for _, coll := range cr.GetCollections() {
+		if coll.ID == collectionID {
+			return coll
 		}
 	}
 	return
@@ -131,8 +397,14 @@ func (cr *CollectionRepo) GetItem(collectionID string, itemName string) (i Item)
 		if n.Name() == itemName || n.ID() == itemName {
 			return n
 		}
-		// If item is a show, also search in seasons and episodes
+		// If item is a multi-part movie or show, also search in parts, seasons and episodes
 		switch v := n.(type) {
+		case *Movie:
+			for _, p := range v.parts {
+				if p.ID() == itemName {
+					return p
+				}
+			}
 		case *Show:
 			for _, s := range v.Seasons {
 				if s.ID() == itemName {
@@ -151,9 +423,13 @@ func (cr *CollectionRepo) GetItem(collectionID string, itemName string) (i Item)
 
 // GetItemByID returns an item in a collection by its ID.
 func (cr *CollectionRepo) GetItemByID(itemID string) (*Collection, Item) {
-	for _, c := range cr.collections {
+	if c, i := cr.lookupItemIndex(itemID); i != nil {
+		return c, i
+	}
+	// Items added since the index was built
+	for _, c := range cr.GetCollections() {
 		if i := cr.GetItem(c.ID, itemID); i != nil {
-			return &c, i
+			return c, i
 		}
 	}
 	return nil, nil
@@ -161,12 +437,12 @@ func (cr *CollectionRepo) GetItemByID(itemID string) (*Collection, Item) {
 
 // GetShowByID returns a show in a collection by its ID.
 func (cr *CollectionRepo) GetShowByID(showID string) (*Collection, *Show) {
-	for _, c := range cr.collections {
+	for _, c := range cr.GetCollections() {
 		for _, i := range c.Items {
 			switch v := i.(type) {
 			case *Show:
 				if v.id == showID {
-					return &c, v
+					return c, v
 				}
 			}
 		}
@@ -177,13 +453,13 @@ func (cr *CollectionRepo) GetShowByID(showID string) (*Collection, *Show) {
 // GetSeasonByID returns a season in a collection by its ID.
 func (cr *CollectionRepo) GetSeasonByID(saesonID string) (*Collection, *Show, *Season) {
 	// fixme: wooho O(n^^3) "just temporarily.."
-	for _, c := range cr.collections {
+	for _, c := range cr.GetCollections() {
 		for _, i := range c.Items {
 			switch v := i.(type) {
 			case *Show:
 				for _, s := range v.Seasons {
 					if s.id == saesonID {
-						return &c, v, &s
+						return c, v, &s
 					}
 				}
 			}
@@ -195,14 +471,14 @@ func (cr *CollectionRepo) GetSeasonByID(saesonID string) (*Collection, *Show, *S
 // GetEpisodeByID returns an episode in a collection by its ID.
 func (cr *CollectionRepo) GetEpisodeByID(episodeID string) (*Collection, *Show, *Season, *Episode) {
 	// fixme: wooho O(n^^4) "just temporarily.."
-	for _, c := range cr.collections {
+	for _, c := range cr.GetCollections() {
 		for _, i := range c.Items {
 			switch v := i.(type) {
 			case *Show:
 				for _, s := range v.Seasons {
 					for _, e := range s.Episodes {
 						if e.id == episodeID {
-							return &c, v, &s, &e
+							return c, v, &s, &e
 						}
 					}
 				}
@@ -212,122 +488,6 @@ func (cr *CollectionRepo) GetEpisodeByID(episodeID string) (*Collection, *Show,
 	return nil, nil, nil, nil
 }
 
-// NextUpInSeries returns the nextup episode in a series based upon list of watched episodes and seriesID.
-func (cr *CollectionRepo) NextUpInSeries(watchedEpisodeIDs []string, seriesID string) (nextUpEpisodeIDs []string, e error) {
-	c, show := cr.GetShowByID(seriesID)
-	if c == nil || show == nil {
-		return []string{}, nil
-	}
-
-	// Check if any watched episodes belong to this show
-	hasWatchedEpisodes := false
-	for _, season := range show.Seasons {
-		for _, episode := range season.Episodes {
-			for _, watchedID := range watchedEpisodeIDs {
-				if episode.id == watchedID {
-					hasWatchedEpisodes = true
-				}
-			}
-		}
-	}
-	// If no episodes from this series have been watched, return first episode we can find
-	if !hasWatchedEpisodes {
-		if len(show.Seasons) > 0 && len(show.Seasons[0].Episodes) > 0 {
-			log.Printf("NextUp: returning first episode of unwatched series %s(%s)\n", show.name, show.id)
-			return []string{show.Seasons[0].Episodes[0].id}, nil
-		}
-	}
-	return []string{}, nil
-}
-
-// NextUpInCollection returns the nextup episodes in the collection based upon list of watched episodes
-func (cr *CollectionRepo) NextUpInCollection(watchedEpisodeIDs []string, seriesID string) (nextUpEpisodeIDs []string, e error) {
-	type ShowEntry struct {
-		show          *Show
-		seasonNumber  int
-		episodeNumber int
-		seasonIdx     int
-		epIdx         int
-	}
-
-	// todo: look at seriesID and filter watchedEpisodeIDs to only include episodes from that series
-	showMap := make(map[string]ShowEntry)
-	for _, episodeID := range watchedEpisodeIDs {
-		c, show, season, episode := cr.GetEpisodeByID(episodeID)
-		if c == nil || show == nil || season == nil || episode == nil {
-			continue
-		}
-		// NextUp skips everything apart from shows
-		if c.Type != CollectionTypeShows {
-			continue
-		}
-
-		log.Printf("NextUp: %s(%s) %s, %d-%d\n", show.name, show.id, episode.id, episode.SeasonNo, episode.EpisodeNo)
-
-		// Find season and episode index
-		seasonIdx, epIdx := -1, -1
-		// seasonIdx = season.SeasonNo - 1
-		// epIdx = episode.EpisodeNo - 1
-		for si, s := range show.Seasons {
-			if s.id == season.id {
-				seasonIdx = si
-				for ei, e := range s.Episodes {
-					if e.id == episode.id {
-						epIdx = ei
-						break
-					}
-				}
-				break
-			}
-		}
-		if seasonIdx == -1 || epIdx == -1 {
-			continue
-		}
-
-		entry, exists := showMap[show.id]
-		// No entries for this show, add it
-		if !exists ||
-			// watched item is in next season
-			season.seasonno > entry.seasonNumber ||
-			// watched item is in same season but next episode
-			(season.seasonno == entry.seasonNumber && episode.EpisodeNo > entry.episodeNumber) {
-			showMap[show.id] = ShowEntry{
-				show:          show,
-				seasonNumber:  season.seasonno,
-				episodeNumber: episode.EpisodeNo,
-				seasonIdx:     seasonIdx,
-				epIdx:         epIdx,
-			}
-		}
-	}
-
-	log.Printf("NextUp: showMap: %+v\n", showMap)
-
-	nextUpEpisodeIDs = make([]string, 0)
-	for _, entry := range showMap {
-		item := entry.show
-		seasonIdx := entry.seasonIdx
-		epIdx := entry.epIdx
-
-		if seasonIdx < len(item.Seasons) {
-			season := &item.Seasons[seasonIdx]
-			if epIdx+1 < len(season.Episodes) {
-				log.Printf("Adding: in same season %s(%s) %s, %d-%d\n", item.name, item.id, season.Episodes[epIdx+1].id, seasonIdx, epIdx+1)
-				// Try next episode in same season
-				nextUpEpisodeIDs = append(nextUpEpisodeIDs, season.Episodes[epIdx+1].id)
-				continue
-			}
-			// Try first episode in next season
-			if seasonIdx+1 < len(item.Seasons) && len(item.Seasons[seasonIdx+1].Episodes) > 0 {
-				log.Printf("Adding: in next season %s(%s) %s, %d-%d\n", item.name, item.id, item.Seasons[seasonIdx+1].Episodes[0].id, seasonIdx+1, 0)
-				nextUpEpisodeIDs = append(nextUpEpisodeIDs, item.Seasons[seasonIdx+1].Episodes[0].id)
-			}
-		}
-	}
-
-	return nextUpEpisodeIDs, nil
-}
-
 // Statistics contains aggregate stats about the collection.
 type Statistics struct {
 	// Number of movies.
@@ -336,29 +496,54 @@ type Statistics struct {
 	ShowCount int
 	// Number of episodes.
 	EpisodeCount int
+	// Total size of all video files in bytes, hardlinked files are counted once.
+	TotalSize int64
+	// Number of video files that are a hardlink of another item's video file.
+	HardlinkCount int
 }
 
 // Statistics returns collection details such as genres, tags, ratings, etc.
 func (c *CollectionRepo) GetStatistics() Statistics {
-	var movieCount, showCount, episodeCount int
+	var movieCount, showCount, episodeCount, hardlinkCount int
+	var totalSize int64
+	seen := make(map[fileID]bool)
+	addFile := func(id fileID, size int64) {
+		if id.valid() {
+			if seen[id] {
+				hardlinkCount++
+				return
+			}
+			seen[id] = true
+		}
+		totalSize += size
+	}
 	for _, col := range c.GetCollections() {
 		for _, i := range col.Items {
 			switch v := i.(type) {
 			case *Movie:
 				movieCount++
+				addFile(v.fileID, v.fileSize)
+				for _, p := range v.parts {
+					addFile(p.fileID, p.fileSize)
+				}
 			case *Show:
 				showCount++
 				for _, season := range v.Seasons {
 					episodeCount += len(season.Episodes)
+					for _, e := range season.Episodes {
+						addFile(e.fileID, e.fileSize)
+					}
 				}
 			}
 		}
 	}
 
 	details := Statistics{
-		MovieCount:   movieCount,
-		ShowCount:    showCount,
-		EpisodeCount: episodeCount,
+		MovieCount:    movieCount,
+		ShowCount:     showCount,
+		EpisodeCount:  episodeCount,
+		TotalSize:     totalSize,
+		HardlinkCount: hardlinkCount,
 	}
 	return details
 }
@@ -393,9 +578,9 @@ func (j *CollectionRepo) BuildSearchIndex(ctx context.Context) error {
 	}
 
 	var docs []search.Document
-	for _, c := range j.collections {
+	for _, c := range j.GetCollections() {
 		for _, i := range c.Items {
-			docs = append(docs, makeSearchDocument(&c, i))
+			docs = append(docs, makeSearchDocument(c, i))
 		}
 	}
 
@@ -433,14 +618,6 @@ func (j *CollectionRepo) SearchPerson(ctx context.Context, term string) ([]strin
 	return j.bleveIndex.SearchPerson(ctx, term, searchResultCount)
 }
 
-// Similar performs a item search in collection repository and returns matching items.
-func (j *CollectionRepo) Similar(ctx context.Context, c *Collection, i Item) ([]string, error) {
-	if j.bleveIndex == nil {
-		return nil, SearchIndexNotInitializedError
-	}
-	return j.bleveIndex.Similar(ctx, makeSearchDocument(c, i), searchResultCount)
-}
-
 // makeSearchDocument creates a search document from a collection item.
 func makeSearchDocument(c *Collection, i Item) search.Document {
 	// Collect people involved in the item
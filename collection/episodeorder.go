@@ -0,0 +1,136 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/erikbos/jellofin-server/tmdb"
+)
+
+// Episode orders of the shows in a collection.
+const (
+	// EpisodeOrderAired orders episodes by the season and episode number of their filename, e.g. "S02E05".
+	EpisodeOrderAired = "aired"
+	// EpisodeOrderAbsolute shows all episodes in one season, numbered by absolute episode number.
+	EpisodeOrderAbsolute = "absolute"
+	// EpisodeOrderTvdb shows episodes in seasons, absolute numbered files are mapped to their season.
+	EpisodeOrderTvdb = "tvdb"
+)
+
+// episodeGuideTimeout is the maximum time to retrieve the episode guide of a show while scanning.
+const episodeGuideTimeout = 30 * time.Second
+
+// validEpisodeOrder returns an error if the episode order is not supported.
+func validEpisodeOrder(episodeOrder string) error {
+	switch episodeOrder {
+	case "", EpisodeOrderAired, EpisodeOrderAbsolute, EpisodeOrderTvdb:
+		return nil
+	}
+	return fmt.Errorf("unknown episode order %s", episodeOrder)
+}
+
+// absoluteNumbering returns true if episode filenames can have absolute episode numbers, e.g. "show - 123.mkv".
+func (c *Collection) absoluteNumbering() bool {
+	return c.EpisodeOrder == EpisodeOrderAbsolute || c.EpisodeOrder == EpisodeOrderTvdb
+}
+
+// applyEpisodeOrder sets the absolute episode number of all episodes of a show and moves
+// episodes to the season of the episode order of the collection. Season lengths are taken from
+// the episode guide, or from the available episodes if the show cannot be found in the guide.
+// Specials are not changed.
+func (cr *CollectionRepo) applyEpisodeOrder(coll *Collection, show *Show) {
+	if !coll.absoluteNumbering() {
+		return
+	}
+	seasonLengths := cr.seasonLengths(show)
+
+	// offset returns the number of episodes before the first episode of a season
+	offset := func(seasonNo int) (int, bool) {
+		total := 0
+		for sn := 1; sn < seasonNo; sn++ {
+			length, ok := seasonLengths[sn]
+			if !ok {
+				return 0, false
+			}
+			total += length
+		}
+		return total, true
+	}
+
+	var episodes Episodes
+	for i := range show.Seasons {
+		episodes = append(episodes, show.Seasons[i].Episodes...)
+		show.Seasons[i].Episodes = nil
+	}
+	for _, ep := range episodes {
+		switch {
+		case ep.SeasonNo == 0:
+		case ep.AbsoluteEpisodeNo > 0:
+			// Absolute numbered file, find the season it belongs to
+			if coll.EpisodeOrder != EpisodeOrderTvdb {
+				break
+			}
+			first, last := 0, 0
+			for sn := 1; ; sn++ {
+				length, ok := seasonLengths[sn]
+				if !ok {
+					// Episodes after the last known season, e.g. not in the guide yet, continue that season
+					if last > 0 {
+						ep.SeasonNo = last
+						ep.EpisodeNo = ep.AbsoluteEpisodeNo - (first - seasonLengths[last])
+					}
+					break
+				}
+				if ep.AbsoluteEpisodeNo <= first+length {
+					ep.SeasonNo = sn
+					ep.EpisodeNo = ep.AbsoluteEpisodeNo - first
+					break
+				}
+				first += length
+				last = sn
+			}
+		default:
+			if first, ok := offset(ep.SeasonNo); ok {
+				ep.AbsoluteEpisodeNo = first + ep.EpisodeNo
+			}
+		}
+		if coll.EpisodeOrder == EpisodeOrderAbsolute && ep.SeasonNo > 0 && ep.AbsoluteEpisodeNo > 0 {
+			ep.SeasonNo = 1
+			ep.EpisodeNo = ep.AbsoluteEpisodeNo
+		}
+		season := cr.getSeason(show, ep.SeasonNo)
+		season.Episodes = append(season.Episodes, ep)
+	}
+}
+
+// seasonLengths returns the number of episodes of each season of a show, specials are left out.
+func (cr *CollectionRepo) seasonLengths(show *Show) map[int]int {
+	lengths := make(map[int]int)
+	if cr.episodeGuide != nil && show.Metadata != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), episodeGuideTimeout)
+		defer cancel()
+		guide, err := cr.episodeGuide.ShowEpisodes(ctx, show.Metadata.ProviderIDs())
+		if err == nil {
+			for _, e := range guide {
+				if e.SeasonNumber > 0 {
+					lengths[e.SeasonNumber] = max(lengths[e.SeasonNumber], e.EpisodeNumber)
+				}
+			}
+			return lengths
+		}
+		if !errors.Is(err, tmdb.ErrNoProviderID) {
+			log.Printf("Show %s: cannot retrieve episode guide: %s", show.name, err)
+		}
+	}
+	for _, s := range show.Seasons {
+		for _, ep := range s.Episodes {
+			if ep.SeasonNo > 0 && ep.AbsoluteEpisodeNo == 0 {
+				lengths[ep.SeasonNo] = max(lengths[ep.SeasonNo], ep.EpisodeNo)
+			}
+		}
+	}
+	return lengths
+}
@@ -0,0 +1,33 @@
+package collection
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+// etagVersion is included in all etags. Increase it when the way items are presented
+// to clients changes, so clients refresh their cached copies of all items.
+const etagVersion = "2"
+
+// etagFile returns a hash of the name, size and modification time of a file.
+// The hashes of all files of an item are summed, so the order of files does not matter.
+func etagFile(f *FileInfo) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(f.Name()))
+	h.Write([]byte(strconv.FormatInt(f.Size(), 10)))
+	h.Write([]byte(strconv.FormatInt(f.Modtime().UnixNano(), 10)))
+	return h.Sum64()
+}
+
+// makeEtag returns an etag of an item based upon its id and the hash of its files.
+func makeEtag(id string, files uint64, children int) string {
+	return versionedEtag(etagVersion, id, files, children)
+}
+
+// versionedEtag returns an etag of an item for a version of the etag format.
+func versionedEtag(version string, id string, files uint64, children int) string {
+	return idhash.Hash(fmt.Sprintf("%s/%s/%x/%d", version, id, files, children))
+}
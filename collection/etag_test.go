@@ -0,0 +1,199 @@
+package collection
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database"
+	"github.com/erikbos/jellofin-server/database/sqlite"
+)
+
+// newFixtureRepo returns a repository with the default fixture library, scanned once.
+func newFixtureRepo(t *testing.T) (*CollectionRepo, *Fixture) {
+	t.Helper()
+	dir := t.TempDir()
+	fixture := DefaultFixture(filepath.Join(dir, "library"))
+	collections, err := fixture.Build()
+	if err != nil {
+		t.Fatalf("building fixture library: %s", err)
+	}
+	repo, err := database.New("sqlite", sqlite.ConfigFile{
+		Filename: filepath.Join(dir, "jellofin.db"),
+	})
+	if err != nil {
+		t.Fatalf("database.New: %s", err)
+	}
+	cr := New(&Options{
+		Collections:  collections,
+		Repo:         repo,
+		ThumbnailDir: filepath.Join(dir, "thumbnails"),
+	})
+	cr.Init()
+	return cr, fixture
+}
+
+// rescan scans all collections again and returns the etags of all items by ID.
+func rescan(cr *CollectionRepo) map[string]string {
+	for _, c := range cr.GetCollections() {
+		cr.updateCollection(c, 0)
+	}
+	return itemEtags(cr)
+}
+
+// itemEtags returns the etags of all movies, shows, seasons and episodes by ID.
+func itemEtags(cr *CollectionRepo) map[string]string {
+	etags := make(map[string]string)
+	for _, c := range cr.GetCollections() {
+		for _, i := range c.Items {
+			switch v := i.(type) {
+			case *Movie:
+				etags[v.ID()] = v.Etag()
+			case *Show:
+				etags[v.ID()] = v.Etag()
+				for _, season := range v.Seasons {
+					etags[season.ID()] = season.Etag()
+					for _, episode := range season.Episodes {
+						etags[episode.ID()] = episode.Etag()
+					}
+				}
+			}
+		}
+	}
+	return etags
+}
+
+// itemByName returns the ID of the movie or show with a name, which is its directory name.
+func itemByName(t *testing.T, cr *CollectionRepo, name string) string {
+	t.Helper()
+	for _, c := range cr.GetCollections() {
+		for _, i := range c.Items {
+			if i.Name() == name {
+				return i.ID()
+			}
+		}
+	}
+	t.Fatalf("no item named %s", name)
+	return ""
+}
+
+func TestEtagUnchangedRescan(t *testing.T) {
+	cr, _ := newFixtureRepo(t)
+	before := itemEtags(cr)
+	if len(before) == 0 {
+		t.Fatal("fixture library has no items")
+	}
+	after := rescan(cr)
+	for id, etag := range before {
+		if after[id] != etag {
+			t.Errorf("etag of item %s changed from %s to %s without changes on disk", id, etag, after[id])
+		}
+	}
+}
+
+func TestEtagFileChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(filename string) error
+	}{
+		{
+			name: "mtime",
+			change: func(filename string) error {
+				mtime := time.Now().Add(time.Hour)
+				return os.Chtimes(filename, mtime, mtime)
+			},
+		},
+		{
+			name: "size",
+			change: func(filename string) error {
+				fi, err := os.Stat(filename)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(filename, []byte("moov"), 0o644); err != nil {
+					return err
+				}
+				// keep the modification time, only the size changes
+				return os.Chtimes(filename, fi.ModTime(), fi.ModTime())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, fixture := newFixtureRepo(t)
+			movieID := itemByName(t, cr, "Casablanca (1942)")
+			otherID := itemByName(t, cr, "Heat (1995)")
+			before := itemEtags(cr)
+
+			filename := filepath.Join(fixture.Dir, "movies", "Casablanca (1942)", "casablanca.mp4")
+			if err := tt.change(filename); err != nil {
+				t.Fatal(err)
+			}
+			after := rescan(cr)
+			if after[movieID] == before[movieID] {
+				t.Errorf("etag of changed movie stayed %s", before[movieID])
+			}
+			if after[otherID] != before[otherID] {
+				t.Errorf("etag of unchanged movie changed from %s to %s", before[otherID], after[otherID])
+			}
+		})
+	}
+}
+
+func TestEtagEpisodes(t *testing.T) {
+	seasonDir := func(fixture *Fixture) string {
+		return filepath.Join(fixture.Dir, "shows", "The Wire (2002)", "S01")
+	}
+	tests := []struct {
+		name   string
+		change func(seasonDir string) error
+	}{
+		{
+			name: "episode added",
+			change: func(seasonDir string) error {
+				return os.WriteFile(filepath.Join(seasonDir, "the.wire.s01e04.episode.4.mp4"), nil, 0o644)
+			},
+		},
+		{
+			name: "episode removed",
+			change: func(seasonDir string) error {
+				for _, ext := range []string{".mp4", ".nfo"} {
+					if err := os.Remove(filepath.Join(seasonDir, "the.wire.s01e03.episode.3"+ext)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, fixture := newFixtureRepo(t)
+			showID := itemByName(t, cr, "The Wire (2002)")
+			before := itemEtags(cr)
+
+			if err := tt.change(seasonDir(fixture)); err != nil {
+				t.Fatal(err)
+			}
+			after := rescan(cr)
+			if after[showID] == before[showID] {
+				t.Errorf("etag of show stayed %s", before[showID])
+			}
+		})
+	}
+}
+
+func TestEtagVersion(t *testing.T) {
+	files := etagFile(&FileInfo{name: "casablanca.mp4", size: 1024, modtime: time.Unix(1700000000, 0), didstat: true})
+	current := makeEtag("EiwM6fOJmbTvtkYHapd3", files, 0)
+	if again := makeEtag("EiwM6fOJmbTvtkYHapd3", files, 0); again != current {
+		t.Errorf("etag is not stable: %s and %s", current, again)
+	}
+	if bumped := versionedEtag(etagVersion+"1", "EiwM6fOJmbTvtkYHapd3", files, 0); bumped == current {
+		t.Errorf("etag stayed %s after a version bump", current)
+	}
+	if children := makeEtag("EiwM6fOJmbTvtkYHapd3", files, 1); children == current {
+		t.Errorf("etag stayed %s with a different number of children", current)
+	}
+}
@@ -0,0 +1,90 @@
+package collection
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// ignoreFileName is the name of the file listing entries of a directory that should
+// not be scanned. An empty ignore file excludes the whole directory.
+const ignoreFileName = ".jellofinignore"
+
+// scanFilter decides which directory entries are skipped during a scan.
+type scanFilter struct {
+	// exclude holds the collection exclusion globs, matched against entry name
+	// and path relative to collection root.
+	exclude []string
+	// ignore holds the globs of the ignore file of the directory, matched against entry name.
+	ignore []string
+}
+
+// newScanFilter returns the scan filter for a directory in collection root directory
+// root. Returns false if the directory should not be scanned at all.
+func (c *Collection) newScanFilter(root, dir string) (scanFilter, bool) {
+	filter := scanFilter{exclude: c.Exclude}
+	patterns, found := readIgnoreFile(path.Join(root, dir, ignoreFileName))
+	if found && len(patterns) == 0 {
+		return filter, false
+	}
+	filter.ignore = patterns
+	return filter, true
+}
+
+// skip returns true if the entry at relative path rel should not be scanned.
+func (f scanFilter) skip(rel string) bool {
+	name := path.Base(rel)
+	for _, pattern := range f.exclude {
+		if globMatch(pattern, name) || globMatch(pattern, rel) {
+			return true
+		}
+	}
+	for _, pattern := range f.ignore {
+		if globMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the entries of directory dir that should be scanned.
+func (f scanFilter) filter(dir string, fi []FileInfo) []FileInfo {
+	if len(f.exclude) == 0 && len(f.ignore) == 0 {
+		return fi
+	}
+	entries := make([]FileInfo, 0, len(fi))
+	for _, e := range fi {
+		if !f.skip(path.Join(dir, e.Name())) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// globMatch returns true if name matches the shell pattern, case insensitive.
+func globMatch(pattern, name string) bool {
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return matched
+}
+
+// readIgnoreFile reads the globs from an ignore file, one per line. Empty lines
+// and lines starting with # are skipped. Returns false if the file does not exist.
+func readIgnoreFile(filename string) ([]string, bool) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, true
+}
@@ -0,0 +1,85 @@
+package collection
+
+import (
+	"log"
+	"path"
+)
+
+// BuildFileIndex builds the table of files found by the scanner, such as videos,
+// images and subtitles. Only these files are served, to prevent crafted
+// requests from reading arbitrary files in or outside of collection directories.
+func (cr *CollectionRepo) BuildFileIndex() {
+	index := make(map[string]struct{})
+	add := func(dir string, files ...string) {
+		for _, f := range files {
+			if f != "" {
+				index[path.Join(dir, f)] = struct{}{}
+			}
+		}
+	}
+	addSubs := func(dir string, subs ...Subtitles) {
+		for _, s := range subs {
+			for _, sub := range s {
+				add(dir, sub.Path)
+			}
+		}
+	}
+	for _, c := range cr.GetCollections() {
+		for _, item := range c.Items {
+			dir := c.ItemDirectory(item)
+			switch v := item.(type) {
+			case *Movie:
+				add(dir, v.fileName, v.banner, v.fanart, v.folder, v.poster)
+				for _, p := range v.parts {
+					add(dir, p.fileName)
+				}
+				addSubs(dir, v.SrtSubs, v.VttSubs)
+			case *Show:
+				add(dir, v.banner, v.fanart, v.folder, v.poster, v.logo, v.seasonAllBanner, v.seasonAllPoster)
+				for _, s := range v.Seasons {
+					add(dir, s.banner, s.fanart, s.poster)
+					for _, e := range s.Episodes {
+						add(dir, e.fileName, e.thumb)
+						addSubs(dir, e.SrtSubs, e.VttSubs)
+					}
+				}
+			}
+		}
+	}
+
+	cr.fileIndexMu.Lock()
+	cr.fileIndex = index
+	cr.fileIndexMu.Unlock()
+	log.Printf("File index added %d files.", len(index))
+}
+
+// RegisterFile adds a file stored next to media by the server, such as a downloaded
+// subtitle, to the file index. It can be served right away, before the next scan
+// finds it.
+func (cr *CollectionRepo) RegisterFile(filename string) {
+	cr.fileIndexMu.Lock()
+	defer cr.fileIndexMu.Unlock()
+	if cr.fileIndex == nil {
+		cr.fileIndex = make(map[string]struct{})
+	}
+	cr.fileIndex[path.Clean(filename)] = struct{}{}
+}
+
+// Registered returns true if filename is a file found by the scanner, an
+// extracted thumbnail or uploaded artwork.
+func (cr *CollectionRepo) Registered(filename string) bool {
+	filename = path.Clean(filename)
+	if cr.thumbnailDir != "" && path.Dir(filename) == path.Clean(cr.thumbnailDir) {
+		return true
+	}
+	cr.artworkMu.RLock()
+	_, uploaded := cr.artworkFiles[filename]
+	cr.artworkMu.RUnlock()
+	if uploaded {
+		return true
+	}
+	cr.fileIndexMu.RLock()
+	defer cr.fileIndexMu.RUnlock()
+	_, found := cr.fileIndex[filename]
+	return found
+}
@@ -0,0 +1,200 @@
+package collection
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// fingerprintSampleRate is the sample rate audio is decoded at for fingerprinting.
+	fingerprintSampleRate = 8000
+	// fingerprintFrameSize is the number of samples of a fingerprint frame, a power of two.
+	fingerprintFrameSize = 1024
+	// fingerprintHop is the number of samples between the start of two frames.
+	fingerprintHop = 64
+	// fingerprintBands is the number of frequency bands, each frame hash has one bit less.
+	fingerprintBands = 33
+	// fingerprintMinFreq and fingerprintMaxFreq are the frequency range of the bands in Hz.
+	fingerprintMinFreq = 300
+	fingerprintMaxFreq = 2000
+	// fingerprintMaxBitErrors is the maximum number of differing bits of matching frame hashes.
+	fingerprintMaxBitErrors = 6
+	// fingerprintMaxGap is the maximum gap of non-matching frames within a matching segment.
+	fingerprintMaxGap = time.Second
+	// fingerprintShifts is the number of most likely alignments of two fingerprints that are compared.
+	fingerprintShifts = 5
+	// fingerprintMaxRepeats is the number of times a hash can occur before it is no longer used to align fingerprints.
+	fingerprintMaxRepeats = 20
+)
+
+// fingerprint is an audio fingerprint, one hash per frame. Each bit of a hash tells if the
+// energy difference between two neighbouring frequency bands increased since the previous frame.
+type fingerprint []uint32
+
+// fingerprintFrameDuration returns the time between the start of two frames.
+func fingerprintFrameDuration() time.Duration {
+	return time.Duration(fingerprintHop) * time.Second / fingerprintSampleRate
+}
+
+// audioFingerprint decodes the first part of the audio of a video file and returns its fingerprint.
+func (cr *CollectionRepo) audioFingerprint(ctx context.Context, filename string, duration time.Duration) (fingerprint, error) {
+	cmd := exec.CommandContext(ctx, cr.ffmpeg,
+		"-nostdin", "-loglevel", "error",
+		"-t", strconv.FormatFloat(duration.Seconds(), 'f', 3, 64),
+		"-i", filename,
+		"-vn", "-ac", "1", "-ar", strconv.Itoa(fingerprintSampleRate),
+		"-f", "s16le", "-")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+				return nil, fmt.Errorf("%w: %s", err, msg)
+			}
+		}
+		return nil, err
+	}
+	samples := make([]float64, len(output)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(output[i*2:])))
+	}
+	return makeFingerprint(samples), nil
+}
+
+// makeFingerprint returns the fingerprint of mono audio samples at fingerprintSampleRate.
+func makeFingerprint(samples []float64) fingerprint {
+	window := make([]float64, fingerprintFrameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fingerprintFrameSize-1))
+	}
+	// Band edges as FFT bins, spaced logarithmically
+	edges := make([]int, fingerprintBands+1)
+	for b := range edges {
+		freq := fingerprintMinFreq * math.Pow(fingerprintMaxFreq/fingerprintMinFreq, float64(b)/fingerprintBands)
+		edges[b] = int(freq * fingerprintFrameSize / fingerprintSampleRate)
+	}
+
+	var fp fingerprint
+	var previous []float64
+	frame := make([]complex128, fingerprintFrameSize)
+	for start := 0; start+fingerprintFrameSize <= len(samples); start += fingerprintHop {
+		for i := range frame {
+			frame[i] = complex(samples[start+i]*window[i], 0)
+		}
+		fft(frame)
+		energy := make([]float64, fingerprintBands)
+		for b := range energy {
+			for bin := edges[b]; bin < max(edges[b+1], edges[b]+1); bin++ {
+				energy[b] += math.Pow(cmplx.Abs(frame[bin]), 2)
+			}
+		}
+		if previous != nil {
+			var hash uint32
+			for b := 0; b < fingerprintBands-1; b++ {
+				if (energy[b]-energy[b+1])-(previous[b]-previous[b+1]) > 0 {
+					hash |= 1 << b
+				}
+			}
+			fp = append(fp, hash)
+		}
+		previous = energy
+	}
+	return fp
+}
+
+// fft is an in place radix-2 fast fourier transform, the length of x must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// commonSegment returns the longest stretch of audio two fingerprints have in common, as
+// start and end of the stretch in both. Returns false if there is none.
+func commonSegment(a, b fingerprint) (startA, endA, startB, endB time.Duration, found bool) {
+	// Find the most likely alignments from frames with identical hashes. Hashes that occur
+	// often, such as that of silence, do not tell much and are skipped.
+	positions := make(map[uint32][]int)
+	for j, hash := range b {
+		positions[hash] = append(positions[hash], j)
+	}
+	votes := make(map[int]int)
+	for i, hash := range a {
+		if hash == 0 || len(positions[hash]) > fingerprintMaxRepeats {
+			continue
+		}
+		for _, j := range positions[hash] {
+			votes[i-j]++
+		}
+	}
+	shifts := make([]int, 0, len(votes))
+	for shift, count := range votes {
+		if count > 1 {
+			shifts = append(shifts, shift)
+		}
+	}
+	// Most votes first, smallest shift first for equal votes to be deterministic
+	slices.SortFunc(shifts, func(x, y int) int {
+		return cmp.Or(cmp.Compare(votes[y], votes[x]), cmp.Compare(x, y))
+	})
+	if len(shifts) > fingerprintShifts {
+		shifts = shifts[:fingerprintShifts]
+	}
+
+	maxGap := int(fingerprintMaxGap / fingerprintFrameDuration())
+	bestStart, bestEnd, bestShift := 0, 0, 0
+	for _, shift := range shifts {
+		// Walk the overlapping frames, i in a and i-shift in b
+		runStart, lastMatch := -1, -1
+		for i := max(shift, 0); i < len(a) && i-shift < len(b); i++ {
+			// Silence has an empty hash and is never considered common audio
+			if a[i] == 0 || bits.OnesCount32(a[i]^b[i-shift]) > fingerprintMaxBitErrors {
+				if runStart != -1 && i-lastMatch > maxGap {
+					runStart = -1
+				}
+				continue
+			}
+			if runStart == -1 {
+				runStart = i
+			}
+			lastMatch = i
+			if lastMatch-runStart > bestEnd-bestStart {
+				bestStart, bestEnd, bestShift = runStart, lastMatch, shift
+			}
+		}
+	}
+	if bestEnd == bestStart {
+		return 0, 0, 0, 0, false
+	}
+	frame := fingerprintFrameDuration()
+	return time.Duration(bestStart) * frame, time.Duration(bestEnd+1) * frame,
+		time.Duration(bestStart-bestShift) * frame, time.Duration(bestEnd+1-bestShift) * frame, true
+}
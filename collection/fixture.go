@@ -0,0 +1,267 @@
+package collection
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fixture builds a small library on disk, with video files, NFO files and posters laid out
+// the way Kodi does. It is meant for tests that need a scanned library, such as end-to-end
+// tests of the APIs. Video files are empty, so they cannot be played or probed.
+type Fixture struct {
+	// Dir is the directory the library is created in.
+	Dir string
+	// Movies are the movies in the movies collection.
+	Movies []FixtureMovie
+	// Shows are the shows in the shows collection.
+	Shows []FixtureShow
+}
+
+// FixtureMovie is a movie of a fixture library.
+type FixtureMovie struct {
+	// Title is the title of the movie, e.g. "Casablanca".
+	Title string
+	// Year is the release year, used in the directory name.
+	Year int
+	// Premiered is the premiere date in NFO format, e.g. "1942-11-26". Empty leaves it out.
+	Premiered string
+	// Genres are the genres, e.g. "Drama".
+	Genres []string
+	// Rating is the rating (0.0 - 10.0).
+	Rating float64
+	// Actors are the names of the actors.
+	Actors []string
+	// IMDbID is the IMDb id, e.g. "tt0034583". Empty leaves it out.
+	IMDbID string
+	// NoNfo leaves out the NFO file, so metadata is derived from the filename.
+	NoNfo bool
+	// NoPoster leaves out the poster image.
+	NoPoster bool
+}
+
+// FixtureShow is a show of a fixture library.
+type FixtureShow struct {
+	// Title is the title of the show, e.g. "The Wire".
+	Title string
+	// Year is the year the show started, used in the directory name.
+	Year int
+	// Genres are the genres, e.g. "Crime".
+	Genres []string
+	// Episodes is the number of episodes of each season, by season number. Season 0 holds specials.
+	Episodes map[int]int
+	// NoNfo leaves out the NFO files of the show and its episodes.
+	NoNfo bool
+}
+
+// fixtureNfo is an NFO file of a movie, show or episode.
+type fixtureNfo struct {
+	XMLName   xml.Name
+	Title     string            `xml:"title"`
+	Premiered string            `xml:"premiered,omitempty"`
+	Aired     string            `xml:"aired,omitempty"`
+	Season    int               `xml:"season,omitempty"`
+	Episode   int               `xml:"episode,omitempty"`
+	Genres    []string          `xml:"genre"`
+	Rating    float64           `xml:"rating,omitempty"`
+	Actors    []fixtureActor    `xml:"actor"`
+	UniqueIDs []fixtureUniqueID `xml:"uniqueid"`
+}
+
+type fixtureActor struct {
+	Name string `xml:"name"`
+}
+
+type fixtureUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	ID      string `xml:",chardata"`
+}
+
+// DefaultFixture returns a fixture with two movies, one without NFO file, and a show with
+// two seasons and specials.
+func DefaultFixture(dir string) *Fixture {
+	return &Fixture{
+		Dir: dir,
+		Movies: []FixtureMovie{
+			{
+				Title:     "Casablanca",
+				Year:      1942,
+				Premiered: "1942-11-26",
+				Genres:    []string{"Drama", "Romance"},
+				Rating:    8.5,
+				Actors:    []string{"Humphrey Bogart", "Ingrid Bergman"},
+				IMDbID:    "tt0034583",
+			},
+			{
+				Title: "Heat",
+				Year:  1995,
+				NoNfo: true,
+			},
+		},
+		Shows: []FixtureShow{
+			{
+				Title:    "The Wire",
+				Year:     2002,
+				Genres:   []string{"Crime", "Drama"},
+				Episodes: map[int]int{0: 1, 1: 3, 2: 2},
+			},
+		},
+	}
+}
+
+// Build writes the library to disk and returns the movies and shows collections, ready to be
+// passed to New as Options.Collections.
+func (f *Fixture) Build() (Collections, error) {
+	moviesDir := filepath.Join(f.Dir, "movies")
+	showsDir := filepath.Join(f.Dir, "shows")
+	for _, dir := range []string{moviesDir, showsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range f.Movies {
+		if err := m.build(moviesDir); err != nil {
+			return nil, fmt.Errorf("fixture movie %s: %w", m.Title, err)
+		}
+	}
+	for _, s := range f.Shows {
+		if err := s.build(showsDir); err != nil {
+			return nil, fmt.Errorf("fixture show %s: %w", s.Title, err)
+		}
+	}
+	return Collections{
+		{
+			ID:          "fixturemovies",
+			Name:        "Movies",
+			Type:        CollectionTypeMovies,
+			Directories: []string{moviesDir},
+		},
+		{
+			ID:          "fixtureshows",
+			Name:        "Shows",
+			Type:        CollectionTypeShows,
+			Directories: []string{showsDir},
+		},
+	}, nil
+}
+
+func (m FixtureMovie) build(moviesDir string) error {
+	dir := filepath.Join(moviesDir, fmt.Sprintf("%s (%d)", m.Title, m.Year))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := fixtureFilename(m.Title)
+	if err := os.WriteFile(filepath.Join(dir, base+".mp4"), nil, 0o644); err != nil {
+		return err
+	}
+	if !m.NoPoster {
+		if err := writeFixtureImage(filepath.Join(dir, "poster.jpg"), 200, 300); err != nil {
+			return err
+		}
+	}
+	if m.NoNfo {
+		return nil
+	}
+	nfo := fixtureNfo{
+		XMLName:   xml.Name{Local: "movie"},
+		Title:     m.Title,
+		Premiered: m.Premiered,
+		Genres:    m.Genres,
+		Rating:    m.Rating,
+	}
+	for _, actor := range m.Actors {
+		nfo.Actors = append(nfo.Actors, fixtureActor{Name: actor})
+	}
+	if m.IMDbID != "" {
+		nfo.UniqueIDs = append(nfo.UniqueIDs, fixtureUniqueID{Type: "imdb", Default: true, ID: m.IMDbID})
+	}
+	return writeFixtureNfo(filepath.Join(dir, base+".nfo"), nfo)
+}
+
+func (s FixtureShow) build(showsDir string) error {
+	dir := filepath.Join(showsDir, fmt.Sprintf("%s (%d)", s.Title, s.Year))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeFixtureImage(filepath.Join(dir, "poster.jpg"), 200, 300); err != nil {
+		return err
+	}
+	if !s.NoNfo {
+		nfo := fixtureNfo{
+			XMLName:   xml.Name{Local: "tvshow"},
+			Title:     s.Title,
+			Premiered: fmt.Sprintf("%d-01-01", s.Year),
+			Genres:    s.Genres,
+		}
+		if err := writeFixtureNfo(filepath.Join(dir, "tvshow.nfo"), nfo); err != nil {
+			return err
+		}
+	}
+
+	base := fixtureFilename(s.Title)
+	for season, episodes := range s.Episodes {
+		seasonDir := filepath.Join(dir, fmt.Sprintf("S%02d", season))
+		if err := os.MkdirAll(seasonDir, 0o755); err != nil {
+			return err
+		}
+		for episode := 1; episode <= episodes; episode++ {
+			title := fmt.Sprintf("Episode %d", episode)
+			name := fmt.Sprintf("%s.s%02de%02d.%s", base, season, episode, fixtureFilename(title))
+			if err := os.WriteFile(filepath.Join(seasonDir, name+".mp4"), nil, 0o644); err != nil {
+				return err
+			}
+			if s.NoNfo {
+				continue
+			}
+			nfo := fixtureNfo{
+				XMLName: xml.Name{Local: "episodedetails"},
+				Title:   title,
+				Aired:   fmt.Sprintf("%d-%02d-%02d", s.Year+max(season-1, 0), min(season+1, 12), episode),
+				Season:  season,
+				Episode: episode,
+			}
+			if err := writeFixtureNfo(filepath.Join(seasonDir, name+".nfo"), nfo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fixtureFilename returns the base filename of a title, e.g. "the.wire" for "The Wire".
+func fixtureFilename(title string) string {
+	return strings.ReplaceAll(strings.ToLower(title), " ", ".")
+}
+
+func writeFixtureNfo(filename string, nfo fixtureNfo) error {
+	data, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append([]byte(xml.Header), data...), 0o644)
+}
+
+// writeFixtureImage writes a single color JPEG image.
+func writeFixtureImage(filename string, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: 40, G: 80, B: 120, A: 255})
+		}
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,83 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// collectionState is the content state of a collection, stored so LastUpdate
+// survives restarts.
+type collectionState struct {
+	Etag       string    `json:"etag"`
+	LastUpdate time.Time `json:"lastupdate"`
+}
+
+// collectionStateKey returns the settings key the state of a collection is stored under.
+func collectionStateKey(collectionID string) string {
+	return "collection." + collectionID + ".state"
+}
+
+// updateFreshness updates the etag, LastUpdate and DateLastMediaAdded of a collection
+// after a scan. LastUpdate only changes if items were added, removed or changed.
+func (cr *CollectionRepo) updateFreshness(c *Collection) {
+	h := fnv.New64a()
+	var lastMediaAdded time.Time
+	for _, i := range c.Items {
+		fmt.Fprintf(h, "%s/%s\n", i.ID(), itemEtag(i))
+		if added := itemAdded(i); added.After(lastMediaAdded) {
+			lastMediaAdded = added
+		}
+	}
+	etag := fmt.Sprintf("%x", h.Sum64())
+	c.DateLastMediaAdded = lastMediaAdded
+	if etag == c.Etag {
+		return
+	}
+
+	// After a restart the contents might not have changed since the stored state
+	if c.Etag == "" && cr.repo != nil {
+		var stored collectionState
+		if value, err := cr.repo.GetSetting(context.Background(), collectionStateKey(c.ID)); err == nil &&
+			json.Unmarshal([]byte(value), &stored) == nil && stored.Etag == etag {
+			c.Etag = stored.Etag
+			c.LastUpdate = stored.LastUpdate
+			return
+		}
+	}
+	state := collectionState{
+		Etag:       etag,
+		LastUpdate: time.Now().UTC(),
+	}
+	c.Etag = state.Etag
+	c.LastUpdate = state.LastUpdate
+	if cr.repo == nil {
+		return
+	}
+	value, _ := json.Marshal(state)
+	if err := cr.repo.UpsertSetting(context.Background(), collectionStateKey(c.ID), string(value)); err != nil {
+		log.Printf("Failed to store state of collection %s: %s", c.Name, err)
+	}
+}
+
+// itemEtag returns the etag of an item, empty if the item does not have one.
+func itemEtag(i Item) string {
+	if e, ok := i.(interface{ Etag() string }); ok {
+		return e.Etag()
+	}
+	return ""
+}
+
+// itemAdded returns the time the most recent video of an item was added.
+func itemAdded(i Item) time.Time {
+	switch v := i.(type) {
+	case *Movie:
+		return v.Created()
+	case *Show:
+		return v.LastVideo()
+	}
+	return time.Time{}
+}
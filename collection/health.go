@@ -0,0 +1,128 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// healthCheckInterval is the time between checks of collection directories.
+	healthCheckInterval = 30 * time.Second
+	// healthCheckTimeout is the maximum time reading a collection directory may take,
+	// reads on stale network mounts tend to hang.
+	healthCheckTimeout = 10 * time.Second
+)
+
+var (
+	ErrStorageTimeout = errors.New("timeout reading directory")
+	ErrStorageEmpty   = errors.New("directory is empty, share not mounted?")
+)
+
+// Offline returns true if the file is stored in a collection directory that is
+// currently not available, e.g. because a network share dropped.
+func (cr *CollectionRepo) Offline(filename string) bool {
+	cr.offlineMu.RLock()
+	defer cr.offlineMu.RUnlock()
+	for root := range cr.offline {
+		if filename == root || strings.HasPrefix(filename, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectionOffline returns true if all directories of a collection are not available.
+func (cr *CollectionRepo) CollectionOffline(c *Collection) bool {
+	if len(c.Directories) == 0 {
+		return false
+	}
+	cr.offlineMu.RLock()
+	defer cr.offlineMu.RUnlock()
+	for _, root := range c.Directories {
+		if _, offline := cr.offline[path.Clean(root)]; !offline {
+			return false
+		}
+	}
+	return true
+}
+
+// healthCheckBackground keeps checking whether collection directories are available.
+func (cr *CollectionRepo) healthCheckBackground(ctx context.Context) {
+	for {
+		cr.checkCollectionsHealth()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+// checkCollectionsHealth checks all collection directories and marks them offline
+// or online again.
+func (cr *CollectionRepo) checkCollectionsHealth() {
+	for _, c := range cr.GetCollections() {
+		for _, root := range c.Directories {
+			root = path.Clean(root)
+			err := cr.checkDirectory(root)
+			// A mount point of a share that dropped is often an empty directory
+			if errors.Is(err, io.EOF) {
+				err = nil
+				if len(c.Items) > 0 {
+					err = ErrStorageEmpty
+				}
+			}
+
+			cr.offlineMu.Lock()
+			_, wasOffline := cr.offline[root]
+			switch {
+			case err != nil && !wasOffline:
+				log.Printf("Collection %s: directory %s is offline: %s", c.Name, root, err)
+				cr.offline[root] = struct{}{}
+			case err == nil && wasOffline:
+				log.Printf("Collection %s: directory %s is online again", c.Name, root)
+				delete(cr.offline, root)
+			}
+			cr.offlineMu.Unlock()
+		}
+	}
+}
+
+// checkDirectory returns an error if a directory cannot be read within healthCheckTimeout.
+// A directory read that hangs is not retried until it finishes, to avoid piling up
+// blocked goroutines on a stale mount.
+func (cr *CollectionRepo) checkDirectory(dir string) error {
+	cr.offlineMu.Lock()
+	if cr.checking[dir] {
+		cr.offlineMu.Unlock()
+		return ErrStorageTimeout
+	}
+	cr.checking[dir] = true
+	cr.offlineMu.Unlock()
+
+	result := make(chan error, 1)
+	go func() {
+		f, err := os.Open(dir)
+		if err == nil {
+			_, err = f.Readdirnames(1)
+			f.Close()
+		}
+		cr.offlineMu.Lock()
+		delete(cr.checking, dir)
+		cr.offlineMu.Unlock()
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(healthCheckTimeout):
+		return ErrStorageTimeout
+	}
+}
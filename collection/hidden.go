@@ -0,0 +1,159 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// hiddenItemsKey is the settings key the IDs of items hidden for all users are stored under.
+const hiddenItemsKey = "collection.hidden"
+
+// trashDirName is the directory in a collection directory deleted items are moved to.
+// Like all names starting with a dot it is skipped by the scanner.
+const trashDirName = ".trash"
+
+var ErrTrashNotSupported = errors.New("only movies, shows and episodes can be deleted")
+
+// Hidden returns true if an item has been hidden for all users, or has been moved to the trash.
+func (cr *CollectionRepo) Hidden(itemID string) bool {
+	cr.hiddenMu.RLock()
+	defer cr.hiddenMu.RUnlock()
+	_, hidden := cr.hidden[itemID]
+	_, trashed := cr.trashed[itemID]
+	return hidden || trashed
+}
+
+// HiddenItems returns the IDs of the items hidden for all users.
+func (cr *CollectionRepo) HiddenItems() []string {
+	cr.hiddenMu.RLock()
+	defer cr.hiddenMu.RUnlock()
+	return slices.Sorted(maps.Keys(cr.hidden))
+}
+
+// SetHidden hides an item for all users, or shows it again. The setting is stored right away.
+func (cr *CollectionRepo) SetHidden(ctx context.Context, itemID string, hidden bool) error {
+	cr.hiddenMu.Lock()
+	if hidden {
+		cr.hidden[itemID] = struct{}{}
+	} else {
+		delete(cr.hidden, itemID)
+	}
+	value, _ := json.Marshal(slices.Sorted(maps.Keys(cr.hidden)))
+	cr.hiddenMu.Unlock()
+
+	if cr.repo != nil {
+		return cr.repo.UpsertSetting(ctx, hiddenItemsKey, string(value))
+	}
+	return nil
+}
+
+// loadHiddenItems loads the IDs of the items hidden for all users.
+func (cr *CollectionRepo) loadHiddenItems() {
+	if cr.repo == nil {
+		return
+	}
+	value, err := cr.repo.GetSetting(context.Background(), hiddenItemsKey)
+	if err != nil || value == "" {
+		return
+	}
+	var itemIDs []string
+	if err := json.Unmarshal([]byte(value), &itemIDs); err != nil {
+		log.Printf("Failed to load hidden items: %s", err)
+		return
+	}
+	cr.hiddenMu.Lock()
+	defer cr.hiddenMu.Unlock()
+	for _, id := range itemIDs {
+		cr.hidden[id] = struct{}{}
+	}
+}
+
+// TrashItem moves the files of a movie, show or episode to the trash directory of the collection
+// directory it was found in, keeping their path relative to the collection directory. Emptying
+// the trash is up to the administrator, moving the files back restores the item. The item is
+// hidden right away, the next scan removes it.
+func (cr *CollectionRepo) TrashItem(c *Collection, i Item) error {
+	root := itemRoot(i)
+	if root == "" && len(c.Directories) > 0 {
+		root = c.Directories[0]
+	}
+	switch v := i.(type) {
+	case *Movie, *Show:
+		if err := moveToTrash(root, v.Path()); err != nil {
+			return err
+		}
+	case *Episode:
+		dir := path.Join(v.path, path.Dir(v.fileName))
+		entries, err := os.ReadDir(path.Join(root, dir))
+		if err != nil {
+			return err
+		}
+		// Move the video together with its NFO, thumb and subtitles, e.g. "casablanca.s01e01.nl.srt"
+		for _, e := range entries {
+			if e.IsDir() || !episodeFile(v, e.Name()) {
+				continue
+			}
+			if err := moveToTrash(root, path.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrTrashNotSupported
+	}
+	log.Printf("Collection %s: moved %s to trash", c.Name, i.Name())
+
+	cr.hiddenMu.Lock()
+	cr.trashed[i.ID()] = time.Now()
+	cr.hiddenMu.Unlock()
+	return nil
+}
+
+// episodeSidecar matches the files belonging to an episode after its base name: NFO,
+// thumb, subtitles and lyrics, e.g. ".nfo", "-thumb.jpg" and ".nl.forced.srt".
+var episodeSidecar = regexp.MustCompile(`(?i)^(\.nfo|\.tbn|\.lrc|-thumb\.(jpe?g|png|webp|tbn)|(\.[a-z]{2,3}([-_][a-z0-9]+)?)?(\.forced|\.sdh)?\.(srt|vtt))$`)
+
+// episodeFile returns true if a file in the directory of an episode is its video or one of
+// its sidecar files. Other videos that start with the same name, e.g. "show s01e01-e02.mkv",
+// are not.
+func episodeFile(e *Episode, name string) bool {
+	if name == path.Base(e.fileName) {
+		return true
+	}
+	rest, found := strings.CutPrefix(name, e.baseName)
+	return found && episodeSidecar.MatchString(rest)
+}
+
+// forgetTrashed forgets the items moved to the trash before a scan started, the scan removed them.
+func (cr *CollectionRepo) forgetTrashed(scanStart time.Time) {
+	cr.hiddenMu.Lock()
+	defer cr.hiddenMu.Unlock()
+	maps.DeleteFunc(cr.trashed, func(_ string, trashed time.Time) bool {
+		return trashed.Before(scanStart)
+	})
+}
+
+// moveToTrash moves a file or directory, relative to collection directory root, to the trash directory.
+// If the trash already holds an entry with that name the current time is appended.
+func moveToTrash(root, rel string) error {
+	if rel == "" || rel == "." || strings.HasPrefix(path.Clean(rel), "..") {
+		return fmt.Errorf("cannot move %s to trash", rel)
+	}
+	dest := path.Join(root, trashDirName, rel)
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		dest += "." + time.Now().Format("20060102150405")
+	}
+	return os.Rename(path.Join(root, rel), dest)
+}
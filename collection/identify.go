@@ -0,0 +1,129 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// providerIDsKey is the settings key the provider ID overrides are stored under.
+const providerIDsKey = "collection.providerids"
+
+// identifiedMetadata is metadata of which the provider IDs have been corrected through the API,
+// or of which fields have been locked in the metadata editor.
+type identifiedMetadata struct {
+	metadata.Metadata
+	providerIDs map[string]string
+	lock        *ItemLock
+}
+
+// ProviderIDs returns the provider IDs of the metadata, replaced by the corrected ones.
+func (m *identifiedMetadata) ProviderIDs() map[string]string {
+	ids := make(map[string]string)
+	for provider, id := range m.Metadata.ProviderIDs() {
+		if _, ok := m.providerIDs[providerIndexKeyName(provider)]; !ok {
+			ids[provider] = id
+		}
+	}
+	maps.Copy(ids, m.providerIDs)
+	return ids
+}
+
+// Unwrap returns the metadata without corrected provider IDs and locked fields.
+func (m *identifiedMetadata) Unwrap() metadata.Metadata {
+	return m.Metadata
+}
+
+// itemIdentified returns the metadata of an item with the provider IDs set through the API
+// and the fields locked in the metadata editor applied.
+func (cr *CollectionRepo) itemIdentified(itemID string, m metadata.Metadata) metadata.Metadata {
+	if u, ok := m.(*identifiedMetadata); ok {
+		m = u.Metadata
+	}
+	cr.providerIDsMu.RLock()
+	ids := cr.providerIDs[itemID]
+	cr.providerIDsMu.RUnlock()
+	lock := cr.ItemLock(itemID)
+	if m == nil || (len(ids) == 0 && lock == nil) {
+		return m
+	}
+	return &identifiedMetadata{Metadata: m, providerIDs: ids, lock: lock}
+}
+
+// ProviderIDsOverride returns the provider IDs set for an item through the API, nil if not set.
+func (cr *CollectionRepo) ProviderIDsOverride(itemID string) map[string]string {
+	cr.providerIDsMu.RLock()
+	defer cr.providerIDsMu.RUnlock()
+	return maps.Clone(cr.providerIDs[itemID])
+}
+
+// SetProviderIDs corrects the provider IDs (e.g. {"tmdb": "289", "imdb": "tt0034583"}) of a
+// movie or show that was matched wrongly. They replace the IDs of the same provider found in
+// its metadata, IDs of other providers are kept. Empty providerIDs removes the correction.
+// The correction is stored and applied to the item right away.
+func (cr *CollectionRepo) SetProviderIDs(ctx context.Context, itemID string, providerIDs map[string]string) error {
+	ids := make(map[string]string)
+	for provider, id := range providerIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			ids[providerIndexKeyName(provider)] = id
+		}
+	}
+	cr.providerIDsMu.Lock()
+	if len(ids) == 0 {
+		delete(cr.providerIDs, itemID)
+	} else {
+		cr.providerIDs[itemID] = ids
+	}
+	value, _ := json.Marshal(cr.providerIDs)
+	cr.providerIDsMu.Unlock()
+
+	if cr.repo != nil {
+		if err := cr.repo.UpsertSetting(ctx, providerIDsKey, string(value)); err != nil {
+			return err
+		}
+	}
+	_, i := cr.GetItemByID(itemID)
+	switch i := i.(type) {
+	case *Movie:
+		i.Metadata = cr.itemIdentified(i.id, i.Metadata)
+		for _, p := range i.parts {
+			p.Metadata = i.Metadata
+		}
+	case *Show:
+		i.Metadata = cr.itemIdentified(i.id, i.Metadata)
+	}
+	cr.BuildProviderIndex()
+	return nil
+}
+
+// loadProviderIDs loads the stored provider ID overrides.
+func (cr *CollectionRepo) loadProviderIDs() {
+	if cr.repo == nil {
+		return
+	}
+	value, err := cr.repo.GetSetting(context.Background(), providerIDsKey)
+	if err != nil || value == "" {
+		return
+	}
+	var providerIDs map[string]map[string]string
+	if err := json.Unmarshal([]byte(value), &providerIDs); err != nil {
+		log.Printf("Failed to load provider id overrides: %s", err)
+		return
+	}
+	cr.providerIDsMu.Lock()
+	defer cr.providerIDsMu.Unlock()
+	cr.providerIDs = providerIDs
+}
+
+// providerIndexKeyName returns the name of a provider as used in the provider index, e.g. "tmdb" for "TheMovieDb".
+func providerIndexKeyName(provider string) string {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if alias, ok := providerAliases[provider]; ok {
+		return alias
+	}
+	return provider
+}
@@ -0,0 +1,41 @@
+package collection
+
+import "fmt"
+
+// Image formats images of a collection can be served in.
+const (
+	// ImageFormatOriginal serves images in the format they are stored in.
+	ImageFormatOriginal = "original"
+	// ImageFormatJPEG converts png images to jpeg, which is much smaller for photos such as backdrops.
+	ImageFormatJPEG = "jpeg"
+)
+
+// ImageOptions are the quality, size and format of the images served from a collection.
+type ImageOptions struct {
+	// PosterQuality is the JPEG quality of posters, logos and thumbs (1-100), 0 uses the server setting.
+	PosterQuality int
+	// BackdropQuality is the JPEG quality of backdrops (1-100), 0 serves backdrops as is unless resized.
+	BackdropQuality int
+	// MaxWidth and MaxHeight limit the size of images served in pixels, 0 means no limit.
+	MaxWidth  int
+	MaxHeight int
+	// Format is ImageFormatOriginal or ImageFormatJPEG. Empty is the same as original.
+	Format string
+}
+
+// validate returns an error if an image option is out of range.
+func (o ImageOptions) validate() error {
+	for _, q := range []int{o.PosterQuality, o.BackdropQuality} {
+		if q < 0 || q > 100 {
+			return fmt.Errorf("image quality %d out of range 1-100", q)
+		}
+	}
+	if o.MaxWidth < 0 || o.MaxHeight < 0 {
+		return fmt.Errorf("negative maximum image size %dx%d", o.MaxWidth, o.MaxHeight)
+	}
+	switch o.Format {
+	case "", ImageFormatOriginal, ImageFormatJPEG:
+		return nil
+	}
+	return fmt.Errorf("unknown image format %s", o.Format)
+}
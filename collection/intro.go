@@ -0,0 +1,181 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os/exec"
+	"path"
+	"time"
+)
+
+const (
+	// introsKey is the settings key the detected intros are stored under.
+	introsKey = "collection.intros"
+	// introSearchDuration is the part at the start of episodes that is searched for the intro.
+	introSearchDuration = 10 * time.Minute
+	// introMinDuration and introMaxDuration are the minimum and maximum duration of an intro.
+	introMinDuration = 15 * time.Second
+	introMaxDuration = 2 * time.Minute
+	// introDecodeTimeout is the maximum time ffmpeg may take to decode the audio of an episode.
+	introDecodeTimeout = 5 * time.Minute
+)
+
+// IntroSegment is the intro of an episode, from the start of the intro up to the end.
+// Both are zero for an episode that has been analyzed and has no intro.
+type IntroSegment struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
+// IntroDetectionResult is the result of an intro detection run.
+type IntroDetectionResult struct {
+	// Seasons is the number of seasons analyzed.
+	Seasons int
+	// Episodes is the number of episodes analyzed.
+	Episodes int
+	// Detected is the number of analyzed episodes an intro was found in.
+	Detected int
+	// Failed is the number of episodes of which the audio could not be decoded.
+	Failed int
+}
+
+// Intro returns the intro of an episode. Returns false if the episode has no intro, or
+// has not been analyzed yet.
+func (cr *CollectionRepo) Intro(episodeID string) (IntroSegment, bool) {
+	cr.introsMu.RLock()
+	defer cr.introsMu.RUnlock()
+	intro, ok := cr.intros[episodeID]
+	return intro, ok && intro.End > intro.Start
+}
+
+// DetectIntros searches the intros of episodes that have not been analyzed yet. Episodes
+// of a season share their intro, so the audio at the start of each episode is compared with
+// that of the episodes before and after it. The longest stretch of audio they have in common
+// is the intro. Specials are skipped as they rarely share an intro.
+func (cr *CollectionRepo) DetectIntros(ctx context.Context) (result IntroDetectionResult, err error) {
+	if _, err := exec.LookPath(cr.ffmpeg); err != nil {
+		return result, err
+	}
+	for _, c := range cr.GetCollections() {
+		if c.Type != CollectionTypeShows || cr.CollectionOffline(c) {
+			continue
+		}
+		for _, i := range c.Items {
+			show, ok := i.(*Show)
+			if !ok {
+				continue
+			}
+			for si := range show.Seasons {
+				if err := ctx.Err(); err != nil {
+					return result, err
+				}
+				season := &show.Seasons[si]
+				if season.seasonno == 0 || len(season.Episodes) < 2 || cr.seasonAnalyzed(season) {
+					continue
+				}
+				if err := cr.detectSeasonIntros(ctx, c, season, &result); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// seasonAnalyzed returns true if all episodes of a season have been analyzed.
+func (cr *CollectionRepo) seasonAnalyzed(season *Season) bool {
+	cr.introsMu.RLock()
+	defer cr.introsMu.RUnlock()
+	for _, e := range season.Episodes {
+		if _, ok := cr.intros[e.id]; !ok && e.fileName != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// detectSeasonIntros searches the intros of the episodes of a season and stores them.
+func (cr *CollectionRepo) detectSeasonIntros(ctx context.Context, c *Collection, season *Season, result *IntroDetectionResult) error {
+	// Fingerprint all episodes, as the ones analyzed before are compared with new ones
+	var episodes []*Episode
+	var fingerprints []fingerprint
+	for ei := range season.Episodes {
+		e := &season.Episodes[ei]
+		if e.fileName == "" {
+			continue
+		}
+		decodeCtx, cancel := context.WithTimeout(ctx, introDecodeTimeout)
+		fp, err := cr.audioFingerprint(decodeCtx, path.Join(c.ItemDirectory(e), e.fileName), introSearchDuration)
+		cancel()
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ctx.Err()
+			}
+			log.Printf("Intro detection of %s failed: %s", e.fileName, err)
+			result.Failed++
+			continue
+		}
+		episodes = append(episodes, e)
+		fingerprints = append(fingerprints, fp)
+	}
+	if len(episodes) < 2 {
+		return nil
+	}
+
+	intros := make([]IntroSegment, len(episodes))
+	match := func(x, y int) {
+		startX, endX, startY, endY, found := commonSegment(fingerprints[x], fingerprints[y])
+		if !found || endX-startX < introMinDuration || endX-startX > introMaxDuration {
+			return
+		}
+		if endX-startX > intros[x].End-intros[x].Start {
+			intros[x] = IntroSegment{Start: startX, End: endX}
+		}
+		if endY-startY > intros[y].End-intros[y].Start {
+			intros[y] = IntroSegment{Start: startY, End: endY}
+		}
+	}
+	for x := 0; x+1 < len(episodes); x++ {
+		match(x, x+1)
+	}
+
+	result.Seasons++
+	cr.introsMu.Lock()
+	for x, e := range episodes {
+		result.Episodes++
+		if intros[x].End > intros[x].Start {
+			result.Detected++
+		}
+		cr.intros[e.id] = intros[x]
+	}
+	value, _ := json.Marshal(cr.intros)
+	cr.introsMu.Unlock()
+
+	if cr.repo != nil {
+		if err := cr.repo.UpsertSetting(ctx, introsKey, string(value)); err != nil {
+			log.Printf("Failed to store intros: %s", err)
+		}
+	}
+	return nil
+}
+
+// loadIntros loads the stored intros.
+func (cr *CollectionRepo) loadIntros() {
+	if cr.repo == nil {
+		return
+	}
+	value, err := cr.repo.GetSetting(context.Background(), introsKey)
+	if err != nil || value == "" {
+		return
+	}
+	var intros map[string]IntroSegment
+	if err := json.Unmarshal([]byte(value), &intros); err != nil {
+		log.Printf("Failed to load intros: %s", err)
+		return
+	}
+	cr.introsMu.Lock()
+	defer cr.introsMu.Unlock()
+	cr.intros = intros
+}
@@ -3,10 +3,8 @@ package collection
 import (
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/erikbos/jellofin-server/collection/metadata"
-	"github.com/erikbos/jellofin-server/idhash"
 )
 
 type Item interface {
@@ -69,6 +67,8 @@ type Movie struct {
 	sortName string
 	// path is the directory to the movie, relative to collection root.
 	path string
+	// root is the collection directory the movie was found in.
+	root string
 	// baseUrl is the base URL for accessing the movie.
 	baseUrl string
 	// created is the create timestamp of the movie.
@@ -83,12 +83,18 @@ type Movie struct {
 	poster string
 	// Etag, unique id. Should change when the movie is updated, e.g. when metadata is updated or when the file is changed.
 	etag string
+	// etagFiles is the sum of the hashes of the video, NFO and image files.
+	etagFiles uint64
 	// Filename, e.g. "casablanca.mp4"
 	fileName string
 	// fileSize is the size of the video file in bytes.
 	fileSize int64
+	// fileID identifies the video file on disk, hardlinks share the same fileID.
+	fileID fileID
 	// Metadata holds the metadata for the movie, e.g. from NFO file.
 	Metadata metadata.Metadata
+	// parts holds the additional parts of a multi-part movie, e.g. "casablanca-part2.mp4".
+	parts []*Movie
 
 	SrtSubs Subtitles
 	VttSubs Subtitles
@@ -97,7 +103,7 @@ type Movie struct {
 func (m *Movie) ID() string { return m.id }
 func (m *Movie) Etag() string {
 	if m.etag == "" {
-		m.etag = idhash.Hash(m.id)
+		m.etag = makeEtag(m.id, m.etagFiles, 0)
 	}
 	return m.etag
 }
@@ -114,12 +120,14 @@ func (m *Movie) Logo() string              { return "" }
 func (m *Movie) FileName() string          { return m.fileName }
 func (m *Movie) FilePath() string          { return m.path + "/" + m.fileName }
 func (m *Movie) FileSize() int64           { return m.fileSize }
+func (m *Movie) AdditionalParts() []*Movie { return m.parts }
 func (m *Movie) Duration() time.Duration   { return m.Metadata.Duration() }
 func (m *Movie) VideoCodec() string        { return m.Metadata.VideoCodec() }
 func (m *Movie) VideoBitrate() int         { return m.Metadata.VideoBitrate() }
 func (m *Movie) VideoFrameRate() float64   { return m.Metadata.VideoFrameRate() }
 func (m *Movie) VideoHeight() int          { return m.Metadata.VideoHeight() }
 func (m *Movie) VideoWidth() int           { return m.Metadata.VideoWidth() }
+func (m *Movie) VideoHdrType() string      { return m.Metadata.VideoHdrType() }
 func (m *Movie) AudioCodec() string        { return m.Metadata.AudioCodec() }
 func (m *Movie) AudioBitrate() int         { return m.Metadata.AudioBitrate() }
 func (m *Movie) AudioChannels() int        { return m.Metadata.AudioChannels() }
@@ -145,6 +153,8 @@ type Show struct {
 	sortName string
 	// path is the directory to the show, relative to collection root. E.g. "Casablanca (1949)"
 	path string
+	// root is the collection directory the show was found in.
+	root string
 	// baseUrl is the base URL for accessing the m.
 	baseUrl string
 	// firstVideo is the timestamp of the first video in the show.
@@ -167,6 +177,8 @@ type Show struct {
 	seasonAllPoster string
 	// Etag, unique id. Should change when the show is updated, e.g. when metadata is updated or when the file is changed.
 	etag string
+	// etagFiles is the sum of the hashes of the NFO and image files.
+	etagFiles uint64
 	// filename of the video file, e.g. "casablanca.mp4"
 	fileName string
 	// fileSize is the size of the video file in bytes.
@@ -183,10 +195,16 @@ type Show struct {
 func (s *Show) ID() string { return s.id }
 func (s *Show) Etag() string {
 	if s.etag == "" {
-		s.etag = idhash.Hash(s.id)
+		s.etag = makeEtag(s.id, s.etagFiles, s.episodeCount())
 	}
 	return s.etag
 }
+func (s *Show) episodeCount() (count int) {
+	for _, season := range s.Seasons {
+		count += len(season.Episodes)
+	}
+	return
+}
 func (s *Show) Name() string            { return s.name }
 func (s *Show) SortName() string        { return s.sortName }
 func (s *Show) Path() string            { return s.path }
@@ -214,6 +232,7 @@ func (s *Show) VideoBitrate() int         { return s.Metadata.VideoBitrate() }
 func (s *Show) VideoFrameRate() float64   { return s.Metadata.VideoFrameRate() }
 func (s *Show) VideoHeight() int          { return s.Metadata.VideoHeight() }
 func (s *Show) VideoWidth() int           { return s.Metadata.VideoWidth() }
+func (s *Show) VideoHdrType() string      { return s.Metadata.VideoHdrType() }
 func (s *Show) AudioCodec() string        { return s.Metadata.AudioCodec() }
 func (s *Show) AudioBitrate() int         { return s.Metadata.AudioBitrate() }
 func (s *Show) AudioChannels() int        { return s.Metadata.AudioChannels() }
@@ -237,6 +256,8 @@ type Season struct {
 	name string
 	// path is the directory to the show(!), relative to collection root. (e.g. Casablanca)
 	path string
+	// root is the collection directory the show was found in.
+	root string
 	// seasonno is the season number, e.g., 1, 2, etc. 0 is used for specials.
 	seasonno int
 	// banner is the path to the season banner image.
@@ -251,6 +272,8 @@ type Season struct {
 	seasonAllPoster string
 	// Etag, unique id. Should change when the season is updated, e.g. when metadata is updated or when the file is changed.
 	etag string
+	// etagFiles is the sum of the hashes of the image files.
+	etagFiles uint64
 	// Episodes contains the episodes in this season.
 	Episodes Episodes
 }
@@ -258,7 +281,7 @@ type Season struct {
 func (season *Season) ID() string { return season.id }
 func (season *Season) Etag() string {
 	if season.etag == "" {
-		season.etag = idhash.Hash(season.id)
+		season.etag = makeEtag(season.id, season.etagFiles, len(season.Episodes))
 	}
 	return season.etag
 }
@@ -290,11 +313,35 @@ func (season *Season) Duration() time.Duration {
 	return duration
 }
 
+// FirstVideo returns the creation time of the oldest episode, zero if the season has no episodes.
+func (season *Season) FirstVideo() (first time.Time) {
+	for _, ep := range season.Episodes {
+		if first.IsZero() || ep.Created().Before(first) {
+			first = ep.Created()
+		}
+	}
+	return
+}
+
+// Premiered returns the earliest premiere date of the episodes, unknown if no episode has one.
+func (season *Season) Premiered() (premiered metadata.Date) {
+	for _, ep := range season.Episodes {
+		if ep.Metadata == nil {
+			continue
+		}
+		if date := ep.Metadata.Premiered(); date.Known() && (!premiered.Known() || date.Before(premiered)) {
+			premiered = date
+		}
+	}
+	return
+}
+
 func (season *Season) VideoCodec() string        { return "" }
 func (season *Season) VideoBitrate() int         { return 0 }
 func (season *Season) VideoFrameRate() float64   { return 0 }
 func (season *Season) VideoHeight() int          { return 0 }
 func (season *Season) VideoWidth() int           { return 0 }
+func (season *Season) VideoHdrType() string      { return "" }
 func (season *Season) AudioCodec() string        { return "" }
 func (season *Season) AudioBitrate() int         { return 0 }
 func (season *Season) AudioChannels() int        { return 0 }
@@ -332,12 +379,17 @@ type Episode struct {
 	name string
 	// path is the directory of the show, relative to collection root. (e.g. Casablanca)
 	path string
+	// root is the collection directory the show was found in.
+	root string
 	// SortName is the name of the m when sorting is applied.
 	sortName string
 	// SeasonNo is the season number, e.g., 1, 2, etc. 0 is used for specials.
 	SeasonNo int
 	// EpisodeNo is the episode number within the season, e.g., 1, 2, etc.
 	EpisodeNo int
+	// AbsoluteEpisodeNo is the episode number counted over all seasons, 0 if not known.
+	// Only set for collections with absolute or tvdb episode order.
+	AbsoluteEpisodeNo int
 	// Double indicates if this is a double episode, e.g., 1-2.
 	Double bool
 	// baseName is the base name of the episode, e.g., "casablanca.s01e01"
@@ -346,10 +398,14 @@ type Episode struct {
 	created time.Time
 	// Etag, unique id. Should change when the episode is updated, e.g. when metadata is updated or when the file is changed.
 	etag string
+	// etagFiles is the sum of the hashes of the video, NFO and thumbnail files.
+	etagFiles uint64
 	// FileName is the filename relative to show directory, e.g. "S01/casablanca.s01e01.mp4"
 	fileName string
 	// fileSize is the size of the video file in bytes.
 	fileSize int64
+	// fileID identifies the video file on disk, hardlinks share the same fileID.
+	fileID fileID
 	// Thumb is the thumbname image relative to show directory, e.g. "S01/casablanca.s01e01-thumb.jpg"
 	thumb string
 	// Metadata holds the metadata for the episode, e.g. from NFO file.
@@ -361,7 +417,7 @@ type Episode struct {
 func (e *Episode) ID() string { return e.id }
 func (e *Episode) Etag() string {
 	if e.etag == "" {
-		e.etag = idhash.Hash(e.id)
+		e.etag = makeEtag(e.id, e.etagFiles, 0)
 	}
 	return e.etag
 }
@@ -384,6 +440,7 @@ func (e *Episode) VideoBitrate() int         { return e.Metadata.VideoBitrate()
 func (e *Episode) VideoFrameRate() float64   { return e.Metadata.VideoFrameRate() }
 func (e *Episode) VideoHeight() int          { return e.Metadata.VideoHeight() }
 func (e *Episode) VideoWidth() int           { return e.Metadata.VideoWidth() }
+func (e *Episode) VideoHdrType() string      { return e.Metadata.VideoHdrType() }
 func (e *Episode) AudioCodec() string        { return e.Metadata.AudioCodec() }
 func (e *Episode) AudioBitrate() int         { return e.Metadata.AudioBitrate() }
 func (e *Episode) AudioChannels() int        { return e.Metadata.AudioChannels() }
@@ -399,6 +456,16 @@ func (e *Episode) Year() int                 { return e.Metadata.Year() }
 func (e *Episode) Rating() float32           { return e.Metadata.Rating() }
 func (e *Episode) OfficialRating() string    { return e.Metadata.OfficialRating() }
 
+// DisplaySeasonNo returns the season an episode is shown in. Specials with a placement are
+// shown in the season they aired in, other specials in season 0.
+func (e *Episode) DisplaySeasonNo() int {
+	if e.SeasonNo != 0 {
+		return e.SeasonNo
+	}
+	p := e.Metadata.SpecialPlacement()
+	return max(p.AirsBeforeSeason, p.AirsAfterSeason)
+}
+
 type Episodes []Episode
 
 func (e Episodes) Len() int {
@@ -422,29 +489,6 @@ type Subs struct {
 
 type Subtitles []Subs
 
-// makeSortName returns a name suitable for sorting.
-func makeSortName(name string) string {
-	// Start with lowercasing and trimming whitespace.
-	title := strings.ToLower(strings.TrimSpace(name))
-
-	// Remove leading articles.
-	for _, prefix := range []string{"the ", "a ", "an "} {
-		if strings.HasPrefix(title, prefix) {
-			title = strings.TrimSpace(title[len(prefix):])
-			break
-		}
-	}
-
-	// Remove whitespace and punctuation.
-	title = strings.TrimLeftFunc(title, func(r rune) bool {
-		return unicode.IsSpace(r) || unicode.IsPunct(r)
-	})
-
-	// Remove year suffix if present.
-	title = removeYearSuffix(title)
-	return title
-}
-
 // removeYearSuffix remoyes year suffix from item name.
 func removeYearSuffix(name string) string {
 	s := isYear.FindStringSubmatch(name)
@@ -0,0 +1,280 @@
+package collection
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+// IDCollision is an item that got a different ID because its regular ID, derived from its
+// name, was already in use by another item. E.g. the same movie directory in two collections,
+// or the same episode filename in two shows.
+type IDCollision struct {
+	// ID is the regular ID, used by the item found first.
+	ID string
+	// Path is the source path of the item using the regular ID.
+	Path string
+	// CollisionID is the ID the other item got, its regular ID salted with its path.
+	CollisionID string
+	// CollisionPath is the source path of the other item.
+	CollisionPath string
+}
+
+// idClaim is an item ID with the source path of the item using it.
+type idClaim struct {
+	id     string
+	source string
+}
+
+// resolveIDCollisions returns the items of a collection with IDs that are not used by another
+// item. Items keep their regular ID, derived from their name, unless it is taken. Then the ID
+// is salted with the source path of the item. An ID stays with the item that used it first:
+// an item of another collection, or the item of previous, the items of the previous scan or the
+// snapshot. Other items claim IDs in order of their source path. As IDs are derived from regular
+// IDs every time, an item gets its regular ID back once the collision is gone.
+// Items are not changed, as they can be in use, e.g. unchanged items of the snapshot. Items that
+// get another ID are copied.
+func (cr *CollectionRepo) resolveIDCollisions(coll *Collection, items, previous []Item) []Item {
+	taken := make(map[string]string)
+	for _, c := range cr.GetCollections() {
+		if c.ID == coll.ID {
+			continue
+		}
+		for _, item := range c.Items {
+			for _, claim := range itemClaims(item) {
+				taken[claim.id] = claim.source
+			}
+		}
+	}
+	// Reserve the regular IDs items already used
+	owners := make(map[string]string)
+	for _, item := range previous {
+		for _, claim := range itemClaims(item) {
+			owners[claim.id] = claim.source
+		}
+	}
+	for _, item := range items {
+		for _, claim := range regularClaims(item) {
+			if _, found := taken[claim.id]; !found && owners[claim.id] == claim.source {
+				taken[claim.id] = claim.source
+			}
+		}
+	}
+
+	// Use an ID that is not taken
+	var collisions []IDCollision
+	claim := func(baseID, source string) string {
+		id := baseID
+		if owner, found := taken[baseID]; found && owner != source {
+			id = idhash.IdHash(baseID + ":" + source)
+			collisions = append(collisions, IDCollision{
+				ID:            baseID,
+				Path:          owner,
+				CollisionID:   id,
+				CollisionPath: source,
+			})
+		}
+		taken[id] = source
+		return id
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return strings.Compare(itemSource(items[a]), itemSource(items[b]))
+	})
+	resolved := slices.Clone(items)
+	for _, i := range order {
+		current := itemClaims(items[i])
+		ids := make([]string, 0, len(current))
+		changed := false
+		for n, c := range regularClaims(items[i]) {
+			ids = append(ids, claim(c.id, c.source))
+			changed = changed || ids[n] != current[n].id
+		}
+		if changed {
+			resolved[i] = cr.itemWithIDs(items[i], ids)
+		}
+	}
+
+	cr.idCollisionsMu.Lock()
+	previousCollisions := cr.idCollisions[coll.ID]
+	cr.idCollisions[coll.ID] = collisions
+	cr.idCollisionsMu.Unlock()
+	for _, collision := range collisions {
+		if !slices.Contains(previousCollisions, collision) {
+			log.Printf("Collection %s: %s has the same ID %s as %s, using ID %s",
+				coll.Name, collision.CollisionPath, collision.ID, collision.Path, collision.CollisionID)
+		}
+	}
+	return resolved
+}
+
+// itemWithIDs returns a copy of a movie or show with the IDs of ids, in the order of itemClaims.
+func (cr *CollectionRepo) itemWithIDs(item Item, ids []string) Item {
+	switch v := item.(type) {
+	case *Movie:
+		m := *v
+		if m.id != ids[0] {
+			m.id = ids[0]
+			m.etag = ""
+			m.Metadata = cr.itemIdentified(m.id, m.Metadata)
+			m.sortName = cr.itemSortName(m.id, m.name, m.Metadata)
+			cr.dbLoadItem(m.id, m.name, m.Metadata.Year(), m.Metadata.Genres())
+		}
+		m.parts = slices.Clone(v.parts)
+		for i, p := range v.parts {
+			if id := ids[1+i]; p.id != id {
+				part := *p
+				part.id = id
+				part.etag = ""
+				m.parts[i] = &part
+			}
+		}
+		return &m
+	case *Show:
+		show := *v
+		parentsChanged := false
+		if show.id != ids[0] {
+			show.id = ids[0]
+			show.etag = ""
+			show.Metadata = cr.itemIdentified(show.id, show.Metadata)
+			show.sortName = cr.itemSortName(show.id, show.name, show.Metadata)
+			cr.dbLoadItem(show.id, show.name, show.Metadata.Year(), show.Metadata.Genres())
+			parentsChanged = true
+		}
+		show.Seasons = slices.Clone(v.Seasons)
+		n := 1
+		for si := range show.Seasons {
+			s := &show.Seasons[si]
+			if s.id != ids[n] {
+				s.id = ids[n]
+				s.etag = ""
+				parentsChanged = true
+			}
+			n++
+			s.Episodes = slices.Clone(s.Episodes)
+			for ei := range s.Episodes {
+				e := &s.Episodes[ei]
+				if e.id != ids[n] {
+					e.id = ids[n]
+					e.etag = ""
+					if cr.repo != nil {
+						cr.repo.SetItemParents(e.id, []string{s.id, show.id})
+					}
+				}
+				n++
+			}
+		}
+		// Register parents of episodes again so played counts of show and seasons are maintained
+		if parentsChanged && cr.repo != nil {
+			for _, s := range show.Seasons {
+				for _, e := range s.Episodes {
+					cr.repo.SetItemParents(e.id, []string{s.id, show.id})
+				}
+			}
+		}
+		return &show
+	}
+	return item
+}
+
+// dbLoadItem stores the details of a movie or show in the database.
+func (cr *CollectionRepo) dbLoadItem(id, name string, year int, genres []string) {
+	if cr.repo == nil {
+		return
+	}
+	cr.repo.DbLoadItem(&model.Item{
+		ID:    id,
+		Name:  name,
+		Year:  year,
+		Genre: strings.Join(genres, ","),
+	})
+}
+
+// itemClaims returns the IDs of an item, including those of parts, seasons and episodes.
+func itemClaims(item Item) []idClaim {
+	claims := []idClaim{{id: item.ID(), source: itemSource(item)}}
+	switch v := item.(type) {
+	case *Movie:
+		for _, p := range v.parts {
+			claims = append(claims, idClaim{id: p.id, source: itemSource(p)})
+		}
+	case *Show:
+		for si := range v.Seasons {
+			s := &v.Seasons[si]
+			claims = append(claims, idClaim{id: s.id, source: itemSource(s)})
+			for ei := range s.Episodes {
+				e := &s.Episodes[ei]
+				claims = append(claims, idClaim{id: e.id, source: itemSource(e)})
+			}
+		}
+	}
+	return claims
+}
+
+// regularClaims returns the regular IDs of an item, derived from their names, in the order of itemClaims.
+func regularClaims(item Item) []idClaim {
+	var claims []idClaim
+	switch v := item.(type) {
+	case *Movie:
+		claims = append(claims, idClaim{id: idhash.IdHash(v.name), source: itemSource(v)})
+		for _, p := range v.parts {
+			claims = append(claims, idClaim{id: idhash.IdHash(path.Join(p.name, p.fileName)), source: itemSource(p)})
+		}
+	case *Show:
+		claims = append(claims, idClaim{id: idhash.IdHash(v.name), source: itemSource(v)})
+		for si := range v.Seasons {
+			s := &v.Seasons[si]
+			claims = append(claims, idClaim{id: idhash.IdHash(s.name), source: itemSource(s)})
+			for ei := range s.Episodes {
+				e := &s.Episodes[ei]
+				claims = append(claims, idClaim{id: idhash.IdHash(path.Base(e.fileName)), source: itemSource(e)})
+			}
+		}
+	}
+	return claims
+}
+
+// itemSource returns the path an item was found at, e.g. "/movies/Heat (1995)/heat.mp4".
+func itemSource(item Item) string {
+	switch v := item.(type) {
+	case *Movie:
+		return path.Join(v.root, v.path, v.fileName)
+	case *Show:
+		return path.Join(v.root, v.path)
+	case *Season:
+		return fmt.Sprintf("%s (season %d)", path.Join(v.root, v.path), v.seasonno)
+	case *Episode:
+		return path.Join(v.root, v.path, v.fileName)
+	}
+	return ""
+}
+
+// ItemSource returns the collection and the path an item was found at, e.g.
+// "/movies/Heat (1995)/heat.mp4". Returns false if the item does not exist.
+func (cr *CollectionRepo) ItemSource(itemID string) (*Collection, string, bool) {
+	c, item := cr.GetItemByID(itemID)
+	if item == nil {
+		return nil, "", false
+	}
+	return c, itemSource(item), true
+}
+
+// IDCollisions returns the items that got a different ID as their regular ID was already in use.
+func (cr *CollectionRepo) IDCollisions() []IDCollision {
+	cr.idCollisionsMu.Lock()
+	defer cr.idCollisionsMu.Unlock()
+	var collisions []IDCollision
+	for _, c := range cr.GetCollections() {
+		collisions = append(collisions, cr.idCollisions[c.ID]...)
+	}
+	return collisions
+}
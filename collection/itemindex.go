@@ -0,0 +1,54 @@
+package collection
+
+import "log"
+
+// itemIndexEntry is the location of an item in the item index.
+type itemIndexEntry struct {
+	collectionID string
+	item         Item
+}
+
+// BuildItemIndex builds the index of item IDs to movies, shows, seasons and episodes.
+func (cr *CollectionRepo) BuildItemIndex() {
+	index := make(map[string]itemIndexEntry)
+	for _, c := range cr.GetCollections() {
+		for _, i := range c.Items {
+			index[i.ID()] = itemIndexEntry{collectionID: c.ID, item: i}
+			if v, ok := i.(*Movie); ok {
+				for _, p := range v.parts {
+					index[p.ID()] = itemIndexEntry{collectionID: c.ID, item: p}
+				}
+			}
+			if v, ok := i.(*Show); ok {
+				for si := range v.Seasons {
+					s := &v.Seasons[si]
+					index[s.ID()] = itemIndexEntry{collectionID: c.ID, item: s}
+					for ei := range s.Episodes {
+						e := &s.Episodes[ei]
+						index[e.ID()] = itemIndexEntry{collectionID: c.ID, item: e}
+					}
+				}
+			}
+		}
+	}
+
+	cr.itemIndexMu.Lock()
+	cr.itemIndex = index
+	cr.itemIndexMu.Unlock()
+	log.Printf("Item index added %d ids.", len(index))
+}
+
+// lookupItemIndex returns the collection and item of an item ID from the item index.
+func (cr *CollectionRepo) lookupItemIndex(itemID string) (*Collection, Item) {
+	cr.itemIndexMu.RLock()
+	entry, ok := cr.itemIndex[itemID]
+	cr.itemIndexMu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	c := cr.GetCollection(entry.collectionID)
+	if c == nil {
+		return nil, nil
+	}
+	return c, entry.item
+}
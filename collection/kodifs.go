@@ -3,11 +3,15 @@ package collection
 
 import (
 	"fmt"
+	"log"
 	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/erikbos/jellofin-server/collection/metadata"
@@ -24,6 +28,21 @@ var isExt1 = regexp.MustCompile(`^(.*)()\.(png|jpg|jpeg|tbn|nfo|srt)$`)
 var isExt2 = regexp.MustCompile(`^(.*)[.-]([a-z]+)\.(png|jpg|jpeg|tbn|nfo|srt)$`)
 var isYear = regexp.MustCompile(` \(([0-9]+)\)$`)
 
+// isPart matches the name of a part of a multi-part movie, e.g. "casablanca-part1" or "casablanca.cd2".
+var isPart = regexp.MustCompile(`(?i)^(.*?)[ _.-]*(?:cd|dvd|part|pt|disc|disk)[ _.-]*([0-9]+)$`)
+
+// moviePart is a video file of a multi-part movie.
+type moviePart struct {
+	// base is the name without part number, e.g. "casablanca"
+	base     string
+	number   int
+	fileName string
+	fileSize int64
+	fileID   fileID
+	created  time.Time
+	etag     uint64
+}
+
 type epMapType struct {
 	eps *Episodes
 	idx int
@@ -33,37 +52,159 @@ type epMapType struct {
 // between processing each movie directory, to avoid overloading the filesystem.
 // If pace is 0, no waiting is done.
 func (cr *CollectionRepo) buildMovies(coll *Collection, pace time.Duration) (items []Item) {
-	f, err := OpenDir(coll.Directory)
+	items = cr.scanCollectionDir(coll, pace, func(root, name string) Item {
+		if i := cr.unchangedSnapshotItem(coll, root, name); i != nil {
+			return i
+		}
+		if m := cr.buildMovie(coll, root, name); m != nil {
+			return m
+		}
+		return nil
+	})
+	if items != nil {
+		items = cr.resolveIDCollisions(coll, items, coll.Items)
+		coll.Items = items
+	}
+	return
+}
+
+// scanEntry is a directory entry in one of the collection directories.
+type scanEntry struct {
+	root string
+	name string
+	// real is the path of the entry with symlinks resolved.
+	real string
+}
+
+// scanCollectionDir calls build for each directory entry of a collection and
+// returns the items in directory order. Entries are processed by a pool of
+// workers. If pace is set entries are processed one by one instead, waiting pace
+// between each of them to avoid overloading the filesystem.
+// In case a collection has multiple directories the entries of all of them are
+// merged, if an entry exists in more than one directory the first one is used.
+// Symlinked entries pointing at an entry that is already scanned are skipped.
+func (cr *CollectionRepo) scanCollectionDir(coll *Collection, pace time.Duration, build func(root, name string) Item) []Item {
+	var names []scanEntry
+	var readable bool
+	unreadable := make(map[string]bool)
+	seen := make(map[string]bool)
+	seenReal := make(map[string]string)
+	for _, root := range coll.Directories {
+		entries, ok := cr.readCollectionDir(coll, root)
+		if !ok {
+			unreadable[root] = true
+			continue
+		}
+		readable = true
+		for _, e := range entries {
+			if seen[e.name] {
+				log.Printf("Collection %s: skipping %s in %s, already found in other directory", coll.Name, e.name, root)
+				continue
+			}
+			if other, found := seenReal[e.real]; found {
+				log.Printf("Collection %s: skipping %s in %s, same directory as %s", coll.Name, e.name, root, other)
+				continue
+			}
+			seen[e.name] = true
+			seenReal[e.real] = path.Join(root, e.name)
+			names = append(names, e)
+		}
+	}
+	// Keep current items in case no directory could be read, e.g. disks not mounted
+	if !readable {
+		return nil
+	}
+
+	workers := cr.scanWorkers
+	if pace > 0 || workers < 1 {
+		workers = 1
+	}
+
+	results := make([]Item, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = build(names[i].root, names[i].name)
+				if pace > 0 {
+					time.Sleep(pace)
+				}
+			}
+		}()
+	}
+	for i := range names {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	items := make([]Item, 0, len(results))
+	for _, item := range results {
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	// Keep current items of directories that could not be read
+	for _, item := range coll.Items {
+		if unreadable[itemRoot(item)] && !seen[path.Base(item.Path())] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readCollectionDir returns the entries of a collection directory that should be scanned.
+// Returns false if the directory cannot be read.
+func (cr *CollectionRepo) readCollectionDir(coll *Collection, root string) ([]scanEntry, bool) {
+	f, err := OpenDir(root)
 	if err != nil {
-		return
+		return nil, false
 	}
 	defer f.Close()
 	fi, _ := f.Readdir(0)
 	if len(fi) == 0 {
-		return
+		return nil, false
 	}
+	filter, ok := coll.newScanFilter(root, "")
+	if !ok {
+		return nil, true
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+
+	var entries []scanEntry
 	for _, f := range fi {
 		name := f.Name()
 		if (len(name) > 0 && name[:1] == ".") ||
 			(len(name) > 1 && name[:2] == "+ ") {
 			continue
 		}
-		m := cr.buildMovie(coll, name)
-		if m != nil {
-			items = append(items, m)
+		if filter.skip(name) {
+			continue
 		}
-		if pace > 0 {
-			time.Sleep(pace)
+		entry := scanEntry{root: root, name: name, real: path.Join(realRoot, name)}
+		if f.IsSymlink() {
+			real, ok := coll.realPath(path.Join(root, name))
+			if !ok {
+				log.Printf("Collection %s: skipping %s, symlink points outside of collection directories", coll.Name, path.Join(root, name))
+				continue
+			}
+			entry.real = real
 		}
+		entries = append(entries, entry)
 	}
-	coll.Items = items
-	return
+	return entries, true
 }
 
 // buildMovie builds a movie item from a movie directory. It scans the directory
 // for video files and images, and returns an Item.
-func (cr *CollectionRepo) buildMovie(coll *Collection, dir string) (movie *Movie) {
-	d := path.Join(coll.Directory, dir)
+func (cr *CollectionRepo) buildMovie(coll *Collection, root, dir string) (movie *Movie) {
+	d := path.Join(root, dir)
 	f, err := OpenDir(d)
 	if err != nil {
 		return
@@ -73,24 +214,60 @@ func (cr *CollectionRepo) buildMovie(coll *Collection, dir string) (movie *Movie
 	if len(fi) == 0 {
 		return
 	}
+	filter, ok := coll.newScanFilter(root, dir)
+	if !ok {
+		return
+	}
+	fi = coll.followSymlinks(d, filter.filter(dir, fi))
 	mname := path.Base(dir)
 
 	var base, video string
 	var filesize int64
+	var videoEtag uint64
+	var fileid fileID
 	var created time.Time
+	var parts []moviePart
 	for _, f := range fi {
 		s := isVideo.FindStringSubmatch(f.Name())
 		if len(s) > 0 {
 			ts := f.Createtime()
 			if !ts.IsZero() {
+				if p := isPart.FindStringSubmatch(s[1]); p != nil {
+					parts = append(parts, moviePart{
+						base:     p[1],
+						number:   parseInt(p[2]),
+						fileName: s[0],
+						fileSize: f.Size(),
+						fileID:   f.fileID(),
+						created:  ts,
+						etag:     etagFile(&f),
+					})
+					continue
+				}
 				video = s[0]
 				base = s[1]
 				filesize = f.Size()
+				fileid = f.fileID()
 				created = ts
+				videoEtag = etagFile(&f)
 
 			}
 		}
 	}
+	// A multi-part movie is played starting with its first part
+	if video == "" && len(parts) > 0 {
+		sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+		parts = slices.DeleteFunc(parts, func(p moviePart) bool { return p.base != parts[0].base })
+		video = parts[0].fileName
+		base = parts[0].base
+		filesize = parts[0].fileSize
+		fileid = parts[0].fileID
+		created = parts[0].created
+		videoEtag = parts[0].etag
+		parts = parts[1:]
+	} else {
+		parts = nil
+	}
 	if video == "" {
 		return
 	}
@@ -108,16 +285,20 @@ func (cr *CollectionRepo) buildMovie(coll *Collection, dir string) (movie *Movie
 	}
 
 	movie = &Movie{
-		id:       idhash.IdHash(mname),
-		name:     mname,
-		sortName: makeSortName(mname),
+		id:   idhash.IdHash(mname),
+		name: mname,
 		// BaseUrl:    coll.BaseUrl,
 		path:     dir,
+		root:     root,
 		fileName: video,
 		fileSize: filesize,
+		fileID:   fileid,
 		created:  created,
+		// Changes of images and NFO are added below
+		etagFiles: videoEtag,
 	}
 
+	var files []SidecarFile
 	for _, f := range fi {
 		name := f.Name()
 
@@ -140,57 +321,62 @@ func (cr *CollectionRepo) buildMovie(coll *Collection, dir string) (movie *Movie
 		if ext == "" {
 			continue
 		}
-
 		if isImage.MatchString(name) {
 			if ext == "tbn" && aux == "" {
 				aux = "poster"
 			}
 			switch aux {
-			case `banner`:
-				movie.banner = name
-			case `fanart`:
-				movie.fanart = name
-			case `folder`:
-				movie.folder = name
-			case `poster`:
-				movie.poster = name
-			}
-			continue
-		}
-
-		if ext == "srt" {
-			if aux == "" || aux == "und" {
-				aux = "zz"
-			}
-			movie.SrtSubs = append(movie.SrtSubs, Subs{
-				Lang: aux,
-				Path: name,
-			})
-			continue
-		}
-
-		if ext == "vtt" {
-			if aux == "" || aux == "und" {
-				aux = "zz"
+			case `banner`, `fanart`, `folder`, `poster`:
+				movie.etagFiles += etagFile(&f)
+			default:
+				continue
 			}
-			movie.VttSubs = append(movie.VttSubs, Subs{
-				Lang: aux,
-				Path: name,
-			})
-			continue
 		}
-
 		if ext == "nfo" {
-			movie.Metadata = metadata.NewNfo(path.Join(coll.Directory, dir, name))
-			movie.Metadata.SetYear(year)
-			continue
+			movie.etagFiles += etagFile(&f)
 		}
+		files = append(files, SidecarFile{Name: name, Aux: aux, Ext: ext})
+	}
+
+	req := ProviderRequest{
+		Dir:   path.Join(root, dir),
+		Name:  movie.name,
+		Year:  year,
+		Files: files,
+	}
+	images := cr.itemImages(coll, req)
+	movie.banner = images["banner"]
+	movie.fanart = images["fanart"]
+	movie.folder = images["folder"]
+	movie.poster = images["poster"]
+	movie.SrtSubs, movie.VttSubs = cr.itemSubtitles(coll, req)
+	if movie.Metadata = cr.itemMetadata(coll, req); movie.Metadata != nil {
+		movie.Metadata.SetYear(year)
 	}
 
 	// Setup a filename-based metadata handler in case of no metadata yet.
 	if movie.Metadata == nil {
 		movie.Metadata = metadata.NewFilename(movie.name, year)
 	}
+	movie.Metadata = cr.itemIdentified(movie.id, movie.Metadata)
+	movie.sortName = cr.itemSortName(movie.id, movie.name, movie.Metadata)
+
+	for _, p := range parts {
+		movie.parts = append(movie.parts, &Movie{
+			id:        idhash.IdHash(path.Join(mname, p.fileName)),
+			name:      mname,
+			sortName:  movie.sortName,
+			path:      dir,
+			root:      root,
+			created:   p.created,
+			fileName:  p.fileName,
+			fileSize:  p.fileSize,
+			fileID:    p.fileID,
+			etagFiles: p.etag,
+			Metadata:  movie.Metadata,
+		})
+		movie.etagFiles += p.etag
+	}
 
 	cr.copySrtVttSubs(movie.SrtSubs, &movie.VttSubs)
 
@@ -206,34 +392,23 @@ func (cr *CollectionRepo) buildMovie(coll *Collection, dir string) (movie *Movie
 	return
 }
 
-// buildMovies builds the movies in a collection. pace is the time to wait
-// between processing each movie directory, to avoid overloading the filesystem.
+// buildShows builds the shows in a collection. pace is the time to wait
+// between processing each show directory, to avoid overloading the filesystem.
 // If pace is 0, no waiting is done.
 func (cr *CollectionRepo) buildShows(coll *Collection, pace time.Duration) (items []Item) {
-	f, err := OpenDir(coll.Directory)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	fi, _ := f.Readdir(0)
-	if len(fi) == 0 {
-		return
-	}
-	for _, f := range fi {
-		name := f.Name()
-		if (len(name) > 0 && name[:1] == ".") ||
-			(len(name) > 1 && name[:2] == "+ ") {
-			continue
-		}
-		m := cr.buildShow(coll, name)
-		if m != nil {
-			items = append(items, m)
+	items = cr.scanCollectionDir(coll, pace, func(root, name string) Item {
+		if i := cr.unchangedSnapshotItem(coll, root, name); i != nil {
+			return i
 		}
-		if pace > 0 {
-			time.Sleep(pace)
+		if s := cr.buildShow(coll, root, name); s != nil {
+			return s
 		}
+		return nil
+	})
+	if items != nil {
+		items = cr.resolveIDCollisions(coll, items, coll.Items)
+		coll.Items = items
 	}
-	coll.Items = items
 	return
 }
 
@@ -252,6 +427,7 @@ func (cr *CollectionRepo) getSeason(show *Show, seasonNo int) (s *Season) {
 		id:       idhash.IdHash(name),
 		name:     name,
 		path:     show.path,
+		root:     show.root,
 		seasonno: seasonNo,
 		// Default images in case we do not have season-specific ones.
 		seasonAllBanner: show.seasonAllBanner,
@@ -287,7 +463,7 @@ func epMatch(epMap map[string]epMapType, s []string) (ep *Episode, aux, ext stri
 
 // showScanDir scans a show directory for episodes and images. It updates the
 // show item with the found episodes and images.
-func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, seasonHint int, show *Show) {
+func (cr *CollectionRepo) showScanDir(coll *Collection, showDir, baseDir, seasonDir string, seasonHint int, show *Show) {
 	d := path.Join(baseDir, seasonDir)
 	f, err := OpenDir(d)
 	if err != nil {
@@ -298,6 +474,12 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 	if len(fi) == 0 {
 		return
 	}
+	dir := path.Join(showDir, seasonDir)
+	filter, ok := coll.newScanFilter(show.root, dir)
+	if !ok {
+		return
+	}
+	fi = coll.followSymlinks(d, filter.filter(dir, fi))
 
 	epMap := make(map[string]epMapType)
 
@@ -312,13 +494,18 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 			s := isShowSubdir.FindStringSubmatch(fn)
 			if len(s) > 0 {
 				sn := parseInt(s[1])
-				cr.showScanDir(showDir, d, fn, sn, show)
+				cr.showScanDir(coll, showDir, d, fn, sn, show)
 				continue
 			}
 
 			// nfo file.
 			if fn == "tvshow.nfo" {
-				show.Metadata = metadata.NewNfo(path.Join(d, fn))
+				show.Metadata = cr.itemMetadata(coll, ProviderRequest{
+					Dir:   d,
+					Name:  show.name,
+					Files: []SidecarFile{{Name: fn, Ext: "nfo"}},
+				})
+				show.etagFiles += etagFile(&f)
 				continue
 			}
 
@@ -334,6 +521,7 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 					// Assign specials poster to season 0.
 					if season := cr.getSeason(show, 0); season != nil {
 						season.poster = path.Join(seasonDir, fn)
+						season.etagFiles += etagFile(&f)
 					}
 				case "banner":
 					show.banner = fn
@@ -346,6 +534,7 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 				case "poster":
 					show.poster = fn
 				}
+				show.etagFiles += etagFile(&f)
 			}
 		}
 
@@ -360,10 +549,12 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 				case "banner":
 					season := cr.getSeason(show, seasonHint)
 					season.banner = p
+					season.etagFiles += etagFile(&f)
 					c = true
 				case "poster":
 					season := cr.getSeason(show, seasonHint)
 					season.poster = p
+					season.etagFiles += etagFile(&f)
 					c = true
 				}
 			}
@@ -387,6 +578,7 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 				// probably a poster.
 				season.poster = p
 			}
+			season.etagFiles += etagFile(&f)
 			continue
 		}
 
@@ -396,13 +588,17 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 			ep := Episode{
 				id:       idhash.IdHash(s[0]),
 				path:     showDir,
+				root:     show.root,
 				fileName: path.Join(seasonDir, fn),
 				fileSize: f.Size(),
+				fileID:   f.fileID(),
 				baseName: s[1],
 				Metadata: metadata.NewFilename(s[1], 0),
 				created:  f.Createtime(),
+				// Changes of thumbnail and NFO are added below
+				etagFiles: etagFile(&f),
 			}
-			if parseEpisodeName(s[1], seasonHint, &ep) {
+			if parseEpisodeName(s[1], seasonHint, coll.absoluteNumbering(), &ep) {
 				season := cr.getSeason(show, ep.SeasonNo)
 				season.Episodes =
 					append(season.Episodes, ep)
@@ -416,6 +612,7 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 	}
 
 	// Now scan the directory again for episode-related files.
+	epFiles := make(map[*Episode][]SidecarFile)
 	for _, f := range fi {
 
 		name := f.Name()
@@ -428,61 +625,65 @@ func (cr *CollectionRepo) showScanDir(showDir, baseDir, seasonDir string, season
 		if ep == nil {
 			continue
 		}
-		p := path.Join(seasonDir, name)
 
 		if isImageExt.MatchString(ext) {
 			if ext == "tbn" && aux == "" {
 				aux = "thumb"
 			}
-			switch aux {
-			case "thumb":
-				ep.thumb = p
+			if aux != "thumb" {
+				continue
 			}
-			continue
+			ep.etagFiles += etagFile(&f)
 		}
-
-		if ext == "srt" {
-			if aux == "" || aux == "und" {
-				aux = "zz"
-			}
-			ep.SrtSubs = append(ep.SrtSubs, Subs{
-				Lang: aux,
-				Path: p,
-			})
-			continue
+		if ext == "nfo" {
+			ep.etagFiles += etagFile(&f)
 		}
+		epFiles[ep] = append(epFiles[ep], SidecarFile{Name: name, Aux: aux, Ext: ext})
+	}
 
-		if ext == "vtt" {
-			if aux == "" || aux == "und" {
-				aux = "zz"
-			}
-			ep.VttSubs = append(ep.VttSubs, Subs{
-				Lang: aux,
-				Path: p,
-			})
+	for _, epx := range epMap {
+		ep := &(*epx.eps)[epx.idx]
+		files := epFiles[ep]
+		if len(files) == 0 {
 			continue
 		}
-
-		if ext == "nfo" {
-			ep.Metadata = metadata.NewNfo(path.Join(baseDir, seasonDir, name))
-			continue
+		req := ProviderRequest{
+			Dir:   d,
+			Name:  ep.baseName,
+			Files: files,
+			// Episodes are lazy loaded as large libraries have many of them.
+			Lazy: true,
+		}
+		if thumb := cr.itemImages(coll, req)["thumb"]; thumb != "" {
+			ep.thumb = path.Join(seasonDir, thumb)
+		}
+		srt, vtt := cr.itemSubtitles(coll, req)
+		for _, sub := range srt {
+			ep.SrtSubs = append(ep.SrtSubs, Subs{Lang: sub.Lang, Path: path.Join(seasonDir, sub.Path)})
+		}
+		for _, sub := range vtt {
+			ep.VttSubs = append(ep.VttSubs, Subs{Lang: sub.Lang, Path: path.Join(seasonDir, sub.Path)})
+		}
+		if m := cr.itemMetadata(coll, req); m != nil {
+			ep.Metadata = m
 		}
 	}
 }
 
 // buildShow builds a show item from a show directory.
 // It scans the directory for episodes and images, and returns an Item
-func (cr *CollectionRepo) buildShow(coll *Collection, dir string) (show *Show) {
+func (cr *CollectionRepo) buildShow(coll *Collection, root, dir string) (show *Show) {
 	name := path.Base(dir)
 	item := &Show{
-		id:       idhash.IdHash(name),
-		name:     name,
-		sortName: makeSortName(name),
+		id:   idhash.IdHash(name),
+		name: name,
 		// BaseUrl: coll.BaseUrl,
 		path: dir,
+		root: root,
 	}
-	d := path.Join(coll.Directory, dir)
-	cr.showScanDir(dir, d, "", -1, item)
+	d := path.Join(root, dir)
+	cr.showScanDir(coll, dir, d, "", -1, item)
+	cr.applyEpisodeOrder(coll, item)
 
 	for i := range item.Seasons {
 		s := &(item.Seasons[i])
@@ -547,6 +748,8 @@ func (cr *CollectionRepo) buildShow(coll *Collection, dir string) (show *Show) {
 		item.Metadata = metadata.NewFilename(item.name, year)
 	}
 	item.Metadata.SetYear(year)
+	item.Metadata = cr.itemIdentified(item.id, item.Metadata)
+	item.sortName = cr.itemSortName(item.id, item.name, item.Metadata)
 
 	dbItemShow := &model.Item{
 		ID:    item.id,
@@ -555,6 +758,13 @@ func (cr *CollectionRepo) buildShow(coll *Collection, dir string) (show *Show) {
 		Genre: strings.Join(item.Metadata.Genres(), ","),
 	}
 	cr.repo.DbLoadItem(dbItemShow)
+
+	// Register parents of episodes so played counts of show and seasons can be maintained
+	for _, s := range item.Seasons {
+		for _, e := range s.Episodes {
+			cr.repo.SetItemParents(e.id, []string{s.id, item.id})
+		}
+	}
 	return
 }
 
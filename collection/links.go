@@ -0,0 +1,66 @@
+package collection
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileID identifies a file on disk, hardlinks of a file have the same fileID.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// valid returns true if the file could be identified.
+func (id fileID) valid() bool {
+	return id.ino != 0
+}
+
+// realPath returns the path of p with all symlinks resolved. Returns false if
+// p does not resolve into one of the collection directories, this prevents
+// symlinks from exposing files outside of the collection.
+func (c *Collection) realPath(p string) (string, bool) {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", false
+	}
+	for _, dir := range c.Directories {
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if real == root || strings.HasPrefix(real, root+string(filepath.Separator)) {
+			return real, true
+		}
+	}
+	return "", false
+}
+
+// followSymlink returns true if the directory entry f in directory dir can be
+// scanned. Entries that are not symlinks are always allowed.
+func (c *Collection) followSymlink(dir string, f *FileInfo) bool {
+	if !f.IsSymlink() {
+		return true
+	}
+	p := path.Join(dir, f.Name())
+	if _, ok := c.realPath(p); !ok {
+		log.Printf("Collection %s: skipping %s, symlink points outside of collection directories", c.Name, p)
+		return false
+	}
+	return true
+}
+
+// followSymlinks returns the entries of directory dir that can be scanned, leaving out
+// symlinks pointing outside of the collection directories. This applies to all files
+// such as videos, images, NFOs and subtitles, as they are all served to clients.
+func (c *Collection) followSymlinks(dir string, fi []FileInfo) []FileInfo {
+	entries := make([]FileInfo, 0, len(fi))
+	for _, f := range fi {
+		if c.followSymlink(dir, &f) {
+			entries = append(entries, f)
+		}
+	}
+	return entries
+}
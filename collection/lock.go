@@ -0,0 +1,263 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// locksKey is the settings key the locked fields are stored under.
+const locksKey = "collection.locks"
+
+// Fields that can be locked in the metadata editor, as named by Jellyfin.
+const (
+	LockedFieldName                = "Name"
+	LockedFieldOverview            = "Overview"
+	LockedFieldGenres              = "Genres"
+	LockedFieldStudios             = "Studios"
+	LockedFieldCast                = "Cast"
+	LockedFieldOfficialRating      = "OfficialRating"
+	LockedFieldProductionLocations = "ProductionLocations"
+	LockedFieldRuntime             = "Runtime"
+)
+
+// ItemLock holds the metadata fields of a movie or show that are locked in the metadata editor,
+// with their values. Scans keep using these values, whatever is found in NFO files or by providers.
+type ItemLock struct {
+	// LockData locks all fields.
+	LockData bool `json:"lockdata,omitempty"`
+	// Fields are the locked fields, e.g. "Name" or "Genres".
+	Fields []string `json:"fields,omitempty"`
+	// Values holds the values of the locked fields.
+	Values LockedValues `json:"values"`
+}
+
+// LockedValues are the values of locked fields, only those of locked fields are used.
+type LockedValues struct {
+	Title          string            `json:"title,omitempty"`
+	Plot           string            `json:"plot,omitempty"`
+	Genres         []string          `json:"genres,omitempty"`
+	Studios        []string          `json:"studios,omitempty"`
+	Actors         map[string]string `json:"actors,omitempty"`
+	Directors      []string          `json:"directors,omitempty"`
+	Writers        []string          `json:"writers,omitempty"`
+	OfficialRating string            `json:"officialrating,omitempty"`
+	Countries      []string          `json:"countries,omitempty"`
+	Duration       time.Duration     `json:"duration,omitempty"`
+}
+
+// Locked returns true if a field is locked.
+func (l *ItemLock) Locked(field string) bool {
+	return l != nil && (l.LockData || slices.Contains(l.Fields, field))
+}
+
+// Title returns the title, the locked one if locked.
+func (m *identifiedMetadata) Title() string {
+	if m.lock.Locked(LockedFieldName) {
+		return m.lock.Values.Title
+	}
+	return m.Metadata.Title()
+}
+
+// Plot returns the plot, the locked one if locked.
+func (m *identifiedMetadata) Plot() string {
+	if m.lock.Locked(LockedFieldOverview) {
+		return m.lock.Values.Plot
+	}
+	return m.Metadata.Plot()
+}
+
+// Genres returns the genres, the locked ones if locked.
+func (m *identifiedMetadata) Genres() []string {
+	if m.lock.Locked(LockedFieldGenres) {
+		return m.lock.Values.Genres
+	}
+	return m.Metadata.Genres()
+}
+
+// Studios returns the studios, the locked ones if locked.
+func (m *identifiedMetadata) Studios() []string {
+	if m.lock.Locked(LockedFieldStudios) {
+		return m.lock.Values.Studios
+	}
+	return m.Metadata.Studios()
+}
+
+// Actors returns the actors, the locked ones if the cast is locked.
+func (m *identifiedMetadata) Actors() map[string]string {
+	if m.lock.Locked(LockedFieldCast) {
+		return m.lock.Values.Actors
+	}
+	return m.Metadata.Actors()
+}
+
+// Directors returns the directors, the locked ones if the cast is locked.
+func (m *identifiedMetadata) Directors() []string {
+	if m.lock.Locked(LockedFieldCast) {
+		return m.lock.Values.Directors
+	}
+	return m.Metadata.Directors()
+}
+
+// Writers returns the writers, the locked ones if the cast is locked.
+func (m *identifiedMetadata) Writers() []string {
+	if m.lock.Locked(LockedFieldCast) {
+		return m.lock.Values.Writers
+	}
+	return m.Metadata.Writers()
+}
+
+// Crew returns the directors and writers, the locked ones if the cast is locked.
+func (m *identifiedMetadata) Crew() []metadata.CrewMember {
+	if !m.lock.Locked(LockedFieldCast) {
+		return m.Metadata.Crew()
+	}
+	var crew []metadata.CrewMember
+	for _, name := range m.lock.Values.Directors {
+		crew = append(crew, metadata.CrewMember{Name: name, Type: "Director"})
+	}
+	for _, name := range m.lock.Values.Writers {
+		crew = append(crew, metadata.CrewMember{Name: name, Type: "Writer"})
+	}
+	return crew
+}
+
+// OfficialRating returns the official rating, the locked one if locked.
+func (m *identifiedMetadata) OfficialRating() string {
+	if m.lock.Locked(LockedFieldOfficialRating) {
+		return m.lock.Values.OfficialRating
+	}
+	return m.Metadata.OfficialRating()
+}
+
+// Countries returns the production countries, the locked ones if locked.
+func (m *identifiedMetadata) Countries() []string {
+	if m.lock.Locked(LockedFieldProductionLocations) {
+		return m.lock.Values.Countries
+	}
+	return m.Metadata.Countries()
+}
+
+// Duration returns the duration, the locked one if the runtime is locked.
+func (m *identifiedMetadata) Duration() time.Duration {
+	if m.lock.Locked(LockedFieldRuntime) {
+		return m.lock.Values.Duration
+	}
+	return m.Metadata.Duration()
+}
+
+// ItemLock returns the locked fields of an item, nil if it has none.
+func (cr *CollectionRepo) ItemLock(itemID string) *ItemLock {
+	cr.locksMu.RLock()
+	defer cr.locksMu.RUnlock()
+	lock, ok := cr.locks[itemID]
+	if !ok {
+		return nil
+	}
+	return &lock
+}
+
+// SetItemLock locks fields of a movie or show. Locked fields keep their current value, unless
+// values holds a value for them, e.g. a title changed in the metadata editor. No locked fields
+// removes the lock.
+// The lock is stored and applied to the item right away.
+func (cr *CollectionRepo) SetItemLock(ctx context.Context, itemID string, lockData bool, fields []string, values LockedValues) error {
+	var m metadata.Metadata
+	_, i := cr.GetItemByID(itemID)
+	switch i := i.(type) {
+	case *Movie:
+		m = i.Metadata
+	case *Show:
+		m = i.Metadata
+	}
+
+	cr.locksMu.Lock()
+	if !lockData && len(fields) == 0 {
+		delete(cr.locks, itemID)
+	} else {
+		lock := ItemLock{LockData: lockData, Fields: fields}
+		if m != nil {
+			lock.Values = lockedValues(m, values)
+		}
+		cr.locks[itemID] = lock
+	}
+	value, _ := json.Marshal(cr.locks)
+	cr.locksMu.Unlock()
+
+	if cr.repo != nil {
+		if err := cr.repo.UpsertSetting(ctx, locksKey, string(value)); err != nil {
+			return err
+		}
+	}
+	switch i := i.(type) {
+	case *Movie:
+		i.Metadata = cr.itemIdentified(i.id, i.Metadata)
+		for _, p := range i.parts {
+			p.Metadata = i.Metadata
+		}
+		cr.dbLoadItem(i.id, i.name, i.Metadata.Year(), i.Metadata.Genres())
+	case *Show:
+		i.Metadata = cr.itemIdentified(i.id, i.Metadata)
+		cr.dbLoadItem(i.id, i.name, i.Metadata.Year(), i.Metadata.Genres())
+	}
+	return nil
+}
+
+// lockedValues returns the current values of metadata, replaced by the provided values that are set.
+func lockedValues(m metadata.Metadata, values LockedValues) LockedValues {
+	current := LockedValues{
+		Title:          m.Title(),
+		Plot:           m.Plot(),
+		Genres:         m.Genres(),
+		Studios:        m.Studios(),
+		Actors:         maps.Clone(m.Actors()),
+		Directors:      m.Directors(),
+		Writers:        m.Writers(),
+		OfficialRating: m.OfficialRating(),
+		Countries:      m.Countries(),
+		Duration:       m.Duration(),
+	}
+	if values.Title != "" {
+		current.Title = values.Title
+	}
+	if values.Plot != "" {
+		current.Plot = values.Plot
+	}
+	if values.Genres != nil {
+		current.Genres = values.Genres
+	}
+	if values.Studios != nil {
+		current.Studios = values.Studios
+	}
+	if values.OfficialRating != "" {
+		current.OfficialRating = values.OfficialRating
+	}
+	if values.Countries != nil {
+		current.Countries = values.Countries
+	}
+	return current
+}
+
+// loadLocks loads the stored locked fields.
+func (cr *CollectionRepo) loadLocks() {
+	if cr.repo == nil {
+		return
+	}
+	value, err := cr.repo.GetSetting(context.Background(), locksKey)
+	if err != nil || value == "" {
+		return
+	}
+	var locks map[string]ItemLock
+	if err := json.Unmarshal([]byte(value), &locks); err != nil {
+		log.Printf("Failed to load locked fields: %s", err)
+		return
+	}
+	cr.locksMu.Lock()
+	defer cr.locksMu.Unlock()
+	cr.locks = locks
+}
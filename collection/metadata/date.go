@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+)
+
+// dateFormats are the formats of dates found in metadata, tried in order. Formats
+// without time zone are read as UTC, formats with a time zone keep it.
+var dateFormats = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"02 Jan 2006",
+	"02 Jan 2006 15:04:05",
+	"2006",
+}
+
+// Date is a date found in metadata, such as a premiere date. It tells a known date apart
+// from an unknown one, the zero value is an unknown date.
+type Date struct {
+	time  time.Time
+	known bool
+}
+
+// NewDate returns a known date, or an unknown date if t is zero.
+func NewDate(t time.Time) Date {
+	return Date{time: t, known: !t.IsZero()}
+}
+
+// ParseDate parses a date as found in NFO files, e.g. "2006-01-02" or "2006-01-02T15:04:05+02:00".
+func ParseDate(input string) (Date, error) {
+	for _, format := range dateFormats {
+		if parsedTime, err := time.Parse(format, input); err == nil {
+			return NewDate(parsedTime), nil
+		}
+	}
+	return Date{}, fmt.Errorf("unable to parse date %s", input)
+}
+
+// Known returns true if the date is known.
+func (d Date) Known() bool { return d.known }
+
+// Time returns the date with its original time zone, zero time if unknown.
+func (d Date) Time() time.Time { return d.time }
+
+// UTC returns the date in UTC, zero time if unknown.
+func (d Date) UTC() time.Time {
+	if !d.known {
+		return time.Time{}
+	}
+	return d.time.UTC()
+}
+
+// Year returns the year, 0 if unknown.
+func (d Date) Year() int {
+	if !d.known {
+		return 0
+	}
+	return d.time.Year()
+}
+
+// Format returns the date formatted according to layout, empty if unknown.
+func (d Date) Format(layout string) string {
+	if !d.known {
+		return ""
+	}
+	return d.time.Format(layout)
+}
+
+// Before returns true if the date is before u. An unknown date is before any known date.
+func (d Date) Before(u Date) bool {
+	if !d.known || !u.known {
+		return !d.known && u.known
+	}
+	return d.time.Before(u.time)
+}
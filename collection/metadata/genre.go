@@ -1,10 +1,17 @@
 package metadata
 
 import (
+	"fmt"
+	"maps"
+	"os"
 	"slices"
 	"strings"
+	"sync"
+
+	"go.yaml.in/yaml/v3"
 )
 
+// genreMap is the built-in mapping of lowercase genre names to their normalized name.
 var genreMap = map[string]string{
 	"absurdist":       "Absurdist",
 	"action":          "Action",
@@ -64,9 +71,87 @@ var genreMap = map[string]string{
 	"western":         "Western",
 }
 
-func normalizeGenres(genres []string) (res []string) {
+var (
+	// customGenreMapping is the mapping set by SetGenreMapping.
+	customGenreMapping map[string]string
+	// activeGenreMapping is genreMap extended with customGenreMapping.
+	activeGenreMapping = genreMap
+	genreMappingMu     sync.RWMutex
+)
+
+// LoadGenreMapping reads a YAML file mapping genre names to their normalized name
+// and applies it using SetGenreMapping, e.g.:
+//
+//	"sci-fi": "Science Fiction"
+//	"science fiction": "Science Fiction"
+func LoadGenreMapping(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var mapping map[string]string
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	SetGenreMapping(mapping)
+	return nil
+}
+
+// SaveGenreMapping writes a genre mapping to a YAML file and applies it using SetGenreMapping.
+func SaveGenreMapping(filename string, mapping map[string]string) error {
+	data, err := yaml.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
+	SetGenreMapping(mapping)
+	return nil
+}
+
+// SetGenreMapping extends the built-in genre mapping, overriding built-in entries with
+// the same name. Genres are normalized when read, so the mapping applies to all items
+// without rescanning.
+func SetGenreMapping(mapping map[string]string) {
+	custom := make(map[string]string, len(mapping))
+	for genre, normalized := range mapping {
+		custom[strings.ToLower(strings.TrimSpace(genre))] = strings.TrimSpace(normalized)
+	}
+	active := maps.Clone(genreMap)
+	for genre, normalized := range active {
+		// Built-in names can be mapped as well, e.g. "Sci-Fi" to "Science Fiction"
+		if n, ok := custom[strings.ToLower(normalized)]; ok {
+			active[genre] = n
+		}
+	}
+	maps.Copy(active, custom)
+
+	genreMappingMu.Lock()
+	defer genreMappingMu.Unlock()
+	customGenreMapping = custom
+	activeGenreMapping = active
+}
+
+// GenreMapping returns the mapping set by SetGenreMapping.
+func GenreMapping() map[string]string {
+	genreMappingMu.RLock()
+	defer genreMappingMu.RUnlock()
+	return maps.Clone(customGenreMapping)
+}
+
+// normalizeGenres normalizes genres using the active genre mapping.
+func normalizeGenres(genres []string) []string {
+	genreMappingMu.RLock()
+	mapping := activeGenreMapping
+	genreMappingMu.RUnlock()
+	return mapGenres(genres, mapping)
+}
+
+// mapGenres returns the normalized genres, without duplicates.
+func mapGenres(genres []string, mapping map[string]string) (res []string) {
 	for _, g := range genres {
-		if normalizedGenre, ok := genreMap[strings.ToLower(g)]; ok {
+		if normalizedGenre, ok := mapping[strings.ToLower(g)]; ok {
 			g = normalizedGenre
 		}
 		if !slices.Contains(res, g) && len(g) > 1 {
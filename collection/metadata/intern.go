@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"container/list"
+	"sync"
+	"unique"
+)
+
+// intern returns a canonical copy of s, so identical strings that occur in many
+// items such as genres, studios and codecs share their memory.
+func intern(s string) string {
+	if s == "" {
+		return s
+	}
+	return unique.Make(s).Value()
+}
+
+// internSlice interns all strings of a slice in place.
+func internSlice(s []string) []string {
+	for i := range s {
+		s[i] = intern(s[i])
+	}
+	return s
+}
+
+// defaultDetailsCacheSize is the default number of NFO details kept in memory.
+const defaultDetailsCacheSize = 1000
+
+// detailsCache holds recently used NFO details of lazy loaded metadata.
+var detailsCache = newLRU[*nfoDetails](defaultDetailsCacheSize)
+
+// SetDetailsCacheSize sets the number of lazy loaded metadata details kept in memory.
+func SetDetailsCacheSize(size int) {
+	if size > 0 {
+		detailsCache.resize(size)
+	}
+}
+
+// lru is a least recently used cache.
+type lru[V any] struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+// lruEntry is a key/value pair in the lru order list.
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// newLRU creates an lru cache holding at most size entries.
+func newLRU[V any](size int) *lru[V] {
+	return &lru[V]{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a value from cache and marks it as recently used.
+func (l *lru[V]) get(key string) (value V, found bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		l.order.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	return
+}
+
+// put stores a value in cache, evicting the least recently used entry if the cache is full.
+func (l *lru[V]) put(key string, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		e.Value.(*lruEntry[V]).value = value
+		l.order.MoveToFront(e)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry[V]{key: key, value: value})
+	l.evict()
+}
+
+// resize changes the maximum number of entries.
+func (l *lru[V]) resize(size int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.size = size
+	l.evict()
+}
+
+// evict removes least recently used entries until the cache fits its size.
+func (l *lru[V]) evict() {
+	for l.order.Len() > l.size {
+		e := l.order.Back()
+		l.order.Remove(e)
+		delete(l.entries, e.Value.(*lruEntry[V]).key)
+	}
+}
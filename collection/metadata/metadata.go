@@ -1,30 +1,41 @@
 package metadata
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Metadata interface {
 	// Title returns the title.
 	Title() string
+	// SortTitle returns the title to sort on, empty if not set.
+	SortTitle() string
 	// Plot returns the plot/summary/description.
 	Plot() string
 	// Tagline returns the tagline.
 	Tagline() string
 	// Actors returns map with actors and their role (e.g. Anthony Hopkins as Hannibal Lector).
 	Actors() map[string]string
+	// ActorThumbs returns map with actors and the URL of their thumb, actors without thumb are left out.
+	ActorThumbs() map[string]string
 	// Directors returns the directors.
 	Directors() []string
 	// Writers returns the writers.
 	Writers() []string
+	// Crew returns the directors and writers with their role.
+	Crew() []CrewMember
 	// Studios returns the studios.
 	Studios() []string
+	// Countries returns the production countries.
+	Countries() []string
 	// Genres returns the genres.
 	Genres() []string
 	// Year returns the release year.
 	Year() int
 	// SetYear sets the release year.
 	SetYear(year int)
-	// Premiered returns the premiere date.
-	Premiered() time.Time
+	// Premiered returns the premiere date, unknown if not available.
+	Premiered() Date
 	// GetRating returns the rating (0.0 - 10.0).
 	Rating() float32
 	// OfficialRating returns the official rating (e.g. "PG-13").
@@ -33,11 +44,34 @@ type Metadata interface {
 	ProviderIDs() map[string]string
 	// Duration returns the item duration.
 	Duration() time.Duration
+	// SpecialPlacement returns where a special is shown between the episodes of the regular seasons.
+	SpecialPlacement() SpecialPlacement
 
 	VideoMetadata
 	AudioMetadata
 }
 
+// CrewMember is a person that worked on an item other than as actor.
+type CrewMember struct {
+	// Name of the person, e.g. "Michael Mann"
+	Name string
+	// Type is the kind of work, "Director" or "Writer"
+	Type string
+	// Role is the specific job, e.g. "Screenplay". Empty if not known.
+	Role string
+}
+
+// SpecialPlacement is the position of a special between the episodes of the regular seasons,
+// e.g. a christmas special that aired between two episodes. All fields are 0 if not known.
+type SpecialPlacement struct {
+	// AirsBeforeSeason is the season the special airs before an episode of.
+	AirsBeforeSeason int
+	// AirsBeforeEpisode is the episode the special airs before, 0 for the first episode of the season.
+	AirsBeforeEpisode int
+	// AirsAfterSeason is the season the special airs after the last episode of.
+	AirsAfterSeason int
+}
+
 type VideoMetadata interface {
 	// VideoCodec returns the video codec (e.g. "h264").
 	VideoCodec() string
@@ -49,6 +83,32 @@ type VideoMetadata interface {
 	VideoHeight() int
 	// VideoWidth returns the video width in pixels.
 	VideoWidth() int
+	// VideoHdrType returns the HDR format of the video, e.g. "dolbyvision". Empty for SDR video.
+	VideoHdrType() string
+}
+
+// HDR formats of videos.
+const (
+	HdrTypeHDR10       = "hdr10"
+	HdrTypeHDR10Plus   = "hdr10plus"
+	HdrTypeDolbyVision = "dolbyvision"
+	HdrTypeHLG         = "hlg"
+)
+
+// normalizeHdrType returns the HDR format of a name used in NFO files or filenames, e.g. "DoVi" or "HDR10+".
+// Returns an empty string for unknown formats.
+func normalizeHdrType(hdrType string) string {
+	switch strings.ToLower(strings.Join(strings.Fields(hdrType), "")) {
+	case "hdr", "hdr10":
+		return HdrTypeHDR10
+	case "hdr10+", "hdr10plus":
+		return HdrTypeHDR10Plus
+	case "dv", "dovi", "dolbyvision":
+		return HdrTypeDolbyVision
+	case "hlg":
+		return HdrTypeHLG
+	}
+	return ""
 }
 
 type AudioMetadata interface {
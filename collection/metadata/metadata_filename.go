@@ -20,6 +20,8 @@ type MetadataFilename struct {
 	width int
 	// videoCodec is the video coded.
 	videoCodec string
+	// hdrType is the HDR format of the video.
+	hdrType string
 	// audioCodec is the audio coded.
 	audioCodec string
 	// audiochannels is the number of audio channels.
@@ -36,6 +38,9 @@ func NewFilename(filename string, year int) *MetadataFilename {
 	return handler
 }
 
+// reHdr matches the HDR format in a filename, e.g. "movie.2160p.DV.HDR10.mkv"
+var reHdr = regexp.MustCompile(`(?i)(?:^|[ ._\[(-])(dv|dovi|dolby[ .]?vision|hdr10\+|hdr10plus|hdr10|hdr|hlg)(?:$|[ ._\])-])`)
+
 // parseFilename guesses metadata from the filename.
 func (n *MetadataFilename) parseFilename() {
 	// We should attempt to extract title from the filename, removing common tags.
@@ -55,6 +60,16 @@ func (n *MetadataFilename) parseFilename() {
 		n.height = 2160
 	}
 
+	// Dolby Vision releases often carry a HDR10 layer as well, Dolby Vision is preferred.
+	if s := reHdr.FindAllStringSubmatch(n.name, -1); len(s) > 0 {
+		for _, m := range s {
+			hdrType := normalizeHdrType(m[1])
+			if n.hdrType == "" || hdrType == HdrTypeDolbyVision {
+				n.hdrType = hdrType
+			}
+		}
+	}
+
 	if strings.Contains(n.name, "aac") {
 		n.audioCodec = "aac"
 	}
@@ -76,6 +91,11 @@ func (n *MetadataFilename) Title() string {
 	return n.name
 }
 
+// SortTitle returns the title to sort on, filenames do not have one.
+func (n *MetadataFilename) SortTitle() string {
+	return ""
+}
+
 // GetGenres returns the genres.
 func (n *MetadataFilename) Genres() []string {
 	return []string{}
@@ -107,11 +127,11 @@ func (n *MetadataFilename) Plot() string {
 }
 
 // Premiered returns the premiere date.
-func (n *MetadataFilename) Premiered() time.Time {
+func (n *MetadataFilename) Premiered() Date {
 	if n.year == 0 {
-		return time.Time{}
+		return Date{}
 	}
-	return time.Date(n.year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return NewDate(time.Date(n.year, time.January, 1, 0, 0, 0, 0, time.UTC))
 }
 
 // Actors returns map with actors and their role (e.g. Anthony Hopkins as Hannibal Lector).
@@ -119,6 +139,11 @@ func (n *MetadataFilename) Actors() map[string]string {
 	return map[string]string{}
 }
 
+// ActorThumbs returns map with actors and the URL of their thumb.
+func (n *MetadataFilename) ActorThumbs() map[string]string {
+	return map[string]string{}
+}
+
 // Directors returns the directors.
 func (n *MetadataFilename) Directors() []string {
 	return []string{}
@@ -129,11 +154,21 @@ func (n *MetadataFilename) Writers() []string {
 	return []string{}
 }
 
+// Crew returns the directors and writers with their role.
+func (n *MetadataFilename) Crew() []CrewMember {
+	return []CrewMember{}
+}
+
 // Studios returns the studios.
 func (n *MetadataFilename) Studios() []string {
 	return []string{}
 }
 
+// Countries returns the production countries.
+func (n *MetadataFilename) Countries() []string {
+	return []string{}
+}
+
 // Tagline returns the tagline.
 func (n *MetadataFilename) Tagline() string {
 	return ""
@@ -144,6 +179,11 @@ func (n *MetadataFilename) ProviderIDs() map[string]string {
 	return ids
 }
 
+// SpecialPlacement returns where a special is shown, filenames do not have this.
+func (n *MetadataFilename) SpecialPlacement() SpecialPlacement {
+	return SpecialPlacement{}
+}
+
 // VideoBitrateBitrate returns the video bitrate in kbps.
 func (n *MetadataFilename) VideoBitrate() int {
 	return 0
@@ -169,6 +209,11 @@ func (n *MetadataFilename) VideoWidth() int {
 	return n.width
 }
 
+// VideoHdrType returns the HDR format of the video, e.g. "dolbyvision". Empty for SDR video.
+func (n *MetadataFilename) VideoHdrType() string {
+	return n.hdrType
+}
+
 // AudioCodec returns the audio codec (e.g. "aac").
 func (n *MetadataFilename) AudioCodec() string {
 	return "unknown"
@@ -2,17 +2,22 @@
 package metadata
 
 import (
+	"encoding/json"
 	"encoding/xml"
-	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// nfoCacheVersion is stored with cached parse results, increase it when fields are
+// added to the nfo struct so cached results are parsed again.
+const nfoCacheVersion = 4
+
 type MetadataNfo struct {
 	// filename is the full path to the NFO file, e.g. "/mnt/media/casablanca.nfo"
 	filename string
@@ -20,12 +25,49 @@ type MetadataNfo struct {
 	year int
 	// nfo is the parsed NFO data.
 	nfo *nfo
+	// cache is an optional cache for parsed NFO data.
+	cache Cache
+	// lazy indicates plot and people are not kept in memory but loaded on demand.
+	lazy bool
+}
+
+// nfoDetails holds the fields of an NFO that use most memory. These are loaded
+// on demand for lazy loaded metadata.
+type nfoDetails struct {
+	Plot      string
+	Tagline   string
+	Actor     []Actor
+	Directors []string
+	Credits   []string
+}
+
+// Cache stores parse results of metadata files, so unchanged files do not
+// need to be parsed again. Entries are keyed by filename, modification time and size.
+type Cache interface {
+	// Get returns the cached parse result, false if the file is not in the cache or has changed.
+	Get(filename string, modTime time.Time, size int64) ([]byte, bool)
+	// Put stores the parse result of a file.
+	Put(filename string, modTime time.Time, size int64, data []byte)
 }
 
 // NewNfo creates a new metadata handler for the given NFO filename.
-func NewNfo(filename string) *MetadataNfo {
+// cache is optional and can be nil.
+func NewNfo(filename string, cache Cache) *MetadataNfo {
 	return &MetadataNfo{
 		filename: filename,
+		cache:    cache,
+	}
+}
+
+// NewNfoLazy creates a new metadata handler for the given NFO filename that does not
+// keep plot and people in memory. These are loaded on demand and kept in a LRU cache.
+// This saves memory for items that exist in large numbers such as episodes.
+// cache is optional and can be nil.
+func NewNfoLazy(filename string, cache Cache) *MetadataNfo {
+	return &MetadataNfo{
+		filename: filename,
+		cache:    cache,
+		lazy:     true,
 	}
 }
 
@@ -44,14 +86,20 @@ func (n *MetadataNfo) Title() string {
 	return n.nfo.Title
 }
 
+// SortTitle returns the title to sort on, empty if not set.
+func (n *MetadataNfo) SortTitle() string {
+	n.loadNfo()
+	return n.nfo.SortTitle
+}
+
 // GetGenres returns the genres.
 func (n *MetadataNfo) Genres() []string {
 	n.loadNfo()
 	if len(n.nfo.Genre) == 0 {
 		return nil
 	}
-
-	return n.nfo.Genre
+	// Normalized again as the genre mapping can change after parsing
+	return normalizeGenres(n.nfo.Genre)
 }
 
 // SetYear sets the release year.
@@ -83,44 +131,94 @@ func (n *MetadataNfo) OfficialRating() string {
 
 // Plot returns the plot/summary/description.
 func (n *MetadataNfo) Plot() string {
-	n.loadNfo()
-	return n.nfo.Plot
+	return n.details().Plot
 }
 
-// Premiered returns the premiere date.
-func (n *MetadataNfo) Premiered() time.Time {
+// Premiered returns the premiere date, or the date of first airing of an episode.
+func (n *MetadataNfo) Premiered() Date {
 	n.loadNfo()
-	if n.nfo.Aired != "" {
-		if parsedTime, err := n.parseTime(n.nfo.Aired); err == nil {
-			return parsedTime
+	for _, input := range []string{n.nfo.Aired, n.nfo.Premiered} {
+		if input == "" {
+			continue
+		}
+		if date, err := ParseDate(input); err == nil {
+			return date
 		}
 	}
-	if parsedTime, err := n.parseTime(n.nfo.Premiered); err == nil {
-		return parsedTime
-	}
-	return time.Time{}
+	return Date{}
 }
 
 // Actors returns map with actors and their role (e.g. Anthony Hopkins as Hannibal Lector).
 func (n *MetadataNfo) Actors() map[string]string {
-	n.loadNfo()
-	actors := make(map[string]string, len(n.nfo.Actor))
-	for _, actor := range n.nfo.Actor {
+	details := n.details()
+	actors := make(map[string]string, len(details.Actor))
+	for _, actor := range details.Actor {
 		actors[actor.Name] = actor.Role
 	}
 	return actors
 }
 
+// ActorThumbs returns map with actors and the URL of their thumb, actors without thumb are left out.
+func (n *MetadataNfo) ActorThumbs() map[string]string {
+	thumbs := make(map[string]string)
+	for _, actor := range n.details().Actor {
+		if actor.Thumb != "" {
+			thumbs[actor.Name] = actor.Thumb
+		}
+	}
+	return thumbs
+}
+
 // Directors returns the directors.
 func (n *MetadataNfo) Directors() []string {
-	n.loadNfo()
-	return n.nfo.Directors
+	return crewNames(n.details().Directors)
 }
 
 // Writers returns the writers.
 func (n *MetadataNfo) Writers() []string {
-	n.loadNfo()
-	return n.nfo.Credits
+	return crewNames(n.details().Credits)
+}
+
+// Crew returns the directors and writers with their role. NFO files can have the role
+// after the name, e.g. <credits>Frank Darabont (Screenplay)</credits>.
+func (n *MetadataNfo) Crew() []CrewMember {
+	details := n.details()
+	crew := make([]CrewMember, 0, len(details.Directors)+len(details.Credits))
+	for _, director := range details.Directors {
+		name, role := splitCrewRole(director)
+		crew = append(crew, CrewMember{Name: name, Type: "Director", Role: role})
+	}
+	for _, writer := range details.Credits {
+		name, role := splitCrewRole(writer)
+		crew = append(crew, CrewMember{Name: name, Type: "Writer", Role: role})
+	}
+	return crew
+}
+
+// crewNames returns the names of crew members without role.
+func crewNames(crew []string) []string {
+	if len(crew) == 0 {
+		return crew
+	}
+	names := make([]string, 0, len(crew))
+	for _, c := range crew {
+		name, _ := splitCrewRole(c)
+		names = append(names, name)
+	}
+	return names
+}
+
+// splitCrewRole splits a crew entry into name and role, e.g. "Frank Darabont (Screenplay)".
+func splitCrewRole(crew string) (name, role string) {
+	crew = strings.TrimSpace(crew)
+	if !strings.HasSuffix(crew, ")") {
+		return crew, ""
+	}
+	i := strings.LastIndex(crew, "(")
+	if i <= 0 {
+		return crew, ""
+	}
+	return strings.TrimSpace(crew[:i]), strings.TrimSpace(crew[i+1 : len(crew)-1])
 }
 
 // Studios returns the studios.
@@ -129,10 +227,15 @@ func (n *MetadataNfo) Studios() []string {
 	return n.nfo.Studios
 }
 
+// Countries returns the production countries.
+func (n *MetadataNfo) Countries() []string {
+	n.loadNfo()
+	return n.nfo.Countries
+}
+
 // Tagline returns the tagline.
 func (n *MetadataNfo) Tagline() string {
-	n.loadNfo()
-	return n.nfo.Tagline
+	return n.details().Tagline
 }
 
 func (n *MetadataNfo) ProviderIDs() map[string]string {
@@ -141,13 +244,44 @@ func (n *MetadataNfo) ProviderIDs() map[string]string {
 	for _, id := range n.nfo.UniqueIDs {
 		if id.Default == "true" || id.Default == "1" {
 			ids["default"] = id.Value
-		} else if id.Type != "" && id.Value != "" {
+		}
+		if id.Type != "" && id.Value != "" {
 			ids[strings.ToLower(id.Type)] = id.Value
 		}
 	}
 	return ids
 }
 
+// SpecialPlacement returns where a special is shown. Kodi uses <displayseason> and <displayepisode>,
+// a display episode of 4096 or none means after the season. Jellyfin and Emby use <airsbefore_season>,
+// <airsbefore_episode> and <airsafter_season>.
+func (n *MetadataNfo) SpecialPlacement() SpecialPlacement {
+	n.loadNfo()
+	var p SpecialPlacement
+	if season := parseNfoInt(n.nfo.DisplaySeason); season > 0 {
+		episode := parseNfoInt(n.nfo.DisplayEpisode)
+		if episode > 0 && episode < 4096 {
+			p.AirsBeforeSeason = season
+			p.AirsBeforeEpisode = episode
+		} else {
+			p.AirsAfterSeason = season
+		}
+		return p
+	}
+	p.AirsBeforeSeason = max(parseNfoInt(n.nfo.AirsBeforeSeason), 0)
+	p.AirsBeforeEpisode = max(parseNfoInt(n.nfo.AirsBeforeEpisode), 0)
+	p.AirsAfterSeason = max(parseNfoInt(n.nfo.AirsAfterSeason), 0)
+	return p
+}
+
+// parseNfoInt parses a number of an NFO field, -1 if the field is not set or not a number.
+func parseNfoInt(s string) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return n
+	}
+	return -1
+}
+
 // VideoBitrateBitrate returns the video bitrate in kbps.
 func (n *MetadataNfo) VideoBitrate() int {
 	n.loadNfo()
@@ -178,6 +312,12 @@ func (n *MetadataNfo) VideoWidth() int {
 	return n.nfo.FileInfo.StreamDetails.Video.Width
 }
 
+// VideoHdrType returns the HDR format of the video, e.g. "dolbyvision". Empty for SDR video.
+func (n *MetadataNfo) VideoHdrType() string {
+	n.loadNfo()
+	return normalizeHdrType(n.nfo.FileInfo.StreamDetails.Video.HdrType)
+}
+
 // AudioCodec returns the audio codec (e.g. "aac").
 func (n *MetadataNfo) AudioCodec() string {
 	n.loadNfo()
@@ -212,14 +352,7 @@ func (n *MetadataNfo) loadNfo() {
 	if n.nfo != nil {
 		return
 	}
-	if file, err := os.Open(n.filename); err == nil {
-		defer file.Close()
-		n.nfo, err = NfoDecode(file)
-		if err != nil {
-			log.Printf("Error parsing NFO file %s: %v\n", n.filename, err)
-		}
-		// We ignore errors here, as we can work with partial data.
-	}
+	n.nfo = n.parseNfo()
 
 	// We create empty structs to avoid nil pointer dereferences later.
 	if n.nfo == nil {
@@ -241,6 +374,119 @@ func (n *MetadataNfo) loadNfo() {
 			Codec: "unknown",
 		}
 	}
+
+	// Move details out of the NFO, they will be loaded again when needed.
+	if n.lazy {
+		detailsCache.put(n.filename, n.nfo.details())
+		n.nfo.Plot = ""
+		n.nfo.Tagline = ""
+		n.nfo.Actor = nil
+		n.nfo.Directors = nil
+		n.nfo.Credits = nil
+	}
+}
+
+// parseNfo reads the NFO file, using the cache if possible. Returns nil if the file cannot be read.
+func (n *MetadataNfo) parseNfo() *nfo {
+	file, err := os.Open(n.filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	if data := n.loadCachedNfo(file); data != nil {
+		return data
+	}
+	data, err := NfoDecode(file)
+	if err != nil {
+		log.Printf("Error parsing NFO file %s: %v\n", n.filename, err)
+		// We ignore errors here, as we can work with partial data.
+		return data
+	}
+	if data != nil {
+		data.Parsed = time.Now().UTC()
+		n.storeCachedNfo(file, data)
+	}
+	return data
+}
+
+// Parsed returns the time the NFO file was parsed. For results taken from the cache this is
+// the time of the original parse, zero if unknown.
+func (n *MetadataNfo) Parsed() time.Time {
+	n.loadNfo()
+	return n.nfo.Parsed
+}
+
+// Refresh parses the NFO file again, ignoring the cache, and stores the result in the cache.
+// The metadata itself is not changed, metadata created afterwards uses the new result.
+func (n *MetadataNfo) Refresh() error {
+	file, err := os.Open(n.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := NfoDecode(file)
+	if err != nil || data == nil {
+		return err
+	}
+	data.Parsed = time.Now().UTC()
+	n.storeCachedNfo(file, data)
+	return nil
+}
+
+// details returns plot and people of the NFO, for lazy loaded metadata these
+// are loaded on demand.
+func (n *MetadataNfo) details() *nfoDetails {
+	n.loadNfo()
+	if !n.lazy {
+		return n.nfo.details()
+	}
+	if details, found := detailsCache.get(n.filename); found {
+		return details
+	}
+	details := &nfoDetails{}
+	if data := n.parseNfo(); data != nil {
+		details = data.details()
+	}
+	detailsCache.put(n.filename, details)
+	return details
+}
+
+// loadCachedNfo returns the parsed NFO from cache, nil if not cached or the file changed.
+func (n *MetadataNfo) loadCachedNfo(file *os.File) *nfo {
+	if n.cache == nil {
+		return nil
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	data, found := n.cache.Get(n.filename, fi.ModTime(), fi.Size())
+	if !found {
+		return nil
+	}
+	var cached nfo
+	if err := json.Unmarshal(data, &cached); err != nil || cached.CacheVersion != nfoCacheVersion {
+		return nil
+	}
+	cached.intern()
+	return &cached
+}
+
+// storeCachedNfo stores the parsed NFO in cache.
+func (n *MetadataNfo) storeCachedNfo(file *os.File, data *nfo) {
+	if n.cache == nil {
+		return
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return
+	}
+	data.CacheVersion = nfoCacheVersion
+	if encoded, err := json.Marshal(data); err == nil {
+		n.cache.Put(n.filename, fi.ModTime(), fi.Size(), encoded)
+	}
 }
 
 // nfo represents the structure of a Kodi style .NFO file.
@@ -252,6 +498,7 @@ type nfo struct {
 	YearString   string       `xml:"year,omitempty"`
 	Year         int          `xml:"-"`
 	OTitle       string       `xml:"originaltitle,omitempty"`
+	SortTitle    string       `xml:"sorttitle,omitempty"`
 	Plot         string       `xml:"plot,omitempty"`
 	Tagline      string       `xml:"tagline,omitempty"`
 	Premiered    string       `xml:"premiered,omitempty"`
@@ -259,6 +506,7 @@ type nfo struct {
 	Episode      string       `xml:"episode,omitempty"`
 	Aired        string       `xml:"aired,omitempty"`
 	Studios      []string     `xml:"studio,omitempty"`
+	Countries    []string     `xml:"country,omitempty"`
 	RatingString string       `xml:"rating,omitempty"`
 	Rating       float64      `xml:"-"`
 	VotesString  string       `xml:"votes,omitempty"`
@@ -267,6 +515,7 @@ type nfo struct {
 	Actor        []Actor      `xml:"actor,omitempty"`
 	Directors    []string     `xml:"director,omitempty"`
 	Credits      []string     `xml:"credits,omitempty"`
+	Writers      []string     `xml:"writer,omitempty"`
 	UniqueIDs    []UniqueID   `xml:"uniqueid,omitempty"`
 	Thumb        string       `xml:"thumb,omitempty"`
 	Fanart       []Thumb      `xml:"fanart,omitempty"`
@@ -274,6 +523,50 @@ type nfo struct {
 	Discart      []Thumb      `xml:"discart,omitempty"`
 	Logo         []Thumb      `xml:"logo,omitempty"`
 	FileInfo     *VidFileInfo `xml:"fileinfo,omitempty"`
+	CacheVersion int          `xml:"-"`
+	// Parsed is the time the file was parsed, kept in the cache to determine its age.
+	Parsed time.Time `xml:"-"`
+
+	// DisplaySeason and DisplayEpisode are the Kodi placement of a special.
+	DisplaySeason  string `xml:"displayseason,omitempty"`
+	DisplayEpisode string `xml:"displayepisode,omitempty"`
+	// AirsBeforeSeason, AirsBeforeEpisode and AirsAfterSeason are the Jellyfin placement of a special.
+	AirsBeforeSeason  string `xml:"airsbefore_season,omitempty"`
+	AirsBeforeEpisode string `xml:"airsbefore_episode,omitempty"`
+	AirsAfterSeason   string `xml:"airsafter_season,omitempty"`
+}
+
+// details returns the memory heavy fields of the NFO.
+func (data *nfo) details() *nfoDetails {
+	return &nfoDetails{
+		Plot:      data.Plot,
+		Tagline:   data.Tagline,
+		Actor:     data.Actor,
+		Directors: data.Directors,
+		Credits:   data.Credits,
+	}
+}
+
+// intern interns the strings that are shared among many items.
+func (data *nfo) intern() {
+	data.Mpaa = intern(data.Mpaa)
+	data.Genre = internSlice(data.Genre)
+	data.Studios = internSlice(data.Studios)
+	data.Countries = internSlice(data.Countries)
+	data.Directors = internSlice(data.Directors)
+	data.Credits = internSlice(data.Credits)
+	for i := range data.Actor {
+		data.Actor[i].Name = intern(data.Actor[i].Name)
+	}
+	if data.FileInfo != nil && data.FileInfo.StreamDetails != nil {
+		if v := data.FileInfo.StreamDetails.Video; v != nil {
+			v.Codec = intern(v.Codec)
+		}
+		if a := data.FileInfo.StreamDetails.Audio; a != nil {
+			a.Codec = intern(a.Codec)
+			a.Language = intern(a.Language)
+		}
+	}
 }
 
 type UniqueID struct {
@@ -307,6 +600,7 @@ type VideoDetails struct {
 	Height            int     `xml:"height,omitempty"`
 	FrameRate         float32 `xml:"framerate,omitempty"`
 	DurationInSeconds int     `xml:"durationinseconds,omitempty"`
+	HdrType           string  `xml:"hdrtype,omitempty"`
 }
 
 type AudioDetails struct {
@@ -366,7 +660,16 @@ func NfoDecode(r io.ReadSeeker) (*nfo, error) {
 		data.Genre = genre
 	}
 
-	data.Genre = normalizeGenres(data.Genre)
+	// Writers can be listed as credits or writer, e.g. in NFO files written by Jellyfin
+	for _, writer := range data.Writers {
+		if !slices.Contains(data.Credits, writer) {
+			data.Credits = append(data.Credits, writer)
+		}
+	}
+	data.Writers = nil
+
+	// Only the built-in mapping is applied, parse results are cached and the genre mapping can change.
+	data.Genre = mapGenres(data.Genre, genreMap)
 
 	// Some non-string fields can be fscked up and explode the
 	// XML decoder, so decode them after the fact.
@@ -374,6 +677,8 @@ func NfoDecode(r io.ReadSeeker) (*nfo, error) {
 	data.Votes = parseInt(data.VotesString)
 	data.Year = parseInt(data.YearString)
 
+	data.intern()
+
 	return data, nil
 }
 
@@ -389,23 +694,3 @@ func parseFloat64(s string) (i float64) {
 	i, _ = strconv.ParseFloat(s, 64)
 	return
 }
-
-func (n *MetadataNfo) parseTime(input string) (time.Time, error) {
-	timeFormats := []string{
-		"15:04:05",
-		"2006-01-02",
-		"2006/01/02",
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-		"02 Jan 2006",
-		"02 Jan 2006 15:04:05",
-	}
-
-	// Try each format until one succeeds
-	for _, format := range timeFormats {
-		if parsedTime, err := time.Parse(format, input); err == nil {
-			return parsedTime, nil
-		}
-	}
-	return time.Time{}, fmt.Errorf("unable to parse time %s in %s", input, n.filename)
-}
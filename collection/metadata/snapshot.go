@@ -0,0 +1,57 @@
+package metadata
+
+// Types of metadata stored in a snapshot.
+const (
+	SnapshotNfo      = "nfo"
+	SnapshotFilename = "filename"
+)
+
+// Snapshot holds what is needed to create metadata again without scanning, e.g. when
+// loading a library snapshot at startup. Parse results are not part of it, NFO files
+// are read again from the metadata cache when used.
+type Snapshot struct {
+	// Type is the type of metadata, "nfo" or "filename".
+	Type string `json:"type"`
+	// Filename is the NFO file, or the name metadata is derived from.
+	Filename string `json:"filename"`
+	// Year is the release year override, 0 if not set.
+	Year int `json:"year,omitempty"`
+	// Lazy indicates plot and people are loaded on demand.
+	Lazy bool `json:"lazy,omitempty"`
+}
+
+// Snapshot returns the snapshot of NFO metadata.
+func (n *MetadataNfo) Snapshot() Snapshot {
+	return Snapshot{
+		Type:     SnapshotNfo,
+		Filename: n.filename,
+		Year:     n.year,
+		Lazy:     n.lazy,
+	}
+}
+
+// Snapshot returns the snapshot of filename metadata.
+func (n *MetadataFilename) Snapshot() Snapshot {
+	return Snapshot{
+		Type:     SnapshotFilename,
+		Filename: n.filename,
+		Year:     n.year,
+	}
+}
+
+// FromSnapshot creates metadata from a snapshot, nil if the type is unknown.
+// cache is optional and can be nil.
+func FromSnapshot(s Snapshot, cache Cache) Metadata {
+	switch s.Type {
+	case SnapshotNfo:
+		return &MetadataNfo{
+			filename: s.Filename,
+			year:     s.Year,
+			cache:    cache,
+			lazy:     s.Lazy,
+		}
+	case SnapshotFilename:
+		return NewFilename(s.Filename, s.Year)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package collection
+
+import (
+	"context"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database"
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// metadataCacheRefresh is how often the last used timestamp of a cache entry
+// is updated, so it does not get purged while its file still exists.
+const metadataCacheRefresh = 24 * time.Hour
+
+// metadataCache stores metadata file parse results in the database.
+type metadataCache struct {
+	repo database.MetadataCacheRepo
+}
+
+// Get returns the cached parse result of a file.
+func (m *metadataCache) Get(filename string, modTime time.Time, size int64) ([]byte, bool) {
+	ctx := context.Background()
+	entry, err := m.repo.GetMetadataCache(ctx, filename, modTime, size)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(entry.Updated) > metadataCacheRefresh {
+		entry.Updated = time.Now().UTC()
+		m.repo.UpsertMetadataCache(ctx, *entry)
+	}
+	return entry.Data, true
+}
+
+// Put stores the parse result of a file.
+func (m *metadataCache) Put(filename string, modTime time.Time, size int64, data []byte) {
+	m.repo.UpsertMetadataCache(context.Background(), model.MetadataCacheEntry{
+		Path:    filename,
+		ModTime: modTime,
+		Size:    size,
+		Data:    data,
+		Updated: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,75 @@
+package collection
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// MetadataRefreshResult is the result of a metadata refresh run.
+type MetadataRefreshResult struct {
+	// Checked is the number of items with metadata in collections with a refresh interval.
+	Checked int
+	// Refreshed are the movies, shows and episodes of which the metadata was refreshed.
+	Refreshed []Item
+	// Failed is the number of items of which the metadata could not be refreshed.
+	Failed int
+}
+
+// refreshableMetadata is metadata that knows its age and can be read again.
+type refreshableMetadata interface {
+	Parsed() time.Time
+	Refresh() error
+}
+
+// RefreshStaleMetadata reads the metadata files of items again if they were parsed longer than
+// the refresh interval of their collection ago, so changes that keep modification time and size
+// of a file are picked up too. Collections without refresh interval or that are offline are
+// skipped. Refreshed results are stored in the metadata cache and used by the next scan.
+func (cr *CollectionRepo) RefreshStaleMetadata(ctx context.Context) (result MetadataRefreshResult, err error) {
+	for _, c := range cr.GetCollections() {
+		if c.RefreshIntervalDays <= 0 || cr.CollectionOffline(c) {
+			continue
+		}
+		maxAge := time.Duration(c.RefreshIntervalDays) * 24 * time.Hour
+
+		refresh := func(item Item, m metadata.Metadata) {
+			if u, ok := m.(interface{ Unwrap() metadata.Metadata }); ok {
+				m = u.Unwrap()
+			}
+			r, ok := m.(refreshableMetadata)
+			if !ok {
+				return
+			}
+			result.Checked++
+			if time.Since(r.Parsed()) < maxAge {
+				return
+			}
+			if err := r.Refresh(); err != nil {
+				log.Printf("Collection %s: cannot refresh metadata of %s: %s", c.Name, item.Name(), err)
+				result.Failed++
+				return
+			}
+			result.Refreshed = append(result.Refreshed, item)
+		}
+		for _, item := range c.Items {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			switch v := item.(type) {
+			case *Movie:
+				refresh(v, v.Metadata)
+			case *Show:
+				refresh(v, v.Metadata)
+				for _, s := range v.Seasons {
+					for e := range s.Episodes {
+						refresh(&s.Episodes[e], s.Episodes[e].Metadata)
+					}
+				}
+			}
+		}
+	}
+	return
+}
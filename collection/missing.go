@@ -0,0 +1,79 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erikbos/jellofin-server/idhash"
+	"github.com/erikbos/jellofin-server/tmdb"
+)
+
+// EpisodeGuide provides the complete list of episodes of a show.
+type EpisodeGuide interface {
+	ShowEpisodes(ctx context.Context, providerIDs map[string]string) ([]tmdb.Episode, error)
+}
+
+// MissingEpisode is an episode that has aired but is not available in the collection.
+type MissingEpisode struct {
+	// ID is the unique identifier of the missing episode, based upon show, season and episode number.
+	ID string
+	// SeasonNo is the season number.
+	SeasonNo int
+	// EpisodeNo is the episode number within the season.
+	EpisodeNo int
+	// Name is the title of the episode.
+	Name string
+	// Overview is the plot of the episode.
+	Overview string
+	// Aired is the date the episode was first aired.
+	Aired time.Time
+}
+
+// MissingEpisodes returns the episodes of a show that have aired but are not
+// available. Only seasons that are at least partly available are checked.
+// Returns nil if no episode guide is configured.
+func (cr *CollectionRepo) MissingEpisodes(ctx context.Context, show *Show) ([]MissingEpisode, error) {
+	if cr.episodeGuide == nil || show == nil || show.Metadata == nil {
+		return nil, nil
+	}
+	guide, err := cr.episodeGuide.ShowEpisodes(ctx, show.Metadata.ProviderIDs())
+	if err != nil {
+		return nil, err
+	}
+
+	type episodeKey struct{ season, episode int }
+	available := make(map[episodeKey]bool)
+	seasons := make(map[int]bool)
+	for _, s := range show.Seasons {
+		seasons[s.seasonno] = true
+		for _, e := range s.Episodes {
+			available[episodeKey{e.SeasonNo, e.EpisodeNo}] = true
+			// Double episodes contain the next episode as well
+			if e.Double {
+				available[episodeKey{e.SeasonNo, e.EpisodeNo + 1}] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	var missing []MissingEpisode
+	for _, e := range guide {
+		if !seasons[e.SeasonNumber] || available[episodeKey{e.SeasonNumber, e.EpisodeNumber}] {
+			continue
+		}
+		// Episodes that have not aired yet are not missing
+		if e.AirDate.IsZero() || e.AirDate.After(now) {
+			continue
+		}
+		missing = append(missing, MissingEpisode{
+			ID:        idhash.IdHash(fmt.Sprintf("%s-missing-%d-%d", show.id, e.SeasonNumber, e.EpisodeNumber)),
+			SeasonNo:  e.SeasonNumber,
+			EpisodeNo: e.EpisodeNumber,
+			Name:      e.Name,
+			Overview:  e.Overview,
+			Aired:     e.AirDate,
+		})
+	}
+	return missing, nil
+}
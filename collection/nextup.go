@@ -0,0 +1,204 @@
+package collection
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// nextUpMaxAge is how long ago a series can have been watched last
+// to still be considered for next up, unless a cutoff is provided.
+const nextUpMaxAge = 365 * 24 * time.Hour
+
+// WatchedItem is the watch state of an item, used as input for next up.
+type WatchedItem struct {
+	// ID is the item ID.
+	ID string
+	// Played indicates the item has been fully watched.
+	Played bool
+	// Timestamp is the last time the item was watched.
+	Timestamp time.Time
+}
+
+// NextUpOptions holds options to determine next up episodes.
+type NextUpOptions struct {
+	// SeriesID limits next up to one series, if set.
+	SeriesID string
+	// DisableFirstEpisode skips the first episode of series that have not been started yet.
+	DisableFirstEpisode bool
+	// EnableRewatching allows series that have been fully watched to restart.
+	EnableRewatching bool
+	// Cutoff skips series that have not been watched since, defaults to nextUpMaxAge ago.
+	Cutoff time.Time
+}
+
+// NextUp returns the next up episode IDs based upon the watch state of a user. Series are
+// ranked by the most recent time they have been watched. For each series the next unplayed
+// episode after the most recently watched episode is returned. Episodes before it that were
+// skipped, e.g. when a season was watched out of order, are returned once the series has
+// no unplayed episodes left after the most recently watched episode.
+func (cr *CollectionRepo) NextUp(watched []WatchedItem, options NextUpOptions) []string {
+	type showEntry struct {
+		show *Show
+		// lastWatched is the most recent time an episode of this show was watched.
+		lastWatched time.Time
+		// lastEpisodeID is the most recently watched episode.
+		lastEpisodeID string
+		// played contains the IDs of all fully watched episodes.
+		played map[string]bool
+	}
+
+	cutoff := options.Cutoff
+	if cutoff.IsZero() {
+		cutoff = time.Now().Add(-nextUpMaxAge)
+	}
+
+	showMap := make(map[string]*showEntry)
+	for _, w := range watched {
+		c, show, _, episode := cr.GetEpisodeByID(w.ID)
+		if c == nil || show == nil || episode == nil {
+			continue
+		}
+		// NextUp skips everything apart from shows
+		if c.Type != CollectionTypeShows {
+			continue
+		}
+		if options.SeriesID != "" && show.id != options.SeriesID {
+			continue
+		}
+		entry, exists := showMap[show.id]
+		if !exists {
+			entry = &showEntry{
+				show:   show,
+				played: make(map[string]bool),
+			}
+			showMap[show.id] = entry
+		}
+		if w.Played {
+			entry.played[episode.id] = true
+		}
+		if w.Timestamp.After(entry.lastWatched) || entry.lastEpisodeID == "" {
+			entry.lastWatched = w.Timestamp
+			entry.lastEpisodeID = episode.id
+		}
+	}
+
+	// A series that has not been watched at all starts with its first episode
+	if options.SeriesID != "" && len(showMap) == 0 && !options.DisableFirstEpisode {
+		if _, show := cr.GetShowByID(options.SeriesID); show != nil {
+			if episodes := show.EpisodesInWatchOrder(false); len(episodes) > 0 {
+				return []string{episodes[0].id}
+			}
+		}
+		return []string{}
+	}
+
+	entries := make([]*showEntry, 0, len(showMap))
+	for _, entry := range showMap {
+		if entry.lastWatched.Before(cutoff) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	// Most recently watched series first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastWatched.After(entries[j].lastWatched)
+	})
+
+	nextUpEpisodeIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		episodes := entry.show.EpisodesInWatchOrder(false)
+		if len(episodes) == 0 {
+			continue
+		}
+		lastIdx := 0
+		for i, e := range episodes {
+			if e.id == entry.lastEpisodeID {
+				lastIdx = i
+				break
+			}
+		}
+
+		// First unplayed episode starting at the most recently watched one, this
+		// includes the last watched episode itself in case it was not finished.
+		nextIdx := -1
+		for i := lastIdx; i < len(episodes); i++ {
+			if !entry.played[episodes[i].id] {
+				nextIdx = i
+				break
+			}
+		}
+		// Nothing left after it, pick up episodes that were skipped earlier on
+		if nextIdx == -1 {
+			for i := range lastIdx {
+				if !entry.played[episodes[i].id] {
+					nextIdx = i
+					break
+				}
+			}
+		}
+		// Series has been watched completely, restart if rewatching is enabled
+		if nextIdx == -1 {
+			if !options.EnableRewatching {
+				continue
+			}
+			nextIdx = (lastIdx + 1) % len(episodes)
+		}
+		if nextIdx == 0 && options.DisableFirstEpisode {
+			continue
+		}
+		nextUpEpisodeIDs = append(nextUpEpisodeIDs, episodes[nextIdx].id)
+	}
+	return nextUpEpisodeIDs
+}
+
+// EpisodesInWatchOrder returns all episodes of a show in watch order. Specials with a placement,
+// e.g. <displayseason> in their NFO, are put between the episodes they aired in between. If
+// includeSpecials is set the other specials are returned after all episodes, otherwise skipped.
+func (s *Show) EpisodesInWatchOrder(includeSpecials bool) []*Episode {
+	type watchEpisode struct {
+		episode *Episode
+		// season and episode number to sort on, order puts specials before (-1) or after (1) the episode.
+		// Specials before the first episode of a season have number 0, after the last episode math.MaxInt.
+		season, number, order int
+	}
+	var episodes []watchEpisode
+	var specials []*Episode
+	for si := range s.Seasons {
+		season := &s.Seasons[si]
+		for ei := range season.Episodes {
+			e := &season.Episodes[ei]
+			if season.seasonno != 0 {
+				episodes = append(episodes, watchEpisode{episode: e, season: e.SeasonNo, number: e.EpisodeNo})
+				continue
+			}
+			switch p := e.Metadata.SpecialPlacement(); {
+			case p.AirsBeforeSeason > 0:
+				episodes = append(episodes, watchEpisode{episode: e, season: p.AirsBeforeSeason, number: p.AirsBeforeEpisode, order: -1})
+			case p.AirsAfterSeason > 0:
+				episodes = append(episodes, watchEpisode{episode: e, season: p.AirsAfterSeason, number: math.MaxInt, order: 1})
+			default:
+				specials = append(specials, e)
+			}
+		}
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		if a.season != b.season {
+			return a.season < b.season
+		}
+		if a.number != b.number {
+			return a.number < b.number
+		}
+		return a.order < b.order
+	})
+
+	result := make([]*Episode, 0, len(episodes)+len(specials))
+	for _, e := range episodes {
+		result = append(result, e.episode)
+	}
+	if includeSpecials {
+		result = append(result, specials...)
+	}
+	return result
+}
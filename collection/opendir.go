@@ -24,6 +24,8 @@ type FileInfo struct {
 	modtime    time.Time
 	createtime time.Time
 	isdir      bool
+	islink     bool
+	id         fileID
 	didstat    bool
 }
 
@@ -109,16 +111,35 @@ func (fi *FileInfo) IsDir() bool {
 	return fi.isdir
 }
 
+// IsSymlink returns true if the entry is a symbolic link, the other
+// attributes are those of the file the link points to.
+func (fi *FileInfo) IsSymlink() bool {
+	fi.stat()
+	return fi.islink
+}
+
+func (fi *FileInfo) fileID() fileID {
+	fi.stat()
+	return fi.id
+}
+
 func (fi *FileInfo) stat() {
 	if fi.didstat {
 		return
 	}
 	p := path.Join(fi.dir.name, fi.name)
-	s, err := os.Stat(p)
+	s, err := os.Lstat(p)
 	if err != nil {
 		return
 	}
+	if s.Mode()&os.ModeSymlink != 0 {
+		fi.islink = true
+		if s, err = os.Stat(p); err != nil {
+			return
+		}
+	}
 
+	fi.id = statFileID(s)
 	fi.size = s.Size()
 	fi.mode = s.Mode()
 	fi.modtime = s.ModTime()
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package collection
+
+import "os"
+
+// statFileID returns the device and inode number of a file, which is not
+// supported on this platform.
+func statFileID(s os.FileInfo) fileID {
+	return fileID{}
+}
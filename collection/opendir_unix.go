@@ -0,0 +1,16 @@
+//go:build unix
+
+package collection
+
+import (
+	"os"
+	"syscall"
+)
+
+// statFileID returns the device and inode number of a file.
+func statFileID(s os.FileInfo) fileID {
+	if st, ok := s.Sys().(*syscall.Stat_t); ok {
+		return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+	}
+	return fileID{}
+}
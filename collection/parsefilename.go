@@ -19,7 +19,16 @@ var pat3 = regexp.MustCompile(`^.*[ .]([0-9]{4})[.-]([0-9]{2})[.-]([0-9]{2})[ .]
 // pattern: ___.308.___  (or 3x08) where first number is season.
 var pat4 = regexp.MustCompile(`^.*[ .]([0-9]{1,2})x?([0-9]{2})[ .].*$`)
 
-func parseEpisodeName(name string, seasonHint int, ep *Episode) (ok bool) {
+// pattern: ___ - 123 ___ or ___ - 123v2 ___, absolute episode number.
+var patAbsolute1 = regexp.MustCompile(`^.*[ ._]-[ ._]?(?:[eE][pP]?)?([0-9]{1,4})(?:[vV][0-9])?(?:[ ._\[(].*)?$`)
+
+// pattern: ___.e123.___ or ___ ep123 ___, absolute episode number.
+var patAbsolute2 = regexp.MustCompile(`^.*[ ._][eE][pP]?([0-9]{1,4})(?:[vV][0-9])?(?:[ ._\[(].*)?$`)
+
+// parseEpisodeName parses season and episode number from the name of an episode file.
+// If absolute is set names without season, e.g. "show - 123", are parsed as absolute episode number,
+// these episodes are put in the season of the hint, or season 1 if there is no hint.
+func parseEpisodeName(name string, seasonHint int, absolute bool, ep *Episode) (ok bool) {
 
 	ok = true
 
@@ -40,6 +49,20 @@ func parseEpisodeName(name string, seasonHint int, ep *Episode) (ok bool) {
 		return
 	}
 
+	if absolute {
+		s = patAbsolute1.FindStringSubmatch(name)
+		if len(s) == 0 {
+			s = patAbsolute2.FindStringSubmatch(name)
+		}
+		if len(s) > 0 {
+			ep.name = s[1]
+			ep.SeasonNo = max(seasonHint, 1)
+			ep.EpisodeNo = parseInt(s[1])
+			ep.AbsoluteEpisodeNo = ep.EpisodeNo
+			return
+		}
+	}
+
 	s = pat3.FindStringSubmatch(name)
 	if len(s) > 0 {
 		ep.name = s[1] + "." + s[2] + "." + s[3]
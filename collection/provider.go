@@ -0,0 +1,187 @@
+package collection
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// ProviderRequest holds the details of an item a provider uses to find data for it.
+type ProviderRequest struct {
+	// Dir is the absolute directory of the item, e.g. "/media/movies/Casablanca (1942)"
+	Dir string
+	// Name of the item as derived from its directory or filename, e.g. "Casablanca (1942)"
+	Name string
+	// Year of the item, 0 if not known
+	Year int
+	// Files are the files next to the video belonging to the item, e.g. nfo, images and subtitles
+	Files []SidecarFile
+	// Lazy indicates metadata should be loaded on first use, as there can be many items, e.g. episodes.
+	Lazy bool
+	// MetadataCache caches parsed metadata files.
+	MetadataCache metadata.Cache
+}
+
+// SidecarFile is a file belonging to an item, e.g. "casablanca-poster.jpg".
+type SidecarFile struct {
+	// Name of the file in the item directory, e.g. "Season 1/S01E01-thumb.jpg"
+	Name string
+	// Aux is the type or language of the file, e.g. "poster", "fanart", "thumb" or "en". Empty if not set.
+	Aux string
+	// Ext is the extension of the file, e.g. "nfo", "jpg" or "srt"
+	Ext string
+}
+
+// MetadataProvider provides metadata of items, e.g. from an NFO file.
+type MetadataProvider interface {
+	// Metadata returns the metadata of an item, nil if the provider has none.
+	Metadata(req ProviderRequest) metadata.Metadata
+}
+
+// ImageProvider provides artwork of items.
+type ImageProvider interface {
+	// Images returns the images of an item by type ("banner", "fanart", "folder", "poster" or "thumb"),
+	// as filename relative to the item directory.
+	Images(req ProviderRequest) map[string]string
+}
+
+// SubtitleProvider provides subtitles of items.
+type SubtitleProvider interface {
+	// Subtitles returns the srt and vtt subtitles of an item.
+	Subtitles(req ProviderRequest) (srt, vtt Subtitles)
+}
+
+// ProviderConfig holds the names of the providers used by a collection, in order of priority.
+// An empty list uses all registered providers, ordered by their default priority.
+type ProviderConfig struct {
+	Metadata  []string
+	Images    []string
+	Subtitles []string
+}
+
+// registeredProvider is a provider with its name and default priority.
+type registeredProvider[P any] struct {
+	name     string
+	priority int
+	provider P
+}
+
+// providerRegistry holds the registered providers of one kind.
+type providerRegistry[P any] struct {
+	mu        sync.RWMutex
+	providers []registeredProvider[P]
+}
+
+var (
+	metadataProviders providerRegistry[MetadataProvider]
+	imageProviders    providerRegistry[ImageProvider]
+	subtitleProviders providerRegistry[SubtitleProvider]
+)
+
+// RegisterMetadataProvider registers a metadata provider. Providers with a higher
+// priority are asked first, unless the collection configures an order.
+func RegisterMetadataProvider(name string, priority int, p MetadataProvider) {
+	metadataProviders.register(name, priority, p)
+}
+
+// RegisterImageProvider registers an image provider. Providers with a higher
+// priority are asked first, unless the collection configures an order.
+func RegisterImageProvider(name string, priority int, p ImageProvider) {
+	imageProviders.register(name, priority, p)
+}
+
+// RegisterSubtitleProvider registers a subtitle provider. Providers with a higher
+// priority are asked first, unless the collection configures an order.
+func RegisterSubtitleProvider(name string, priority int, p SubtitleProvider) {
+	subtitleProviders.register(name, priority, p)
+}
+
+// register adds a provider, a provider with the same name is replaced.
+func (r *providerRegistry[P]) register(name string, priority int, p P) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers = slices.DeleteFunc(r.providers, func(rp registeredProvider[P]) bool { return rp.name == name })
+	r.providers = append(r.providers, registeredProvider[P]{name: name, priority: priority, provider: p})
+	slices.SortStableFunc(r.providers, func(a, b registeredProvider[P]) int { return b.priority - a.priority })
+}
+
+// ordered returns the providers to use in order. If names is empty all providers are
+// returned by priority, otherwise the named providers in the provided order.
+func (r *providerRegistry[P]) ordered(names []string) []P {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var providers []P
+	if len(names) == 0 {
+		for _, rp := range r.providers {
+			providers = append(providers, rp.provider)
+		}
+		return providers
+	}
+	for _, name := range names {
+		for _, rp := range r.providers {
+			if rp.name == name {
+				providers = append(providers, rp.provider)
+			}
+		}
+	}
+	return providers
+}
+
+// validate returns an error if one of the names is not a registered provider.
+func (r *providerRegistry[P]) validate(kind string, names []string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range names {
+		if !slices.ContainsFunc(r.providers, func(rp registeredProvider[P]) bool { return rp.name == name }) {
+			return fmt.Errorf("unknown %s provider %s", kind, name)
+		}
+	}
+	return nil
+}
+
+// validate returns an error if the config names a provider that is not registered.
+func (pc ProviderConfig) validate() error {
+	if err := metadataProviders.validate("metadata", pc.Metadata); err != nil {
+		return err
+	}
+	if err := imageProviders.validate("image", pc.Images); err != nil {
+		return err
+	}
+	return subtitleProviders.validate("subtitle", pc.Subtitles)
+}
+
+// itemMetadata returns the metadata of the first provider having metadata of an item, nil if none has.
+func (cr *CollectionRepo) itemMetadata(coll *Collection, req ProviderRequest) metadata.Metadata {
+	req.MetadataCache = cr.metadataCache
+	for _, p := range metadataProviders.ordered(coll.Providers.Metadata) {
+		if m := p.Metadata(req); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// itemImages returns the images of an item, for each image type the first provider having it is used.
+func (cr *CollectionRepo) itemImages(coll *Collection, req ProviderRequest) map[string]string {
+	images := make(map[string]string)
+	for _, p := range imageProviders.ordered(coll.Providers.Images) {
+		for imageType, filename := range p.Images(req) {
+			if _, ok := images[imageType]; !ok {
+				images[imageType] = filename
+			}
+		}
+	}
+	return images
+}
+
+// itemSubtitles returns the subtitles of an item of all providers.
+func (cr *CollectionRepo) itemSubtitles(coll *Collection, req ProviderRequest) (srt, vtt Subtitles) {
+	for _, p := range subtitleProviders.ordered(coll.Providers.Subtitles) {
+		s, v := p.Subtitles(req)
+		srt = append(srt, s...)
+		vtt = append(vtt, v...)
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package collection
+
+import (
+	"path"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// Names of the built-in providers.
+const (
+	ProviderNfo      = "nfo"
+	ProviderFilename = "filename"
+	ProviderLocal    = "local"
+)
+
+func init() {
+	RegisterMetadataProvider(ProviderNfo, 100, nfoProvider{})
+	RegisterMetadataProvider(ProviderFilename, 0, filenameProvider{})
+	RegisterImageProvider(ProviderLocal, 100, localImageProvider{})
+	RegisterSubtitleProvider(ProviderLocal, 100, localSubtitleProvider{})
+}
+
+// nfoProvider provides metadata from Kodi NFO files.
+type nfoProvider struct{}
+
+func (nfoProvider) Metadata(req ProviderRequest) metadata.Metadata {
+	for _, f := range req.Files {
+		if f.Ext != "nfo" {
+			continue
+		}
+		if req.Lazy {
+			return metadata.NewNfoLazy(path.Join(req.Dir, f.Name), req.MetadataCache)
+		}
+		return metadata.NewNfo(path.Join(req.Dir, f.Name), req.MetadataCache)
+	}
+	return nil
+}
+
+// filenameProvider provides metadata derived from the name of an item.
+type filenameProvider struct{}
+
+func (filenameProvider) Metadata(req ProviderRequest) metadata.Metadata {
+	return metadata.NewFilename(req.Name, req.Year)
+}
+
+// localImageProvider provides images stored next to the video.
+type localImageProvider struct{}
+
+func (localImageProvider) Images(req ProviderRequest) map[string]string {
+	images := make(map[string]string)
+	for _, f := range req.Files {
+		if !isImageExt.MatchString(f.Ext) {
+			continue
+		}
+		switch f.Aux {
+		case "banner", "fanart", "folder", "poster", "thumb":
+			images[f.Aux] = f.Name
+		}
+	}
+	return images
+}
+
+// localSubtitleProvider provides subtitles stored next to the video.
+type localSubtitleProvider struct{}
+
+func (localSubtitleProvider) Subtitles(req ProviderRequest) (srt, vtt Subtitles) {
+	for _, f := range req.Files {
+		if f.Ext != "srt" && f.Ext != "vtt" {
+			continue
+		}
+		lang := f.Aux
+		if lang == "" || lang == "und" {
+			lang = "zz"
+		}
+		sub := Subs{
+			Lang: lang,
+			Path: f.Name,
+		}
+		if f.Ext == "srt" {
+			srt = append(srt, sub)
+		} else {
+			vtt = append(vtt, sub)
+		}
+	}
+	return
+}
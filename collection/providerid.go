@@ -0,0 +1,64 @@
+package collection
+
+import (
+	"log"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// providerAliases maps alternative provider names onto the name used in the index.
+var providerAliases = map[string]string{
+	"themoviedb": "tmdb",
+	"thetvdb":    "tvdb",
+}
+
+// BuildProviderIndex builds the index of provider IDs (e.g. imdb, tmdb) to items.
+func (cr *CollectionRepo) BuildProviderIndex() {
+	index := make(map[string][]string)
+	add := func(m metadata.Metadata, itemID string) {
+		if m == nil {
+			return
+		}
+		for provider, id := range m.ProviderIDs() {
+			if provider == "default" || id == "" {
+				continue
+			}
+			key := providerIndexKey(provider, id)
+			index[key] = append(index[key], itemID)
+		}
+	}
+	for _, c := range cr.GetCollections() {
+		for _, i := range c.Items {
+			switch v := i.(type) {
+			case *Movie:
+				add(v.Metadata, v.id)
+			case *Show:
+				add(v.Metadata, v.id)
+				for _, s := range v.Seasons {
+					for _, e := range s.Episodes {
+						add(e.Metadata, e.id)
+					}
+				}
+			}
+		}
+	}
+
+	cr.providerIndexMu.Lock()
+	cr.providerIndex = index
+	cr.providerIndexMu.Unlock()
+	log.Printf("Provider index added %d ids.", len(index))
+}
+
+// LookupProviderID returns the IDs of items having the provided provider ID,
+// e.g. provider "imdb" and id "tt0111161".
+func (cr *CollectionRepo) LookupProviderID(provider, id string) []string {
+	cr.providerIndexMu.RLock()
+	defer cr.providerIndexMu.RUnlock()
+	return cr.providerIndex[providerIndexKey(provider, id)]
+}
+
+// providerIndexKey returns the index key of a provider ID.
+func providerIndexKey(provider, id string) string {
+	return providerIndexKeyName(provider) + "." + strings.ToLower(strings.TrimSpace(id))
+}
@@ -0,0 +1,181 @@
+package collection
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// similarYearRange is the number of years after which two items no longer
+// get any points for year proximity.
+const similarYearRange = 10
+
+// SimilarWeights are the weights of the similarity scoring model.
+type SimilarWeights struct {
+	// Genres is the weight of overlapping genres.
+	Genres float64
+	// People is the weight of overlapping actors, directors and writers.
+	People float64
+	// Studios is the weight of overlapping studios.
+	Studios float64
+	// Year is the weight of items being released in about the same year.
+	Year float64
+	// Rating is the weight of the community rating of the candidate item.
+	Rating float64
+}
+
+// DefaultSimilarWeights are the weights used if none are configured.
+var DefaultSimilarWeights = SimilarWeights{
+	Genres:  3.0,
+	People:  2.0,
+	Studios: 1.0,
+	Year:    1.0,
+	Rating:  0.5,
+}
+
+// similarVector holds the features of an item used for similarity scoring.
+type similarVector struct {
+	genres  map[string]bool
+	people  map[string]bool
+	studios map[string]bool
+	year    int
+	rating  float32
+}
+
+// Similar returns the IDs of the items in the same collection that are most
+// similar to the provided item, best match first. Seasons and episodes are
+// scored based upon their show. At most count items are returned.
+func (j *CollectionRepo) Similar(ctx context.Context, c *Collection, i Item, count int) ([]string, error) {
+	if c == nil || i == nil {
+		return []string{}, nil
+	}
+	// Seasons and episodes do not carry show metadata, so use the show instead.
+	switch i.(type) {
+	case *Season, *Episode:
+		if _, show, _, _ := j.GetEpisodeByID(i.ID()); show != nil {
+			i = show
+		} else if _, show, _ := j.GetSeasonByID(i.ID()); show != nil {
+			i = show
+		}
+	}
+
+	type scoredItem struct {
+		id    string
+		score float64
+	}
+
+	reference := makeSimilarVector(i)
+	scored := make([]scoredItem, 0, len(c.Items))
+	for _, candidate := range c.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if candidate.ID() == i.ID() {
+			continue
+		}
+		score := j.similarWeights.score(reference, makeSimilarVector(candidate))
+		if score <= 0 {
+			continue
+		}
+		scored = append(scored, scoredItem{id: candidate.ID(), score: score})
+	}
+	sort.SliceStable(scored, func(a, b int) bool {
+		return scored[a].score > scored[b].score
+	})
+
+	if count > 0 && len(scored) > count {
+		scored = scored[:count]
+	}
+	ids := make([]string, 0, len(scored))
+	for _, s := range scored {
+		ids = append(ids, s.id)
+	}
+	return ids, nil
+}
+
+// score calculates the similarity score of a candidate compared to a reference item.
+// Rating only adds to the score if the candidate has something in common with the
+// reference, otherwise highly rated items would be similar to everything.
+func (w SimilarWeights) score(reference, candidate similarVector) float64 {
+	overlap := w.Genres*jaccard(reference.genres, candidate.genres) +
+		w.People*overlapRatio(reference.people, candidate.people) +
+		w.Studios*jaccard(reference.studios, candidate.studios)
+
+	if reference.year != 0 && candidate.year != 0 {
+		distance := reference.year - candidate.year
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < similarYearRange {
+			overlap += w.Year * float64(similarYearRange-distance) / similarYearRange
+		}
+	}
+	if overlap == 0 {
+		return 0
+	}
+	return overlap + w.Rating*float64(candidate.rating)/10
+}
+
+// makeSimilarVector creates the similarity features of an item.
+func makeSimilarVector(i Item) similarVector {
+	v := similarVector{
+		genres:  make(map[string]bool),
+		people:  make(map[string]bool),
+		studios: make(map[string]bool),
+		year:    i.Year(),
+		rating:  i.Rating(),
+	}
+	for _, g := range i.Genres() {
+		if g != "" {
+			v.genres[strings.ToLower(g)] = true
+		}
+	}
+	for actor := range i.Actors() {
+		v.people[strings.ToLower(actor)] = true
+	}
+	for _, director := range i.Directors() {
+		v.people[strings.ToLower(director)] = true
+	}
+	for _, writer := range i.Writers() {
+		v.people[strings.ToLower(writer)] = true
+	}
+	for _, s := range i.Studios() {
+		if s != "" {
+			v.studios[strings.ToLower(s)] = true
+		}
+	}
+	return v
+}
+
+// jaccard returns the size of the intersection divided by size of the union of two sets.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := intersectionCount(a, b)
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// overlapRatio returns the size of the intersection divided by the size of the smallest set.
+// Used for people as casts vary a lot in size.
+func overlapRatio(a, b map[string]bool) float64 {
+	smallest := min(len(a), len(b))
+	if smallest == 0 {
+		return 0
+	}
+	return float64(intersectionCount(a, b)) / float64(smallest)
+}
+
+// intersectionCount returns the number of entries two sets have in common.
+func intersectionCount(a, b map[string]bool) int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var count int
+	for k := range a {
+		if b[k] {
+			count++
+		}
+	}
+	return count
+}
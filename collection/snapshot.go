@@ -0,0 +1,555 @@
+package collection
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// snapshotVersion is stored in the library snapshot, increase it when the format changes
+// so snapshots of older versions are ignored.
+const snapshotVersion = 1
+
+// librarySnapshot holds the scanned items of all collections, so they are available
+// right after startup instead of after the first scan.
+type librarySnapshot struct {
+	Version     int                  `json:"version"`
+	Created     time.Time            `json:"created"`
+	Collections []collectionSnapshot `json:"collections"`
+}
+
+// collectionSnapshot holds the items of a collection.
+type collectionSnapshot struct {
+	ID string `json:"id"`
+	// Config holds the settings that change scan results, the snapshot is not used if they changed.
+	Config string          `json:"config"`
+	Movies []movieSnapshot `json:"movies,omitempty"`
+	Shows  []showSnapshot  `json:"shows,omitempty"`
+}
+
+type movieSnapshot struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Path      string             `json:"path"`
+	Root      string             `json:"root"`
+	Created   time.Time          `json:"created"`
+	Banner    string             `json:"banner,omitempty"`
+	Fanart    string             `json:"fanart,omitempty"`
+	Folder    string             `json:"folder,omitempty"`
+	Poster    string             `json:"poster,omitempty"`
+	EtagFiles uint64             `json:"etagfiles"`
+	FileName  string             `json:"filename"`
+	FileSize  int64              `json:"filesize"`
+	FileID    [2]uint64          `json:"fileid"`
+	Metadata  *metadata.Snapshot `json:"metadata,omitempty"`
+	Parts     []movieSnapshot    `json:"parts,omitempty"`
+	SrtSubs   Subtitles          `json:"srt,omitempty"`
+	VttSubs   Subtitles          `json:"vtt,omitempty"`
+	// Dirs holds the modification time of the movie directory.
+	Dirs map[string]int64 `json:"dirs,omitempty"`
+}
+
+type showSnapshot struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Path            string            `json:"path"`
+	Root            string            `json:"root"`
+	FirstVideo      time.Time         `json:"firstvideo"`
+	LastVideo       time.Time         `json:"lastvideo"`
+	Banner          string            `json:"banner,omitempty"`
+	Fanart          string            `json:"fanart,omitempty"`
+	Folder          string            `json:"folder,omitempty"`
+	Poster          string            `json:"poster,omitempty"`
+	Logo            string            `json:"logo,omitempty"`
+	SeasonAllBanner string            `json:"seasonallbanner,omitempty"`
+	SeasonAllPoster string            `json:"seasonallposter,omitempty"`
+	EtagFiles       uint64            `json:"etagfiles"`
+	Metadata        metadata.Snapshot `json:"metadata"`
+	Seasons         []seasonSnapshot  `json:"seasons"`
+	// Dirs holds the modification times of the show directory and its season directories.
+	Dirs map[string]int64 `json:"dirs,omitempty"`
+}
+
+type seasonSnapshot struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Number    int               `json:"number"`
+	Banner    string            `json:"banner,omitempty"`
+	Fanart    string            `json:"fanart,omitempty"`
+	Poster    string            `json:"poster,omitempty"`
+	EtagFiles uint64            `json:"etagfiles"`
+	Episodes  []episodeSnapshot `json:"episodes"`
+}
+
+type episodeSnapshot struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	SortName          string            `json:"sortname,omitempty"`
+	SeasonNo          int               `json:"seasonno"`
+	EpisodeNo         int               `json:"episodeno"`
+	AbsoluteEpisodeNo int               `json:"absoluteepisodeno,omitempty"`
+	Double            bool              `json:"double,omitempty"`
+	BaseName          string            `json:"basename"`
+	Created           time.Time         `json:"created"`
+	EtagFiles         uint64            `json:"etagfiles"`
+	FileName          string            `json:"filename"`
+	FileSize          int64             `json:"filesize"`
+	FileID            [2]uint64         `json:"fileid"`
+	Thumb             string            `json:"thumb,omitempty"`
+	Metadata          metadata.Snapshot `json:"metadata"`
+	SrtSubs           Subtitles         `json:"srt,omitempty"`
+	VttSubs           Subtitles         `json:"vtt,omitempty"`
+}
+
+// snapshotItem is an item restored from the snapshot, with the modification times of its
+// directories at the time the snapshot was stored.
+type snapshotItem struct {
+	item Item
+	dirs map[string]int64
+}
+
+// snapshotConfig returns the settings of a collection that change scan results.
+func snapshotConfig(c *Collection) string {
+	return fmt.Sprintf("%s|%q|%q|%s|%v", c.Type, c.Directories, c.Exclude, c.EpisodeOrder, c.Providers)
+}
+
+// snapshotEtags returns the etags of all collections, they change when items change.
+func (cr *CollectionRepo) snapshotEtags() string {
+	var etags strings.Builder
+	for _, c := range cr.GetCollections() {
+		fmt.Fprintf(&etags, "%s/%s\n", c.ID, c.Etag)
+	}
+	return etags.String()
+}
+
+// SaveSnapshot stores the items of all collections in the snapshot file, they are loaded
+// at the next start so items are available without waiting for a scan.
+func (cr *CollectionRepo) SaveSnapshot() error {
+	if cr.snapshotFile == "" {
+		return nil
+	}
+	cr.snapshotMu.Lock()
+	defer cr.snapshotMu.Unlock()
+
+	etags := cr.snapshotEtags()
+	snapshot := librarySnapshot{
+		Version: snapshotVersion,
+		Created: time.Now().UTC(),
+	}
+	for _, c := range cr.GetCollections() {
+		if len(c.Items) == 0 {
+			continue
+		}
+		cs, err := cr.snapshotCollection(c)
+		if err != nil {
+			log.Printf("Collection %s: not stored in snapshot: %s", c.Name, err)
+			continue
+		}
+		snapshot.Collections = append(snapshot.Collections, cs)
+	}
+
+	// Write to a temporary file first so a partially written snapshot is never loaded
+	tmp := cr.snapshotFile + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(snapshot)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, cr.snapshotFile)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	cr.savedEtags = etags
+	return nil
+}
+
+// updateSnapshot stores the snapshot if items changed since it was stored last.
+func (cr *CollectionRepo) updateSnapshot() {
+	if cr.snapshotFile == "" {
+		return
+	}
+	cr.snapshotMu.Lock()
+	changed := cr.savedEtags != cr.snapshotEtags()
+	cr.snapshotMu.Unlock()
+	if !changed {
+		return
+	}
+	if err := cr.SaveSnapshot(); err != nil {
+		log.Printf("Failed to store library snapshot %s: %s", cr.snapshotFile, err)
+	}
+}
+
+// snapshotCollection returns the snapshot of the items of a collection. Directory modification
+// times are left out for collections that are offline, their items are scanned again after restoring.
+func (cr *CollectionRepo) snapshotCollection(c *Collection) (collectionSnapshot, error) {
+	cs := collectionSnapshot{
+		ID:     c.ID,
+		Config: snapshotConfig(c),
+	}
+	offline := cr.CollectionOffline(c)
+	dirModTimes := func(dir string, subdirs []string) map[string]int64 {
+		if offline {
+			return nil
+		}
+		dirs := make(map[string]int64)
+		for _, subdir := range append([]string{""}, subdirs...) {
+			fi, err := os.Stat(path.Join(dir, subdir))
+			if err != nil {
+				return nil
+			}
+			dirs[subdir] = fi.ModTime().UnixNano()
+		}
+		return dirs
+	}
+
+	for _, i := range c.Items {
+		switch v := i.(type) {
+		case *Movie:
+			m, err := snapshotMetadata(v.Metadata)
+			if err != nil {
+				return cs, fmt.Errorf("movie %s: %w", v.name, err)
+			}
+			ms := snapshotMovie(v)
+			ms.Metadata = &m
+			for _, p := range v.parts {
+				ms.Parts = append(ms.Parts, snapshotMovie(p))
+			}
+			ms.Dirs = dirModTimes(c.ItemDirectory(v), nil)
+			cs.Movies = append(cs.Movies, ms)
+		case *Show:
+			m, err := snapshotMetadata(v.Metadata)
+			if err != nil {
+				return cs, fmt.Errorf("show %s: %w", v.name, err)
+			}
+			ss := showSnapshot{
+				ID:              v.id,
+				Name:            v.name,
+				Path:            v.path,
+				Root:            v.root,
+				FirstVideo:      v.firstVideo,
+				LastVideo:       v.lastVideo,
+				Banner:          v.banner,
+				Fanart:          v.fanart,
+				Folder:          v.folder,
+				Poster:          v.poster,
+				Logo:            v.logo,
+				SeasonAllBanner: v.seasonAllBanner,
+				SeasonAllPoster: v.seasonAllPoster,
+				EtagFiles:       v.etagFiles,
+				Metadata:        m,
+			}
+			var subdirs []string
+			for _, s := range v.Seasons {
+				season := seasonSnapshot{
+					ID:        s.id,
+					Name:      s.name,
+					Number:    s.seasonno,
+					Banner:    s.banner,
+					Fanart:    s.fanart,
+					Poster:    s.poster,
+					EtagFiles: s.etagFiles,
+				}
+				for _, e := range s.Episodes {
+					m, err := snapshotMetadata(e.Metadata)
+					if err != nil {
+						return cs, fmt.Errorf("episode %s: %w", e.fileName, err)
+					}
+					season.Episodes = append(season.Episodes, episodeSnapshot{
+						ID:                e.id,
+						Name:              e.name,
+						SortName:          e.sortName,
+						SeasonNo:          e.SeasonNo,
+						EpisodeNo:         e.EpisodeNo,
+						AbsoluteEpisodeNo: e.AbsoluteEpisodeNo,
+						Double:            e.Double,
+						BaseName:          e.baseName,
+						Created:           e.created,
+						EtagFiles:         e.etagFiles,
+						FileName:          e.fileName,
+						FileSize:          e.fileSize,
+						FileID:            [2]uint64{e.fileID.dev, e.fileID.ino},
+						Thumb:             e.thumb,
+						Metadata:          m,
+						SrtSubs:           e.SrtSubs,
+						VttSubs:           e.VttSubs,
+					})
+					if dir := path.Dir(e.fileName); dir != "." && !slices.Contains(subdirs, dir) {
+						subdirs = append(subdirs, dir)
+					}
+				}
+				ss.Seasons = append(ss.Seasons, season)
+			}
+			ss.Dirs = dirModTimes(c.ItemDirectory(v), subdirs)
+			cs.Shows = append(cs.Shows, ss)
+		}
+	}
+	return cs, nil
+}
+
+// snapshotMovie returns the snapshot of a movie or movie part, without metadata.
+func snapshotMovie(m *Movie) movieSnapshot {
+	return movieSnapshot{
+		ID:        m.id,
+		Name:      m.name,
+		Path:      m.path,
+		Root:      m.root,
+		Created:   m.created,
+		Banner:    m.banner,
+		Fanart:    m.fanart,
+		Folder:    m.folder,
+		Poster:    m.poster,
+		EtagFiles: m.etagFiles,
+		FileName:  m.fileName,
+		FileSize:  m.fileSize,
+		FileID:    [2]uint64{m.fileID.dev, m.fileID.ino},
+		SrtSubs:   m.SrtSubs,
+		VttSubs:   m.VttSubs,
+	}
+}
+
+// snapshotMetadata returns the snapshot of metadata, provider ID overrides are applied again when restoring.
+func snapshotMetadata(m metadata.Metadata) (metadata.Snapshot, error) {
+	if u, ok := m.(interface{ Unwrap() metadata.Metadata }); ok {
+		m = u.Unwrap()
+	}
+	if s, ok := m.(interface{ Snapshot() metadata.Snapshot }); ok {
+		return s.Snapshot(), nil
+	}
+	return metadata.Snapshot{}, fmt.Errorf("metadata of type %T cannot be stored", m)
+}
+
+// restoreSnapshot loads the items of collections from the snapshot file. Collections that were
+// not in the snapshot, or of which settings changed since it was stored, are not restored.
+// Returns the IDs of the restored collections.
+func (cr *CollectionRepo) restoreSnapshot() map[string]bool {
+	if cr.snapshotFile == "" {
+		return nil
+	}
+	snapshot, err := readSnapshot(cr.snapshotFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Cannot load library snapshot %s: %s", cr.snapshotFile, err)
+		}
+		return nil
+	}
+	if snapshot.Version != snapshotVersion {
+		log.Printf("Ignoring library snapshot %s of version %d", cr.snapshotFile, snapshot.Version)
+		return nil
+	}
+
+	restored := make(map[string]bool)
+	cr.snapshotItems = make(map[string]snapshotItem)
+	for _, cs := range snapshot.Collections {
+		c := cr.GetCollection(cs.ID)
+		if c == nil || cs.Config != snapshotConfig(c) {
+			continue
+		}
+		items, snapshotItems, err := cr.restoreCollection(c, cs)
+		if err != nil {
+			log.Printf("Collection %s: cannot restore snapshot: %s", c.Name, err)
+			continue
+		}
+		// Items of the snapshot keep their IDs, unless another collection uses them now
+		c.Items = cr.resolveIDCollisions(c, items, items)
+		for key, si := range snapshotItems {
+			cr.snapshotItems[key] = si
+		}
+		cr.updateFreshness(c)
+		restored[c.ID] = true
+		log.Printf("Collection %s: restored %d items from snapshot of %s", c.Name, len(items), snapshot.Created.Format(time.RFC3339))
+	}
+	cr.savedEtags = cr.snapshotEtags()
+	return restored
+}
+
+// readSnapshot reads a library snapshot file.
+func readSnapshot(filename string) (*librarySnapshot, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var snapshot librarySnapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// restoreCollection returns the items of a collection snapshot, and the restored items by
+// directory for validation by the next scan.
+func (cr *CollectionRepo) restoreCollection(c *Collection, cs collectionSnapshot) ([]Item, map[string]snapshotItem, error) {
+	items := make([]Item, 0, len(cs.Movies)+len(cs.Shows))
+	snapshotItems := make(map[string]snapshotItem)
+	restoreMetadata := func(s metadata.Snapshot) (metadata.Metadata, error) {
+		if m := metadata.FromSnapshot(s, cr.metadataCache); m != nil {
+			return m, nil
+		}
+		return nil, fmt.Errorf("unknown metadata type %s", s.Type)
+	}
+
+	for _, ms := range cs.Movies {
+		if ms.Metadata == nil {
+			return nil, nil, fmt.Errorf("movie %s has no metadata", ms.Name)
+		}
+		m, err := restoreMetadata(*ms.Metadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		movie := restoreMovie(ms)
+		movie.Metadata = cr.itemIdentified(movie.id, m)
+		movie.sortName = cr.itemSortName(movie.id, movie.name, movie.Metadata)
+		for _, ps := range ms.Parts {
+			part := restoreMovie(ps)
+			part.sortName = movie.sortName
+			part.Metadata = movie.Metadata
+			movie.parts = append(movie.parts, part)
+		}
+		items = append(items, movie)
+		snapshotItems[snapshotItemKey(c, movie.root, movie.path)] = snapshotItem{item: movie, dirs: ms.Dirs}
+	}
+
+	for _, ss := range cs.Shows {
+		m, err := restoreMetadata(ss.Metadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		show := &Show{
+			id:              ss.ID,
+			name:            ss.Name,
+			path:            ss.Path,
+			root:            ss.Root,
+			firstVideo:      ss.FirstVideo,
+			lastVideo:       ss.LastVideo,
+			banner:          ss.Banner,
+			fanart:          ss.Fanart,
+			folder:          ss.Folder,
+			poster:          ss.Poster,
+			logo:            ss.Logo,
+			seasonAllBanner: ss.SeasonAllBanner,
+			seasonAllPoster: ss.SeasonAllPoster,
+			etagFiles:       ss.EtagFiles,
+		}
+		show.Metadata = cr.itemIdentified(show.id, m)
+		show.sortName = cr.itemSortName(show.id, show.name, show.Metadata)
+		for _, s := range ss.Seasons {
+			season := Season{
+				id:              s.ID,
+				name:            s.Name,
+				path:            show.path,
+				root:            show.root,
+				seasonno:        s.Number,
+				banner:          s.Banner,
+				fanart:          s.Fanart,
+				poster:          s.Poster,
+				seasonAllBanner: show.seasonAllBanner,
+				seasonAllPoster: show.seasonAllPoster,
+				etagFiles:       s.EtagFiles,
+			}
+			for _, es := range s.Episodes {
+				m, err := restoreMetadata(es.Metadata)
+				if err != nil {
+					return nil, nil, err
+				}
+				season.Episodes = append(season.Episodes, Episode{
+					id:                es.ID,
+					name:              es.Name,
+					path:              show.path,
+					root:              show.root,
+					sortName:          es.SortName,
+					SeasonNo:          es.SeasonNo,
+					EpisodeNo:         es.EpisodeNo,
+					AbsoluteEpisodeNo: es.AbsoluteEpisodeNo,
+					Double:            es.Double,
+					baseName:          es.BaseName,
+					created:           es.Created,
+					etagFiles:         es.EtagFiles,
+					fileName:          es.FileName,
+					fileSize:          es.FileSize,
+					fileID:            fileID{dev: es.FileID[0], ino: es.FileID[1]},
+					thumb:             es.Thumb,
+					Metadata:          m,
+					SrtSubs:           es.SrtSubs,
+					VttSubs:           es.VttSubs,
+				})
+			}
+			show.Seasons = append(show.Seasons, season)
+		}
+		// Register parents of episodes so played counts of show and seasons can be maintained
+		for _, s := range show.Seasons {
+			for _, e := range s.Episodes {
+				cr.repo.SetItemParents(e.id, []string{s.id, show.id})
+			}
+		}
+		items = append(items, show)
+		snapshotItems[snapshotItemKey(c, show.root, show.path)] = snapshotItem{item: show, dirs: ss.Dirs}
+	}
+	return items, snapshotItems, nil
+}
+
+// restoreMovie returns a movie or movie part from its snapshot, without metadata.
+func restoreMovie(ms movieSnapshot) *Movie {
+	return &Movie{
+		id:        ms.ID,
+		name:      ms.Name,
+		path:      ms.Path,
+		root:      ms.Root,
+		created:   ms.Created,
+		banner:    ms.Banner,
+		fanart:    ms.Fanart,
+		folder:    ms.Folder,
+		poster:    ms.Poster,
+		etagFiles: ms.EtagFiles,
+		fileName:  ms.FileName,
+		fileSize:  ms.FileSize,
+		fileID:    fileID{dev: ms.FileID[0], ino: ms.FileID[1]},
+		SrtSubs:   ms.SrtSubs,
+		VttSubs:   ms.VttSubs,
+	}
+}
+
+// snapshotItemKey returns the key of an item directory in the restored snapshot items.
+func snapshotItemKey(c *Collection, root, dir string) string {
+	return c.ID + ":" + path.Join(root, dir)
+}
+
+// unchangedSnapshotItem returns the item restored from the snapshot for a directory, if the
+// modification times of its directories did not change since the snapshot was stored.
+// Returns nil if the directory has to be scanned.
+func (cr *CollectionRepo) unchangedSnapshotItem(coll *Collection, root, dir string) Item {
+	si, ok := cr.snapshotItems[snapshotItemKey(coll, root, dir)]
+	if !ok || len(si.dirs) == 0 {
+		return nil
+	}
+	for subdir, modTime := range si.dirs {
+		fi, err := os.Stat(path.Join(root, dir, subdir))
+		if err != nil || fi.ModTime().UnixNano() != modTime {
+			return nil
+		}
+	}
+	return si.item
+}
@@ -0,0 +1,116 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+// sortNamesKey is the settings key the sort name overrides are stored under.
+const sortNamesKey = "collection.sortnames"
+
+// DefaultSortArticles are the articles removed from the start of names when sorting.
+var DefaultSortArticles = []string{"the", "a", "an"}
+
+// itemSortName returns the name an item is sorted on: the override set through the API,
+// the sort title from the NFO, or the name without leading article.
+func (cr *CollectionRepo) itemSortName(itemID, name string, m metadata.Metadata) string {
+	cr.sortNamesMu.RLock()
+	override := cr.sortNames[itemID]
+	cr.sortNamesMu.RUnlock()
+	if override != "" {
+		return strings.ToLower(override)
+	}
+	if m != nil {
+		if sortTitle := strings.TrimSpace(m.SortTitle()); sortTitle != "" {
+			return strings.ToLower(sortTitle)
+		}
+	}
+	return makeSortName(name, cr.sortArticles)
+}
+
+// SortNameOverride returns the sort name override of an item, empty if not set.
+func (cr *CollectionRepo) SortNameOverride(itemID string) string {
+	cr.sortNamesMu.RLock()
+	defer cr.sortNamesMu.RUnlock()
+	return cr.sortNames[itemID]
+}
+
+// SetSortName overrides the name an item is sorted on, an empty name removes the override.
+// The override is stored and applied to the item right away.
+func (cr *CollectionRepo) SetSortName(ctx context.Context, itemID, sortName string) error {
+	cr.sortNamesMu.Lock()
+	sortName = strings.TrimSpace(sortName)
+	if sortName == "" {
+		delete(cr.sortNames, itemID)
+	} else {
+		cr.sortNames[itemID] = sortName
+	}
+	value, _ := json.Marshal(cr.sortNames)
+	cr.sortNamesMu.Unlock()
+
+	if cr.repo != nil {
+		if err := cr.repo.UpsertSetting(ctx, sortNamesKey, string(value)); err != nil {
+			return err
+		}
+	}
+	_, i := cr.GetItemByID(itemID)
+	switch i := i.(type) {
+	case *Movie:
+		i.sortName = cr.itemSortName(i.id, i.name, i.Metadata)
+	case *Show:
+		i.sortName = cr.itemSortName(i.id, i.name, i.Metadata)
+	}
+	return nil
+}
+
+// loadSortNames loads the stored sort name overrides.
+func (cr *CollectionRepo) loadSortNames() {
+	if cr.repo == nil {
+		return
+	}
+	value, err := cr.repo.GetSetting(context.Background(), sortNamesKey)
+	if err != nil || value == "" {
+		return
+	}
+	var sortNames map[string]string
+	if err := json.Unmarshal([]byte(value), &sortNames); err != nil {
+		log.Printf("Failed to load sort name overrides: %s", err)
+		return
+	}
+	cr.sortNamesMu.Lock()
+	defer cr.sortNamesMu.Unlock()
+	cr.sortNames = sortNames
+}
+
+// makeSortName returns a name suitable for sorting.
+func makeSortName(name string, articles []string) string {
+	// Start with lowercasing and trimming whitespace.
+	title := strings.ToLower(strings.TrimSpace(name))
+
+	// Remove leading articles, articles ending with an apostrophe such as "l'" are not
+	// followed by a space.
+	for _, article := range articles {
+		prefix := strings.ToLower(article)
+		if !strings.HasSuffix(prefix, "'") {
+			prefix += " "
+		}
+		if strings.HasPrefix(title, prefix) && len(title) > len(prefix) {
+			title = strings.TrimSpace(title[len(prefix):])
+			break
+		}
+	}
+
+	// Remove whitespace and punctuation.
+	title = strings.TrimLeftFunc(title, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+
+	// Remove year suffix if present.
+	title = removeYearSuffix(title)
+	return title
+}
@@ -0,0 +1,221 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// thumbnailPosition is the fraction of the runtime at which a frame is extracted.
+	thumbnailPosition = 0.2
+	// thumbnailFallbackOffset is used as position in case episode runtime is not known.
+	thumbnailFallbackOffset = 5 * time.Minute
+	// thumbnailTimeout is the maximum time ffmpeg may take to extract a single frame.
+	thumbnailTimeout = 2 * time.Minute
+	// thumbnailInterval is the wait time between scans for episodes without thumbnail.
+	thumbnailInterval = 10 * time.Minute
+)
+
+// Thumbnail returns the filename of an extracted thumbnail of an episode.
+// Returns false if no thumbnail has been extracted (yet).
+func (cr *CollectionRepo) Thumbnail(episodeID string) (string, bool) {
+	if cr.thumbnailDir == "" {
+		return "", false
+	}
+	cr.thumbnailsMu.RLock()
+	defer cr.thumbnailsMu.RUnlock()
+	if !cr.thumbnails[episodeID] {
+		return "", false
+	}
+	return cr.thumbnailFilename(episodeID), true
+}
+
+// thumbnailFilename returns the filename of the thumbnail of an episode.
+func (cr *CollectionRepo) thumbnailFilename(episodeID string) string {
+	return path.Join(cr.thumbnailDir, episodeID+".jpg")
+}
+
+// thumbnailBackground keeps extracting thumbnails for episodes that do not have one.
+func (cr *CollectionRepo) thumbnailBackground(ctx context.Context) {
+	if cr.thumbnailDir == "" {
+		return
+	}
+	if _, err := exec.LookPath(cr.ffmpeg); err != nil {
+		log.Printf("Thumbnail extraction disabled, cannot find %s: %s", cr.ffmpeg, err)
+		return
+	}
+	if err := os.MkdirAll(cr.thumbnailDir, 0755); err != nil {
+		log.Printf("Thumbnail extraction disabled: %s", err)
+		return
+	}
+	for {
+		cr.extractThumbnails(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(thumbnailInterval):
+		}
+	}
+}
+
+// thumbnailFailure is a video file thumbnail extraction failed for. Extraction is not
+// retried until the file changes.
+type thumbnailFailure struct {
+	size    int64
+	modtime time.Time
+}
+
+// extractThumbnails extracts a thumbnail for every episode without thumb image.
+// Episodes extraction failed for before are skipped until their video file changes.
+func (cr *CollectionRepo) extractThumbnails(ctx context.Context) {
+	var extracted int
+	for _, c := range cr.GetCollections() {
+		if c.Type != CollectionTypeShows {
+			continue
+		}
+		for _, i := range c.Items {
+			show, ok := i.(*Show)
+			if !ok {
+				continue
+			}
+			for si := range show.Seasons {
+				for ei := range show.Seasons[si].Episodes {
+					if ctx.Err() != nil {
+						return
+					}
+					e := &show.Seasons[si].Episodes[ei]
+					if e.thumb != "" || e.fileName == "" {
+						continue
+					}
+					if cr.haveThumbnail(e.id) {
+						continue
+					}
+					filename := path.Join(c.ItemDirectory(e), e.fileName)
+					fi, err := os.Stat(filename)
+					if err != nil || cr.thumbnailFailed(filename, fi) {
+						continue
+					}
+					if err := cr.extractThumbnail(ctx, c, e); err != nil {
+						if ctx.Err() != nil {
+							return
+						}
+						log.Printf("Thumbnail extraction of %s failed: %s", e.fileName, err)
+						cr.setThumbnailFailed(filename, fi)
+						continue
+					}
+					extracted++
+				}
+			}
+		}
+	}
+	if extracted > 0 {
+		log.Printf("Extracted %d episode thumbnails", extracted)
+	}
+}
+
+// haveThumbnail returns true if the thumbnail of an episode is available on disk.
+func (cr *CollectionRepo) haveThumbnail(episodeID string) bool {
+	cr.thumbnailsMu.RLock()
+	found := cr.thumbnails[episodeID]
+	cr.thumbnailsMu.RUnlock()
+	if found {
+		return true
+	}
+	// Thumbnail might have been extracted before a restart
+	if _, err := os.Stat(cr.thumbnailFilename(episodeID)); err != nil {
+		return false
+	}
+	cr.setThumbnail(episodeID)
+	return true
+}
+
+// setThumbnail records an episode has a thumbnail.
+func (cr *CollectionRepo) setThumbnail(episodeID string) {
+	cr.thumbnailsMu.Lock()
+	cr.thumbnails[episodeID] = true
+	cr.thumbnailsMu.Unlock()
+}
+
+// thumbnailFailed returns true if thumbnail extraction failed for a video file before,
+// and the file has not changed since.
+func (cr *CollectionRepo) thumbnailFailed(filename string, fi os.FileInfo) bool {
+	cr.thumbnailsMu.RLock()
+	defer cr.thumbnailsMu.RUnlock()
+	failure, found := cr.thumbnailFailures[filename]
+	return found && failure.size == fi.Size() && failure.modtime.Equal(fi.ModTime())
+}
+
+// setThumbnailFailed records thumbnail extraction failed for a video file.
+func (cr *CollectionRepo) setThumbnailFailed(filename string, fi os.FileInfo) {
+	cr.thumbnailsMu.Lock()
+	defer cr.thumbnailsMu.Unlock()
+	cr.thumbnailFailures[filename] = thumbnailFailure{
+		size:    fi.Size(),
+		modtime: fi.ModTime(),
+	}
+}
+
+// extractThumbnail extracts a single frame at about 20% of the runtime of an episode.
+func (cr *CollectionRepo) extractThumbnail(ctx context.Context, c *Collection, e *Episode) error {
+	ctx, cancel := context.WithTimeout(ctx, thumbnailTimeout)
+	defer cancel()
+
+	filename := path.Join(c.ItemDirectory(e), e.fileName)
+	duration := e.Duration()
+	if duration == 0 {
+		duration = cr.probeDuration(ctx, filename)
+	}
+	position := thumbnailFallbackOffset
+	if duration > 0 {
+		position = time.Duration(float64(duration) * thumbnailPosition)
+	}
+
+	// Write to temporary file first so a partially written thumbnail is never served.
+	dst := cr.thumbnailFilename(e.id)
+	tmp := dst + ".tmp.jpg"
+	cmd := exec.CommandContext(ctx, cr.ffmpeg,
+		"-nostdin", "-loglevel", "error", "-y",
+		"-ss", strconv.FormatFloat(position.Seconds(), 'f', 3, 64),
+		"-i", filename,
+		"-frames:v", "1",
+		"-q:v", "3",
+		tmp)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	cr.setThumbnail(e.id)
+	return nil
+}
+
+// probeDuration returns the runtime of a video file using ffprobe, zero if unknown.
+func (cr *CollectionRepo) probeDuration(ctx context.Context, filename string) time.Duration {
+	ffprobe := path.Join(path.Dir(cr.ffmpeg), strings.Replace(path.Base(cr.ffmpeg), "ffmpeg", "ffprobe", 1))
+	output, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filename).Output()
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/sqlite"
+	"github.com/erikbos/jellofin-server/idhash"
+	"github.com/erikbos/jellofin-server/jellyfin"
+)
+
+// envPrefix is the prefix of environment variables that override config file settings,
+// e.g. JELLOFIN_JELLYFIN_SERVERNAME overrides jellyfin.servername.
+const envPrefix = "JELLOFIN"
+
+type configFile struct {
+	Listen struct {
+		Address   string
+		Addresses []string
+		Port      string
+		TlsCert   string
+		TlsKey    string
+		IPACL     string
+	}
+	Appdir       string
+	Datadir      string
+	Cachedir     string
+	Cachemaxsize int64
+	Thumbnaildir string
+	Ffmpeg       string
+	Dbdir        string
+	Snapshotfile string
+	Database     struct {
+		Sqlite sqlite.ConfigFile `yaml:"sqlite"`
+	} `yaml:"database"`
+	Logfile     string
+	Collections []struct {
+		ID                string
+		Name              string
+		Type              string
+		Directory         string
+		Directories       []string
+		BaseUrl           string
+		HlsServer         string
+		Exclude           []string
+		SubtitleLanguages []string
+		MetadataProviders []string
+		ImageProviders    []string
+		SubtitleProviders []string
+		EpisodeOrder      string
+		// RefreshIntervalDays is the number of days after which metadata of items is refreshed.
+		RefreshIntervalDays int
+		// Images are the quality, size and format of images served from the collection.
+		Images collection.ImageOptions
+		// DisplayNames are translations of the collection name by language.
+		DisplayNames map[string]string
+	}
+	Scanworkers       int
+	Metadatacachesize int
+	Sortarticles      []string
+	Genremapping      string
+	Similar           *collection.SimilarWeights
+	Jellyfin          struct {
+		ServerID           string
+		ServerName         string
+		AutoRegister       bool
+		QuickConnect       bool
+		ImageQualityPoster int
+		ImageWidthBuckets  []int
+		ParentalRatings    string
+		CompressionLevel   int
+		CompressionLevels  map[string]int
+		CompressionMinSize int
+		SessionIdleTimeout time.Duration
+		Cors               jellyfin.CORS
+		RequestTimeout     time.Duration
+		Branding           jellyfin.Branding
+		Resume             jellyfin.Resume
+		SmartCollections   []jellyfin.SmartCollection
+		DeleteFiles        bool
+		MaxItems           int
+		DedupeItems        bool
+		IntroDetection     bool
+		Notifications      jellyfin.Notifications
+		Discovery          struct {
+			Enabled bool
+			MDNS    bool
+		}
+		PortMapping struct {
+			Enabled      bool
+			ExternalPort int
+		}
+	}
+	Tmdb struct {
+		ApiKey  string
+		Persons bool
+	}
+	OpenSubtitles struct {
+		ApiKey   string
+		Username string
+		Password string
+	}
+	Accesslog struct {
+		Format  string
+		Exclude []string
+	}
+}
+
+// loadConfig reads the config file, applies environment variable overrides and
+// validates the result. Unknown keys in the config file are an error.
+func loadConfig(filename string) (configFile, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetDefault("listen.port", "8096")
+	v.SetDefault("logfile", "/dev/stdout")
+	// Allow overriding every setting using environment variables
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	configType := reflect.TypeOf(configFile{})
+	for _, key := range configKeys(configType, "") {
+		v.BindEnv(key)
+	}
+
+	var config configFile
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return config, fmt.Errorf("cannot read config file %s: %w", filename, err)
+	}
+	if unknown := unknownConfigKeys(v, configType); len(unknown) > 0 {
+		return config, fmt.Errorf("config file %s has unknown keys: %s", filename, strings.Join(unknown, ", "))
+	}
+	if err := v.Unmarshal(&config); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	applyDatadir(&config)
+	if err := validateConfig(config); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return config, nil
+}
+
+// configKeys returns the keys of all settings of a config struct, e.g. "jellyfin.servername".
+// Lists of structs, such as collections, are returned as a single key.
+func configKeys(t reflect.Type, prefix string) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var keys []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key := prefix + strings.ToLower(field.Name)
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			keys = append(keys, configKeys(ft, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// unknownConfigKeys returns the keys in the config file that do not match a setting,
+// including unknown keys of collections.
+func unknownConfigKeys(v *viper.Viper, configType reflect.Type) []string {
+	known := make(map[string]bool)
+	for _, key := range configKeys(configType, "") {
+		known[key] = true
+	}
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	collectionField, _ := configType.FieldByName("Collections")
+	collectionKeys := make(map[string]bool)
+	for _, key := range configKeys(collectionField.Type.Elem(), "") {
+		collectionKeys[key] = true
+	}
+	collections, _ := v.Get("collections").([]any)
+	for n, c := range collections {
+		settings, _ := c.(map[string]any)
+		for key, value := range settings {
+			// Sections such as images are checked key by key, maps such as displaynames are a single setting
+			if section, ok := value.(map[string]any); ok && !collectionKeys[strings.ToLower(key)] {
+				for subkey := range section {
+					if !collectionKeys[strings.ToLower(key+"."+subkey)] {
+						unknown = append(unknown, fmt.Sprintf("collections[%d].%s.%s", n, key, subkey))
+					}
+				}
+				continue
+			}
+			if !collectionKeys[strings.ToLower(key)] {
+				unknown = append(unknown, fmt.Sprintf("collections[%d].%s", n, key))
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// validateConfig checks the config for mistakes that would prevent the server from working.
+// Collection directories that do not exist are only logged, as storage can come online later.
+func validateConfig(config configFile) error {
+	var errs []error
+	if port, err := strconv.Atoi(config.Listen.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("listen.port %q is not a valid port number", config.Listen.Port))
+	}
+	if (config.Listen.TlsCert == "") != (config.Listen.TlsKey == "") {
+		errs = append(errs, errors.New("listen.tlscert and listen.tlskey must be set together"))
+	}
+	errs = append(errs, checkFile("listen.tlscert", config.Listen.TlsCert, false))
+	errs = append(errs, checkFile("listen.tlskey", config.Listen.TlsKey, false))
+	errs = append(errs, checkFile("appdir", config.Appdir, true))
+	if config.Database.Sqlite.Filename == "" && config.Dbdir == "" {
+		errs = append(errs, errors.New("database.sqlite.filename or datadir must be set"))
+	}
+	if err := validAccessLogFormat(config.Accesslog.Format); err != nil {
+		errs = append(errs, fmt.Errorf("accesslog.format: %w", err))
+	}
+	if q := config.Jellyfin.ImageQualityPoster; q < 0 || q > 100 {
+		errs = append(errs, fmt.Errorf("jellyfin.imagequalityposter %d is not between 0 and 100", q))
+	}
+	for _, width := range config.Jellyfin.ImageWidthBuckets {
+		if width <= 0 {
+			errs = append(errs, fmt.Errorf("jellyfin.imagewidthbuckets width %d is not positive", width))
+		}
+	}
+	if port := config.Jellyfin.PortMapping.ExternalPort; port < 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("jellyfin.portmapping.externalport %d is not a valid port number", port))
+	}
+	if err := config.Jellyfin.Notifications.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("jellyfin.notifications: %w", err))
+	}
+	if config.Jellyfin.MaxItems < 0 {
+		errs = append(errs, fmt.Errorf("jellyfin.maxitems %d is negative", config.Jellyfin.MaxItems))
+	}
+
+	ids := make(map[string]bool)
+	for n, coll := range config.Collections {
+		if coll.Name == "" {
+			errs = append(errs, fmt.Errorf("collection %d has no name", n+1))
+			continue
+		}
+		if coll.Type != "movies" && coll.Type != "shows" {
+			errs = append(errs, fmt.Errorf("collection %s has unknown type %q, must be movies or shows", coll.Name, coll.Type))
+		}
+		directories := collectionDirectories(coll.Directory, coll.Directories)
+		if len(directories) == 0 {
+			errs = append(errs, fmt.Errorf("collection %s has no directories", coll.Name))
+		}
+		for _, dir := range directories {
+			if err := checkFile("collection "+coll.Name+" directory", dir, true); err != nil {
+				log.Printf("Warning: %s", err)
+			}
+		}
+		id := coll.ID
+		if id == "" {
+			id = idhash.IdHash(coll.Name)
+		}
+		if ids[id] {
+			errs = append(errs, fmt.Errorf("collection %s has duplicate id %s", coll.Name, id))
+		}
+		ids[id] = true
+	}
+	return errors.Join(errs...)
+}
+
+// applyDatadir sets the database file and image cache directory to their default
+// locations inside datadir, if they are not configured.
+func applyDatadir(config *configFile) {
+	if config.Datadir == "" {
+		return
+	}
+	if config.Database.Sqlite.Filename == "" && config.Dbdir == "" {
+		config.Database.Sqlite.Filename = path.Join(config.Datadir, "jellofin.db")
+	}
+	if config.Cachedir == "" {
+		config.Cachedir = path.Join(config.Datadir, "cache")
+	}
+	if config.Snapshotfile == "" {
+		config.Snapshotfile = path.Join(config.Datadir, "library.snapshot")
+	}
+}
+
+// stateDirectories returns the directories the server writes to, by config key.
+func stateDirectories(config configFile) map[string]string {
+	dirs := map[string]string{
+		"datadir":      config.Datadir,
+		"cachedir":     config.Cachedir,
+		"thumbnaildir": config.Thumbnaildir,
+		"dbdir":        config.Dbdir,
+	}
+	if config.Database.Sqlite.Filename != "" {
+		dirs["database.sqlite.filename"] = path.Dir(config.Database.Sqlite.Filename)
+	}
+	dirs["database.sqlite.backupdir"] = config.Database.Sqlite.BackupDir
+	if file := snapshotFile(config); file != "" {
+		dirs["snapshotfile"] = path.Dir(file)
+	}
+	return dirs
+}
+
+// databaseFilename returns the sqlite database file.
+func databaseFilename(config configFile) string {
+	if config.Database.Sqlite.Filename != "" {
+		return config.Database.Sqlite.Filename
+	}
+	// Legacy support for Dbdir
+	if config.Dbdir != "" {
+		return path.Join(config.Dbdir, "tink-items.db")
+	}
+	return ""
+}
+
+// snapshotFile returns the library snapshot file, empty if disabled.
+func snapshotFile(config configFile) string {
+	if config.Snapshotfile == "none" {
+		return ""
+	}
+	return config.Snapshotfile
+}
+
+// restoreDatabase replaces the database with a backup.
+func restoreDatabase(config configFile, backupFilename string) error {
+	filename := databaseFilename(config)
+	if filename == "" {
+		return errors.New("no database configured")
+	}
+	if err := sqlite.Restore(backupFilename, filename); err != nil {
+		return err
+	}
+	log.Printf("Restored database %s from %s", filename, backupFilename)
+	return nil
+}
+
+// prepareStateDirectories creates the directories the server writes to, and checks
+// they are writable. This helps to spot volume permission problems in containers.
+func prepareStateDirectories(config configFile) error {
+	var errs []error
+	for key, dir := range stateDirectories(config) {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			errs = append(errs, fmt.Errorf("%s: cannot create directory %s: %w", key, dir, err))
+			continue
+		}
+		f, err := os.CreateTemp(dir, ".writetest-*")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: directory %s is not writable by uid %d: %w", key, dir, os.Getuid(), err))
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	return errors.Join(errs...)
+}
+
+// checkFile returns an error if a configured file or directory does not exist.
+func checkFile(key, filename string, directory bool) error {
+	if filename == "" {
+		return nil
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("%s %s does not exist", key, filename)
+	}
+	if directory && !fi.IsDir() {
+		return fmt.Errorf("%s %s is not a directory", key, filename)
+	}
+	return nil
+}
+
+// collectionDirectories returns the directories of a collection, directory is the legacy single directory.
+func collectionDirectories(directory string, directories []string) []string {
+	if directory != "" {
+		return append([]string{directory}, directories...)
+	}
+	return directories
+}
+
+// collectionProviders returns the providers of a collection in order of priority.
+func collectionProviders(metadata, images, subtitles []string) collection.ProviderConfig {
+	return collection.ProviderConfig{
+		Metadata:  metadata,
+		Images:    images,
+		Subtitles: subtitles,
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/collection/metadata"
+	"github.com/erikbos/jellofin-server/jellyfin"
+)
+
+// configReloadInterval is how often the config file is checked for changes.
+const configReloadInterval = 15 * time.Second
+
+// configReloader applies changes of the config file at runtime. Only settings that are
+// safe to change are applied: auto-registration, poster image quality, the genre mapping
+// and additional collections. Other settings require a restart.
+type configReloader struct {
+	mu          sync.Mutex
+	filename    string
+	modTime     time.Time
+	collections *collection.CollectionRepo
+	jellyfin    *jellyfin.Jellyfin
+}
+
+// newConfigReloader creates a config reloader for a config file.
+func newConfigReloader(filename string, collections *collection.CollectionRepo) *configReloader {
+	cr := &configReloader{
+		filename:    filename,
+		collections: collections,
+	}
+	if fi, err := os.Stat(filename); err == nil {
+		cr.modTime = fi.ModTime()
+	}
+	return cr
+}
+
+// watch reloads the config file when it has been modified.
+func (cr *configReloader) watch() {
+	for {
+		time.Sleep(configReloadInterval)
+		fi, err := os.Stat(cr.filename)
+		if err != nil {
+			continue
+		}
+		cr.mu.Lock()
+		modified := !fi.ModTime().Equal(cr.modTime)
+		cr.mu.Unlock()
+		if !modified {
+			continue
+		}
+		log.Printf("Config file %s modified, reloading", cr.filename)
+		if err := cr.reload(); err != nil {
+			log.Printf("Keeping current configuration because the config file is invalid: %v", err)
+		}
+	}
+}
+
+// reload reads the config file, validates it and applies the settings that can be
+// changed at runtime.
+func (cr *configReloader) reload() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if fi, err := os.Stat(cr.filename); err == nil {
+		cr.modTime = fi.ModTime()
+	}
+	config, err := loadConfig(cr.filename)
+	if err != nil {
+		return err
+	}
+
+	if config.Genremapping != "" {
+		if err := metadata.LoadGenreMapping(config.Genremapping); err != nil {
+			return err
+		}
+	}
+	if cr.jellyfin != nil {
+		cr.jellyfin.Reconfigure(&jellyfin.Options{
+			AutoRegister:       config.Jellyfin.AutoRegister,
+			ImageQualityPoster: config.Jellyfin.ImageQualityPoster,
+		})
+	}
+	for _, coll := range config.Collections {
+		if err := cr.collections.QueueCollection(
+			coll.Name,
+			coll.ID,
+			coll.Type,
+			collectionDirectories(coll.Directory, coll.Directories),
+			coll.HlsServer,
+			coll.Exclude,
+			coll.SubtitleLanguages,
+			collectionProviders(coll.MetadataProviders, coll.ImageProviders, coll.SubtitleProviders),
+			coll.EpisodeOrder,
+			coll.RefreshIntervalDays,
+			coll.Images,
+			coll.DisplayNames,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
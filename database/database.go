@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/erikbos/jellofin-server/database/model"
 	"github.com/erikbos/jellofin-server/database/sqlite"
@@ -16,9 +17,16 @@ type Repository interface {
 	ItemRepo
 	UserDataRepo
 	PlaylistRepo
+	BoxSetRepo
 	PersonRepo
 	ImageRepo
+	MetadataCacheRepo
+	SettingsRepo
+	ActivityLogRepo
+	PlaybackHistoryRepo
 	StartBackgroundJobs(ctx context.Context)
+	// Flush writes changes kept in memory, such as playback progress, to the database.
+	Flush(ctx context.Context) error
 }
 
 // UserRepo defines the interface for user database operations
@@ -61,6 +69,9 @@ type QuickConnectRepo interface {
 // ItemRepo defines item operations
 type ItemRepo interface {
 	DbLoadItem(item *model.Item)
+	// SetItemParents registers the show and season an episode belongs to, so played
+	// counts of these can be maintained when the play state of the episode changes.
+	SetItemParents(itemID string, parentIDs []string)
 }
 
 // UserDataRepo defines play-state operations
@@ -69,11 +80,19 @@ type UserDataRepo interface {
 	GetUserData(ctx context.Context, userID, itemID string) (details *model.UserData, err error)
 	// Get all favorite items of a user.
 	GetFavorites(ctx context.Context, userID string) (favoriteItemIDs []string, err error)
+	// GetLikes returns the items a user likes and dislikes.
+	GetLikes(ctx context.Context, userID string) (likedItemIDs, dislikedItemIDs []string, err error)
 	// GetRecentlyWatched returns last 10 watched items that have not been fully watched.
 	// If seriesID is provided, it returns all watched items.
 	GetRecentlyWatched(ctx context.Context, userID string, count int, includeFullyWatched bool) (resumeItemIDs []string, err error)
 	// Update stores the play state details for a user and item.
 	UpdateUserData(ctx context.Context, userID, itemID string, details *model.UserData) error
+	// UpdateUserDataIfNewer stores the play state details in case these are more recent
+	// than the stored details, based upon timestamp. Returns true if the details were stored.
+	UpdateUserDataIfNewer(ctx context.Context, userID, itemID string, details *model.UserData) (bool, error)
+	// GetPlayedCount returns the number of played children of a show or season for a user.
+	// The count is recomputed in case the children of the item have changed.
+	GetPlayedCount(ctx context.Context, userID, itemID string, childIDs []string) (model.PlayedCount, error)
 }
 
 // PlaylistRepo defines playlist DB operations
@@ -86,10 +105,26 @@ type PlaylistRepo interface {
 	MovePlaylistItem(ctx context.Context, playlistID string, itemID string, newIndex int) error
 }
 
+// BoxSetRepo defines boxset DB operations
+type BoxSetRepo interface {
+	// CreateBoxSet creates a boxset and returns its ID.
+	CreateBoxSet(ctx context.Context, b model.BoxSet) (boxSetID string, err error)
+	// GetBoxSets retrieves all boxsets.
+	GetBoxSets(ctx context.Context) ([]model.BoxSet, error)
+	// GetBoxSet retrieves a boxset with its items.
+	GetBoxSet(ctx context.Context, boxSetID string) (*model.BoxSet, error)
+	// AddItemsToBoxSet adds items to a boxset, items already in the boxset are ignored.
+	AddItemsToBoxSet(ctx context.Context, boxSetID string, itemIDs []string) error
+	// DeleteItemsFromBoxSet removes items from a boxset.
+	DeleteItemsFromBoxSet(ctx context.Context, boxSetID string, itemIDs []string) error
+}
+
 // PersonRepo defines person DB operations
 type PersonRepo interface {
 	// GetPerson retrieves a person by name.
 	GetPersonByName(ctx context.Context, name, userID string) (person *model.Person, err error)
+	// UpsertPerson stores the details of a person, an existing person with the same name is updated.
+	UpsertPerson(ctx context.Context, person model.Person) error
 }
 
 type ImageRepo interface {
@@ -103,6 +138,38 @@ type ImageRepo interface {
 	DeleteImage(ctx context.Context, itemID, imageType string) error
 }
 
+// MetadataCacheRepo defines metadata parse cache operations
+type MetadataCacheRepo interface {
+	// GetMetadataCache retrieves the cached parse result of a file, if its modification time and size still match.
+	GetMetadataCache(ctx context.Context, path string, modTime time.Time, size int64) (*model.MetadataCacheEntry, error)
+	// UpsertMetadataCache stores the parse result of a file.
+	UpsertMetadataCache(ctx context.Context, entry model.MetadataCacheEntry) error
+}
+
+// SettingsRepo defines server settings operations
+type SettingsRepo interface {
+	// GetSetting retrieves a server setting by key.
+	GetSetting(ctx context.Context, key string) (value string, err error)
+	// UpsertSetting stores a server setting.
+	UpsertSetting(ctx context.Context, key, value string) error
+}
+
+// ActivityLogRepo defines activity log operations
+type ActivityLogRepo interface {
+	// AddActivityLogEntry stores an activity log entry.
+	AddActivityLogEntry(ctx context.Context, entry model.ActivityLogEntry) error
+	// GetActivityLogEntries returns activity log entries, most recent first, and the total number of matching entries.
+	GetActivityLogEntries(ctx context.Context, query model.ActivityLogQuery) (entries []model.ActivityLogEntry, total int, err error)
+}
+
+// PlaybackHistoryRepo defines playback history operations
+type PlaybackHistoryRepo interface {
+	// AddPlaybackHistoryEntry stores a playback of an item.
+	AddPlaybackHistoryEntry(ctx context.Context, entry model.PlaybackHistoryEntry) error
+	// GetPlaybackHistory returns playback history entries, most recent first, and the total number of matching entries.
+	GetPlaybackHistory(ctx context.Context, query model.PlaybackHistoryQuery) (entries []model.PlaybackHistoryEntry, total int, err error)
+}
+
 // New creates a new database repository based on the type and options provided.
 func New(t string, o any) (Repository, error) {
 	switch t {
@@ -46,12 +46,44 @@ type UserProperties struct {
 	EnabledFolders []string
 	// OrderedViews is a list of collection displayPreferenceIDs in the order they should be displayed for the user.
 	OrderedViews []string
+	// PinnedViews is a list of collection item IDs that are shown first, before the ordered views.
+	PinnedViews []string
 	// MyMediaExcludes is a list of collection displayPreferenceIDs that should be excluded from the user's personalized view.
 	MyMediaExcludes []string
 	// AllowTags is a list of tags that are allowed for the user.
 	AllowTags []string
 	// BlockTags is a list of tags that are blocked for the user.
 	BlockTags []string
+	// MaxParentalRating is the maximum parental rating score of items the user can see, -1 means unrestricted.
+	MaxParentalRating int
+	// DisplayMissingEpisodes indicates if episodes that have aired but are not available should be listed.
+	DisplayMissingEpisodes bool
+	// MinResumePercentage is the played percentage below which no resume position is kept, 0 uses the server setting.
+	MinResumePercentage int
+	// MaxResumePercentage is the played percentage above which an item is marked as played, 0 uses the server setting.
+	MaxResumePercentage int
+	// MinResumeDurationSeconds is the duration below which items are not resumable, 0 uses the server setting.
+	MinResumeDurationSeconds int
+	// MaxActiveSessions is the maximum number of devices that can play at the same time, 0 means unlimited.
+	MaxActiveSessions int
+	// RemoteClientBitrateLimit is the maximum bitrate in bits per second of items played outside the local network, 0 means unlimited.
+	RemoteClientBitrateLimit int
+	// PlayDefaultAudioTrack indicates if the default audio track of a file is played regardless of its language.
+	PlayDefaultAudioTrack bool
+	// AudioLanguagePreference is the preferred audio language of the user, e.g. "eng".
+	AudioLanguagePreference string
+	// SubtitleLanguagePreference is the preferred subtitle language of the user, e.g. "eng".
+	SubtitleLanguagePreference string
+	// SubtitleMode is the subtitle mode of the user, e.g. "Default", "Always" or "None".
+	SubtitleMode string
+	// HiddenItems is a list of item IDs the user has hidden from all listings.
+	HiddenItems []string
+	// Pin is the hashed PIN of the user, empty if the user has no PIN.
+	Pin string
+	// Guest indicates the user can browse and play, but not change play states, favorites, ratings or playlists.
+	Guest bool
+	// PinFolders is a list of collection item IDs that can only be opened after entering the PIN.
+	PinFolders []string
 }
 
 // AccessToken represents an access token for a user.
@@ -116,10 +148,26 @@ type UserData struct {
 	Played bool
 	// True if the item is favorite of user
 	Favorite bool
+	// Likes is true if the user likes the item, false if disliked, nil if not rated
+	Likes *bool
+	// Rating is the rating of the user (0.0 - 10.0), 0 if not rated
+	Rating float64
 	// Timestamp of item playing
 	Timestamp time.Time
 }
 
+// PlayedCount is the aggregated play state of the episodes of a show or season for a user.
+type PlayedCount struct {
+	// Played is the number of played episodes.
+	Played int
+	// Children is the number of episodes the count is based upon, -1 means it needs to be recomputed.
+	Children int
+	// LastPlayed is the most recent time an episode was played.
+	LastPlayed time.Time
+	// Timestamp of the last change of the count.
+	Timestamp time.Time
+}
+
 // Playlist represents a user playlist with item IDs.
 type Playlist struct {
 	// ID is the unique identifier for the playlist.
@@ -132,6 +180,20 @@ type Playlist struct {
 	ItemIDs []string
 }
 
+// BoxSet represents a collection of items, e.g. all movies of a franchise, shared by all users.
+type BoxSet struct {
+	// ID is the unique identifier for the boxset.
+	ID string
+	// UserID is the identifier of the user who created the boxset.
+	UserID string
+	// Name of the boxset.
+	Name string
+	// ItemIDs is a list of item IDs contained in the boxset, in order of addition.
+	ItemIDs []string
+	// Created is the time the boxset was created.
+	Created time.Time
+}
+
 type Person struct {
 	// ID is the unique identifier for the person.
 	ID string
@@ -161,3 +223,87 @@ type ImageMetadata struct {
 	// Updated is the time the image was last received and stored.
 	Updated time.Time
 }
+
+// MetadataCacheEntry is a cached parse result of a metadata file, e.g. an NFO file.
+type MetadataCacheEntry struct {
+	// Path is the full path of the file that was parsed.
+	Path string
+	// ModTime is the modification time of the file when it was parsed.
+	ModTime time.Time
+	// Size is the size of the file in bytes when it was parsed.
+	Size int64
+	// Data is the parse result.
+	Data []byte
+	// Updated is the last time the entry was used.
+	Updated time.Time
+}
+
+// ActivityLogEntry is a notable server event, e.g. a login or playback.
+type ActivityLogEntry struct {
+	// ID is the unique identifier of the entry.
+	ID int64
+	// Name is the description of the event, e.g. "john is playing Casablanca".
+	Name string
+	// Overview holds details of the event.
+	Overview string
+	// ShortOverview holds short details of the event, e.g. the IP address of the client.
+	ShortOverview string
+	// Type is the type of event, e.g. "AuthenticationSucceeded".
+	Type string
+	// ItemID is the ID of the item the event is about, if any.
+	ItemID string
+	// UserID is the ID of the user that triggered the event, if any.
+	UserID string
+	// Date is the time of the event.
+	Date time.Time
+	// Severity is the level of the event, e.g. "Information", "Warning" or "Error".
+	Severity string
+}
+
+// ActivityLogQuery selects activity log entries.
+type ActivityLogQuery struct {
+	// StartIndex is the number of entries to skip.
+	StartIndex int
+	// Limit is the maximum number of entries to return, 0 means no limit.
+	Limit int
+	// MinDate only selects entries at or after this time, if set.
+	MinDate time.Time
+	// HasUserID only selects entries with (true) or without (false) a user, if set.
+	HasUserID *bool
+}
+
+// PlaybackHistoryEntry is a playback of an item by a user.
+type PlaybackHistoryEntry struct {
+	// ID is the unique identifier of the entry.
+	ID int64
+	// UserID is the ID of the user that played the item.
+	UserID string
+	// ItemID is the ID of the item played.
+	ItemID string
+	// DeviceName is the name of the device the item was played on.
+	DeviceName string
+	// Client is the name of the application the item was played with.
+	Client string
+	// Started is the time playback started.
+	Started time.Time
+	// Stopped is the time playback stopped.
+	Stopped time.Time
+	// Position is the playback position in seconds when playback stopped.
+	Position int64
+}
+
+// PlaybackHistoryQuery selects playback history entries.
+type PlaybackHistoryQuery struct {
+	// UserID only selects playback of this user, if set.
+	UserID string
+	// ItemID only selects playback of this item, if set.
+	ItemID string
+	// MinDate only selects playback started at or after this time, if set.
+	MinDate time.Time
+	// MaxDate only selects playback started before this time, if set.
+	MaxDate time.Time
+	// StartIndex is the number of entries to skip.
+	StartIndex int
+	// Limit is the maximum number of entries to return, 0 means no limit.
+	Limit int
+}
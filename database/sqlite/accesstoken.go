@@ -11,15 +11,19 @@ import (
 )
 
 // GetAccessToken returns accesstoken details based upon tokenid.
+// LastUsed of the returned token is the time of the previous use of the token,
+// the current use is recorded and written to the database in the background.
 func (s *SqliteRepo) GetAccessToken(ctx context.Context, token string) (*model.AccessToken, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Try our in-memory store first
+	// Last use that might not have been written to the database yet
+	var lastUsed time.Time
 	if at, ok := s.accessTokenCache[token]; ok {
-		// Update token timestamp so we can keep track of in-use tokens
-		at.LastUsed = time.Now().UTC()
-		s.accessTokenCache[token] = at
+		lastUsed = at.LastUsed
 		// skip using this: we want to always get the latest token details from the database, as
 		// we update the last used timestamp in memory and want to make sure we have the latest value for that and other fields.
 		//  if we use the cache, we might return stale data.
@@ -53,13 +57,20 @@ func (s *SqliteRepo) GetAccessToken(ctx context.Context, token string) (*model.A
 		log.Printf("Error retrieving access token from db for token: %s: %s\n", token, err)
 		return nil, model.ErrNotFound
 	}
-	// cache it
-	t.LastUsed = time.Now().UTC()
-	s.accessTokenCache[token] = &t
+	if lastUsed.After(t.LastUsed) {
+		t.LastUsed = lastUsed
+	}
+	// cache it, update token timestamp so we can keep track of in-use tokens
+	cached := t
+	cached.LastUsed = time.Now().UTC()
+	s.accessTokenCache[token] = &cached
 	return &t, nil
 }
 
 func (s *SqliteRepo) GetAccessTokenByDeviceID(ctx context.Context, deviceID string) (*model.AccessToken, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `SELECT
 		userid,
 		token,
@@ -97,6 +108,9 @@ func (s *SqliteRepo) GetAccessTokenByDeviceID(ctx context.Context, deviceID stri
 
 // GetAccessTokens returns all access tokens for a user.
 func (s *SqliteRepo) GetAccessTokens(ctx context.Context, userID string) ([]model.AccessToken, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `SELECT
 		userid,
 		token,
@@ -128,6 +142,9 @@ func (s *SqliteRepo) GetAccessTokens(ctx context.Context, userID string) ([]mode
 
 // UpsertAccessToken upserts a token.
 func (s *SqliteRepo) UpsertAccessToken(ctx context.Context, t model.AccessToken) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -154,6 +171,9 @@ func (s *SqliteRepo) UpsertAccessToken(ctx context.Context, t model.AccessToken)
 
 // DeleteAccessToken deletes an access token from the database and cache.
 func (s *SqliteRepo) DeleteAccessToken(ctx context.Context, token string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -188,6 +208,7 @@ func (s *SqliteRepo) writeChangedAccessTokensToDB(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	syncTime := time.Now().UTC()
 	tx, err := s.dbWriteHandle.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -201,9 +222,12 @@ func (s *SqliteRepo) writeChangedAccessTokensToDB(ctx context.Context) error {
 			}
 		}
 	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	// Update sync time so we only write changed entries next time
-	s.accessTokenCacheSyncTime = time.Now().UTC()
-	return tx.Commit()
+	s.accessTokenCacheSyncTime = syncTime
+	return nil
 }
 
 // storeAccessToken stores an access token in the database
@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// activityLogMaxAge is how long activity log entries are kept.
+const activityLogMaxAge = 30 * 24 * time.Hour
+
+// AddActivityLogEntry stores an activity log entry.
+func (s *SqliteRepo) AddActivityLogEntry(ctx context.Context, entry model.ActivityLogEntry) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	if entry.Date.IsZero() {
+		entry.Date = time.Now().UTC()
+	}
+	const query = `INSERT INTO activitylog (name, overview, shortoverview, type, itemid, userid, date, severity)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.dbWriteHandle.ExecContext(ctx, query,
+		entry.Name,
+		entry.Overview,
+		entry.ShortOverview,
+		entry.Type,
+		entry.ItemID,
+		entry.UserID,
+		entry.Date,
+		entry.Severity)
+	return err
+}
+
+// GetActivityLogEntries returns activity log entries, most recent first, and the
+// total number of entries matching the query.
+func (s *SqliteRepo) GetActivityLogEntries(ctx context.Context, q model.ActivityLogQuery) ([]model.ActivityLogEntry, int, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	var where []string
+	var args []any
+	if !q.MinDate.IsZero() {
+		where = append(where, "date >= ?")
+		args = append(args, q.MinDate.UTC())
+	}
+	if q.HasUserID != nil {
+		if *q.HasUserID {
+			where = append(where, "userid != ''")
+		} else {
+			where = append(where, "userid = ''")
+		}
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.dbReadHandle.QueryRowContext(ctx, `SELECT COUNT(*) FROM activitylog`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `SELECT id, name, overview, shortoverview, type, itemid, userid, date, severity FROM activitylog` +
+		filter + ` ORDER BY date DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := s.dbReadHandle.QueryContext(ctx, query, append(args, limit, max(q.StartIndex, 0))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []model.ActivityLogEntry
+	for rows.Next() {
+		var e model.ActivityLogEntry
+		if err := rows.Scan(&e.ID, &e.Name, &e.Overview, &e.ShortOverview, &e.Type, &e.ItemID, &e.UserID, &e.Date, &e.Severity); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// activityLogBackgroundJob periodically removes old activity log entries.
+func (s *SqliteRepo) activityLogBackgroundJob(ctx context.Context, interval time.Duration) {
+	if s.dbWriteHandle == nil {
+		log.Fatal(model.ErrNoDbHandle)
+	}
+
+	const query = `DELETE FROM activitylog WHERE date < ?`
+	for {
+		if _, err := s.dbWriteHandle.ExecContext(ctx, query, time.Now().UTC().Add(-activityLogMaxAge)); err != nil {
+			log.Printf("Error purging activity log: %s\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// defaultBackupInterval is the time between database backups if not configured.
+	defaultBackupInterval = 24 * time.Hour
+	// defaultBackupCount is the number of database backups kept if not configured.
+	defaultBackupCount = 7
+	// backupPrefix and backupSuffix surround the timestamp in the filename of a backup.
+	backupPrefix = "jellofin-"
+	backupSuffix = ".db"
+)
+
+// Backup writes a consistent snapshot of the database, including play state that has
+// not been synced yet, to a new file in dir. Returns the filename of the backup.
+func (s *SqliteRepo) Backup(ctx context.Context, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+	if err := s.Flush(ctx); err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102-150405")+backupSuffix)
+	// VACUUM INTO refuses to overwrite, so write to a temporary file first
+	tmpFilename := filename + ".tmp"
+	os.Remove(tmpFilename)
+	if _, err := s.dbWriteHandle.ExecContext(ctx, `VACUUM INTO ?`, tmpFilename); err != nil {
+		os.Remove(tmpFilename)
+		return "", err
+	}
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		os.Remove(tmpFilename)
+		return "", err
+	}
+	return filename, nil
+}
+
+// rotateBackups removes the oldest backups in dir, keeping count backups.
+func rotateBackups(dir string, count int) error {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupSuffix))
+	if err != nil {
+		return err
+	}
+	if len(backups) <= count {
+		return nil
+	}
+	// The timestamp in the filename sorts in chronological order
+	slices.Sort(backups)
+	for _, backup := range backups[:len(backups)-count] {
+		if err := os.Remove(backup); err != nil {
+			return err
+		}
+		log.Printf("Removed database backup %s", backup)
+	}
+	return nil
+}
+
+// backupBackgroundJob backs up the database every interval.
+func (s *SqliteRepo) backupBackgroundJob(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		filename, err := s.Backup(ctx, s.backupDir)
+		if err != nil {
+			log.Printf("Error backing up database: %s\n", err)
+			continue
+		}
+		log.Printf("Database backed up to %s", filename)
+		if err := rotateBackups(s.backupDir, s.backupCount); err != nil {
+			log.Printf("Error removing old database backups: %s\n", err)
+		}
+	}
+}
+
+// Restore replaces the database file with a backup. The server must not be running.
+// The current database is kept next to it with suffix ".before-restore".
+func Restore(backupFilename, filename string) error {
+	if err := checkIntegrity(backupFilename); err != nil {
+		return fmt.Errorf("backup %s is not usable: %w", backupFilename, err)
+	}
+	if _, err := os.Stat(filename); err == nil {
+		if err := copyFile(filename, filename+".before-restore"); err != nil {
+			return err
+		}
+	}
+	tmpFilename := filename + ".tmp"
+	if err := copyFile(backupFilename, tmpFilename); err != nil {
+		return err
+	}
+	// Journal files of the current database do not belong to the backup
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(filename + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(tmpFilename, filename)
+}
+
+// checkIntegrity verifies a database file is a valid sqlite database.
+func checkIntegrity(filename string) error {
+	if _, err := os.Stat(filename); err != nil {
+		return err
+	}
+	db, err := sqlx.Connect("sqlite3", "file:"+filename+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if !strings.EqualFold(result, "ok") {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// copyFile copies a file, the destination is synced to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+func (s *SqliteRepo) CreateBoxSet(ctx context.Context, newBoxSet model.BoxSet) (boxSetID string, err error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	newBoxSet.ID = idhash.NewRandomID()
+
+	tx, err := s.dbWriteHandle.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.NamedExecContext(ctx, `INSERT INTO boxset (id, name, userid, timestamp)
+		VALUES (:id, :name, :userid, :timestamp)`,
+		map[string]any{
+			"id":        newBoxSet.ID,
+			"name":      newBoxSet.Name,
+			"userid":    newBoxSet.UserID,
+			"timestamp": time.Now().UTC(),
+		}); err != nil {
+		return "", err
+	}
+	if err := addItemsToBoxSet(ctx, tx, newBoxSet.ID, newBoxSet.ItemIDs); err != nil {
+		return "", err
+	}
+	return newBoxSet.ID, tx.Commit()
+}
+
+func (s *SqliteRepo) GetBoxSets(ctx context.Context) ([]model.BoxSet, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	var boxSetIDs []string
+	if err := s.dbReadHandle.SelectContext(ctx, &boxSetIDs, "SELECT id FROM boxset ORDER BY name"); err != nil {
+		return nil, err
+	}
+	boxSets := make([]model.BoxSet, 0, len(boxSetIDs))
+	for _, boxSetID := range boxSetIDs {
+		boxSet, err := s.GetBoxSet(ctx, boxSetID)
+		if err != nil {
+			return nil, err
+		}
+		boxSets = append(boxSets, *boxSet)
+	}
+	return boxSets, nil
+}
+
+func (s *SqliteRepo) GetBoxSet(ctx context.Context, boxSetID string) (*model.BoxSet, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	var boxSet struct {
+		ID        string    `db:"id"`
+		Name      string    `db:"name"`
+		UserID    string    `db:"userid"`
+		Timestamp time.Time `db:"timestamp"`
+	}
+	err := s.dbReadHandle.GetContext(ctx, &boxSet, "SELECT id, name, userid, timestamp FROM boxset WHERE id=? LIMIT 1", boxSetID)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.BoxSet{
+		ID:      boxSet.ID,
+		Name:    boxSet.Name,
+		UserID:  boxSet.UserID,
+		Created: boxSet.Timestamp,
+	}
+	if err := s.dbReadHandle.SelectContext(ctx, &result.ItemIDs,
+		"SELECT itemid FROM boxset_item WHERE boxsetid=? ORDER BY itemorder", boxSetID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *SqliteRepo) AddItemsToBoxSet(ctx context.Context, boxSetID string, itemIDs []string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.dbWriteHandle.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := addItemsToBoxSet(ctx, tx, boxSetID, itemIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addItemsToBoxSet adds items after the last item of a boxset, items already in the boxset keep their position.
+func addItemsToBoxSet(ctx context.Context, tx *sqlx.Tx, boxSetID string, itemIDs []string) error {
+	var maxOrder int
+	if err := tx.GetContext(ctx, &maxOrder,
+		"SELECT COALESCE(MAX(itemorder), 0) FROM boxset_item WHERE boxsetid=?", boxSetID); err != nil {
+		return err
+	}
+	order := maxOrder + 1
+	for _, itemID := range itemIDs {
+		if _, err := tx.NamedExecContext(ctx, `INSERT OR IGNORE INTO boxset_item (boxsetid, itemid, itemorder, timestamp)
+			VALUES (:boxsetid, :itemid, :itemorder, :timestamp)`,
+			map[string]any{
+				"boxsetid":  boxSetID,
+				"itemid":    itemID,
+				"itemorder": order,
+				"timestamp": time.Now().UTC(),
+			}); err != nil {
+			return err
+		}
+		order++
+	}
+	return nil
+}
+
+func (s *SqliteRepo) DeleteItemsFromBoxSet(ctx context.Context, boxSetID string, itemIDs []string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.dbWriteHandle.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, itemID := range itemIDs {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM boxset_item WHERE boxsetid=? AND itemid=?", boxSetID, itemID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
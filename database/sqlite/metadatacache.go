@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// metadataCacheMaxAge is how long a cache entry is kept when its file is no longer scanned.
+const metadataCacheMaxAge = 30 * 24 * time.Hour
+
+// GetMetadataCache retrieves the cached parse result of a file. The entry is only
+// returned if the file modification time and size still match.
+func (s *SqliteRepo) GetMetadataCache(ctx context.Context, path string, modTime time.Time, size int64) (*model.MetadataCacheEntry, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	const query = `SELECT data, updated FROM metadatacache WHERE path = ? AND mtime = ? AND size = ? LIMIT 1`
+	entry := model.MetadataCacheEntry{
+		Path:    path,
+		ModTime: modTime,
+		Size:    size,
+	}
+	err := s.dbReadHandle.QueryRowContext(ctx, query, path, modTime.UnixNano(), size).Scan(&entry.Data, &entry.Updated)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// UpsertMetadataCache stores the parse result of a file.
+func (s *SqliteRepo) UpsertMetadataCache(ctx context.Context, entry model.MetadataCacheEntry) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	const query = `REPLACE INTO metadatacache (path, mtime, size, data, updated) VALUES (?, ?, ?, ?, ?)`
+	_, err := s.dbWriteHandle.ExecContext(ctx, query, entry.Path, entry.ModTime.UnixNano(), entry.Size, entry.Data, entry.Updated)
+	return err
+}
+
+// metadataCacheBackgroundJob periodically removes cache entries of files that have not been scanned for a long time.
+func (s *SqliteRepo) metadataCacheBackgroundJob(ctx context.Context, interval time.Duration) {
+	if s.dbWriteHandle == nil {
+		log.Fatal(model.ErrNoDbHandle)
+	}
+
+	const query = `DELETE FROM metadatacache WHERE updated < ?`
+	for {
+		if _, err := s.dbWriteHandle.ExecContext(ctx, query, time.Now().UTC().Add(-metadataCacheMaxAge)); err != nil {
+			log.Printf("Error purging metadata cache: %s\n", err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -2,6 +2,9 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/erikbos/jellofin-server/database/model"
 )
@@ -12,14 +15,19 @@ const (
 
 // GetPersonByName retrieves a person by name.
 func (s *SqliteRepo) GetPersonByName(ctx context.Context, name, userID string) (*model.Person, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	const query = `SELECT id,
 		name,
 		date_of_birth,
 		place_of_birth,
 		profile_path,
-		biography FROM persons WHERE name=? COLLATE NOCASE LIMIT 1`
+		biography,
+		lastupdated FROM persons WHERE name=? COLLATE NOCASE LIMIT 1`
 
 	var person model.Person
+	var lastUpdated sql.NullTime
 	row := s.dbReadHandle.QueryRowContext(ctx, query, name)
 	if err := row.Scan(
 		&person.ID,
@@ -27,9 +35,43 @@ func (s *SqliteRepo) GetPersonByName(ctx context.Context, name, userID string) (
 		&person.DateOfBirth,
 		&person.PlaceOfBirth,
 		&person.PosterURL,
-		&person.Bio); err != nil {
+		&person.Bio,
+		&lastUpdated); err != nil {
 		return nil, model.ErrNotFound
 	}
-	person.PosterURL = baseImageURL + person.PosterURL
+	// Persons that could not be found are stored without profile, so they are not looked up again
+	if person.PosterURL != "" {
+		person.PosterURL = baseImageURL + person.PosterURL
+	}
+	person.LastUpdated = lastUpdated.Time
 	return &person, nil
 }
+
+// UpsertPerson stores the details of a person, an existing person with the same name is updated.
+func (s *SqliteRepo) UpsertPerson(ctx context.Context, person model.Person) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.dbWriteHandle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	profilePath := strings.TrimPrefix(person.PosterURL, baseImageURL)
+	result, err := tx.ExecContext(ctx, `UPDATE persons SET date_of_birth=?, place_of_birth=?, profile_path=?,
+		biography=?, lastupdated=? WHERE name=? COLLATE NOCASE`,
+		person.DateOfBirth, person.PlaceOfBirth, profilePath, person.Bio, time.Now().UTC(), person.Name)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+		return tx.Commit()
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO persons (id, name, date_of_birth, place_of_birth, profile_path,
+		biography, lastupdated) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		person.ID, person.Name, person.DateOfBirth, person.PlaceOfBirth, profilePath, person.Bio, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
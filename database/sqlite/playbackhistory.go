@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// AddPlaybackHistoryEntry stores a playback of an item.
+func (s *SqliteRepo) AddPlaybackHistoryEntry(ctx context.Context, entry model.PlaybackHistoryEntry) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	const query = `INSERT INTO playbackhistory (userid, itemid, devicename, client, started, stopped, position)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.dbWriteHandle.ExecContext(ctx, query,
+		entry.UserID,
+		entry.ItemID,
+		entry.DeviceName,
+		entry.Client,
+		entry.Started.UTC(),
+		entry.Stopped.UTC(),
+		entry.Position)
+	return err
+}
+
+// GetPlaybackHistory returns playback history entries, most recent first, and the
+// total number of entries matching the query.
+func (s *SqliteRepo) GetPlaybackHistory(ctx context.Context, q model.PlaybackHistoryQuery) ([]model.PlaybackHistoryEntry, int, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	var where []string
+	var args []any
+	if q.UserID != "" {
+		where = append(where, "userid = ?")
+		args = append(args, q.UserID)
+	}
+	if q.ItemID != "" {
+		where = append(where, "itemid = ?")
+		args = append(args, q.ItemID)
+	}
+	if !q.MinDate.IsZero() {
+		where = append(where, "started >= ?")
+		args = append(args, q.MinDate.UTC())
+	}
+	if !q.MaxDate.IsZero() {
+		where = append(where, "started < ?")
+		args = append(args, q.MaxDate.UTC())
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.dbReadHandle.QueryRowContext(ctx, `SELECT COUNT(*) FROM playbackhistory`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `SELECT id, userid, itemid, devicename, client, started, stopped, position FROM playbackhistory` +
+		filter + ` ORDER BY started DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := s.dbReadHandle.QueryContext(ctx, query, append(args, limit, max(q.StartIndex, 0))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []model.PlaybackHistoryEntry
+	for rows.Next() {
+		var e model.PlaybackHistoryEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ItemID, &e.DeviceName, &e.Client, &e.Started, &e.Stopped, &e.Position); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// SetItemParents registers the show and season an episode belongs to. Added or removed
+// episodes change the number of children, which makes GetPlayedCount recompute the count.
+func (s *SqliteRepo) SetItemParents(itemID string, parentIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.itemParents[itemID] = slices.Clone(parentIDs)
+}
+
+// GetPlayedCount returns the number of played children of a show or season for a user.
+// The count is recomputed in case the children of the item have changed.
+func (s *SqliteRepo) GetPlayedCount(ctx context.Context, userID, itemID string, childIDs []string) (model.PlayedCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := makeUserDataCacheKey(userID, itemID)
+	if count, ok := s.playedCounts[key]; ok && count.Children == len(childIDs) {
+		return count, nil
+	}
+
+	count := model.PlayedCount{
+		Children:  len(childIDs),
+		Timestamp: time.Now().UTC(),
+	}
+	for _, childID := range childIDs {
+		if details, ok := s.userDataEntries[makeUserDataCacheKey(userID, childID)]; ok && details.Played {
+			count.Played++
+			if details.Timestamp.After(count.LastPlayed) {
+				count.LastPlayed = details.Timestamp
+			}
+		}
+	}
+	s.playedCounts[key] = count
+	return count, nil
+}
+
+// playedCountChanges returns the played counts of the parents of an item that change
+// because of a play state update. The caller must hold the lock.
+func (s *SqliteRepo) playedCountChanges(userID, itemID string, details *model.UserData) map[userDataKey]model.PlayedCount {
+	parentIDs := s.itemParents[itemID]
+	if len(parentIDs) == 0 {
+		return nil
+	}
+	wasPlayed := false
+	if current, ok := s.userDataEntries[makeUserDataCacheKey(userID, itemID)]; ok {
+		wasPlayed = current.Played
+	}
+	if !wasPlayed && !details.Played {
+		return nil
+	}
+
+	changes := make(map[userDataKey]model.PlayedCount)
+	for _, parentID := range parentIDs {
+		key := makeUserDataCacheKey(userID, parentID)
+		// Counts are created on first use by GetPlayedCount
+		count, ok := s.playedCounts[key]
+		if !ok || count.Children == -1 {
+			continue
+		}
+		if details.Played {
+			if !wasPlayed {
+				count.Played++
+			}
+			if details.Timestamp.After(count.LastPlayed) {
+				count.LastPlayed = details.Timestamp
+			}
+		} else {
+			// The most recent play time of the remaining episodes is unknown, recompute on next use.
+			count.Children = -1
+		}
+		count.Timestamp = time.Now().UTC()
+		changes[key] = count
+	}
+	return changes
+}
+
+// loadPlayedCountsFromDB loads playedcount table into memory.
+func (s *SqliteRepo) loadPlayedCountsFromDB() error {
+	if s.dbReadHandle == nil {
+		return model.ErrNoDbHandle
+	}
+
+	var playedCounts []struct {
+		UserID     string    `db:"userid"`
+		ItemID     string    `db:"itemid"`
+		Played     int       `db:"played"`
+		Children   int       `db:"children"`
+		LastPlayed time.Time `db:"lastplayed"`
+		Timestamp  time.Time `db:"timestamp"`
+	}
+	if err := s.dbReadHandle.Select(&playedCounts, "SELECT userid, itemid, played, children, lastplayed, timestamp FROM playedcount"); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pc := range playedCounts {
+		s.playedCounts[makeUserDataCacheKey(pc.UserID, pc.ItemID)] = model.PlayedCount{
+			Played:     pc.Played,
+			Children:   pc.Children,
+			LastPlayed: pc.LastPlayed,
+			Timestamp:  pc.Timestamp,
+		}
+	}
+	return nil
+}
+
+func (s *SqliteRepo) storePlayedCount(ctx context.Context, tx *sqlx.Tx, userID, itemID string, count model.PlayedCount) error {
+	const query = `REPLACE INTO playedcount (
+		userid,
+		itemid,
+		played,
+		children,
+		lastplayed,
+		timestamp) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query,
+		userID,
+		itemID,
+		count.Played,
+		count.Children,
+		count.LastPlayed.UTC(),
+		count.Timestamp.UTC(),
+	)
+	return err
+}
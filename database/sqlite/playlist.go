@@ -10,6 +10,9 @@ import (
 )
 
 func (s *SqliteRepo) CreatePlaylist(ctx context.Context, newPlaylist model.Playlist) (playlistID string, err error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	log.Printf("CreatePlaylist: %+v", newPlaylist)
 
 	// every create playlist will have a unique id (=Jellyfin behaviour)
@@ -51,6 +54,9 @@ func (s *SqliteRepo) CreatePlaylist(ctx context.Context, newPlaylist model.Playl
 }
 
 func (s *SqliteRepo) GetPlaylists(ctx context.Context, userID string) (playlistIDs []string, err error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	var playlistIDEntries []struct {
 		ID string `db:"id"`
 	}
@@ -65,6 +71,9 @@ func (s *SqliteRepo) GetPlaylists(ctx context.Context, userID string) (playlistI
 }
 
 func (s *SqliteRepo) GetPlaylist(ctx context.Context, userID, playlistID string) (*model.Playlist, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	// log.Printf("db - GetPlaylist: %s\n", playlistID)
 
 	var playlist struct {
@@ -101,6 +110,9 @@ func (s *SqliteRepo) GetPlaylist(ctx context.Context, userID, playlistID string)
 }
 
 func (s *SqliteRepo) AddItemsToPlaylist(ctx context.Context, UserID, playlistID string, itemIDs []string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	log.Printf("AddItemsToPlaylist: %s, %s, %+v\n", UserID, playlistID, itemIDs)
 
 	tx, err := s.dbWriteHandle.Beginx()
@@ -8,12 +8,18 @@ import (
 
 // GetQuickConnectCodeBySecret retrieves a quick connect code for a user by secret string.
 func (s *SqliteRepo) GetQuickConnectCodeBySecret(ctx context.Context, secret string) (*model.QuickConnectCode, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `SELECT userid, deviceid, secret, authorized, code, created FROM quickconnect WHERE secret=? LIMIT 1`
 	return s.loadQuickConnectCode(s.dbReadHandle.QueryRowContext(ctx, query, secret))
 }
 
 // GetQuickConnectCodeByCode retrieves a quick connect code for a user by code string.
 func (s *SqliteRepo) GetQuickConnectCodeByCode(ctx context.Context, code string) (*model.QuickConnectCode, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `SELECT userid, deviceid, secret, authorized, code, created FROM quickconnect WHERE code=? LIMIT 1`
 	return s.loadQuickConnectCode(s.dbReadHandle.QueryRowContext(ctx, query, code))
 }
@@ -33,6 +39,9 @@ func (s *SqliteRepo) loadQuickConnectCode(scanner sqlScanner) (*model.QuickConne
 
 // UpsertQuickConnectCode inserts or updates a quick connect code for a user.
 func (s *SqliteRepo) UpsertQuickConnectCode(ctx context.Context, code model.QuickConnectCode) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `REPLACE INTO quickconnect (userid, deviceid, secret, authorized, code, created)
 	VALUES (?, ?, ?, ?, ?, ?)`
 
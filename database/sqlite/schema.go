@@ -74,10 +74,22 @@ playedpercentage INTEGER,
 played BOOLEAN,
 playcount INTEGER,
 favorite BOOLEAN,
-timestamp DATETIME);`,
+timestamp DATETIME,
+likes BOOLEAN,
+rating REAL);`,
 
 		`CREATE UNIQUE INDEX IF NOT EXISTS userid_itemid_idx ON playstate (userid, itemid);`,
 
+		`CREATE TABLE IF NOT EXISTS playedcount (
+userid TEXT NOT NULL,
+itemid TEXT NOT NULL,
+played INTEGER NOT NULL,
+children INTEGER NOT NULL,
+lastplayed DATETIME,
+timestamp DATETIME);`,
+
+		`CREATE UNIQUE INDEX IF NOT EXISTS playedcount_userid_itemid_idx ON playedcount (userid, itemid);`,
+
 		`CREATE TABLE IF NOT EXISTS playlist (
 id TEXT NOT NULL PRIMARY KEY,
 name TEXT NOT NULL,
@@ -92,6 +104,20 @@ timestamp DATETIME,
 PRIMARY KEY (playlistid, itemid),
 FOREIGN KEY (playlistid) REFERENCES playlists(id));`,
 
+		`CREATE TABLE IF NOT EXISTS boxset (
+id TEXT NOT NULL PRIMARY KEY,
+name TEXT NOT NULL,
+userid TEXT NOT NULL,
+timestamp DATETIME);`,
+
+		`CREATE TABLE IF NOT EXISTS boxset_item (
+boxsetid TEXT NOT NULL,
+itemid TEXT NOT NULL,
+itemorder INTEGER NOT NULL,
+timestamp DATETIME,
+PRIMARY KEY (boxsetid, itemid),
+FOREIGN KEY (boxsetid) REFERENCES boxset(id));`,
+
 		`CREATE TABLE IF NOT EXISTS images (
 itemid TEXT NOT NULL,
 type TEXT NOT NULL,
@@ -102,6 +128,54 @@ filesize INTEGER NOT NULL,
 data BLOB NOT NULL);`,
 
 		`CREATE UNIQUE INDEX IF NOT EXISTS images_idx ON images (itemid, type)`,
+
+		`CREATE TABLE IF NOT EXISTS metadatacache (
+path TEXT NOT NULL PRIMARY KEY,
+mtime INTEGER NOT NULL,
+size INTEGER NOT NULL,
+data BLOB NOT NULL,
+updated DATETIME NOT NULL);`,
+
+		`CREATE TABLE IF NOT EXISTS activitylog (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+name TEXT NOT NULL,
+overview TEXT NOT NULL,
+shortoverview TEXT NOT NULL,
+type TEXT NOT NULL,
+itemid TEXT NOT NULL,
+userid TEXT NOT NULL,
+date DATETIME NOT NULL,
+severity TEXT NOT NULL);`,
+
+		`CREATE INDEX IF NOT EXISTS activitylog_date_idx ON activitylog (date)`,
+
+		`CREATE TABLE IF NOT EXISTS playbackhistory (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+userid TEXT NOT NULL,
+itemid TEXT NOT NULL,
+devicename TEXT NOT NULL,
+client TEXT NOT NULL,
+started DATETIME NOT NULL,
+stopped DATETIME NOT NULL,
+position INTEGER NOT NULL);`,
+
+		`CREATE INDEX IF NOT EXISTS playbackhistory_user_idx ON playbackhistory (userid, started)`,
+
+		`CREATE TABLE IF NOT EXISTS persons (
+id TEXT NOT NULL PRIMARY KEY,
+name TEXT NOT NULL,
+date_of_birth DATETIME NOT NULL,
+place_of_birth TEXT NOT NULL,
+profile_path TEXT NOT NULL,
+biography TEXT NOT NULL,
+lastupdated DATETIME);`,
+
+		`CREATE INDEX IF NOT EXISTS persons_name_idx ON persons (name COLLATE NOCASE)`,
+
+		`CREATE TABLE IF NOT EXISTS settings (
+key TEXT NOT NULL PRIMARY KEY,
+value TEXT NOT NULL,
+updated DATETIME NOT NULL);`,
 	}
 
 	for _, query := range schema {
@@ -110,5 +184,30 @@ data BLOB NOT NULL);`,
 			return err
 		}
 	}
+	return dbAddColumns(d)
+}
+
+// dbAddColumns adds columns introduced after a table was created to existing databases.
+func dbAddColumns(d *sqlx.DB) error {
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"playstate", "likes", "BOOLEAN"},
+		{"playstate", "rating", "REAL"},
+		{"persons", "lastupdated", "DATETIME"},
+	}
+	for _, c := range columns {
+		var count int
+		if err := d.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := d.Exec(`ALTER TABLE ` + c.table + ` ADD COLUMN ` + c.column + ` ` + c.definition); err != nil {
+			log.Printf("dbAddColumns error: %s\n", err)
+			return err
+		}
+	}
 	return nil
 }
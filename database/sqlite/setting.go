@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// GetSetting retrieves a server setting.
+func (s *SqliteRepo) GetSetting(ctx context.Context, key string) (string, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	const query = `SELECT value FROM settings WHERE key = ? LIMIT 1`
+	var value string
+	err := s.dbReadHandle.QueryRowContext(ctx, query, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", model.ErrNotFound
+	}
+	return value, err
+}
+
+// UpsertSetting stores a server setting.
+func (s *SqliteRepo) UpsertSetting(ctx context.Context, key, value string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	const query = `REPLACE INTO settings (key, value, updated) VALUES (?, ?, ?)`
+	_, err := s.dbWriteHandle.ExecContext(ctx, query, key, value, time.Now().UTC())
+	return err
+}
@@ -22,19 +22,43 @@ type SqliteRepo struct {
 	accessTokenCache map[string]*model.AccessToken
 	// last time the access token cache was synced to the database
 	accessTokenCacheSyncTime time.Time
-	// in-memory user data store, entries are written to the database every 3 seconds.
+	// in-memory user data store, entries are written to the database every 10 seconds. Progress
+	// updates of an item in between are coalesced into a single write.
 	userDataEntries map[userDataKey]model.UserData
 	// last time the user data entries were synced to the database
 	userDataEntriesCacheSyncTime time.Time
+	// in-memory played counts of shows and seasons, written to the database together with user data.
+	playedCounts map[userDataKey]model.PlayedCount
+	// parent items (season, show) of each episode
+	itemParents map[string][]string
 	// mutex to protect access to in-memory stores
 	mu sync.Mutex
+	// maximum execution time of a query
+	queryTimeout time.Duration
+	// directory database backups are written to, empty disables backups
+	backupDir string
+	// time between backups
+	backupInterval time.Duration
+	// number of backups to keep
+	backupCount int
 }
 
 // ConfigFile holds configuration options
 type ConfigFile struct {
 	Filename string `yaml:"filename"`
+	// QueryTimeout is the maximum execution time of a query, defaults to 10 seconds.
+	QueryTimeout time.Duration `yaml:"querytimeout"`
+	// BackupDir is the directory database backups are written to, backups are disabled if not set.
+	BackupDir string `yaml:"backupdir"`
+	// BackupInterval is the time between backups, defaults to 24 hours.
+	BackupInterval time.Duration `yaml:"backupinterval"`
+	// BackupCount is the number of backups to keep, defaults to 7.
+	BackupCount int `yaml:"backupcount"`
 }
 
+// defaultQueryTimeout is the maximum execution time of a query if not configured.
+const defaultQueryTimeout = 10 * time.Second
+
 // New initializes a sqlite database and creates schema if necssary.
 func New(o *ConfigFile) (*SqliteRepo, error) {
 	if o == nil || o.Filename == "" {
@@ -62,10 +86,26 @@ func New(o *ConfigFile) (*SqliteRepo, error) {
 		dbReadHandle:     dbHandle,
 		dbWriteHandle:    writeDB,
 		userDataEntries:  make(map[userDataKey]model.UserData),
+		playedCounts:     make(map[userDataKey]model.PlayedCount),
+		itemParents:      make(map[string][]string),
 		accessTokenCache: make(map[string]*model.AccessToken),
+		queryTimeout:     o.QueryTimeout,
+		backupDir:        o.BackupDir,
+		backupInterval:   o.BackupInterval,
+		backupCount:      o.BackupCount,
+	}
+	if d.queryTimeout <= 0 {
+		d.queryTimeout = defaultQueryTimeout
+	}
+	if d.backupInterval <= 0 {
+		d.backupInterval = defaultBackupInterval
+	}
+	if d.backupCount <= 0 {
+		d.backupCount = defaultBackupCount
 	}
 
 	d.loadUserDataFromDB()
+	d.loadPlayedCountsFromDB()
 
 	return d, nil
 }
@@ -77,4 +117,24 @@ func (s *SqliteRepo) StartBackgroundJobs(ctx context.Context) {
 
 	go s.accessTokenBackgroundJob(ctx, syncInterval)
 	go s.userDataBackgroundJob(ctx, syncInterval)
+	go s.metadataCacheBackgroundJob(ctx, time.Hour)
+	go s.activityLogBackgroundJob(ctx, time.Hour)
+	if s.backupDir != "" {
+		go s.backupBackgroundJob(ctx, s.backupInterval)
+	}
+}
+
+// Flush writes the play state and access tokens that changed since the last sync to the
+// database, instead of waiting for the background jobs.
+func (s *SqliteRepo) Flush(ctx context.Context) error {
+	if err := s.writeChangedUserDataToDB(ctx); err != nil {
+		return err
+	}
+	return s.writeChangedAccessTokensToDB(ctx)
+}
+
+// queryContext returns a context that expires after the maximum query execution time,
+// the query is also cancelled in case ctx is done, e.g. because the client disconnected.
+func (s *SqliteRepo) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
 }
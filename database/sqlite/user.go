@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"log"
+	"strconv"
 	"strings"
 
 	"github.com/erikbos/jellofin-server/database/model"
@@ -10,18 +11,27 @@ import (
 
 // GetUser retrieves a user.
 func (s *SqliteRepo) GetUser(ctx context.Context, username string) (user *model.User, err error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	const query = `SELECT id, username,	password, created, lastlogin, lastused FROM users WHERE username=? LIMIT 1`
-	return s.loadUser(s.dbReadHandle.QueryRowContext(ctx, query, username))
+	return s.loadUser(ctx, s.dbReadHandle.QueryRowContext(ctx, query, username))
 }
 
 // GetByID retrieves a user from the database by their ID.
 func (s *SqliteRepo) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	query := `SELECT id, username, password, created, lastlogin, lastused FROM users WHERE id=? LIMIT 1`
-	return s.loadUser(s.dbReadHandle.QueryRowContext(ctx, query, userID))
+	return s.loadUser(ctx, s.dbReadHandle.QueryRowContext(ctx, query, userID))
 }
 
 // GetAllUsers retrieves all users from the database.
 func (s *SqliteRepo) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	const query = `SELECT id, username,	password, created, lastlogin, lastused FROM users`
 	rows, err := s.dbReadHandle.QueryContext(ctx, query)
 	if err != nil {
@@ -30,7 +40,7 @@ func (s *SqliteRepo) GetAllUsers(ctx context.Context) ([]model.User, error) {
 	defer rows.Close()
 	var users []model.User
 	for rows.Next() {
-		if user, err := s.loadUser(rows); err == nil {
+		if user, err := s.loadUser(ctx, rows); err == nil {
 			users = append(users, *user)
 		} else {
 			log.Printf("Error loading user from db: %s\n", err)
@@ -43,7 +53,7 @@ type sqlScanner interface {
 	Scan(dest ...any) error
 }
 
-func (s *SqliteRepo) loadUser(scanner sqlScanner) (*model.User, error) {
+func (s *SqliteRepo) loadUser(ctx context.Context, scanner sqlScanner) (*model.User, error) {
 	var user model.User
 	if err := scanner.Scan(
 		&user.ID,
@@ -55,12 +65,15 @@ func (s *SqliteRepo) loadUser(scanner sqlScanner) (*model.User, error) {
 		return nil, model.ErrNotFound
 	}
 	var err error
-	user.Properties, err = s.loadUserProperties(context.Background(), user.ID)
+	user.Properties, err = s.loadUserProperties(ctx, user.ID)
 	return &user, err
 }
 
 // UpsertUser upserts a user into the database.
 func (s *SqliteRepo) UpsertUser(ctx context.Context, user *model.User) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	tx, _ := s.dbWriteHandle.BeginTxx(ctx, nil)
 	defer tx.Rollback()
 
@@ -84,6 +97,9 @@ func (s *SqliteRepo) UpsertUser(ctx context.Context, user *model.User) error {
 }
 
 func (s *SqliteRepo) DeleteUser(ctx context.Context, userID string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	tx, _ := s.dbWriteHandle.BeginTxx(ctx, nil)
 	defer tx.Rollback()
 	const query = `DELETE FROM users WHERE id = ?`
@@ -96,16 +112,32 @@ func (s *SqliteRepo) DeleteUser(ctx context.Context, userID string) error {
 
 // Database keys for user properties
 const (
-	propAdmin            = "admin"
-	propDisabled         = "disabled"
-	propEnableAllFolders = "enableallfolders"
-	propEnabledFolders   = "enabledfolders"
-	propEnableDownloads  = "enabledownloads"
-	propIsHidden         = "ishidden"
-	propOrderedViews     = "orderedviews"
-	propMyMediaExcludes  = "mymediaexcludes"
-	propAllowTags        = "allowtags"
-	propBlockTags        = "blocktags"
+	propAdmin             = "admin"
+	propDisabled          = "disabled"
+	propEnableAllFolders  = "enableallfolders"
+	propEnabledFolders    = "enabledfolders"
+	propEnableDownloads   = "enabledownloads"
+	propIsHidden          = "ishidden"
+	propOrderedViews      = "orderedviews"
+	propPinnedViews       = "pinnedviews"
+	propMyMediaExcludes   = "mymediaexcludes"
+	propAllowTags         = "allowtags"
+	propBlockTags         = "blocktags"
+	propMaxParentalRating = "maxparentalrating"
+	propDisplayMissing    = "displaymissingepisodes"
+	propMinResumePct      = "minresumepct"
+	propMaxResumePct      = "maxresumepct"
+	propMinResumeDuration = "minresumeduration"
+	propMaxActiveSessions = "maxactivesessions"
+	propRemoteBitrate     = "remotebitratelimit"
+	propPlayDefaultAudio  = "playdefaultaudiotrack"
+	propAudioLanguage     = "audiolanguage"
+	propSubtitleLanguage  = "subtitlelanguage"
+	propSubtitleMode      = "subtitlemode"
+	propHiddenItems       = "hiddenitems"
+	propPin               = "pin"
+	propPinFolders        = "pinfolders"
+	propGuest             = "guest"
 )
 
 func (s *SqliteRepo) loadUserProperties(ctx context.Context, userID string) (model.UserProperties, error) {
@@ -118,9 +150,12 @@ func (s *SqliteRepo) loadUserProperties(ctx context.Context, userID string) (mod
 	// We set default values for a user here in case we do not have entries in db.
 	// jellyfin/user.go:createUser() has the same default values, so if we change defaults there, we should also change them here.
 	props := model.UserProperties{
-		IsHidden:         true,
-		EnableAllFolders: true,
-		EnableDownloads:  true,
+		IsHidden:              true,
+		EnableAllFolders:      true,
+		EnableDownloads:       true,
+		MaxParentalRating:     -1,
+		PlayDefaultAudioTrack: true,
+		SubtitleMode:          "Default",
 	}
 	for rows.Next() {
 		var key, value string
@@ -142,12 +177,46 @@ func (s *SqliteRepo) loadUserProperties(ctx context.Context, userID string) (mod
 			props.IsHidden = value == "1"
 		case propOrderedViews:
 			props.OrderedViews = splitComma(value)
+		case propPinnedViews:
+			props.PinnedViews = splitComma(value)
 		case propMyMediaExcludes:
 			props.MyMediaExcludes = splitComma(value)
 		case propAllowTags:
 			props.AllowTags = splitComma(value)
 		case propBlockTags:
 			props.BlockTags = splitComma(value)
+		case propMaxParentalRating:
+			if rating, err := strconv.Atoi(value); err == nil {
+				props.MaxParentalRating = rating
+			}
+		case propDisplayMissing:
+			props.DisplayMissingEpisodes = value == "1"
+		case propMinResumePct:
+			props.MinResumePercentage, _ = strconv.Atoi(value)
+		case propMaxResumePct:
+			props.MaxResumePercentage, _ = strconv.Atoi(value)
+		case propMinResumeDuration:
+			props.MinResumeDurationSeconds, _ = strconv.Atoi(value)
+		case propMaxActiveSessions:
+			props.MaxActiveSessions, _ = strconv.Atoi(value)
+		case propRemoteBitrate:
+			props.RemoteClientBitrateLimit, _ = strconv.Atoi(value)
+		case propPlayDefaultAudio:
+			props.PlayDefaultAudioTrack = value == "1"
+		case propAudioLanguage:
+			props.AudioLanguagePreference = value
+		case propSubtitleLanguage:
+			props.SubtitleLanguagePreference = value
+		case propSubtitleMode:
+			props.SubtitleMode = value
+		case propHiddenItems:
+			props.HiddenItems = splitComma(value)
+		case propPin:
+			props.Pin = value
+		case propPinFolders:
+			props.PinFolders = splitComma(value)
+		case propGuest:
+			props.Guest = value == "1"
 		default:
 			log.Printf("Unknown user property key: %s\n", key)
 		}
@@ -185,9 +254,25 @@ func (s *SqliteRepo) saveUserProperties(ctx context.Context, userID string, prop
 		{propEnableAllFolders, boolToString(props.EnableAllFolders)},
 		{propEnabledFolders, strings.Join(props.EnabledFolders, ",")},
 		{propOrderedViews, strings.Join(props.OrderedViews, ",")},
+		{propPinnedViews, strings.Join(props.PinnedViews, ",")},
 		{propMyMediaExcludes, strings.Join(props.MyMediaExcludes, ",")},
 		{propAllowTags, strings.Join(props.AllowTags, ",")},
 		{propBlockTags, strings.Join(props.BlockTags, ",")},
+		{propMaxParentalRating, strconv.Itoa(props.MaxParentalRating)},
+		{propDisplayMissing, boolToString(props.DisplayMissingEpisodes)},
+		{propMinResumePct, strconv.Itoa(props.MinResumePercentage)},
+		{propMaxResumePct, strconv.Itoa(props.MaxResumePercentage)},
+		{propMinResumeDuration, strconv.Itoa(props.MinResumeDurationSeconds)},
+		{propMaxActiveSessions, strconv.Itoa(props.MaxActiveSessions)},
+		{propRemoteBitrate, strconv.Itoa(props.RemoteClientBitrateLimit)},
+		{propPlayDefaultAudio, boolToString(props.PlayDefaultAudioTrack)},
+		{propAudioLanguage, props.AudioLanguagePreference},
+		{propSubtitleLanguage, props.SubtitleLanguagePreference},
+		{propSubtitleMode, props.SubtitleMode},
+		{propHiddenItems, strings.Join(props.HiddenItems, ",")},
+		{propPin, props.Pin},
+		{propPinFolders, strings.Join(props.PinFolders, ",")},
+		{propGuest, boolToString(props.Guest)},
 	}
 	for _, item := range properties {
 		// log.Printf("Saving user property for userID: %s, key: %s, value: %s\n", userID, item.key, item.value)
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"sort"
 	"time"
@@ -33,6 +34,9 @@ func (s *SqliteRepo) GetUserData(ctx context.Context, userID, itemID string) (*m
 
 // Get the play state details for an item per user.
 func (s *SqliteRepo) GetUserData2(ctx context.Context, userID, itemID string) (*model.UserData, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -41,17 +45,25 @@ func (s *SqliteRepo) GetUserData2(ctx context.Context, userID, itemID string) (*
 	playedpercentage,
 	played,
 	favorite,
+	likes,
+	COALESCE(rating, 0),
 	timestamp
 FROM playstate WHERE userid = ? AND itemid = ?`
 	row := s.dbReadHandle.QueryRowContext(ctx, query, userID, itemID)
 	var i model.UserData
+	var likes sql.NullBool
 	err := row.Scan(
 		&i.Position,
 		&i.PlayedPercentage,
 		&i.Played,
 		&i.Favorite,
+		&likes,
+		&i.Rating,
 		&i.Timestamp,
 	)
+	if likes.Valid {
+		i.Likes = &likes.Bool
+	}
 	if err != nil {
 		log.Printf("Error retrieving play state from db for userID: %s, itemID: %s: %s\n", userID, itemID, err)
 	}
@@ -67,12 +79,60 @@ func (s *SqliteRepo) UpdateUserData(ctx context.Context, userID, itemID string,
 
 	// log.Printf("SqliteRepoUpdate: userID: %s, itemID: %s, data: %+v\n", userID, itemID, details)
 
+	for k, count := range s.playedCountChanges(userID, itemID, details) {
+		s.playedCounts[k] = count
+	}
 	key := makeUserDataCacheKey(userID, itemID)
 	s.userDataEntries[key] = *details
 
 	return nil
 }
 
+// UpdateUserDataIfNewer stores the play state details for a user and item, in case
+// these are more recent than the stored details. Returns false if the stored details
+// are more recent. As the timestamp can be in the past the entry is written to the
+// database immediately instead of by the background job.
+func (s *SqliteRepo) UpdateUserDataIfNewer(ctx context.Context, userID, itemID string, details *model.UserData) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if details.Timestamp.IsZero() {
+		details.Timestamp = time.Now().UTC()
+	}
+
+	key := makeUserDataCacheKey(userID, itemID)
+	if current, ok := s.userDataEntries[key]; ok && current.Timestamp.After(details.Timestamp) {
+		return false, nil
+	}
+
+	tx, err := s.dbWriteHandle.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if err := s.storeUserData(ctx, tx, userID, itemID, *details); err != nil {
+		return false, err
+	}
+	// Played counts of season and show are updated in the same transaction
+	playedCounts := s.playedCountChanges(userID, itemID, details)
+	for k, count := range playedCounts {
+		if err := s.storePlayedCount(ctx, tx, k.userID, k.itemID, count); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	for k, count := range playedCounts {
+		s.playedCounts[k] = count
+	}
+	s.userDataEntries[key] = *details
+	return true, nil
+}
+
 // GetFavorites returns all favorite items of a user.
 func (s *SqliteRepo) GetFavorites(ctx context.Context, userID string) ([]string, error) {
 	s.mu.Lock()
@@ -87,6 +147,24 @@ func (s *SqliteRepo) GetFavorites(ctx context.Context, userID string) ([]string,
 	return favoriteItemIDs, nil
 }
 
+// GetLikes returns the items a user likes and dislikes.
+func (s *SqliteRepo) GetLikes(ctx context.Context, userID string) (likedItemIDs, dislikedItemIDs []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, state := range s.userDataEntries {
+		if key.userID != userID || state.Likes == nil {
+			continue
+		}
+		if *state.Likes {
+			likedItemIDs = append(likedItemIDs, key.itemID)
+		} else {
+			dislikedItemIDs = append(dislikedItemIDs, key.itemID)
+		}
+	}
+	return likedItemIDs, dislikedItemIDs, nil
+}
+
 // GetRecentlyWatched returns last 10 watched items that have not been fully watched.
 // If seriesID is provided, it returns all watched items.
 func (s *SqliteRepo) GetRecentlyWatched(ctx context.Context, userID string, count int, includeFullyWatched bool) ([]string, error) {
@@ -132,16 +210,18 @@ func (s *SqliteRepo) loadUserDataFromDB() error {
 	}
 
 	var UserDatas []struct {
-		UserID           string    `db:"userid"`
-		ItemID           string    `db:"itemid"`
-		Position         int64     `db:"position"`
-		PlayedPercentage int       `db:"playedpercentage"`
-		Played           bool      `db:"played"`
-		Favorite         bool      `db:"favorite"`
-		Timestamp        time.Time `db:"timestamp"`
+		UserID           string          `db:"userid"`
+		ItemID           string          `db:"itemid"`
+		Position         int64           `db:"position"`
+		PlayedPercentage int             `db:"playedpercentage"`
+		Played           bool            `db:"played"`
+		Favorite         bool            `db:"favorite"`
+		Likes            sql.NullBool    `db:"likes"`
+		Rating           sql.NullFloat64 `db:"rating"`
+		Timestamp        time.Time       `db:"timestamp"`
 	}
 
-	if err := s.dbReadHandle.Select(&UserDatas, "SELECT userid, itemid, position, playedpercentage, played, favorite, timestamp FROM playstate"); err != nil {
+	if err := s.dbReadHandle.Select(&UserDatas, "SELECT userid, itemid, position, playedpercentage, played, favorite, likes, rating, timestamp FROM playstate"); err != nil {
 		// log.Printf("Error loading play state from db: %s\n", err)
 		return err
 	}
@@ -151,13 +231,18 @@ func (s *SqliteRepo) loadUserDataFromDB() error {
 
 	for _, ps := range UserDatas {
 		key := makeUserDataCacheKey(ps.UserID, ps.ItemID)
-		s.userDataEntries[key] = model.UserData{
+		userData := model.UserData{
 			Position:         ps.Position,
 			PlayedPercentage: ps.PlayedPercentage,
 			Played:           ps.Played,
 			Favorite:         ps.Favorite,
+			Rating:           ps.Rating.Float64,
 			Timestamp:        ps.Timestamp,
 		}
+		if ps.Likes.Valid {
+			userData.Likes = &ps.Likes.Bool
+		}
+		s.userDataEntries[key] = userData
 	}
 	return nil
 }
@@ -181,6 +266,7 @@ func (s *SqliteRepo) writeChangedUserDataToDB(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	syncTime := time.Now().UTC()
 	tx, err := s.dbWriteHandle.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -194,9 +280,20 @@ func (s *SqliteRepo) writeChangedUserDataToDB(ctx context.Context) error {
 			}
 		}
 	}
-	// Update sync time so we only write changed entries next time
-	s.userDataEntriesCacheSyncTime = time.Now().UTC()
-	return tx.Commit()
+	for k, count := range s.playedCounts {
+		if count.Timestamp.After(s.userDataEntriesCacheSyncTime) {
+			if err := s.storePlayedCount(ctx, tx, k.userID, k.itemID, count); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// Update sync time so we only write changed entries next time, entries are
+	// written again next time in case the commit failed
+	s.userDataEntriesCacheSyncTime = syncTime
+	return nil
 }
 
 func (s *SqliteRepo) storeUserData(ctx context.Context, tx *sqlx.Tx, userID, itemID string, data model.UserData) error {
@@ -207,7 +304,9 @@ func (s *SqliteRepo) storeUserData(ctx context.Context, tx *sqlx.Tx, userID, ite
 		playedpercentage,
 		played,
 		favorite,
-		timestamp) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		likes,
+		rating,
+		timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := tx.ExecContext(ctx, query,
 		userID,
 		itemID,
@@ -215,6 +314,8 @@ func (s *SqliteRepo) storeUserData(ctx context.Context, tx *sqlx.Tx, userID, ite
 		data.PlayedPercentage,
 		data.Played,
 		data.Favorite,
+		data.Likes,
+		data.Rating,
 		data.Timestamp.UTC(),
 	)
 	return err
@@ -0,0 +1,141 @@
+// Package discovery lets clients on the local network find the server.
+//
+// It implements the Jellyfin UDP discovery protocol on port 7359, where clients
+// broadcast "Who is JellyfinServer?" and servers reply with their address, and
+// optionally advertises the server using mDNS.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+const (
+	// discoveryPort is the UDP port Jellyfin clients send discovery requests to.
+	discoveryPort = 7359
+	// discoveryRequest is the message clients broadcast to find servers.
+	discoveryRequest = "who is jellyfinserver?"
+)
+
+// Options holds the details of the server to advertise.
+type Options struct {
+	// ServerID is the unique ID of the server
+	ServerID string
+	// ServerName returns the name of the server, it can be changed at runtime
+	ServerName func() string
+	// Port is the port the server listens on
+	Port int
+	// TLS indicates the server is serving HTTPS
+	TLS bool
+	// MDNS enables mDNS advertisement
+	MDNS bool
+}
+
+// Discovery answers discovery requests of clients.
+type Discovery struct {
+	serverID   string
+	serverName func() string
+	port       int
+	scheme     string
+	mdns       bool
+}
+
+// discoveryResponse is the reply to a discovery request.
+type discoveryResponse struct {
+	Address         string  `json:"Address"`
+	ID              string  `json:"Id"`
+	Name            string  `json:"Name"`
+	EndpointAddress *string `json:"EndpointAddress"`
+}
+
+// New creates a Discovery.
+func New(o *Options) *Discovery {
+	d := &Discovery{
+		serverID:   o.ServerID,
+		serverName: o.ServerName,
+		port:       o.Port,
+		scheme:     "http",
+		mdns:       o.MDNS,
+	}
+	if o.TLS {
+		d.scheme = "https"
+	}
+	return d
+}
+
+// Start starts answering discovery requests in the background, until ctx is cancelled.
+func (d *Discovery) Start(ctx context.Context) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: discoveryPort})
+	if err != nil {
+		return err
+	}
+	log.Printf("Discovery listening on udp port %d", discoveryPort)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go d.serve(conn)
+
+	if d.mdns {
+		if err := d.startMDNS(ctx); err != nil {
+			log.Printf("mDNS advertisement disabled: %s", err)
+		}
+	}
+	return nil
+}
+
+// serve answers discovery requests until the connection is closed.
+func (d *Discovery) serve(conn *net.UDPConn) {
+	buf := make([]byte, 1024)
+	for {
+		n, client, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !strings.Contains(strings.ToLower(string(buf[:n])), discoveryRequest) {
+			continue
+		}
+		ip := localIP(client.IP)
+		if ip == nil {
+			continue
+		}
+		response, err := json.Marshal(discoveryResponse{
+			Address: d.address(ip),
+			ID:      d.serverID,
+			Name:    d.serverName(),
+		})
+		if err != nil {
+			continue
+		}
+		if _, err := conn.WriteToUDP(response, client); err != nil {
+			log.Printf("Discovery: cannot reply to %s: %s", client, err)
+		}
+	}
+}
+
+// address returns the URL of the server on ip.
+func (d *Discovery) address(ip net.IP) string {
+	return fmt.Sprintf("%s://%s", d.scheme, net.JoinHostPort(ip.String(), fmt.Sprint(d.port)))
+}
+
+// localIP returns the IP address of the interface used to reach a client.
+func localIP(client net.IP) net.IP {
+	// Connecting a UDP socket does not send packets, it only selects the route
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: client, Port: discoveryPort})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		return ip4
+	}
+	return addr.IP
+}
@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// mdnsService is the DNS-SD service type we advertise.
+	mdnsService = "_jellyfin._tcp.local"
+	// mdnsServices is the DNS-SD name used to enumerate all service types.
+	mdnsServices = "_services._dns-sd._udp.local"
+	// mdnsTTL is the time to live of advertised records, in seconds.
+	mdnsTTL = 120
+)
+
+// DNS record types and classes used in mDNS messages
+const (
+	dnsTypeA      = 1
+	dnsTypePTR    = 12
+	dnsTypeTXT    = 16
+	dnsTypeSRV    = 33
+	dnsClassIN    = 1
+	dnsCacheFlush = 0x8000
+)
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// startMDNS announces the server and answers mDNS queries for it, until ctx is cancelled.
+func (d *Discovery) startMDNS(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsAddr)
+	if err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	host, _, _ := strings.Cut(hostname, ".")
+	host += ".local"
+	log.Printf("mDNS advertising %s on %s", mdnsService, host)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	// Announce twice at startup, as recommended by RFC 6762
+	go func() {
+		for range 2 {
+			if _, err := conn.WriteToUDP(d.mdnsResponse(host), mdnsAddr); err != nil {
+				log.Printf("mDNS announcement failed: %s", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	go d.serveMDNS(conn, host)
+	return nil
+}
+
+// serveMDNS answers queries for our service, instance or host name.
+func (d *Discovery) serveMDNS(conn *net.UDPConn, host string) {
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		questions, err := parseDNSQuestions(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, q := range questions {
+			if strings.EqualFold(q, mdnsService) || strings.EqualFold(q, mdnsServices) ||
+				strings.EqualFold(q, d.instanceName()) || strings.EqualFold(q, host) {
+				conn.WriteToUDP(d.mdnsResponse(host), mdnsAddr)
+				break
+			}
+		}
+	}
+}
+
+// instanceName returns the DNS-SD instance name of the server.
+func (d *Discovery) instanceName() string {
+	return d.instanceLabel() + "." + mdnsService
+}
+
+// instanceLabel returns the first label of the instance name, which is the server name.
+func (d *Discovery) instanceLabel() string {
+	// Dots in the server name would be read as label separators by some clients
+	label := strings.ReplaceAll(d.serverName(), ".", " ")
+	// DNS labels are at most 63 bytes
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return label
+}
+
+// mdnsResponse returns an mDNS response advertising the server.
+func (d *Discovery) mdnsResponse(host string) []byte {
+	// Header: id 0, flags response + authoritative answer
+	msg := []byte{0, 0, 0x84, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	instance := append([]byte{byte(len(d.instanceLabel()))}, d.instanceLabel()...)
+	instance = append(instance, encodeDNSName(mdnsService)...)
+
+	msg = appendDNSRecord(msg, encodeDNSName(mdnsServices), dnsTypePTR, dnsClassIN, encodeDNSName(mdnsService))
+	msg = appendDNSRecord(msg, encodeDNSName(mdnsService), dnsTypePTR, dnsClassIN, instance)
+	srv := binary.BigEndian.AppendUint16(nil, 0) // priority
+	srv = binary.BigEndian.AppendUint16(srv, 0)  // weight
+	srv = binary.BigEndian.AppendUint16(srv, uint16(d.port))
+	srv = append(srv, encodeDNSName(host)...)
+	msg = appendDNSRecord(msg, instance, dnsTypeSRV, dnsClassIN|dnsCacheFlush, srv)
+	var txt []byte
+	for _, s := range []string{"id=" + d.serverID, "scheme=" + d.scheme, "port=" + fmt.Sprint(d.port)} {
+		txt = append(txt, byte(len(s)))
+		txt = append(txt, s...)
+	}
+	msg = appendDNSRecord(msg, instance, dnsTypeTXT, dnsClassIN|dnsCacheFlush, txt)
+	count := uint16(4)
+	for _, ip := range interfaceIPv4s() {
+		msg = appendDNSRecord(msg, encodeDNSName(host), dnsTypeA, dnsClassIN|dnsCacheFlush, ip)
+		count++
+	}
+	binary.BigEndian.PutUint16(msg[6:8], count)
+	return msg
+}
+
+// appendDNSRecord appends a resource record to a DNS message.
+func appendDNSRecord(msg, name []byte, rrtype, class uint16, rdata []byte) []byte {
+	msg = append(msg, name...)
+	msg = binary.BigEndian.AppendUint16(msg, rrtype)
+	msg = binary.BigEndian.AppendUint16(msg, class)
+	msg = binary.BigEndian.AppendUint32(msg, mdnsTTL)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	return append(msg, rdata...)
+}
+
+// encodeDNSName encodes a dot separated name as DNS labels.
+func encodeDNSName(name string) []byte {
+	var b []byte
+	for label := range strings.SplitSeq(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// parseDNSQuestions returns the names asked for in a DNS query.
+func parseDNSQuestions(msg []byte) ([]string, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("message too short")
+	}
+	// Ignore responses of other hosts
+	if msg[2]&0x80 != 0 {
+		return nil, nil
+	}
+	count := int(binary.BigEndian.Uint16(msg[4:6]))
+	offset := 12
+	var names []string
+	for range count {
+		name, next, err := parseDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		// Skip type and class
+		offset = next + 4
+		if offset > len(msg) {
+			return nil, errors.New("message too short")
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseDNSName parses a possibly compressed name at offset, and returns the offset after it.
+func parseDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errors.New("invalid name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) || jumps > 10 {
+				return "", 0, errors.New("invalid name")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errors.New("invalid name")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// interfaceIPv4s returns the IPv4 addresses of the network interfaces that are up.
+func interfaceIPv4s() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				if ip4 := ipnet.IP.To4(); ip4 != nil {
+					ips = append(ips, ip4)
+				}
+			}
+		}
+	}
+	return ips
+}
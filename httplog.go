@@ -1,12 +1,61 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Access log formats.
+const (
+	// accessLogDefault logs the remote address, request, status, length, user agent and duration in milliseconds.
+	accessLogDefault = "default"
+	// accessLogCombined logs in Apache combined log format, followed by route template and duration in milliseconds.
+	accessLogCombined = "combined"
+	// accessLogJSON logs one JSON object per request.
+	accessLogJSON = "json"
 )
 
+// defaultAccessLogExclude are the requests that are not logged if no excludes are configured.
+var defaultAccessLogExclude = []string{"/health", "/System/Ping"}
+
+// AccessLogOptions configures the access log.
+type AccessLogOptions struct {
+	// Format is default, combined or json. Empty is the same as default.
+	Format string
+	// Exclude holds glob patterns of paths and route templates that are not logged,
+	// e.g. "/Items/*/Images" or "/Sessions/Playing/Progress". A pattern also matches
+	// everything below it.
+	Exclude []string
+}
+
+// validAccessLogFormat returns an error if the access log format is not supported.
+func validAccessLogFormat(format string) error {
+	switch format {
+	case "", accessLogDefault, accessLogCombined, accessLogJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown access log format %s", format)
+}
+
+// accessLogEntry holds request details that are only known by handlers further down the chain.
+type accessLogEntry struct {
+	route string
+	user  string
+}
+
+type accessLogKey struct{}
+
 // statusWriter proxies http.ResponseWriter
 // and stores the requests status and length.
 type statusWriter struct {
@@ -29,40 +78,157 @@ func (w *statusWriter) Write(b []byte) (length int, err error) {
 	return
 }
 
+// Hijack takes over the connection, used by websocket connections.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the original ResponseWriter, used by http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // HttpLog calls ServeHTTP with a custom responsewriter that
 // stores the requests status and length so we can log it.
-func HttpLog(handle http.Handler) http.HandlerFunc {
+func HttpLog(handle http.Handler, options AccessLogOptions) http.HandlerFunc {
 	if handle == nil {
 		handle = http.DefaultServeMux
 	}
+	exclude := options.Exclude
+	if exclude == nil {
+		exclude = defaultAccessLogExclude
+	}
+	// combined and json lines carry their own timestamp
+	accessLog := log.New(log.Writer(), "", 0)
+
 	return func(w http.ResponseWriter, request *http.Request) {
 		start := time.Now()
 		writer := statusWriter{w, 0, 0}
-		handle.ServeHTTP(&writer, request)
+		entry := &accessLogEntry{}
+		handle.ServeHTTP(&writer, request.WithContext(context.WithValue(request.Context(), accessLogKey{}, entry)))
 		end := time.Now()
 		latency := end.Sub(start)
 
-		if request.URL.Path == "/health" || request.URL.Path == "/System/Ping" {
-			// Don't log health & ping requests
+		if accessLogExcluded(exclude, request.URL.Path, entry.route) {
 			return
 		}
 
-		if writer.status > 206 {
-			log.Printf("\n")
+		switch options.Format {
+		case accessLogCombined:
+			host, _, err := net.SplitHostPort(request.RemoteAddr)
+			if err != nil {
+				host = request.RemoteAddr
+			}
+			accessLog.Printf("%s - %s [%s] \"%s %s %s\" %d %d %s %s %s %d",
+				host,
+				orDash(entry.user),
+				start.Format("02/Jan/2006:15:04:05 -0700"),
+				request.Method,
+				request.URL.RequestURI(),
+				request.Proto,
+				writer.status,
+				writer.length,
+				strconv.Quote(orDash(request.Referer())),
+				strconv.Quote(request.Header.Get("User-Agent")),
+				strconv.Quote(orDash(entry.route)),
+				latency.Milliseconds())
+		case accessLogJSON:
+			line, err := json.Marshal(struct {
+				Time      time.Time `json:"time"`
+				Remote    string    `json:"remote"`
+				User      string    `json:"user,omitempty"`
+				Method    string    `json:"method"`
+				URI       string    `json:"uri"`
+				Route     string    `json:"route,omitempty"`
+				Proto     string    `json:"proto"`
+				Status    int       `json:"status"`
+				Bytes     int       `json:"bytes"`
+				Duration  float64   `json:"duration_ms"`
+				Referer   string    `json:"referer,omitempty"`
+				UserAgent string    `json:"user_agent,omitempty"`
+			}{
+				Time:      start.UTC(),
+				Remote:    request.RemoteAddr,
+				User:      entry.user,
+				Method:    request.Method,
+				URI:       request.URL.RequestURI(),
+				Route:     entry.route,
+				Proto:     request.Proto,
+				Status:    writer.status,
+				Bytes:     writer.length,
+				Duration:  float64(latency.Microseconds()) / 1000,
+				Referer:   request.Referer(),
+				UserAgent: request.Header.Get("User-Agent"),
+			})
+			if err == nil {
+				accessLog.Print(string(line))
+			}
+		default:
+			if writer.status > 206 {
+				log.Printf("\n")
+			}
+
+			log.Printf("%s \"%s %s %s\" %d %d %s %v",
+				request.RemoteAddr,
+				request.Method,
+				request.URL.String(),
+				request.Proto,
+				writer.status,
+				writer.length,
+				strconv.Quote(request.Header.Get("User-Agent")),
+				latency.Milliseconds())
+
+			if writer.status > 206 {
+				log.Printf("\n")
+			}
 		}
+	}
+}
 
-		log.Printf("%s \"%s %s %s\" %d %d %s %v",
-			request.RemoteAddr,
-			request.Method,
-			request.URL.String(),
-			request.Proto,
-			writer.status,
-			writer.length,
-			strconv.Quote(request.Header.Get("User-Agent")),
-			latency.Milliseconds())
-
-		if writer.status > 206 {
-			log.Printf("\n")
+// accessLogRoute is a router middleware that stores the path template of the matched route in the access log entry.
+func accessLogRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				entry.route, _ = route.GetPathTemplate()
+			}
 		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setAccessLogUser stores the name of the authenticated user of a request in its access log entry.
+func setAccessLogUser(r *http.Request, username string) {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.user = username
+	}
+}
+
+// accessLogExcluded returns true if one of the patterns matches the path or route template,
+// or a leading part of them. Matching is case-insensitive as clients differ in path casing.
+func accessLogExcluded(patterns []string, urlPath, route string) bool {
+	for _, pattern := range patterns {
+		for _, p := range []string{urlPath, route} {
+			for p != "" {
+				if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(p)); matched {
+					return true
+				}
+				i := strings.LastIndex(p, "/")
+				if i <= 0 {
+					break
+				}
+				p = p[:i]
+			}
+		}
+	}
+	return false
+}
+
+// orDash returns "-" for empty values, as used in combined log format.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
@@ -0,0 +1,88 @@
+package imageresize
+
+import (
+	"context"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cacheFile is a file in the resize cache.
+type cacheFile struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// CacheEvictor keeps the total size of the resize cache below maxSize bytes by
+// removing least recently used files every interval. The cache directory is kept
+// across restarts, files used before the restart are ordered by modification time.
+func (r *Resizer) CacheEvictor(ctx context.Context, maxSize int64, interval time.Duration) {
+	if r.cachedir == "" || maxSize <= 0 {
+		return
+	}
+	for {
+		r.evictCache(maxSize)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// evictCache removes least recently used files until the cache fits in maxSize bytes.
+func (r *Resizer) evictCache(maxSize int64) {
+	var files []cacheFile
+	var totalSize int64
+
+	r.cacheUsedLock.Lock()
+	cacheUsed := maps.Clone(r.cacheUsed)
+	r.cacheUsedLock.Unlock()
+
+	filepath.Walk(r.cachedir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		lastUsed := fi.ModTime()
+		if used, ok := cacheUsed[path]; ok && used.After(lastUsed) {
+			lastUsed = used
+		}
+		files = append(files, cacheFile{path: path, size: fi.Size(), lastUsed: lastUsed})
+		totalSize += fi.Size()
+		return nil
+	})
+
+	if totalSize <= maxSize {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].lastUsed.Before(files[j].lastUsed)
+	})
+
+	var removed int
+	for _, f := range files {
+		if totalSize <= maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			continue
+		}
+		totalSize -= f.size
+		removed++
+		r.cacheUsedLock.Lock()
+		delete(r.cacheUsed, f.path)
+		r.cacheUsedLock.Unlock()
+	}
+	log.Printf("Image cache: removed %d files, cache size now %d bytes", removed, totalSize)
+}
+
+// markCacheUsed records a cache file has been used.
+func (r *Resizer) markCacheUsed(path string) {
+	r.cacheUsedLock.Lock()
+	r.cacheUsed[path] = time.Now()
+	r.cacheUsedLock.Unlock()
+}
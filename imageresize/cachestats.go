@@ -0,0 +1,46 @@
+package imageresize
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// CacheStats holds how well the resize cache performs, and its disk usage.
+type CacheStats struct {
+	// Hits is the number of resized images served from the cache.
+	Hits int64
+	// Misses is the number of images that had to be resized.
+	Misses int64
+	// Files is the number of files in the cache.
+	Files int
+	// Size is the total size of the files in the cache in bytes.
+	Size int64
+}
+
+// HitRatio returns the fraction of resized images served from the cache, 0 if none were served yet.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CacheStats returns the hits and misses of the resize cache since startup, and its disk usage.
+func (r *Resizer) CacheStats() CacheStats {
+	stats := CacheStats{
+		Hits:   r.cacheHits.Load(),
+		Misses: r.cacheMisses.Load(),
+	}
+	if r.cachedir == "" {
+		return stats
+	}
+	filepath.Walk(r.cachedir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		stats.Files++
+		stats.Size += fi.Size()
+		return nil
+	})
+	return stats
+}
@@ -0,0 +1,70 @@
+package imageresize
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+// etagCacheMaxEntries is the maximum number of image hashes kept in memory.
+const etagCacheMaxEntries = 10000
+
+// ETag returns a strong ETag based upon the content of an opened image.
+// variant identifies the resized version of the image, e.g. the request query string.
+// Hashes are cached in memory as long as the file size and modification time do not change.
+func (r *Resizer) ETag(file http.File, name, variant string) (string, error) {
+	key, err := etagKey(file, name, variant)
+	if err != nil {
+		return "", err
+	}
+
+	r.etagCacheLock.Lock()
+	etag, found := r.etagCache[key]
+	r.etagCacheLock.Unlock()
+	if found {
+		return etag, nil
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag = `"` + idhash.HashBytes(data) + `"`
+
+	r.etagCacheLock.Lock()
+	if len(r.etagCache) >= etagCacheMaxEntries {
+		clear(r.etagCache)
+	}
+	r.etagCache[key] = etag
+	r.etagCacheLock.Unlock()
+
+	return etag, nil
+}
+
+// CachedETag returns the ETag of an opened image in case it has been calculated before,
+// without reading the image.
+func (r *Resizer) CachedETag(file http.File, name, variant string) (string, bool) {
+	key, err := etagKey(file, name, variant)
+	if err != nil {
+		return "", false
+	}
+
+	r.etagCacheLock.Lock()
+	defer r.etagCacheLock.Unlock()
+	etag, found := r.etagCache[key]
+	return etag, found
+}
+
+// etagKey returns the key of an image in the etag cache.
+func etagKey(file http.File, name, variant string) (string, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s?%s:%d:%d", name, variant, fi.Size(), fi.ModTime().UnixNano()), nil
+}
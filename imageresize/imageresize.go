@@ -9,10 +9,13 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/disintegration/imaging"
 )
@@ -25,6 +28,15 @@ type Resizer struct {
 	tmpExt             string
 	resizeMutexMap     map[string]*sync.Mutex
 	resizeMutexMapLock sync.Mutex
+	// cacheUsed holds the last time a cache file was used.
+	cacheUsed     map[string]time.Time
+	cacheUsedLock sync.Mutex
+	// etagCache holds content hashes of images.
+	etagCache     map[string]string
+	etagCacheLock sync.Mutex
+	// cacheHits and cacheMisses count resized images served from the cache, and resized on request.
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
 }
 
 func New(config Options) *Resizer {
@@ -32,6 +44,8 @@ func New(config Options) *Resizer {
 		cachedir:       config.Cachedir,
 		resizeMutexMap: make(map[string]*sync.Mutex),
 		tmpExt:         fmt.Sprintf(".%d", os.Getpid()),
+		cacheUsed:      make(map[string]time.Time),
+		etagCache:      make(map[string]string),
 	}
 	return r
 }
@@ -107,8 +121,18 @@ func (r *Resizer) cacheWriteInfo(file http.File, w float64, h float64) {
 	}
 }
 
+// cacheFilename returns the name of a resized image in the cache, format is empty
+// unless the image is converted to another format.
+func (r *Resizer) cacheFilename(cn string, w, h, q uint, format string) string {
+	fn := fmt.Sprintf("%s/%s:%dx%dq=%d", r.cachedir, cn, w, h, q)
+	if format != "" {
+		fn += "." + format
+	}
+	return fn
+}
+
 // see if we have the resized file in the cache.
-func (r *Resizer) cacheRead(file http.File, w, h, q uint) (rfile http.File) {
+func (r *Resizer) cacheRead(file http.File, w, h, q uint, format string) (rfile http.File) {
 	if r.cachedir == "" {
 		return
 	}
@@ -116,16 +140,17 @@ func (r *Resizer) cacheRead(file http.File, w, h, q uint) (rfile http.File) {
 	if cn == "" {
 		return
 	}
-	fn := fmt.Sprintf("%s/%s:%dx%dq=%d", r.cachedir, cn, w, h, q)
+	fn := r.cacheFilename(cn, w, h, q, format)
 	rfile, err := os.Open(fn)
 	if err != nil {
-		rfile = nil
+		return nil
 	}
+	r.markCacheUsed(fn)
 	return
 }
 
 // store resized file in the cache.
-func (r *Resizer) cacheWrite(file http.File, blob []byte, w, h, q uint) (rfile http.File) {
+func (r *Resizer) cacheWrite(file http.File, blob []byte, w, h, q uint, format string) (rfile http.File) {
 	if r.cachedir == "" {
 		return
 	}
@@ -133,7 +158,7 @@ func (r *Resizer) cacheWrite(file http.File, blob []byte, w, h, q uint) (rfile h
 	if cn == "" {
 		return
 	}
-	fn := fmt.Sprintf("%s/%s:%dx%dq=%d", r.cachedir, cn, w, h, q)
+	fn := r.cacheFilename(cn, w, h, q, format)
 	tmp := fn + r.tmpExt
 	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
 	if err != nil {
@@ -159,6 +184,34 @@ func (r *Resizer) cacheWrite(file http.File, blob []byte, w, h, q uint) (rfile h
 // then we return a handle to the resized image.
 func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string,
 	imageQuality int) (file http.File, err error) {
+	// do we want to resize, HEAD requests get the same image so Content-Length matches.
+	var params url.Values
+	if (rq.Method == http.MethodGet || rq.Method == http.MethodHead) && rq.URL.RawQuery != "" {
+		params, _ = url.ParseQuery(rq.URL.RawQuery)
+	} else {
+		imageQuality = 0
+	}
+	file, ctype, err := r.Open(name, params, imageQuality)
+	if ctype != "" {
+		rw.Header().Set("Content-Type", "image/"+ctype)
+	}
+	return
+}
+
+// Pregenerate resizes an image and stores it in the cache, so the first request
+// for the image with the same parameters is served from the cache.
+func (r *Resizer) Pregenerate(name string, params url.Values, imageQuality int) error {
+	file, _, err := r.Open(name, params, imageQuality)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Open returns a handle to an image resized according to the 'w', 'h', 'mw', 'mh'
+// and 'q' parameters, and its content type. Parameter 'f=jpg' converts png images to jpeg.
+// Files that are not an image are returned as is.
+func (r *Resizer) Open(name string, params url.Values, imageQuality int) (file http.File, ctype string, err error) {
 	file, err = os.Open(name)
 	if err != nil {
 		return
@@ -175,19 +228,17 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 	if len(s) == 0 {
 		return
 	}
-	ctype := s[1]
+	ctype = s[1]
 	if ctype == "tbn" || ctype == "jpeg" {
 		ctype = "jpg"
 	}
-	rw.Header().Set("Content-Type", "image/"+ctype)
-
-	// do we want to resize.
-	if rq.Method != "GET" || rq.URL.RawQuery == "" {
-		return
+	// convert to jpeg if requested.
+	var format string
+	if params.Get("f") == "jpg" && ctype == "png" {
+		format = "jpg"
+		ctype = "jpg"
 	}
 
-	// parse 'w', 'h', 'q' query parameters.
-	params, _ := url.ParseQuery(rq.URL.RawQuery)
 	mw := param2float(params, "mw")
 	mh := param2float(params, "mh")
 	w := param2float(params, "w")
@@ -199,7 +250,7 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 		q = float64(imageQuality)
 	}
 
-	if mw+mh+w+h+q == 0 {
+	if mw+mh+w+h+q == 0 && format == "" {
 		return
 	}
 
@@ -214,8 +265,9 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 		ch = mh
 	}
 	if cw != 0 && ch != 0 {
-		cf := r.cacheRead(file, uint(cw), uint(ch), uint(q))
+		cf := r.cacheRead(file, uint(cw), uint(ch), uint(q), format)
 		if cf != nil {
+			r.cacheHits.Add(1)
 			file.Close()
 			file = cf
 			return
@@ -226,7 +278,7 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 	if ow == 0 || oh == 0 {
 		img, _, err2 := image.Decode(file)
 		if err2 != nil {
-			return nil, err
+			return nil, ctype, err2
 		}
 		ow = float64(img.Bounds().Dx())
 		oh = float64(img.Bounds().Dy())
@@ -276,17 +328,19 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 
 	// image could be the right size and quality already.
 	need_resize := uint(ow) != uint(w) || uint(oh) != uint(h)
-	if !need_resize && q == 0 {
+	if !need_resize && q == 0 && format == "" {
 		return
 	}
 
 	// now that we have all parameters, check cache once more.
-	cf := r.cacheRead(file, uint(w), uint(h), uint(q))
+	cf := r.cacheRead(file, uint(w), uint(h), uint(q), format)
 	if cf != nil {
+		r.cacheHits.Add(1)
 		file.Close()
 		file = cf
 		return
 	}
+	r.cacheMisses.Add(1)
 
 	r.resizeMutexMapLock.Lock()
 	m, ok := r.resizeMutexMap[name]
@@ -313,8 +367,12 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 	// set quality
 	var imageblob []byte
 	if ctype == "jpg" {
+		quality := int(q)
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
 		var buf bytes.Buffer
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: int(q)})
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
 		imageblob = buf.Bytes()
 	} else if ctype == "png" {
 		var buf bytes.Buffer
@@ -329,7 +387,7 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 	f := NewBlobBytesReader(imageblob, file)
 
 	// Write cache file.
-	cachefh := r.cacheWrite(file, f.blob, uint(w), uint(h), uint(q))
+	cachefh := r.cacheWrite(file, f.blob, uint(w), uint(h), uint(q), format)
 	if cachefh != nil {
 		f.Close()
 		file.Close()
@@ -342,3 +400,23 @@ func (r *Resizer) OpenFile(rw http.ResponseWriter, rq *http.Request, name string
 	file = f
 	return
 }
+
+// Invalidate removes the cached size info and resized versions of an image, e.g. before it is replaced.
+func (r *Resizer) Invalidate(name string) {
+	if r.cachedir == "" {
+		return
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	cn := cacheName(file)
+	file.Close()
+	if cn == "" {
+		return
+	}
+	cached, _ := filepath.Glob(fmt.Sprintf("%s/%s*", r.cachedir, cn))
+	for _, fn := range cached {
+		os.Remove(fn)
+	}
+}
@@ -0,0 +1,143 @@
+package jellyfin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+const (
+	activityAuthenticationSucceeded = "AuthenticationSucceeded"
+	activityAuthenticationFailed    = "AuthenticationFailed"
+	activityVideoPlayback           = "VideoPlayback"
+	activityVideoPlaybackStopped    = "VideoPlaybackStopped"
+
+	severityInformation = "Information"
+	severityWarning     = "Warning"
+)
+
+// GET /System/ActivityLog/Entries?startIndex=0&limit=7&minDate=2025-01-01T00:00:00.000Z&hasUserId=false
+//
+// Supported query params:
+// - startIndex, number of entries to skip
+// - limit, maximum number of entries to return
+// - minDate, only return entries at or after this date
+// - hasUserId, only return entries with or without a user
+//
+// systemActivityLogEntriesHandler returns the activity log, most recent first.
+func (j *Jellyfin) systemActivityLogEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view the activity log", http.StatusForbidden)
+		return
+	}
+
+	queryparams := r.URL.Query()
+	var query model.ActivityLogQuery
+	query.StartIndex, _ = strconv.Atoi(queryparams.Get("startIndex"))
+	query.Limit, _ = strconv.Atoi(queryparams.Get("limit"))
+	if minDate := queryparams.Get("minDate"); minDate != "" {
+		t, err := parseISO8601date(minDate)
+		if err != nil {
+			apierror(w, "Invalid minDate", http.StatusBadRequest)
+			return
+		}
+		query.MinDate = t
+	}
+	if hasUserID := queryparams.Get("hasUserId"); hasUserID != "" {
+		v := hasUserID == "true"
+		query.HasUserID = &v
+	}
+
+	entries, total, err := j.repo.GetActivityLogEntries(r.Context(), query)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := JFActivityLogEntryResponse{
+		Items:            make([]JFActivityLogEntry, 0, len(entries)),
+		TotalRecordCount: total,
+		StartIndex:       query.StartIndex,
+	}
+	for _, e := range entries {
+		response.Items = append(response.Items, JFActivityLogEntry{
+			Id:            e.ID,
+			Name:          e.Name,
+			Overview:      e.Overview,
+			ShortOverview: e.ShortOverview,
+			Type:          e.Type,
+			ItemId:        e.ItemID,
+			Date:          e.Date.UTC(),
+			UserId:        e.UserID,
+			Severity:      e.Severity,
+		})
+	}
+	serveJSON(response, w)
+}
+
+// logActivity adds an entry to the activity log. The entry is stored even if the
+// request context is cancelled.
+func (j *Jellyfin) logActivity(ctx context.Context, entry model.ActivityLogEntry) {
+	entry.Date = time.Now().UTC()
+	if entry.Severity == "" {
+		entry.Severity = severityInformation
+	}
+	if err := j.repo.AddActivityLogEntry(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("Failed to add activity log entry %q: %s", entry.Name, err)
+	}
+}
+
+// logAuthentication adds a successful or failed login to the activity log.
+func (j *Jellyfin) logAuthentication(r *http.Request, username, userID string, succeeded bool) {
+	entry := model.ActivityLogEntry{
+		ShortOverview: "IP address: " + remoteIP(r),
+		UserID:        userID,
+	}
+	if succeeded {
+		entry.Name = fmt.Sprintf("%s successfully authenticated", username)
+		entry.Type = activityAuthenticationSucceeded
+	} else {
+		entry.Name = fmt.Sprintf("Login attempt failed for %s", username)
+		entry.Type = activityAuthenticationFailed
+		entry.Severity = severityWarning
+		j.notifyFailedLogin(r, username, userID)
+	}
+	j.logActivity(r.Context(), entry)
+}
+
+// logPlayback adds start or stop of playback of an item to the activity log.
+func (j *Jellyfin) logPlayback(ctx context.Context, reqCtx *requestContext, itemID string, stopped bool) {
+	_, item := j.collections.GetItemByID(trimPrefix(itemID))
+	if item == nil {
+		return
+	}
+	entry := model.ActivityLogEntry{
+		ItemID: item.ID(),
+		UserID: reqCtx.User.ID,
+	}
+	if stopped {
+		entry.Name = fmt.Sprintf("%s has finished playing %s on %s", reqCtx.User.Username, item.Name(), reqCtx.Token.DeviceName)
+		entry.Type = activityVideoPlaybackStopped
+	} else {
+		entry.Name = fmt.Sprintf("%s is playing %s on %s", reqCtx.User.Username, item.Name(), reqCtx.Token.DeviceName)
+		entry.Type = activityVideoPlayback
+	}
+	j.logActivity(ctx, entry)
+}
+
+// remoteIP returns the IP address of the client.
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
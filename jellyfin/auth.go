@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -30,6 +31,8 @@ const (
 type requestContext struct {
 	Token *model.AccessToken
 	User  *model.User
+	// Languages are the languages of the client in order of preference, e.g. "nl-BE"
+	Languages []string
 }
 
 // authSchemeValues holds parsed jellyfin authorization scheme values
@@ -66,19 +69,25 @@ func (j *Jellyfin) usersAuthenticateByNameHandler(w http.ResponseWriter, r *http
 	if err == nil {
 		// User found, verify password
 		if err = validatePassword(user.Password, request.Pw); err != nil {
+			j.logAuthentication(r, request.Username, user.ID, false)
 			apierror(w, "Invalid username/password", http.StatusUnauthorized)
 			return
 		}
 	}
 
 	// Try to auto-register user if not found and auto-register is enabled
-	if user == nil && j.autoRegister {
+	if user == nil && j.autoRegisterEnabled() {
 		user, err = j.createUser(r.Context(), request.Username, request.Pw)
 		if err != nil || user == nil {
 			apierror(w, "Failed to auto-register user", http.StatusInternalServerError)
 			return
 		}
 	}
+	if user == nil {
+		j.logAuthentication(r, request.Username, "", false)
+		apierror(w, "Invalid username/password", http.StatusUnauthorized)
+		return
+	}
 	// Update user's last login and last used time
 	user.LastLogin = time.Now().UTC()
 	user.LastUsed = time.Now().UTC()
@@ -96,7 +105,8 @@ func (j *Jellyfin) usersAuthenticateByNameHandler(w http.ResponseWriter, r *http
 
 	var token *model.AccessToken
 	existingToken, err := j.repo.GetAccessTokenByDeviceID(r.Context(), authHeader.deviceID)
-	if err == nil && existingToken != nil {
+	newDevice := err != nil || existingToken == nil
+	if !newDevice {
 		log.Printf("Existing access token found for user %s deviceID: %s, token: %s\n", user.Username, authHeader.deviceID, existingToken.Token)
 		token = existingToken
 	} else {
@@ -124,6 +134,10 @@ func (j *Jellyfin) usersAuthenticateByNameHandler(w http.ResponseWriter, r *http
 		User:        j.makeJFUser(r.Context(), user),
 	}
 	log.Printf("User %s authenticated successfully, deviceid: %s, client: %s, token: %s\n", user.Username, token.DeviceId, token.ApplicationName, token.Token)
+	j.logAuthentication(r, user.Username, user.ID, true)
+	if newDevice {
+		j.notifyNewDevice(user, token)
+	}
 	serveJSON(response, w)
 }
 
@@ -181,6 +195,8 @@ func (j *Jellyfin) usersAuthenticateWithQuickConnectHandler(w http.ResponseWrite
 		return
 	}
 	// Create access token for the user
+	existingToken, err := j.repo.GetAccessTokenByDeviceID(r.Context(), authHeader.deviceID)
+	newDevice := err != nil || existingToken == nil
 	token := &model.AccessToken{
 		Token:   rand.Text(),
 		UserID:  user.ID,
@@ -199,6 +215,10 @@ func (j *Jellyfin) usersAuthenticateWithQuickConnectHandler(w http.ResponseWrite
 		User:        j.makeJFUser(r.Context(), user),
 	}
 	log.Printf("User %s authenticated successfully with quick connect, token: %s\n", user.Username, token.Token)
+	j.logAuthentication(r, user.Username, user.ID, true)
+	if newDevice {
+		j.notifyNewDevice(user, token)
+	}
 	serveJSON(response, w)
 }
 
@@ -235,22 +255,25 @@ func updateTokenDetails(t *model.AccessToken, r *http.Request, authHeader *authS
 	return changed
 }
 
+// authSchemeParam matches a key/value pair in an authorization header, value can be quoted or not.
+var authSchemeParam = regexp.MustCompile(`(\w+)\s*=\s*(?:"([^"]*)"|([^,]*))`)
+
 // parseAuthHeader parses jellyfin-formated authorization header
+//
+// The header is read from "Authorization" as sent by Jellyfin 10.9+ clients, older clients
+// use "X-Emby-Authorization". The latter is also used when "Authorization" holds credentials
+// of another scheme, e.g. basic auth added by a reverse proxy.
 func (j *Jellyfin) parseAuthHeader(r *http.Request) (*authSchemeValues, error) {
 	errAuthHeader := errors.New("invalid or no authorization header provided")
 
 	var authHeader string
-	var found bool
-	if authHeader = r.Header.Get("authorization"); authHeader != "" {
-		found = true
-	} else {
-		// todo: remove after Jellyfin 11.12 release
-		if authHeader = r.Header.Get("x-emby-authorization"); authHeader != "" {
-			found = true
+	for _, header := range []string{"authorization", "x-emby-authorization"} {
+		if value, ok := cutAuthScheme(r.Header.Get(header)); ok {
+			authHeader = value
+			break
 		}
 	}
-	if !found ||
-		!(strings.HasPrefix(authHeader, "MediaBrowser ") || strings.HasPrefix(authHeader, "Emby ")) {
+	if authHeader == "" {
 		return nil, errAuthHeader
 	}
 
@@ -259,40 +282,46 @@ func (j *Jellyfin) parseAuthHeader(r *http.Request) (*authSchemeValues, error) {
 	// MediaBrowser Client="Jellyflix", Device="MacBookPro18,1", DeviceId="11C750BF-4CE0-54C1-89B8-075C36A97A17", Version="1.0.0", Token="ba644327ee654ef5ac7116367da81fe3"]
 	// MediaBrowser Client="JellyWatch", Device="Android", DeviceId="3a9112ee-8a68-4bbb-89dc-2d1ac008f4c7", Version="1.6.REV-90"
 	// MediaBrowser Version=1.4.1, DeviceId=iOS_11798B04-7824-46EE-B608-AB4BEB956AD2, Device=iPhone, Client=Swiftfin iOS, Token=LVLWISEHBBEKJDQJURZCCAEJCS
-
-	// Try parsing quoted format: key="value", key="value"
-	kvMatchQuoted := `(\w+)="(.*?)"`
-	reQuoted := regexp.MustCompile(kvMatchQuoted)
-	matches := reQuoted.FindAllStringSubmatch(authHeader, -1)
-
-	if len(matches) == 0 {
-		// Try parsing unquoted format: key=value, key=value
-		kvMatchUnquoted := `(\w+)=([^,]+)`
-		reUnquoted := regexp.MustCompile(kvMatchUnquoted)
-		matches = reUnquoted.FindAllStringSubmatch(authHeader, -1)
-	}
-
+	// Emby UserId="", Client="Android", Device="Pixel 7", DeviceId="a1b2c3", Version="3.4.0", Token="1c9e2b..."
 	var result authSchemeValues
-	for _, match := range matches {
-		if len(match) == 3 {
-			value := strings.TrimSpace(match[2])
-			switch match[1] {
-			case "Client":
-				result.client = value
-			case "Version":
-				result.clientVersion = value
-			case "Device":
-				result.device = value
-			case "DeviceId":
-				result.deviceID = value
-			case "Token":
-				result.token = value
-			}
+	for _, match := range authSchemeParam.FindAllStringSubmatch(authHeader, -1) {
+		value := match[2]
+		if value == "" {
+			value = strings.TrimSpace(match[3])
+		}
+		// Some clients URL-encode values, e.g. "Jellyfin%20Media%20Player"
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		switch strings.ToLower(match[1]) {
+		case "client":
+			result.client = value
+		case "version":
+			result.clientVersion = value
+		case "device":
+			result.device = value
+		case "deviceid":
+			result.deviceID = value
+		case "token":
+			result.token = value
 		}
 	}
 	return &result, nil
 }
 
+// cutAuthScheme returns the parameters of an authorization header value if it uses
+// the MediaBrowser or Emby scheme. Scheme names are case-insensitive.
+func cutAuthScheme(header string) (string, bool) {
+	scheme, params, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found {
+		return "", false
+	}
+	if !strings.EqualFold(scheme, "MediaBrowser") && !strings.EqualFold(scheme, "Emby") {
+		return "", false
+	}
+	return params, true
+}
+
 // authMiddleware validates auth token, token can be provided in various headers
 func (j *Jellyfin) authmiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -326,16 +355,27 @@ func (j *Jellyfin) authmiddleware(next http.Handler) http.Handler {
 		}
 		if !found {
 			// log.Printf("no token found in request headers: %+v", r.Header)
-			apierror(w, "no token provided", http.StatusUnauthorized)
+			unauthorized(w, "no token provided")
 			return
 		}
 
 		token, err := j.repo.GetAccessToken(r.Context(), requestToken)
 		if err != nil {
 			log.Printf("invalid access token: %s, %s", requestToken, err)
-			apierror(w, "invalid access token", http.StatusUnauthorized)
+			unauthorized(w, "invalid access token")
 			return
 		}
+		// Expire sessions that have not been used for a long time
+		if j.sessionIdleTimeout > 0 && !token.LastUsed.IsZero() && time.Since(token.LastUsed) > j.sessionIdleTimeout {
+			log.Printf("access token of user %s on device %s expired, idle since %s", token.UserID, token.DeviceName, token.LastUsed)
+			if err := j.repo.DeleteAccessToken(r.Context(), token.Token); err != nil {
+				log.Printf("failed to delete expired access token: %s", err)
+			}
+			unauthorized(w, "access token expired")
+			return
+		}
+		token.LastUsed = time.Now().UTC()
+		previousAddress := token.RemoteAddress
 		// Update token details from auth header if changed and store back to database
 		if updateTokenDetails(token, r, embyHeader) {
 			err = j.repo.UpsertAccessToken(r.Context(), *token)
@@ -346,18 +386,31 @@ func (j *Jellyfin) authmiddleware(next http.Handler) http.Handler {
 		user, err := j.repo.GetUserByID(r.Context(), token.UserID)
 		if err != nil {
 			log.Printf("Error retrieving user for access token from db for token: %s, userID: %s: %s\n", requestToken, token.UserID, err)
-			apierror(w, "invalid access token", http.StatusUnauthorized)
+			unauthorized(w, "invalid access token")
 			return
 		}
+		if previousAddress != "" && previousAddress != token.RemoteAddress {
+			j.notifyTokenReuse(user, token, previousAddress)
+		}
+		if j.requestUser != nil {
+			j.requestUser(r, user.Username)
+		}
 		requestCtx := &requestContext{
-			Token: token,
-			User:  user,
+			Token:     token,
+			User:      user,
+			Languages: requestLanguages(r, user),
 		}
 		ctx := context.WithValue(r.Context(), requestContextKey, requestCtx)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// unauthorized writes a 401 response with a WWW-Authenticate challenge, so clients know to authenticate again.
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `MediaBrowser realm="Jellyfin"`)
+	apierror(w, msg, http.StatusUnauthorized)
+}
+
 // getRequestCtx returns access token and user details from the request context populated by authmiddleware()
 //
 // if not found sends an HTTP unauthorized error
@@ -366,6 +419,6 @@ func (j *Jellyfin) getRequestCtx(w http.ResponseWriter, r *http.Request) *reques
 	if details, ok := r.Context().Value(requestContextKey).(*requestContext); ok {
 		return details
 	}
-	apierror(w, "access token not found", http.StatusUnauthorized)
+	unauthorized(w, "access token not found")
 	return nil
 }
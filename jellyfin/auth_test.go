@@ -0,0 +1,255 @@
+package jellyfin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/erikbos/jellofin-server/database"
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/database/sqlite"
+)
+
+func TestCutAuthScheme(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+		wantOk bool
+	}{
+		{"mediabrowser", `MediaBrowser Client="Infuse-Direct"`, `Client="Infuse-Direct"`, true},
+		{"emby", `Emby Client="Android"`, `Client="Android"`, true},
+		{"scheme case-insensitive", `mediabrowser Token="abc"`, `Token="abc"`, true},
+		{"emby case-insensitive", `EMBY Token="abc"`, `Token="abc"`, true},
+		{"surrounding whitespace", `  MediaBrowser Token="abc"  `, `Token="abc"`, true},
+		{"basic auth", `Basic YWxhZGRpbjpvcGVuc2VzYW1l`, "", false},
+		{"bearer", `Bearer abc`, "", false},
+		{"scheme only", `MediaBrowser`, "", false},
+		{"empty", ``, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cutAuthScheme(tt.header)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("cutAuthScheme(%q) = %q, %t, want %q, %t", tt.header, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestParseAuthHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    authSchemeValues
+		wantErr bool
+	}{
+		{
+			name: "quoted values",
+			headers: map[string]string{
+				"Authorization": `MediaBrowser Client="Jellyfin Web", Device="Firefox", DeviceId="TW96aWxsYQ11", Version="10.10.3", Token="aea78abca5744378"`,
+			},
+			want: authSchemeValues{client: "Jellyfin Web", device: "Firefox", deviceID: "TW96aWxsYQ11", clientVersion: "10.10.3", token: "aea78abca5744378"},
+		},
+		{
+			name: "unquoted values",
+			headers: map[string]string{
+				"Authorization": `MediaBrowser Version=1.4.1, DeviceId=iOS_11798B04, Device=iPhone, Client=Swiftfin iOS, Token=LVLWISEHBBEKJDQJ`,
+			},
+			want: authSchemeValues{client: "Swiftfin iOS", device: "iPhone", deviceID: "iOS_11798B04", clientVersion: "1.4.1", token: "LVLWISEHBBEKJDQJ"},
+		},
+		{
+			name: "case-insensitive keys",
+			headers: map[string]string{
+				"Authorization": `MediaBrowser client="Infuse-Direct", DEVICE="Apple TV", deviceid="6A3F2C1E", VERSION="8.0.9", token="826c2aa3"`,
+			},
+			want: authSchemeValues{client: "Infuse-Direct", device: "Apple TV", deviceID: "6A3F2C1E", clientVersion: "8.0.9", token: "826c2aa3"},
+		},
+		{
+			name: "emby scheme",
+			headers: map[string]string{
+				"Authorization": `Emby UserId="", Client="Android", Device="Pixel 7", DeviceId="a1b2c3", Version="3.4.0", Token="1c9e2b"`,
+			},
+			want: authSchemeValues{client: "Android", device: "Pixel 7", deviceID: "a1b2c3", clientVersion: "3.4.0", token: "1c9e2b"},
+		},
+		{
+			name: "percent-encoded device name",
+			headers: map[string]string{
+				"Authorization": `MediaBrowser Client="Jellyfin%20Media%20Player", Device="Erik%27s%20MacBook", DeviceId="0dabe147", Version="1.11.1"`,
+			},
+			want: authSchemeValues{client: "Jellyfin Media Player", device: "Erik's MacBook", deviceID: "0dabe147", clientVersion: "1.11.1"},
+		},
+		{
+			name: "invalid percent-encoding is kept",
+			headers: map[string]string{
+				"Authorization": `MediaBrowser Client="Findroid", Device="100%", DeviceId="d1"`,
+			},
+			want: authSchemeValues{client: "Findroid", device: "100%", deviceID: "d1"},
+		},
+		{
+			name: "legacy header",
+			headers: map[string]string{
+				"X-Emby-Authorization": `MediaBrowser Client="Infuse-Direct", Device="Mac", DeviceId="d2", Version="8.0.9", Token="826c2aa3"`,
+			},
+			want: authSchemeValues{client: "Infuse-Direct", device: "Mac", deviceID: "d2", clientVersion: "8.0.9", token: "826c2aa3"},
+		},
+		{
+			name: "legacy header behind basic auth",
+			headers: map[string]string{
+				"Authorization":        `Basic YWxhZGRpbjpvcGVuc2VzYW1l`,
+				"X-Emby-Authorization": `MediaBrowser Client="Infuse-Direct", DeviceId="d3", Token="826c2aa3"`,
+			},
+			want: authSchemeValues{client: "Infuse-Direct", deviceID: "d3", token: "826c2aa3"},
+		},
+		{
+			name: "authorization header preferred",
+			headers: map[string]string{
+				"Authorization":        `MediaBrowser Client="Jellyfin Web", Token="new"`,
+				"X-Emby-Authorization": `MediaBrowser Client="Jellyfin Web", Token="old"`,
+			},
+			want: authSchemeValues{client: "Jellyfin Web", token: "new"},
+		},
+		{
+			name:    "other scheme",
+			headers: map[string]string{"Authorization": `Bearer abc`},
+			wantErr: true,
+		},
+		{
+			name:    "no header",
+			wantErr: true,
+		},
+	}
+	j := &Jellyfin{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/Users/Me", nil)
+			for header, value := range tt.headers {
+				r.Header.Set(header, value)
+			}
+			got, err := j.parseAuthHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseAuthHeader() = %+v, want error", *got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAuthHeader() error: %s", err)
+			}
+			if *got != tt.want {
+				t.Errorf("parseAuthHeader() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareToken(t *testing.T) {
+	repo, err := database.New("sqlite", sqlite.ConfigFile{
+		Filename: filepath.Join(t.TempDir(), "jellofin.db"),
+	})
+	if err != nil {
+		t.Fatalf("database.New: %s", err)
+	}
+	user := &model.User{ID: "u1", Username: "viewer"}
+	if err := repo.UpsertUser(context.Background(), user); err != nil {
+		t.Fatalf("UpsertUser: %s", err)
+	}
+	token := model.AccessToken{UserID: user.ID, Token: "validtoken", DeviceId: "d1"}
+	if err := repo.UpsertAccessToken(context.Background(), token); err != nil {
+		t.Fatalf("UpsertAccessToken: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		want    int
+	}{
+		{
+			name:    "authorization header",
+			url:     "/Users/Me",
+			headers: map[string]string{"Authorization": `MediaBrowser Client="Jellyfin Web", DeviceId="d1", Token="validtoken"`},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "emby authorization header",
+			url:     "/Users/Me",
+			headers: map[string]string{"Authorization": `Emby Client="Android", DeviceId="d1", Token="validtoken"`},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "x-emby-token",
+			url:     "/Users/Me",
+			headers: map[string]string{"X-Emby-Token": "validtoken"},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "x-mediabrowser-token",
+			url:     "/Users/Me",
+			headers: map[string]string{"X-MediaBrowser-Token": "validtoken"},
+			want:    http.StatusOK,
+		},
+		{
+			name: "api_key",
+			url:  "/Videos/1/stream?static=true&api_key=validtoken",
+			want: http.StatusOK,
+		},
+		{
+			name: "apiKey",
+			url:  "/Videos/1/stream?static=true&apiKey=validtoken",
+			want: http.StatusOK,
+		},
+		{
+			name:    "api_key overrides header",
+			url:     "/Users/Me?api_key=validtoken",
+			headers: map[string]string{"X-Emby-Token": "othertoken"},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "unknown token",
+			url:     "/Users/Me",
+			headers: map[string]string{"X-Emby-Token": "othertoken"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "authorization header without token",
+			url:     "/Users/Me",
+			headers: map[string]string{"Authorization": `MediaBrowser Client="Jellyfin Web", DeviceId="d1"`},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "no token",
+			url:  "/Users/Me",
+			want: http.StatusUnauthorized,
+		},
+	}
+	j := &Jellyfin{repo: repo}
+	handler := j.authmiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := j.getRequestCtx(w, r)
+		if reqCtx == nil {
+			return
+		}
+		if reqCtx.User.ID != user.ID {
+			t.Errorf("request of user %s, want %s", reqCtx.User.ID, user.ID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			for header, value := range tt.headers {
+				r.Header.Set(header, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("401 response without WWW-Authenticate challenge")
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package jellyfin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+const (
+	// boxSetCollageWidth and boxSetCollageHeight are the dimensions of a generated boxset poster.
+	boxSetCollageWidth  = 600
+	boxSetCollageHeight = 900
+)
+
+// POST /Collections
+//
+// Supported query params:
+// - name, name of the boxset
+// - ids, comma separated list of item ids to add
+//
+// createBoxSetHandler creates a boxset, a collection of items shared by all users.
+func (j *Jellyfin) createBoxSetHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	queryparams := r.URL.Query()
+	name := strings.TrimSpace(queryparams.Get("name"))
+	if name == "" {
+		apierror(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	newBoxSet := model.BoxSet{
+		Name:    name,
+		UserID:  reqCtx.User.ID,
+		ItemIDs: boxSetItemIDs(queryparams.Get("ids")),
+	}
+	boxSetID, err := j.repo.CreateBoxSet(r.Context(), newBoxSet)
+	if err != nil {
+		log.Printf("Failed to create boxset: %s", err)
+		apierror(w, "Failed to create collection", http.StatusInternalServerError)
+		return
+	}
+	j.updateBoxSetCollage(r.Context(), boxSetID)
+	serveJSON(&JFCreateBoxSetResponse{
+		ID: makeJFBoxSetID(boxSetID),
+	}, w)
+}
+
+// POST /Collections/{collectionid}/Items
+//
+// Supported query params:
+// - ids, comma separated list of item ids to add
+//
+// addBoxSetItemsHandler adds items to a boxset
+func (j *Jellyfin) addBoxSetItemsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	boxSetID := trimPrefix(mux.Vars(r)["collectionid"])
+	if !j.boxSetEditAllowed(w, r, reqCtx.User, boxSetID) {
+		return
+	}
+	if err := j.repo.AddItemsToBoxSet(r.Context(), boxSetID, boxSetItemIDs(r.URL.Query().Get("ids"))); err != nil {
+		log.Printf("Failed to add items to boxset %s: %s", boxSetID, err)
+		apierror(w, "Failed to add items", http.StatusInternalServerError)
+		return
+	}
+	j.updateBoxSetCollage(r.Context(), boxSetID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /Collections/{collectionid}/Items
+//
+// Supported query params:
+// - ids, comma separated list of item ids to remove
+//
+// deleteBoxSetItemsHandler removes items from a boxset
+func (j *Jellyfin) deleteBoxSetItemsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	boxSetID := trimPrefix(mux.Vars(r)["collectionid"])
+	if !j.boxSetEditAllowed(w, r, reqCtx.User, boxSetID) {
+		return
+	}
+	if err := j.repo.DeleteItemsFromBoxSet(r.Context(), boxSetID, boxSetItemIDs(r.URL.Query().Get("ids"))); err != nil {
+		log.Printf("Failed to remove items from boxset %s: %s", boxSetID, err)
+		apierror(w, "Failed to remove items", http.StatusInternalServerError)
+		return
+	}
+	j.updateBoxSetCollage(r.Context(), boxSetID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// boxSetEditAllowed checks the boxset exists and can be changed by the user, which is
+// only allowed for its creator and administrators. Writes an error response if not.
+func (j *Jellyfin) boxSetEditAllowed(w http.ResponseWriter, r *http.Request, user *model.User, boxSetID string) bool {
+	boxSet, err := j.repo.GetBoxSet(r.Context(), boxSetID)
+	if err != nil {
+		apierror(w, "Collection not found", http.StatusNotFound)
+		return false
+	}
+	if boxSet.UserID != user.ID && !user.Properties.Admin {
+		apierror(w, "Only the creator of a collection or administrators can change it", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// boxSetItemIDs returns the item ids of a comma separated list.
+func boxSetItemIDs(ids string) []string {
+	var itemIDs []string
+	for id := range strings.SplitSeq(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			itemIDs = append(itemIDs, trimPrefix(id))
+		}
+	}
+	return itemIDs
+}
+
+// updateBoxSetCollage generates the poster of a boxset from the posters of its first items.
+func (j *Jellyfin) updateBoxSetCollage(ctx context.Context, boxSetID string) {
+	boxSet, err := j.repo.GetBoxSet(ctx, boxSetID)
+	if err != nil {
+		return
+	}
+	var posters []image.Image
+	for _, itemID := range boxSet.ItemIDs {
+		c, i := j.collections.GetItemByID(itemID)
+		if i == nil || i.Poster() == "" {
+			continue
+		}
+		poster, err := imaging.Open(c.ItemDirectory(i) + "/" + i.Poster())
+		if err != nil {
+			continue
+		}
+		posters = append(posters, poster)
+		if len(posters) == 4 {
+			break
+		}
+	}
+	id := makeJFBoxSetID(boxSetID)
+	if len(posters) == 0 {
+		if err := j.repo.DeleteImage(ctx, id, imageTypePrimary); err != nil {
+			log.Printf("Failed to delete poster of boxset %s: %s", boxSetID, err)
+		}
+		return
+	}
+
+	// A single poster is used as is, otherwise posters are placed in a 2x2 grid
+	collage := imaging.New(boxSetCollageWidth, boxSetCollageHeight, color.Black)
+	if len(posters) == 1 {
+		collage = imaging.Fill(posters[0], boxSetCollageWidth, boxSetCollageHeight, imaging.Center, imaging.Lanczos)
+	} else {
+		w, h := boxSetCollageWidth/2, boxSetCollageHeight/2
+		for n := range 4 {
+			cell := imaging.Fill(posters[n%len(posters)], w, h, imaging.Center, imaging.Lanczos)
+			collage = imaging.Paste(collage, cell, image.Pt((n%2)*w, (n/2)*h))
+		}
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, collage, imaging.JPEG, imaging.JPEGQuality(defaultImageQuality)); err != nil {
+		log.Printf("Failed to encode poster of boxset %s: %s", boxSetID, err)
+		return
+	}
+	metadata := model.ImageMetadata{
+		MimeType: "image/jpeg",
+		FileSize: buf.Len(),
+		Etag:     idhash.HashBytes(buf.Bytes()),
+		Updated:  time.Now().UTC(),
+	}
+	if err := j.repo.StoreImage(ctx, id, imageTypePrimary, metadata, buf.Bytes()); err != nil {
+		log.Printf("Failed to store poster of boxset %s: %s", boxSetID, err)
+	}
+}
+
+// makeJFItemCollectionBoxSets creates a top level collection item with items for each boxset.
+// Returns an error if there are no boxsets, so the collection is only shown once used.
+func (j *Jellyfin) makeJFItemCollectionBoxSets(ctx context.Context) (JFItem, error) {
+	boxSets, err := j.repo.GetBoxSets(ctx)
+	if err != nil || len(boxSets) == 0 {
+		return JFItem{}, errors.New("no boxsets")
+	}
+
+	id := makeJFCollectionBoxSetID(boxSetCollectionID)
+	response := JFItem{
+		Name:                     "Collections",
+		ServerID:                 j.serverID,
+		ID:                       id,
+		ParentID:                 makeJFRootID(collectionRootID),
+		Etag:                     idhash.Hash(boxSetCollectionID),
+		CollectionType:           collectionTypeBoxSets,
+		SortName:                 collectionTypeBoxSets,
+		Type:                     itemTypeUserView,
+		IsFolder:                 true,
+		EnableMediaSourceDisplay: true,
+		ChildCount:               len(boxSets),
+		DisplayPreferencesID:     makeJFDisplayPreferencesID(boxSetCollectionID),
+		ExternalUrls:             []JFExternalUrls{},
+		PlayAccess:               "Full",
+		PrimaryImageAspectRatio:  1.7777777777777777,
+		RemoteTrailers:           []JFRemoteTrailers{},
+		LocationType:             "FileSystem",
+		Path:                     "/collection",
+		LockData:                 false,
+		MediaType:                "Unknown",
+		CanDelete:                false,
+		CanDownload:              true,
+		SpecialFeatureCount:      0,
+		ImageTags:                j.makeJFImageTags(ctx, id, imageTypePrimary),
+	}
+	return response, nil
+}
+
+// makeJFItemBoxSet creates a boxset item from the provided boxSetID
+func (j *Jellyfin) makeJFItemBoxSet(ctx context.Context, boxSetID string) (JFItem, error) {
+	boxSet, err := j.repo.GetBoxSet(ctx, boxSetID)
+	if err != nil {
+		return JFItem{}, errors.New("could not find collection")
+	}
+
+	id := makeJFBoxSetID(boxSet.ID)
+	response := JFItem{
+		Type:                     itemTypeBoxSet,
+		ID:                       id,
+		ParentID:                 makeJFCollectionBoxSetID(boxSetCollectionID),
+		ServerID:                 j.serverID,
+		Name:                     boxSet.Name,
+		SortName:                 strings.ToLower(boxSet.Name),
+		IsFolder:                 true,
+		Path:                     "/boxset",
+		Etag:                     idhash.Hash(boxSet.ID + strings.Join(boxSet.ItemIDs, ",")),
+		DateCreated:              boxSet.Created.UTC(),
+		CanDelete:                false,
+		CanDownload:              false,
+		PlayAccess:               "Full",
+		RecursiveItemCount:       len(boxSet.ItemIDs),
+		ChildCount:               len(boxSet.ItemIDs),
+		LocationType:             "FileSystem",
+		MediaType:                "Unknown",
+		CollectionType:           collectionTypeBoxSets,
+		DisplayPreferencesID:     makeJFDisplayPreferencesID(boxSetCollectionID),
+		EnableMediaSourceDisplay: true,
+		PrimaryImageAspectRatio:  float64(boxSetCollageWidth) / float64(boxSetCollageHeight),
+		ImageTags:                j.makeJFImageTags(ctx, id, imageTypePrimary),
+	}
+	return response, nil
+}
+
+// makeJFItemBoxSetOverview creates a list of all boxsets.
+func (j *Jellyfin) makeJFItemBoxSetOverview(ctx context.Context) ([]JFItem, error) {
+	boxSets, err := j.repo.GetBoxSets(ctx)
+	if err != nil {
+		return []JFItem{}, err
+	}
+	items := []JFItem{}
+	for _, boxSet := range boxSets {
+		if item, err := j.makeJFItemBoxSet(ctx, boxSet.ID); err == nil {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// makeJFItemBoxSetItemList creates the list of items of one boxset.
+func (j *Jellyfin) makeJFItemBoxSetItemList(ctx context.Context, userID, boxSetID string) ([]JFItem, error) {
+	boxSet, err := j.repo.GetBoxSet(ctx, boxSetID)
+	if err != nil {
+		return []JFItem{}, err
+	}
+	items := []JFItem{}
+	for _, itemID := range boxSet.ItemIDs {
+		c, i := j.collections.GetItemByID(itemID)
+		if i == nil {
+			continue
+		}
+		item, err := j.makeJFItem(ctx, userID, i, c.ID)
+		if err != nil {
+			return []JFItem{}, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// makeJFBoxSetID returns an external id for a boxset.
+func makeJFBoxSetID(boxSetID string) string {
+	return itemprefix_boxset + boxSetID
+}
+
+// isJFBoxSetID checks if the provided ID is a boxset ID.
+func isJFBoxSetID(id string) bool {
+	return strings.HasPrefix(id, itemprefix_boxset)
+}
+
+// makeJFCollectionBoxSetID returns an external id for the boxset collection.
+func makeJFCollectionBoxSetID(boxSetCollectionID string) string {
+	return itemprefix_collection_boxset + boxSetCollectionID
+}
+
+// isJFCollectionBoxSetID checks if the provided ID is the boxset collection ID.
+func isJFCollectionBoxSetID(id string) bool {
+	// There is only one boxset collection id, so we can do a direct comparison
+	return id == makeJFCollectionBoxSetID(boxSetCollectionID)
+}
@@ -1,21 +1,69 @@
 package jellyfin
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"os"
 )
 
+// Branding holds the branding of the login screen of web clients.
+type Branding struct {
+	// LoginDisclaimer is the text shown below the login form, can contain HTML
+	LoginDisclaimer string
+	// CustomCss is CSS applied by web clients
+	CustomCss string
+	// Splashscreen is the path to the image shown while web clients are loading
+	Splashscreen string
+}
+
 // /Branding/Configuration
+//
+// brandingConfigurationHandler returns the branding of the login screen.
 func (j *Jellyfin) brandingConfigurationHandler(w http.ResponseWriter, r *http.Request) {
-	response := JFBrandingConfigurationResponse{
-		SplashscreenEnabled: false,
-	}
-	serveJSON(response, w)
+	serveJSON(j.brandingConfiguration(r.Context()), w)
 }
 
 // /Branding/Css
 // /Branding/Css.css
+//
+// brandingCssHandler returns the custom CSS of web clients.
 func (j *Jellyfin) brandingCssHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "text/css; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(j.brandingConfiguration(r.Context()).CustomCss))
+}
+
+// /Branding/Splashscreen
+//
+// brandingSplashscreenHandler returns the splash screen image.
+func (j *Jellyfin) brandingSplashscreenHandler(w http.ResponseWriter, r *http.Request) {
+	if !j.brandingConfiguration(r.Context()).SplashscreenEnabled {
+		apierror(w, "Splashscreen not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("cache-control", "max-age=3600")
+	http.ServeFile(w, r, j.branding.Splashscreen)
+}
+
+// brandingConfiguration returns the branding from the config file, overridden by
+// branding saved in the admin dashboard.
+func (j *Jellyfin) brandingConfiguration(ctx context.Context) JFBrandingConfigurationResponse {
+	config := JFBrandingConfigurationResponse{
+		LoginDisclaimer:     j.branding.LoginDisclaimer,
+		CustomCss:           j.branding.CustomCss,
+		SplashscreenEnabled: j.branding.Splashscreen != "",
+	}
+	if stored, err := j.repo.GetSetting(ctx, serverConfigurationKey("branding")); err == nil {
+		json.Unmarshal([]byte(stored), &config)
+	}
+	// We can only show a splashscreen if we have an image
+	if j.branding.Splashscreen == "" {
+		config.SplashscreenEnabled = false
+	} else if _, err := os.Stat(j.branding.Splashscreen); err != nil {
+		config.SplashscreenEnabled = false
+	}
+	return config
 }
 
 // /Localization/Countries
@@ -0,0 +1,26 @@
+package jellyfin
+
+import (
+	"net/http"
+)
+
+// /Channels
+// /Channels/Items/Latest
+// /Channels/{channelid}/Items
+//
+// channelsHandler returns an empty list, channels provided by plugins are not supported.
+func (j *Jellyfin) channelsHandler(w http.ResponseWriter, r *http.Request) {
+	response := UserItemsResponse{
+		Items:            []JFItem{},
+		StartIndex:       0,
+		TotalRecordCount: 0,
+	}
+	serveJSON(response, w)
+}
+
+// /Channels/Features
+//
+// channelsFeaturesHandler returns the features of all channels, as there are no channels the list is empty.
+func (j *Jellyfin) channelsFeaturesHandler(w http.ResponseWriter, r *http.Request) {
+	serveJSON([]string{}, w)
+}
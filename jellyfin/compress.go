@@ -0,0 +1,267 @@
+package jellyfin
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultCompressionMinSize is the minimum size of a response in bytes to compress it.
+const defaultCompressionMinSize = 1024
+
+// responseEncoder is a content encoding API responses can be compressed with.
+type responseEncoder struct {
+	name string
+	// minLevel, maxLevel and defaultLevel are the compression levels of the encoding
+	minLevel     int
+	maxLevel     int
+	defaultLevel int
+	// newWriter returns a writer compressing to w at the provided level.
+	newWriter func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// responseEncoders are the supported content encodings, in order of preference
+// in case a client accepts several of them equally.
+var responseEncoders = []responseEncoder{
+	{
+		name:         "zstd",
+		minLevel:     1,
+		maxLevel:     22,
+		defaultLevel: 3,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+		},
+	},
+	{
+		name:         "br",
+		minLevel:     brotli.BestSpeed,
+		maxLevel:     brotli.BestCompression,
+		defaultLevel: 4,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return brotli.NewWriterLevel(w, level), nil
+		},
+	},
+	{
+		name:         "gzip",
+		minLevel:     gzip.BestSpeed,
+		maxLevel:     gzip.BestCompression,
+		defaultLevel: 6,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	},
+	{
+		name:         "deflate",
+		minLevel:     zlib.BestSpeed,
+		maxLevel:     zlib.BestCompression,
+		defaultLevel: 6,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	},
+}
+
+// compressionLevels returns the compression level of each content encoding. level applies to
+// gzip and deflate, levels sets it per encoding, e.g. "br": 5. Levels out of range of an encoding
+// are ignored, 0 leaves the level of gzip and deflate at their default.
+func compressionLevels(level int, levels map[string]int) map[string]int {
+	result := make(map[string]int, len(responseEncoders))
+	for _, e := range responseEncoders {
+		l := e.defaultLevel
+		if (e.name == "gzip" || e.name == "deflate") && level != 0 {
+			l = level
+		}
+		if configured, found := levels[e.name]; found {
+			l = configured
+		}
+		if l < e.minLevel || l > e.maxLevel {
+			log.Printf("Ignoring compression level %d of %s, valid levels are %d to %d", l, e.name, e.minLevel, e.maxLevel)
+			l = e.defaultLevel
+		}
+		result[e.name] = l
+	}
+	return result
+}
+
+// compressHandler compresses responses using the content encoding preferred by the client.
+// Responses smaller than the minimum size, and images, video and audio are sent as is.
+func (j *Jellyfin) compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoder := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoder == nil || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			encoder:        encoder,
+			level:          j.compressionLevels[encoder.name],
+			minSize:        j.compressionMinSize,
+		}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality value in an
+// Accept-Encoding header, nil if the response should not be compressed.
+func negotiateEncoding(acceptEncoding string) *responseEncoder {
+	if acceptEncoding == "" {
+		return nil
+	}
+	qualities := make(map[string]float64)
+	for entry := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[name] = q
+	}
+
+	var best *responseEncoder
+	var bestQ float64
+	for i, e := range responseEncoders {
+		q, found := qualities[e.name]
+		if !found {
+			if q, found = qualities["*"]; !found {
+				continue
+			}
+		}
+		if q > bestQ {
+			best = &responseEncoders[i]
+			bestQ = q
+		}
+	}
+	return best
+}
+
+// compressResponseWriter buffers the start of a response until it is known whether it
+// is large enough to compress.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoder *responseEncoder
+	level   int
+	minSize int
+	status  int
+	buf     []byte
+	// started is true once the status has been sent, writer is set if the response is compressed.
+	started bool
+	writer  io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	w.status = status
+	// Responses without body are sent right away
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		w.start(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the status and buffered data, compressed if requested and the response allows it.
+func (w *compressResponseWriter) start(compress bool) error {
+	w.started = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	contentType := h.Get("Content-Type")
+	if contentType == "" && len(w.buf) > 0 {
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/") || strings.HasPrefix(contentType, "audio/") {
+		compress = false
+	}
+
+	if compress {
+		writer, err := w.encoder.newWriter(w.ResponseWriter, w.level)
+		if err != nil {
+			return err
+		}
+		w.writer = writer
+		h.Set("Content-Encoding", w.encoder.name)
+		h.Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.writer != nil {
+		_, err := w.writer.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends what is left of the response once the handler is done.
+func (w *compressResponseWriter) close() {
+	if !w.started {
+		// Nothing written, leave it to net/http to send the status
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.start(false)
+	}
+	if w.writer != nil {
+		w.writer.Close()
+	}
+}
+
+// Flush sends the buffered data to the client, used by long running responses.
+func (w *compressResponseWriter) Flush() {
+	if !w.started {
+		w.start(true)
+	}
+	if f, ok := w.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection.
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the original ResponseWriter, used by http.ResponseController.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
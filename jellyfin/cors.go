@@ -0,0 +1,59 @@
+package jellyfin
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/handlers"
+)
+
+// CORS holds the cross-origin resource sharing settings, needed by web clients
+// that are hosted on another origin than the server.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the API, e.g. "https://jellyfin.example.com".
+	// "*" allows all origins, empty disables CORS.
+	AllowedOrigins []string
+	// AllowedHeaders are request headers allowed in addition to the Jellyfin authorization headers.
+	AllowedHeaders []string
+	// AllowCredentials indicates whether requests may include credentials such as cookies.
+	AllowCredentials bool
+}
+
+// corsAllowedHeaders are the request headers used by Jellyfin clients.
+var corsAllowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Range",
+	"X-Emby-Authorization",
+	"X-Emby-Token",
+	"X-MediaBrowser-Token",
+}
+
+// corsExposedHeaders are the response headers web clients need access to.
+var corsExposedHeaders = []string{
+	"Content-Disposition",
+	"Content-Length",
+	"Content-Range",
+	"Retry-After",
+}
+
+// CORSMiddleware returns middleware that adds CORS headers and answers preflight
+// requests. It needs to wrap the router, as preflight requests do not match
+// the methods of most routes. Returns next as-is if no origins are configured.
+func (j *Jellyfin) CORSMiddleware(next http.Handler) http.Handler {
+	if len(j.cors.AllowedOrigins) == 0 {
+		return next
+	}
+	options := []handlers.CORSOption{
+		handlers.AllowedOrigins(j.cors.AllowedOrigins),
+		handlers.AllowedHeaders(slices.Concat(corsAllowedHeaders, j.cors.AllowedHeaders)),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"}),
+		handlers.ExposedHeaders(corsExposedHeaders),
+		handlers.MaxAge(600),
+		handlers.OptionStatusCode(http.StatusNoContent),
+	}
+	if j.cors.AllowCredentials {
+		options = append(options, handlers.AllowCredentials())
+	}
+	return handlers.CORS(options...)(next)
+}
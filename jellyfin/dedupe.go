@@ -0,0 +1,112 @@
+package jellyfin
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/collection"
+)
+
+// duplicateCandidate is a movie or show that might be in more than one collection.
+type duplicateCandidate struct {
+	collectionID string
+	item         collection.Item
+}
+
+// duplicateItems returns the IDs of movies and shows that are a lower quality version of an
+// item in another collection, e.g. the 1080p version of a movie that is also in a 4K collection.
+// Items are the same if they share a provider ID, items without provider IDs are never left out.
+// If both versions are of the same quality the one in the first collection is kept.
+func (j *Jellyfin) duplicateItems() map[string]bool {
+	duplicates := make(map[string]bool)
+	// kept holds the versions to keep, byProviderID points into kept
+	var kept []duplicateCandidate
+	byProviderID := make(map[string]int)
+	for _, c := range j.collections.GetCollections() {
+		for _, i := range c.Items {
+			keys := duplicateKeys(i)
+			if len(keys) == 0 {
+				continue
+			}
+			found := -1
+			for _, key := range keys {
+				if k, ok := byProviderID[key]; ok && kept[k].collectionID != c.ID {
+					found = k
+					break
+				}
+			}
+			candidate := duplicateCandidate{collectionID: c.ID, item: i}
+			switch {
+			case found == -1:
+				kept = append(kept, candidate)
+				found = len(kept) - 1
+			case compareVideoQuality(i, kept[found].item) > 0:
+				duplicates[kept[found].item.ID()] = true
+				kept[found] = candidate
+			default:
+				duplicates[i.ID()] = true
+			}
+			for _, key := range keys {
+				if _, ok := byProviderID[key]; !ok {
+					byProviderID[key] = found
+				}
+			}
+		}
+	}
+	return duplicates
+}
+
+// duplicateKeys returns the provider IDs of a movie or show, e.g. "movie.imdb.tt0034583".
+func duplicateKeys(i collection.Item) []string {
+	var itemType string
+	var providerIDs map[string]string
+	switch v := i.(type) {
+	case *collection.Movie:
+		itemType, providerIDs = "movie", v.Metadata.ProviderIDs()
+	case *collection.Show:
+		itemType, providerIDs = "show", v.Metadata.ProviderIDs()
+	default:
+		return nil
+	}
+	var keys []string
+	for provider, id := range providerIDs {
+		if provider == "default" || id == "" {
+			continue
+		}
+		keys = append(keys, itemType+"."+strings.ToLower(provider)+"."+strings.ToLower(strings.TrimSpace(id)))
+	}
+	return keys
+}
+
+// compareVideoQuality compares the video of two items by resolution, bitrate and file size.
+// The video of a show is that of its first episode.
+func compareVideoQuality(a, b collection.Item) int {
+	a, b = qualityVideo(a), qualityVideo(b)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return cmp.Or(
+		cmp.Compare(a.VideoWidth()*a.VideoHeight(), b.VideoWidth()*b.VideoHeight()),
+		cmp.Compare(a.VideoBitrate(), b.VideoBitrate()),
+		cmp.Compare(a.FileSize(), b.FileSize()),
+	)
+}
+
+// qualityVideo returns the item holding the video to compare quality of, nil if there is none.
+func qualityVideo(i collection.Item) collection.Item {
+	show, ok := i.(*collection.Show)
+	if !ok {
+		return i
+	}
+	for si := range show.Seasons {
+		if len(show.Seasons[si].Episodes) > 0 {
+			return &show.Seasons[si].Episodes[0]
+		}
+	}
+	return nil
+}
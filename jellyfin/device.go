@@ -142,19 +142,17 @@ func (j *Jellyfin) devicesOptionsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (j *Jellyfin) makeJFDeviceItem(accessToken model.AccessToken, user string) JFDeviceItem {
+	capabilities := j.getCapabilities(accessToken.DeviceId)
 	return JFDeviceItem{
-		ID:           accessToken.DeviceId,
-		LastUserID:   accessToken.UserID,
-		LastUserName: user,
-		Name:         accessToken.DeviceName,
-		AppName:      accessToken.ApplicationName,
-		AppVersion:   accessToken.ApplicationVersion,
-		Capabilities: JFSessionResponseCapabilities{
-			PlayableMediaTypes:           []string{},
-			SupportedCommands:            []string{},
-			SupportsMediaControl:         false,
-			SupportsPersistentIdentifier: true,
-		},
+		ID:               accessToken.DeviceId,
+		LastUserID:       accessToken.UserID,
+		LastUserName:     user,
+		Name:             accessToken.DeviceName,
+		AppName:          accessToken.ApplicationName,
+		AppVersion:       accessToken.ApplicationVersion,
+		DeviceType:       deviceType(accessToken.ApplicationName, accessToken.DeviceName),
+		IconURL:          capabilities.IconURL,
+		Capabilities:     capabilities,
 		DateLastActivity: accessToken.LastUsed,
 	}
 }
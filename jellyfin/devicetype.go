@@ -0,0 +1,71 @@
+package jellyfin
+
+import (
+	"strings"
+)
+
+// Device types of clients, shown as device icon in the admin dashboard.
+const (
+	deviceTypeTV      = "tv"
+	deviceTypeMobile  = "mobile"
+	deviceTypeDesktop = "desktop"
+	deviceTypeWeb     = "web"
+)
+
+// deviceTypes maps parts of client and device names to their device type. The first
+// match wins, so TV apps go before the mobile apps of the same platform.
+var deviceTypes = []struct {
+	match      string
+	deviceType string
+}{
+	{"android tv", deviceTypeTV},
+	{"androidtv", deviceTypeTV},
+	{"fire tv", deviceTypeTV},
+	{"tvos", deviceTypeTV},
+	{"apple tv", deviceTypeTV},
+	{"roku", deviceTypeTV},
+	{"webos", deviceTypeTV},
+	{"tizen", deviceTypeTV},
+	{"kodi", deviceTypeTV},
+	{"jellycon", deviceTypeTV},
+	{"xbox", deviceTypeTV},
+	{"smart tv", deviceTypeTV},
+	{"android", deviceTypeMobile},
+	{"ios", deviceTypeMobile},
+	{"iphone", deviceTypeMobile},
+	{"ipad", deviceTypeMobile},
+	{"findroid", deviceTypeMobile},
+	{"streamyfin", deviceTypeMobile},
+	{"swiftfin", deviceTypeMobile},
+	{"infuse", deviceTypeMobile},
+	{"mobile", deviceTypeMobile},
+	{"media player", deviceTypeDesktop},
+	{"mpv", deviceTypeDesktop},
+	{"desktop", deviceTypeDesktop},
+	{"web", deviceTypeWeb},
+}
+
+// deviceType returns the device type of a client, e.g. "tv" for "Jellyfin Android TV",
+// based on its application name. The device name is used for unknown clients and for
+// web clients, as the web client also runs on TVs. It returns an empty string for unknown clients.
+func deviceType(client, device string) string {
+	clientType := matchDeviceType(client)
+	if clientType != "" && clientType != deviceTypeWeb {
+		return clientType
+	}
+	if t := matchDeviceType(device); t != "" {
+		return t
+	}
+	return clientType
+}
+
+// matchDeviceType returns the device type of the first entry of deviceTypes matching name.
+func matchDeviceType(name string) string {
+	name = strings.ToLower(name)
+	for _, t := range deviceTypes {
+		if strings.Contains(name, t.match) {
+			return t.deviceType
+		}
+	}
+	return ""
+}
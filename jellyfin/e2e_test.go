@@ -0,0 +1,575 @@
+package jellyfin_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database"
+	"github.com/erikbos/jellofin-server/database/sqlite"
+	"github.com/erikbos/jellofin-server/imageresize"
+	"github.com/erikbos/jellofin-server/jellyfin"
+	"github.com/erikbos/jellofin-server/muxnormalizer"
+	"github.com/erikbos/jellofin-server/parentalrating"
+)
+
+// clientStep is a request made by a client, with the response it expects.
+// Placeholders like {userId} in path and body are replaced by values captured
+// from earlier responses.
+type clientStep struct {
+	method string
+	path   string
+	body   string
+	// status is the expected response status code
+	status int
+	// contentType is the expected content type, JSON if empty
+	contentType string
+	// fields are the JSON paths that must be present in the response, e.g. "Items.0.Id"
+	fields []string
+	// values are JSON paths with their expected value, placeholders are replaced
+	values map[string]string
+	// capture stores the values of JSON paths as placeholders for later steps
+	capture map[string]string
+}
+
+// clientSequence is the sequence of requests a client makes to log in, browse and play.
+type clientSequence struct {
+	name string
+	// header is the name of the header carrying the client authorization
+	header string
+	// authorization is the authorization header value, {token} is replaced by the access token
+	authorization string
+	steps         []clientStep
+}
+
+func TestClientSequences(t *testing.T) {
+	for _, client := range clientSequences {
+		t.Run(client.name, func(t *testing.T) {
+			server := newTestServer(t)
+			placeholders := map[string]string{"token": ""}
+			for i, step := range client.steps {
+				replacer := placeholderReplacer(placeholders)
+				path := replacer.Replace(step.path)
+				var body io.Reader
+				if step.body != "" {
+					body = strings.NewReader(replacer.Replace(step.body))
+				}
+				req, err := http.NewRequest(step.method, server.URL+path, body)
+				if err != nil {
+					t.Fatalf("step %d %s %s: %s", i, step.method, path, err)
+				}
+				req.Header.Set(client.header, replacer.Replace(client.authorization))
+				if step.body != "" {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("step %d %s %s: %s", i, step.method, path, err)
+				}
+				data, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatalf("step %d %s %s: reading response: %s", i, step.method, path, err)
+				}
+				if resp.StatusCode != step.status {
+					t.Fatalf("step %d %s %s: status %d, want %d: %s", i, step.method, path, resp.StatusCode, step.status, data)
+				}
+				if step.contentType != "" {
+					if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, step.contentType) {
+						t.Fatalf("step %d %s %s: content type %q, want %q", i, step.method, path, ct, step.contentType)
+					}
+					continue
+				}
+				if len(step.fields) == 0 && len(step.values) == 0 && len(step.capture) == 0 {
+					continue
+				}
+				var response any
+				if err := json.Unmarshal(data, &response); err != nil {
+					t.Fatalf("step %d %s %s: invalid JSON response: %s", i, step.method, path, err)
+				}
+				for _, field := range step.fields {
+					if _, ok := jsonPath(response, field); !ok {
+						t.Errorf("step %d %s %s: response has no %s: %s", i, step.method, path, field, data)
+					}
+				}
+				for field, want := range step.values {
+					want = replacer.Replace(want)
+					if got, _ := jsonPath(response, field); got != want {
+						t.Errorf("step %d %s %s: %s is %q, want %q", i, step.method, path, field, got, want)
+					}
+				}
+				for name, field := range step.capture {
+					value, ok := jsonPath(response, field)
+					if !ok || value == "" {
+						t.Fatalf("step %d %s %s: cannot capture %s from %s: %s", i, step.method, path, name, field, data)
+					}
+					placeholders[name] = value
+				}
+			}
+		})
+	}
+}
+
+// newTestServer starts a server with the Jellyfin API on the default fixture library,
+// wired the way the server binary does it.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+
+	collections, err := collection.DefaultFixture(filepath.Join(dir, "library")).Build()
+	if err != nil {
+		t.Fatalf("building fixture library: %s", err)
+	}
+	repo, err := database.New("sqlite", sqlite.ConfigFile{
+		Filename: filepath.Join(dir, "jellofin.db"),
+	})
+	if err != nil {
+		t.Fatalf("database.New: %s", err)
+	}
+	collectionRepo := collection.New(&collection.Options{
+		Collections:  collections,
+		Repo:         repo,
+		ThumbnailDir: filepath.Join(dir, "thumbnails"),
+	})
+	collectionRepo.Init()
+
+	parentalRatings, err := parentalrating.New("")
+	if err != nil {
+		t.Fatalf("parentalrating.New: %s", err)
+	}
+	j := jellyfin.New(&jellyfin.Options{
+		Collections: collectionRepo,
+		Repo:        repo,
+		Imageresizer: imageresize.New(imageresize.Options{
+			Cachedir: filepath.Join(dir, "cache"),
+		}),
+		ParentalRatings: parentalRatings,
+		ServerID:        "e2etestserver",
+		ServerName:      "e2e",
+		AutoRegister:    true,
+	})
+	r := mux.NewRouter()
+	j.RegisterHandlers(r)
+
+	canon, err := muxnormalizer.New(r)
+	if err != nil {
+		t.Fatalf("muxnormalizer.New: %s", err)
+	}
+	server := httptest.NewServer(canon.Middleware(r))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// placeholderReplacer returns a replacer of {name} placeholders by their values.
+func placeholderReplacer(placeholders map[string]string) *strings.Replacer {
+	var oldnew []string
+	for name, value := range placeholders {
+		oldnew = append(oldnew, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// jsonPath returns the value at a dot separated path of a decoded JSON document, e.g.
+// "Items.0.Id". Values that are not strings are returned in their JSON form.
+func jsonPath(document any, path string) (string, bool) {
+	value := document
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return "", false
+			}
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", false
+			}
+			value = v[index]
+		default:
+			return "", false
+		}
+	}
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	data, _ := json.Marshal(value)
+	return string(data), true
+}
+
+// login is the request all clients make to authenticate, users are registered on first login.
+var login = clientStep{
+	method: "POST",
+	path:   "/Users/AuthenticateByName",
+	body:   `{"Username":"viewer","Pw":"secret"}`,
+	status: http.StatusOK,
+	fields: []string{"User.Policy", "SessionInfo"},
+	values: map[string]string{
+		"User.Name": "viewer",
+		"ServerId":  "e2etestserver",
+	},
+	capture: map[string]string{
+		"token":  "AccessToken",
+		"userId": "User.Id",
+	},
+}
+
+// publicInfo is the request all clients make to find out which server they talk to.
+var publicInfo = clientStep{
+	method: "GET",
+	path:   "/System/Info/Public",
+	status: http.StatusOK,
+	fields: []string{"Version", "ProductName", "StartupWizardCompleted"},
+	values: map[string]string{
+		"Id":         "e2etestserver",
+		"ServerName": "e2e",
+	},
+}
+
+var clientSequences = []clientSequence{
+	{
+		// Infuse uses the legacy authorization header and the /Users/{userId} routes
+		name:          "Infuse",
+		header:        "X-Emby-Authorization",
+		authorization: `MediaBrowser Device="Apple TV", DeviceId="6A3F2C1E-1B7D-4C59-9E0A-6B8D2F4E7C11", Token="{token}", Client="Infuse-Direct", Version="8.0.9"`,
+		steps: []clientStep{
+			publicInfo,
+			login,
+			{
+				method: "GET",
+				path:   "/Users/{userId}",
+				status: http.StatusOK,
+				fields: []string{"Policy", "Configuration"},
+				values: map[string]string{"Id": "{userId}"},
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Views",
+				status: http.StatusOK,
+				fields: []string{"TotalRecordCount"},
+				values: map[string]string{
+					"Items.0.Name":           "Movies",
+					"Items.0.CollectionType": "movies",
+					"Items.1.Name":           "Shows",
+					"Items.1.CollectionType": "tvshows",
+				},
+				capture: map[string]string{
+					"moviesId": "Items.0.Id",
+					"showsId":  "Items.1.Id",
+				},
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Items?ParentId={moviesId}&Recursive=true&IncludeItemTypes=Movie&SortBy=SortName&Fields=ProviderIds,MediaSources,Genres",
+				status: http.StatusOK,
+				fields: []string{"Items.0.UserData", "Items.0.ImageTags"},
+				values: map[string]string{
+					"TotalRecordCount": "2",
+					"Items.0.Name":     "Casablanca",
+					"Items.0.Type":     "Movie",
+				},
+				capture: map[string]string{"movieId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Items/{movieId}",
+				status: http.StatusOK,
+				fields: []string{"MediaSources.0.Id", "Genres", "People", "UserData"},
+				values: map[string]string{
+					"Id":               "{movieId}",
+					"Name":             "Casablanca",
+					"ProductionYear":   "1942",
+					"ProviderIds.Imdb": "tt0034583",
+				},
+			},
+			{
+				method:      "GET",
+				path:        "/Items/{movieId}/Images/Primary?maxWidth=300",
+				status:      http.StatusOK,
+				contentType: "image/jpeg",
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Items?ParentId={showsId}&Recursive=true&IncludeItemTypes=Series",
+				status: http.StatusOK,
+				values: map[string]string{
+					"TotalRecordCount": "1",
+					"Items.0.Name":     "The Wire",
+					"Items.0.Type":     "Series",
+				},
+				capture: map[string]string{"showId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/{showId}/Seasons?UserId={userId}",
+				status: http.StatusOK,
+				values: map[string]string{
+					"Items.0.Type":     "Season",
+					"Items.0.SeriesId": "{showId}",
+				},
+				capture: map[string]string{"seasonId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/{showId}/Episodes?SeasonId={seasonId}&UserId={userId}",
+				status: http.StatusOK,
+				fields: []string{"Items.0.IndexNumber", "Items.0.ParentIndexNumber"},
+				values: map[string]string{
+					"Items.0.Type":     "Episode",
+					"Items.0.SeriesId": "{showId}",
+					"Items.0.SeasonId": "{seasonId}",
+				},
+			},
+			{
+				method: "POST",
+				path:   "/Users/{userId}/PlayedItems/{movieId}",
+				status: http.StatusOK,
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Items/{movieId}",
+				status: http.StatusOK,
+				values: map[string]string{"UserData.Played": "true"},
+			},
+			{
+				method: "GET",
+				path:   "/Users/{userId}/Items/Resume?Limit=12&MediaTypes=Video",
+				status: http.StatusOK,
+				fields: []string{"Items", "TotalRecordCount"},
+			},
+		},
+	},
+	{
+		// Jellyfin web uses the Authorization header and the routes without user ID
+		name:          "Jellyfin web",
+		header:        "Authorization",
+		authorization: `MediaBrowser Client="Jellyfin Web", Device="Firefox", DeviceId="TW96aWxsYS81LjAgKFgxMTsgTGludXggeDg2XzY0KQ11", Version="10.10.3", Token="{token}"`,
+		steps: []clientStep{
+			publicInfo,
+			{
+				method: "GET",
+				path:   "/Branding/Configuration",
+				status: http.StatusOK,
+				fields: []string{"SplashscreenEnabled"},
+			},
+			login,
+			{
+				method: "GET",
+				path:   "/Users/Me",
+				status: http.StatusOK,
+				values: map[string]string{"Id": "{userId}"},
+			},
+			{
+				method: "GET",
+				path:   "/DisplayPreferences/usersettings?userId={userId}&client=emby",
+				status: http.StatusOK,
+				fields: []string{"Id", "CustomPrefs"},
+			},
+			{
+				method: "GET",
+				path:   "/UserViews?userId={userId}",
+				status: http.StatusOK,
+				values: map[string]string{
+					"Items.0.CollectionType": "movies",
+					"Items.1.CollectionType": "tvshows",
+				},
+				capture: map[string]string{
+					"moviesId": "Items.0.Id",
+					"showsId":  "Items.1.Id",
+				},
+			},
+			{
+				method: "GET",
+				path:   "/UserItems/Resume?userId={userId}&limit=12&mediaTypes=Video&enableTotalRecordCount=false",
+				status: http.StatusOK,
+				fields: []string{"Items"},
+			},
+			{
+				method: "GET",
+				path:   "/Items/Latest?userId={userId}&parentId={moviesId}&limit=16&fields=PrimaryImageAspectRatio",
+				status: http.StatusOK,
+				fields: []string{"0.Id", "1.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/NextUp?userId={userId}&limit=24&enableResumable=false",
+				status: http.StatusOK,
+				fields: []string{"Items"},
+			},
+			{
+				method: "GET",
+				path:   "/Items?userId={userId}&parentId={moviesId}&sortBy=SortName&sortOrder=Ascending&includeItemTypes=Movie&recursive=true&startIndex=0&limit=100",
+				status: http.StatusOK,
+				values: map[string]string{
+					"TotalRecordCount": "2",
+					"StartIndex":       "0",
+					"Items.0.Name":     "Casablanca",
+				},
+				capture: map[string]string{"movieId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Items/{movieId}?userId={userId}",
+				status: http.StatusOK,
+				values: map[string]string{
+					"Name":      "Casablanca",
+					"IsFolder":  "false",
+					"MediaType": "Video",
+				},
+			},
+			{
+				method: "POST",
+				path:   "/Items/{movieId}/PlaybackInfo?userId={userId}&autoOpenLiveStream=true",
+				body:   `{"DeviceProfile":{"MaxStreamingBitrate":120000000},"StartTimeTicks":0}`,
+				status: http.StatusOK,
+				fields: []string{"MediaSources.0.Id", "MediaSources.0.SupportsDirectPlay"},
+				capture: map[string]string{
+					"playSessionId": "PlaySessionId",
+					"mediaSourceId": "MediaSources.0.Id",
+				},
+			},
+			{
+				method: "POST",
+				path:   "/Sessions/Playing",
+				body:   `{"ItemId":"{movieId}","MediaSourceId":"{mediaSourceId}","PlaySessionId":"{playSessionId}","PositionTicks":0,"CanSeek":true,"PlayMethod":"DirectPlay"}`,
+				status: http.StatusNoContent,
+			},
+			{
+				method: "POST",
+				path:   "/Sessions/Playing/Progress",
+				body:   `{"ItemId":"{movieId}","MediaSourceId":"{mediaSourceId}","PlaySessionId":"{playSessionId}","PositionTicks":600000000,"IsPaused":false}`,
+				status: http.StatusNoContent,
+			},
+			{
+				method: "GET",
+				path:   "/Sessions?activeWithinSeconds=960",
+				status: http.StatusOK,
+				values: map[string]string{
+					"0.NowPlayingItem.Id":       "{movieId}",
+					"0.PlayState.PositionTicks": "600000000",
+				},
+			},
+			{
+				method: "POST",
+				path:   "/Sessions/Playing/Stopped",
+				body:   `{"ItemId":"{movieId}","MediaSourceId":"{mediaSourceId}","PlaySessionId":"{playSessionId}","PositionTicks":1200000000}`,
+				status: http.StatusNoContent,
+			},
+			{
+				method: "GET",
+				path:   "/Sessions?activeWithinSeconds=960",
+				status: http.StatusOK,
+				fields: []string{"0.Id"},
+			},
+		},
+	},
+	{
+		// Findroid uses the Authorization header, and browses shows to play an episode
+		name:          "Findroid",
+		header:        "Authorization",
+		authorization: `MediaBrowser Client="Findroid", Device="Pixel%207", DeviceId="a1b2c3d4e5f60718", Version="0.15.3", Token="{token}"`,
+		steps: []clientStep{
+			publicInfo,
+			login,
+			{
+				method: "POST",
+				path:   "/Sessions/Capabilities/Full",
+				body:   `{"PlayableMediaTypes":["Video"],"SupportedCommands":[],"SupportsMediaControl":false}`,
+				status: http.StatusNoContent,
+			},
+			{
+				method: "GET",
+				path:   "/UserViews?userId={userId}",
+				status: http.StatusOK,
+				capture: map[string]string{
+					"showsId": "Items.1.Id",
+				},
+			},
+			{
+				method: "GET",
+				path:   "/Items?userId={userId}&parentId={showsId}&includeItemTypes=Series&recursive=true&fields=Overview,Genres",
+				status: http.StatusOK,
+				fields: []string{"Items.0.Genres", "Items.0.ImageTags"},
+				values: map[string]string{
+					"Items.0.Name": "The Wire",
+				},
+				capture: map[string]string{"showId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Items/{showId}?userId={userId}",
+				status: http.StatusOK,
+				values: map[string]string{
+					"Type":     "Series",
+					"IsFolder": "true",
+				},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/{showId}/Seasons?userId={userId}",
+				status: http.StatusOK,
+				fields: []string{"Items.0.IndexNumber", "Items.1.IndexNumber", "Items.2.IndexNumber"},
+				values: map[string]string{"TotalRecordCount": "3"},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/{showId}/Episodes?userId={userId}&fields=Overview",
+				status: http.StatusOK,
+				values: map[string]string{"TotalRecordCount": "6"},
+			},
+			{
+				method: "GET",
+				path:   "/Shows/NextUp?userId={userId}&seriesId={showId}",
+				status: http.StatusOK,
+				values: map[string]string{
+					"Items.0.Type":     "Episode",
+					"Items.0.SeriesId": "{showId}",
+				},
+				capture: map[string]string{"episodeId": "Items.0.Id"},
+			},
+			{
+				method: "GET",
+				path:   "/Items/{episodeId}?userId={userId}",
+				status: http.StatusOK,
+				fields: []string{"SeasonId", "SeriesName", "IndexNumber", "ParentIndexNumber"},
+				values: map[string]string{
+					"Type":     "Episode",
+					"SeriesId": "{showId}",
+				},
+			},
+			{
+				method:  "POST",
+				path:    "/Items/{episodeId}/PlaybackInfo?userId={userId}",
+				body:    `{"DeviceProfile":{},"EnableDirectPlay":true}`,
+				status:  http.StatusOK,
+				fields:  []string{"MediaSources.0.Path"},
+				capture: map[string]string{"playSessionId": "PlaySessionId"},
+			},
+			{
+				method:      "GET",
+				path:        "/Videos/{episodeId}/stream?static=true&playSessionId={playSessionId}",
+				status:      http.StatusOK,
+				contentType: "video/mp4",
+			},
+			{
+				method: "POST",
+				path:   "/UserPlayedItems/{episodeId}?userId={userId}",
+				status: http.StatusOK,
+			},
+			{
+				method: "GET",
+				path:   "/Shows/NextUp?userId={userId}&seriesId={showId}",
+				status: http.StatusOK,
+				fields: []string{"Items.0.Id"},
+			},
+		},
+	},
+}
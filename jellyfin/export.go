@@ -0,0 +1,176 @@
+package jellyfin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// userDataExportVersion is the version of the user data export format.
+const userDataExportVersion = 1
+
+// UserDataExport is a portable export of the play state, favorites, ratings and playlists of a user.
+// Items are identified by provider ids, so the export can be imported into another server.
+type UserDataExport struct {
+	Version    int                      `json:"Version"`
+	ExportDate time.Time                `json:"ExportDate"`
+	UserName   string                   `json:"UserName"`
+	Items      []UserDataExportItem     `json:"Items"`
+	Playlists  []UserDataExportPlaylist `json:"Playlists"`
+}
+
+// UserDataExportItem is the play state of a movie, show or episode.
+type UserDataExportItem struct {
+	UserDataExportRef
+	Played                bool      `json:"Played"`
+	PlayCount             int       `json:"PlayCount,omitempty"`
+	PlaybackPositionTicks int64     `json:"PlaybackPositionTicks,omitempty"`
+	PlayedPercentage      int       `json:"PlayedPercentage,omitempty"`
+	IsFavorite            bool      `json:"IsFavorite"`
+	Likes                 *bool     `json:"Likes,omitempty"`
+	Rating                float64   `json:"Rating,omitempty"`
+	LastPlayedDate        time.Time `json:"LastPlayedDate"`
+}
+
+// UserDataExportRef identifies an item independent of this server. Episodes are
+// identified by the provider ids of their show and their season and episode number.
+type UserDataExportRef struct {
+	Type              string            `json:"Type"`
+	Name              string            `json:"Name"`
+	ProductionYear    int               `json:"ProductionYear,omitempty"`
+	ProviderIds       map[string]string `json:"ProviderIds"`
+	SeriesName        string            `json:"SeriesName,omitempty"`
+	SeriesProviderIds map[string]string `json:"SeriesProviderIds,omitempty"`
+	ParentIndexNumber *int              `json:"ParentIndexNumber,omitempty"`
+	IndexNumber       *int              `json:"IndexNumber,omitempty"`
+}
+
+// UserDataExportPlaylist is a playlist with its items in order.
+type UserDataExportPlaylist struct {
+	Name  string              `json:"Name"`
+	Items []UserDataExportRef `json:"Items"`
+}
+
+// GET /Jellofin/Export/UserData
+//
+// Supported query params:
+// - userId, user to export, only administrators can export other users
+//
+// exportUserDataHandler returns watched flags, positions, favorites and playlists of a user.
+func (j *Jellyfin) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	user := reqCtx.User
+	if userID := r.URL.Query().Get("userId"); userID != "" && userID != user.ID {
+		if !reqCtx.User.Properties.Admin {
+			apierror(w, "Only administrators can export other users", http.StatusForbidden)
+			return
+		}
+		var err error
+		if user, err = j.repo.GetUserByID(r.Context(), userID); err != nil {
+			apierror(w, ErrUserIDNotFound, http.StatusNotFound)
+			return
+		}
+	}
+
+	response, err := j.makeUserDataExport(r.Context(), user)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-disposition", `attachment; filename="userdata-`+user.Username+`.json"`)
+	serveJSON(response, w)
+}
+
+// makeUserDataExport creates the export of the play state, favorites and playlists of a user.
+func (j *Jellyfin) makeUserDataExport(ctx context.Context, user *model.User) (UserDataExport, error) {
+	export := UserDataExport{
+		Version:    userDataExportVersion,
+		ExportDate: time.Now().UTC(),
+		UserName:   user.Username,
+		Items:      []UserDataExportItem{},
+		Playlists:  []UserDataExportPlaylist{},
+	}
+
+	// refs holds the reference of every item, needed to export playlists
+	refs := make(map[string]UserDataExportRef)
+	addItem := func(itemID string, ref UserDataExportRef) {
+		refs[itemID] = ref
+		p, err := j.repo.GetUserData(ctx, user.ID, itemID)
+		if err != nil || (!p.Played && !p.Favorite && p.Position == 0 && p.PlayCount == 0 && p.Likes == nil && p.Rating == 0) {
+			return
+		}
+		export.Items = append(export.Items, UserDataExportItem{
+			UserDataExportRef:     ref,
+			Played:                p.Played,
+			PlayCount:             p.PlayCount,
+			PlaybackPositionTicks: p.Position * TicsToSeconds,
+			PlayedPercentage:      p.PlayedPercentage,
+			IsFavorite:            p.Favorite,
+			Likes:                 p.Likes,
+			Rating:                p.Rating,
+			LastPlayedDate:        p.Timestamp,
+		})
+	}
+
+	for _, c := range j.collections.GetCollections() {
+		for _, i := range c.Items {
+			switch i := i.(type) {
+			case *collection.Movie:
+				addItem(i.ID(), UserDataExportRef{
+					Type:           itemTypeMovie,
+					Name:           i.Metadata.Title(),
+					ProductionYear: i.Metadata.Year(),
+					ProviderIds:    i.Metadata.ProviderIDs(),
+				})
+			case *collection.Show:
+				addItem(i.ID(), UserDataExportRef{
+					Type:           itemTypeShow,
+					Name:           i.Metadata.Title(),
+					ProductionYear: i.Metadata.Year(),
+					ProviderIds:    i.Metadata.ProviderIDs(),
+				})
+				for _, s := range i.Seasons {
+					for _, e := range s.Episodes {
+						addItem(e.ID(), UserDataExportRef{
+							Type:              itemTypeEpisode,
+							Name:              e.Metadata.Title(),
+							ProviderIds:       e.Metadata.ProviderIDs(),
+							SeriesName:        i.Metadata.Title(),
+							SeriesProviderIds: i.Metadata.ProviderIDs(),
+							ParentIndexNumber: &e.SeasonNo,
+							IndexNumber:       &e.EpisodeNo,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	playlistIDs, err := j.repo.GetPlaylists(ctx, user.ID)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, playlistID := range playlistIDs {
+		playlist, err := j.repo.GetPlaylist(ctx, user.ID, playlistID)
+		if err != nil {
+			return UserDataExport{}, err
+		}
+		p := UserDataExportPlaylist{
+			Name:  playlist.Name,
+			Items: make([]UserDataExportRef, 0, len(playlist.ItemIDs)),
+		}
+		for _, itemID := range playlist.ItemIDs {
+			if ref, ok := refs[itemID]; ok {
+				p.Items = append(p.Items, ref)
+			}
+		}
+		export.Playlists = append(export.Playlists, p)
+	}
+	return export, nil
+}
@@ -2,13 +2,16 @@ package jellyfin
 
 import (
 	"context"
+	"encoding/json"
+	"log"
 	"net/http"
 	"net/url"
 	"slices"
 	"strings"
-	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection/metadata"
 )
 
 // /Genres
@@ -107,6 +110,7 @@ func (j *Jellyfin) usersItemsFiltersHandler(w http.ResponseWriter, r *http.Reque
 	tags := make([]string, 0)
 	official := make([]string, 0)
 	years := make([]int, 0)
+	locations := make([]string, 0)
 
 	for _, i := range items {
 		for _, g := range i.Genres {
@@ -125,15 +129,22 @@ func (j *Jellyfin) usersItemsFiltersHandler(w http.ResponseWriter, r *http.Reque
 		if i.ProductionYear != 0 && !slices.Contains(years, i.ProductionYear) {
 			years = append(years, i.ProductionYear)
 		}
+		for _, l := range i.ProductionLocations {
+			if !slices.Contains(locations, l) {
+				locations = append(locations, l)
+			}
+		}
 	}
 
 	slices.Sort(years)
+	slices.Sort(locations)
 
 	response := JFItemFilterResponse{
-		Genres:          genres,
-		Tags:            tags,
-		OfficialRatings: official,
-		Years:           years,
+		Genres:              genres,
+		Tags:                tags,
+		OfficialRatings:     official,
+		Years:               years,
+		ProductionLocations: locations,
 	}
 	serveJSON(response, w)
 }
@@ -201,8 +212,6 @@ func (j *Jellyfin) makeJFItemGenre(ctx context.Context, _, genreID string) (JFIt
 		Name:         genre,
 		SortName:     genre,
 		Etag:         genreID,
-		DateCreated:  time.Now().UTC(),
-		PremiereDate: time.Now().UTC(),
 		LocationType: "FileSystem",
 		MediaType:    "Unknown",
 		ChildCount:   1,
@@ -231,3 +240,81 @@ func isJFGenreID(id string) bool {
 func decodeJFGenreID(genreID string) (string, error) {
 	return decodeExternalName(itemprefix_genre, genreID)
 }
+
+// GET /Library/GenreMapping
+//
+// genreMappingGetHandler returns the genre mapping, extending the built-in mapping
+func (j *Jellyfin) genreMappingGetHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view the genre mapping", http.StatusForbidden)
+		return
+	}
+	mapping := metadata.GenreMapping()
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+	serveJSON(mapping, w)
+}
+
+// POST /Library/GenreMapping
+//
+// genreMappingPostHandler replaces the genre mapping, e.g. {"sci-fi": "Science Fiction"}.
+// The mapping is stored in the genre mapping file and applies to all items without rescan.
+func (j *Jellyfin) genreMappingPostHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can change the genre mapping", http.StatusForbidden)
+		return
+	}
+	if j.genreMappingFile == "" {
+		apierror(w, "Genre mapping file not configured", http.StatusNotImplemented)
+		return
+	}
+	var mapping map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&mapping); err != nil {
+		apierror(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for genre, normalized := range mapping {
+		if strings.TrimSpace(genre) == "" || strings.TrimSpace(normalized) == "" {
+			apierror(w, "Genre names cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := metadata.SaveGenreMapping(j.genreMappingFile, mapping); err != nil {
+		log.Printf("Failed to store genre mapping: %s", err)
+		apierror(w, "Failed to store genre mapping", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /Library/GenreMapping/Reload
+//
+// genreMappingReloadHandler reads the genre mapping file again, e.g. after it has been edited.
+func (j *Jellyfin) genreMappingReloadHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can reload the genre mapping", http.StatusForbidden)
+		return
+	}
+	if j.genreMappingFile == "" {
+		apierror(w, "Genre mapping file not configured", http.StatusNotImplemented)
+		return
+	}
+	if err := metadata.LoadGenreMapping(j.genreMappingFile); err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// JFUserGuestRequest turns guest access of a user on or off.
+type JFUserGuestRequest struct {
+	Guest bool `json:"Guest"`
+}
+
+// POST /Jellofin/Users/{userid}/Guest
+//
+// usersGuestHandler makes a user a guest or a regular user. Guests can browse and play,
+// but their play states, favorites, ratings and playlists are not changed. Administrators only.
+func (j *Jellyfin) usersGuestHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can change guest access", http.StatusForbidden)
+		return
+	}
+	var req JFUserGuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror(w, ErrInvalidJSONPayload, http.StatusBadRequest)
+		return
+	}
+	dbuser, err := j.repo.GetUserByID(r.Context(), mux.Vars(r)["userid"])
+	if err != nil {
+		apierror(w, ErrUserIDNotFound, http.StatusNotFound)
+		return
+	}
+	if req.Guest && dbuser.Properties.Admin {
+		apierror(w, "Administrators cannot be guests", http.StatusBadRequest)
+		return
+	}
+	dbuser.Properties.Guest = req.Guest
+	if err = j.repo.UpsertUser(r.Context(), dbuser); err != nil {
+		apierror(w, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// guestDenied returns true if the user is a guest, after writing an error response.
+// Used by requests that change user data, guests have read-only access.
+func guestDenied(w http.ResponseWriter, user *model.User) bool {
+	if !user.Properties.Guest {
+		return false
+	}
+	apierror(w, "Guests cannot change user data", http.StatusForbidden)
+	return true
+}
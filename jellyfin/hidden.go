@@ -0,0 +1,127 @@
+package jellyfin
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// POST /Jellofin/Items/{item}/Hide
+//
+// Supported query params:
+// - global, true to hide the item for all users, administrators only
+//
+// itemsHideHandler hides an item from all listings of the user, or of all users.
+func (j *Jellyfin) itemsHideHandler(w http.ResponseWriter, r *http.Request) {
+	j.setItemHidden(w, r, true)
+}
+
+// DELETE /Jellofin/Items/{item}/Hide
+//
+// Supported query params:
+// - global, true to show an item hidden for all users again, administrators only
+//
+// itemsUnhideHandler shows a hidden item again.
+func (j *Jellyfin) itemsUnhideHandler(w http.ResponseWriter, r *http.Request) {
+	j.setItemHidden(w, r, false)
+}
+
+// setItemHidden hides or shows an item for the user of the request, or for all users.
+func (j *Jellyfin) setItemHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	itemID := trimPrefix(mux.Vars(r)["itemid"])
+	if _, i := j.collections.GetItemByID(itemID); i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	if strings.EqualFold(r.URL.Query().Get("global"), "true") {
+		if !reqCtx.User.Properties.Admin {
+			apierror(w, "Only administrators can hide items for all users", http.StatusForbidden)
+			return
+		}
+		if err := j.collections.SetHidden(r.Context(), itemID, hidden); err != nil {
+			apierror(w, "Failed to store hidden items", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	dbuser, err := j.repo.GetUserByID(r.Context(), reqCtx.User.ID)
+	if err != nil {
+		apierror(w, ErrUserIDNotFound, http.StatusNotFound)
+		return
+	}
+	index := slices.Index(dbuser.Properties.HiddenItems, itemID)
+	switch {
+	case hidden && index == -1:
+		dbuser.Properties.HiddenItems = append(dbuser.Properties.HiddenItems, itemID)
+	case !hidden && index != -1:
+		dbuser.Properties.HiddenItems = slices.Delete(dbuser.Properties.HiddenItems, index, index+1)
+	}
+	if err = j.repo.UpsertUser(r.Context(), dbuser); err != nil {
+		apierror(w, "Failed to store hidden items", http.StatusInternalServerError)
+		return
+	}
+	reqCtx.User.Properties.HiddenItems = dbuser.Properties.HiddenItems
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /Jellofin/HiddenItems
+//
+// hiddenItemsHandler returns the items hidden by the user and the items hidden for all users.
+func (j *Jellyfin) hiddenItemsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	itemIDs := append(slices.Clone(reqCtx.User.Properties.HiddenItems), j.collections.HiddenItems()...)
+	slices.Sort(itemIDs)
+	items := []JFItem{}
+	for _, itemID := range slices.Compact(itemIDs) {
+		if jfitem, err := j.makeJFItemByID(r.Context(), reqCtx.User.ID, itemID); err == nil {
+			items = append(items, jfitem)
+		}
+	}
+	serveJSON(UserItemsResponse{
+		Items:            items,
+		StartIndex:       0,
+		TotalRecordCount: len(items),
+	}, w)
+}
+
+// applyHiddenItems removes items hidden by the user or for all users. Seasons and episodes
+// of a hidden show, and episodes of a hidden season, are removed as well.
+func (j *Jellyfin) applyHiddenItems(items []JFItem, user *model.User) []JFItem {
+	resultItems := make([]JFItem, 0, len(items))
+	for _, item := range items {
+		if j.itemHidden(trimPrefix(item.ID), user) || j.itemHidden(trimPrefix(item.SeriesID), user) ||
+			j.itemHidden(trimPrefix(item.SeasonID), user) {
+			continue
+		}
+		resultItems = append(resultItems, item)
+	}
+	return resultItems
+}
+
+// itemHidden returns true if the item is hidden by the user or for all users.
+func (j *Jellyfin) itemHidden(itemID string, user *model.User) bool {
+	if itemID == "" {
+		return false
+	}
+	if user != nil && slices.Contains(user.Properties.HiddenItems, itemID) {
+		return true
+	}
+	return j.collections.Hidden(itemID)
+}
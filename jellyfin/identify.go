@@ -0,0 +1,133 @@
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/tmdb"
+)
+
+// MovieSearchProvider searches movies in an online movie database, used to identify movies that were matched wrongly.
+type MovieSearchProvider interface {
+	SearchMovies(ctx context.Context, title string, year int, providerIDs map[string]string) ([]tmdb.Movie, error)
+}
+
+// movieSearchProviderName is the provider name reported to clients.
+const movieSearchProviderName = "TheMovieDb"
+
+// POST /Items/RemoteSearch/Movie
+//
+// itemsRemoteSearchMovieHandler searches movies by title and year, or by tmdb or imdb id.
+// Without title the name and year of the item in the query are used.
+func (j *Jellyfin) itemsRemoteSearchMovieHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	var request JFRemoteSearchQuery
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	response := []JFRemoteSearchResult{}
+	if j.movieSearchProvider == nil ||
+		(request.SearchProviderName != "" && request.SearchProviderName != movieSearchProviderName) {
+		serveJSON(response, w)
+		return
+	}
+
+	info := request.SearchInfo
+	providerIDs := make(map[string]string)
+	for provider, id := range info.ProviderIds {
+		if id = strings.TrimSpace(id); id != "" {
+			providerIDs[strings.ToLower(provider)] = id
+		}
+	}
+	if strings.TrimSpace(info.Name) == "" && len(providerIDs) == 0 {
+		if _, i := j.collections.GetItemByID(trimPrefix(request.ItemID)); i != nil {
+			if movie, ok := i.(*collection.Movie); ok {
+				info.Name = movie.Name()
+				if movie.Metadata != nil {
+					info.Year = movie.Metadata.Year()
+				}
+			}
+		}
+	}
+
+	movies, err := j.movieSearchProvider.SearchMovies(r.Context(), info.Name, info.Year, providerIDs)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+		log.Printf("Searching movie %s failed: %s", info.Name, err)
+		apierror(w, "Failed to search movie", http.StatusBadGateway)
+		return
+	}
+	for _, m := range movies {
+		result := JFRemoteSearchResult{
+			Name:               m.Title,
+			ProviderIds:        map[string]string{"Tmdb": m.ID},
+			ImageURL:           tmdb.ThumbnailURL(m.PosterPath),
+			SearchProviderName: movieSearchProviderName,
+			Overview:           m.Overview,
+		}
+		if m.IMDbID != "" {
+			result.ProviderIds["Imdb"] = m.IMDbID
+		}
+		if !m.ReleaseDate.IsZero() {
+			premiere := m.ReleaseDate
+			result.PremiereDate = &premiere
+			result.ProductionYear = m.ReleaseDate.Year()
+		}
+		response = append(response, result)
+	}
+	serveJSON(response, w)
+}
+
+// POST /Items/RemoteSearch/Apply/{item}
+// POST /Items/{item}/Identify
+//
+// itemsRemoteSearchApplyHandler stores the provider IDs of the search result chosen by the user
+// as the provider IDs of a movie or show, replacing the IDs of the same provider from its NFO.
+func (j *Jellyfin) itemsRemoteSearchApplyHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can identify items", http.StatusForbidden)
+		return
+	}
+	itemID := trimPrefix(mux.Vars(r)["itemid"])
+	_, i := j.collections.GetItemByID(itemID)
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	switch i.(type) {
+	case *collection.Movie, *collection.Show:
+	default:
+		apierror(w, "Only movies and shows can be identified", http.StatusBadRequest)
+		return
+	}
+
+	var request JFRemoteSearchResult
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(request.ProviderIds) == 0 {
+		apierror(w, "ProviderIds are required", http.StatusBadRequest)
+		return
+	}
+	if err := j.collections.SetProviderIDs(r.Context(), itemID, request.ProviderIds); err != nil {
+		apierror(w, "Failed to store provider ids", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Identified %s as %v", i.Name(), request.ProviderIds)
+	w.WriteHeader(http.StatusNoContent)
+}
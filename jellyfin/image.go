@@ -10,11 +10,13 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/erikbos/jellofin-server/collection"
 	"github.com/erikbos/jellofin-server/database/model"
 	"github.com/erikbos/jellofin-server/idhash"
 )
@@ -26,6 +28,8 @@ const (
 	imageTypeProfile = "Profile"
 	// database key for primary iamges
 	imageTypePrimary = "Primary"
+	// jpeg quality of resized images
+	defaultImageQuality = 90
 )
 
 // /Items/rVFG3EzPthk2wowNkqUl/Images/Backdrop?tag=7cec54f0c8f362c75588e83d76fefa75
@@ -57,6 +61,12 @@ func (j *Jellyfin) itemsImagesGetHandler(w http.ResponseWriter, r *http.Request)
 		fallthrough
 	case isJFCollectionPlaylistID(itemID):
 		fallthrough
+	case isJFSmartCollectionID(itemID):
+		fallthrough
+	case isJFCollectionBoxSetID(itemID):
+		fallthrough
+	case isJFBoxSetID(itemID):
+		fallthrough
 	case isJFGenreID(itemID):
 		fallthrough
 	case isJFStudioID(itemID):
@@ -73,6 +83,10 @@ func (j *Jellyfin) itemsImagesGetHandler(w http.ResponseWriter, r *http.Request)
 			http.Redirect(w, r, dbperson.PosterURL, http.StatusFound)
 			return
 		}
+		if thumb, ok := j.personThumb(name); ok {
+			http.Redirect(w, r, thumb, http.StatusFound)
+			return
+		}
 		apierror(w, ErrUserIDNotFound, http.StatusNotFound)
 		return
 	}
@@ -82,11 +96,23 @@ func (j *Jellyfin) itemsImagesGetHandler(w http.ResponseWriter, r *http.Request)
 		apierror(w, "Item not found", http.StatusNotFound)
 		return
 	}
+	// Uploaded artwork is preferred over images found by the scanner
+	if artworkType, ok := artworkType(imageType); ok {
+		if filename, ok := j.collections.UploadedArtwork(i.ID(), artworkType); ok {
+			j.serveImageFile(w, r, c, filename, artworkType)
+			return
+		}
+	}
 
 	switch strings.ToLower(imageType) {
 	case "primary":
 		if i.Poster() != "" {
-			j.serveImageFile(w, r, c.Directory+"/"+i.Path()+"/"+i.Poster(), j.imageQualityPoster)
+			j.serveImageFile(w, r, c, c.ItemDirectory(i)+"/"+i.Poster(), collection.ArtworkPoster)
+			return
+		}
+		// Episodes without thumb image can have a thumbnail extracted from the video
+		if thumbnail, ok := j.collections.Thumbnail(i.ID()); ok {
+			j.serveImageFile(w, r, c, thumbnail, collection.ArtworkPoster)
 			return
 		}
 		// todo implement fallback options:
@@ -96,14 +122,14 @@ func (j *Jellyfin) itemsImagesGetHandler(w http.ResponseWriter, r *http.Request)
 		return
 	case "backdrop":
 		if i.Fanart() != "" {
-			j.serveFile(w, r, c.Directory+"/"+i.Path()+"/"+i.Fanart())
+			j.serveImageFile(w, r, c, c.ItemDirectory(i)+"/"+i.Fanart(), collection.ArtworkFanart)
 			return
 		}
 		apierror(w, "Backdrop not found", http.StatusNotFound)
 		return
 	case "logo":
 		if i.Logo() != "" {
-			j.serveImageFile(w, r, c.Directory+"/"+i.Path()+"/"+i.Logo(), j.imageQualityPoster)
+			j.serveImageFile(w, r, c, c.ItemDirectory(i)+"/"+i.Logo(), collection.ArtworkLogo)
 			return
 		}
 		apierror(w, "Logo not found", http.StatusNotFound)
@@ -115,8 +141,18 @@ func (j *Jellyfin) itemsImagesGetHandler(w http.ResponseWriter, r *http.Request)
 
 // POST /Items/{item}/Images/{type}
 //
-// itemsImagesPostHandler stores item images like posters, backdrops and logos
+// itemsImagesPostHandler stores uploaded item images like posters, backdrops and logos. Images of movies,
+// shows, seasons and episodes are stored as artwork and used instead of the images found by the scanner.
+// Other items such as collections only have a primary image, which is stored in the database.
 func (j *Jellyfin) itemsImagesPostHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can upload images", http.StatusForbidden)
+		return
+	}
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
 	imageType := vars["type"]
@@ -126,12 +162,52 @@ func (j *Jellyfin) itemsImagesPostHandler(w http.ResponseWriter, r *http.Request
 		apierror(w, "itemId parameter is required", http.StatusBadRequest)
 		return
 	}
-	// We do not check item type, so collections, shows, seasons and episodes can all have images uploaded.
-	if strings.ToLower(imageType) != "primary" {
-		apierror(w, "Only primary images can be uploaded", http.StatusBadRequest)
+	c, i := j.collections.GetItemByID(trimPrefix(itemID))
+	if i == nil {
+		if strings.ToLower(imageType) != "primary" {
+			apierror(w, "Only primary images can be uploaded", http.StatusBadRequest)
+			return
+		}
+		j.receiveItemImage(w, r, itemID, imageType)
 		return
 	}
-	j.receiveItemImage(w, r, itemID, imageType)
+	artworkType, ok := artworkType(imageType)
+	if !ok {
+		apierror(w, "Unsupported image type", http.StatusBadRequest)
+		return
+	}
+	imageData, _, ok := readImageUpload(w, r)
+	if !ok {
+		return
+	}
+	j.storeArtwork(w, c, i, artworkType, imageData)
+}
+
+// storeArtwork stores a jpeg or png image as artwork of an item.
+func (j *Jellyfin) storeArtwork(w http.ResponseWriter, c *collection.Collection, i collection.Item, artworkType string, imageData []byte) {
+	var ext string
+	switch http.DetectContentType(imageData) {
+	case "image/jpeg":
+		ext = "jpg"
+	case "image/png":
+		ext = "png"
+	default:
+		apierror(w, "Only jpeg and png images can be stored", http.StatusBadRequest)
+		return
+	}
+	filename, err := j.collections.ArtworkFilename(c, i, artworkType, ext)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Resized versions of the current image are stale once it is replaced
+	j.imageresizer.Invalidate(filename)
+	if err := j.collections.StoreArtwork(i.ID(), artworkType, filename, imageData); err != nil {
+		log.Printf("Storing image of item %s failed: %s", i.ID(), err)
+		apierror(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // GET /Users/{user}/Images/{type}
@@ -169,6 +245,9 @@ func (j *Jellyfin) userImagePostHandler(w http.ResponseWriter, r *http.Request)
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 	queryParams := r.URL.Query()
 	userID := queryParams.Get("userId")
 	if userID == "" {
@@ -190,6 +269,9 @@ func (j *Jellyfin) userImageDeleteHandler(w http.ResponseWriter, r *http.Request
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 	queryParams := r.URL.Query()
 	userID := queryParams.Get("userId")
 	if userID == "" {
@@ -282,78 +364,82 @@ func (j *Jellyfin) itemsImagesHandler(w http.ResponseWriter, r *http.Request) {
 		apierror(w, "Item not found", http.StatusNotFound)
 		return
 	}
+	uploaded := func(artworkType string) bool {
+		_, ok := j.collections.UploadedArtwork(i.ID(), artworkType)
+		return ok
+	}
 	var images []JFResponseItemImages
 	index := 0
-	if i.Poster() != "" {
+	if _, ok := j.collections.Thumbnail(i.ID()); i.Poster() != "" || ok || uploaded(collection.ArtworkPoster) {
 		images = append(images, JFResponseItemImages{ImageIndex: index, ImageType: "Primary", ImageTag: i.ID()})
 		index++
 	}
-	if i.Banner() != "" {
+	if i.Fanart() != "" || uploaded(collection.ArtworkFanart) {
 		images = append(images, JFResponseItemImages{ImageIndex: index, ImageType: "Backdrop", ImageTag: i.ID()})
 		index++
 	}
-	if i.Logo() != "" {
+	if i.Logo() != "" || uploaded(collection.ArtworkLogo) {
 		images = append(images, JFResponseItemImages{ImageIndex: index, ImageType: "Logo", ImageTag: i.ID()})
 		index++
 	}
 	serveJSON(images, w)
 }
 
-// /Items/{item}/RemoteImages
-//
-// itemsRemoteImagesHandler returns a list of remote images for an item
-func (j *Jellyfin) itemsRemoteImagesHandler(w http.ResponseWriter, r *http.Request) {
-	response := JFResponseItemRemoteImages{
-		Images:           []JFResponseItemRemoteImagesImage{},
-		TotalRecordCount: 0,
+// receiveItemImage reads image data from the request and stores it in the repository
+func (j *Jellyfin) receiveItemImage(w http.ResponseWriter, r *http.Request, userID, imageType string) {
+	imageData, mimeType, ok := readImageUpload(w, r)
+	if !ok {
+		return
 	}
-	serveJSON(response, w)
-}
-
-// /Items/episode_c2y4g6NdjoX23XPWnjJv/RemoteImages/Providers
-//
-// itemsRemoteImagesProvidersHandler returns a list of remote image providers for an item
-func (j *Jellyfin) itemsRemoteImagesProvidersHandler(w http.ResponseWriter, r *http.Request) {
-	response := JFResponseItemRemoteImagesProviders{
-		{
-			Name:            "Local Repository",
-			SupportedImages: []string{"Primary"},
-		},
-	}
-	serveJSON(response, w)
+	metadata := model.ImageMetadata{
+		MimeType: mimeType,
+		FileSize: len(imageData),
+		Etag:     idhash.HashBytes(imageData),
+		Updated:  time.Now().UTC(),
+	}
+	if err := j.repo.StoreImage(r.Context(), userID, imageType, metadata, imageData); err != nil {
+		apierror(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// receiveItemImage reads image data from the request and stores it in the repository
-func (j *Jellyfin) receiveItemImage(w http.ResponseWriter, r *http.Request, userID, imageType string) {
+// readImageUpload reads uploaded image data from the request and returns it with its mime type.
+func readImageUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 	imageData, err := io.ReadAll(r.Body)
 	if err != nil {
 		apierror(w, "Failed to read image data", http.StatusBadRequest)
-		return
+		return nil, "", false
 	}
 	mimeType := http.DetectContentType(imageData)
 	// If we cannot detect an image mime type, we'll try to decode as Base64 and check again
 	// Some clients like Swiftfin send Base64-encoded data without setting Content-Type..
 	if !strings.HasPrefix(mimeType, "image/") {
-		imageData, err := base64.StdEncoding.DecodeString(string(imageData))
+		imageData, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(imageData)))
 		mimeType = http.DetectContentType(imageData)
 		// Validate it's now a valid image
 		if err != nil || !strings.HasPrefix(mimeType, "image/") {
 			apierror(w, "Uploaded file is not a valid image", http.StatusBadRequest)
-			return
+			return nil, "", false
 		}
 	}
-	metadata := model.ImageMetadata{
-		MimeType: mimeType,
-		FileSize: len(imageData),
-		Etag:     idhash.HashBytes(imageData),
-		Updated:  time.Now().UTC(),
-	}
-	if err = j.repo.StoreImage(r.Context(), userID, imageType, metadata, imageData); err != nil {
-		apierror(w, "Failed to store image", http.StatusInternalServerError)
-		return
+	return imageData, mimeType, true
+}
+
+// artworkType returns the collection artwork type of a Jellyfin image type.
+func artworkType(imageType string) (string, bool) {
+	switch strings.ToLower(imageType) {
+	case "primary":
+		return collection.ArtworkPoster, true
+	case "backdrop":
+		return collection.ArtworkFanart, true
+	case "banner":
+		return collection.ArtworkBanner, true
+	case "logo":
+		return collection.ArtworkLogo, true
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return "", false
 }
 
 // makeJFImageTags checks if an item has an image and returns the appropriate JFImageTags
@@ -375,16 +461,24 @@ func (j *Jellyfin) serveItemImage(w http.ResponseWriter, r *http.Request, itemID
 		apierror(w, "Failed to retrieve image", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("etag", metadata.Etag)
+	w.Header().Set("etag", `"`+strings.Trim(metadata.Etag, `"`)+`"`)
 	w.Header().Set("content-type", metadata.MimeType)
 	w.Header().Set("content-length", fmt.Sprintf("%d", metadata.FileSize))
 	w.Header().Set("last-modified", metadata.Updated.Format(http.TimeFormat))
 	http.ServeContent(w, r, "", metadata.Updated, bytes.NewReader(imageData))
 }
 
-// serveImageFile serves an image file from the filesystem
-func (j *Jellyfin) serveImageFile(w http.ResponseWriter, r *http.Request, filename string, imageQuality int) {
-	file, err := j.imageresizer.OpenFile(w, r, filename, imageQuality)
+// serveImageFile serves an image file of a collection from the filesystem
+func (j *Jellyfin) serveImageFile(w http.ResponseWriter, r *http.Request, c *collection.Collection, filename, artworkType string) {
+	if !j.collections.Registered(filename) {
+		apierror(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if j.storageOffline(w, filename) {
+		return
+	}
+	params, imageQuality := j.collectionImageParams(c, artworkType, r.URL.Query())
+	file, ctype, err := j.imageresizer.Open(filename, params, imageQuality)
 	if err != nil {
 		apierror(w, "File not found", http.StatusNotFound)
 		return
@@ -396,13 +490,98 @@ func (j *Jellyfin) serveImageFile(w http.ResponseWriter, r *http.Request, filena
 		apierror(w, "Could not retrieve file info", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("etag", fileStat.ModTime().Format("20060102150405"))
-	w.Header().Set("content-type", mimeTypeByExtension(filename))
+	// Strong etag based upon content, so clients can revalidate using If-None-Match.
+	// HEAD requests only get an etag if known already, to not read the image.
+	if r.Method == http.MethodHead {
+		if etag, ok := j.imageresizer.CachedETag(file, filename, params.Encode()); ok {
+			w.Header().Set("etag", etag)
+		}
+	} else if etag, err := j.imageresizer.ETag(file, filename, params.Encode()); err == nil {
+		w.Header().Set("etag", etag)
+	}
+	// Images can be converted to another format
+	if ctype != "" {
+		w.Header().Set("content-type", mimeTypeByExtension("."+ctype))
+	} else {
+		w.Header().Set("content-type", mimeTypeByExtension(filename))
+	}
 	w.Header().Set("content-length", fmt.Sprintf("%d", fileStat.Size()))
 	w.Header().Set("last-modified", fileStat.ModTime().Format(http.TimeFormat))
 	http.ServeContent(w, r, fileStat.Name(), fileStat.ModTime(), file)
 }
 
+// collectionImageParams returns the image resizer parameters and quality of an image of a collection,
+// based upon the request query parameters and the image options of the collection. Quality is 0 for
+// backdrops without configured quality, so they are served as is unless resized.
+func (j *Jellyfin) collectionImageParams(c *collection.Collection, artworkType string, query url.Values) (url.Values, int) {
+	params := imageResizeParams(query)
+	j.bucketImageWidth(params)
+	quality := j.posterImageQuality()
+	if artworkType == collection.ArtworkFanart {
+		quality = 0
+	}
+	if c == nil {
+		return params, quality
+	}
+
+	options := c.Images
+	if artworkType == collection.ArtworkFanart {
+		quality = options.BackdropQuality
+	} else if options.PosterQuality > 0 {
+		quality = options.PosterQuality
+	}
+	limitImageSize(params, "w", "h", "mw", options.MaxWidth)
+	limitImageSize(params, "h", "w", "mh", options.MaxHeight)
+	if options.Format == collection.ImageFormatJPEG {
+		params.Set("f", "jpg")
+	}
+	if len(params) > 0 && params.Get("q") == "" {
+		params.Set("q", strconv.Itoa(defaultImageQuality))
+	}
+	return params, quality
+}
+
+// limitImageSize limits size parameter param of the image resizer to limit pixels, the other
+// dimension is scaled along. maxParam is set to the limit, so images without requested size
+// are scaled down as well.
+func limitImageSize(params url.Values, param, otherParam, maxParam string, limit int) {
+	if limit <= 0 {
+		return
+	}
+	if size, _ := strconv.Atoi(params.Get(param)); size > limit {
+		if other, _ := strconv.Atoi(params.Get(otherParam)); other > 0 {
+			params.Set(otherParam, strconv.Itoa(other*limit/size))
+		}
+		params.Set(param, strconv.Itoa(limit))
+	}
+	if size, _ := strconv.Atoi(params.Get(maxParam)); size == 0 || size > limit {
+		params.Set(maxParam, strconv.Itoa(limit))
+	}
+}
+
+// imageResizeParams translates the image size query parameters of a request into
+// the parameters of the image resizer. Fill sizes are treated as maximum sizes.
+func imageResizeParams(query url.Values) url.Values {
+	params := url.Values{}
+	for _, p := range []struct{ param, resizerParam string }{
+		{"width", "w"},
+		{"height", "h"},
+		{"fillWidth", "mw"},
+		{"fillHeight", "mh"},
+		{"maxWidth", "mw"},
+		{"maxHeight", "mh"},
+	} {
+		if value := query.Get(p.param); value != "" {
+			params.Set(p.resizerParam, value)
+		}
+	}
+	// Quality requested by clients varies, using a fixed quality improves cache hits
+	if len(params) > 0 {
+		params.Set("q", strconv.Itoa(defaultImageQuality))
+	}
+	return params
+}
+
 // mimeTypeByExtension returns the mime type based on the file extension
 func mimeTypeByExtension(filename string) string {
 	switch strings.ToLower(path.Ext(filename)) {
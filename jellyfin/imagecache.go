@@ -0,0 +1,82 @@
+package jellyfin
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// JFImageCacheResponse is the state of the image cache.
+type JFImageCacheResponse struct {
+	Hits     int64   `json:"Hits"`
+	Misses   int64   `json:"Misses"`
+	HitRatio float64 `json:"HitRatio"`
+	Files    int     `json:"Files"`
+	Size     int64   `json:"Size"`
+	Buckets  []int   `json:"Buckets"`
+}
+
+// GET /Jellofin/ImageCache
+//
+// imageCacheHandler returns the hit ratio of the image cache since startup and its disk usage,
+// to tune the image width buckets. Administrators only.
+func (j *Jellyfin) imageCacheHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view the image cache", http.StatusForbidden)
+		return
+	}
+	stats := j.imageresizer.CacheStats()
+	response := JFImageCacheResponse{
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		HitRatio: stats.HitRatio(),
+		Files:    stats.Files,
+		Size:     stats.Size,
+		Buckets:  j.imageWidthBuckets,
+	}
+	if response.Buckets == nil {
+		response.Buckets = []int{}
+	}
+	serveJSON(response, w)
+}
+
+// bucketImageWidth rounds the requested widths of the image resizer up to the nearest width
+// bucket, so clients asking for slightly different sizes share cache entries. The height
+// requested along with a width is scaled along to keep the requested aspect ratio.
+func (j *Jellyfin) bucketImageWidth(params url.Values) {
+	if len(j.imageWidthBuckets) == 0 {
+		return
+	}
+	for _, p := range []struct{ width, height string }{
+		{"w", "h"},
+		{"mw", "mh"},
+	} {
+		width, _ := strconv.Atoi(params.Get(p.width))
+		if width <= 0 {
+			continue
+		}
+		bucket := imageWidthBucket(j.imageWidthBuckets, width)
+		if bucket == width {
+			continue
+		}
+		if height, _ := strconv.Atoi(params.Get(p.height)); height > 0 {
+			params.Set(p.height, strconv.Itoa(height*bucket/width))
+		}
+		params.Set(p.width, strconv.Itoa(bucket))
+	}
+}
+
+// imageWidthBucket returns the smallest bucket at least as wide as width, the largest
+// bucket if width is larger than all. Buckets are sorted in ascending order.
+func imageWidthBucket(buckets []int, width int) int {
+	i, _ := slices.BinarySearch(buckets, width)
+	if i == len(buckets) {
+		return buckets[len(buckets)-1]
+	}
+	return buckets[i]
+}
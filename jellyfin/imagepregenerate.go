@@ -0,0 +1,236 @@
+package jellyfin
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+)
+
+const (
+	// imagePregenerateInterval is the wait time between runs of the image pre-generation task.
+	imagePregenerateInterval = 6 * time.Hour
+	// imagePregenerateTaskID is the id of the image pre-generation task in the scheduled tasks API.
+	imagePregenerateTaskID = "b8a5c3e1f0d94a7e9c2b6d4f1e3a5c7b"
+)
+
+// imagePregenerateSizes are the image sizes most clients request, per image type.
+// They are passed through collectionImageParams, so they produce the same cache entries
+// as client requests with the same query parameters.
+var imagePregenerateSizes = map[string][]url.Values{
+	imageTypePrimary: {
+		// list view
+		{"fillWidth": {"300"}, "fillHeight": {"446"}},
+		// detail view
+		{"maxWidth": {"600"}},
+	},
+	"Backdrop": {
+		// list view
+		{"maxWidth": {"780"}},
+		// detail view
+		{"maxWidth": {"1920"}},
+	},
+}
+
+// backgroundTask is the state of a task that runs in the background, e.g. image pre-generation.
+type backgroundTask struct {
+	mu      sync.Mutex
+	running bool
+	start   time.Time
+	end     time.Time
+	status  string
+	// trigger starts a run outside of the interval
+	trigger chan struct{}
+}
+
+// imagePregenerateJob is a single image to resize.
+type imagePregenerateJob struct {
+	filename string
+	params   url.Values
+	quality  int
+}
+
+// ImagePregenerateBackground keeps pre-generating resized posters and backdrops in the
+// sizes most clients request, so new devices scrolling the library are served from cache.
+func (j *Jellyfin) ImagePregenerateBackground(ctx context.Context) {
+	for {
+		j.pregenerateImages(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.imageTask.trigger:
+		case <-time.After(imagePregenerateInterval):
+		}
+	}
+}
+
+// triggerImagePregenerate starts a pre-generation run, returns false if a run is already in progress.
+func (j *Jellyfin) triggerImagePregenerate() bool {
+	j.imageTask.mu.Lock()
+	running := j.imageTask.running
+	j.imageTask.mu.Unlock()
+	if running {
+		return false
+	}
+	select {
+	case j.imageTask.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pregenerateImages resizes the images of all items using a pool of workers.
+func (j *Jellyfin) pregenerateImages(ctx context.Context) {
+	j.imageTask.mu.Lock()
+	j.imageTask.running = true
+	j.imageTask.start = time.Now().UTC()
+	j.imageTask.mu.Unlock()
+
+	jobs := make(chan imagePregenerateJob)
+	var wg sync.WaitGroup
+	var failed, generated int
+	var countMu sync.Mutex
+	for range max(runtime.NumCPU()/2, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := j.imageresizer.Pregenerate(job.filename, job.params, job.quality)
+				countMu.Lock()
+				if err != nil {
+					log.Printf("Image pre-generation of %s failed: %s", job.filename, err)
+					failed++
+				} else {
+					generated++
+				}
+				countMu.Unlock()
+			}
+		}()
+	}
+
+	canceled := false
+	for _, c := range j.collections.GetCollections() {
+		for _, i := range c.Items {
+			if !j.queueItemImages(ctx, jobs, c, i) {
+				canceled = true
+				break
+			}
+		}
+		if canceled {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	status := "Completed"
+	if canceled {
+		status = "Cancelled"
+	} else if failed > 0 {
+		status = "CompletedWithErrors"
+	}
+	log.Printf("Image pre-generation %s, %d images, %d failed", status, generated, failed)
+
+	j.imageTask.mu.Lock()
+	j.imageTask.running = false
+	j.imageTask.end = time.Now().UTC()
+	j.imageTask.status = status
+	j.imageTask.mu.Unlock()
+}
+
+// queueItemImages queues the images of an item, and of the seasons and episodes of a show.
+// Returns false if the context got canceled.
+func (j *Jellyfin) queueItemImages(ctx context.Context, jobs chan<- imagePregenerateJob, c *collection.Collection, i collection.Item) bool {
+	dir := c.ItemDirectory(i)
+	images := map[string]string{}
+	if uploaded, ok := j.collections.UploadedArtwork(i.ID(), collection.ArtworkPoster); ok {
+		images[imageTypePrimary] = uploaded
+	} else if i.Poster() != "" {
+		images[imageTypePrimary] = dir + "/" + i.Poster()
+	} else if thumbnail, ok := j.collections.Thumbnail(i.ID()); ok {
+		images[imageTypePrimary] = thumbnail
+	}
+	if uploaded, ok := j.collections.UploadedArtwork(i.ID(), collection.ArtworkFanart); ok {
+		images["Backdrop"] = uploaded
+	} else if i.Fanart() != "" {
+		images["Backdrop"] = dir + "/" + i.Fanart()
+	}
+	for imageType, filename := range images {
+		// Same parameters as used by itemsImagesGetHandler
+		artworkType := collection.ArtworkPoster
+		if imageType != imageTypePrimary {
+			artworkType = collection.ArtworkFanart
+		}
+		for _, size := range imagePregenerateSizes[imageType] {
+			params, quality := j.collectionImageParams(c, artworkType, size)
+			job := imagePregenerateJob{
+				filename: filename,
+				params:   params,
+				quality:  quality,
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case jobs <- job:
+			}
+		}
+	}
+
+	show, ok := i.(*collection.Show)
+	if !ok {
+		return true
+	}
+	for si := range show.Seasons {
+		season := &show.Seasons[si]
+		if !j.queueItemImages(ctx, jobs, c, season) {
+			return false
+		}
+		for ei := range season.Episodes {
+			if !j.queueItemImages(ctx, jobs, c, &season.Episodes[ei]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// imageTaskResponse returns the image pre-generation task as scheduled task.
+func (j *Jellyfin) imageTaskResponse() JFScheduledTasksResponse {
+	j.imageTask.mu.Lock()
+	defer j.imageTask.mu.Unlock()
+
+	const name = "Pre-generate images"
+	const key = "PregenerateImages"
+	response := JFScheduledTasksResponse{
+		Name:        name,
+		State:       "Idle",
+		ID:          imagePregenerateTaskID,
+		Description: "Resizes posters and backdrops into the sizes most clients request.",
+		Category:    "Library",
+		Key:         key,
+		Triggers: []ScheduledTaskTrigger{
+			{
+				Type:          "IntervalTrigger",
+				IntervalTicks: int64(imagePregenerateInterval / 100),
+			},
+		},
+	}
+	if j.imageTask.running {
+		response.State = "Running"
+	}
+	if !j.imageTask.end.IsZero() {
+		response.LastExecutionResult = ScheduledTaskLastExecutionResult{
+			StartTimeUtc: j.imageTask.start,
+			EndTimeUtc:   j.imageTask.end,
+			Status:       j.imageTask.status,
+			Name:         name,
+			Key:          key,
+			ID:           imagePregenerateTaskID,
+		}
+	}
+	return response
+}
@@ -0,0 +1,175 @@
+package jellyfin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+const (
+	// introDetectionInterval is the wait time between runs of the intro detection task.
+	introDetectionInterval = 24 * time.Hour
+	// introDetectionTaskID is the id of the intro detection task in the scheduled tasks API.
+	introDetectionTaskID = "e2c4a6f8b0d14c3e9a5b7d9f1c3e5a70"
+	// mediaSegmentTypeIntro is the media segment type of intros.
+	mediaSegmentTypeIntro = "Intro"
+)
+
+// JFMediaSegment is a segment of an item, such as its intro.
+type JFMediaSegment struct {
+	ID         string `json:"Id"`
+	ItemID     string `json:"ItemId"`
+	Type       string `json:"Type"`
+	StartTicks int64  `json:"StartTicks"`
+	EndTicks   int64  `json:"EndTicks"`
+}
+
+// JFMediaSegmentsResponse is the list of media segments of an item.
+type JFMediaSegmentsResponse struct {
+	Items            []JFMediaSegment `json:"Items"`
+	TotalRecordCount int              `json:"TotalRecordCount"`
+	StartIndex       int              `json:"StartIndex"`
+}
+
+// /MediaSegments/NrXTYiS6xAxFj4QAiJoT
+//
+// Supported query params:
+// - includeSegmentTypes, segment types to return, e.g. Intro
+//
+// mediaSegmentsHandler returns the intro, commercial, preview, recap and outro segments of an item.
+// Only intros found by the intro detection task are available.
+func (j *Jellyfin) mediaSegmentsHandler(w http.ResponseWriter, r *http.Request) {
+	itemID := trimPrefix(mux.Vars(r)["itemid"])
+	response := JFMediaSegmentsResponse{
+		Items: []JFMediaSegment{},
+	}
+	if intro, ok := j.collections.Intro(itemID); ok && includeSegmentType(r, mediaSegmentTypeIntro) {
+		response.Items = append(response.Items, JFMediaSegment{
+			ID:         idhash.IdHash(itemID + "-" + mediaSegmentTypeIntro),
+			ItemID:     itemID,
+			Type:       mediaSegmentTypeIntro,
+			StartTicks: makeRuntimeTicks(intro.Start),
+			EndTicks:   makeRuntimeTicks(intro.End),
+		})
+	}
+	response.TotalRecordCount = len(response.Items)
+	serveJSON(response, w)
+}
+
+// includeSegmentType returns true if a media segment type is requested, all types are if none are provided.
+func includeSegmentType(r *http.Request, segmentType string) bool {
+	types := r.URL.Query()["includeSegmentTypes"]
+	if len(types) == 0 {
+		return true
+	}
+	for _, entry := range types {
+		for t := range strings.SplitSeq(entry, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), segmentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IntroDetectionBackground searches the intros of new episodes, if intro detection is enabled.
+func (j *Jellyfin) IntroDetectionBackground(ctx context.Context) {
+	if !j.introDetection {
+		return
+	}
+	for {
+		j.detectIntros(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.introTask.trigger:
+		case <-time.After(introDetectionInterval):
+		}
+	}
+}
+
+// triggerIntroDetection starts an intro detection run, returns false if a run is already in progress.
+func (j *Jellyfin) triggerIntroDetection() bool {
+	j.introTask.mu.Lock()
+	running := j.introTask.running
+	j.introTask.mu.Unlock()
+	if running {
+		return false
+	}
+	select {
+	case j.introTask.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// detectIntros searches the intros of episodes that have not been analyzed yet.
+func (j *Jellyfin) detectIntros(ctx context.Context) {
+	j.introTask.mu.Lock()
+	j.introTask.running = true
+	j.introTask.start = time.Now().UTC()
+	j.introTask.mu.Unlock()
+
+	status := "Completed"
+	result, err := j.collections.DetectIntros(ctx)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		status = "Cancelled"
+	case err != nil:
+		log.Printf("Intro detection failed: %s", err)
+		status = "Failed"
+	case result.Failed > 0:
+		status = "CompletedWithErrors"
+	}
+	log.Printf("Intro detection %s, %d seasons, %d episodes, %d intros found, %d failed",
+		status, result.Seasons, result.Episodes, result.Detected, result.Failed)
+
+	j.introTask.mu.Lock()
+	j.introTask.running = false
+	j.introTask.end = time.Now().UTC()
+	j.introTask.status = status
+	j.introTask.mu.Unlock()
+}
+
+// introTaskResponse returns the intro detection task as scheduled task.
+func (j *Jellyfin) introTaskResponse() JFScheduledTasksResponse {
+	j.introTask.mu.Lock()
+	defer j.introTask.mu.Unlock()
+
+	const name = "Detect intros"
+	const key = "DetectIntros"
+	response := JFScheduledTasksResponse{
+		Name:        name,
+		State:       "Idle",
+		ID:          introDetectionTaskID,
+		Description: "Finds the intros of episodes by comparing the audio of episodes of a season, so clients can skip them.",
+		Category:    "Library",
+		Key:         key,
+		Triggers: []ScheduledTaskTrigger{
+			{
+				Type:          "IntervalTrigger",
+				IntervalTicks: int64(introDetectionInterval / 100),
+			},
+		},
+	}
+	if j.introTask.running {
+		response.State = "Running"
+	}
+	if !j.introTask.end.IsZero() {
+		response.LastExecutionResult = ScheduledTaskLastExecutionResult{
+			StartTimeUtc: j.introTask.start,
+			EndTimeUtc:   j.introTask.end,
+			Status:       j.introTask.status,
+			Name:         name,
+			Key:          key,
+			ID:           introDetectionTaskID,
+		}
+	}
+	return response
+}
@@ -1,22 +1,29 @@
 package jellyfin
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
 )
 
 // /Items/f137a2dd21bbc1b99aa5c0f6bf02a805
@@ -33,10 +40,18 @@ func (j *Jellyfin) usersItemHandler(w http.ResponseWriter, r *http.Request) {
 	itemID := vars["itemid"]
 
 	response, err := j.makeJFItemByID(r.Context(), reqCtx.User.ID, itemID)
+	if errors.Is(err, errPinRequired) {
+		apierror(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		apierror(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	if len(j.applyParentalRating([]JFItem{response}, reqCtx.User)) == 0 {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
 	serveJSON(response, w)
 }
 
@@ -48,6 +63,9 @@ func (j *Jellyfin) usersItemHandler(w http.ResponseWriter, r *http.Request) {
 // Supported query params:
 // - parentId, if provided scope result set to this collection
 // - searchTerm, search term to match items against
+// - anyProviderIdEquals, comma separated list of provider ids to match, e.g. imdb.tt0111161
+// - imdbId, tmdbId, tvdbId, provider id to match
+// - ids, comma separated list of item ids to return in requested order, other filters are ignored
 // - startIndex, index of first result item
 // - limit=50, number of items to return
 func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
@@ -59,15 +77,36 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 	queryparams := r.URL.Query()
 	parentID := queryparams.Get("parentId")
 	searchTerm := queryparams.Get("searchTerm")
+	if !validSearchTerm(w, searchTerm) {
+		return
+	}
+	// Searches and listing all items build every item of the collections
+	if searchTerm != "" || (parentID == "" && strings.EqualFold(queryparams.Get("recursive"), "true")) {
+		release, ok := j.guardItemQuery(w, r, reqCtx)
+		if !ok {
+			return
+		}
+		defer release()
+	}
 
 	var items []JFItem
 	var err error
 
-	if searchTerm == "" {
+	// Provider ids are looked up in the index, parentId is applied by applyItemFilter() later on.
+	providerIDs := parseProviderIDs(queryparams)
+	if len(providerIDs) > 0 {
+		items = j.makeJFItemsByProviderIDs(r.Context(), reqCtx.User.ID, providerIDs)
+	}
+
+	if searchTerm == "" && len(providerIDs) == 0 {
 		if parentID != "" {
 			// Get list of items based upon provided parentID, this means
 			// we are fetching items for a specific collection, season or series.
 			items, err = j.getJFItemsByParentID(r.Context(), reqCtx.User.ID, parentID)
+			if errors.Is(err, errPinRequired) {
+				apierror(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			if err != nil {
 				apierror(w, err.Error(), http.StatusNotFound)
 				return
@@ -85,7 +124,12 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 			// (1) Handle provided "ids", we fetch these directly by ID.
 			var itemsFetchedByIDs bool
 			if ids := queryparams.Get("ids"); ids != "" {
-				items, err = j.makeJFItemByIDs(r.Context(), reqCtx.User.ID, strings.Split(ids, ","))
+				itemIDs := strings.Split(ids, ",")
+				if j.maxItems > 0 && len(itemIDs) > j.maxItems {
+					log.Printf("usersItemsHandler: limiting %d requested ids to %d", len(itemIDs), j.maxItems)
+					itemIDs = itemIDs[:j.maxItems]
+				}
+				items, err = j.makeJFItemByIDs(r.Context(), reqCtx.User.ID, itemIDs)
 				if err != nil {
 					apierror(w, err.Error(), http.StatusInternalServerError)
 					return
@@ -97,17 +141,29 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 				queryparams.Del("ids")
 			}
 
-			// (2) Get top-level collection items if no items found by IDs
-			if !itemsFetchedByIDs {
-				items, err = j.makeJFCollectionRootOverview(r.Context(), reqCtx.User.ID)
-				if err != nil {
-					apierror(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
+			// Items fetched by ID are returned in requested order, without filtering and sorting,
+			// as clients use this to fetch details of items they already know.
+			if itemsFetchedByIDs {
+				items = j.applyParentalRating(items, reqCtx.User)
+				items = j.applyHiddenItems(items, reqCtx.User)
+				items = j.applyPinLock(r.Context(), items)
+				serveJSON(UserItemsResponse{
+					Items:            items,
+					StartIndex:       0,
+					TotalRecordCount: len(items),
+				}, w)
+				return
+			}
+
+			// (2) Get top-level collection items as no items were found by IDs
+			items, err = j.makeJFCollectionRootOverview(r.Context(), reqCtx.User.ID)
+			if err != nil {
+				apierror(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
 
 			// (3) No items found so far, add all media items recursively
-			if !itemsFetchedByIDs && strings.EqualFold(queryparams.Get("recursive"), "true") {
+			if strings.EqualFold(queryparams.Get("recursive"), "true") {
 				allitems, err := j.getJFItemsAll(r.Context(), reqCtx.User.ID)
 				if err != nil {
 					apierror(w, err.Error(), http.StatusNotFound)
@@ -119,7 +175,7 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If searchTerm is provided, filter items based on search results
-	if searchTerm != "" {
+	if searchTerm != "" && len(providerIDs) == 0 {
 		// If searchTerm is provided we search in whole collection,
 		// applyItemFilter() will take care of parentID filtering
 		foundItemIDs, err := j.collections.SearchItem(r.Context(), searchTerm)
@@ -142,6 +198,9 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
 
 	totalItemCount := len(items)
 	responseItems, startIndex := j.applyItemPaginating(j.applyItemSorting(items, queryparams), queryparams)
@@ -153,6 +212,56 @@ func (j *Jellyfin) usersItemsHandler(w http.ResponseWriter, r *http.Request) {
 	serveJSON(response, w)
 }
 
+// providerID is a provider name and id, e.g. "imdb" and "tt0111161".
+type providerID struct {
+	provider string
+	id       string
+}
+
+// parseProviderIDs returns the provider ids to lookup from query params
+// anyProviderIdEquals, imdbId, tmdbId and tvdbId. These are removed from
+// the query params as applyItemsFilter() should not act on them.
+func parseProviderIDs(queryparams url.Values) []providerID {
+	var ids []providerID
+	for _, entry := range queryparams["anyProviderIdEquals"] {
+		for value := range strings.SplitSeq(entry, ",") {
+			if provider, id, ok := strings.Cut(strings.TrimSpace(value), "."); ok && id != "" {
+				ids = append(ids, providerID{provider: provider, id: id})
+			}
+		}
+	}
+	queryparams.Del("anyProviderIdEquals")
+	for param, provider := range map[string]string{"imdbId": "imdb", "tmdbId": "tmdb", "tvdbId": "tvdb"} {
+		if id := queryparams.Get(param); id != "" {
+			ids = append(ids, providerID{provider: provider, id: id})
+		}
+		queryparams.Del(param)
+	}
+	return ids
+}
+
+// makeJFItemsByProviderIDs returns the items matching any of the provided provider ids.
+func (j *Jellyfin) makeJFItemsByProviderIDs(ctx context.Context, userID string, providerIDs []providerID) []JFItem {
+	seen := make(map[string]bool)
+	items := []JFItem{}
+	for _, p := range providerIDs {
+		for _, itemID := range j.collections.LookupProviderID(p.provider, p.id) {
+			if seen[itemID] {
+				continue
+			}
+			seen[itemID] = true
+			c, i := j.collections.GetItemByID(itemID)
+			if i == nil {
+				continue
+			}
+			if jfitem, err := j.makeJFItem(ctx, userID, i, c.ID); err == nil {
+				items = append(items, jfitem)
+			}
+		}
+	}
+	return items
+}
+
 // /Items/Latest
 // /Users/2b1ec0a52b09456c9823a367d84ac9e5/Items/Latest?Fields=DateCreated,Etag,Genres,MediaSources,AlternateMediaSources,Overview,ParentId,Path,People,ProviderIds,SortName,RecursiveItemCount,ChildCount&ParentId=f137a2dd21bbc1b99aa5c0f6bf02a805&StartIndex=0&Limit=20
 //
@@ -184,6 +293,11 @@ func (j *Jellyfin) usersItemsLatestHandler(w http.ResponseWriter, r *http.Reques
 			return
 		}
 	} else {
+		release, ok := j.guardItemQuery(w, r, reqCtx)
+		if !ok {
+			return
+		}
+		defer release()
 		// All items recursively
 		items, err = j.getJFItemsAll(r.Context(), reqCtx.User.ID)
 		if err != nil {
@@ -193,6 +307,9 @@ func (j *Jellyfin) usersItemsLatestHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
 
 	// Sort by premieredate to list most recent releases first
 	sort.SliceStable(items, func(i, j int) bool {
@@ -240,10 +357,23 @@ func (j *Jellyfin) searchHintsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validSearchTerm(w, queryparams.Get("searchTerm")) {
+		return
+	}
+	release, ok := j.guardItemQuery(w, r, reqCtx)
+	if !ok {
+		return
+	}
+	defer release()
+
 	var searchC *collection.Collection
 	if parentID != "" {
 		collectionid := strings.TrimPrefix(parentID, itemprefix_collection)
 		searchC = j.collections.GetCollection(collectionid)
+		if searchC != nil && j.pinLocked(r.Context(), searchC.ID) {
+			apierror(w, errPinRequired.Error(), http.StatusForbidden)
+			return
+		}
 	}
 
 	items := make([]JFItem, 0)
@@ -252,6 +382,9 @@ func (j *Jellyfin) searchHintsHandler(w http.ResponseWriter, r *http.Request) {
 		if searchC != nil && searchC.ID != c.ID {
 			continue
 		}
+		if j.pinLocked(r.Context(), c.ID) {
+			continue
+		}
 
 		for _, i := range c.Items {
 			jfitem, err := j.makeJFItem(r.Context(), reqCtx.User.ID, i, c.ID)
@@ -265,6 +398,9 @@ func (j *Jellyfin) searchHintsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+
 	totalItemCount := len(items)
 	searchItems, _ := j.applyItemPaginating(j.applyItemSorting(items, queryparams), queryparams)
 
@@ -346,9 +482,15 @@ func (j *Jellyfin) usersItemsResumeHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	thresholds := j.resumeThresholds(reqCtx.User)
 	items := make([]JFItem, 0, len(resumeItemIDs))
 	for _, id := range resumeItemIDs {
 		if c, i := j.collections.GetItemByID(id); c != nil && i != nil {
+			// Skip items of which the resume position is outside the resume thresholds
+			playstate, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, id)
+			if err != nil || !thresholds.resumable(playstate, int64(i.Duration().Seconds())) {
+				continue
+			}
 			jfitem, err := j.makeJFItem(r.Context(), reqCtx.User.ID, i, c.ID)
 			if err != nil {
 				apierror(w, err.Error(), http.StatusInternalServerError)
@@ -362,6 +504,10 @@ func (j *Jellyfin) usersItemsResumeHandler(w http.ResponseWriter, r *http.Reques
 		log.Printf("usersItemsResumeHandler: item %s not found\n", id)
 	}
 
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
+
 	// Apply user provided sorting
 	items = j.applyItemSorting(items, queryparams)
 
@@ -383,7 +529,14 @@ func (j *Jellyfin) usersItemsRefreshHandler(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// /Items/Similar
+const (
+	// similarItemCount is the number of similar items to return.
+	similarItemCount = 15
+	// instantMixDefaultLimit is the number of items returned in an instant mix in case no limit is provided.
+	instantMixDefaultLimit = 100
+)
+
+// /Items/{item}/Similar
 //
 // usersItemsSimilarHandler returns a list of items that are similar
 func (j *Jellyfin) usersItemsSimilarHandler(w http.ResponseWriter, r *http.Request) {
@@ -397,13 +550,7 @@ func (j *Jellyfin) usersItemsSimilarHandler(w http.ResponseWriter, r *http.Reque
 	queryparams := r.URL.Query()
 
 	// We support similar items for movies, series and episodes only.
-	if isJFPersonID(itemID) ||
-		isJFGenreID(itemID) ||
-		isJFStudioID(itemID) ||
-		isJFCollectionID(itemID) ||
-		isJFCollectionFavoritesID(itemID) ||
-		isJFCollectionPlaylistID(itemID) ||
-		isJFRootID(itemID) {
+	if !isJFSimilarItemID(itemID) {
 		response := JFUsersItemsSimilarResponse{
 			Items:            []JFItem{},
 			StartIndex:       0,
@@ -420,24 +567,15 @@ func (j *Jellyfin) usersItemsSimilarHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	similarItemIDs, err := j.collections.Similar(r.Context(), c, i)
+	items, err := j.makeJFSimilarItems(r.Context(), reqCtx.User.ID, c, i, similarItemCount)
 	if err != nil {
 		apierror(w, "Could not get similar items list", http.StatusInternalServerError)
 		return
 	}
-
-	items := make([]JFItem, 0, len(similarItemIDs))
-	for _, id := range similarItemIDs {
-		c, i := j.collections.GetItemByID(id)
-		jfitem, err := j.makeJFItem(r.Context(), reqCtx.User.ID, i, c.ID)
-		if err != nil {
-			apierror(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if j.applyItemFilter(&jfitem, queryparams) {
-			items = append(items, jfitem)
-		}
-	}
+	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
 
 	totalItemCount := len(items)
 	responseItems, startIndex := j.applyItemPaginating(j.applyItemSorting(items, queryparams), queryparams)
@@ -449,6 +587,109 @@ func (j *Jellyfin) usersItemsSimilarHandler(w http.ResponseWriter, r *http.Reque
 	serveJSON(response, w)
 }
 
+// /Items/{item}/InstantMix
+//
+// Supported query params:
+// - limit, number of items to return, defaults to 100
+//
+// itemsInstantMixHandler returns a play queue starting with the item itself,
+// followed by the items that are most similar to it.
+func (j *Jellyfin) itemsInstantMixHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["itemid"]
+	queryparams := r.URL.Query()
+
+	if !isJFSimilarItemID(itemID) {
+		apierror(w, "Instant mix not supported for this item", http.StatusBadRequest)
+		return
+	}
+	c, i := j.collections.GetItemByID(trimPrefix(itemID))
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	seed, err := j.makeJFItem(r.Context(), reqCtx.User.ID, i, c.ID)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit := instantMixDefaultLimit
+	if l, err := strconv.Atoi(queryparams.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	similarItems, err := j.makeJFSimilarItems(r.Context(), reqCtx.User.ID, c, i, limit)
+	if err != nil {
+		apierror(w, "Could not get similar items list", http.StatusInternalServerError)
+		return
+	}
+
+	items := append([]JFItem{seed}, similarItems...)
+	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
+
+	if queryparams.Get("limit") == "" {
+		queryparams.Set("limit", strconv.Itoa(instantMixDefaultLimit))
+	}
+	totalItemCount := len(items)
+	responseItems, startIndex := j.applyItemPaginating(items, queryparams)
+	response := UserItemsResponse{
+		Items:            responseItems,
+		StartIndex:       startIndex,
+		TotalRecordCount: totalItemCount,
+	}
+	serveJSON(response, w)
+}
+
+// isJFSimilarItemID returns true if similar items can be determined for the item ID.
+func isJFSimilarItemID(itemID string) bool {
+	return !(isJFPersonID(itemID) ||
+		isJFGenreID(itemID) ||
+		isJFStudioID(itemID) ||
+		isJFCollectionID(itemID) ||
+		isJFCollectionFavoritesID(itemID) ||
+		isJFCollectionPlaylistID(itemID) ||
+		isJFCollectionBoxSetID(itemID) ||
+		isJFBoxSetID(itemID) ||
+		isJFRootID(itemID))
+}
+
+// makeJFSimilarItems returns up to count items that are most similar to the provided item, best match first.
+func (j *Jellyfin) makeJFSimilarItems(ctx context.Context, userID string, c *collection.Collection, i collection.Item, count int) ([]JFItem, error) {
+	// Ask for extra items as items disliked by the user are left out
+	_, dislikedIDs, _ := j.repo.GetLikes(ctx, userID)
+	similarItemIDs, err := j.collections.Similar(ctx, c, i, count+len(dislikedIDs))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]JFItem, 0, count)
+	for _, id := range similarItemIDs {
+		if len(items) >= count {
+			break
+		}
+		if slices.Contains(dislikedIDs, id) {
+			continue
+		}
+		c, i := j.collections.GetItemByID(id)
+		if i == nil {
+			continue
+		}
+		jfitem, err := j.makeJFItem(ctx, userID, i, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, jfitem)
+	}
+	return items, nil
+}
+
 // /Items/{item}/Intros
 // /Users/{user}/Items/{item}/Intros
 func (j *Jellyfin) usersItemsIntrosHandler(w http.ResponseWriter, r *http.Request) {
@@ -478,18 +719,6 @@ func (j *Jellyfin) usersItemsSpecialFeaturesHandler(w http.ResponseWriter, r *ht
 	serveJSON(response, w)
 }
 
-// /Items/Suggestions
-//
-// usersItemsSuggestionsHandler returns a list of items that are suggested for the user
-func (j *Jellyfin) usersItemsSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
-	response := JFUsersItemsSuggestionsResponse{
-		Items:            []JFItem{},
-		StartIndex:       0,
-		TotalRecordCount: 0,
-	}
-	serveJSON(response, w)
-}
-
 // applyItemsFilter applies filtering on a list of JFItems based on provided queryparams
 func (j *Jellyfin) applyItemsFilter(items []JFItem, queryparams url.Values) []JFItem {
 	// Apply filtering
@@ -502,6 +731,65 @@ func (j *Jellyfin) applyItemsFilter(items []JFItem, queryparams url.Values) []JF
 	return resultItems
 }
 
+// applyParentalRating removes items rated above the maximum parental rating of the user.
+// Seasons and episodes without rating have the rating of their show. Unrated items are always included.
+func (j *Jellyfin) applyParentalRating(items []JFItem, user *model.User) []JFItem {
+	if user == nil || user.Properties.MaxParentalRating < 0 {
+		return items
+	}
+	showRatings := make(map[string]string)
+	resultItems := make([]JFItem, 0, len(items))
+	for _, item := range items {
+		rating := item.OfficialRating
+		if rating == "" && item.SeriesID != "" {
+			showID := trimPrefix(item.SeriesID)
+			if _, found := showRatings[showID]; !found {
+				if _, show := j.collections.GetShowByID(showID); show != nil {
+					showRatings[showID] = show.OfficialRating()
+				}
+			}
+			rating = showRatings[showID]
+		}
+		if !j.parentalRatingAllowed(rating, user) {
+			continue
+		}
+		resultItems = append(resultItems, item)
+	}
+	return resultItems
+}
+
+// parentalRatingAllowed returns true if an item with the official rating can be shown to the user.
+// Unrated items are always allowed.
+func (j *Jellyfin) parentalRatingAllowed(rating string, user *model.User) bool {
+	if user == nil || user.Properties.MaxParentalRating < 0 {
+		return true
+	}
+	score, ok := j.parentalRatings.Score(rating)
+	return !ok || score <= user.Properties.MaxParentalRating
+}
+
+// itemRatingAllowed returns true if a movie, show, season or episode can be played by the user.
+// Seasons and episodes without official rating get the rating of their show.
+func (j *Jellyfin) itemRatingAllowed(i collection.Item, user *model.User) bool {
+	if user == nil || user.Properties.MaxParentalRating < 0 {
+		return true
+	}
+	rating := i.OfficialRating()
+	if rating == "" {
+		var show *collection.Show
+		switch i.(type) {
+		case *collection.Season:
+			_, show, _ = j.collections.GetSeasonByID(i.ID())
+		case *collection.Episode:
+			_, show, _, _ = j.collections.GetEpisodeByID(i.ID())
+		}
+		if show != nil {
+			rating = show.OfficialRating()
+		}
+	}
+	return j.parentalRatingAllowed(rating, user)
+}
+
 // applyItemFilter checks if the item should be included in a result set or not.
 // returns true if the item should be included, false if it should be skipped.
 func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
@@ -558,6 +846,22 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 		}
 	}
 
+	// excludeLocationTypes, e.g. excludeLocationTypes=Virtual to skip missing episodes
+	if excludeLocationTypes := queryparams.Get("excludeLocationTypes"); excludeLocationTypes != "" {
+		for locationType := range strings.SplitSeq(excludeLocationTypes, ",") {
+			if strings.EqualFold(locationType, i.LocationType) {
+				return false
+			}
+		}
+	}
+
+	// isMissing, missing items are virtual items
+	if isMissing := strings.ToLower(queryparams.Get("isMissing")); isMissing != "" {
+		if (isMissing == "true") != (i.LocationType == "Virtual") {
+			return false
+		}
+	}
+
 	// media type filtering, top level categories: audio, video, photo, book
 	if mediaType := queryparams.Get("mediaTypes"); mediaType != "" {
 		keepItem := false
@@ -577,21 +881,30 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 
 	// isHd
 	if isHD := queryparams.Get("isHd"); isHD != "" {
-		switch strings.ToLower(isHD) {
-		case "true":
-			return i.IsHD
-		case "false":
-			return !i.IsHD
+		if strings.EqualFold(isHD, "true") != i.IsHD {
+			return false
 		}
 	}
 
 	// is4K
 	if is4K := queryparams.Get("is4K"); is4K != "" {
-		switch strings.ToLower(is4K) {
-		case "true":
-			return i.Is4K
-		case "false":
-			return !i.Is4K
+		if strings.EqualFold(is4K, "true") != i.Is4K {
+			return false
+		}
+	}
+
+	// isHdr, HDR10, HDR10+, HLG or Dolby Vision
+	if isHdr := queryparams.Get("isHdr"); isHdr != "" {
+		rangeType := jfItemVideoRangeType(i)
+		if strings.EqualFold(isHdr, "true") != (rangeType != "" && rangeType != videoRangeSDR) {
+			return false
+		}
+	}
+
+	// hasDolbyVision
+	if hasDolbyVision := queryparams.Get("hasDolbyVision"); hasDolbyVision != "" {
+		if strings.EqualFold(hasDolbyVision, "true") != strings.HasPrefix(jfItemVideoRangeType(i), "DOVI") {
+			return false
 		}
 	}
 
@@ -667,12 +980,17 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 		}
 	}
 
-	// filter on personIds
+	// filter on personIds, optionally limited to personTypes, e.g. "Director"
 	if personIDs := queryparams.Get("personIds"); personIDs != "" {
+		var personTypes []string
+		if types := queryparams.Get("personTypes"); types != "" {
+			personTypes = strings.Split(strings.ToLower(types), ",")
+		}
 		keepItem := false
 		for personID := range strings.SplitSeq(personIDs, ",") {
 			for _, person := range i.People {
-				if person.ID == personID {
+				if person.ID == personID &&
+					(personTypes == nil || slices.Contains(personTypes, strings.ToLower(person.Type))) {
 					keepItem = true
 				}
 			}
@@ -749,11 +1067,24 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 		}
 	}
 
-	// filter on offical rating
+	// filter on production location (country)
+	if includeLocations := queryparams.Get("productionLocations"); includeLocations != "" {
+		keepItem := false
+		for location := range strings.SplitSeq(includeLocations, "|") {
+			if slices.Contains(i.ProductionLocations, location) {
+				keepItem = true
+			}
+		}
+		if !keepItem {
+			return false
+		}
+	}
+
+	// filter on offical rating, ratings from different countries with the same score are considered equal
 	if includeOfficialRatings := queryparams.Get("officialRatings"); includeOfficialRatings != "" {
 		keepItem := false
 		for rating := range strings.SplitSeq(includeOfficialRatings, "|") {
-			if i.OfficialRating == rating {
+			if j.parentalRatings.Equal(i.OfficialRating, rating) {
 				keepItem = true
 			}
 		}
@@ -762,6 +1093,24 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 		}
 	}
 
+	// filter on minOfficialRating, unrated items are skipped
+	if minOfficialRating := queryparams.Get("minOfficialRating"); minOfficialRating != "" {
+		if minScore, ok := j.parentalRatings.Score(minOfficialRating); ok {
+			if score, ok := j.parentalRatings.Score(i.OfficialRating); !ok || score < minScore {
+				return false
+			}
+		}
+	}
+
+	// filter on maxOfficialRating, unrated items are skipped
+	if maxOfficialRating := queryparams.Get("maxOfficialRating"); maxOfficialRating != "" {
+		if maxScore, ok := j.parentalRatings.Score(maxOfficialRating); ok {
+			if score, ok := j.parentalRatings.Score(i.OfficialRating); !ok || score > maxScore {
+				return false
+			}
+		}
+	}
+
 	// filter on minCommunityRating
 	if minCommunityRatingStr := queryparams.Get("minCommunityRating"); minCommunityRatingStr != "" {
 		if minCommunityRating, err := strconv.ParseFloat(minCommunityRatingStr, 32); err == nil {
@@ -792,7 +1141,7 @@ func (j *Jellyfin) applyItemFilter(i *JFItem, queryparams url.Values) bool {
 	// Filter on maxPremierDate
 	if maxPremiereDateStr := queryparams.Get("maxPremiereDate"); maxPremiereDateStr != "" {
 		if maxPremiereDate, err := parseISO8601date(maxPremiereDateStr); err == nil {
-			if i.PremiereDate.After(maxPremiereDate) {
+			if i.PremiereDate.IsZero() || i.PremiereDate.After(maxPremiereDate) {
 				return false
 			}
 		}
@@ -870,6 +1219,14 @@ func (j *Jellyfin) applyItemSorting(items []JFItem, queryparams url.Values) []JF
 		sortFieldsLowered[i] = strings.ToLower(field)
 	}
 
+	// Random order overrides any other sort field
+	if slices.Contains(sortFieldsLowered, "random") {
+		rand.Shuffle(len(items), func(i, j int) {
+			items[i], items[j] = items[j], items[i]
+		})
+		return items
+	}
+
 	var sortDescending bool
 	if strings.ToLower(queryparams.Get("sortOrder")) == "descending" {
 		sortDescending = true
@@ -988,13 +1345,6 @@ func (j *Jellyfin) applyItemSorting(items []JFItem, queryparams url.Values) []JF
 					}
 					return items[i].ProductionYear < items[j].ProductionYear
 				}
-			case "random":
-				if items[i].SortName != items[j].SortName {
-					if rand.Intn(2) == 0 {
-						return items[i].SortName > items[j].SortName
-					}
-					return items[i].SortName < items[j].SortName
-				}
 			case "runtime":
 				if items[i].RunTimeTicks != items[j].RunTimeTicks {
 					if sortDescending {
@@ -1024,14 +1374,20 @@ func (j *Jellyfin) applyItemSorting(items []JFItem, queryparams url.Values) []JF
 	return items
 }
 
-// apply pagination to a list of items
+// apply pagination to a list of items, at most maxItems items are returned
 func (j *Jellyfin) applyItemPaginating(items []JFItem, queryparams url.Values) ([]JFItem, int) {
-	startIndex, startIndexErr := strconv.Atoi(queryparams.Get("startIndex"))
-	if startIndexErr == nil && startIndex >= 0 && startIndex < len(items) {
-		items = items[startIndex:]
-	}
+	startIndex, _ := strconv.Atoi(queryparams.Get("startIndex"))
+	startIndex = min(max(startIndex, 0), len(items))
+	items = items[startIndex:]
 	limit, limitErr := strconv.Atoi(queryparams.Get("limit"))
-	if limitErr == nil && limit > 0 && limit < len(items) {
+	if limitErr != nil || limit <= 0 {
+		limit = len(items)
+	}
+	if j.maxItems > 0 && limit > j.maxItems && len(items) > j.maxItems {
+		log.Printf("applyItemPaginating: limiting response to %d of %d items", j.maxItems, len(items))
+		limit = j.maxItems
+	}
+	if limit < len(items) {
 		items = items[:limit]
 	}
 	return items, startIndex
@@ -1039,38 +1395,252 @@ func (j *Jellyfin) applyItemPaginating(items []JFItem, queryparams url.Values) (
 
 // Items/{item} DELETE
 //
-// itemsDeleteHandler handles deleting an item
+// itemsDeleteHandler moves the files of a movie, show or episode to the trash directory
+// of its collection. Deleting files has to be enabled in the configuration.
 func (j *Jellyfin) itemsDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	apierror(w, "Not implemented", http.StatusForbidden)
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !j.deleteFiles {
+		apierror(w, "Deleting files is disabled", http.StatusForbidden)
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can delete items", http.StatusForbidden)
+		return
+	}
+	c, i := j.collections.GetItemByID(trimPrefix(mux.Vars(r)["itemid"]))
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err := j.collections.TrashItem(c, i); err != nil {
+		if errors.Is(err, collection.ErrTrashNotSupported) {
+			apierror(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Moving %s to trash failed: %s", i.Name(), err)
+		apierror(w, "Failed to delete item", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /Items/{item}
+//
+// itemsUpdateHandler updates item metadata from the metadata editor, only the sort name and
+// locked fields of movies and shows can be changed. Locked fields keep the value sent along.
+func (j *Jellyfin) itemsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can edit metadata", http.StatusForbidden)
+		return
+	}
+	vars := mux.Vars(r)
+	itemID := trimPrefix(vars["itemid"])
+
+	_, i := j.collections.GetItemByID(itemID)
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	switch i.(type) {
+	case *collection.Movie, *collection.Show:
+	default:
+		apierror(w, "Metadata can only be edited for movies and shows", http.StatusBadRequest)
+		return
+	}
+
+	var request JFItemUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := j.collections.SetSortName(r.Context(), itemID, request.ForcedSortName); err != nil {
+		apierror(w, "Failed to store sort name", http.StatusInternalServerError)
+		return
+	}
+	if request.LockData != nil || request.LockedFields != nil {
+		values := collection.LockedValues{
+			Title:          request.Name,
+			Plot:           request.Overview,
+			Genres:         request.Genres,
+			OfficialRating: request.OfficialRating,
+			Countries:      request.ProductionLocations,
+		}
+		if request.Studios != nil {
+			values.Studios = make([]string, 0, len(request.Studios))
+			for _, studio := range request.Studios {
+				values.Studios = append(values.Studios, studio.Name)
+			}
+		}
+		lockData := request.LockData != nil && *request.LockData
+		if err := j.collections.SetItemLock(r.Context(), itemID, lockData, request.LockedFields, values); err != nil {
+			apierror(w, "Failed to store locked fields", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// makeJFLockedFields returns if all fields of an item are locked and the locked fields.
+func (j *Jellyfin) makeJFLockedFields(itemID string) (bool, []string) {
+	lock := j.collections.ItemLock(itemID)
+	if lock == nil || lock.Fields == nil {
+		return lock != nil && lock.LockData, []string{}
+	}
+	return lock.LockData, lock.Fields
 }
 
 // /Items/68d73f6f48efedb7db697bf9fee580cb/PlaybackInfo?UserId=2b1ec0a52b09456c9823a367d84ac9e5
 //
-// itemsPlaybackInfoHandler returns playback information about an item, including media sources
+// itemsPlaybackInfoHandler returns playback information about an item, including media sources.
+// For a show, season or playlist the media sources of the items to play are returned in order.
 func (j *Jellyfin) itemsPlaybackInfoHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
 
-	_, i := j.collections.GetItemByID(trimPrefix(itemID))
-	if i == nil {
-		apierror(w, "Could not find item", http.StatusNotFound)
+	if c, _ := j.collections.GetItemByID(trimPrefix(itemID)); c != nil && j.pinLocked(r.Context(), c.ID) {
+		apierror(w, errPinRequired.Error(), http.StatusForbidden)
 		return
 	}
-	mediaSource := j.makeMediaSource(i)
-	if mediaSource == nil {
+	queue := slices.DeleteFunc(j.playbackQueue(r.Context(), reqCtx.User.ID, itemID), func(i collection.Item) bool {
+		return !j.itemRatingAllowed(i, reqCtx.User)
+	})
+	if len(queue) == 0 {
 		apierror(w, "Could not find item", http.StatusNotFound)
 		return
 	}
+	mediaSource := make([]JFMediaSources, 0, len(queue))
+	for _, i := range queue {
+		mediaSource = append(mediaSource, j.makeMediaSource(i)...)
+	}
+	for i := range mediaSource {
+		applyUserAudioPreference(&mediaSource[i], reqCtx.User)
+	}
+	// Sessions are registered for the first item, clients report the others when playing them
+	if len(queue) > 1 {
+		itemID = queue[0].ID()
+	}
+
+	if j.streamLimitReached(reqCtx.User, reqCtx.Token.DeviceId) {
+		response := JFPlaybackInfoResponse{
+			MediaSources: []JFMediaSources{},
+			ErrorCode:    "RateLimitExceeded",
+		}
+		serveJSON(response, w)
+		return
+	}
+	if !applyBitrateLimit(r, reqCtx.User, mediaSource) {
+		response := JFPlaybackInfoResponse{
+			MediaSources: mediaSource,
+			ErrorCode:    "NoCompatibleStream",
+		}
+		serveJSON(response, w)
+		return
+	}
 
 	response := JFPlaybackInfoResponse{
 		MediaSources: mediaSource,
-		// TODO this static id should be generated based upon authenticated user
 		// this id is used when submitting playstate via /Sessions/Playing endpoints
-		PlaySessionID: sessionID,
+		PlaySessionID: j.newPlaySession(reqCtx, itemID, j.playbackStartTicks(r, reqCtx.User.ID, queue[0])),
 	}
 	serveJSON(response, w)
 }
 
+// playbackStartTicks returns the position to start playback of an item at. This is
+// startTimeTicks of the request, or the resume position of the user in case the client
+// did not provide one, so playback resumes at the same position on all devices.
+func (j *Jellyfin) playbackStartTicks(r *http.Request, userID string, i collection.Item) int64 {
+	var startTimeTicks int64
+	if ticks, err := strconv.ParseInt(r.URL.Query().Get("startTimeTicks"), 10, 64); err == nil {
+		startTimeTicks = ticks
+	} else {
+		var request JFPlayBackInfoRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err == nil && request.StartTimeTicks > 0 {
+			startTimeTicks = request.StartTimeTicks
+		} else if playstate, err := j.repo.GetUserData(r.Context(), userID, i.ID()); err == nil && !playstate.Played {
+			startTimeTicks = playstate.Position * TicsToSeconds
+		}
+	}
+	// Direct play cannot start beyond the end of the video
+	if startTimeTicks < 0 || (i.Duration() > 0 && startTimeTicks >= makeRuntimeTicks(i.Duration())) {
+		return 0
+	}
+	return startTimeTicks
+}
+
+// playbackQueue returns the items to play for an item ID. For a show, season or playlist
+// these are the unplayed episodes in order, or all of them in case all have been played.
+// Specials are only played when a specials season is requested.
+func (j *Jellyfin) playbackQueue(ctx context.Context, userID, itemID string) []collection.Item {
+	var items []collection.Item
+	switch {
+	case isJFPlaylistID(itemID):
+		playlist, err := j.repo.GetPlaylist(ctx, userID, trimPrefix(itemID))
+		if err != nil {
+			return nil
+		}
+		for _, id := range playlist.ItemIDs {
+			if c, i := j.collections.GetItemByID(trimPrefix(id)); i != nil && i.FileName() != "" && !j.pinLocked(ctx, c.ID) {
+				items = append(items, i)
+			}
+		}
+		return items
+	case isJFBoxSetID(itemID):
+		boxSet, err := j.repo.GetBoxSet(ctx, trimPrefix(itemID))
+		if err != nil {
+			return nil
+		}
+		for _, id := range boxSet.ItemIDs {
+			if c, i := j.collections.GetItemByID(id); i != nil && i.FileName() != "" && !j.pinLocked(ctx, c.ID) {
+				items = append(items, i)
+			}
+		}
+		return items
+	}
+
+	_, i := j.collections.GetItemByID(trimPrefix(itemID))
+	switch v := i.(type) {
+	case nil:
+		return nil
+	case *collection.Show:
+		for s := range v.Seasons {
+			if v.Seasons[s].Number() == 0 {
+				continue
+			}
+			for e := range v.Seasons[s].Episodes {
+				items = append(items, &v.Seasons[s].Episodes[e])
+			}
+		}
+	case *collection.Season:
+		for e := range v.Episodes {
+			items = append(items, &v.Episodes[e])
+		}
+	default:
+		return []collection.Item{i}
+	}
+
+	var unplayed []collection.Item
+	for _, i := range items {
+		if playstate, err := j.repo.GetUserData(ctx, userID, i.ID()); err != nil || !playstate.Played {
+			unplayed = append(unplayed, i)
+		}
+	}
+	if len(unplayed) == 0 {
+		return items
+	}
+	return unplayed
+}
+
 // /Items/{item}/ThemeMedia
 //
 // usersItemsThemeMediaHandler
@@ -1095,36 +1665,145 @@ func (j *Jellyfin) usersItemsThemeMediaHandler(w http.ResponseWriter, r *http.Re
 	serveJSON(response, w)
 }
 
-// /Items/NrXTYiS6xAxFj4QAiJoT/MediaSegments
+// /Videos/NrXTYiS6xAxFj4QAiJoT/stream
+//
+// Supported query params:
+// - playSessionId: playback session, as returned by PlaybackInfo
+// - startTimeTicks: position playback starts at, recorded in the playback session
+//
+// videoStreamHandler streams the actual video file to the client. HEAD requests
+// get the headers of the video without reading it, clients use them to probe before ranged requests.
+func (j *Jellyfin) videoStreamHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	vars := mux.Vars(r)
+	itemID := vars["itemid"]
+
+	c, i := j.collections.GetItemByID(trimPrefix(itemID))
+	if i == nil || i.FileName() == "" || !j.itemRatingAllowed(i, reqCtx.User) {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if j.pinLocked(r.Context(), c.ID) {
+		apierror(w, errPinRequired.Error(), http.StatusForbidden)
+		return
+	}
+	queryparams := r.URL.Query()
+	if j.streamLimitReached(reqCtx.User, reqCtx.Token.DeviceId) {
+		apierror(w, "User is at their maximum number of active streams", http.StatusForbidden)
+		return
+	}
+	// A probe does not start playback
+	if r.Method != http.MethodHead {
+		j.registerStream(reqCtx, i.ID(), queryparams.Get("playSessionId"))
+		if ticks, err := strconv.ParseInt(queryparams.Get("startTimeTicks"), 10, 64); err == nil {
+			j.setPlaySessionStart(queryparams.Get("playSessionId"), ticks)
+		}
+	}
+	w.Header().Set("content-type", mimeTypeByExtension(i.FileName()))
+	j.serveFile(w, r, c.ItemDirectory(i)+"/"+i.FileName())
+}
+
+// /Videos/{item}/AdditionalParts
 //
-// mediaSegmentsHandler returns information about intro, commercial, preview, recap, outro segments
-// of an item, not supported.
-func (j *Jellyfin) mediaSegmentsHandler(w http.ResponseWriter, r *http.Request) {
+// videosAdditionalPartsHandler returns the parts following the first part of a
+// multi-part movie, in playback order. Empty for items that are not multi-part.
+func (j *Jellyfin) videosAdditionalPartsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	vars := mux.Vars(r)
+	c, i := j.collections.GetItemByID(trimPrefix(vars["itemid"]))
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
 	response := UserItemsResponse{
-		Items:            []JFItem{},
-		TotalRecordCount: 0,
-		StartIndex:       0,
+		Items: []JFItem{},
 	}
+	if movie, ok := i.(*collection.Movie); ok {
+		for _, part := range movie.AdditionalParts() {
+			if jfitem, err := j.makeJFItem(r.Context(), reqCtx.User.ID, part, c.ID); err == nil {
+				response.Items = append(response.Items, jfitem)
+			}
+		}
+	}
+	response.TotalRecordCount = len(response.Items)
 	serveJSON(response, w)
 }
 
-// /Videos/NrXTYiS6xAxFj4QAiJoT/stream
+// DELETE /Videos/ActiveEncodings?deviceId=x&playSessionId=y
 //
-// videoStreamHandler streams the actual video file to the client
-func (j *Jellyfin) videoStreamHandler(w http.ResponseWriter, r *http.Request) {
+// videosActiveEncodingsHandler stops the transcodes of a playback session, web clients call
+// it when switching quality. Items are always played directly so there is nothing to stop.
+func (j *Jellyfin) videosActiveEncodingsHandler(w http.ResponseWriter, r *http.Request) {
+	if reqCtx := j.getRequestCtx(w, r); reqCtx == nil {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// /Items/{item}/Download
+//
+// itemsDownloadHandler serves the original video file of an item as attachment,
+// in case the user is allowed to download content. Range requests are supported
+// so clients can resume interrupted downloads.
+func (j *Jellyfin) itemsDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.EnableDownloads {
+		apierror(w, "User is not allowed to download content", http.StatusForbidden)
+		return
+	}
+
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
 
 	c, i := j.collections.GetItemByID(trimPrefix(itemID))
-	if i == nil || i.FileName() == "" {
+	if i == nil || i.FileName() == "" || !j.itemRatingAllowed(i, reqCtx.User) {
 		apierror(w, "Item not found", http.StatusNotFound)
 		return
 	}
+	if j.pinLocked(r.Context(), c.ID) {
+		apierror(w, errPinRequired.Error(), http.StatusForbidden)
+		return
+	}
+	if j.streamLimitReached(reqCtx.User, reqCtx.Token.DeviceId) {
+		apierror(w, "User is at their maximum number of active streams", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodHead {
+		j.registerStream(reqCtx, i.ID(), "")
+	}
+
+	// Movies are named after their directory, e.g. "Casablanca (1942).mp4",
+	// episode filenames are descriptive already.
+	filename := path.Base(i.FileName())
+	if _, ok := i.(*collection.Movie); ok {
+		filename = i.Name() + path.Ext(i.FileName())
+	}
+	w.Header().Set("content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
 	w.Header().Set("content-type", mimeTypeByExtension(i.FileName()))
-	j.serveFile(w, r, c.Directory+"/"+i.Path()+"/"+i.FileName())
+	j.serveFile(w, r, c.ItemDirectory(i)+"/"+i.FileName())
 }
 
 func (j *Jellyfin) serveFile(w http.ResponseWriter, r *http.Request, filename string) {
+	// Client is gone, no need to open the file
+	if r.Context().Err() != nil {
+		return
+	}
+	if !j.collections.Registered(filename) {
+		apierror(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if j.storageOffline(w, filename) {
+		return
+	}
 	file, err := os.Open(filename)
 	if err != nil {
 		apierror(w, "File not found", http.StatusNotFound)
@@ -1140,9 +1819,44 @@ func (j *Jellyfin) serveFile(w http.ResponseWriter, r *http.Request, filename st
 	http.ServeContent(w, r, fileStat.Name(), fileStat.ModTime(), file)
 }
 
+// storageOffline responds with 503 in case a file is stored in a collection directory
+// that is currently not available, e.g. because a network share dropped.
+func (j *Jellyfin) storageOffline(w http.ResponseWriter, filename string) bool {
+	if !j.collections.Offline(filename) {
+		return false
+	}
+	w.Header().Set("retry-after", "60")
+	apierror(w, "Storage of this collection is temporarily offline", http.StatusServiceUnavailable)
+	return true
+}
+
 func serveJSON(obj any, w http.ResponseWriter) {
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		// Do not keep exceptionally large buffers around
+		if buf.Cap() <= jsonBufferMaxPooledSize {
+			buf.Reset()
+			jsonBufferPool.Put(buf)
+		}
+	}()
+
 	w.Header().Set("content-type", "application/json")
-	_ = json.NewEncoder(w).Encode(obj)
+	if err := json.NewEncoder(buf).Encode(obj); err != nil {
+		log.Printf("serveJSON: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	_, _ = buf.WriteTo(w)
+}
+
+// jsonBufferMaxPooledSize is the maximum size of a buffer to be returned to the pool.
+const jsonBufferMaxPooledSize = 4 << 20
+
+// jsonBufferPool holds buffers used to encode responses, to reduce allocations for large responses.
+var jsonBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
 }
 
 // parseISO8601date tries to parse a date string in various ISO 8601 formats
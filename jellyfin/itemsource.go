@@ -0,0 +1,73 @@
+package jellyfin
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// JFItemSourceResponse is the location an item was found at.
+type JFItemSourceResponse struct {
+	ID           string `json:"Id"`
+	CollectionID string `json:"CollectionId"`
+	Path         string `json:"Path"`
+}
+
+// JFItemIDCollision is an item that got a different ID as its regular ID was already in use.
+type JFItemIDCollision struct {
+	ID            string `json:"Id"`
+	Path          string `json:"Path"`
+	CollisionID   string `json:"CollisionId"`
+	CollisionPath string `json:"CollisionPath"`
+}
+
+// GET /Jellofin/Items/{itemid}/Source
+//
+// itemsSourceHandler returns the path an item was found at, for debugging. Administrators only.
+func (j *Jellyfin) itemsSourceHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view item sources", http.StatusForbidden)
+		return
+	}
+	itemID := trimPrefix(mux.Vars(r)["itemid"])
+	c, source, found := j.collections.ItemSource(itemID)
+	if !found {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	response := JFItemSourceResponse{
+		ID:           itemID,
+		CollectionID: makeJFCollectionID(c.ID),
+		Path:         source,
+	}
+	serveJSON(response, w)
+}
+
+// GET /Jellofin/ItemIdCollisions
+//
+// itemIDCollisionsHandler returns the items that got a different ID because their regular ID,
+// derived from their name, was already used by another item. Administrators only.
+func (j *Jellyfin) itemIDCollisionsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view item ID collisions", http.StatusForbidden)
+		return
+	}
+	response := []JFItemIDCollision{}
+	for _, c := range j.collections.IDCollisions() {
+		response = append(response, JFItemIDCollision{
+			ID:            c.ID,
+			Path:          c.Path,
+			CollisionID:   c.CollisionID,
+			CollisionPath: c.CollisionPath,
+		})
+	}
+	serveJSON(response, w)
+}
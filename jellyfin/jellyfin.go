@@ -1,28 +1,37 @@
 package jellyfin
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
 	"github.com/erikbos/jellofin-server/collection"
 	"github.com/erikbos/jellofin-server/database"
 	"github.com/erikbos/jellofin-server/idhash"
 	"github.com/erikbos/jellofin-server/imageresize"
+	"github.com/erikbos/jellofin-server/parentalrating"
 )
 
 // API definitions: https://swagger.emby.media/ & https://api.jellyfin.org/
 // Docs: https://github.com/mediabrowser/emby/wiki
 
+// defaultRequestTimeout is the maximum time an API request may spend on repository and metadata work.
+const defaultRequestTimeout = 30 * time.Second
+
 type Options struct {
 	Collections  *collection.CollectionRepo
 	Repo         database.Repository
 	Imageresizer *imageresize.Resizer
+	// ParentalRatings is the content rating normalization table
+	ParentalRatings *parentalrating.Ratings
 	// Unique ID of this server, used in API responses
 	ServerID string
 	// ServerName is name of server returned in info responses
@@ -35,35 +44,241 @@ type Options struct {
 	QuickConnect bool
 	// JPEG quality for posters
 	ImageQualityPoster int
+	// ImageWidthBuckets are the widths requested image widths are rounded up to, to improve image cache hits, optional
+	ImageWidthBuckets []int
+	// CompressionLevel is the gzip and deflate compression level of API responses (1-9)
+	CompressionLevel int
+	// CompressionLevels are the compression levels per content encoding, e.g. "br": 5, optional
+	CompressionLevels map[string]int
+	// CompressionMinSize is the minimum size of API responses in bytes to compress them, defaults to 1024
+	CompressionMinSize int
+	// SessionIdleTimeout expires access tokens that have not been used for this long, 0 disables expiry
+	SessionIdleTimeout time.Duration
+	// CORS holds the cross-origin settings for web clients hosted elsewhere
+	CORS CORS
+	// RequestTimeout is the maximum time an API request may spend on repository and metadata work, defaults to 30 seconds
+	RequestTimeout time.Duration
+	// Paths are the server directories reported in system info
+	Paths SystemPaths
+	// Branding holds the login disclaimer, custom CSS and splash screen for web clients
+	Branding Branding
+	// Resume holds the thresholds for resume positions and marking items as played
+	Resume Resume
+	// ReloadConfig reloads the config file, it is called by the reload endpoint
+	ReloadConfig func() error
+	// SubtitleProvider is used to search and download subtitles, optional
+	SubtitleProvider SubtitleProvider
+	// PersonProvider is used to look up biographies and images of persons, optional
+	PersonProvider PersonProvider
+	// RemoteImageProvider is used to list and download artwork of movies and shows, optional
+	RemoteImageProvider RemoteImageProvider
+	// MovieSearchProvider is used to identify movies that were matched wrongly, optional
+	MovieSearchProvider MovieSearchProvider
+	// RequestUser is called with the name of the authenticated user of a request, optional
+	RequestUser func(r *http.Request, username string)
+	// GenreMappingFile is the YAML file holding the genre mapping, optional
+	GenreMappingFile string
+	// SmartCollections are the smart collections defined in the config file
+	SmartCollections []SmartCollection
+	// DeleteFiles allows administrators to delete movies, shows and episodes, their files are moved to the trash
+	DeleteFiles bool
+	// MaxItems is the maximum number of items in a response, clients page through larger results. 0 is unlimited
+	MaxItems int
+	// IntroDetection enables the task detecting intros of episodes
+	IntroDetection bool
+	// Notifications holds the webhooks administrators are alerted on about security events, optional
+	Notifications Notifications
+	// ExternalAddress returns the address of the server on the internet, e.g. "203.0.113.5:8096", optional
+	ExternalAddress func() string
+	// DedupeItems leaves out lower quality versions of movies and shows in more than one collection from listings of all items
+	DedupeItems bool
+}
+
+// SystemPaths are the server directories reported in system info.
+type SystemPaths struct {
+	// ProgramData is the directory holding the database
+	ProgramData string
+	// Web is the directory holding the web UI
+	Web string
+	// Cache is the image cache directory
+	Cache string
+	// Log is the log file
+	Log string
+	// Metadata is the directory holding extracted thumbnails
+	Metadata string
 }
 
 type Jellyfin struct {
 	collections  *collection.CollectionRepo
 	repo         database.Repository
 	imageresizer *imageresize.Resizer
+	// parentalRatings is the content rating normalization table
+	parentalRatings *parentalrating.Ratings
 	// Unique ID of this server, used in API responses
 	serverID string
-	// serverName is name of server returned in info responses
-	serverName string
-	// Indicates if we should auto-register Jellyfin users
+	// serverName is name of server returned in info responses, can be changed in server configuration
+	serverName   string
+	serverNameMu sync.RWMutex
+	// serverPort is the port of the server
+	serverPort string
+	// externalAddress returns the address of the server on the internet, empty if unknown
+	externalAddress func() string
+	// Indicates if we should auto-register Jellyfin users, can be changed by config reload
 	autoRegister bool
 	// Indicates if quickconnect is enabled
 	quickConnectEnabled bool
-	// JPEG quality for posters
+	// JPEG quality for posters, can be changed by config reload
 	imageQualityPoster int
+	// widths requested image widths are rounded up to, sorted ascending
+	imageWidthBuckets []int
+	// optionsMu guards the options that can be changed by config reload
+	optionsMu sync.RWMutex
+	// reloadConfig reloads the config file
+	reloadConfig func() error
+	// subtitleProvider searches and downloads subtitles, nil if not configured
+	subtitleProvider SubtitleProvider
+	// genreMappingFile holds the genre mapping, empty if not configured
+	genreMappingFile string
+	// smartCollectionsConfig are the smart collections defined in the config file
+	smartCollectionsConfig []SmartCollection
+	// compression level of API responses per content encoding
+	compressionLevels map[string]int
+	// minimum size of API responses to compress them
+	compressionMinSize int
+	// expire access tokens that have not been used for this long
+	sessionIdleTimeout time.Duration
+	// cross-origin settings for web clients
+	cors CORS
+	// maximum time an API request may spend on repository and metadata work
+	requestTimeout time.Duration
+	// server directories reported in system info
+	paths SystemPaths
+	// branding of web clients
+	branding Branding
+	// allow administrators to move files of items to the trash
+	deleteFiles bool
+	// maximum number of items in a response, 0 is unlimited
+	maxItems int
+	// leave out lower quality duplicates of items from listings of all items
+	dedupeItems bool
+	// itemQueries limits the expensive item queries running at the same time, by user id
+	itemQueries   map[string]chan struct{}
+	itemQueriesMu sync.Mutex
+	// imageTask is the state of the image pre-generation task
+	imageTask backgroundTask
+	// personTask is the state of the person refresh task
+	personTask backgroundTask
+	// metadataTask is the state of the metadata refresh task
+	metadataTask backgroundTask
+	// introDetection enables the intro detection task
+	introDetection bool
+	// introTask is the state of the intro detection task
+	introTask backgroundTask
+	// personProvider looks up details of persons, nil if not configured
+	personProvider PersonProvider
+	// remoteImageProvider lists and downloads artwork, nil if not configured
+	remoteImageProvider RemoteImageProvider
+	// movieSearchProvider searches movies to identify items, nil if not configured
+	movieSearchProvider MovieSearchProvider
+	// requestUser is called with the name of the authenticated user of a request
+	requestUser func(r *http.Request, username string)
+	// personThumbs holds the thumb URLs of actors found in metadata, by name
+	personThumbs   map[string]string
+	personThumbsMu sync.RWMutex
+	// resume holds the resume thresholds, can be changed in server configuration
+	resume   Resume
+	resumeMu sync.RWMutex
+	// resumeConfig holds the resume thresholds of the config file
+	resumeConfig Resume
+	// playSessions holds the playback sessions by PlaySessionId
+	playSessions   map[string]*playSession
+	playSessionsMu sync.Mutex
+	// sockets holds the websocket connections of clients
+	sockets   map[*socketConn]struct{}
+	socketsMu sync.Mutex
+	// capabilities holds the capabilities reported by clients, by device id
+	capabilities   map[string]JFSessionResponseCapabilities
+	capabilitiesMu sync.Mutex
+	// playQueues holds the server tracked play queues, by session id
+	playQueues   map[string]*playQueue
+	playQueuesMu sync.Mutex
+	// liveTVServices are the sources of live TV channels, live TV is disabled if there are none
+	liveTVServices []liveTVService
+	// pinUnlocks holds until when the restricted collections of a user are unlocked, by access token
+	pinUnlocks map[string]time.Time
+	// pinFailures counts wrong PINs, by user id and device id of the request
+	pinFailures map[string]*pinFailures
+	pinMu       sync.Mutex
+	// notifications holds the failed login thresholds of security notifications
+	notifications Notifications
+	// webhooks are posted security events
+	webhooks []webhook
+	// failedLogins holds the recent failed logins, by username
+	failedLogins   map[string][]time.Time
+	failedLoginsMu sync.Mutex
+	// openAPI describes the registered endpoints
+	openAPI *openAPIDocument
 }
 
 func New(o *Options) *Jellyfin {
 	j := &Jellyfin{
-		collections:         o.Collections,
-		repo:                o.Repo,
-		serverID:            o.ServerID,
-		serverName:          o.ServerName,
-		imageresizer:        o.Imageresizer,
-		autoRegister:        o.AutoRegister,
-		quickConnectEnabled: o.QuickConnect,
-		imageQualityPoster:  o.ImageQualityPoster,
+		collections:            o.Collections,
+		repo:                   o.Repo,
+		serverID:               o.ServerID,
+		serverName:             o.ServerName,
+		serverPort:             o.ServerPort,
+		externalAddress:        o.ExternalAddress,
+		imageresizer:           o.Imageresizer,
+		parentalRatings:        o.ParentalRatings,
+		autoRegister:           o.AutoRegister,
+		quickConnectEnabled:    o.QuickConnect,
+		imageQualityPoster:     o.ImageQualityPoster,
+		imageWidthBuckets:      slices.Sorted(slices.Values(o.ImageWidthBuckets)),
+		compressionLevels:      compressionLevels(o.CompressionLevel, o.CompressionLevels),
+		compressionMinSize:     o.CompressionMinSize,
+		sessionIdleTimeout:     o.SessionIdleTimeout,
+		cors:                   o.CORS,
+		requestTimeout:         o.RequestTimeout,
+		paths:                  o.Paths,
+		branding:               o.Branding,
+		deleteFiles:            o.DeleteFiles,
+		maxItems:               o.MaxItems,
+		dedupeItems:            o.DedupeItems,
+		introDetection:         o.IntroDetection,
+		reloadConfig:           o.ReloadConfig,
+		subtitleProvider:       o.SubtitleProvider,
+		personProvider:         o.PersonProvider,
+		remoteImageProvider:    o.RemoteImageProvider,
+		movieSearchProvider:    o.MovieSearchProvider,
+		requestUser:            o.RequestUser,
+		genreMappingFile:       o.GenreMappingFile,
+		smartCollectionsConfig: o.SmartCollections,
+		resumeConfig: o.Resume.withDefaults(Resume{
+			MinResumePercentage:      defaultMinResumePercentage,
+			MaxResumePercentage:      defaultMaxResumePercentage,
+			MinResumeDurationSeconds: defaultMinResumeDurationSeconds,
+		}),
+	}
+	j.resume = j.resumeConfig
+	j.playSessions = make(map[string]*playSession)
+	j.capabilities = make(map[string]JFSessionResponseCapabilities)
+	j.playQueues = make(map[string]*playQueue)
+	j.itemQueries = make(map[string]chan struct{})
+	j.pinUnlocks = make(map[string]time.Time)
+	j.pinFailures = make(map[string]*pinFailures)
+	j.notifications = o.Notifications
+	if j.notifications.FailedLogins <= 0 {
+		j.notifications.FailedLogins = defaultFailedLogins
+	}
+	if j.notifications.FailedLoginWindow <= 0 {
+		j.notifications.FailedLoginWindow = defaultFailedLoginWindow
 	}
+	j.webhooks = makeWebhooks(o.Notifications)
+	j.failedLogins = make(map[string][]time.Time)
+	j.imageTask.trigger = make(chan struct{}, 1)
+	j.personTask.trigger = make(chan struct{}, 1)
+	j.metadataTask.trigger = make(chan struct{}, 1)
+	j.introTask.trigger = make(chan struct{}, 1)
 	if j.serverID == "" {
 		if hostname, err := os.Hostname(); err == nil {
 			j.serverID = idhash.IdHash(hostname)
@@ -74,17 +289,55 @@ func New(o *Options) *Jellyfin {
 	if j.serverName == "" {
 		j.serverName = "Jellofin"
 	}
+	j.loadServerConfiguration(context.Background())
+	if j.requestTimeout <= 0 {
+		j.requestTimeout = defaultRequestTimeout
+	}
+	if j.compressionMinSize <= 0 {
+		j.compressionMinSize = defaultCompressionMinSize
+	}
 	return j
 }
 
+// Reconfigure applies the options that can be changed at runtime: auto-registration
+// and poster image quality. Other options are ignored.
+func (j *Jellyfin) Reconfigure(o *Options) {
+	j.optionsMu.Lock()
+	defer j.optionsMu.Unlock()
+	if j.autoRegister != o.AutoRegister {
+		log.Printf("Config reload: autoregister set to %t", o.AutoRegister)
+		j.autoRegister = o.AutoRegister
+	}
+	if j.imageQualityPoster != o.ImageQualityPoster {
+		log.Printf("Config reload: imagequalityposter set to %d", o.ImageQualityPoster)
+		j.imageQualityPoster = o.ImageQualityPoster
+	}
+}
+
+// autoRegisterEnabled returns true if unknown users are registered when they log in.
+func (j *Jellyfin) autoRegisterEnabled() bool {
+	j.optionsMu.RLock()
+	defer j.optionsMu.RUnlock()
+	return j.autoRegister
+}
+
+// posterImageQuality returns the JPEG quality of posters.
+func (j *Jellyfin) posterImageQuality() int {
+	j.optionsMu.RLock()
+	defer j.optionsMu.RUnlock()
+	return j.imageQualityPoster
+}
+
 func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r := s.UseEncodedPath()
+	// Routes of other APIs registered before are not part of the OpenAPI document
+	existingRoutes := countRoutes(r)
 
 	r.Use(normalizeJellyfinRequest)
 
 	// middleware for endpoints to check valid auth token
 	middleware := func(handler http.HandlerFunc) http.Handler {
-		return handlers.CompressHandler(j.authmiddleware(http.HandlerFunc(handler)))
+		return j.compressHandler(j.timeoutmiddleware(j.authmiddleware(http.HandlerFunc(handler))))
 	}
 
 	r.Handle("/health", http.HandlerFunc(j.healthHandler))
@@ -93,13 +346,24 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/System/Ping", http.HandlerFunc(j.systemPingHandler))
 	r.Handle("/System/Info", middleware(j.systemInfoHandler))
 	r.Handle("/System/Info/Public", http.HandlerFunc(j.systemInfoPublicHandler))
+	r.Handle("/System/ActivityLog/Entries", middleware(j.systemActivityLogEntriesHandler)).Methods("GET")
+	r.Handle("/PlaybackHistory", middleware(j.playbackHistoryHandler)).Methods("GET")
+	r.Handle("/System/Configuration", middleware(j.systemConfigurationGetHandler)).Methods("GET")
+	r.Handle("/System/Configuration", middleware(j.systemConfigurationPostHandler)).Methods("POST")
+	r.Handle("/System/Configuration/Reload", middleware(j.systemConfigurationReloadHandler)).Methods("POST")
+	r.Handle("/System/Configuration/{key}", middleware(j.systemConfigurationGetHandler)).Methods("GET")
+	r.Handle("/System/Configuration/{key}", middleware(j.systemConfigurationPostHandler)).Methods("POST")
 	r.Handle("/System/Logs", middleware(j.systemLogsHandler))
 	r.Handle("/System/Restart", middleware(j.systemRestartHandler)).Methods("POST")
 	r.Handle("/System/Shutdown", middleware(j.systemRestartHandler)).Methods("POST")
 	r.Handle("/Plugins", middleware(j.pluginsHandler))
 	r.Handle("/ScheduledTasks", middleware(j.scheduledTasksHandler))
+	r.Handle("/ScheduledTasks/Running/{taskid}", middleware(j.scheduledTasksStartHandler)).Methods("POST")
 	r.Handle("/Playback/BitrateTest", middleware(j.playbackBitrateTestHandler))
 
+	// websocket connections are long-lived, so no compression and request timeout
+	r.Handle("/socket", j.authmiddleware(http.HandlerFunc(j.socketHandler)))
+
 	r.Handle("/Users/AuthenticateByName", http.HandlerFunc(j.usersAuthenticateByNameHandler)).Methods("POST")
 	r.Handle("/Users/AuthenticateWithQuickConnect", http.HandlerFunc(j.usersAuthenticateWithQuickConnectHandler)).Methods("POST")
 	r.Handle("/QuickConnect/Authorize", middleware(j.quickConnectAuthorizeHandler)).Methods("POST")
@@ -127,11 +391,16 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/Users/{userid}/Items/Resume", middleware(j.usersItemsResumeHandler))
 	r.Handle("/Users/{userid}/Items/Suggestions", middleware(j.usersItemsSuggestionsHandler))
 	r.Handle("/Users/{userid}/Items/{itemid}", middleware(j.usersItemHandler))
+	r.Handle("/Users/{userid}/Items/{itemid}/PlaybackHistory", middleware(j.usersItemPlaybackHistoryHandler)).Methods("GET")
 
 	r.Handle("/UserViews", middleware(j.usersViewsHandler))
 	r.Handle("/UserViews/GroupingOptions", middleware(j.usersGroupingOptionsHandler))
 
 	r.Handle("/UserItems/Resume", middleware(j.usersItemsResumeHandler))
+	r.Handle("/UserItems/Sync", middleware(j.usersItemsSyncHandler)).Methods("POST")
+	r.Handle("/UserItems/{itemid}/UserData", middleware(j.usersItemUserDataPostHandler)).Methods("POST")
+	r.Handle("/UserItems/{itemid}/Userdata", middleware(j.usersItemUserDataPostHandler)).Methods("POST")
+	r.Handle("/UserItems/{itemid}/UserData", middleware(j.usersItemUserDataHandler))
 	r.Handle("/UserItems/{itemid}/Userdata", middleware(j.usersItemUserDataHandler))
 
 	r.Handle("/DisplayPreferences/{id}", middleware(j.displayPreferencesHandler))
@@ -139,6 +408,12 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/Library/MediaFolders", middleware(j.usersViewsHandler))
 	r.Handle("/Library/VirtualFolders", middleware(j.libraryVirtualFoldersHandler))
 	r.Handle("/Library/Refresh", middleware(j.libraryRefreshHandler)).Methods("POST")
+	r.Handle("/Library/GenreMapping", middleware(j.genreMappingGetHandler)).Methods("GET")
+	r.Handle("/Library/GenreMapping", middleware(j.genreMappingPostHandler)).Methods("POST")
+	r.Handle("/Library/GenreMapping/Reload", middleware(j.genreMappingReloadHandler)).Methods("POST")
+	r.Handle("/Library/SmartCollections", middleware(j.smartCollectionsHandler)).Methods("GET")
+	r.Handle("/Library/SmartCollections", middleware(j.smartCollectionsPostHandler)).Methods("POST")
+	r.Handle("/Library/SmartCollections/{id}", middleware(j.smartCollectionsDeleteHandler)).Methods("DELETE")
 
 	r.Handle("/Shows/NextUp", middleware(j.showsNextUpHandler))
 	r.Handle("/Shows/{showid}/Seasons", middleware(j.showsSeasonsHandler))
@@ -152,20 +427,30 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/Items/Root", middleware(j.usersItemsRootHandler))
 	r.Handle("/Items/Suggestions", middleware(j.usersItemsSuggestionsHandler))
 	r.Handle("/Items/{itemid}", middleware(j.itemsDeleteHandler)).Methods("DELETE")
+	r.Handle("/Items/{itemid}", middleware(j.itemsUpdateHandler)).Methods("POST")
 	r.Handle("/Items/{itemid}", middleware(j.usersItemHandler))
 	r.Handle("/Items/{itemid}/Ancestors", middleware(j.usersItemsAncestorsHandler))
+	r.Handle("/Items/{itemid}/Download", middleware(j.itemsDownloadHandler)).Methods("GET", "HEAD")
 	// Images can be fetched without auth, https://github.com/jellyfin/jellyfin/issues/13988
 	r.Handle("/Items/{itemid}/Images", http.HandlerFunc(j.itemsImagesHandler))
 	r.Handle("/Items/{itemid}/Images/{type}", http.HandlerFunc(j.itemsImagesGetHandler)).Methods("GET", "HEAD")
-	r.Handle("/Items/{itemid}/Images/{type}", http.HandlerFunc(j.itemsImagesPostHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/Images/{type}", middleware(j.itemsImagesPostHandler)).Methods("POST")
 	r.Handle("/Items/{itemid}/Images/{type}/{index}", http.HandlerFunc(j.itemsImagesGetHandler)).Methods("GET", "HEAD")
-	r.Handle("/Items/{itemid}/Images/{type}/{index}", http.HandlerFunc(j.itemsImagesPostHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/Images/{type}/{index}", middleware(j.itemsImagesPostHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/InstantMix", middleware(j.itemsInstantMixHandler))
 	r.Handle("/Items/{itemid}/Intros", middleware(j.usersItemsIntrosHandler))
 	r.Handle("/Items/{itemid}/LocalTrailers", middleware(j.usersItemsLocalTrailersHandler))
 	r.Handle("/Items/{itemid}/PlaybackInfo", middleware(j.itemsPlaybackInfoHandler))
 	r.Handle("/Items/{itemid}/Refresh", middleware(j.usersItemsRefreshHandler)).Methods("POST")
-	r.Handle("/Items/{itemid}/RemoteImages", http.HandlerFunc(j.itemsRemoteImagesHandler))
-	r.Handle("/Items/{itemid}/RemoteImages/Providers", http.HandlerFunc(j.itemsRemoteImagesProvidersHandler))
+	r.Handle("/Items/{itemid}/RemoteImages", middleware(j.itemsRemoteImagesHandler)).Methods("GET")
+	r.Handle("/Items/{itemid}/RemoteImages/Providers", middleware(j.itemsRemoteImagesProvidersHandler)).Methods("GET")
+	r.Handle("/Items/{itemid}/RemoteImages/Download", middleware(j.itemsRemoteImagesDownloadHandler)).Methods("POST")
+	r.Handle("/Items/RemoteSearch/Movie", middleware(j.itemsRemoteSearchMovieHandler)).Methods("POST")
+	r.Handle("/Items/RemoteSearch/Apply/{itemid}", middleware(j.itemsRemoteSearchApplyHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/Identify", middleware(j.itemsRemoteSearchApplyHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/RemoteSearch/Subtitles/{language}", middleware(j.itemsRemoteSearchSubtitlesHandler)).Methods("GET")
+	r.Handle("/Items/{itemid}/RemoteSearch/Subtitles/{subtitleid}", middleware(j.itemsRemoteSearchSubtitlesDownloadHandler)).Methods("POST")
+	r.Handle("/Items/{itemid}/Shuffle", middleware(j.itemsShuffleHandler))
 	r.Handle("/Items/{itemid}/Similar", middleware(j.usersItemsSimilarHandler))
 	r.Handle("/Items/{itemid}/SpecialFeatures", middleware(j.usersItemsSpecialFeaturesHandler))
 	r.Handle("/Items/{itemid}/ThemeMedia", middleware(j.usersItemsThemeMediaHandler))
@@ -188,10 +473,14 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 
 	r.Handle("/Search/Hints", middleware(j.searchHintsHandler))
 	r.Handle("/Movies/Recommendations", middleware(j.moviesRecommendationsHandler))
+	r.Handle("/Audio/{itemid}/Lyrics", middleware(j.audioLyricsHandler)).Methods("GET")
 
-	// Video can be fetched without auth, https://github.com/jellyfin/jellyfin/issues/13984
+	// Media segments can be fetched without auth, https://github.com/jellyfin/jellyfin/issues/13984.
+	// Streams need auth, PIN locks and stream limits depend on the user and device.
 	r.Handle("/MediaSegments/{itemid}", http.HandlerFunc(j.mediaSegmentsHandler))
-	r.Handle("/Videos/{itemid}/{stream}", http.HandlerFunc(j.videoStreamHandler))
+	r.Handle("/Videos/ActiveEncodings", middleware(j.videosActiveEncodingsHandler)).Methods("DELETE")
+	r.Handle("/Videos/{itemid}/AdditionalParts", middleware(j.videosAdditionalPartsHandler)).Methods("GET")
+	r.Handle("/Videos/{itemid}/{stream}", middleware(j.videoStreamHandler)).Methods("GET", "HEAD")
 
 	r.Handle("/Persons", middleware(j.personsHandler))
 	r.Handle("/Persons/{name}", middleware(j.personHandler))
@@ -207,14 +496,31 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/Sessions/Playing/Progress", middleware(j.sessionsPlayingProgressHandler)).Methods("POST")
 	r.Handle("/Sessions/Playing/Stopped", middleware(j.sessionsPlayingStoppedHandler)).Methods("POST")
 	r.Handle("/Sessions", middleware(j.sessionsHandler))
+	r.Handle("/Sessions/{sessionid}/Playing", middleware(j.sessionsPlayHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/Playing/{command}", middleware(j.sessionsPlaystateHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/Command/{command}", middleware(j.sessionsGeneralCommandHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/PlayQueue", middleware(j.sessionsPlayQueueGetHandler)).Methods("GET")
+	r.Handle("/Sessions/{sessionid}/PlayQueue", middleware(j.sessionsPlayQueueCreateHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/PlayQueue", middleware(j.sessionsPlayQueueDeleteHandler)).Methods("DELETE")
+	r.Handle("/Sessions/{sessionid}/PlayQueue/Next", middleware(j.sessionsPlayQueueNextHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/PlayQueue/Previous", middleware(j.sessionsPlayQueuePreviousHandler)).Methods("POST")
+	r.Handle("/Sessions/{sessionid}/PlayQueue/Mode", middleware(j.sessionsPlayQueueModeHandler)).Methods("POST")
 	r.Handle("/UserPlayedItems/{itemid}", middleware(j.usersPlayedItemsPostHandler)).Methods("POST")
 	r.Handle("/UserPlayedItems/{itemid}", middleware(j.usersPlayedItemsDeleteHandler)).Methods("DELETE")
 	r.Handle("/UserFavoriteItems/{itemid}", middleware(j.userFavoriteItemsPostHandler)).Methods("POST")
 	r.Handle("/UserFavoriteItems/{itemid}", middleware(j.userFavoriteItemsDeleteHandler)).Methods("DELETE")
+	r.Handle("/UserItems/{itemid}/Rating", middleware(j.userItemRatingPostHandler)).Methods("POST")
+	r.Handle("/UserItems/{itemid}/Rating", middleware(j.userItemRatingDeleteHandler)).Methods("DELETE")
 	r.Handle("/Users/{user}/PlayedItems/{itemid}", middleware(j.usersPlayedItemsPostHandler)).Methods("POST")
 	r.Handle("/Users/{user}/PlayedItems/{itemid}", middleware(j.usersPlayedItemsDeleteHandler)).Methods("DELETE")
 	r.Handle("/Users/{user}/FavoriteItems/{itemid}", middleware(j.userFavoriteItemsPostHandler)).Methods("POST")
 	r.Handle("/Users/{user}/FavoriteItems/{itemid}", middleware(j.userFavoriteItemsDeleteHandler)).Methods("DELETE")
+	r.Handle("/Users/{user}/Items/{itemid}/Rating", middleware(j.userItemRatingPostHandler)).Methods("POST")
+	r.Handle("/Users/{user}/Items/{itemid}/Rating", middleware(j.userItemRatingDeleteHandler)).Methods("DELETE")
+
+	r.Handle("/Collections", middleware(j.createBoxSetHandler)).Methods("POST")
+	r.Handle("/Collections/{collectionid}/Items", middleware(j.addBoxSetItemsHandler)).Methods("POST")
+	r.Handle("/Collections/{collectionid}/Items", middleware(j.deleteBoxSetItemsHandler)).Methods("DELETE")
 
 	r.Handle("/Playlists", middleware(j.createPlaylistHandler)).Methods("POST")
 	r.Handle("/Playlists/{playlistid}", middleware(j.getPlaylistHandler)).Methods("GET")
@@ -226,17 +532,47 @@ func (j *Jellyfin) RegisterHandlers(s *mux.Router) {
 	r.Handle("/Playlists/{playlistid}/Users", middleware(j.getPlaylistAllUsersHandler)).Methods("GET")
 	r.Handle("/Playlists/{playlistid}/Users/{userid}", middleware(j.getPlaylistUsersHandler)).Methods("GET")
 
+	r.Handle("/Jellofin/Export/UserData", middleware(j.exportUserDataHandler)).Methods("GET")
+	r.Handle("/Jellofin/HiddenItems", middleware(j.hiddenItemsHandler)).Methods("GET")
+	r.Handle("/Jellofin/Items/{itemid}/Hide", middleware(j.itemsHideHandler)).Methods("POST")
+	r.Handle("/Jellofin/Items/{itemid}/Hide", middleware(j.itemsUnhideHandler)).Methods("DELETE")
+	r.Handle("/Jellofin/Items/{itemid}/Source", middleware(j.itemsSourceHandler)).Methods("GET")
+	r.Handle("/Jellofin/ItemIdCollisions", middleware(j.itemIDCollisionsHandler)).Methods("GET")
+	r.Handle("/Jellofin/Users/{userid}/Pin", middleware(j.usersPinHandler)).Methods("POST")
+	r.Handle("/Jellofin/Users/{userid}/Guest", middleware(j.usersGuestHandler)).Methods("POST")
+	r.Handle("/Jellofin/Pin", middleware(j.pinStatusHandler)).Methods("GET")
+	r.Handle("/Jellofin/Pin/Challenge", middleware(j.pinChallengeHandler)).Methods("POST")
+	r.Handle("/Jellofin/Pin/Lock", middleware(j.pinLockHandler)).Methods("POST")
+	r.Handle("/Jellofin/ImageCache", middleware(j.imageCacheHandler)).Methods("GET")
+
 	r.HandleFunc("/Branding/Configuration", j.brandingConfigurationHandler)
 	r.HandleFunc("/Branding/Css", j.brandingCssHandler)
 	r.HandleFunc("/Branding/Css.css", j.brandingCssHandler)
+	r.HandleFunc("/Branding/Splashscreen", j.brandingSplashscreenHandler)
 
 	r.HandleFunc("/Localization/Countries", j.localizationCountriesHandler)
 	r.HandleFunc("/Localization/Cultures", j.localizationCulturesHandler)
 	r.HandleFunc("/Localization/Options", j.localizationOptionsHandler)
 	r.HandleFunc("/Localization/ParentalRatings", j.localizationParentalRatingsHandler)
 
+	r.Handle("/Channels", middleware(j.channelsHandler)).Methods("GET")
+	r.Handle("/Channels/Features", middleware(j.channelsFeaturesHandler)).Methods("GET")
+	r.Handle("/Channels/Items/Latest", middleware(j.channelsHandler)).Methods("GET")
+	r.Handle("/Channels/{channelid}/Items", middleware(j.channelsHandler)).Methods("GET")
+
+	r.Handle("/LiveTv/Info", middleware(j.liveTVInfoHandler)).Methods("GET")
+	r.Handle("/LiveTv/Channels", middleware(j.liveTVChannelsHandler)).Methods("GET")
+	r.Handle("/LiveTv/Programs", middleware(j.liveTVEmptyItemsHandler)).Methods("GET", "POST")
+	r.Handle("/LiveTv/Programs/Recommended", middleware(j.liveTVEmptyItemsHandler)).Methods("GET")
+	r.Handle("/LiveTv/Recordings", middleware(j.liveTVEmptyItemsHandler)).Methods("GET")
+	r.Handle("/LiveTv/Timers", middleware(j.liveTVEmptyItemsHandler)).Methods("GET")
+	r.Handle("/LiveTv/SeriesTimers", middleware(j.liveTVEmptyItemsHandler)).Methods("GET")
+
 	r.Handle("/SyncPlay/List", http.HandlerFunc(j.syncPlayListHandler))
 	r.Handle("/SyncPlay/New", http.HandlerFunc(j.syncPlayNewHandler))
+
+	r.Handle("/openapi.json", http.HandlerFunc(j.openAPIHandler)).Methods("GET")
+	j.openAPI = makeOpenAPIDocument(r, existingRoutes)
 }
 
 // normalizeJellyfinRequest is a middleware that normalizes requests:
@@ -266,3 +602,14 @@ func normalizeJellyfinRequest(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// timeoutmiddleware sets a deadline on the request context, this limits the time
+// spent on repository queries and metadata loading. The context is also cancelled
+// if the client disconnects. Files being streamed are not affected.
+func (j *Jellyfin) timeoutmiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), j.requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
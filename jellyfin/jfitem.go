@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jxskiss/base62"
 
 	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/collection/metadata"
+	"github.com/erikbos/jellofin-server/database/model"
 	"github.com/erikbos/jellofin-server/idhash"
 )
 
@@ -20,11 +23,14 @@ const (
 	collectionRootID = "e9d5075a555c1cbc394eec4cef295274"
 	// ID of dynamically generated Playlist collection
 	playlistCollectionID = "2f0340563593c4d98b97c9bfa21ce23c"
+	// ID of dynamically generated boxset collection
+	boxSetCollectionID = "9c1e4b7a2d5f8e3c6b0a9d2f5e8c1b4a"
 	// ID of dynamically generated favorites collection
 	favoritesCollectionID    = "f4a0b1c2d3e5c4b8a9e6f7d8e9a0b1c2"
 	collectionTypeMovies     = "movies"
 	collectionTypeTVShows    = "tvshows"
 	collectionTypePlaylists  = "playlists"
+	collectionTypeBoxSets    = "boxsets"
 	itemTypeUserRootFolder   = "UserRootFolder"
 	itemTypeCollectionFolder = "CollectionFolder"
 	itemTypeUserView         = "UserView"
@@ -33,6 +39,7 @@ const (
 	itemTypeSeason           = "Season"
 	itemTypeEpisode          = "Episode"
 	itemTypePlaylist         = "Playlist"
+	itemTypeBoxSet           = "BoxSet"
 	itemTypeGenre            = "Genre"
 	itemTypeStudio           = "Studio"
 	itemTypePerson           = "Person"
@@ -71,6 +78,22 @@ func (j *Jellyfin) getJFItemsByParentID(ctx context.Context, userID, parentID st
 		}
 		return items, nil
 
+	// List of boxsets requested?
+	case isJFCollectionBoxSetID(parentID):
+		return j.makeJFItemBoxSetOverview(ctx)
+
+	// Specific boxset requested?
+	case isJFBoxSetID(parentID):
+		items, err := j.makeJFItemBoxSetItemList(ctx, userID, trimPrefix(parentID))
+		if err != nil {
+			return []JFItem{}, errors.New("could not find collection")
+		}
+		return items, nil
+
+	// Specific smart collection requested?
+	case isJFSmartCollectionID(parentID):
+		return j.makeJFItemSmartCollectionOverview(ctx, userID, trimPrefix(parentID))
+
 	// Specific playlist requests?
 	case isJFPlaylistID(parentID):
 		playlistID := trimPrefix(parentID)
@@ -137,6 +160,9 @@ func (j *Jellyfin) getJFItemsByParentID(ctx context.Context, userID, parentID st
 		if c == nil {
 			return []JFItem{}, errors.New("could not find collection")
 		}
+		if j.pinLocked(ctx, c.ID) {
+			return []JFItem{}, errPinRequired
+		}
 		items := make([]JFItem, 0, len(c.Items))
 		for _, i := range c.Items {
 			jfitem, err := j.makeJFItem(ctx, userID, i, c.ID)
@@ -170,11 +196,21 @@ func (j *Jellyfin) getJFItemsByParentID(ctx context.Context, userID, parentID st
 	return []JFItem{}, errors.New("parentID not found")
 }
 
-// getJFItemsAll returns list of all items
+// getJFItemsAll returns list of all items, leaving out lower quality duplicates if enabled
 func (j *Jellyfin) getJFItemsAll(ctx context.Context, userID string) ([]JFItem, error) {
+	var duplicates map[string]bool
+	if j.dedupeItems {
+		duplicates = j.duplicateItems()
+	}
 	items := make([]JFItem, 0)
 	for _, c := range j.collections.GetCollections() {
+		if j.pinLocked(ctx, c.ID) {
+			continue
+		}
 		for _, i := range c.Items {
+			if duplicates[i.ID()] {
+				continue
+			}
 			jfitem, err := j.makeJFItem(ctx, userID, i, c.ID)
 			if err != nil {
 				return []JFItem{}, err
@@ -230,10 +266,19 @@ func (j *Jellyfin) makeJFItemByID(ctx context.Context, userID, itemID string) (J
 		return j.makeJFItemCollectionFavorites(ctx, userID)
 	case isJFCollectionPlaylistID(itemID):
 		return j.makeJFItemCollectionPlaylist(ctx, userID)
+	case isJFCollectionBoxSetID(itemID):
+		return j.makeJFItemCollectionBoxSets(ctx)
 	case isJFCollectionID(itemID):
+		if j.pinLocked(ctx, trimPrefix(itemID)) {
+			return JFItem{}, errPinRequired
+		}
 		return j.makeJFItemCollection(ctx, trimPrefix(itemID))
+	case isJFSmartCollectionID(itemID):
+		return j.makeJFItemSmartCollection(ctx, userID, trimPrefix(itemID))
 	case isJFPlaylistID(itemID):
 		return j.makeJFItemPlaylist(ctx, userID, trimPrefix(itemID))
+	case isJFBoxSetID(itemID):
+		return j.makeJFItemBoxSet(ctx, trimPrefix(itemID))
 	case isJFPersonID(itemID):
 		return j.makeJFItemPerson(ctx, userID, itemID)
 	case isJFGenreID(itemID):
@@ -247,11 +292,18 @@ func (j *Jellyfin) makeJFItemByID(ctx context.Context, userID, itemID string) (J
 	if i == nil {
 		return JFItem{}, errors.New("item not found")
 	}
+	if j.pinLocked(ctx, c.ID) {
+		return JFItem{}, errPinRequired
+	}
 	return j.makeJFItem(ctx, userID, i, c.ID)
 }
 
 // makeJFItem make movie or show from provided item
 func (j *Jellyfin) makeJFItem(ctx context.Context, userID string, item collection.Item, parentID string) (JFItem, error) {
+	// Stop building items in case request timed out or client disconnected
+	if err := ctx.Err(); err != nil {
+		return JFItem{}, err
+	}
 	switch i := item.(type) {
 	case *collection.Movie:
 		return j.makeJFItemMovie(ctx, userID, i, parentID)
@@ -272,7 +324,7 @@ func makeJFProviderIds(providerIDs map[string]string) JFProviderIds {
 		switch strings.ToLower(k) {
 		case "imdb":
 			ids.Imdb = v
-		case "themoviedb":
+		case "themoviedb", "tmdb":
 			ids.Tmdb = v
 		case "tvdb":
 			ids.Tvdb = v
@@ -322,6 +374,31 @@ func (j *Jellyfin) makeMediaSource(item collection.Item) (mediasources []JFMedia
 	return []JFMediaSources{mediasource}
 }
 
+// applyUserAudioPreference marks the audio stream in the preferred language of the user as default
+// stream, unless the user wants to play the default audio track of a file.
+func applyUserAudioPreference(mediasource *JFMediaSources, user *model.User) {
+	if user.Properties.PlayDefaultAudioTrack || user.Properties.AudioLanguagePreference == "" {
+		return
+	}
+	preferred, ok := twoLetterLanguage(user.Properties.AudioLanguagePreference)
+	if !ok {
+		return
+	}
+	index := slices.IndexFunc(mediasource.MediaStreams, func(s JFMediaStreams) bool {
+		language, ok := twoLetterLanguage(s.Language)
+		return s.Type == "Audio" && ok && language == preferred
+	})
+	if index == -1 {
+		return
+	}
+	for i := range mediasource.MediaStreams {
+		if mediasource.MediaStreams[i].Type == "Audio" {
+			mediasource.MediaStreams[i].IsDefault = i == index
+		}
+	}
+	mediasource.DefaultAudioStreamIndex = mediasource.MediaStreams[index].Index
+}
+
 // makeJFMediaStreams creates media stream information for the provided item
 func (j *Jellyfin) makeJFMediaStreams(item collection.Item) []JFMediaStreams {
 	videostream := JFMediaStreams{
@@ -368,8 +445,15 @@ func (j *Jellyfin) makeJFMediaStreams(item collection.Item) []JFMediaStreams {
 		videostream.CodecTag = "unknown"
 		// log.Printf("Item %s/%s has unknown video codec %s", item.ID(), item.FileName(), item.VideoCodec())
 	}
+	videostream.VideoRange, videostream.VideoRangeType = makeJFVideoRange(item.VideoHdrType())
+	if videostream.VideoRange == videoRangeHDR {
+		videostream.BitDepth = 10
+		if videostream.Codec == "hevc" {
+			videostream.Profile = "Main 10"
+		}
+	}
 	videostream.Title = strings.ToUpper(videostream.Codec)
-	videostream.DisplayTitle = videostream.Title + " - " + videostream.VideoRange
+	videostream.DisplayTitle = videostream.Title + " - " + videostream.VideoRangeType
 
 	audiostream := JFMediaStreams{
 		Index:              1,
@@ -458,10 +542,13 @@ const (
 	itemprefix_collection           = "collection_"
 	itemprefix_collection_favorites = "collectionfavorites_"
 	itemprefix_collection_playlist  = "collectionplaylist_"
+	itemprefix_collection_boxset    = "collectionboxset_"
 	itemprefix_show                 = "show_"
 	itemprefix_season               = "season_"
 	itemprefix_episode              = "episode_"
 	itemprefix_playlist             = "playlist_"
+	itemprefix_boxset               = "boxset_"
+	itemprefix_smartcollection      = "smartcollection_"
 	itemprefix_genre                = "genre_"
 	itemprefix_studio               = "studio_"
 	itemprefix_person               = "person_"
@@ -495,12 +582,44 @@ func decodeExternalName(itemprefix, id string) (string, error) {
 	return string(b), nil
 }
 
-// itemIsHD checks if the provided item is HD (720p or higher)
+// itemIsHD checks if the provided item is HD (720p or higher), widescreen
+// movies have a lower height so width is checked as well, e.g. 1280x536.
 func itemIsHD(item collection.Item) bool {
-	return item.VideoHeight() >= 720
+	return item.VideoHeight() >= 720 || item.VideoWidth() >= 1280
 }
 
-// itemIs4K checks if the provided item is 4K (2160p or higher)
+// itemIs4K checks if the provided item is 4K (2160p or higher), e.g. 3840x1600.
 func itemIs4K(item collection.Item) bool {
-	return item.VideoHeight() >= 1500
+	return item.VideoHeight() >= 1500 || item.VideoWidth() >= 3200
+}
+
+const (
+	videoRangeSDR = "SDR"
+	videoRangeHDR = "HDR"
+)
+
+// makeJFVideoRange returns the Jellyfin video range ("SDR" or "HDR") and video range type,
+// e.g. "DOVI", of the HDR format of a video.
+func makeJFVideoRange(hdrType string) (videoRange, videoRangeType string) {
+	switch hdrType {
+	case metadata.HdrTypeHDR10:
+		return videoRangeHDR, "HDR10"
+	case metadata.HdrTypeHDR10Plus:
+		return videoRangeHDR, "HDR10Plus"
+	case metadata.HdrTypeDolbyVision:
+		return videoRangeHDR, "DOVI"
+	case metadata.HdrTypeHLG:
+		return videoRangeHDR, "HLG"
+	}
+	return videoRangeSDR, videoRangeSDR
+}
+
+// jfItemVideoRangeType returns the video range type of the video stream of an item, empty if it has none.
+func jfItemVideoRangeType(i *JFItem) string {
+	for _, stream := range i.MediaStreams {
+		if stream.Type == "Video" {
+			return stream.VideoRangeType
+		}
+	}
+	return ""
 }
@@ -0,0 +1,68 @@
+package jellyfin
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// requestLanguages returns the languages of a request in order of preference: those of the
+// Accept-Language header, followed by the preferred audio language of the user.
+func requestLanguages(r *http.Request, user *model.User) []string {
+	languages := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if user != nil {
+		if language, ok := twoLetterLanguage(user.Properties.AudioLanguagePreference); ok {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header, e.g.
+// "nl-BE,nl;q=0.9,en;q=0.8", ordered by quality. Wildcards and languages with quality 0 are left out.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		quality  float64
+	}
+	var entries []weighted
+	for part := range strings.SplitSeq(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.TrimSpace(language)
+		if language == "" || language == "*" {
+			continue
+		}
+		quality := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q <= 0 {
+				continue
+			}
+			quality = q
+		}
+		entries = append(entries, weighted{language: language, quality: quality})
+	}
+	// Stable sort keeps the order of the header for equal quality
+	slices.SortStableFunc(entries, func(a, b weighted) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+	languages := make([]string, 0, len(entries))
+	for _, e := range entries {
+		languages = append(languages, e.language)
+	}
+	return languages
+}
+
+// contextLanguages returns the languages of the request of a context, nil for requests
+// without authentication.
+func contextLanguages(ctx context.Context) []string {
+	if reqCtx, ok := ctx.Value(requestContextKey).(*requestContext); ok {
+		return reqCtx.Languages
+	}
+	return nil
+}
@@ -7,9 +7,9 @@ import (
 	"net/http"
 	"slices"
 	"strings"
-	"time"
 
 	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
 	"github.com/erikbos/jellofin-server/idhash"
 )
 
@@ -31,7 +31,11 @@ func (j *Jellyfin) libraryVirtualFoldersHandler(w http.ResponseWriter, r *http.R
 			CollectionType: collectionItem.Type,
 			Locations: []string{
 				// stub directory path
-				"/" + strings.ToLower(strings.Join(strings.Fields(collectionItem.Name), "")),
+				"/" + strings.ToLower(strings.Join(strings.Fields(c.Name), "")),
+			},
+			LibraryOptions: JFLibraryOptions{
+				Enabled:                      true,
+				AutomaticRefreshIntervalDays: c.RefreshIntervalDays,
 			},
 		}
 		if _, err := j.repo.HasImage(r.Context(), collectionItem.ID, imageTypePrimary); err == nil {
@@ -59,8 +63,8 @@ func (j *Jellyfin) usersViewsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("usersViewsHandler: EnableAllFolders: %v, EnabledFolders: %v, OrderedViews: %v, MyMediaExcludes: %v",
-		reqCtx.User.Properties.EnableAllFolders, reqCtx.User.Properties.EnabledFolders, reqCtx.User.Properties.OrderedViews, reqCtx.User.Properties.MyMediaExcludes)
+	log.Printf("usersViewsHandler: EnableAllFolders: %v, EnabledFolders: %v, PinnedViews: %v, OrderedViews: %v, MyMediaExcludes: %v",
+		reqCtx.User.Properties.EnableAllFolders, reqCtx.User.Properties.EnabledFolders, reqCtx.User.Properties.PinnedViews, reqCtx.User.Properties.OrderedViews, reqCtx.User.Properties.MyMediaExcludes)
 
 	for _, item := range items {
 		log.Printf("usersViewsHandler: before filtering item: %s, DisplayPreferencesID: %s", item.ID, item.DisplayPreferencesID)
@@ -81,37 +85,13 @@ func (j *Jellyfin) usersViewsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Collections locked by the PIN of the user are left out until it is entered
+	items = j.applyPinLock(r.Context(), items)
+
 	queryparams := r.URL.Query()
 	includeHidden := queryparams.Get("includeHidden") == "true"
 
-	// If the user has configured an order of views, we need to order the items based on that.
-	// And exclude collection items unless includeHidden is true
-	// Any items that are not in the user's ordered views will be added at the end.
-	if len(reqCtx.User.Properties.OrderedViews) != 0 {
-		// Order the items based on user preferences, and exclude items in my media excludes
-		orderedItems := make([]JFItem, 0, len(items))
-		seenItems := make(map[string]struct{})
-		for _, displayPreferenceID := range reqCtx.User.Properties.OrderedViews {
-			// If includeHidden is false, we need to exclude items that are in MyMediaExcludes
-			if !includeHidden && slices.Contains(reqCtx.User.Properties.MyMediaExcludes, displayPreferenceID) {
-				continue
-			}
-			for _, item := range items {
-				if item.DisplayPreferencesID == displayPreferenceID {
-					orderedItems = append(orderedItems, item)
-					seenItems[item.ID] = struct{}{}
-					break
-				}
-			}
-		}
-		// Append any items that were not included in the user's ordered views at the end of the list
-		for _, item := range items {
-			if _, exists := seenItems[item.ID]; !exists {
-				orderedItems = append(orderedItems, item)
-			}
-		}
-		items = orderedItems
-	}
+	items = orderViews(items, reqCtx.User.Properties, includeHidden)
 
 	for _, item := range items {
 		log.Printf("usersViewsHandler: after ordering item: %s, DisplayPreferencesID: %s", item.ID, item.DisplayPreferencesID)
@@ -125,6 +105,40 @@ func (j *Jellyfin) usersViewsHandler(w http.ResponseWriter, r *http.Request) {
 	serveJSON(response, w)
 }
 
+// orderViews orders the collection items as configured by the user: pinned views first, followed
+// by the ordered views and any remaining items. Views can be referenced by item or display preferences
+// ID. Items in MyMediaExcludes are removed unless includeHidden is true.
+func orderViews(items []JFItem, props model.UserProperties, includeHidden bool) []JFItem {
+	matches := func(item JFItem, id string) bool {
+		return item.ID == id || item.DisplayPreferencesID == id
+	}
+	orderedItems := make([]JFItem, 0, len(items))
+	seenItems := make(map[string]struct{})
+	for _, id := range slices.Concat(props.PinnedViews, props.OrderedViews) {
+		for _, item := range items {
+			if _, seen := seenItems[item.ID]; !seen && matches(item, id) {
+				orderedItems = append(orderedItems, item)
+				seenItems[item.ID] = struct{}{}
+				break
+			}
+		}
+	}
+	// Append any items that were not included in the user's pinned or ordered views at the end of the list
+	for _, item := range items {
+		if _, exists := seenItems[item.ID]; !exists {
+			orderedItems = append(orderedItems, item)
+		}
+	}
+	if includeHidden {
+		return orderedItems
+	}
+	// Pinned views are always shown
+	return slices.DeleteFunc(orderedItems, func(item JFItem) bool {
+		return !slices.ContainsFunc(props.PinnedViews, func(id string) bool { return matches(item, id) }) &&
+			slices.ContainsFunc(props.MyMediaExcludes, func(id string) bool { return matches(item, id) })
+	})
+}
+
 // /Users/2b1ec0a52b09456c9823a367d84ac9e5/GroupingOptions
 //
 // usersGroupingOptionsHandler returns the available collections as grouping options
@@ -178,7 +192,6 @@ func (j *Jellyfin) makeJFItemRoot(ctx context.Context, userID string) (response
 		ServerID:                 j.serverID,
 		ID:                       rootID,
 		Etag:                     idhash.Hash(collectionRootID),
-		DateCreated:              time.Now().UTC(),
 		Type:                     itemTypeUserRootFolder,
 		IsFolder:                 true,
 		CanDelete:                false,
@@ -224,6 +237,14 @@ func (j *Jellyfin) makeJFCollectionRootOverview(ctx context.Context, userID stri
 	if playlistCollection, err := j.makeJFItemCollectionPlaylist(ctx, userID); err == nil {
 		items = append(items, playlistCollection)
 	}
+	if boxSetCollection, err := j.makeJFItemCollectionBoxSets(ctx); err == nil {
+		items = append(items, boxSetCollection)
+	}
+	for _, sc := range j.smartCollections(ctx) {
+		if smartCollection, err := j.makeJFItemSmartCollection(ctx, userID, sc.ID); err == nil {
+			items = append(items, smartCollection)
+		}
+	}
 	return items, nil
 }
 
@@ -236,13 +257,11 @@ func (j *Jellyfin) makeJFItemCollection(ctx context.Context, collectionID string
 	id := makeJFCollectionID(collectionID)
 	collectionGenres := c.Genres()
 	response := JFItem{
-		Name:                     c.Name,
+		Name:                     c.DisplayName(contextLanguages(ctx)),
 		ServerID:                 j.serverID,
 		ID:                       id,
 		ParentID:                 makeJFRootID(collectionRootID),
-		Etag:                     idhash.Hash(collectionID),
-		DateCreated:              time.Now().UTC(),
-		PremiereDate:             time.Now().UTC(),
+		Etag:                     idhash.Hash(collectionID + c.Etag),
 		Type:                     itemTypeCollectionFolder,
 		IsFolder:                 true,
 		LocationType:             "FileSystem",
@@ -271,6 +290,10 @@ func (j *Jellyfin) makeJFItemCollection(ctx context.Context, collectionID string
 	default:
 		log.Printf("makeJItemCollection: unknown collection type: %s", c.Type)
 	}
+	if !c.DateLastMediaAdded.IsZero() {
+		lastMediaAdded := c.DateLastMediaAdded.UTC()
+		response.DateLastMediaAdded = &lastMediaAdded
+	}
 	response.SortName = response.CollectionType
 	return response, nil
 }
@@ -289,8 +312,6 @@ func (j *Jellyfin) makeJFItemCollectionFavorites(ctx context.Context, userID str
 		ID:                       id,
 		ParentID:                 makeJFRootID(collectionRootID),
 		Etag:                     idhash.Hash(favoritesCollectionID),
-		DateCreated:              time.Now().UTC(),
-		PremiereDate:             time.Now().UTC(),
 		CollectionType:           collectionTypePlaylists,
 		SortName:                 collectionTypePlaylists,
 		Type:                     itemTypeUserView,
@@ -310,7 +331,6 @@ func (j *Jellyfin) makeJFItemCollectionFavorites(ctx context.Context, userID str
 		CanDownload:              true,
 		SpecialFeatureCount:      0,
 		ImageTags:                j.makeJFImageTags(ctx, id, imageTypePrimary),
-		// PremiereDate should be set based upon most recent item in collection
 	}
 	return response, nil
 }
@@ -0,0 +1,77 @@
+package jellyfin
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// liveTVService is a source of live TV channels, such as a tuner. No services are
+// implemented yet, without services the LiveTV API reports live TV as disabled so
+// clients can hide it.
+type liveTVService interface {
+	// Info returns the name and status of the service.
+	Info() JFLiveTvServiceInfo
+	// Channels returns the channels the service provides.
+	Channels(ctx context.Context) ([]JFItem, error)
+}
+
+// /LiveTv/Info
+//
+// liveTVInfoHandler returns the live TV services and whether live TV is enabled.
+func (j *Jellyfin) liveTVInfoHandler(w http.ResponseWriter, r *http.Request) {
+	response := JFLiveTvInfo{
+		Services:     []JFLiveTvServiceInfo{},
+		IsEnabled:    len(j.liveTVServices) != 0,
+		EnabledUsers: []string{},
+	}
+	for _, service := range j.liveTVServices {
+		response.Services = append(response.Services, service.Info())
+	}
+	serveJSON(response, w)
+}
+
+// /LiveTv/Channels
+//
+// Supported query params:
+// - startIndex: the index of the first channel to return
+// - limit: the maximum number of channels to return
+//
+// liveTVChannelsHandler returns the channels of all live TV services.
+func (j *Jellyfin) liveTVChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	items := []JFItem{}
+	for _, service := range j.liveTVServices {
+		channels, err := service.Channels(r.Context())
+		if err != nil {
+			log.Printf("liveTVChannelsHandler: cannot get channels of %s: %s", service.Info().Name, err)
+			continue
+		}
+		items = append(items, channels...)
+	}
+
+	totalItemCount := len(items)
+	responseItems, startIndex := j.applyItemPaginating(items, r.URL.Query())
+	response := UserItemsResponse{
+		Items:            responseItems,
+		StartIndex:       startIndex,
+		TotalRecordCount: totalItemCount,
+	}
+	serveJSON(response, w)
+}
+
+// /LiveTv/Programs
+// /LiveTv/Programs/Recommended
+// /LiveTv/Recordings
+// /LiveTv/Timers
+// /LiveTv/SeriesTimers
+//
+// liveTVEmptyItemsHandler returns an empty list, guide data, recordings and timers
+// are not supported.
+func (j *Jellyfin) liveTVEmptyItemsHandler(w http.ResponseWriter, r *http.Request) {
+	response := UserItemsResponse{
+		Items:            []JFItem{},
+		StartIndex:       0,
+		TotalRecordCount: 0,
+	}
+	serveJSON(response, w)
+}
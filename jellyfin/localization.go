@@ -31,11 +31,12 @@ func (j *Jellyfin) localizationOptionsHandler(w http.ResponseWriter, r *http.Req
 
 // Localization/ParentalRatings
 func (j *Jellyfin) localizationParentalRatingsHandler(w http.ResponseWriter, r *http.Request) {
-	response := []JFLocalizationParentalRatings{
-		{
-			Name:  "Unrated",
-			Value: 0,
-		},
+	response := []JFLocalizationParentalRatings{}
+	for _, rating := range j.parentalRatings.Ratings() {
+		response = append(response, JFLocalizationParentalRatings{
+			Name:  rating.Name,
+			Value: rating.Score,
+		})
 	}
 	j.cache1h(w)
 	serveJSON(response, w)
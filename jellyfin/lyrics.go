@@ -0,0 +1,144 @@
+package jellyfin
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxLyricsSize is the maximum size of a lyrics file we parse.
+const maxLyricsSize = 1024 * 1024
+
+// lrcTimeTag matches a time tag of a synced lyrics line, e.g. "[01:23.45]".
+var lrcTimeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2}(?:[.:]\d{1,3})?)\]`)
+
+// lrcIDTag matches an ID tag of a lyrics file, e.g. "[ar:Artist]".
+var lrcIDTag = regexp.MustCompile(`^\[([a-z#]+):(.*)\]$`)
+
+// GET /Audio/{item}/Lyrics
+//
+// audioLyricsHandler returns the lyrics of an item, read from a .lrc file next to the
+// media file, e.g. "song.lrc" for "song.mp3".
+func (j *Jellyfin) audioLyricsHandler(w http.ResponseWriter, r *http.Request) {
+	c, i := j.collections.GetItemByID(trimPrefix(mux.Vars(r)["itemid"]))
+	if i == nil || i.FileName() == "" {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	mediaFile := path.Join(c.ItemDirectory(i), i.FileName())
+	f, err := os.Open(strings.TrimSuffix(mediaFile, path.Ext(mediaFile)) + ".lrc")
+	if err != nil {
+		apierror(w, "Lyrics not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	serveJSON(parseLyrics(io.LimitReader(f, maxLyricsSize)), w)
+}
+
+// parseLyrics parses lyrics in LRC format. Lines without time tag are returned as
+// unsynced lyrics, in which case no lines have a start time.
+func parseLyrics(r io.Reader) JFLyrics {
+	lyrics := JFLyrics{
+		Lyrics: []JFLyricLine{},
+	}
+	var synced []JFLyricLine
+	var unsynced []JFLyricLine
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if m := lrcIDTag.FindStringSubmatch(line); m != nil && !lrcTimeTag.MatchString(line) {
+			parseLyricsIDTag(&lyrics.Metadata, m[1], strings.TrimSpace(m[2]))
+			continue
+		}
+		// A line can have multiple time tags, e.g. for a repeated chorus
+		var starts []int64
+		for {
+			m := lrcTimeTag.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			if start, ok := parseLyricsTime(m[1], m[2]); ok {
+				starts = append(starts, start)
+			}
+			line = line[len(m[0]):]
+		}
+		text := strings.TrimSpace(line)
+		if len(starts) == 0 {
+			unsynced = append(unsynced, JFLyricLine{Text: text})
+			continue
+		}
+		for _, start := range starts {
+			synced = append(synced, JFLyricLine{Text: text, Start: &start})
+		}
+	}
+
+	if len(synced) > 0 {
+		sort.SliceStable(synced, func(i, j int) bool {
+			return *synced[i].Start < *synced[j].Start
+		})
+		lyrics.Lyrics = synced
+		lyrics.Metadata.IsSynced = true
+	} else if len(unsynced) > 0 {
+		lyrics.Lyrics = unsynced
+	}
+	return lyrics
+}
+
+// parseLyricsIDTag sets the lyrics metadata of an ID tag.
+func parseLyricsIDTag(m *JFLyricMetadata, tag, value string) {
+	switch tag {
+	case "ar":
+		m.Artist = value
+	case "al":
+		m.Album = value
+	case "ti":
+		m.Title = value
+	case "au":
+		m.Author = value
+	case "by":
+		m.By = value
+	case "re", "tool":
+		m.Creator = value
+	case "ve":
+		m.Version = value
+	case "length":
+		minutes, seconds, _ := strings.Cut(value, ":")
+		if length, ok := parseLyricsTime(minutes, seconds); ok {
+			m.Length = &length
+		}
+	case "offset":
+		// Offset is in milliseconds
+		if ms, err := strconv.ParseInt(strings.TrimPrefix(value, "+"), 10, 64); err == nil {
+			offset := (time.Duration(ms) * time.Millisecond).Nanoseconds() / 100
+			m.Offset = &offset
+		}
+	}
+}
+
+// parseLyricsTime returns a time tag, e.g. minutes "01" and seconds "23.45", in ticks.
+func parseLyricsTime(minutes, seconds string) (int64, bool) {
+	min, err := strconv.Atoi(strings.TrimSpace(minutes))
+	if err != nil {
+		return 0, false
+	}
+	// Some files use a colon as fraction separator, e.g. "[01:23:45]"
+	sec, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(seconds), ":", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Duration(min)*time.Minute + time.Duration(sec*float64(time.Second))
+	return d.Nanoseconds() / 100, true
+}
@@ -0,0 +1,162 @@
+package jellyfin
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/collection/metadata"
+)
+
+const (
+	// metadataRefreshInterval is the wait time between runs of the metadata refresh task.
+	metadataRefreshInterval = 24 * time.Hour
+	// metadataRefreshTaskID is the id of the metadata refresh task in the scheduled tasks API.
+	metadataRefreshTaskID = "8d1f3b5a7c9e4f20b6a8d0c2e4f6a8b1"
+)
+
+// MetadataRefreshBackground refreshes the metadata of items in collections with a refresh interval
+// once it is older than that interval.
+func (j *Jellyfin) MetadataRefreshBackground(ctx context.Context) {
+	for {
+		j.refreshMetadata(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.metadataTask.trigger:
+		case <-time.After(metadataRefreshInterval):
+		}
+	}
+}
+
+// triggerMetadataRefresh starts a metadata refresh run, returns false if a run is already in progress.
+func (j *Jellyfin) triggerMetadataRefresh() bool {
+	j.metadataTask.mu.Lock()
+	running := j.metadataTask.running
+	j.metadataTask.mu.Unlock()
+	if running {
+		return false
+	}
+	select {
+	case j.metadataTask.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// refreshMetadata reads the metadata files of stale items again. If a person provider is configured
+// the persons of refreshed movies and shows are looked up again as well, unless that happened
+// during the last metadataRefreshInterval.
+func (j *Jellyfin) refreshMetadata(ctx context.Context) {
+	j.metadataTask.mu.Lock()
+	j.metadataTask.running = true
+	j.metadataTask.start = time.Now().UTC()
+	j.metadataTask.mu.Unlock()
+
+	status := "Completed"
+	result, err := j.collections.RefreshStaleMetadata(ctx)
+	failed := result.Failed
+
+	var persons int
+	if err == nil && j.personProvider != nil {
+		for _, name := range refreshedPersons(result.Refreshed) {
+			if _, ok := j.personThumb(name); ok {
+				continue
+			}
+			if person, err := j.repo.GetPersonByName(ctx, name, ""); err == nil && time.Since(person.LastUpdated) < metadataRefreshInterval {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(personRefreshDelay):
+			}
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			if err := j.lookupPerson(ctx, name); err != nil {
+				log.Printf("Metadata refresh of person %s failed: %s", name, err)
+				failed++
+				continue
+			}
+			persons++
+		}
+	}
+	switch {
+	case err != nil:
+		status = "Cancelled"
+	case failed > 0:
+		status = "CompletedWithErrors"
+	}
+	log.Printf("Metadata refresh %s, %d items, %d refreshed, %d persons updated, %d failed",
+		status, result.Checked, len(result.Refreshed), persons, failed)
+
+	j.metadataTask.mu.Lock()
+	j.metadataTask.running = false
+	j.metadataTask.end = time.Now().UTC()
+	j.metadataTask.status = status
+	j.metadataTask.mu.Unlock()
+}
+
+// refreshedPersons returns the names of the actors, directors and writers of refreshed movies and shows.
+func refreshedPersons(items []collection.Item) []string {
+	var names []string
+	add := func(m metadata.Metadata) {
+		if m == nil {
+			return
+		}
+		for name := range m.Actors() {
+			names = append(names, name)
+		}
+		names = append(names, m.Directors()...)
+		names = append(names, m.Writers()...)
+	}
+	for _, i := range items {
+		switch v := i.(type) {
+		case *collection.Movie:
+			add(v.Metadata)
+		case *collection.Show:
+			add(v.Metadata)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// metadataTaskResponse returns the metadata refresh task as scheduled task.
+func (j *Jellyfin) metadataTaskResponse() JFScheduledTasksResponse {
+	j.metadataTask.mu.Lock()
+	defer j.metadataTask.mu.Unlock()
+
+	const name = "Refresh metadata"
+	const key = "RefreshMetadata"
+	response := JFScheduledTasksResponse{
+		Name:        name,
+		State:       "Idle",
+		ID:          metadataRefreshTaskID,
+		Description: "Reads metadata of items again once it is older than the refresh interval of their library.",
+		Category:    "Library",
+		Key:         key,
+		Triggers: []ScheduledTaskTrigger{
+			{
+				Type:          "IntervalTrigger",
+				IntervalTicks: int64(metadataRefreshInterval / 100),
+			},
+		},
+	}
+	if j.metadataTask.running {
+		response.State = "Running"
+	}
+	if !j.metadataTask.end.IsZero() {
+		response.LastExecutionResult = ScheduledTaskLastExecutionResult{
+			StartTimeUtc: j.metadataTask.start,
+			EndTimeUtc:   j.metadataTask.end,
+			Status:       j.metadataTask.status,
+			Name:         name,
+			Key:          key,
+			ID:           metadataRefreshTaskID,
+		}
+	}
+	return response
+}
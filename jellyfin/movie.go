@@ -3,19 +3,249 @@ package jellyfin
 import (
 	"context"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 
 	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+const (
+	// recommendationCategoryLimit is the number of recommendation categories returned in case no limit is provided.
+	recommendationCategoryLimit = 5
+	// recommendationItemLimit is the number of items per recommendation category in case no limit is provided.
+	recommendationItemLimit = 8
+	// recommendationHistoryCount is the number of recently watched items to base recommendations on.
+	recommendationHistoryCount = 200
 )
 
 // /Movies/Recommendations
 //
-// moviesRecommendationsHandler returns a list of recommended movie items
+// Supported query params:
+// - parentId, if provided scope recommendations to this collection
+// - categoryLimit, number of categories to return, defaults to 5
+// - itemLimit, number of items per category, defaults to 8
+//
+// moviesRecommendationsHandler returns categories of recommended movies based upon
+// the watch history and favorites of the user: "Because you watched X",
+// "Because you like X" and top rated unwatched movies of the user's favorite genres.
 func (j *Jellyfin) moviesRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
-	// Not implemented
-	response := []JFItem{}
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+
+	queryparams := r.URL.Query()
+	categoryLimit := recommendationCategoryLimit
+	if l, err := strconv.Atoi(queryparams.Get("categoryLimit")); err == nil && l > 0 {
+		categoryLimit = l
+	}
+	itemLimit := recommendationItemLimit
+	if l, err := strconv.Atoi(queryparams.Get("itemLimit")); err == nil && l > 0 {
+		itemLimit = l
+	}
+
+	// Collections to recommend from
+	var collections []*collection.Collection
+	for _, c := range j.collections.GetCollections() {
+		if c.Type != collection.CollectionTypeMovies {
+			continue
+		}
+		if parentID := queryparams.Get("parentId"); parentID != "" && trimPrefix(parentID) != c.ID {
+			continue
+		}
+		collections = append(collections, j.collections.GetCollection(c.ID))
+	}
+
+	ctx := r.Context()
+	userID := reqCtx.User.ID
+
+	// Movies the user has started or watched do not get recommended
+	watchedIDs, err := j.repo.GetRecentlyWatched(ctx, userID, recommendationHistoryCount, true)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[string]bool, len(watchedIDs))
+	for _, id := range watchedIDs {
+		seen[id] = true
+	}
+	// Liked movies are used as baseline like favorites, disliked movies do not get recommended
+	favoriteIDs, _ := j.repo.GetFavorites(ctx, userID)
+	likedIDs, dislikedIDs, _ := j.repo.GetLikes(ctx, userID)
+	for _, id := range likedIDs {
+		if !slices.Contains(favoriteIDs, id) {
+			favoriteIDs = append(favoriteIDs, id)
+		}
+	}
+	for _, id := range dislikedIDs {
+		seen[id] = true
+	}
+
+	recentlyWatched := j.makeJFRecommendationsSimilar(ctx, reqCtx.User, collections, watchedIDs,
+		recommendationTypeSimilarToRecentlyPlayed, seen, categoryLimit, itemLimit)
+	liked := j.makeJFRecommendationsSimilar(ctx, reqCtx.User, collections, favoriteIDs,
+		recommendationTypeSimilarToLikedItem, seen, categoryLimit, itemLimit)
+	genres := j.makeJFRecommendationsGenre(ctx, reqCtx.User, collections, watchedIDs,
+		seen, categoryLimit, itemLimit)
+
+	// Interleave the different kinds of recommendations
+	response := make([]JFRecommendation, 0, categoryLimit)
+	usedBaselines := make(map[string]bool)
+	for i := 0; len(response) < categoryLimit; i++ {
+		added := false
+		for _, categories := range [][]JFRecommendation{recentlyWatched, genres, liked} {
+			if i >= len(categories) || len(response) >= categoryLimit {
+				continue
+			}
+			added = true
+			if usedBaselines[categories[i].BaselineItemName] {
+				continue
+			}
+			usedBaselines[categories[i].BaselineItemName] = true
+			response = append(response, categories[i])
+		}
+		if !added {
+			break
+		}
+	}
 	serveJSON(response, w)
 }
 
+// makeJFRecommendationsSimilar returns a recommendation category with similar movies
+// for each of the provided baseline movies, up to categoryLimit categories.
+func (j *Jellyfin) makeJFRecommendationsSimilar(ctx context.Context, user *model.User, collections []*collection.Collection,
+	baselineIDs []string, recommendationType string, seen map[string]bool, categoryLimit, itemLimit int) []JFRecommendation {
+
+	categories := make([]JFRecommendation, 0, categoryLimit)
+	for _, baselineID := range baselineIDs {
+		if len(categories) >= categoryLimit {
+			break
+		}
+		c, movie := j.findRecommendationMovie(collections, baselineID)
+		if movie == nil {
+			continue
+		}
+		// Ask for extra items as seen movies are left out
+		similarIDs, err := j.collections.Similar(ctx, c, movie, itemLimit+len(seen))
+		if err != nil {
+			continue
+		}
+		items := make([]JFItem, 0, itemLimit)
+		for _, id := range similarIDs {
+			if seen[id] {
+				continue
+			}
+			if jfitem, err := j.makeJFItemByID(ctx, user.ID, id); err == nil {
+				items = append(items, jfitem)
+			}
+		}
+		items = j.applyParentalRating(items, user)
+		items = j.applyHiddenItems(items, user)
+		items = j.applyPinLock(ctx, items)
+		if len(items) == 0 {
+			continue
+		}
+		categories = append(categories, JFRecommendation{
+			Items:              items[:min(len(items), itemLimit)],
+			RecommendationType: recommendationType,
+			BaselineItemName:   movie.Name(),
+			CategoryID:         idhash.Hash(recommendationType + movie.ID()),
+		})
+	}
+	return categories
+}
+
+// makeJFRecommendationsGenre returns a recommendation category with the top rated unseen movies
+// for each of the genres the user watches most, up to categoryLimit categories.
+func (j *Jellyfin) makeJFRecommendationsGenre(ctx context.Context, user *model.User, collections []*collection.Collection,
+	watchedIDs []string, seen map[string]bool, categoryLimit, itemLimit int) []JFRecommendation {
+
+	genreCount := make(map[string]int)
+	for _, id := range watchedIDs {
+		if _, movie := j.findRecommendationMovie(collections, id); movie != nil {
+			for _, g := range movie.Genres() {
+				if g != "" {
+					genreCount[g]++
+				}
+			}
+		}
+	}
+	genres := make([]string, 0, len(genreCount))
+	for g := range genreCount {
+		genres = append(genres, g)
+	}
+	sort.Slice(genres, func(a, b int) bool {
+		if genreCount[genres[a]] != genreCount[genres[b]] {
+			return genreCount[genres[a]] > genreCount[genres[b]]
+		}
+		return genres[a] < genres[b]
+	})
+
+	categories := make([]JFRecommendation, 0, categoryLimit)
+	for _, genre := range genres {
+		if len(categories) >= categoryLimit {
+			break
+		}
+		// Filter movies before building items, only itemLimit items are needed
+		var movies []*collection.Movie
+		parentIDs := make(map[string]string)
+		for _, c := range collections {
+			if j.pinLocked(ctx, c.ID) {
+				continue
+			}
+			for _, i := range c.Items {
+				movie, ok := i.(*collection.Movie)
+				if !ok || seen[movie.ID()] || !slices.Contains(movie.Genres(), genre) {
+					continue
+				}
+				if !j.parentalRatingAllowed(movie.OfficialRating(), user) || j.itemHidden(movie.ID(), user) {
+					continue
+				}
+				movies = append(movies, movie)
+				parentIDs[movie.ID()] = c.ID
+			}
+		}
+		sort.SliceStable(movies, func(a, b int) bool {
+			return movies[a].Rating() > movies[b].Rating()
+		})
+
+		items := make([]JFItem, 0, itemLimit)
+		for _, movie := range movies {
+			if len(items) >= itemLimit {
+				break
+			}
+			if jfitem, err := j.makeJFItemMovie(ctx, user.ID, movie, parentIDs[movie.ID()]); err == nil {
+				items = append(items, jfitem)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		categories = append(categories, JFRecommendation{
+			Items:              items,
+			RecommendationType: recommendationTypeSimilarToLikedItem,
+			BaselineItemName:   genre,
+			CategoryID:         idhash.Hash(recommendationTypeSimilarToLikedItem + genre),
+		})
+	}
+	return categories
+}
+
+// findRecommendationMovie returns a movie by ID if it is part of one of the provided collections.
+func (j *Jellyfin) findRecommendationMovie(collections []*collection.Collection, itemID string) (*collection.Collection, *collection.Movie) {
+	for _, c := range collections {
+		for _, i := range c.Items {
+			if movie, ok := i.(*collection.Movie); ok && movie.ID() == itemID {
+				return c, movie
+			}
+		}
+	}
+	return nil, nil
+}
+
 // makeJFItem make movie item
 func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *collection.Movie, parentID string) (response JFItem, e error) {
 	response = JFItem{
@@ -26,10 +256,11 @@ func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *co
 		Name:                    movie.Name(),
 		OriginalTitle:           movie.Name(),
 		SortName:                movie.SortName(),
-		ForcedSortName:          movie.SortName(),
+		ForcedSortName:          j.collections.SortNameOverride(movie.ID()),
 		Genres:                  movie.Metadata.Genres(),
 		GenreItems:              makeJFGenreItems(movie.Metadata.Genres()),
 		Studios:                 makeJFStudios(movie.Metadata.Studios()),
+		ProductionLocations:     movie.Metadata.Countries(),
 		IsHD:                    itemIsHD(movie),
 		Is4K:                    itemIs4K(movie),
 		RunTimeTicks:            makeRuntimeTicks(movie.Duration()),
@@ -42,7 +273,7 @@ func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *co
 		Container:               "mov,mp4,m4a",
 		DateCreated:             movie.Created().UTC(),
 		PrimaryImageAspectRatio: 0.6666666666666666,
-		CanDelete:               false,
+		CanDelete:               j.deleteFiles,
 		CanDownload:             true,
 		PlayAccess:              "Full",
 		ImageTags: &JFImageTags{
@@ -66,8 +297,8 @@ func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *co
 		Tags:              []string{},
 		Taglines:          []string{movie.Metadata.Tagline()},
 		Trickplay:         []string{},
-		LockedFields:      []string{},
 	}
+	response.LockData, response.LockedFields = j.makeJFLockedFields(movie.ID())
 
 	// Metadata might have a better title
 	if movie.Metadata.Title() != "" {
@@ -75,7 +306,7 @@ func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *co
 	}
 
 	// Set premiere date from metadata if available else from file timestamp
-	if !movie.Metadata.Premiered().IsZero() {
+	if movie.Metadata.Premiered().Known() {
 		response.PremiereDate = movie.Metadata.Premiered().UTC()
 	} else {
 		response.PremiereDate = movie.Created().UTC()
@@ -90,6 +321,11 @@ func (j *Jellyfin) makeJFItemMovie(ctx context.Context, userID string, movie *co
 	response.MediaSources = j.makeMediaSource(movie)
 	response.MediaStreams = response.MediaSources[0].MediaStreams
 
+	// Multi-part movie, additional parts are available via /Videos/{item}/AdditionalParts
+	if parts := len(movie.AdditionalParts()); parts > 0 {
+		response.PartCount = 1 + parts
+	}
+
 	if playstate, err := j.repo.GetUserData(ctx, userID, movie.ID()); err == nil {
 		response.UserData = j.makeJFUserData(userID, movie.ID(), playstate)
 	} else {
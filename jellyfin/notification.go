@@ -0,0 +1,330 @@
+package jellyfin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// Security events administrators can be notified of.
+const (
+	// notificationFailedLogins is sent when a user fails to log in repeatedly.
+	notificationFailedLogins = "failedlogins"
+	// notificationTokenReuse is sent when an access token is used from another IP address than before.
+	notificationTokenReuse = "tokenreuse"
+	// notificationNewDevice is sent when a user logs in on a device without a session.
+	notificationNewDevice = "newdevice"
+)
+
+const (
+	// defaultFailedLogins is the number of failed logins within the window that triggers a notification.
+	defaultFailedLogins = 3
+	// defaultFailedLoginWindow is the period failed logins are counted in.
+	defaultFailedLoginWindow = 15 * time.Minute
+	// webhookTimeout is the maximum time a webhook may take.
+	webhookTimeout = 10 * time.Second
+)
+
+// notificationEvents are the events webhooks can subscribe to.
+var notificationEvents = []string{notificationFailedLogins, notificationTokenReuse, notificationNewDevice}
+
+// Notifications holds the webhooks that alert administrators of security events.
+type Notifications struct {
+	// Webhooks are called on security events
+	Webhooks []Webhook
+	// FailedLogins is the number of failed logins of a user within FailedLoginWindow that is notified, defaults to 3
+	FailedLogins int
+	// FailedLoginWindow is the period failed logins are counted in, defaults to 15 minutes
+	FailedLoginWindow time.Duration
+}
+
+// Webhook is a URL that is posted security events.
+type Webhook struct {
+	// URL is called with a POST request per event
+	URL string
+	// Events are the events to post, all events if empty
+	Events []string
+	// ContentType is the content type of the request body, defaults to application/json
+	ContentType string
+	// Templates are Go templates of the request body by event, events without template are posted as JSON
+	Templates map[string]string
+}
+
+// NotificationEvent is a security event posted to webhooks, and the data of webhook templates.
+type NotificationEvent struct {
+	Event           string    `json:"Event"`
+	Message         string    `json:"Message"`
+	ServerName      string    `json:"ServerName"`
+	UserID          string    `json:"UserId,omitempty"`
+	Username        string    `json:"Username"`
+	Device          string    `json:"Device,omitempty"`
+	DeviceID        string    `json:"DeviceId,omitempty"`
+	Client          string    `json:"Client,omitempty"`
+	RemoteAddress   string    `json:"RemoteAddress"`
+	PreviousAddress string    `json:"PreviousAddress,omitempty"`
+	FailedLogins    int       `json:"FailedLogins,omitempty"`
+	Date            time.Time `json:"Date"`
+}
+
+// webhook is a configured webhook with its parsed templates.
+type webhook struct {
+	Webhook
+	// name identifies the webhook in logs without revealing tokens in its URL
+	name      string
+	templates map[string]*template.Template
+}
+
+// webhookName returns the name of a webhook in logs, its index and the scheme and host of its URL.
+func webhookName(index int, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d (%s://%s)", index+1, u.Scheme, u.Host)
+}
+
+// templateFuncs are the functions available in webhook templates, json quotes a value
+// so it can be used in a JSON body.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Validate checks the events and templates of the webhooks.
+func (n Notifications) Validate() error {
+	var errs []error
+	for i, hook := range n.Webhooks {
+		if hook.URL == "" {
+			errs = append(errs, fmt.Errorf("webhook %d has no url", i+1))
+		}
+		for _, event := range hook.Events {
+			if !slices.Contains(notificationEvents, event) {
+				errs = append(errs, fmt.Errorf("webhook %d has unknown event %q", i+1, event))
+			}
+		}
+		for event, text := range hook.Templates {
+			if !slices.Contains(notificationEvents, event) {
+				errs = append(errs, fmt.Errorf("webhook %d has template for unknown event %q", i+1, event))
+			}
+			if _, err := template.New(event).Funcs(templateFuncs).Parse(text); err != nil {
+				errs = append(errs, fmt.Errorf("webhook %d template %s: %w", i+1, event, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// makeWebhooks parses the templates of the configured webhooks, webhooks with invalid
+// templates are left out.
+func makeWebhooks(n Notifications) []webhook {
+	var webhooks []webhook
+	for i, hook := range n.Webhooks {
+		w := webhook{Webhook: hook, name: webhookName(i, hook.URL), templates: make(map[string]*template.Template)}
+		if w.ContentType == "" {
+			w.ContentType = "application/json"
+		}
+		valid := true
+		for event, text := range hook.Templates {
+			t, err := template.New(event).Funcs(templateFuncs).Parse(text)
+			if err != nil {
+				log.Printf("Webhook %s disabled, template %s: %s", w.name, event, err)
+				valid = false
+				break
+			}
+			w.templates[event] = t
+		}
+		if valid {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks
+}
+
+// subscribed returns true if a webhook posts an event.
+func (w *webhook) subscribed(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// notifying returns true if any webhook posts an event.
+func (j *Jellyfin) notifying(event string) bool {
+	for i := range j.webhooks {
+		if j.webhooks[i].subscribed(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// notify posts an event to the webhooks subscribed to it, in the background.
+func (j *Jellyfin) notify(event NotificationEvent) {
+	event.ServerName = j.ServerName()
+	event.Date = time.Now().UTC()
+	for i := range j.webhooks {
+		hook := &j.webhooks[i]
+		if !hook.subscribed(event.Event) {
+			continue
+		}
+		go func() {
+			if err := hook.post(event); err != nil {
+				log.Printf("Webhook %s, event %s failed: %s", hook.name, event.Event, err)
+			}
+		}()
+	}
+}
+
+// post sends an event to a webhook, using the template of the event if it has one.
+func (w *webhook) post(event NotificationEvent) error {
+	var body bytes.Buffer
+	if t, ok := w.templates[event.Event]; ok {
+		if err := t.Execute(&body, event); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(&body).Encode(event); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, &body)
+	if err != nil {
+		return withoutURL(err)
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// withoutURL strips the URL from request errors, as webhook URLs often contain tokens.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// notifyFailedLogin counts a failed login of a user, and notifies once the number of
+// failed logins within the window reaches the threshold. The count starts over after that.
+func (j *Jellyfin) notifyFailedLogin(r *http.Request, username, userID string) {
+	if !j.notifying(notificationFailedLogins) {
+		return
+	}
+	now := time.Now()
+	j.failedLoginsMu.Lock()
+	j.pruneFailedLogins(now, username)
+	attempts := append(j.failedLogins[username], now)
+	count := len(attempts)
+	if count >= j.notifications.FailedLogins {
+		delete(j.failedLogins, username)
+	} else {
+		j.failedLogins[username] = attempts
+	}
+	j.failedLoginsMu.Unlock()
+
+	if count < j.notifications.FailedLogins {
+		return
+	}
+	authHeader, _ := j.parseAuthHeader(r)
+	if authHeader == nil {
+		authHeader = &authSchemeValues{}
+	}
+	j.notify(NotificationEvent{
+		Event: notificationFailedLogins,
+		Message: fmt.Sprintf("%d failed login attempts for %s within %s, last from %s",
+			count, username, j.notifications.FailedLoginWindow, remoteIP(r)),
+		UserID:        userID,
+		Username:      username,
+		Device:        authHeader.device,
+		DeviceID:      authHeader.deviceID,
+		Client:        authHeader.client,
+		RemoteAddress: remoteIP(r),
+		FailedLogins:  count,
+	})
+}
+
+// maxFailedLoginUsers is the maximum number of usernames failed logins are counted for.
+// Logins with random usernames should not grow memory use without bound.
+const maxFailedLoginUsers = 1000
+
+// pruneFailedLogins removes failed logins outside the window of all usernames, failedLoginsMu
+// must be held. If failed logins are counted for too many usernames, the username with the
+// oldest last attempt is dropped to make room for username.
+func (j *Jellyfin) pruneFailedLogins(now time.Time, username string) {
+	for name, attempts := range j.failedLogins {
+		attempts = slices.DeleteFunc(attempts, func(t time.Time) bool {
+			return now.Sub(t) > j.notifications.FailedLoginWindow
+		})
+		if len(attempts) == 0 {
+			delete(j.failedLogins, name)
+		} else {
+			j.failedLogins[name] = attempts
+		}
+	}
+	if _, ok := j.failedLogins[username]; ok || len(j.failedLogins) < maxFailedLoginUsers {
+		return
+	}
+	var oldest string
+	var oldestAttempt time.Time
+	for name, attempts := range j.failedLogins {
+		if last := attempts[len(attempts)-1]; oldest == "" || last.Before(oldestAttempt) {
+			oldest, oldestAttempt = name, last
+		}
+	}
+	delete(j.failedLogins, oldest)
+}
+
+// notifyNewDevice notifies a login of a user on a device without a session.
+func (j *Jellyfin) notifyNewDevice(user *model.User, token *model.AccessToken) {
+	if !j.notifying(notificationNewDevice) {
+		return
+	}
+	j.notify(NotificationEvent{
+		Event: notificationNewDevice,
+		Message: fmt.Sprintf("%s logged in on new device %s (%s) from %s",
+			user.Username, token.DeviceName, token.ApplicationName, token.RemoteAddress),
+		UserID:        user.ID,
+		Username:      user.Username,
+		Device:        token.DeviceName,
+		DeviceID:      token.DeviceId,
+		Client:        token.ApplicationName,
+		RemoteAddress: token.RemoteAddress,
+	})
+}
+
+// notifyTokenReuse notifies use of an access token from another IP address than it was last used from.
+func (j *Jellyfin) notifyTokenReuse(user *model.User, token *model.AccessToken, previousAddress string) {
+	if !j.notifying(notificationTokenReuse) {
+		return
+	}
+	j.notify(NotificationEvent{
+		Event: notificationTokenReuse,
+		Message: fmt.Sprintf("Access token of %s on device %s used from %s, previously from %s",
+			user.Username, token.DeviceName, token.RemoteAddress, previousAddress),
+		UserID:          user.ID,
+		Username:        user.Username,
+		Device:          token.DeviceName,
+		DeviceID:        token.DeviceId,
+		Client:          token.ApplicationName,
+		RemoteAddress:   token.RemoteAddress,
+		PreviousAddress: previousAddress,
+	})
+}
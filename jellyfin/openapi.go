@@ -0,0 +1,338 @@
+package jellyfin
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIResponses are the response bodies of endpoints, by method and path template.
+// Endpoints not listed are documented without response body.
+var openAPIResponses = map[string]any{
+	"GET /GetUtcTime":                                       JFGetUtcTimeResponse{},
+	"GET /System/Endpoint":                                  JFSystemEndpointResponse{},
+	"GET /System/Info":                                      JFSystemInfoResponse{},
+	"GET /System/Info/Public":                               JFSystemInfoPublicResponse{},
+	"GET /System/ActivityLog/Entries":                       JFActivityLogEntryResponse{},
+	"GET /System/Logs":                                      []string{},
+	"GET /PlaybackHistory":                                  JFPlaybackHistorySummaryResponse{},
+	"GET /Plugins":                                          []JFPluginResponse{},
+	"GET /ScheduledTasks":                                   []JFScheduledTasksResponse{},
+	"POST /Users/AuthenticateByName":                        JFAuthenticateByNameResponse{},
+	"POST /Users/AuthenticateWithQuickConnect":              JFAuthenticateByNameResponse{},
+	"GET /QuickConnect/Connect":                             JFQuickconnectResponse{},
+	"GET /QuickConnect/Enabled":                             true,
+	"POST /QuickConnect/Initiate":                           JFQuickconnectResponse{},
+	"GET /Users":                                            []JFUser{},
+	"POST /Users":                                           JFUser{},
+	"GET /Users/Me":                                         JFUser{},
+	"POST /Users/New":                                       JFUser{},
+	"GET /Users/Public":                                     []JFUser{},
+	"GET /Users/{userid}":                                   JFUser{},
+	"GET /Users/{userid}/Views":                             JFUserViewsResponse{},
+	"GET /Users/{userid}/GroupingOptions":                   []JFCollection{},
+	"GET /Users/{userid}/Items":                             UserItemsResponse{},
+	"GET /Users/{userid}/Items/Intros":                      UserItemsResponse{},
+	"GET /Users/{userid}/Items/Latest":                      []JFItem{},
+	"GET /Users/{userid}/Items/Resume":                      JFUsersItemsResumeResponse{},
+	"GET /Users/{userid}/Items/Suggestions":                 JFUsersItemsSuggestionsResponse{},
+	"GET /Users/{userid}/Items/{itemid}":                    JFItem{},
+	"GET /Users/{userid}/Items/{itemid}/PlaybackHistory":    JFPlaybackHistoryResponse{},
+	"GET /UserViews":                                        JFUserViewsResponse{},
+	"GET /UserViews/GroupingOptions":                        []JFCollection{},
+	"GET /UserItems/Resume":                                 JFUsersItemsResumeResponse{},
+	"POST /UserItems/Sync":                                  []JFUserData{},
+	"POST /UserItems/{itemid}/UserData":                     JFUserData{},
+	"GET /UserItems/{itemid}/UserData":                      JFUserData{},
+	"GET /DisplayPreferences/{id}":                          DisplayPreferencesResponse{},
+	"GET /Library/MediaFolders":                             JFUserViewsResponse{},
+	"GET /Library/VirtualFolders":                           []JFMediaLibrary{},
+	"GET /Library/SmartCollections":                         []SmartCollection{},
+	"POST /Library/SmartCollections":                        SmartCollection{},
+	"GET /Shows/NextUp":                                     JFShowsNextUpResponse{},
+	"GET /Shows/{showid}/Seasons":                           UserItemsResponse{},
+	"GET /Shows/{showid}/Episodes":                          UserItemsResponse{},
+	"GET /Items":                                            UserItemsResponse{},
+	"GET /Items/Counts":                                     JFItemCountResponse{},
+	"GET /Items/Filters":                                    JFItemFilterResponse{},
+	"GET /Items/Filters2":                                   JFItemFilter2Response{},
+	"GET /Items/Latest":                                     []JFItem{},
+	"GET /Items/Root":                                       JFItem{},
+	"GET /Items/Suggestions":                                JFUsersItemsSuggestionsResponse{},
+	"GET /Items/{itemid}":                                   JFItem{},
+	"GET /Items/{itemid}/Ancestors":                         []JFItem{},
+	"GET /Items/{itemid}/Images":                            []JFResponseItemImages{},
+	"GET /Items/{itemid}/InstantMix":                        UserItemsResponse{},
+	"GET /Items/{itemid}/Intros":                            UserItemsResponse{},
+	"GET /Items/{itemid}/LocalTrailers":                     []JFItem{},
+	"GET /Items/{itemid}/PlaybackInfo":                      JFPlaybackInfoResponse{},
+	"GET /Items/{itemid}/RemoteImages":                      JFResponseItemRemoteImages{},
+	"GET /Items/{itemid}/RemoteImages/Providers":            JFResponseItemRemoteImagesProviders{},
+	"POST /Items/RemoteSearch/Movie":                        []JFRemoteSearchResult{},
+	"GET /Items/{itemid}/RemoteSearch/Subtitles/{language}": []JFRemoteSubtitleInfo{},
+	"GET /Items/{itemid}/Shuffle":                           UserItemsResponse{},
+	"GET /Items/{itemid}/Similar":                           JFUsersItemsSimilarResponse{},
+	"GET /Items/{itemid}/SpecialFeatures":                   []JFItem{},
+	"GET /Items/{itemid}/ThemeMedia":                        JFItemThemeMediaResponse{},
+	"GET /Genres":                                           UserItemsResponse{},
+	"GET /Genres/{name}":                                    JFItem{},
+	"GET /Studios":                                          UserItemsResponse{},
+	"GET /Studios/{name}":                                   JFItem{},
+	"GET /Search/Hints":                                     SearchHintsResponse{},
+	"GET /Movies/Recommendations":                           []JFRecommendation{},
+	"GET /MediaSegments/{itemid}":                           JFMediaSegmentsResponse{},
+	"GET /Videos/{itemid}/AdditionalParts":                  UserItemsResponse{},
+	"GET /Persons":                                          UserItemsResponse{},
+	"GET /Persons/{name}":                                   JFItem{},
+	"GET /Devices/Info":                                     JFDeviceItem{},
+	"GET /Devices":                                          JFDeviceInfoResponse{},
+	"GET /Sessions":                                         []JFSessionInfo{},
+	"POST /UserFavoriteItems/{itemid}":                      JFUserData{},
+	"DELETE /UserFavoriteItems/{itemid}":                    JFUserData{},
+	"POST /Users/{user}/FavoriteItems/{itemid}":             JFUserData{},
+	"DELETE /Users/{user}/FavoriteItems/{itemid}":           JFUserData{},
+	"POST /Collections":                                     JFCreateBoxSetResponse{},
+	"POST /Playlists":                                       JFCreatePlaylistResponse{},
+	"GET /Playlists/{playlistid}":                           JFGetPlaylistResponse{},
+	"GET /Playlists/{playlistid}/Items":                     UserItemsResponse{},
+	"GET /Playlists/{playlistid}/Users":                     []JFPlaylistAccess{},
+	"GET /Playlists/{playlistid}/Users/{userid}":            JFPlaylistAccess{},
+	"GET /Jellofin/Export/UserData":                         UserDataExport{},
+	"GET /Jellofin/HiddenItems":                             UserItemsResponse{},
+	"GET /Jellofin/Items/{itemid}/Source":                   JFItemSourceResponse{},
+	"GET /Jellofin/ItemIdCollisions":                        []JFItemIDCollision{},
+	"GET /Jellofin/Pin":                                     JFPinStatusResponse{},
+	"GET /Jellofin/ImageCache":                              JFImageCacheResponse{},
+	"GET /Branding/Configuration":                           JFBrandingConfigurationResponse{},
+	"GET /Localization/Countries":                           []JFCountry{},
+	"GET /Localization/Cultures":                            []JFLanguage{},
+	"GET /Localization/Options":                             []JFLocalizationOptions{},
+	"GET /Localization/ParentalRatings":                     []JFLocalizationParentalRatings{},
+	"GET /Channels":                                         UserItemsResponse{},
+	"GET /Channels/Features":                                []string{},
+	"GET /Channels/Items/Latest":                            UserItemsResponse{},
+	"GET /Channels/{channelid}/Items":                       UserItemsResponse{},
+	"GET /LiveTv/Info":                                      JFLiveTvInfo{},
+	"GET /LiveTv/Channels":                                  UserItemsResponse{},
+	"GET /LiveTv/Programs":                                  UserItemsResponse{},
+	"GET /LiveTv/Programs/Recommended":                      UserItemsResponse{},
+	"GET /LiveTv/Recordings":                                UserItemsResponse{},
+	"GET /LiveTv/Timers":                                    UserItemsResponse{},
+	"GET /LiveTv/SeriesTimers":                              UserItemsResponse{},
+	"GET /SyncPlay/List":                                    []string{},
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIOperation struct {
+	Parameters []openAPIParameter         `json:"parameters,omitempty"`
+	Responses  map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   map[string]any `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema map[string]any `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas openAPISchemas `json:"schemas"`
+}
+
+// openAPISchemas holds the schemas of named structs, by type name.
+type openAPISchemas map[string]map[string]any
+
+// pathParameter matches a variable in a route path template, e.g. "{itemid}".
+var pathParameter = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// /openapi.json
+//
+// openAPIHandler returns an OpenAPI document describing the endpoints this server supports.
+func (j *Jellyfin) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	serveJSON(j.openAPI, w)
+}
+
+// countRoutes returns the number of routes registered on a router.
+func countRoutes(r *mux.Router) (count int) {
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		count++
+		return nil
+	})
+	return
+}
+
+// makeOpenAPIDocument creates an OpenAPI document of the routes registered on a router,
+// skipping the first skipRoutes routes as they belong to other APIs.
+func makeOpenAPIDocument(r *mux.Router, skipRoutes int) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.1",
+		Info: openAPIInfo{
+			Title:       "Jellofin",
+			Description: "Subset of the Jellyfin API supported by this server.",
+			Version:     serverVersion,
+		},
+		Paths: make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: make(openAPISchemas),
+		},
+	}
+
+	index := 0
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		index++
+		if index <= skipRoutes {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Routes without method restriction are documented as GET
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+
+		var parameters []openAPIParameter
+		for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+			parameters = append(parameters, openAPIParameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   map[string]any{"type": "string"},
+			})
+		}
+		path = pathParameter.ReplaceAllString(path, "{$1}")
+
+		for _, method := range methods {
+			if method == http.MethodHead {
+				continue
+			}
+			response := openAPIResponse{Description: "Success"}
+			if body, ok := openAPIResponses[method+" "+path]; ok {
+				response.Content = map[string]openAPIMediaType{
+					"application/json": {Schema: doc.Components.Schemas.schema(reflect.TypeOf(body))},
+				}
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]openAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(method)] = openAPIOperation{
+				Parameters: parameters,
+				Responses:  map[string]openAPIResponse{"200": response},
+			}
+		}
+		return nil
+	})
+	return doc
+}
+
+// schema returns the schema of a type, named structs are added to the schemas and referenced.
+func (s openAPISchemas) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, found := s[t.Name()]; !found {
+			// Register the name first so recursive types end up referencing themselves
+			s[t.Name()] = map[string]any{}
+			s[t.Name()] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the schema of a struct based upon the json tags of its fields.
+func (s openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	s.addStructFields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addStructFields adds the fields of a struct, including those of embedded structs, as properties.
+// Fields that are always present in a response are added to required.
+func (s openAPISchemas) addStructFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			s.addStructFields(fieldType, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
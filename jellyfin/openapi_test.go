@@ -0,0 +1,26 @@
+package jellyfin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIResponsesMatchRoutes(t *testing.T) {
+	j := New(&Options{})
+	r := mux.NewRouter()
+	j.RegisterHandlers(r)
+
+	doc := makeOpenAPIDocument(r, 0)
+	for key := range openAPIResponses {
+		method, path, ok := strings.Cut(key, " ")
+		if !ok {
+			t.Errorf("response %q is not formatted as \"METHOD /path\"", key)
+			continue
+		}
+		if _, found := doc.Paths[path][strings.ToLower(method)]; !found {
+			t.Errorf("response %q does not match a registered route", key)
+		}
+	}
+}
@@ -2,14 +2,17 @@ package jellyfin
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"unicode"
 
 	"github.com/gorilla/mux"
 
+	"github.com/erikbos/jellofin-server/collection"
 	"github.com/erikbos/jellofin-server/collection/metadata"
 )
 
@@ -77,7 +80,7 @@ func (j *Jellyfin) personsHandler(w http.ResponseWriter, r *http.Request) {
 	serveJSON(response, w)
 }
 
-// /Person/{name}
+// /Persons/{name}
 //
 // personHandler returns details of a specific person
 func (j *Jellyfin) personHandler(w http.ResponseWriter, r *http.Request) {
@@ -99,7 +102,7 @@ func (j *Jellyfin) personHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	response, err := j.makeJFItemPerson(r.Context(), reqCtx.User.ID, makeJFPersonID(name))
 	if err != nil {
-		apierror(w, "could not create person item", http.StatusInternalServerError)
+		apierror(w, err.Error(), http.StatusNotFound)
 		return
 	}
 	serveJSON(response, w)
@@ -135,14 +138,25 @@ func (j *Jellyfin) makeJFItemPerson(ctx context.Context, userID string, personID
 			Key:    "Person-" + name,
 			ItemID: personID,
 		},
-		// Given an item trigger a request for this person, we assume this person was involved in at least one item.
-		ChildCount: 1,
+	}
+
+	// Filmography counts, so clients can show what this person appeared in.
+	response.MovieCount, response.SeriesCount, response.EpisodeCount = j.personItemCount(name)
+	response.ChildCount = response.MovieCount + response.SeriesCount + response.EpisodeCount
+
+	if thumb, ok := j.personThumb(name); ok {
+		response.ImageTags = &JFImageTags{
+			Primary: tagprefix_redirect + thumb,
+		}
 	}
 
 	person, err := j.repo.GetPersonByName(ctx, name, userID)
 	if err != nil {
+		// A person that is in none of our items and not in our database does not exist.
+		if response.ChildCount == 0 {
+			return JFItem{}, errors.New("could not find person")
+		}
 		// If we do not have details on this person, we just return a basic response with just the name.
-		// The person may still exist and just not be in our database.
 		return response, nil
 	}
 
@@ -150,7 +164,9 @@ func (j *Jellyfin) makeJFItemPerson(ctx context.Context, userID string, personID
 	response.Name = person.Name
 	response.Overview = person.Bio
 	response.DateCreated = person.Created
-	response.PremiereDate = person.DateOfBirth
+	if !person.DateOfBirth.IsZero() {
+		response.PremiereDate = person.DateOfBirth
+	}
 	if person.PlaceOfBirth != "" {
 		response.ProductionLocations = []string{
 			person.PlaceOfBirth,
@@ -170,6 +186,42 @@ func (j *Jellyfin) makeJFItemPerson(ctx context.Context, userID string, personID
 	return response, nil
 }
 
+// personItemCount returns the number of movies, shows and episodes a person was involved in.
+// Episodes are only counted if the person is not already credited on the show itself.
+func (j *Jellyfin) personItemCount(name string) (movies, shows, episodes int) {
+	for _, c := range j.collections.GetCollections() {
+		for _, i := range c.Items {
+			switch i := i.(type) {
+			case *collection.Movie:
+				if hasPerson(i, name) {
+					movies++
+				}
+			case *collection.Show:
+				if hasPerson(i, name) {
+					shows++
+					continue
+				}
+				for _, s := range i.Seasons {
+					for ei := range s.Episodes {
+						if hasPerson(&s.Episodes[ei], name) {
+							episodes++
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// hasPerson returns true if the person is an actor, director or writer of the item.
+func hasPerson(i collection.Item, name string) bool {
+	if _, ok := i.Actors()[name]; ok {
+		return true
+	}
+	return slices.Contains(i.Directors(), name) || slices.Contains(i.Writers(), name)
+}
+
 // makeSortName returns a name suitable for sorting.
 func makeSortName(name string) string {
 	// Start with lowercasing and trimming whitespace.
@@ -189,20 +241,23 @@ func (j *Jellyfin) makeJFPeople(_ context.Context, m metadata.Metadata, userID s
 	// }
 
 	actors := m.Actors()
-	directors := m.Directors()
-	writers := m.Writers()
-	people := make([]JFPeople, 0, len(actors)+len(directors)+len(writers))
+	crew := m.Crew()
+	people := make([]JFPeople, 0, len(actors)+len(crew))
 	for name, role := range actors {
 		id := makeJFPersonID(name)
 		people = append(people, JFPeople{ID: id, Name: name, Role: role, Type: "Actor", PrimaryImageTag: id})
 	}
-	for _, name := range directors {
-		id := makeJFPersonID(name)
-		people = append(people, JFPeople{ID: id, Name: name, Role: "Director", Type: "Director", PrimaryImageTag: id})
-	}
-	for _, name := range writers {
-		id := makeJFPersonID(name)
-		people = append(people, JFPeople{ID: id, Name: name, Role: "Screenplay", Type: "Writer", PrimaryImageTag: id})
+	for _, member := range crew {
+		id := makeJFPersonID(member.Name)
+		role := member.Role
+		// Default roles in case the NFO does not have one
+		if role == "" {
+			role = "Director"
+			if member.Type == "Writer" {
+				role = "Screenplay"
+			}
+		}
+		people = append(people, JFPeople{ID: id, Name: member.Name, Role: role, Type: member.Type, PrimaryImageTag: id})
 	}
 	return people
 }
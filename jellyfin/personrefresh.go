@@ -0,0 +1,215 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/collection/metadata"
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+	"github.com/erikbos/jellofin-server/tmdb"
+)
+
+const (
+	// personRefreshInterval is the wait time between runs of the person refresh task.
+	personRefreshInterval = 24 * time.Hour
+	// personRefreshAge is the age after which details of a person are looked up again.
+	personRefreshAge = 30 * 24 * time.Hour
+	// personRefreshDelay is the wait time between lookups, so the provider is not flooded.
+	personRefreshDelay = 250 * time.Millisecond
+	// personRefreshTaskID is the id of the person refresh task in the scheduled tasks API.
+	personRefreshTaskID = "4c7e2a9f1b3d4e6a8c0f2b4d6e8a1c3f"
+)
+
+// PersonProvider looks up biographies and profile images of persons.
+type PersonProvider interface {
+	SearchPerson(ctx context.Context, name string) (tmdb.Person, error)
+}
+
+// PersonRefreshBackground keeps the thumbs of actors found in metadata up to date, and looks up
+// the biography and profile image of persons without thumb if a person provider is configured.
+// Results are stored in the database, so person pages do not wait for the provider.
+func (j *Jellyfin) PersonRefreshBackground(ctx context.Context) {
+	for {
+		j.refreshPersons(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.personTask.trigger:
+		case <-time.After(personRefreshInterval):
+		}
+	}
+}
+
+// triggerPersonRefresh starts a person refresh run, returns false if a run is already in progress.
+func (j *Jellyfin) triggerPersonRefresh() bool {
+	j.personTask.mu.Lock()
+	running := j.personTask.running
+	j.personTask.mu.Unlock()
+	if running {
+		return false
+	}
+	select {
+	case j.personTask.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// refreshPersons collects the persons of all items and looks up the persons that have no thumb
+// and are not in the database yet, or were looked up longer than personRefreshAge ago.
+func (j *Jellyfin) refreshPersons(ctx context.Context) {
+	j.personTask.mu.Lock()
+	j.personTask.running = true
+	j.personTask.start = time.Now().UTC()
+	j.personTask.mu.Unlock()
+
+	names, thumbs := j.collectPersons()
+	j.personThumbsMu.Lock()
+	j.personThumbs = thumbs
+	j.personThumbsMu.Unlock()
+
+	status := "Completed"
+	var updated, failed int
+	for name := range names {
+		if j.personProvider == nil {
+			break
+		}
+		if _, ok := thumbs[name]; ok {
+			continue
+		}
+		if person, err := j.repo.GetPersonByName(ctx, name, ""); err == nil {
+			// Imported persons have no update time, they only need a lookup if they have no image
+			if (person.LastUpdated.IsZero() && person.PosterURL != "") || time.Since(person.LastUpdated) < personRefreshAge {
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(personRefreshDelay):
+		}
+		if ctx.Err() != nil {
+			status = "Cancelled"
+			break
+		}
+
+		if err := j.lookupPerson(ctx, name); err != nil {
+			log.Printf("Person refresh of %s failed: %s", name, err)
+			failed++
+			continue
+		}
+		updated++
+	}
+	if status == "Completed" && failed > 0 {
+		status = "CompletedWithErrors"
+	}
+	log.Printf("Person refresh %s, %d persons, %d updated, %d failed", status, len(names), updated, failed)
+
+	j.personTask.mu.Lock()
+	j.personTask.running = false
+	j.personTask.end = time.Now().UTC()
+	j.personTask.status = status
+	j.personTask.mu.Unlock()
+}
+
+// lookupPerson looks up a person with the person provider and stores the result in the database.
+func (j *Jellyfin) lookupPerson(ctx context.Context, name string) error {
+	found, err := j.personProvider.SearchPerson(ctx, name)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+		return err
+	}
+	// Persons that cannot be found are stored as well, so they are not looked up every run
+	return j.repo.UpsertPerson(ctx, model.Person{
+		ID:           idhash.IdHash(name),
+		Name:         name,
+		DateOfBirth:  found.Birthday,
+		PlaceOfBirth: found.PlaceOfBirth,
+		PosterURL:    tmdb.ImageURL(found.ProfilePath),
+		Bio:          found.Biography,
+	})
+}
+
+// collectPersons returns the names of all actors, directors and writers of movies and shows,
+// and the thumbs of actors that have a http(s) thumb in their metadata. Episodes are skipped,
+// their metadata is loaded on demand and guest stars would cause many lookups.
+func (j *Jellyfin) collectPersons() (map[string]bool, map[string]string) {
+	names := make(map[string]bool)
+	thumbs := make(map[string]string)
+	add := func(m metadata.Metadata) {
+		if m == nil {
+			return
+		}
+		for name := range m.Actors() {
+			names[name] = true
+		}
+		for _, name := range slices.Concat(m.Directors(), m.Writers()) {
+			names[name] = true
+		}
+		for name, thumb := range m.ActorThumbs() {
+			if strings.HasPrefix(thumb, "http://") || strings.HasPrefix(thumb, "https://") {
+				thumbs[name] = thumb
+			}
+		}
+	}
+	for _, c := range j.collections.GetCollections() {
+		for _, i := range c.Items {
+			switch v := i.(type) {
+			case *collection.Movie:
+				add(v.Metadata)
+			case *collection.Show:
+				add(v.Metadata)
+			}
+		}
+	}
+	return names, thumbs
+}
+
+// personThumb returns the thumb URL of an actor found in metadata, if any.
+func (j *Jellyfin) personThumb(name string) (string, bool) {
+	j.personThumbsMu.RLock()
+	defer j.personThumbsMu.RUnlock()
+	thumb, ok := j.personThumbs[name]
+	return thumb, ok
+}
+
+// personTaskResponse returns the person refresh task as scheduled task.
+func (j *Jellyfin) personTaskResponse() JFScheduledTasksResponse {
+	j.personTask.mu.Lock()
+	defer j.personTask.mu.Unlock()
+
+	const name = "Refresh people"
+	const key = "RefreshPeople"
+	response := JFScheduledTasksResponse{
+		Name:        name,
+		State:       "Idle",
+		ID:          personRefreshTaskID,
+		Description: "Looks up biographies and images of actors, directors and writers without thumb.",
+		Category:    "Library",
+		Key:         key,
+		Triggers: []ScheduledTaskTrigger{
+			{
+				Type:          "IntervalTrigger",
+				IntervalTicks: int64(personRefreshInterval / 100),
+			},
+		},
+	}
+	if j.personTask.running {
+		response.State = "Running"
+	}
+	if !j.personTask.end.IsZero() {
+		response.LastExecutionResult = ScheduledTaskLastExecutionResult{
+			StartTimeUtc: j.personTask.start,
+			EndTimeUtc:   j.personTask.end,
+			Status:       j.personTask.status,
+			Name:         name,
+			Key:          key,
+			ID:           personRefreshTaskID,
+		}
+	}
+	return response
+}
@@ -0,0 +1,273 @@
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// pinUnlockDuration is how long restricted collections stay unlocked on a device after entering the PIN
+	pinUnlockDuration = 4 * time.Hour
+	// pinMaxFailures is the number of wrong PINs after which PIN challenges of a user are refused for a while
+	pinMaxFailures = 5
+	// pinFailureTimeout is how long PIN challenges are refused after too many wrong PINs
+	pinFailureTimeout = 5 * time.Minute
+)
+
+// errPinRequired is returned for items of collections that are locked by the PIN of the user.
+var errPinRequired = errors.New("collection is restricted, PIN required")
+
+// pinFailures counts the wrong PINs entered for a user.
+type pinFailures struct {
+	count int
+	last  time.Time
+}
+
+// JFUserPinRequest is the request to set, change or remove the PIN of a user.
+type JFUserPinRequest struct {
+	// Pin is the new PIN, empty removes the PIN
+	Pin string `json:"Pin"`
+	// CurrentPin is the current PIN, required for users changing their own PIN
+	CurrentPin string `json:"CurrentPin"`
+	// RestrictedFolders are the collection ids that can only be opened after entering the PIN
+	RestrictedFolders []string `json:"RestrictedFolders"`
+}
+
+// JFPinChallengeRequest is a PIN entered by a user.
+type JFPinChallengeRequest struct {
+	// UserId is the user to check the PIN of, defaults to the logged in user
+	UserID string `json:"UserId"`
+	Pin    string `json:"Pin"`
+}
+
+// JFPinStatusResponse is the PIN state of the logged in user on the requesting device.
+type JFPinStatusResponse struct {
+	HasPin            bool      `json:"HasPin"`
+	Locked            bool      `json:"Locked"`
+	RestrictedFolders []string  `json:"RestrictedFolders"`
+	UnlockedUntil     time.Time `json:"UnlockedUntil,omitzero"`
+}
+
+// POST /Jellofin/Users/{userid}/Pin
+//
+// usersPinHandler sets the PIN of a user and the collections that need it. Users changing
+// their own PIN have to provide their current PIN, administrators do not.
+func (j *Jellyfin) usersPinHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	userID := mux.Vars(r)["userid"]
+	if !reqCtx.User.Properties.Admin && reqCtx.User.ID != userID {
+		apierror(w, "forbidden to update user PIN", http.StatusForbidden)
+		return
+	}
+	var req JFUserPinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror(w, ErrInvalidJSONPayload, http.StatusBadRequest)
+		return
+	}
+	dbuser, err := j.repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		apierror(w, ErrUserIDNotFound, http.StatusNotFound)
+		return
+	}
+	if !reqCtx.User.Properties.Admin && dbuser.Properties.Pin != "" {
+		if status, msg := j.checkPin(dbuser.ID, reqCtx.Token.DeviceId, dbuser.Properties.Pin, req.CurrentPin); status != http.StatusOK {
+			apierror(w, msg, status)
+			return
+		}
+	}
+	for _, folder := range req.RestrictedFolders {
+		if !isJFCollectionID(folder) || j.collections.GetCollection(trimPrefix(folder)) == nil {
+			apierror(w, "unknown collection "+folder, http.StatusBadRequest)
+			return
+		}
+	}
+
+	dbuser.Properties.Pin = ""
+	if req.Pin != "" {
+		if len(req.Pin) < 4 {
+			apierror(w, "PIN must have at least 4 characters", http.StatusBadRequest)
+			return
+		}
+		if dbuser.Properties.Pin, err = hashPassword(req.Pin); err != nil {
+			apierror(w, "failed to hash PIN", http.StatusInternalServerError)
+			return
+		}
+	}
+	dbuser.Properties.PinFolders = req.RestrictedFolders
+	if err = j.repo.UpsertUser(r.Context(), dbuser); err != nil {
+		apierror(w, "failed to update user PIN", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /Jellofin/Pin
+//
+// pinStatusHandler returns if the logged in user has a PIN and if its restricted collections
+// are locked on the requesting device.
+func (j *Jellyfin) pinStatusHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	response := JFPinStatusResponse{
+		HasPin:            reqCtx.User.Properties.Pin != "",
+		RestrictedFolders: reqCtx.User.Properties.PinFolders,
+	}
+	if response.RestrictedFolders == nil {
+		response.RestrictedFolders = []string{}
+	}
+	if response.HasPin {
+		j.pinMu.Lock()
+		if until, ok := j.pinUnlocks[reqCtx.Token.Token]; ok && time.Now().Before(until) {
+			response.UnlockedUntil = until.UTC()
+		}
+		j.pinMu.Unlock()
+		response.Locked = response.UnlockedUntil.IsZero()
+	}
+	serveJSON(response, w)
+}
+
+// POST /Jellofin/Pin/Challenge
+//
+// pinChallengeHandler checks a PIN. Clients use it before switching to a user with a PIN, or to
+// unlock the restricted collections of the logged in user on the requesting device.
+func (j *Jellyfin) pinChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	var req JFPinChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror(w, ErrInvalidJSONPayload, http.StatusBadRequest)
+		return
+	}
+	user := reqCtx.User
+	if req.UserID != "" && req.UserID != user.ID {
+		var err error
+		if user, err = j.repo.GetUserByID(r.Context(), req.UserID); err != nil {
+			apierror(w, ErrUserIDNotFound, http.StatusNotFound)
+			return
+		}
+	}
+	if user.Properties.Pin == "" {
+		apierror(w, "user has no PIN", http.StatusBadRequest)
+		return
+	}
+	if status, msg := j.checkPin(user.ID, reqCtx.Token.DeviceId, user.Properties.Pin, req.Pin); status != http.StatusOK {
+		apierror(w, msg, status)
+		return
+	}
+	if user.ID == reqCtx.User.ID {
+		j.pinMu.Lock()
+		j.pinUnlocks[reqCtx.Token.Token] = time.Now().Add(pinUnlockDuration)
+		j.pinMu.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /Jellofin/Pin/Lock
+//
+// pinLockHandler locks the restricted collections of the logged in user on the requesting device again.
+func (j *Jellyfin) pinLockHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	j.pinMu.Lock()
+	delete(j.pinUnlocks, reqCtx.Token.Token)
+	j.pinMu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPin checks a PIN against the hashed PIN of a user, refusing checks from a device for
+// a while after too many wrong PINs. Wrong PINs are counted per device, so other users cannot
+// lock a user out of their PIN. Returns http.StatusOK if the PIN is correct.
+func (j *Jellyfin) checkPin(userID, deviceID, hashedPin, pin string) (int, string) {
+	j.pinMu.Lock()
+	defer j.pinMu.Unlock()
+	j.expirePins()
+	key := userID + "/" + deviceID
+	failures := j.pinFailures[key]
+	if failures != nil && failures.count >= pinMaxFailures {
+		return http.StatusTooManyRequests, "too many wrong PINs, try again later"
+	}
+	if pin == "" || validatePassword(hashedPin, pin) != nil {
+		if failures == nil {
+			failures = &pinFailures{}
+		}
+		failures.count++
+		failures.last = time.Now()
+		j.pinFailures[key] = failures
+		return http.StatusForbidden, "wrong PIN"
+	}
+	delete(j.pinFailures, key)
+	return http.StatusOK, ""
+}
+
+// expirePins removes expired unlocks and wrong PIN counts, pinMu must be held.
+func (j *Jellyfin) expirePins() {
+	now := time.Now()
+	for token, until := range j.pinUnlocks {
+		if now.After(until) {
+			delete(j.pinUnlocks, token)
+		}
+	}
+	for key, failures := range j.pinFailures {
+		if now.Sub(failures.last) >= pinFailureTimeout {
+			delete(j.pinFailures, key)
+		}
+	}
+}
+
+// pinLocked returns true if a collection needs the PIN of the user of the request, and the
+// PIN has not been entered on the requesting device.
+func (j *Jellyfin) pinLocked(ctx context.Context, collectionID string) bool {
+	reqCtx, ok := ctx.Value(requestContextKey).(*requestContext)
+	if !ok || reqCtx.User.Properties.Pin == "" ||
+		!slices.Contains(reqCtx.User.Properties.PinFolders, makeJFCollectionID(collectionID)) {
+		return false
+	}
+	j.pinMu.Lock()
+	defer j.pinMu.Unlock()
+	until, ok := j.pinUnlocks[reqCtx.Token.Token]
+	if ok && time.Now().After(until) {
+		delete(j.pinUnlocks, reqCtx.Token.Token)
+		ok = false
+	}
+	return !ok
+}
+
+// applyPinLock removes collections and items of collections that are locked by the PIN of the user.
+func (j *Jellyfin) applyPinLock(ctx context.Context, items []JFItem) []JFItem {
+	reqCtx, ok := ctx.Value(requestContextKey).(*requestContext)
+	if !ok || reqCtx.User.Properties.Pin == "" || len(reqCtx.User.Properties.PinFolders) == 0 {
+		return items
+	}
+	resultItems := make([]JFItem, 0, len(items))
+	for _, item := range items {
+		collectionID := ""
+		if isJFCollectionID(item.ID) {
+			collectionID = trimPrefix(item.ID)
+		} else if c, i := j.collections.GetItemByID(trimPrefix(item.ID)); i != nil {
+			collectionID = c.ID
+		}
+		if collectionID != "" && j.pinLocked(ctx, collectionID) {
+			continue
+		}
+		resultItems = append(resultItems, item)
+	}
+	return resultItems
+}
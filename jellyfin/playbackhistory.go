@@ -0,0 +1,194 @@
+package jellyfin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// GET /Users/{user}/Items/{item}/PlaybackHistory?minDate=2025-01-01T00:00:00.000Z&maxDate=2025-02-01T00:00:00.000Z
+//
+// Supported query params:
+// - startIndex, number of entries to skip
+// - limit, maximum number of entries to return
+// - minDate, only return playback started at or after this date
+// - maxDate, only return playback started before this date
+//
+// usersItemPlaybackHistoryHandler returns the playback history of an item by a user, most recent first.
+func (j *Jellyfin) usersItemPlaybackHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	vars := mux.Vars(r)
+	userID := vars["userid"]
+	// Only allow if requester is an administrator or the user themselves
+	if !reqCtx.User.Properties.Admin && reqCtx.User.ID != userID {
+		apierror(w, "Forbidden to view playback history of other users", http.StatusForbidden)
+		return
+	}
+
+	query, err := parsePlaybackHistoryQuery(r.URL.Query())
+	if err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.UserID = userID
+	query.ItemID = trimPrefix(vars["itemid"])
+
+	entries, total, err := j.repo.GetPlaybackHistory(r.Context(), query)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := JFPlaybackHistoryResponse{
+		Items:            make([]JFPlaybackHistoryEntry, 0, len(entries)),
+		TotalRecordCount: total,
+		StartIndex:       query.StartIndex,
+	}
+	for _, e := range entries {
+		response.Items = append(response.Items, j.makeJFPlaybackHistoryEntry(e))
+	}
+	serveJSON(response, w)
+}
+
+// GET /PlaybackHistory?userId=XAOVn7iqiBujnIQY8sd0&minDate=2025-01-01T00:00:00.000Z
+//
+// Supported query params:
+// - userId, only include playback of this user
+// - minDate, only include playback started at or after this date
+// - maxDate, only include playback started before this date
+//
+// playbackHistoryHandler returns per user and item how often and how long items were played,
+// most recently played first. Only available to administrators.
+func (j *Jellyfin) playbackHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can view the playback history", http.StatusForbidden)
+		return
+	}
+
+	queryparams := r.URL.Query()
+	query, err := parsePlaybackHistoryQuery(queryparams)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Aggregate all matching playback, paginating applies to the aggregate
+	query.UserID = queryparams.Get("userId")
+	query.StartIndex, query.Limit = 0, 0
+
+	entries, _, err := j.repo.GetPlaybackHistory(r.Context(), query)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type summaryKey struct{ userID, itemID string }
+	summaries := make(map[summaryKey]*JFPlaybackHistorySummary)
+	userNames := make(map[string]string)
+	for _, e := range entries {
+		key := summaryKey{e.UserID, e.ItemID}
+		summary, ok := summaries[key]
+		if !ok {
+			if _, found := userNames[e.UserID]; !found {
+				if user, err := j.repo.GetUserByID(r.Context(), e.UserID); err == nil {
+					userNames[e.UserID] = user.Username
+				}
+			}
+			summary = &JFPlaybackHistorySummary{
+				UserID:   e.UserID,
+				UserName: userNames[e.UserID],
+				ItemID:   e.ItemID,
+				ItemName: j.playbackHistoryItemName(e.ItemID),
+			}
+			summaries[key] = summary
+		}
+		summary.PlayCount++
+		summary.PlayDurationSeconds += playbackDuration(e)
+		if e.Started.After(summary.LastPlayedDate) {
+			summary.LastPlayedDate = e.Started.UTC()
+		}
+	}
+
+	items := make([]JFPlaybackHistorySummary, 0, len(summaries))
+	for _, s := range summaries {
+		items = append(items, *s)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastPlayedDate.After(items[j].LastPlayedDate)
+	})
+	totalItemCount := len(items)
+	startIndex, _ := strconv.Atoi(queryparams.Get("startIndex"))
+	startIndex = min(max(startIndex, 0), len(items))
+	items = items[startIndex:]
+	if limit, err := strconv.Atoi(queryparams.Get("limit")); err == nil && limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	response := JFPlaybackHistorySummaryResponse{
+		Items:            items,
+		TotalRecordCount: totalItemCount,
+		StartIndex:       startIndex,
+	}
+	serveJSON(response, w)
+}
+
+// parsePlaybackHistoryQuery parses pagination and date range query params.
+func parsePlaybackHistoryQuery(queryparams url.Values) (model.PlaybackHistoryQuery, error) {
+	var query model.PlaybackHistoryQuery
+	query.StartIndex, _ = strconv.Atoi(queryparams.Get("startIndex"))
+	query.Limit, _ = strconv.Atoi(queryparams.Get("limit"))
+	if minDate := queryparams.Get("minDate"); minDate != "" {
+		t, err := parseISO8601date(minDate)
+		if err != nil {
+			return query, errors.New("invalid minDate")
+		}
+		query.MinDate = t
+	}
+	if maxDate := queryparams.Get("maxDate"); maxDate != "" {
+		t, err := parseISO8601date(maxDate)
+		if err != nil {
+			return query, errors.New("invalid maxDate")
+		}
+		query.MaxDate = t
+	}
+	return query, nil
+}
+
+// makeJFPlaybackHistoryEntry converts a playback history entry into its API representation.
+func (j *Jellyfin) makeJFPlaybackHistoryEntry(e model.PlaybackHistoryEntry) JFPlaybackHistoryEntry {
+	return JFPlaybackHistoryEntry{
+		ID:                  e.ID,
+		UserID:              e.UserID,
+		ItemID:              e.ItemID,
+		ItemName:            j.playbackHistoryItemName(e.ItemID),
+		DeviceName:          e.DeviceName,
+		Client:              e.Client,
+		StartDate:           e.Started.UTC(),
+		EndDate:             e.Stopped.UTC(),
+		PlayDurationSeconds: playbackDuration(e),
+		PositionTicks:       e.Position * TicsToSeconds,
+	}
+}
+
+// playbackHistoryItemName returns the name of an item, empty if the item no longer exists.
+func (j *Jellyfin) playbackHistoryItemName(itemID string) string {
+	if _, item := j.collections.GetItemByID(itemID); item != nil {
+		return item.Name()
+	}
+	return ""
+}
+
+// playbackDuration returns how long playback lasted in seconds.
+func playbackDuration(e model.PlaybackHistoryEntry) int64 {
+	return int64(max(e.Stopped.Sub(e.Started).Seconds(), 0))
+}
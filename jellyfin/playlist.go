@@ -7,7 +7,6 @@ import (
 	"log"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gorilla/mux"
 
@@ -23,6 +22,9 @@ func (j *Jellyfin) createPlaylistHandler(w http.ResponseWriter, r *http.Request)
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	var req JFCreatePlaylistRequest
 
@@ -69,6 +71,14 @@ func (j *Jellyfin) createPlaylistHandler(w http.ResponseWriter, r *http.Request)
 //
 // updatePlaylistHandler updates a playlist
 func (j *Jellyfin) updatePlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+
 	// vars := mux.Vars(r)
 	// playlistID := vars["playlistid"]
 
@@ -165,6 +175,9 @@ func (j *Jellyfin) addPlaylistItemsHandler(w http.ResponseWriter, r *http.Reques
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	vars := mux.Vars(r)
 	playlistID := vars["playlistid"]
@@ -187,6 +200,14 @@ func (j *Jellyfin) addPlaylistItemsHandler(w http.ResponseWriter, r *http.Reques
 //
 // movePlaylistItemHandler moves an item in a playlist
 func (j *Jellyfin) movePlaylistItemHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+
 	// vars := mux.Vars(r)
 	// playlistID := vars["playlistid"]
 	// itemID := vars["itemId"]
@@ -208,6 +229,14 @@ func (j *Jellyfin) movePlaylistItemHandler(w http.ResponseWriter, r *http.Reques
 //
 // deletePlaylistItemsHandler deletes items from a playlist
 func (j *Jellyfin) deletePlaylistItemsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+
 	// vars := mux.Vars(r)
 	// playlistID := vars["playlistid"]
 
@@ -278,8 +307,6 @@ func (j *Jellyfin) makeJFItemCollectionPlaylist(ctx context.Context, userID stri
 		ID:                       id,
 		ParentID:                 makeJFRootID(collectionRootID),
 		Etag:                     idhash.Hash(playlistCollectionID),
-		DateCreated:              time.Now().UTC(),
-		PremiereDate:             time.Now().UTC(),
 		CollectionType:           collectionTypePlaylists,
 		SortName:                 collectionTypePlaylists,
 		Type:                     itemTypeUserView,
@@ -299,7 +326,6 @@ func (j *Jellyfin) makeJFItemCollectionPlaylist(ctx context.Context, userID stri
 		CanDownload:              true,
 		SpecialFeatureCount:      0,
 		ImageTags:                j.makeJFImageTags(ctx, id, imageTypePrimary),
-		// PremiereDate should be set based upon most recent item in collection
 	}
 	return response, nil
 }
@@ -321,7 +347,6 @@ func (j *Jellyfin) makeJFItemPlaylist(ctx context.Context, userID, playlistID st
 		IsFolder:                 true,
 		Path:                     "/playlist",
 		Etag:                     idhash.Hash(playlist.ID),
-		DateCreated:              time.Now().UTC(),
 		CanDelete:                true,
 		CanDownload:              true,
 		PlayAccess:               "Full",
@@ -0,0 +1,327 @@
+package jellyfin
+
+import (
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+// Repeat modes and playback orders of a play queue.
+const (
+	repeatModeNone        = "RepeatNone"
+	repeatModeAll         = "RepeatAll"
+	repeatModeOne         = "RepeatOne"
+	playbackOrderDefault  = "Default"
+	playbackOrderShuffled = "Shuffle"
+)
+
+// playQueue is the server tracked play queue of a session.
+type playQueue struct {
+	// UserID is the user that created the queue
+	UserID string
+	// ItemIDs are the Jellyfin IDs of the items of the queue in play order
+	ItemIDs []string
+	// originalIDs are the items in the order before shuffling
+	originalIDs []string
+	// Index is the index of the current item in ItemIDs
+	Index int
+	// Shuffle indicates if the queue is shuffled
+	Shuffle bool
+	// RepeatMode is RepeatNone, RepeatAll or RepeatOne
+	RepeatMode string
+}
+
+// GET /Sessions/{session}/PlayQueue
+//
+// sessionsPlayQueueGetHandler returns the play queue of a session.
+func (j *Jellyfin) sessionsPlayQueueGetHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	queue, ok := j.sessionPlayQueue(w, reqCtx, mux.Vars(r)["sessionid"])
+	if !ok {
+		return
+	}
+	j.servePlayQueue(w, r, reqCtx, queue)
+}
+
+// POST /Sessions/{session}/PlayQueue
+//
+// Supported query params:
+// - itemIds, comma separated list of items to queue, shows, seasons, playlists and boxsets are expanded
+// - startIndex, index of the item in the queue to start with
+// - shuffle, true to shuffle the queue
+// - repeatMode, RepeatNone, RepeatAll or RepeatOne
+//
+// sessionsPlayQueueCreateHandler creates the play queue of a session, an existing queue is replaced.
+func (j *Jellyfin) sessionsPlayQueueCreateHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	sessionID := mux.Vars(r)["sessionid"]
+	if !j.ownSession(r, reqCtx, sessionID) {
+		apierror(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	queryparams := r.URL.Query()
+	itemIDs := splitList(queryparams.Get("itemIds"))
+	if len(itemIDs) == 0 {
+		apierror(w, "itemIds is required", http.StatusBadRequest)
+		return
+	}
+	repeatMode, ok := parseRepeatMode(queryparams.Get("repeatMode"))
+	if !ok {
+		apierror(w, "Unknown repeatMode", http.StatusBadRequest)
+		return
+	}
+
+	queue := &playQueue{
+		UserID:     reqCtx.User.ID,
+		RepeatMode: repeatMode,
+	}
+	for _, itemID := range itemIDs {
+		for _, i := range j.playbackQueue(r.Context(), reqCtx.User.ID, itemID) {
+			if _, ok := i.(*collection.Episode); ok {
+				queue.ItemIDs = append(queue.ItemIDs, makeJFEpisodeID(i.ID()))
+			} else {
+				queue.ItemIDs = append(queue.ItemIDs, i.ID())
+			}
+		}
+	}
+	if len(queue.ItemIDs) == 0 {
+		apierror(w, "Could not find item", http.StatusNotFound)
+		return
+	}
+	queue.originalIDs = slices.Clone(queue.ItemIDs)
+	if startIndex, err := strconv.Atoi(queryparams.Get("startIndex")); err == nil && startIndex >= 0 && startIndex < len(queue.ItemIDs) {
+		queue.Index = startIndex
+	}
+	if strings.EqualFold(queryparams.Get("shuffle"), "true") {
+		queue.setShuffle(true)
+	}
+
+	j.playQueuesMu.Lock()
+	j.playQueues[sessionID] = queue
+	j.playQueuesMu.Unlock()
+	j.servePlayQueue(w, r, reqCtx, queue)
+}
+
+// DELETE /Sessions/{session}/PlayQueue
+//
+// sessionsPlayQueueDeleteHandler removes the play queue of a session.
+func (j *Jellyfin) sessionsPlayQueueDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	sessionID := mux.Vars(r)["sessionid"]
+	if _, ok := j.sessionPlayQueue(w, reqCtx, sessionID); !ok {
+		return
+	}
+	j.playQueuesMu.Lock()
+	delete(j.playQueues, sessionID)
+	j.playQueuesMu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /Sessions/{session}/PlayQueue/Next
+//
+// sessionsPlayQueueNextHandler moves the play queue of a session to the next item.
+func (j *Jellyfin) sessionsPlayQueueNextHandler(w http.ResponseWriter, r *http.Request) {
+	j.movePlayQueue(w, r, (*playQueue).next)
+}
+
+// POST /Sessions/{session}/PlayQueue/Previous
+//
+// sessionsPlayQueuePreviousHandler moves the play queue of a session to the previous item.
+func (j *Jellyfin) sessionsPlayQueuePreviousHandler(w http.ResponseWriter, r *http.Request) {
+	j.movePlayQueue(w, r, (*playQueue).previous)
+}
+
+// POST /Sessions/{session}/PlayQueue/Mode
+//
+// Supported query params:
+// - shuffle, true or false to shuffle or unshuffle the queue
+// - repeatMode, RepeatNone, RepeatAll or RepeatOne
+//
+// sessionsPlayQueueModeHandler changes the shuffle and repeat mode of the play queue of a session.
+func (j *Jellyfin) sessionsPlayQueueModeHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	queue, ok := j.sessionPlayQueue(w, reqCtx, mux.Vars(r)["sessionid"])
+	if !ok {
+		return
+	}
+	queryparams := r.URL.Query()
+	j.playQueuesMu.Lock()
+	if repeat := queryparams.Get("repeatMode"); repeat != "" {
+		repeatMode, ok := parseRepeatMode(repeat)
+		if !ok {
+			j.playQueuesMu.Unlock()
+			apierror(w, "Unknown repeatMode", http.StatusBadRequest)
+			return
+		}
+		queue.RepeatMode = repeatMode
+	}
+	if shuffle := queryparams.Get("shuffle"); shuffle != "" {
+		queue.setShuffle(strings.EqualFold(shuffle, "true"))
+	}
+	j.playQueuesMu.Unlock()
+	j.servePlayQueue(w, r, reqCtx, queue)
+}
+
+// movePlayQueue moves the play queue of the session in the request to another item.
+func (j *Jellyfin) movePlayQueue(w http.ResponseWriter, r *http.Request, move func(*playQueue) bool) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	queue, ok := j.sessionPlayQueue(w, reqCtx, mux.Vars(r)["sessionid"])
+	if !ok {
+		return
+	}
+	j.playQueuesMu.Lock()
+	moved := move(queue)
+	j.playQueuesMu.Unlock()
+	if !moved {
+		apierror(w, "No more items in play queue", http.StatusConflict)
+		return
+	}
+	j.servePlayQueue(w, r, reqCtx, queue)
+}
+
+// sessionPlayQueue returns the play queue of a session. Users can only access their own
+// queues, administrators can access all queues.
+func (j *Jellyfin) sessionPlayQueue(w http.ResponseWriter, reqCtx *requestContext, sessionID string) (*playQueue, bool) {
+	j.playQueuesMu.Lock()
+	queue, ok := j.playQueues[sessionID]
+	j.playQueuesMu.Unlock()
+	if !ok || (queue.UserID != reqCtx.User.ID && !reqCtx.User.Properties.Admin) {
+		apierror(w, "Play queue not found", http.StatusNotFound)
+		return nil, false
+	}
+	return queue, true
+}
+
+// ownSession returns true if a session ID belongs to one of the devices of the user.
+func (j *Jellyfin) ownSession(r *http.Request, reqCtx *requestContext, sessionID string) bool {
+	if idhash.IdHash(reqCtx.Token.DeviceId) == sessionID {
+		return true
+	}
+	accessTokens, err := j.repo.GetAccessTokens(r.Context(), reqCtx.User.ID)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(accessTokens, func(t model.AccessToken) bool {
+		return idhash.IdHash(t.DeviceId) == sessionID
+	})
+}
+
+// getPlayQueue returns a copy of the play queue of a session, if any.
+func (j *Jellyfin) getPlayQueue(sessionID string) (playQueue, bool) {
+	j.playQueuesMu.Lock()
+	defer j.playQueuesMu.Unlock()
+	if queue, ok := j.playQueues[sessionID]; ok {
+		q := *queue
+		q.ItemIDs = slices.Clone(queue.ItemIDs)
+		return q, true
+	}
+	return playQueue{}, false
+}
+
+// servePlayQueue returns a play queue with the current item.
+func (j *Jellyfin) servePlayQueue(w http.ResponseWriter, r *http.Request, reqCtx *requestContext, queue *playQueue) {
+	j.playQueuesMu.Lock()
+	response := JFPlayQueue{
+		ItemIDs:       slices.Clone(queue.ItemIDs),
+		CurrentIndex:  queue.Index,
+		RepeatMode:    queue.RepeatMode,
+		PlaybackOrder: queue.playbackOrder(),
+	}
+	j.playQueuesMu.Unlock()
+	if c, i := j.collections.GetItemByID(trimPrefix(response.ItemIDs[response.CurrentIndex])); c != nil && i != nil {
+		if item, err := j.makeJFItem(r.Context(), reqCtx.User.ID, i, c.ID); err == nil {
+			response.NowPlayingItem = &item
+		}
+	}
+	serveJSON(response, w)
+}
+
+// next moves to the next item, returns false if the end of the queue has been reached.
+func (q *playQueue) next() bool {
+	switch {
+	case q.RepeatMode == repeatModeOne:
+	case q.Index+1 < len(q.ItemIDs):
+		q.Index++
+	case q.RepeatMode == repeatModeAll:
+		q.Index = 0
+	default:
+		return false
+	}
+	return true
+}
+
+// previous moves to the previous item, returns false if the start of the queue has been reached.
+func (q *playQueue) previous() bool {
+	switch {
+	case q.RepeatMode == repeatModeOne:
+	case q.Index > 0:
+		q.Index--
+	case q.RepeatMode == repeatModeAll:
+		q.Index = len(q.ItemIDs) - 1
+	default:
+		return false
+	}
+	return true
+}
+
+// setShuffle shuffles the queue with the current item first, or restores the original
+// order while keeping the current item.
+func (q *playQueue) setShuffle(shuffle bool) {
+	if shuffle == q.Shuffle {
+		return
+	}
+	current := q.ItemIDs[q.Index]
+	q.Shuffle = shuffle
+	if !shuffle {
+		q.ItemIDs = slices.Clone(q.originalIDs)
+		q.Index = max(0, slices.Index(q.ItemIDs, current))
+		return
+	}
+	rest := slices.Delete(slices.Clone(q.ItemIDs), q.Index, q.Index+1)
+	rand.Shuffle(len(rest), func(i, j int) {
+		rest[i], rest[j] = rest[j], rest[i]
+	})
+	q.ItemIDs = append([]string{current}, rest...)
+	q.Index = 0
+}
+
+// playbackOrder returns the Jellyfin playback order of the queue.
+func (q *playQueue) playbackOrder() string {
+	if q.Shuffle {
+		return playbackOrderShuffled
+	}
+	return playbackOrderDefault
+}
+
+// parseRepeatMode returns the repeat mode, empty defaults to RepeatNone.
+func parseRepeatMode(repeatMode string) (string, bool) {
+	for _, m := range []string{repeatModeNone, repeatModeAll, repeatModeOne} {
+		if strings.EqualFold(repeatMode, m) {
+			return m, true
+		}
+	}
+	return repeatModeNone, repeatMode == ""
+}
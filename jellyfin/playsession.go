@@ -0,0 +1,261 @@
+package jellyfin
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+// playSessionTimeout expires playback sessions of clients that stopped reporting progress.
+// Clients report progress every 10 seconds, also when paused.
+const playSessionTimeout = 5 * time.Minute
+
+// playMethodDirectPlay is the play method of sessions, the server does not transcode or remux.
+const playMethodDirectPlay = "DirectPlay"
+
+// playSession is a playback of an item by a client, identified by PlaySessionId.
+type playSession struct {
+	// ID is the PlaySessionId handed out in PlaybackInfo responses
+	ID string
+	// Token is the access token of the client, it has device and client details
+	Token    model.AccessToken
+	UserName string
+	ItemID   string
+	// MediaSourceID is the media source being played
+	MediaSourceID string
+	PlayMethod    string
+	PositionTicks int64
+	CanSeek       bool
+	IsPaused      bool
+	IsMuted       bool
+	// Guest indicates the user is a guest, playback of guests is not recorded
+	Guest bool
+	// Started indicates playback was reported, not just requested via PlaybackInfo
+	Started     bool
+	StartedAt   time.Time
+	LastCheckIn time.Time
+}
+
+// newPlaySession registers a playback session for an item starting at a position
+// and returns its PlaySessionId. Sessions the device requested before, but did not
+// start playing, are replaced.
+func (j *Jellyfin) newPlaySession(reqCtx *requestContext, itemID string, startTimeTicks int64) string {
+	s := &playSession{
+		ID:            randomID(),
+		Token:         *reqCtx.Token,
+		UserName:      reqCtx.User.Username,
+		ItemID:        itemID,
+		Guest:         reqCtx.User.Properties.Guest,
+		PlayMethod:    playMethodDirectPlay,
+		PositionTicks: startTimeTicks,
+		LastCheckIn:   time.Now().UTC(),
+	}
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	j.expirePlaySessions()
+	for id, previous := range j.playSessions {
+		if !previous.Started && previous.Token.UserID == s.Token.UserID && previous.Token.DeviceId == s.Token.DeviceId {
+			delete(j.playSessions, id)
+		}
+	}
+	j.playSessions[s.ID] = s
+	return s.ID
+}
+
+// registerStream keeps the playback session of a stream or download request alive, so it
+// counts as active also when the client does not report progress. Requests without a known
+// PlaySessionId get a session of the device registered.
+func (j *Jellyfin) registerStream(reqCtx *requestContext, itemID, playSessionID string) {
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	j.expirePlaySessions()
+
+	s, ok := j.playSessions[playSessionID]
+	if !ok || s.Token.UserID != reqCtx.User.ID {
+		// Without PlaySessionId a device can play one item at a time
+		s, ok = j.playSessions[reqCtx.Token.DeviceId]
+	}
+	if !ok || s.Token.UserID != reqCtx.User.ID {
+		s = &playSession{
+			ID:         reqCtx.Token.DeviceId,
+			Token:      *reqCtx.Token,
+			UserName:   reqCtx.User.Username,
+			ItemID:     itemID,
+			Guest:      reqCtx.User.Properties.Guest,
+			PlayMethod: playMethodDirectPlay,
+		}
+		j.playSessions[s.ID] = s
+	}
+	s.LastCheckIn = time.Now().UTC()
+}
+
+// updatePlaySession updates a playback session with a play state reported by a client.
+// Sessions are removed when playback has stopped. Clients that did not request
+// PlaybackInfo, or send an unknown PlaySessionId, get a session registered as well.
+func (j *Jellyfin) updatePlaySession(reqCtx *requestContext, state JFPlayState, stopped bool) {
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	j.expirePlaySessions()
+
+	id := state.PlaySessionID
+	if id == "" {
+		// Without PlaySessionId a device can play one item at a time
+		id = reqCtx.Token.DeviceId
+	}
+	s, ok := j.playSessions[id]
+	if stopped {
+		delete(j.playSessions, id)
+		if !ok || !s.Started || s.Token.UserID != reqCtx.User.ID {
+			// Playback was not reported as started, we do not know when it started
+			s = &playSession{
+				Token:     *reqCtx.Token,
+				StartedAt: time.Now().UTC(),
+			}
+		}
+		s.Guest = reqCtx.User.Properties.Guest
+		if state.ItemId != "" {
+			s.ItemID = state.ItemId
+		}
+		s.PositionTicks = state.PositionTicks
+		go j.recordPlayback(*s, time.Now().UTC())
+		return
+	}
+	if !ok || s.Token.UserID != reqCtx.User.ID {
+		s = &playSession{
+			ID:       id,
+			UserName: reqCtx.User.Username,
+		}
+		j.playSessions[id] = s
+	}
+	s.Token = *reqCtx.Token
+	s.Guest = reqCtx.User.Properties.Guest
+	if state.ItemId != "" {
+		s.ItemID = state.ItemId
+	}
+	if state.MediaSourceID != "" {
+		s.MediaSourceID = state.MediaSourceID
+	}
+	if state.PlayMethod != "" {
+		s.PlayMethod = state.PlayMethod
+	}
+	s.PositionTicks = state.PositionTicks
+	s.CanSeek = state.CanSeek
+	s.IsPaused = state.IsPaused
+	s.IsMuted = state.IsMuted
+	if !s.Started {
+		s.Started = true
+		s.StartedAt = time.Now().UTC()
+	}
+	s.LastCheckIn = time.Now().UTC()
+}
+
+// activePlaySessions returns the started playback sessions, of a user or of all users
+// if userID is empty.
+func (j *Jellyfin) activePlaySessions(userID string) []playSession {
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	j.expirePlaySessions()
+
+	var sessions []playSession
+	for _, s := range j.playSessions {
+		if s.Started && (userID == "" || s.Token.UserID == userID) {
+			sessions = append(sessions, *s)
+		}
+	}
+	return sessions
+}
+
+// expirePlaySessions removes sessions without recent progress reports, playSessionsMu must be held.
+// Playback of expired sessions is added to the playback history.
+func (j *Jellyfin) expirePlaySessions() {
+	cutoff := time.Now().UTC().Add(-playSessionTimeout)
+	for id, s := range j.playSessions {
+		if s.LastCheckIn.Before(cutoff) {
+			delete(j.playSessions, id)
+			if s.Started {
+				go j.recordPlayback(*s, s.LastCheckIn)
+			}
+		}
+	}
+}
+
+// recordPlayback adds the playback of a session to the playback history.
+func (j *Jellyfin) recordPlayback(s playSession, stopped time.Time) {
+	if s.ItemID == "" || s.Guest {
+		return
+	}
+	entry := model.PlaybackHistoryEntry{
+		UserID:     s.Token.UserID,
+		ItemID:     trimPrefix(s.ItemID),
+		DeviceName: s.Token.DeviceName,
+		Client:     s.Token.ApplicationName,
+		Started:    s.StartedAt,
+		Stopped:    stopped,
+		Position:   s.PositionTicks / TicsToSeconds,
+	}
+	if err := j.repo.AddPlaybackHistoryEntry(context.Background(), entry); err != nil {
+		log.Printf("Failed to add playback history of item %s: %s", entry.ItemID, err)
+	}
+}
+
+// streamLimitReached returns true if a user has the maximum number of playback sessions
+// allowed. Sessions count from the PlaybackInfo, stream or download request on, also if the
+// client does not report progress. Sessions of the device that wants to start playback are
+// not counted, its new playback replaces them.
+func (j *Jellyfin) streamLimitReached(user *model.User, deviceID string) bool {
+	if user == nil || user.Properties.MaxActiveSessions <= 0 {
+		return false
+	}
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	j.expirePlaySessions()
+
+	var sessions int
+	for _, s := range j.playSessions {
+		if s.Token.UserID == user.ID && s.Token.DeviceId != deviceID {
+			sessions++
+		}
+	}
+	return sessions >= user.Properties.MaxActiveSessions
+}
+
+// applyBitrateLimit marks media sources above the remote bitrate limit of a user as not
+// playable, for clients outside the local network. The server does not transcode, so these
+// sources cannot be played at a lower bitrate. Returns false if no media source is playable.
+func applyBitrateLimit(r *http.Request, user *model.User, mediaSources []JFMediaSources) bool {
+	limit := user.Properties.RemoteClientBitrateLimit
+	if limit <= 0 || localNetworkAddress(remoteIP(r)) {
+		return true
+	}
+	playable := false
+	for i := range mediaSources {
+		if mediaSources[i].Bitrate > limit {
+			mediaSources[i].SupportsDirectPlay = false
+			mediaSources[i].SupportsDirectStream = false
+			continue
+		}
+		playable = true
+	}
+	return playable
+}
+
+// localNetworkAddress returns true if an IP address is on the local network.
+func localNetworkAddress(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// setPlaySessionStart records the position a stream request of a playback session starts at,
+// in case playback has not been reported yet. Videos are played directly, seeking to the
+// position is up to the client.
+func (j *Jellyfin) setPlaySessionStart(playSessionID string, startTimeTicks int64) {
+	j.playSessionsMu.Lock()
+	defer j.playSessionsMu.Unlock()
+	if s, ok := j.playSessions[playSessionID]; ok && !s.Started && startTimeTicks >= 0 {
+		s.PositionTicks = startTimeTicks
+	}
+}
@@ -0,0 +1,52 @@
+package jellyfin
+
+import (
+	"log"
+	"net/http"
+)
+
+const (
+	// maxUserItemQueries is the number of expensive item queries, such as recursive listings
+	// and searches, a user can run at the same time. Further queries wait for their turn.
+	maxUserItemQueries = 2
+	// maxSearchTermLength is the maximum length of a search term, longer terms are rejected.
+	maxSearchTermLength = 256
+)
+
+// guardItemQuery waits until the user can run an expensive item query, so a single misbehaving
+// client cannot keep all CPUs busy. The returned function has to be called once the query is done.
+// Returns false after responding with 503 Service Unavailable if the request ended while waiting.
+func (j *Jellyfin) guardItemQuery(w http.ResponseWriter, r *http.Request, reqCtx *requestContext) (func(), bool) {
+	j.itemQueriesMu.Lock()
+	slots, ok := j.itemQueries[reqCtx.User.ID]
+	if !ok {
+		slots = make(chan struct{}, maxUserItemQueries)
+		j.itemQueries[reqCtx.User.ID] = slots
+	}
+	j.itemQueriesMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+	}
+	log.Printf("User %s has %d item queries running, waiting for %s", reqCtx.User.Username, maxUserItemQueries, r.URL.Path)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-r.Context().Done():
+		log.Printf("User %s has %d item queries running, rejected %s", reqCtx.User.Username, maxUserItemQueries, r.URL.Path)
+		w.Header().Set("Retry-After", "5")
+		apierror(w, "Too many queries running, try again later", http.StatusServiceUnavailable)
+		return nil, false
+	}
+}
+
+// validSearchTerm returns false after responding with 400 Bad Request if the search term is too long.
+func validSearchTerm(w http.ResponseWriter, searchTerm string) bool {
+	if len(searchTerm) > maxSearchTermLength {
+		apierror(w, "searchTerm is too long", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/tmdb"
+)
+
+// RemoteImageProvider lists and downloads posters, backdrops and logos of movies and shows.
+type RemoteImageProvider interface {
+	Images(ctx context.Context, mediaType string, providerIDs map[string]string) ([]tmdb.Image, error)
+	DownloadImage(ctx context.Context, imageURL string) ([]byte, error)
+}
+
+// remoteImageProviderName is the provider name reported to clients.
+const remoteImageProviderName = "TheMovieDb"
+
+// remoteImageTypes maps image types of the provider to Jellyfin image types.
+var remoteImageTypes = map[string]string{
+	"poster":   "Primary",
+	"backdrop": "Backdrop",
+	"logo":     "Logo",
+}
+
+// GET /Items/{item}/RemoteImages
+//
+// Supported query params:
+// - type, Primary, Backdrop or Logo, empty returns all types
+// - startIndex, index of the first image to return
+// - limit, maximum number of images to return
+// - providerName, only return images of this provider
+// - includeAllLanguages, true to include images with text in other languages than English
+//
+// itemsRemoteImagesHandler returns the images of a movie or show available at the remote image provider
+func (j *Jellyfin) itemsRemoteImagesHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	_, i := j.collections.GetItemByID(trimPrefix(mux.Vars(r)["itemid"]))
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	response := JFResponseItemRemoteImages{
+		Images:    []JFResponseItemRemoteImagesImage{},
+		Providers: []string{},
+	}
+	queryparams := r.URL.Query()
+	mediaType, providerIDs := remoteImageMediaType(i)
+	providerName := queryparams.Get("providerName")
+	if j.remoteImageProvider == nil || mediaType == "" || (providerName != "" && providerName != remoteImageProviderName) {
+		serveJSON(response, w)
+		return
+	}
+	response.Providers = []string{remoteImageProviderName}
+
+	images, err := j.remoteImageProvider.Images(r.Context(), mediaType, providerIDs)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) && !errors.Is(err, tmdb.ErrNoProviderID) {
+		apierror(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	imageType := queryparams.Get("type")
+	allLanguages := strings.EqualFold(queryparams.Get("includeAllLanguages"), "true")
+	for _, image := range images {
+		jfType := remoteImageTypes[image.Type]
+		if imageType != "" && !strings.EqualFold(imageType, jfType) {
+			continue
+		}
+		if !allLanguages && image.Language != "" && image.Language != "en" {
+			continue
+		}
+		response.Images = append(response.Images, JFResponseItemRemoteImagesImage{
+			ProviderName:    remoteImageProviderName,
+			URL:             tmdb.ImageURL(image.FilePath),
+			ThumbnailURL:    tmdb.ThumbnailURL(image.FilePath),
+			Type:            jfType,
+			Width:           image.Width,
+			Height:          image.Height,
+			Language:        image.Language,
+			CommunityRating: image.VoteAverage,
+			VoteCount:       image.VoteCount,
+			RatingType:      "Score",
+		})
+	}
+	response.TotalRecordCount = len(response.Images)
+
+	if startIndex, err := strconv.Atoi(queryparams.Get("startIndex")); err == nil && startIndex > 0 {
+		response.Images = response.Images[min(startIndex, len(response.Images)):]
+	}
+	if limit, err := strconv.Atoi(queryparams.Get("limit")); err == nil && limit >= 0 && limit < len(response.Images) {
+		response.Images = response.Images[:limit]
+	}
+	serveJSON(response, w)
+}
+
+// GET /Items/{item}/RemoteImages/Providers
+//
+// itemsRemoteImagesProvidersHandler returns a list of remote image providers for an item
+func (j *Jellyfin) itemsRemoteImagesProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	response := JFResponseItemRemoteImagesProviders{
+		{
+			Name:            "Local Repository",
+			SupportedImages: []string{"Primary"},
+		},
+	}
+	_, i := j.collections.GetItemByID(trimPrefix(mux.Vars(r)["itemid"]))
+	if mediaType, _ := remoteImageMediaType(i); j.remoteImageProvider != nil && mediaType != "" {
+		response = append(response, JFResponseItemRemoteImagesProviders{
+			{
+				Name:            remoteImageProviderName,
+				SupportedImages: []string{"Primary", "Backdrop", "Logo"},
+			},
+		}...)
+	}
+	serveJSON(response, w)
+}
+
+// POST /Items/{item}/RemoteImages/Download
+//
+// Supported query params:
+// - type, Primary, Backdrop or Logo
+// - imageUrl, url of the image as returned by /Items/{item}/RemoteImages
+//
+// itemsRemoteImagesDownloadHandler downloads a remote image and stores it as artwork of the item
+func (j *Jellyfin) itemsRemoteImagesDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can download images", http.StatusForbidden)
+		return
+	}
+	if j.remoteImageProvider == nil {
+		apierror(w, "No remote image provider configured", http.StatusNotFound)
+		return
+	}
+	c, i := j.collections.GetItemByID(trimPrefix(mux.Vars(r)["itemid"]))
+	if i == nil {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	queryparams := r.URL.Query()
+	artworkType, ok := artworkType(queryparams.Get("type"))
+	if !ok {
+		apierror(w, "Unsupported image type", http.StatusBadRequest)
+		return
+	}
+	imageURL := queryparams.Get("imageUrl")
+	if imageURL == "" {
+		apierror(w, "imageUrl is required", http.StatusBadRequest)
+		return
+	}
+	imageData, err := j.remoteImageProvider.DownloadImage(r.Context(), imageURL)
+	if err != nil {
+		log.Printf("Downloading image %s failed: %s", imageURL, err)
+		apierror(w, "Failed to download image", http.StatusBadGateway)
+		return
+	}
+	j.storeArtwork(w, c, i, artworkType, imageData)
+}
+
+// remoteImageMediaType returns the media type and provider IDs used to look up remote images
+// of an item. Media type is empty for items without remote images, such as episodes.
+func remoteImageMediaType(i collection.Item) (string, map[string]string) {
+	switch v := i.(type) {
+	case *collection.Movie:
+		if v.Metadata != nil {
+			return tmdb.MediaTypeMovie, v.Metadata.ProviderIDs()
+		}
+	case *collection.Show:
+		if v.Metadata != nil {
+			return tmdb.MediaTypeTV, v.Metadata.ProviderIDs()
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,96 @@
+package jellyfin
+
+import (
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+const (
+	// defaultMinResumePercentage is the played percentage below which no resume position is kept.
+	defaultMinResumePercentage = 5
+	// defaultMaxResumePercentage is the played percentage above which an item is marked as played.
+	defaultMaxResumePercentage = 90
+	// defaultMinResumeDurationSeconds is the duration below which items are not resumable.
+	defaultMinResumeDurationSeconds = 300
+)
+
+// Resume holds the thresholds that determine whether playback progress results in a
+// resume position or marks an item as played.
+type Resume struct {
+	// MinResumePercentage is the played percentage below which no resume position is kept, defaults to 5
+	MinResumePercentage int
+	// MaxResumePercentage is the played percentage above which an item is marked as played, defaults to 90
+	MaxResumePercentage int
+	// MinResumeDurationSeconds is the duration below which no resume position is kept, defaults to 300
+	MinResumeDurationSeconds int
+}
+
+// withDefaults returns the thresholds with unset values replaced by defaults.
+func (r Resume) withDefaults(defaults Resume) Resume {
+	if r.MinResumePercentage <= 0 {
+		r.MinResumePercentage = defaults.MinResumePercentage
+	}
+	if r.MaxResumePercentage <= 0 || r.MaxResumePercentage > 100 {
+		r.MaxResumePercentage = defaults.MaxResumePercentage
+	}
+	if r.MinResumeDurationSeconds <= 0 {
+		r.MinResumeDurationSeconds = defaults.MinResumeDurationSeconds
+	}
+	return r
+}
+
+// resumeThresholds returns the resume thresholds of a user, settings of the user
+// take precedence over the server settings.
+func (j *Jellyfin) resumeThresholds(user *model.User) Resume {
+	j.resumeMu.RLock()
+	thresholds := j.resume
+	j.resumeMu.RUnlock()
+	if user == nil {
+		return thresholds
+	}
+	return Resume{
+		MinResumePercentage:      user.Properties.MinResumePercentage,
+		MaxResumePercentage:      user.Properties.MaxResumePercentage,
+		MinResumeDurationSeconds: user.Properties.MinResumeDurationSeconds,
+	}.withDefaults(thresholds)
+}
+
+// resumable returns true if a play state has a resume position within the thresholds.
+// duration is in seconds.
+func (r Resume) resumable(playstate *model.UserData, duration int64) bool {
+	if playstate == nil || playstate.Played || playstate.Position == 0 {
+		return false
+	}
+	if duration > 0 && duration < int64(r.MinResumeDurationSeconds) {
+		return false
+	}
+	return playstate.PlayedPercentage >= r.MinResumePercentage &&
+		playstate.PlayedPercentage < r.MaxResumePercentage
+}
+
+// setPlaybackPosition updates position and played state of playstate. duration is in seconds.
+func (r Resume) setPlaybackPosition(playstate *model.UserData, duration, positionTicks int64, markAsWatched bool) {
+	// If we don't have a duration, we assume 1 hour
+	if duration == 0 {
+		duration = 60 * 60
+	}
+
+	position := positionTicks / TicsToSeconds
+	playedPercentage := int(100 * position / duration)
+
+	switch {
+	// Mark as watched in case most of the item is played
+	case markAsWatched || playedPercentage >= r.MaxResumePercentage:
+		playstate.Position = 0
+		playstate.PlayedPercentage = 0
+		playstate.Played = true
+	// Barely started or too short to resume, do not keep a resume position
+	case playedPercentage < r.MinResumePercentage || duration < int64(r.MinResumeDurationSeconds):
+		playstate.Position = 0
+		playstate.PlayedPercentage = 0
+		playstate.Played = false
+	default:
+		playstate.Position = position
+		playstate.PlayedPercentage = playedPercentage
+		playstate.Played = false
+	}
+}
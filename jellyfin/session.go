@@ -1,15 +1,16 @@
 package jellyfin
 
 import (
+	"encoding/json"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
-	"github.com/erikbos/jellofin-server/database/model"
-)
+	"github.com/gorilla/mux"
 
-const (
-	// sessionID is a unique ID for authenticated session, it's the same
-	// as we do not really track sessions per user
-	sessionID = "e3a869b7a901f8894de8ee65688db6c0"
+	"github.com/erikbos/jellofin-server/database/model"
+	"github.com/erikbos/jellofin-server/idhash"
 )
 
 // /Sessions
@@ -26,58 +27,284 @@ func (j *Jellyfin) sessionsHandler(w http.ResponseWriter, r *http.Request) {
 		apierror(w, "error retrieving sessions", http.StatusInternalServerError)
 		return
 	}
+	// Administrators see playback of all users, e.g. for the dashboard's now playing view
+	playbackUserID := reqCtx.User.ID
+	if reqCtx.User.Properties.Admin {
+		playbackUserID = ""
+	}
+	playing := make(map[string]playSession)
+	for _, p := range j.activePlaySessions(playbackUserID) {
+		if current, ok := playing[p.Token.DeviceId]; !ok || p.LastCheckIn.After(current.LastCheckIn) {
+			playing[p.Token.DeviceId] = p
+		}
+	}
+
 	// Build session list based upon access tokens
 	var sessions []JFSessionInfo
 	for _, t := range accessTokens {
-		sessions = append(sessions, *j.makeJFSessionInfo(&t, reqCtx.User.Username))
+		session := j.makeJFSessionInfo(&t, reqCtx.User.Username)
+		if p, ok := playing[t.DeviceId]; ok && p.Token.UserID == t.UserID {
+			j.addJFSessionPlayState(r, session, &p)
+			delete(playing, t.DeviceId)
+		}
+		sessions = append(sessions, *session)
+	}
+	// Add sessions of other users that are playing
+	for _, p := range playing {
+		session := j.makeJFSessionInfo(&p.Token, p.UserName)
+		j.addJFSessionPlayState(r, session, &p)
+		sessions = append(sessions, *session)
 	}
 	serveJSON(sessions, w)
 }
 
+// addJFSessionPlayState adds the item being played and its play state to a session.
+func (j *Jellyfin) addJFSessionPlayState(r *http.Request, session *JFSessionInfo, p *playSession) {
+	session.LastPlaybackCheckIn = p.LastCheckIn
+	session.PlayState.PositionTicks = p.PositionTicks
+	session.PlayState.CanSeek = p.CanSeek
+	session.PlayState.IsPaused = p.IsPaused
+	session.PlayState.IsMuted = p.IsMuted
+	session.PlayState.PlayMethod = p.PlayMethod
+	session.PlayState.MediaSourceID = p.MediaSourceID
+	session.PlayState.PlaySessionID = p.ID
+	if c, i := j.collections.GetItemByID(trimPrefix(p.ItemID)); c != nil && i != nil {
+		if item, err := j.makeJFItem(r.Context(), p.Token.UserID, i, c.ID); err == nil {
+			session.NowPlayingItem = &item
+		}
+	}
+}
+
 func (j *Jellyfin) makeJFSessionInfo(accessToken *model.AccessToken, username string) *JFSessionInfo {
 	s := &JFSessionInfo{
-		ID:                    sessionID,
-		UserID:                accessToken.UserID,
-		UserName:              username,
-		LastActivityDate:      accessToken.LastUsed,
-		RemoteEndPoint:        accessToken.RemoteAddress,
-		DeviceName:            accessToken.DeviceName,
-		DeviceID:              accessToken.DeviceId,
-		Client:                accessToken.ApplicationName,
-		ApplicationVersion:    accessToken.ApplicationVersion,
-		IsActive:              true,
-		SupportsMediaControl:  false,
-		SupportsRemoteControl: false,
-		HasCustomDeviceName:   false,
-		ServerID:              j.serverID,
-		AdditionalUsers:       []string{},
+		ID:                  idhash.IdHash(accessToken.DeviceId),
+		UserID:              accessToken.UserID,
+		UserName:            username,
+		LastActivityDate:    accessToken.LastUsed,
+		RemoteEndPoint:      accessToken.RemoteAddress,
+		DeviceName:          accessToken.DeviceName,
+		DeviceID:            accessToken.DeviceId,
+		DeviceType:          deviceType(accessToken.ApplicationName, accessToken.DeviceName),
+		Client:              accessToken.ApplicationName,
+		ApplicationVersion:  accessToken.ApplicationVersion,
+		IsActive:            true,
+		HasCustomDeviceName: false,
+		ServerID:            j.serverID,
+		AdditionalUsers:     []string{},
 		PlayState: JFSessionResponsePlayState{
-			RepeatMode:    "RepeatNone",
-			PlaybackOrder: "Default",
-		},
-		Capabilities: JFSessionResponseCapabilities{
-			PlayableMediaTypes:           []string{},
-			SupportedCommands:            []string{},
-			SupportsPersistentIdentifier: true,
+			RepeatMode:    repeatModeNone,
+			PlaybackOrder: playbackOrderDefault,
 		},
 		NowPlayingQueue:          []string{},
 		NowPlayingQueueFullItems: []string{},
-		SupportedCommands:        []string{},
-		PlayableMediaTypes:       []string{},
 	}
+	if queue, ok := j.getPlayQueue(s.ID); ok && queue.UserID == accessToken.UserID {
+		s.PlayState.RepeatMode = queue.RepeatMode
+		s.PlayState.PlaybackOrder = queue.playbackOrder()
+		s.NowPlayingQueue = queue.ItemIDs
+	}
+	// Clients can be remote controlled if they support it and are connected
+	s.Capabilities = j.getCapabilities(accessToken.DeviceId)
+	s.SupportedCommands = s.Capabilities.SupportedCommands
+	s.PlayableMediaTypes = s.Capabilities.PlayableMediaTypes
+	s.SupportsMediaControl = s.Capabilities.SupportsMediaControl
+	s.SupportsRemoteControl = s.Capabilities.SupportsMediaControl && j.deviceConnected(accessToken.DeviceId)
 	return s
 }
 
 // /Sessions/Capabilities
 //
-// sessionsCapabilitiesHandler accepts the capabilities of the client. Ignored.
+// Supported query params:
+// - playableMediaTypes, comma separated list of media types the client can play, e.g. Video,Audio
+// - supportedCommands, comma separated list of remote control commands the client supports
+// - supportsMediaControl, true if the client can be remote controlled
+//
+// sessionsCapabilitiesHandler stores the capabilities of the client.
 func (j *Jellyfin) sessionsCapabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	queryparams := r.URL.Query()
+	capabilities := JFSessionResponseCapabilities{
+		PlayableMediaTypes:           splitList(queryparams.Get("playableMediaTypes")),
+		SupportedCommands:            splitList(queryparams.Get("supportedCommands")),
+		SupportsMediaControl:         strings.EqualFold(queryparams.Get("supportsMediaControl"), "true"),
+		SupportsPersistentIdentifier: true,
+	}
+	j.setCapabilities(reqCtx.Token.DeviceId, capabilities)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // /Sessions/Capabilities/Full
 //
-// sessionsCapabilitiesFullHandler accepts the capabilities of the client. Ignored.
+// sessionsCapabilitiesFullHandler stores the capabilities of the client.
 func (j *Jellyfin) sessionsCapabilitiesFullHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	var capabilities JFSessionResponseCapabilities
+	if err := json.NewDecoder(r.Body).Decode(&capabilities); err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	capabilities.SupportsPersistentIdentifier = true
+	j.setCapabilities(reqCtx.Token.DeviceId, capabilities)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /Sessions/{session}/Playing
+//
+// Supported query params:
+// - itemIds, comma separated list of items to play
+// - playCommand, PlayNow, PlayNext or PlayLast
+// - startPositionTicks, position to start playback of the first item at
+//
+// sessionsPlayHandler instructs a client to play items.
+func (j *Jellyfin) sessionsPlayHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	queryparams := r.URL.Query()
+	itemIDs := splitList(queryparams.Get("itemIds"))
+	if len(itemIDs) == 0 {
+		apierror(w, "itemIds is required", http.StatusBadRequest)
+		return
+	}
+	playCommand := queryparams.Get("playCommand")
+	if playCommand == "" {
+		playCommand = "PlayNow"
+	}
+	startPositionTicks, _ := strconv.ParseInt(queryparams.Get("startPositionTicks"), 10, 64)
+	msg := JFSocketMessage{
+		MessageType: "Play",
+		MessageID:   randomID(),
+		Data: JFPlayRequest{
+			ItemIDs:            itemIDs,
+			StartPositionTicks: startPositionTicks,
+			PlayCommand:        playCommand,
+			ControllingUserID:  reqCtx.User.ID,
+		},
+	}
+	j.sendSessionCommand(w, reqCtx, mux.Vars(r)["sessionid"], "", msg)
+}
+
+// POST /Sessions/{session}/Playing/{command}
+//
+// Supported query params:
+// - seekPositionTicks, position to seek to, for command Seek
+//
+// sessionsPlaystateHandler instructs a client to change playback, e.g. Pause or Seek.
+func (j *Jellyfin) sessionsPlaystateHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	seekPositionTicks, _ := strconv.ParseInt(r.URL.Query().Get("seekPositionTicks"), 10, 64)
+	msg := JFSocketMessage{
+		MessageType: "Playstate",
+		MessageID:   randomID(),
+		Data: JFPlaystateRequest{
+			Command:           mux.Vars(r)["command"],
+			SeekPositionTicks: seekPositionTicks,
+			ControllingUserID: reqCtx.User.ID,
+		},
+	}
+	j.sendSessionCommand(w, reqCtx, mux.Vars(r)["sessionid"], "", msg)
+}
+
+// POST /Sessions/{session}/Command/{command}
+//
+// sessionsGeneralCommandHandler sends a command to a client, e.g. ToggleMute. The
+// client must have reported the command as supported.
+func (j *Jellyfin) sessionsGeneralCommandHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	command := mux.Vars(r)["command"]
+	msg := JFSocketMessage{
+		MessageType: "GeneralCommand",
+		MessageID:   randomID(),
+		Data: JFGeneralCommand{
+			Name:              command,
+			ControllingUserID: reqCtx.User.ID,
+			Arguments:         map[string]string{},
+		},
+	}
+	j.sendSessionCommand(w, reqCtx, mux.Vars(r)["sessionid"], command, msg)
+}
+
+// sendSessionCommand sends a remote control message to the connected client of a session.
+// Clients must support media control, and general commands must be reported as supported.
+func (j *Jellyfin) sendSessionCommand(w http.ResponseWriter, reqCtx *requestContext, sessionID, command string, msg JFSocketMessage) {
+	var target []*socketConn
+	for _, s := range j.deviceSockets() {
+		if idhash.IdHash(s.deviceID) == sessionID {
+			target = append(target, s)
+		}
+	}
+	if len(target) == 0 {
+		apierror(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if target[0].userID != reqCtx.User.ID && !reqCtx.User.Properties.Admin {
+		apierror(w, "Not allowed to control this session", http.StatusForbidden)
+		return
+	}
+	capabilities := j.getCapabilities(target[0].deviceID)
+	if !capabilities.SupportsMediaControl {
+		apierror(w, "Session does not support remote control", http.StatusBadRequest)
+		return
+	}
+	if command != "" && !slices.ContainsFunc(capabilities.SupportedCommands, func(c string) bool {
+		return strings.EqualFold(c, command)
+	}) {
+		apierror(w, "Session does not support command "+command, http.StatusBadRequest)
+		return
+	}
+	for _, s := range target {
+		s.sendMessage(msg)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// setCapabilities stores the capabilities reported by a client.
+func (j *Jellyfin) setCapabilities(deviceID string, capabilities JFSessionResponseCapabilities) {
+	if capabilities.PlayableMediaTypes == nil {
+		capabilities.PlayableMediaTypes = []string{}
+	}
+	if capabilities.SupportedCommands == nil {
+		capabilities.SupportedCommands = []string{}
+	}
+	j.capabilitiesMu.Lock()
+	defer j.capabilitiesMu.Unlock()
+	j.capabilities[deviceID] = capabilities
+}
+
+// getCapabilities returns the capabilities reported by a client, if any.
+func (j *Jellyfin) getCapabilities(deviceID string) JFSessionResponseCapabilities {
+	j.capabilitiesMu.Lock()
+	defer j.capabilitiesMu.Unlock()
+	if capabilities, ok := j.capabilities[deviceID]; ok {
+		return capabilities
+	}
+	return JFSessionResponseCapabilities{
+		PlayableMediaTypes:           []string{},
+		SupportedCommands:            []string{},
+		SupportsPersistentIdentifier: true,
+	}
+}
+
+// splitList splits a comma separated list, empty entries are skipped.
+func splitList(s string) []string {
+	list := []string{}
+	for entry := range strings.SplitSeq(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
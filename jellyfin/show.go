@@ -42,21 +42,37 @@ func (j *Jellyfin) showsEpisodesHandler(w http.ResponseWriter, r *http.Request)
 		log.Printf("showsEpisodesHandler: rewritten seasonID %s request to show request with season filter, showID: %s, seasonID: %s\n", vars["show"], showID, seasonID)
 	}
 
-	_, show := j.collections.GetShowByID(showID)
+	c, show := j.collections.GetShowByID(showID)
 	if show == nil {
 		apierror(w, "Show not found", http.StatusNotFound)
 		return
 	}
-	// Create API response for all episodes of the show
+	if j.pinLocked(r.Context(), c.ID) {
+		apierror(w, errPinRequired.Error(), http.StatusForbidden)
+		return
+	}
+	// Create API response for all episodes of the show, or one season if requested.
+	// Specials that aired during a season are listed between the episodes of that season.
 	episodes := make([]JFItem, 0)
-	for _, s := range show.Seasons {
-		if episodesOfSeason, err := j.makeJFEpisodesOverview(r.Context(), reqCtx.User.ID, &s); err == nil {
-			episodes = append(episodes, episodesOfSeason...)
+	if _, _, season := j.collections.GetSeasonByID(trimPrefix(queryparams.Get("seasonId"))); season != nil && season.Number() != 0 {
+		episodes, _ = j.makeJFEpisodesOverview(r.Context(), reqCtx.User.ID, season)
+		queryparams.Del("seasonId")
+	} else {
+		for _, e := range show.EpisodesInWatchOrder(true) {
+			if episode, err := j.makeJFItemEpisode(r.Context(), reqCtx.User.ID, e, ""); err == nil {
+				episodes = append(episodes, episode)
+			}
 		}
 	}
+	// Add episodes that have aired but are not available, if the user wants to see them
+	if reqCtx.User.Properties.DisplayMissingEpisodes {
+		episodes = append(episodes, j.makeJFMissingEpisodes(r.Context(), show)...)
+	}
 
 	// Apply filtering, e.g. if a particular season is requested ("seasonId")
 	episodes = j.applyItemsFilter(episodes, queryparams)
+	episodes = j.applyParentalRating(episodes, reqCtx.User)
+	episodes = j.applyHiddenItems(episodes, reqCtx.User)
 
 	episodes = j.applyItemSorting(episodes, queryparams)
 
@@ -81,11 +97,15 @@ func (j *Jellyfin) showsSeasonsHandler(w http.ResponseWriter, r *http.Request) {
 	queryparams := r.URL.Query()
 
 	showID := vars["showid"]
-	_, show := j.collections.GetShowByID(showID)
+	c, show := j.collections.GetShowByID(showID)
 	if show == nil {
 		apierror(w, "Show not found", http.StatusNotFound)
 		return
 	}
+	if j.pinLocked(r.Context(), c.ID) {
+		apierror(w, errPinRequired.Error(), http.StatusForbidden)
+		return
+	}
 	seasons, err := j.makeJFSeasonsOverview(r.Context(), reqCtx.User.ID, show)
 	if err != nil {
 		apierror(w, "Could not generate seasons overview", http.StatusInternalServerError)
@@ -93,6 +113,8 @@ func (j *Jellyfin) showsSeasonsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	seasons = j.applyItemsFilter(seasons, queryparams)
+	seasons = j.applyParentalRating(seasons, reqCtx.User)
+	seasons = j.applyHiddenItems(seasons, reqCtx.User)
 
 	// Always sort seasons by number, no user provided sortBy option.
 	// This way season 99, Specials ends up last.
@@ -126,38 +148,36 @@ func (j *Jellyfin) showsNextUpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	queryparams := r.URL.Query()
-	seriesID := queryparams.Get("seriesId")
 
-	var nextUpItemIDs []string
-	if seriesID != "" {
-		recentlyWatchedIDs, err := j.repo.GetRecentlyWatched(r.Context(), reqCtx.User.ID, 100000, true)
-		if err != nil {
-			apierror(w, "Could not get recently watched items list", http.StatusInternalServerError)
-			return
-		}
-		// Get next up items based on recently watched items for a series
-		nextUpItemIDs, err = j.collections.NextUpInSeries(recentlyWatchedIDs, seriesID)
-		if err != nil {
-			apierror(w, "Could not get next up items list", http.StatusInternalServerError)
-			return
+	options := collection.NextUpOptions{
+		SeriesID:            queryparams.Get("seriesId"),
+		DisableFirstEpisode: strings.EqualFold(queryparams.Get("disableFirstEpisode"), "true"),
+		EnableRewatching:    strings.EqualFold(queryparams.Get("enableRewatching"), "true"),
+	}
+	if cutoff := queryparams.Get("nextUpDateCutoff"); cutoff != "" {
+		if cutoffDate, err := parseISO8601date(cutoff); err == nil {
+			options.Cutoff = cutoffDate
 		}
 	}
 
-	// If no next up items found for the series, or no seriesID provided
-	// get next up items based on recently watched items across all series
-	if len(nextUpItemIDs) == 0 {
-		recentlyWatchedIDs, err := j.repo.GetRecentlyWatched(r.Context(), reqCtx.User.ID, 10, true)
-		if err != nil {
-			apierror(w, "Could not get recently watched items list", http.StatusInternalServerError)
-			return
-		}
-		// Get next up items based on recently watched items and optional seriesID filter
-		nextUpItemIDs, err = j.collections.NextUpInCollection(recentlyWatchedIDs, seriesID)
-		if err != nil {
-			apierror(w, "Could not get next up items list", http.StatusInternalServerError)
-			return
+	watchedIDs, err := j.repo.GetRecentlyWatched(r.Context(), reqCtx.User.ID, 100000, true)
+	if err != nil {
+		apierror(w, "Could not get recently watched items list", http.StatusInternalServerError)
+		return
+	}
+	watched := make([]collection.WatchedItem, 0, len(watchedIDs))
+	for _, id := range watchedIDs {
+		userData, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, id)
+		if err != nil || (!userData.Played && userData.Position == 0) {
+			continue
 		}
+		watched = append(watched, collection.WatchedItem{
+			ID:        id,
+			Played:    userData.Played,
+			Timestamp: userData.Timestamp,
+		})
 	}
+	nextUpItemIDs := j.collections.NextUp(watched, options)
 
 	items := make([]JFItem, 0, len(nextUpItemIDs))
 	for _, id := range nextUpItemIDs {
@@ -172,6 +192,9 @@ func (j *Jellyfin) showsNextUpHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
 
 	// Apply user provided filters & sorting
 	items = j.applyItemSorting(items, queryparams)
@@ -196,15 +219,17 @@ func (j *Jellyfin) makeJFItemShow(ctx context.Context, userID string, show *coll
 		Name:                    show.Name(),
 		OriginalTitle:           show.Name(),
 		SortName:                show.SortName(),
-		ForcedSortName:          show.SortName(),
+		ForcedSortName:          j.collections.SortNameOverride(show.ID()),
 		Genres:                  show.Metadata.Genres(),
 		GenreItems:              makeJFGenreItems(show.Metadata.Genres()),
 		Studios:                 makeJFStudios(show.Metadata.Studios()),
+		ProductionLocations:     show.Metadata.Countries(),
+		ProviderIds:             makeJFProviderIds(show.Metadata.ProviderIDs()),
 		IsFolder:                true,
 		Etag:                    show.Etag(),
 		DateCreated:             show.FirstVideo().UTC(),
 		PrimaryImageAspectRatio: 0.6666666666666666,
-		CanDelete:               false,
+		CanDelete:               j.deleteFiles,
 		CanDownload:             true,
 		PlayAccess:              "Full",
 		ImageTags: &JFImageTags{
@@ -226,11 +251,11 @@ func (j *Jellyfin) makeJFItemShow(ctx context.Context, userID string, show *coll
 		Tags:            []string{},
 		Taglines:        []string{show.Metadata.Tagline()},
 		Trickplay:       []string{},
-		LockedFields:    []string{},
 	}
+	response.LockData, response.LockedFields = j.makeJFLockedFields(show.ID())
 
 	// Show logo tends to be optional
-	if show.Logo() != "" {
+	if _, ok := j.collections.UploadedArtwork(show.ID(), collection.ArtworkLogo); show.Logo() != "" || ok {
 		response.ImageTags.Logo = show.ID()
 	}
 
@@ -243,7 +268,7 @@ func (j *Jellyfin) makeJFItemShow(ctx context.Context, userID string, show *coll
 		response.ProductionYear = show.Metadata.Year()
 	}
 
-	if !show.Metadata.Premiered().IsZero() {
+	if show.Metadata.Premiered().Known() {
 		response.PremiereDate = show.Metadata.Premiered().UTC()
 	} else {
 		response.PremiereDate = show.FirstVideo().UTC()
@@ -270,24 +295,20 @@ func (j *Jellyfin) makeJFItemShow(ctx context.Context, userID string, show *coll
 		response.RecursiveItemCount += len(s.Episodes)
 	}
 
-	// Calculate the number of episodes and played episode in the show
-	var playedEpisodes, totalEpisodes int
-	var lastestPlayed time.Time
+	// Get the number of episodes and played episodes in the show
+	var episodeIDs []string
 	for _, s := range show.Seasons {
 		for _, e := range s.Episodes {
-			totalEpisodes++
-			// Get playstate of episode
-			episodePlaystate, err := j.repo.GetUserData(ctx, userID, e.ID())
-			if err == nil && episodePlaystate != nil {
-				if episodePlaystate.Played {
-					playedEpisodes++
-					if episodePlaystate.Timestamp.After(lastestPlayed) {
-						lastestPlayed = episodePlaystate.Timestamp
-					}
-				}
-			}
+			episodeIDs = append(episodeIDs, e.ID())
 		}
 	}
+	totalEpisodes := len(episodeIDs)
+	playedCount, err := j.repo.GetPlayedCount(ctx, userID, show.ID(), episodeIDs)
+	if err != nil {
+		return response, err
+	}
+	playedEpisodes := min(playedCount.Played, totalEpisodes)
+	lastestPlayed := playedCount.LastPlayed
 
 	// In case show has played episodes get playstate of the show itself
 	if totalEpisodes != 0 {
@@ -342,8 +363,7 @@ func (j *Jellyfin) makeJFItemSeason(ctx context.Context, userID string, season *
 		MediaType:          "Unknown",
 		ChildCount:         len(season.Episodes),
 		RecursiveItemCount: len(season.Episodes),
-		DateCreated:        time.Now().UTC(),
-		PremiereDate:       time.Now().UTC(),
+		DateCreated:        season.FirstVideo().UTC(),
 		CanDelete:          false,
 		CanDownload:        true,
 		PlayAccess:         "Full",
@@ -375,9 +395,7 @@ func (j *Jellyfin) makeJFItemSeason(ctx context.Context, userID string, season *
 	}
 
 	// Set season premiere date to first episode airdate if available
-	if len(season.Episodes) != 0 {
-		response.PremiereDate = season.Episodes[0].Metadata.Premiered()
-	}
+	response.PremiereDate = season.Premiered().UTC()
 
 	// Get playstate of the season itself
 	playstate, err := j.repo.GetUserData(ctx, userID, season.ID())
@@ -388,20 +406,17 @@ func (j *Jellyfin) makeJFItemSeason(ctx context.Context, userID string, season *
 	}
 	response.UserData = j.makeJFUserData(userID, season.ID(), playstate)
 
-	// Calculate the number of played episodes in the season
-	var playedEpisodes int
-	var lastestPlayed time.Time
+	// Get the number of played episodes in the season
+	episodeIDs := make([]string, 0, len(season.Episodes))
 	for _, e := range season.Episodes {
-		episodePlaystate, err := j.repo.GetUserData(ctx, userID, e.ID())
-		if err == nil {
-			if episodePlaystate.Played {
-				playedEpisodes++
-				if episodePlaystate.Timestamp.After(lastestPlayed) {
-					lastestPlayed = episodePlaystate.Timestamp
-				}
-			}
-		}
+		episodeIDs = append(episodeIDs, e.ID())
+	}
+	playedCount, err := j.repo.GetPlayedCount(ctx, userID, season.ID(), episodeIDs)
+	if err != nil {
+		return response, err
 	}
+	playedEpisodes := min(playedCount.Played, response.ChildCount)
+	lastestPlayed := playedCount.LastPlayed
 
 	// Populate playstate fields with playstate of episodes in the season
 	response.UserData.UnplayedItemCount = response.ChildCount - playedEpisodes
@@ -424,9 +439,21 @@ func makeSeasonName(seasonNo int) string {
 	}
 }
 
-// makeJFEpisodesOverview generates all episode items for one season of a show
+// makeJFEpisodesOverview generates all episode items for one season of a show,
+// specials that aired during a regular season are included between its episodes.
 func (j *Jellyfin) makeJFEpisodesOverview(ctx context.Context, userID string, season *collection.Season) ([]JFItem, error) {
 	episodes := make([]JFItem, 0, len(season.Episodes))
+	if _, show, _ := j.collections.GetSeasonByID(season.ID()); show != nil && season.Number() != 0 {
+		for _, e := range show.EpisodesInWatchOrder(false) {
+			if e.DisplaySeasonNo() != season.Number() {
+				continue
+			}
+			if episode, err := j.makeJFItemEpisode(ctx, userID, e, season.ID()); err == nil {
+				episodes = append(episodes, episode)
+			}
+		}
+		return episodes, nil
+	}
 	for _, e := range season.Episodes {
 		if episode, err := j.makeJFItemEpisode(ctx, userID, &e, season.ID()); err == nil {
 			episodes = append(episodes, episode)
@@ -435,6 +462,53 @@ func (j *Jellyfin) makeJFEpisodesOverview(ctx context.Context, userID string, se
 	return episodes, nil
 }
 
+// makeJFMissingEpisodes generates virtual episode items for episodes of a show that are not available.
+func (j *Jellyfin) makeJFMissingEpisodes(ctx context.Context, show *collection.Show) []JFItem {
+	missing, err := j.collections.MissingEpisodes(ctx, show)
+	if err != nil {
+		log.Printf("makeJFMissingEpisodes: show %s: %s\n", show.Name(), err)
+		return nil
+	}
+	episodes := make([]JFItem, 0, len(missing))
+	for _, m := range missing {
+		response := JFItem{
+			Type:              itemTypeEpisode,
+			ID:                makeJFEpisodeID(m.ID),
+			Name:              m.Name,
+			SeasonName:        makeSeasonName(m.SeasonNo),
+			SeriesID:          show.ID(),
+			SeriesName:        show.Name(),
+			ParentLogoItemId:  show.ID(),
+			ServerID:          j.serverID,
+			ParentIndexNumber: m.SeasonNo,
+			IndexNumber:       m.EpisodeNo,
+			Overview:          m.Overview,
+			PremiereDate:      m.Aired,
+			IsFolder:          false,
+			LocationType:      "Virtual",
+			MediaType:         "Video",
+			CanDelete:         false,
+			CanDownload:       false,
+			ChannelID:         nil,
+			Chapters:          []JFChapter{},
+			ExternalUrls:      []JFExternalUrls{},
+			People:            []JFPeople{},
+			RemoteTrailers:    []JFRemoteTrailers{},
+			Tags:              []string{},
+			Taglines:          []string{},
+			Trickplay:         []string{},
+			LockedFields:      []string{},
+		}
+		for _, s := range show.Seasons {
+			if s.Number() == m.SeasonNo {
+				response.SeasonID = makeJFSeasonID(s.ID())
+			}
+		}
+		episodes = append(episodes, response)
+	}
+	return episodes
+}
+
 // makeJFItemEpisode makes an episode item
 func (j *Jellyfin) makeJFItemEpisode(ctx context.Context, userID string, episode *collection.Episode, _ string) (JFItem, error) {
 	_, show, season, episode := j.collections.GetEpisodeByID(episode.ID())
@@ -466,7 +540,7 @@ func (j *Jellyfin) makeJFItemEpisode(ctx context.Context, userID string, episode
 		Container:         "mov,mp4,m4a",
 		DateCreated:       episode.Created().UTC(),
 		HasSubtitles:      true,
-		CanDelete:         false,
+		CanDelete:         j.deleteFiles,
 		CanDownload:       true,
 		PlayAccess:        "Full",
 		Width:             episode.VideoWidth(),
@@ -485,12 +559,23 @@ func (j *Jellyfin) makeJFItemEpisode(ctx context.Context, userID string, episode
 		LockedFields:      []string{},
 	}
 
-	if episode.Poster() != "" {
+	if _, ok := j.collections.Thumbnail(episode.ID()); episode.Poster() != "" || ok {
 		response.ImageTags = &JFImageTags{
 			Primary: episode.ID(),
 		}
 	}
 
+	// Only set for collections with absolute or tvdb episode order
+	response.AbsoluteEpisodeNumber = episode.AbsoluteEpisodeNo
+
+	// Position of specials between the regular episodes
+	if episode.SeasonNo == 0 {
+		placement := episode.Metadata.SpecialPlacement()
+		response.AirsBeforeSeasonNumber = placement.AirsBeforeSeason
+		response.AirsBeforeEpisodeNumber = placement.AirsBeforeEpisode
+		response.AirsAfterSeasonNumber = placement.AirsAfterSeason
+	}
+
 	// Get genres from episode, if not available use show genres
 	genres := episode.Metadata.Genres()
 	if len(genres) == 0 {
@@ -511,8 +596,9 @@ func (j *Jellyfin) makeJFItemEpisode(ctx context.Context, userID string, episode
 		response.Name = episode.Metadata.Title()
 	}
 
-	if !show.Metadata.Premiered().IsZero() {
-		response.PremiereDate = show.Metadata.Premiered()
+	// Set premiere date to the airdate of the episode if available else from file timestamp
+	if episode.Metadata.Premiered().Known() {
+		response.PremiereDate = episode.Metadata.Premiered().UTC()
 	} else {
 		response.PremiereDate = episode.Created().UTC()
 	}
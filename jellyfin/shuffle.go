@@ -0,0 +1,134 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+)
+
+// shuffleDefaultLimit is the number of items returned in a shuffle queue in case no limit is provided.
+const shuffleDefaultLimit = 100
+
+// /Items/{item}/Shuffle
+//
+// Supported query params:
+// - limit, number of items to return, defaults to 100
+// - isPlayed=true, include watched episodes as well
+//
+// itemsShuffleHandler returns a randomized play queue for a show, season or collection.
+// For shows and seasons this is a list of unwatched episodes, for a collection random movies
+// or unwatched episodes across all shows of the collection.
+func (j *Jellyfin) itemsShuffleHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["itemid"]
+	queryparams := r.URL.Query()
+	includePlayed := strings.EqualFold(queryparams.Get("isPlayed"), "true")
+	queryparams.Del("isPlayed")
+
+	items, err := j.makeJFShuffleItems(r.Context(), reqCtx.User.ID, itemID)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Leave out watched episodes, unless all of them have been watched
+	if !includePlayed {
+		unplayed := make([]JFItem, 0, len(items))
+		for _, item := range items {
+			if item.Type == itemTypeEpisode && item.UserData != nil && item.UserData.Played {
+				continue
+			}
+			unplayed = append(unplayed, item)
+		}
+		if len(unplayed) > 0 {
+			items = unplayed
+		}
+	}
+
+	items = j.applyItemsFilter(items, queryparams)
+	items = j.applyParentalRating(items, reqCtx.User)
+	items = j.applyHiddenItems(items, reqCtx.User)
+	items = j.applyPinLock(r.Context(), items)
+	rand.Shuffle(len(items), func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+
+	if queryparams.Get("limit") == "" {
+		queryparams.Set("limit", strconv.Itoa(shuffleDefaultLimit))
+	}
+	totalItemCount := len(items)
+	responseItems, startIndex := j.applyItemPaginating(items, queryparams)
+	response := UserItemsResponse{
+		Items:            responseItems,
+		StartIndex:       startIndex,
+		TotalRecordCount: totalItemCount,
+	}
+	serveJSON(response, w)
+}
+
+// makeJFShuffleItems returns all playable items of a show, season or collection.
+func (j *Jellyfin) makeJFShuffleItems(ctx context.Context, userID, itemID string) ([]JFItem, error) {
+	switch {
+	case isJFCollectionID(itemID):
+		c := j.collections.GetCollection(trimPrefix(itemID))
+		if c == nil {
+			return nil, errors.New("could not find collection")
+		}
+		items := make([]JFItem, 0, len(c.Items))
+		for _, i := range c.Items {
+			switch v := i.(type) {
+			case *collection.Movie:
+				if jfitem, err := j.makeJFItemMovie(ctx, userID, v, c.ID); err == nil {
+					items = append(items, jfitem)
+				}
+			case *collection.Show:
+				items = append(items, j.makeJFShuffleEpisodes(ctx, userID, v.Seasons)...)
+			}
+		}
+		return items, nil
+
+	case isJFSeasonID(itemID):
+		_, _, season := j.collections.GetSeasonByID(trimPrefix(itemID))
+		if season == nil {
+			return nil, errors.New("could not find season")
+		}
+		return j.makeJFShuffleEpisodes(ctx, userID, collection.Seasons{*season}), nil
+	}
+
+	if _, show := j.collections.GetShowByID(trimPrefix(itemID)); show != nil {
+		return j.makeJFShuffleEpisodes(ctx, userID, show.Seasons), nil
+	}
+	if c, i := j.collections.GetItemByID(trimPrefix(itemID)); i != nil {
+		if movie, ok := i.(*collection.Movie); ok {
+			jfitem, err := j.makeJFItemMovie(ctx, userID, movie, c.ID)
+			return []JFItem{jfitem}, err
+		}
+	}
+	return nil, errors.New("item not found")
+}
+
+// makeJFShuffleEpisodes returns all episodes of the provided seasons, specials are skipped.
+func (j *Jellyfin) makeJFShuffleEpisodes(ctx context.Context, userID string, seasons collection.Seasons) []JFItem {
+	var episodes []JFItem
+	for _, s := range seasons {
+		if s.Number() == 0 {
+			continue
+		}
+		if items, err := j.makeJFEpisodesOverview(ctx, userID, &s); err == nil {
+			episodes = append(episodes, items...)
+		}
+	}
+	return episodes
+}
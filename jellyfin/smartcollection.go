@@ -0,0 +1,232 @@
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/idhash"
+)
+
+// smartCollectionsKey is the settings key the smart collections created through the API are stored under.
+const smartCollectionsKey = "jellyfin.smartcollections"
+
+// SmartCollection is a view of all items matching a saved filter, e.g. "4K HDR movies".
+type SmartCollection struct {
+	// ID of the smart collection, generated if not set
+	ID string `json:"Id"`
+	// Name shown as view name, e.g. "Unwatched 80s horror"
+	Name string `json:"Name"`
+	// Filter holds the /Items query parameters items need to match,
+	// e.g. "includeItemTypes=Movie&genres=Horror&years=1980,1981,1982&isPlayed=false"
+	Filter string `json:"Filter"`
+	// ReadOnly indicates the smart collection is defined in the config file
+	ReadOnly bool `json:"ReadOnly"`
+}
+
+// smartCollections returns the smart collections of the config file followed by the
+// ones created through the API.
+func (j *Jellyfin) smartCollections(ctx context.Context) []SmartCollection {
+	collections := make([]SmartCollection, 0, len(j.smartCollectionsConfig))
+	for _, sc := range j.smartCollectionsConfig {
+		sc.ID = idhash.IdHash(sc.Name)
+		sc.ReadOnly = true
+		collections = append(collections, sc)
+	}
+	return append(collections, j.storedSmartCollections(ctx)...)
+}
+
+// storedSmartCollections returns the smart collections created through the API.
+func (j *Jellyfin) storedSmartCollections(ctx context.Context) []SmartCollection {
+	stored, err := j.repo.GetSetting(ctx, smartCollectionsKey)
+	if err != nil || stored == "" {
+		return nil
+	}
+	var collections []SmartCollection
+	if err := json.Unmarshal([]byte(stored), &collections); err != nil {
+		log.Printf("Ignoring invalid stored smart collections: %s", err)
+		return nil
+	}
+	return collections
+}
+
+// storeSmartCollections stores the smart collections created through the API.
+func (j *Jellyfin) storeSmartCollections(ctx context.Context, collections []SmartCollection) error {
+	data, err := json.Marshal(collections)
+	if err != nil {
+		return err
+	}
+	return j.repo.UpsertSetting(ctx, smartCollectionsKey, string(data))
+}
+
+// getSmartCollection returns a smart collection by its id.
+func (j *Jellyfin) getSmartCollection(ctx context.Context, smartCollectionID string) (SmartCollection, error) {
+	for _, sc := range j.smartCollections(ctx) {
+		if sc.ID == smartCollectionID {
+			return sc, nil
+		}
+	}
+	return SmartCollection{}, errors.New("smart collection not found")
+}
+
+// GET /Library/SmartCollections
+//
+// smartCollectionsHandler returns all smart collections
+func (j *Jellyfin) smartCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	serveJSON(j.smartCollections(r.Context()), w)
+}
+
+// POST /Library/SmartCollections
+//
+// smartCollectionsPostHandler creates a smart collection, or updates an existing one if Id is provided.
+// The filter uses the query parameters of /Items, e.g. {"Name": "4K movies", "Filter": "includeItemTypes=Movie&is4K=true"}
+func (j *Jellyfin) smartCollectionsPostHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can change smart collections", http.StatusForbidden)
+		return
+	}
+	var request SmartCollection
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		apierror(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := url.ParseQuery(request.Filter); err != nil {
+		apierror(w, "Invalid filter", http.StatusBadRequest)
+		return
+	}
+	request.ReadOnly = false
+
+	collections := j.storedSmartCollections(r.Context())
+	if request.ID == "" {
+		request.ID = idhash.NewRandomID()
+		collections = append(collections, request)
+	} else {
+		index := slices.IndexFunc(collections, func(sc SmartCollection) bool { return sc.ID == request.ID })
+		if index == -1 {
+			apierror(w, "Smart collection not found or defined in config file", http.StatusNotFound)
+			return
+		}
+		collections[index] = request
+	}
+	if err := j.storeSmartCollections(r.Context(), collections); err != nil {
+		log.Printf("Failed to store smart collections: %s", err)
+		apierror(w, "Failed to store smart collection", http.StatusInternalServerError)
+		return
+	}
+	serveJSON(request, w)
+}
+
+// DELETE /Library/SmartCollections/{id}
+//
+// smartCollectionsDeleteHandler deletes a smart collection created through the API
+func (j *Jellyfin) smartCollectionsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can change smart collections", http.StatusForbidden)
+		return
+	}
+	smartCollectionID := trimPrefix(mux.Vars(r)["id"])
+	collections := j.storedSmartCollections(r.Context())
+	index := slices.IndexFunc(collections, func(sc SmartCollection) bool { return sc.ID == smartCollectionID })
+	if index == -1 {
+		apierror(w, "Smart collection not found or defined in config file", http.StatusNotFound)
+		return
+	}
+	if err := j.storeSmartCollections(r.Context(), slices.Delete(collections, index, index+1)); err != nil {
+		log.Printf("Failed to store smart collections: %s", err)
+		apierror(w, "Failed to delete smart collection", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// makeJFItemSmartCollectionOverview creates a list of items matching the filter of a smart collection.
+func (j *Jellyfin) makeJFItemSmartCollectionOverview(ctx context.Context, userID, smartCollectionID string) ([]JFItem, error) {
+	sc, err := j.getSmartCollection(ctx, smartCollectionID)
+	if err != nil {
+		return []JFItem{}, err
+	}
+	filter, err := url.ParseQuery(sc.Filter)
+	if err != nil {
+		return []JFItem{}, err
+	}
+	items, err := j.getJFItemsAll(ctx, userID)
+	if err != nil {
+		return []JFItem{}, err
+	}
+	return j.applyItemsFilter(items, filter), nil
+}
+
+// makeJFItemSmartCollection creates a collection item for a smart collection.
+func (j *Jellyfin) makeJFItemSmartCollection(ctx context.Context, userID, smartCollectionID string) (JFItem, error) {
+	sc, err := j.getSmartCollection(ctx, smartCollectionID)
+	if err != nil {
+		return JFItem{}, err
+	}
+	var itemCount int
+	if items, err := j.makeJFItemSmartCollectionOverview(ctx, userID, smartCollectionID); err == nil {
+		itemCount = len(items)
+	}
+
+	id := makeJFSmartCollectionID(smartCollectionID)
+	response := JFItem{
+		Name:                     sc.Name,
+		ServerID:                 j.serverID,
+		ID:                       id,
+		ParentID:                 makeJFRootID(collectionRootID),
+		Etag:                     idhash.Hash(smartCollectionID + sc.Name + sc.Filter),
+		CollectionType:           collectionTypePlaylists,
+		SortName:                 strings.ToLower(sc.Name),
+		Type:                     itemTypeUserView,
+		IsFolder:                 true,
+		EnableMediaSourceDisplay: true,
+		ChildCount:               itemCount,
+		DisplayPreferencesID:     makeJFDisplayPreferencesID(smartCollectionID),
+		ExternalUrls:             []JFExternalUrls{},
+		PlayAccess:               "Full",
+		PrimaryImageAspectRatio:  1.7777777777777777,
+		RemoteTrailers:           []JFRemoteTrailers{},
+		LocationType:             "FileSystem",
+		Path:                     "/collection",
+		LockData:                 false,
+		MediaType:                "Unknown",
+		CanDelete:                false,
+		CanDownload:              true,
+		SpecialFeatureCount:      0,
+		ImageTags:                j.makeJFImageTags(ctx, id, imageTypePrimary),
+	}
+	return response, nil
+}
+
+// makeJFSmartCollectionID returns an external id for a smart collection.
+func makeJFSmartCollectionID(smartCollectionID string) string {
+	return itemprefix_smartcollection + smartCollectionID
+}
+
+// isJFSmartCollectionID checks if the provided ID is a smart collection ID.
+func isJFSmartCollectionID(id string) bool {
+	return strings.HasPrefix(id, itemprefix_smartcollection)
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -95,8 +94,6 @@ func (j *Jellyfin) makeJFItemStudio(ctx context.Context, _ string, studioID stri
 		Name:              studio,
 		SortName:          studio,
 		Etag:              studioID,
-		DateCreated:       time.Now().UTC(),
-		PremiereDate:      time.Now().UTC(),
 		LocationType:      "FileSystem",
 		MediaType:         "Unknown",
 		ImageBlurHashes:   &JFImageBlurHashes{},
@@ -0,0 +1,237 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/opensubtitles"
+)
+
+// SubtitleProvider searches and downloads subtitles from an online subtitle database.
+type SubtitleProvider interface {
+	SearchSubtitles(ctx context.Context, q opensubtitles.Query) ([]opensubtitles.Subtitle, error)
+	DownloadSubtitle(ctx context.Context, fileID string) ([]byte, error)
+}
+
+// subtitleProviderName is the provider name reported to clients.
+const subtitleProviderName = "Open Subtitles"
+
+// languageCodes maps ISO 639-2 language codes, as used by clients, to ISO 639-1
+// codes as used in subtitle filenames.
+var languageCodes = map[string]string{
+	"ara": "ar", "bul": "bg", "cat": "ca", "ces": "cs", "cze": "cs", "chi": "zh", "zho": "zh",
+	"dan": "da", "deu": "de", "ger": "de", "ell": "el", "gre": "el", "eng": "en", "est": "et",
+	"fas": "fa", "per": "fa", "fin": "fi", "fra": "fr", "fre": "fr", "heb": "he", "hin": "hi",
+	"hrv": "hr", "hun": "hu", "ind": "id", "isl": "is", "ice": "is", "ita": "it", "jpn": "ja",
+	"kor": "ko", "lav": "lv", "lit": "lt", "msa": "ms", "may": "ms", "nld": "nl", "dut": "nl",
+	"nor": "no", "nob": "no", "pol": "pl", "por": "pt", "ron": "ro", "rum": "ro", "rus": "ru",
+	"slk": "sk", "slo": "sk", "slv": "sl", "spa": "es", "srp": "sr", "swe": "sv", "tha": "th",
+	"tur": "tr", "ukr": "uk", "vie": "vi",
+}
+
+// GET /Items/{item}/RemoteSearch/Subtitles/{language}
+//
+// itemsRemoteSearchSubtitlesHandler searches subtitles of a movie or episode in a language.
+func (j *Jellyfin) itemsRemoteSearchSubtitlesHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can search subtitles", http.StatusForbidden)
+		return
+	}
+	vars := mux.Vars(r)
+	language, ok := twoLetterLanguage(vars["language"])
+	if !ok {
+		apierror(w, "Unknown language", http.StatusBadRequest)
+		return
+	}
+	c, query, _, found := j.subtitleQuery(trimPrefix(vars["itemid"]))
+	if !found {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	response := []JFRemoteSubtitleInfo{}
+	if j.subtitleProvider == nil || !subtitleLanguageAllowed(c, language) {
+		serveJSON(response, w)
+		return
+	}
+
+	query.Languages = []string{language}
+	subtitles, err := j.subtitleProvider.SearchSubtitles(r.Context(), query)
+	if err != nil && !errors.Is(err, opensubtitles.ErrNotFound) {
+		apierror(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, s := range subtitles {
+		info := JFRemoteSubtitleInfo{
+			ThreeLetterISOLanguageName: vars["language"],
+			ID:                         s.Language + "-" + s.FileID,
+			ProviderName:               subtitleProviderName,
+			Name:                       s.Name,
+			Format:                     "srt",
+			Author:                     s.Uploader,
+			Comment:                    s.Comment,
+			CommunityRating:            s.Rating,
+			FrameRate:                  s.FPS,
+			DownloadCount:              s.DownloadCount,
+			AiTranslated:               s.AITranslated,
+			MachineTranslated:          s.MachineTranslated,
+			Forced:                     s.ForeignPartsOnly,
+			HearingImpaired:            s.HearingImpaired,
+		}
+		if !s.Uploaded.IsZero() {
+			uploaded := s.Uploaded.UTC()
+			info.DateCreated = &uploaded
+		}
+		response = append(response, info)
+	}
+	serveJSON(response, w)
+}
+
+// POST /Items/{item}/RemoteSearch/Subtitles/{subtitleId}
+//
+// itemsRemoteSearchSubtitlesDownloadHandler downloads a subtitle and stores it next to
+// the video file, e.g. "casablanca.en.srt". An existing subtitle in the same language is not
+// overwritten. The subtitle can be served right away, it is listed with the item after the
+// next collection scan.
+func (j *Jellyfin) itemsRemoteSearchSubtitlesDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can download subtitles", http.StatusForbidden)
+		return
+	}
+	if j.subtitleProvider == nil {
+		apierror(w, "No subtitle provider configured", http.StatusNotFound)
+		return
+	}
+	vars := mux.Vars(r)
+	// Subtitle IDs are "<language>-<fileid>", as returned by search
+	language, fileID, found := strings.Cut(vars["subtitleid"], "-")
+	if !found {
+		apierror(w, "Invalid subtitle id", http.StatusBadRequest)
+		return
+	}
+	language, ok := twoLetterLanguage(language)
+	if !ok {
+		apierror(w, "Unknown language", http.StatusBadRequest)
+		return
+	}
+	c, _, videoFile, found := j.subtitleQuery(trimPrefix(vars["itemid"]))
+	if !found {
+		apierror(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if !subtitleLanguageAllowed(c, language) {
+		apierror(w, "Subtitle language not enabled for this library", http.StatusForbidden)
+		return
+	}
+
+	// Do not overwrite subtitles that are already there, they might have been hand-picked
+	filename := strings.TrimSuffix(videoFile, path.Ext(videoFile)) + "." + language + ".srt"
+	if _, err := os.Stat(filename); err == nil {
+		apierror(w, "Subtitle in this language already exists", http.StatusConflict)
+		return
+	}
+
+	data, err := j.subtitleProvider.DownloadSubtitle(r.Context(), fileID)
+	if errors.Is(err, opensubtitles.ErrNotFound) {
+		apierror(w, "Subtitle not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := writeFileAtomic(filename, data); err != nil {
+		log.Printf("Cannot store subtitle %s: %s", filename, err)
+		apierror(w, "Cannot store subtitle next to media", http.StatusInternalServerError)
+		return
+	}
+	j.collections.RegisterFile(filename)
+	log.Printf("Downloaded subtitle %s", filename)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// subtitleQuery returns the collection, subtitle search query and video file of a movie or episode.
+func (j *Jellyfin) subtitleQuery(itemID string) (*collection.Collection, opensubtitles.Query, string, bool) {
+	c, i := j.collections.GetItemByID(itemID)
+	if movie, ok := i.(*collection.Movie); ok && movie.FileName() != "" {
+		query := opensubtitles.Query{
+			ProviderIDs: movie.Metadata.ProviderIDs(),
+			Title:       movie.Metadata.Title(),
+		}
+		return c, query, path.Join(c.ItemDirectory(movie), movie.FileName()), true
+	}
+	if c, show, _, episode := j.collections.GetEpisodeByID(itemID); episode != nil && episode.FileName() != "" {
+		query := opensubtitles.Query{
+			ProviderIDs:       episode.Metadata.ProviderIDs(),
+			ParentProviderIDs: show.Metadata.ProviderIDs(),
+			Title:             show.Metadata.Title(),
+			SeasonNumber:      episode.SeasonNo,
+			EpisodeNumber:     episode.EpisodeNo,
+		}
+		return c, query, path.Join(c.ItemDirectory(episode), episode.FileName()), true
+	}
+	return nil, opensubtitles.Query{}, "", false
+}
+
+// subtitleLanguageAllowed returns true if subtitles can be downloaded in a language for a collection.
+func subtitleLanguageAllowed(c *collection.Collection, language string) bool {
+	if len(c.SubtitleLanguages) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(c.SubtitleLanguages, func(l string) bool {
+		code, ok := twoLetterLanguage(l)
+		return ok && code == language
+	})
+}
+
+// twoLetterLanguage returns the ISO 639-1 code of a two or three letter language code.
+func twoLetterLanguage(language string) (string, bool) {
+	language = strings.ToLower(language)
+	if len(language) == 2 && strings.Trim(language, "abcdefghijklmnopqrstuvwxyz") == "" {
+		return language, true
+	}
+	code, ok := languageCodes[language]
+	return code, ok
+}
+
+// writeFileAtomic writes a file using a temporary file, so a partial file is never visible.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := os.CreateTemp(path.Dir(filename), ".jellofin-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package jellyfin
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+const (
+	// suggestionDefaultLimit is the number of suggestions returned in case no limit is provided.
+	suggestionDefaultLimit = 20
+	// suggestionRecentWatches is the number of most recently watched items to find similar items for.
+	suggestionRecentWatches = 10
+	// suggestionSimilarCount is the number of similar items looked up per recently watched item.
+	suggestionSimilarCount = 20
+	// suggestionRecentlyAdded is the period in which items are considered recently added.
+	suggestionRecentlyAdded = 30 * 24 * time.Hour
+)
+
+// suggestion is a movie or show that can be suggested to the user, with its score.
+type suggestion struct {
+	c     *collection.Collection
+	item  collection.Item
+	score float64
+}
+
+// /Items/Suggestions
+// /Users/{user}/Items/Suggestions
+//
+// Supported query params:
+// - type, comma separated list of item types to return, "Movie" and/or "Series", defaults to both
+// - startIndex, index of the first suggestion to return
+// - limit, number of suggestions to return, defaults to 20
+//
+// usersItemsSuggestionsHandler returns movies and shows the user has not watched yet, best suggestion first.
+// Suggestions are based on the genres the user watches and likes, similarity to recently watched
+// items with preference for recently added items, and people the user marked as favorite.
+// Items with the same score are ordered by ID so paging is stable.
+func (j *Jellyfin) usersItemsSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+
+	queryparams := r.URL.Query()
+	includeMovies, includeShows := true, true
+	if types := queryparams.Get("type"); types != "" {
+		includeMovies = slices.Contains(strings.Split(types, ","), itemTypeMovie)
+		includeShows = slices.Contains(strings.Split(types, ","), itemTypeShow)
+	}
+
+	suggestions, err := j.makeSuggestions(r.Context(), reqCtx.User, includeMovies, includeShows)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	startIndex, err := strconv.Atoi(queryparams.Get("startIndex"))
+	if err != nil || startIndex < 0 {
+		startIndex = 0
+	}
+	limit, err := strconv.Atoi(queryparams.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = suggestionDefaultLimit
+	}
+	page := suggestions[min(startIndex, len(suggestions)):min(startIndex+limit, len(suggestions))]
+
+	items := make([]JFItem, 0, len(page))
+	for _, s := range page {
+		if jfitem, err := j.makeJFItem(r.Context(), reqCtx.User.ID, s.item, s.c.ID); err == nil {
+			items = append(items, jfitem)
+		}
+	}
+	response := JFUsersItemsSuggestionsResponse{
+		Items:            items,
+		StartIndex:       startIndex,
+		TotalRecordCount: len(suggestions),
+	}
+	serveJSON(response, w)
+}
+
+// makeSuggestions returns all movies and shows the user has not watched, favorited or disliked, ordered by score.
+func (j *Jellyfin) makeSuggestions(ctx context.Context, user *model.User, includeMovies, includeShows bool) ([]suggestion, error) {
+	watchedIDs, err := j.repo.GetRecentlyWatched(ctx, user.ID, recommendationHistoryCount, true)
+	if err != nil {
+		return nil, err
+	}
+	favoriteIDs, _ := j.repo.GetFavorites(ctx, user.ID)
+	likedIDs, dislikedIDs, _ := j.repo.GetLikes(ctx, user.ID)
+
+	// Watched items are mapped to their movie or show, shows with a watched episode are not suggested
+	var watched []collection.Item
+	seen := make(map[string]bool)
+	for _, id := range watchedIDs {
+		// Likes, ratings and favorites also create user data, only count items actually played
+		if userData, err := j.repo.GetUserData(ctx, user.ID, id); err != nil || (!userData.Played && userData.Position == 0) {
+			continue
+		}
+		if _, item := j.suggestionBaseItem(id); item != nil && !seen[item.ID()] {
+			seen[item.ID()] = true
+			watched = append(watched, item)
+		}
+	}
+	for _, id := range slices.Concat(favoriteIDs, dislikedIDs) {
+		seen[id] = true
+	}
+
+	// Genre preference of the user, favorites and likes count double
+	genreWeight := make(map[string]float64)
+	for _, item := range watched {
+		for _, g := range suggestionGenres(item) {
+			genreWeight[g]++
+		}
+	}
+	for _, id := range slices.Concat(favoriteIDs, likedIDs) {
+		if _, item := j.suggestionBaseItem(id); item != nil {
+			for _, g := range suggestionGenres(item) {
+				genreWeight[g] += 2
+			}
+		}
+	}
+	var maxGenreWeight float64
+	for _, weight := range genreWeight {
+		maxGenreWeight = max(maxGenreWeight, weight)
+	}
+
+	// Similarity to recently watched items, best matches score highest
+	similarScore := make(map[string]float64)
+	for _, item := range watched[:min(len(watched), suggestionRecentWatches)] {
+		c, _ := j.collections.GetItemByID(item.ID())
+		similarIDs, err := j.collections.Similar(ctx, c, item, suggestionSimilarCount)
+		if err != nil {
+			continue
+		}
+		for rank, id := range similarIDs {
+			similarScore[id] += 1 - float64(rank)/float64(len(similarIDs))
+		}
+	}
+
+	// People are stored as favorite by their id without prefix
+	favoritePeople := make(map[string]bool)
+	for _, id := range favoriteIDs {
+		favoritePeople[id] = true
+	}
+
+	var suggestions []suggestion
+	for _, coll := range j.collections.GetCollections() {
+		c := j.collections.GetCollection(coll.ID)
+		for _, item := range c.Items {
+			var m suggestionMetadata
+			var added time.Time
+			switch i := item.(type) {
+			case *collection.Movie:
+				if !includeMovies {
+					continue
+				}
+				m, added = i, i.Created()
+			case *collection.Show:
+				if !includeShows {
+					continue
+				}
+				m, added = i, i.LastVideo()
+			default:
+				continue
+			}
+			if seen[item.ID()] {
+				continue
+			}
+			if !j.parentalRatingAllowed(m.OfficialRating(), user) || j.itemHidden(item.ID(), user) {
+				continue
+			}
+
+			rating := float64(m.Rating()) / 10
+			// Highly rated items in favorite genres
+			var genreScore float64
+			if maxGenreWeight > 0 {
+				for _, g := range m.Genres() {
+					genreScore = max(genreScore, genreWeight[g]/maxGenreWeight)
+				}
+			}
+			score := 2 * genreScore * rating
+			// Similar to recent watches, recently added items count fully
+			if similarity := similarScore[item.ID()]; similarity > 0 {
+				if time.Since(added) > suggestionRecentlyAdded {
+					similarity /= 4
+				}
+				score += 2 * similarity
+			}
+			// Favorite actors, directors and writers
+			for _, name := range suggestionPeople(m) {
+				if favoritePeople[trimPrefix(makeJFPersonID(name))] {
+					score++
+				}
+			}
+			// Rating alone orders items without any other signal
+			score += rating / 10
+
+			suggestions = append(suggestions, suggestion{c: c, item: item, score: score})
+		}
+	}
+	sort.Slice(suggestions, func(a, b int) bool {
+		if suggestions[a].score != suggestions[b].score {
+			return suggestions[a].score > suggestions[b].score
+		}
+		return suggestions[a].item.ID() < suggestions[b].item.ID()
+	})
+	return suggestions, nil
+}
+
+// suggestionMetadata is the metadata of movies and shows used to score suggestions.
+type suggestionMetadata interface {
+	Genres() []string
+	Actors() map[string]string
+	Directors() []string
+	Writers() []string
+	Rating() float32
+	OfficialRating() string
+}
+
+// suggestionBaseItem returns the movie or show of an item ID, episodes and seasons return their show.
+func (j *Jellyfin) suggestionBaseItem(itemID string) (*collection.Collection, collection.Item) {
+	if c, show, _, _ := j.collections.GetEpisodeByID(itemID); show != nil {
+		return c, show
+	}
+	if c, show, _ := j.collections.GetSeasonByID(itemID); show != nil {
+		return c, show
+	}
+	c, item := j.collections.GetItemByID(itemID)
+	switch item.(type) {
+	case *collection.Movie, *collection.Show:
+		return c, item
+	}
+	return nil, nil
+}
+
+// suggestionGenres returns the genres of a movie or show.
+func suggestionGenres(item collection.Item) []string {
+	if m, ok := item.(suggestionMetadata); ok {
+		return m.Genres()
+	}
+	return nil
+}
+
+// suggestionPeople returns the names of actors, directors and writers of a movie or show.
+func suggestionPeople(m suggestionMetadata) []string {
+	var people []string
+	for name := range m.Actors() {
+		people = append(people, name)
+	}
+	return slices.Concat(people, m.Directors(), m.Writers())
+}
@@ -1,18 +1,27 @@
 package jellyfin
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 const (
 	serverVersion = "10.11.6"
+	// maximum size of a posted server configuration
+	maxConfigurationSize = 1024 * 1024
 )
 
 // /health
@@ -59,28 +68,30 @@ func (j *Jellyfin) systemEndpointHandler(w http.ResponseWriter, r *http.Request)
 //
 // systemInfoHandler returns server info
 func (j *Jellyfin) systemInfoHandler(w http.ResponseWriter, r *http.Request) {
+	port := j.localPort(r)
 	response := JFSystemInfoResponse{
 		Id:                         j.serverID,
 		HasPendingRestart:          false,
 		IsShuttingDown:             false,
 		SupportsLibraryMonitor:     true,
-		WebSocketPortNumber:        8096,
+		WebSocketPortNumber:        port,
 		CompletedInstallations:     []string{},
 		CanSelfRestart:             true,
 		CanLaunchWebBrowser:        false,
-		ProgramDataPath:            "/jellyfin",
-		WebPath:                    "/jellyfin/web",
-		ItemsByNamePath:            "/jellyfin/metadata",
-		CachePath:                  "/jellyfin/cache",
-		LogPath:                    "/jellyfin/log",
-		InternalMetadataPath:       "/jellyfin/metadata",
-		TranscodingTempPath:        "/jellyfin/cache/transcodes",
+		ProgramDataPath:            j.paths.ProgramData,
+		WebPath:                    j.paths.Web,
+		ItemsByNamePath:            j.paths.Metadata,
+		CachePath:                  j.paths.Cache,
+		LogPath:                    j.paths.Log,
+		InternalMetadataPath:       j.paths.Metadata,
+		TranscodingTempPath:        j.paths.Cache,
 		EncoderLocation:            "System",
 		HasUpdateAvailable:         false,
 		LocalAddress:               localAddress(r),
+		WanAddress:                 j.wanAddress(r),
 		OperatingSystem:            runtime.GOOS,
-		OperatingSystemDisplayName: runtime.GOOS,
-		ServerName:                 j.serverName,
+		OperatingSystemDisplayName: operatingSystemName(),
+		ServerName:                 j.getServerName(),
 		SystemArchitecture:         runtime.GOARCH,
 		Version:                    serverVersion,
 		CastReceiverApplications: []CastReceiverApplication{
@@ -114,7 +125,7 @@ func (j *Jellyfin) systemInfoPublicHandler(w http.ResponseWriter, r *http.Reques
 		// Jellyfin ios native client checks for exact productname so we have to return the same name..
 		// https://github.com/jellyfin/jellyfin-expo/blob/7dedbc72fb53fc4b83c3967c9a8c6c071916425b/utils/ServerValidator.js#L82C49-L82C64
 		ProductName:            "Jellyfin Server",
-		ServerName:             j.serverName,
+		ServerName:             j.getServerName(),
 		Version:                serverVersion,
 		StartupWizardCompleted: true,
 	}
@@ -128,6 +139,202 @@ func (j *Jellyfin) systemPingHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("\"Jellyfin Server\""))
 }
 
+// GET /System/Configuration
+// GET /System/Configuration/network
+//
+// systemConfigurationGetHandler returns the server configuration, or a named configuration
+// such as "network". Settings saved by the admin dashboard override the defaults.
+func (j *Jellyfin) systemConfigurationGetHandler(w http.ResponseWriter, r *http.Request) {
+	key := serverConfigurationKey(mux.Vars(r)["key"])
+	config := map[string]any{}
+	switch key {
+	case serverConfigurationKey(""):
+		config = j.defaultServerConfiguration()
+	case serverConfigurationKey("branding"):
+		// Branding can be stored in dashboard, and is also set in config file
+		data, _ := json.Marshal(j.brandingConfiguration(r.Context()))
+		json.Unmarshal(data, &config)
+	}
+	if stored, err := j.repo.GetSetting(r.Context(), key); err == nil {
+		if err := json.Unmarshal([]byte(stored), &config); err != nil {
+			log.Printf("Ignoring invalid stored configuration %s: %s", key, err)
+		}
+	}
+	serveJSON(config, w)
+}
+
+// POST /System/Configuration
+// POST /System/Configuration/network
+//
+// systemConfigurationPostHandler stores the server configuration, or a named configuration.
+func (j *Jellyfin) systemConfigurationPostHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can change the server configuration", http.StatusForbidden)
+		return
+	}
+
+	var config map[string]any
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigurationSize)).Decode(&config); err != nil {
+		apierror(w, "Invalid configuration", http.StatusBadRequest)
+		return
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key := serverConfigurationKey(mux.Vars(r)["key"])
+	if err := j.repo.UpsertSetting(r.Context(), key, string(data)); err != nil {
+		apierror(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if key == serverConfigurationKey("") {
+		j.applyServerConfiguration(config)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /System/Configuration/Reload
+//
+// systemConfigurationReloadHandler reloads the config file and applies the settings
+// that can be changed at runtime.
+func (j *Jellyfin) systemConfigurationReloadHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can reload the configuration", http.StatusForbidden)
+		return
+	}
+	if j.reloadConfig == nil {
+		apierror(w, "Configuration reload not supported", http.StatusNotImplemented)
+		return
+	}
+	if err := j.reloadConfig(); err != nil {
+		apierror(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// defaultServerConfiguration returns the server configuration based upon the config file.
+func (j *Jellyfin) defaultServerConfiguration() map[string]any {
+	resume := j.resumeThresholds(nil)
+	config := JFServerConfiguration{
+		ServerName:                j.getServerName(),
+		UICulture:                 "en-US",
+		PreferredMetadataLanguage: "en",
+		MetadataCountryCode:       "US",
+		CachePath:                 j.paths.Cache,
+		MetadataPath:              j.paths.Metadata,
+		IsStartupWizardCompleted:  true,
+		QuickConnectAvailable:     j.quickConnectEnabled,
+		LogFileRetentionDays:      3,
+		ActivityLogRetentionDays:  30,
+		MinResumePct:              resume.MinResumePercentage,
+		MaxResumePct:              resume.MaxResumePercentage,
+		MinResumeDurationSeconds:  resume.MinResumeDurationSeconds,
+		LibraryMonitorDelay:       60,
+		SlowResponseThresholdMs:   500,
+		CorsHosts:                 j.cors.AllowedOrigins,
+		SortRemoveCharacters:      []string{",", "&", "-", "{", "}", "'"},
+		SortReplaceCharacters:     []string{".", "+", "%"},
+		SortRemoveWords:           []string{"the", "a", "an"},
+		ContentTypes:              []string{},
+		PluginRepositories:        []string{},
+	}
+	if config.CorsHosts == nil {
+		config.CorsHosts = []string{"*"}
+	}
+	// Convert to map so stored settings, including ones we do not know about, can be merged
+	result := map[string]any{}
+	data, _ := json.Marshal(config)
+	json.Unmarshal(data, &result)
+	return result
+}
+
+// loadServerConfiguration applies the stored server configuration.
+func (j *Jellyfin) loadServerConfiguration(ctx context.Context) {
+	if j.repo == nil {
+		return
+	}
+	stored, err := j.repo.GetSetting(ctx, serverConfigurationKey(""))
+	if err != nil {
+		return
+	}
+	var config map[string]any
+	if err := json.Unmarshal([]byte(stored), &config); err != nil {
+		log.Printf("Ignoring invalid stored server configuration: %s", err)
+		return
+	}
+	j.applyServerConfiguration(config)
+}
+
+// applyServerConfiguration applies the settings of the server configuration that we support.
+func (j *Jellyfin) applyServerConfiguration(config map[string]any) {
+	if name, ok := config["ServerName"].(string); ok && strings.TrimSpace(name) != "" {
+		j.serverNameMu.Lock()
+		j.serverName = strings.TrimSpace(name)
+		j.serverNameMu.Unlock()
+	}
+	// JSON numbers are decoded as float64
+	intSetting := func(key string) int {
+		if v, ok := config[key].(float64); ok {
+			return int(v)
+		}
+		return 0
+	}
+	j.resumeMu.Lock()
+	j.resume = Resume{
+		MinResumePercentage:      intSetting("MinResumePct"),
+		MaxResumePercentage:      intSetting("MaxResumePct"),
+		MinResumeDurationSeconds: intSetting("MinResumeDurationSeconds"),
+	}.withDefaults(j.resumeConfig)
+	j.resumeMu.Unlock()
+}
+
+// ServerID returns the unique ID of the server.
+func (j *Jellyfin) ServerID() string {
+	return j.serverID
+}
+
+// ServerName returns the name of the server.
+func (j *Jellyfin) ServerName() string {
+	return j.getServerName()
+}
+
+// getServerName returns the name of the server.
+func (j *Jellyfin) getServerName() string {
+	j.serverNameMu.RLock()
+	defer j.serverNameMu.RUnlock()
+	return j.serverName
+}
+
+// serverConfigurationKey returns the settings key of the server configuration or a named configuration.
+func serverConfigurationKey(name string) string {
+	if name == "" {
+		return "system.configuration"
+	}
+	return "system.configuration." + strings.ToLower(name)
+}
+
+// operatingSystemName returns the name of the operating system, e.g. "Debian GNU/Linux 12 (bookworm)".
+func operatingSystemName() string {
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for line := range strings.SplitSeq(string(data), "\n") {
+			if name, found := strings.CutPrefix(line, "PRETTY_NAME="); found {
+				return strings.Trim(name, `"`)
+			}
+		}
+	}
+	return runtime.GOOS
+}
+
 // /System/Logs
 //
 // systemLogsHandler returns empty log list, we do not support logs at the moment
@@ -146,7 +353,7 @@ func (j *Jellyfin) systemRestartHandler(w http.ResponseWriter, r *http.Request)
 
 // GET /ScheduledTasks
 //
-// scheduledTasksHandler returns empty scheduled task list, we do not support scheduled tasks at the moment
+// scheduledTasksHandler returns the scheduled task list
 func (j *Jellyfin) scheduledTasksHandler(w http.ResponseWriter, r *http.Request) {
 	response := []JFScheduledTasksResponse{
 		{
@@ -162,10 +369,53 @@ func (j *Jellyfin) scheduledTasksHandler(w http.ResponseWriter, r *http.Request)
 				ID:           "3a025083141d3c17dd96d5f9b951287b",
 			},
 		},
+		j.imageTaskResponse(),
+		j.personTaskResponse(),
+		j.metadataTaskResponse(),
+	}
+	if j.introDetection {
+		response = append(response, j.introTaskResponse())
 	}
 	serveJSON(response, w)
 }
 
+// POST /ScheduledTasks/Running/{taskid}
+//
+// scheduledTasksStartHandler starts a scheduled task
+func (j *Jellyfin) scheduledTasksStartHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if !reqCtx.User.Properties.Admin {
+		apierror(w, "Only administrators can start scheduled tasks", http.StatusForbidden)
+		return
+	}
+	var started bool
+	switch mux.Vars(r)["taskid"] {
+	case imagePregenerateTaskID:
+		started = j.triggerImagePregenerate()
+	case personRefreshTaskID:
+		started = j.triggerPersonRefresh()
+	case metadataRefreshTaskID:
+		started = j.triggerMetadataRefresh()
+	case introDetectionTaskID:
+		if !j.introDetection {
+			apierror(w, "Intro detection is not enabled", http.StatusNotFound)
+			return
+		}
+		started = j.triggerIntroDetection()
+	default:
+		apierror(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !started {
+		apierror(w, "Task is already running", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // /Playback/BitrateTest?size=500000
 //
 // playbackBitrateTestHandler returns random data of requested size for bitrate testing
@@ -186,10 +436,47 @@ func (j *Jellyfin) playbackBitrateTestHandler(w http.ResponseWriter, r *http.Req
 	io.CopyN(w, rand.Reader, size)
 }
 
+// localAddress returns the URL of the server on the interface the request was received on,
+// e.g. "http://192.168.1.10:8096" or "http://[fd00::10]:8096".
 func localAddress(r *http.Request) string {
 	protocol := "http"
 	if r.TLS != nil {
 		protocol = "https"
 	}
-	return fmt.Sprintf("%s://%s", protocol, r.Host)
+	host := r.Host
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok {
+		ip := addr.IP
+		// Report IPv4 addresses that arrived on a dual-stack socket as IPv4
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		host = net.JoinHostPort(ip.String(), strconv.Itoa(addr.Port))
+	}
+	return fmt.Sprintf("%s://%s", protocol, host)
+}
+
+// wanAddress returns the URL of the server on the internet, e.g. "http://203.0.113.5:8096",
+// empty if the router has not forwarded a port to the server.
+func (j *Jellyfin) wanAddress(r *http.Request) string {
+	if j.externalAddress == nil {
+		return ""
+	}
+	address := j.externalAddress()
+	if address == "" {
+		return ""
+	}
+	protocol := "http"
+	if r.TLS != nil {
+		protocol = "https"
+	}
+	return fmt.Sprintf("%s://%s", protocol, address)
+}
+
+// localPort returns the port the request was received on.
+func (j *Jellyfin) localPort(r *http.Request) int {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	port, _ := strconv.Atoi(j.serverPort)
+	return port
 }
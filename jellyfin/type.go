@@ -38,12 +38,98 @@ type JFSystemInfoResponse struct {
 	EncoderLocation            string                    `json:"EncoderLocation"`
 	SystemArchitecture         string                    `json:"SystemArchitecture"`
 	LocalAddress               string                    `json:"LocalAddress"`
+	WanAddress                 string                    `json:"WanAddress,omitempty"`
 	ServerName                 string                    `json:"ServerName"`
 	Version                    string                    `json:"Version"`
 	OperatingSystem            string                    `json:"OperatingSystem"`
 	Id                         string                    `json:"Id"`
 }
 
+// JFServerConfiguration holds the server settings shown in the admin dashboard.
+type JFServerConfiguration struct {
+	ServerName                   string   `json:"ServerName"`
+	UICulture                    string   `json:"UICulture"`
+	PreferredMetadataLanguage    string   `json:"PreferredMetadataLanguage"`
+	MetadataCountryCode          string   `json:"MetadataCountryCode"`
+	CachePath                    string   `json:"CachePath"`
+	MetadataPath                 string   `json:"MetadataPath"`
+	IsStartupWizardCompleted     bool     `json:"IsStartupWizardCompleted"`
+	QuickConnectAvailable        bool     `json:"QuickConnectAvailable"`
+	EnableMetrics                bool     `json:"EnableMetrics"`
+	LogFileRetentionDays         int      `json:"LogFileRetentionDays"`
+	ActivityLogRetentionDays     int      `json:"ActivityLogRetentionDays"`
+	MinResumePct                 int      `json:"MinResumePct"`
+	MaxResumePct                 int      `json:"MaxResumePct"`
+	MinResumeDurationSeconds     int      `json:"MinResumeDurationSeconds"`
+	LibraryMonitorDelay          int      `json:"LibraryMonitorDelay"`
+	RemoteClientBitrateLimit     int      `json:"RemoteClientBitrateLimit"`
+	EnableFolderView             bool     `json:"EnableFolderView"`
+	DisplaySpecialsWithinSeasons bool     `json:"DisplaySpecialsWithinSeasons"`
+	EnableSlowResponseWarning    bool     `json:"EnableSlowResponseWarning"`
+	SlowResponseThresholdMs      int      `json:"SlowResponseThresholdMs"`
+	CorsHosts                    []string `json:"CorsHosts"`
+	SortRemoveCharacters         []string `json:"SortRemoveCharacters"`
+	SortReplaceCharacters        []string `json:"SortReplaceCharacters"`
+	SortRemoveWords              []string `json:"SortRemoveWords"`
+	ContentTypes                 []string `json:"ContentTypes"`
+	PluginRepositories           []string `json:"PluginRepositories"`
+}
+
+type JFActivityLogEntryResponse struct {
+	Items            []JFActivityLogEntry `json:"Items"`
+	TotalRecordCount int                  `json:"TotalRecordCount"`
+	StartIndex       int                  `json:"StartIndex"`
+}
+
+type JFActivityLogEntry struct {
+	Id            int64     `json:"Id"`
+	Name          string    `json:"Name"`
+	Overview      string    `json:"Overview,omitempty"`
+	ShortOverview string    `json:"ShortOverview,omitempty"`
+	Type          string    `json:"Type"`
+	ItemId        string    `json:"ItemId,omitempty"`
+	Date          time.Time `json:"Date"`
+	UserId        string    `json:"UserId"`
+	Severity      string    `json:"Severity"`
+}
+
+type JFPlaybackHistoryResponse struct {
+	Items            []JFPlaybackHistoryEntry `json:"Items"`
+	TotalRecordCount int                      `json:"TotalRecordCount"`
+	StartIndex       int                      `json:"StartIndex"`
+}
+
+// JFPlaybackHistoryEntry is a playback of an item by a user, not part of the Jellyfin API.
+type JFPlaybackHistoryEntry struct {
+	ID                  int64     `json:"Id"`
+	UserID              string    `json:"UserId"`
+	ItemID              string    `json:"ItemId"`
+	ItemName            string    `json:"ItemName"`
+	DeviceName          string    `json:"DeviceName"`
+	Client              string    `json:"Client"`
+	StartDate           time.Time `json:"StartDate"`
+	EndDate             time.Time `json:"EndDate"`
+	PlayDurationSeconds int64     `json:"PlayDurationSeconds"`
+	PositionTicks       int64     `json:"PositionTicks"`
+}
+
+type JFPlaybackHistorySummaryResponse struct {
+	Items            []JFPlaybackHistorySummary `json:"Items"`
+	TotalRecordCount int                        `json:"TotalRecordCount"`
+	StartIndex       int                        `json:"StartIndex"`
+}
+
+// JFPlaybackHistorySummary is how often and how long a user played an item, not part of the Jellyfin API.
+type JFPlaybackHistorySummary struct {
+	UserID              string    `json:"UserId"`
+	UserName            string    `json:"UserName"`
+	ItemID              string    `json:"ItemId"`
+	ItemName            string    `json:"ItemName"`
+	PlayCount           int       `json:"PlayCount"`
+	PlayDurationSeconds int64     `json:"PlayDurationSeconds"`
+	LastPlayedDate      time.Time `json:"LastPlayedDate"`
+}
+
 type CastReceiverApplication struct {
 	Id   string `json:"Id"`
 	Name string `json:"Name"`
@@ -60,6 +146,23 @@ type JFPluginResponse struct {
 	Status                string `json:"Status"`
 }
 
+type JFLiveTvInfo struct {
+	Services     []JFLiveTvServiceInfo `json:"Services"`
+	IsEnabled    bool                  `json:"IsEnabled"`
+	EnabledUsers []string              `json:"EnabledUsers"`
+}
+
+type JFLiveTvServiceInfo struct {
+	Name               string   `json:"Name"`
+	HomePageUrl        string   `json:"HomePageUrl,omitempty"`
+	Status             string   `json:"Status"`
+	StatusMessage      string   `json:"StatusMessage,omitempty"`
+	Version            string   `json:"Version,omitempty"`
+	HasUpdateAvailable bool     `json:"HasUpdateAvailable"`
+	IsVisible          bool     `json:"IsVisible"`
+	Tuners             []string `json:"Tuners"`
+}
+
 type JFSystemEndpointResponse struct {
 	IsLocal     bool `json:"IsLocal"`
 	IsInNetwork bool `json:"IsInNetwork"`
@@ -87,6 +190,7 @@ type JFUserNewRequest struct {
 type JFUserConfiguration struct {
 	// MyMediaExcludes is a list of collection displayPreference IDs to exclude from the collection overview.
 	// OrderedViews is a list of collection displayPreference IDs indicating in which order to collections should be shown.
+	AudioLanguagePreference   string   `json:"AudioLanguagePreference,omitempty"`
 	CastReceiverId            string   `json:"CastReceiverId"`
 	DisplayCollectionsView    bool     `json:"DisplayCollectionsView"`
 	DisplayMissingEpisodes    bool     `json:"DisplayMissingEpisodes"`
@@ -98,12 +202,20 @@ type JFUserConfiguration struct {
 	// MyMediaExcludes is a list of collection displayPreference IDs to exclude from the collection overview.
 	MyMediaExcludes []string `json:"MyMediaExcludes"`
 	// OrderedViews is a list of collection displayPreference IDs indicating in which order to collections should be shown.
-	OrderedViews               []string `json:"OrderedViews"`
+	OrderedViews []string `json:"OrderedViews"`
+	// PinnedViews is a list of collection IDs that are shown before all other collections.
+	// Not part of the Jellyfin API, nil leaves the current setting unchanged.
+	PinnedViews                []string `json:"PinnedViews,omitempty"`
 	PlayDefaultAudioTrack      bool     `json:"PlayDefaultAudioTrack"`
 	RememberAudioSelections    bool     `json:"RememberAudioSelections"`
 	RememberSubtitleSelections bool     `json:"RememberSubtitleSelections"`
 	SubtitleLanguagePreference string   `json:"SubtitleLanguagePreference"`
 	SubtitleMode               string   `json:"SubtitleMode"`
+	// MinResumePct, MaxResumePct and MinResumeDurationSeconds override the server resume thresholds,
+	// 0 uses the server setting. Not part of the Jellyfin API, nil leaves the current setting unchanged.
+	MinResumePct             *int `json:"MinResumePct,omitempty"`
+	MaxResumePct             *int `json:"MaxResumePct,omitempty"`
+	MinResumeDurationSeconds *int `json:"MinResumeDurationSeconds,omitempty"`
 }
 
 type JFUserPolicy struct {
@@ -150,12 +262,15 @@ type JFUserPolicy struct {
 	// IsDisabled is true if the user is disabled.
 	IsDisabled bool `json:"IsDisabled"`
 	// IsHidden is true if the user is hidden, /Users/Public does not list hidden users.
-	IsHidden                   bool   `json:"IsHidden"`
-	LoginAttemptsBeforeLockout int    `json:"LoginAttemptsBeforeLockout"`
-	MaxActiveSessions          int    `json:"MaxActiveSessions"`
-	PasswordResetProviderID    string `json:"PasswordResetProviderId"`
-	RemoteClientBitrateLimit   int    `json:"RemoteClientBitrateLimit"`
-	SyncPlayAccess             string `json:"SyncPlayAccess"`
+	IsHidden                   bool `json:"IsHidden"`
+	LoginAttemptsBeforeLockout int  `json:"LoginAttemptsBeforeLockout"`
+	// MaxActiveSessions is the maximum number of playback sessions at the same time, 0 means unlimited.
+	MaxActiveSessions int `json:"MaxActiveSessions"`
+	// MaxParentalRating is the maximum parental rating score the user is allowed to see, nil if unrestricted.
+	MaxParentalRating        *int   `json:"MaxParentalRating,omitempty"`
+	PasswordResetProviderID  string `json:"PasswordResetProviderId"`
+	RemoteClientBitrateLimit int    `json:"RemoteClientBitrateLimit"`
+	SyncPlayAccess           string `json:"SyncPlayAccess"`
 }
 
 type JFUserPasswordRequest struct {
@@ -207,6 +322,21 @@ type JFUsersItemsSimilarResponse struct {
 	StartIndex       int      `json:"StartIndex"`
 }
 
+// JFRecommendation is a category of recommended items.
+type JFRecommendation struct {
+	Items []JFItem `json:"Items"`
+	// RecommendationType is the reason for recommending the items, e.g. "SimilarToRecentlyPlayed".
+	RecommendationType string `json:"RecommendationType"`
+	// BaselineItemName is the name of the item or genre the recommendations are based upon.
+	BaselineItemName string `json:"BaselineItemName"`
+	CategoryID       string `json:"CategoryId"`
+}
+
+const (
+	recommendationTypeSimilarToRecentlyPlayed = "SimilarToRecentlyPlayed"
+	recommendationTypeSimilarToLikedItem      = "SimilarToLikedItem"
+)
+
 type JFUsersItemsSuggestionsResponse struct {
 	Items            []JFItem `json:"Items"`
 	TotalRecordCount int      `json:"TotalRecordCount"`
@@ -264,6 +394,10 @@ type JFItem struct {
 	ServerID                 string             `json:"ServerId"`
 	IndexNumber              int                `json:"IndexNumber,omitempty"`
 	ParentIndexNumber        int                `json:"ParentIndexNumber,omitempty"`
+	AbsoluteEpisodeNumber    int                `json:"AbsoluteEpisodeNumber,omitempty"`
+	AirsBeforeSeasonNumber   int                `json:"AirsBeforeSeasonNumber,omitempty"`
+	AirsBeforeEpisodeNumber  int                `json:"AirsBeforeEpisodeNumber,omitempty"`
+	AirsAfterSeasonNumber    int                `json:"AirsAfterSeasonNumber,omitempty"`
 	Type                     string             `json:"Type,omitempty"`
 	Name                     string             `json:"Name"`
 	SortName                 string             `json:"SortName,omitempty"`
@@ -272,11 +406,12 @@ type JFItem struct {
 	SeasonName               string             `json:"SeasonName,omitempty"`
 	OriginalTitle            string             `json:"OriginalTitle,omitempty"`
 	Etag                     string             `json:"Etag"`
-	DateCreated              time.Time          `json:"DateCreated,omitempty"` // When item was added to the library.
+	DateCreated              time.Time          `json:"DateCreated,omitzero"` // When item was added to the library, left out if unknown.
+	DateLastMediaAdded       *time.Time         `json:"DateLastMediaAdded,omitempty"`
 	CanDelete                bool               `json:"CanDelete"`
 	CanDownload              bool               `json:"CanDownload"`
 	Container                string             `json:"Container,omitempty"`
-	PremiereDate             time.Time          `json:"PremiereDate,omitempty"`
+	PremiereDate             time.Time          `json:"PremiereDate,omitzero"` // Left out if unknown.
 	MediaSources             []JFMediaSources   `json:"MediaSources,omitempty"`
 	CriticRating             int                `json:"CriticRating,omitempty"`
 	ProductionLocations      []string           `json:"ProductionLocations,omitempty"`
@@ -324,6 +459,10 @@ type JFItem struct {
 	Chapters                 []JFChapter        `json:"Chapters,omitempty"`
 	ParentLogoItemId         string             `json:"ParentLogoItemId,omitempty"`
 	RecursiveItemCount       int                `json:"RecursiveItemCount,omitempty"`
+	MovieCount               int                `json:"MovieCount,omitempty"`
+	SeriesCount              int                `json:"SeriesCount,omitempty"`
+	EpisodeCount             int                `json:"EpisodeCount,omitempty"`
+	PartCount                int                `json:"PartCount,omitempty"`
 }
 
 type JFExternalUrls struct {
@@ -473,6 +612,8 @@ type JFUserData struct {
 	PlayedPercentage      int       `json:"PlayedPercentage"`
 	PlayCount             int       `json:"PlayCount"`
 	IsFavorite            bool      `json:"IsFavorite"`
+	Likes                 *bool     `json:"Likes,omitempty"`
+	Rating                *float64  `json:"Rating,omitempty"`
 	LastPlayedDate        time.Time `json:"LastPlayedDate,omitempty"`
 	Played                bool      `json:"Played"`
 	Key                   string    `json:"Key"`
@@ -481,6 +622,22 @@ type JFUserData struct {
 	UnplayedItemCount int    `json:"UnplayedItemCount"`
 }
 
+// JFUserDataUpdate holds the user data fields a client can change, fields not provided are left unchanged.
+type JFUserDataUpdate struct {
+	IsFavorite *bool    `json:"IsFavorite,omitempty"`
+	Likes      *bool    `json:"Likes,omitempty"`
+	Rating     *float64 `json:"Rating,omitempty"`
+}
+
+// JFUserDataSyncItem is a play state update of an item, as sent by clients syncing offline playback.
+type JFUserDataSyncItem struct {
+	ItemID                string    `json:"ItemId"`
+	PlaybackPositionTicks int64     `json:"PlaybackPositionTicks"`
+	Played                bool      `json:"Played"`
+	IsFavorite            *bool     `json:"IsFavorite,omitempty"`
+	LastPlayedDate        time.Time `json:"LastPlayedDate"`
+}
+
 type JFImageTags struct {
 	Primary  string `json:"Primary,omitempty"`
 	Backdrop string `json:"Backdrop,omitempty"`
@@ -535,7 +692,7 @@ type JFPlayBackInfoRequest struct {
 		} `json:"SubtitleProfiles"`
 	} `json:"deviceProfile"`
 	UserID              string `json:"userId"`
-	StartTimeTicks      int    `json:"startTimeTicks"`
+	StartTimeTicks      int64  `json:"startTimeTicks"`
 	AutoOpenLiveStream  bool   `json:"autoOpenLiveStream"`
 	MediaSourceID       string `json:"mediaSourceId"`
 	AudioStreamIndex    int    `json:"audioStreamIndex"`
@@ -545,6 +702,8 @@ type JFPlayBackInfoRequest struct {
 type JFPlaybackInfoResponse struct {
 	MediaSources  []JFMediaSources `json:"MediaSources"`
 	PlaySessionID string           `json:"PlaySessionId"`
+	// ErrorCode is set when playback is not possible, e.g. "RateLimitExceeded"
+	ErrorCode string `json:"ErrorCode,omitempty"`
 }
 
 type JFPathInfo struct {
@@ -667,10 +826,11 @@ type JFItemCountResponse struct {
 }
 
 type JFItemFilterResponse struct {
-	Genres          []string `json:"Genres"`
-	Tags            []string `json:"Tags"`
-	OfficialRatings []string `json:"OfficialRatings"`
-	Years           []int    `json:"Years"`
+	Genres              []string `json:"Genres"`
+	Tags                []string `json:"Tags"`
+	OfficialRatings     []string `json:"OfficialRatings"`
+	Years               []int    `json:"Years"`
+	ProductionLocations []string `json:"ProductionLocations"`
 }
 
 type JFItemFilter2Response struct {
@@ -698,6 +858,7 @@ type JFSessionInfo struct {
 	LastPlaybackCheckIn      time.Time                     `json:"LastPlaybackCheckIn"`
 	DeviceName               string                        `json:"DeviceName"`
 	DeviceID                 string                        `json:"DeviceId"`
+	DeviceType               string                        `json:"DeviceType,omitempty"`
 	ApplicationVersion       string                        `json:"ApplicationVersion"`
 	IsActive                 bool                          `json:"IsActive"`
 	SupportsMediaControl     bool                          `json:"SupportsMediaControl"`
@@ -707,14 +868,19 @@ type JFSessionInfo struct {
 	HasCustomDeviceName      bool                          `json:"HasCustomDeviceName"`
 	ServerID                 string                        `json:"ServerId"`
 	SupportedCommands        []string                      `json:"SupportedCommands"`
+	NowPlayingItem           *JFItem                       `json:"NowPlayingItem,omitempty"`
 }
 
 type JFSessionResponsePlayState struct {
+	PositionTicks int64  `json:"PositionTicks,omitempty"`
 	CanSeek       bool   `json:"CanSeek"`
 	IsPaused      bool   `json:"IsPaused"`
 	IsMuted       bool   `json:"IsMuted"`
+	MediaSourceID string `json:"MediaSourceId,omitempty"`
+	PlayMethod    string `json:"PlayMethod,omitempty"`
 	RepeatMode    string `json:"RepeatMode"`
 	PlaybackOrder string `json:"PlaybackOrder"`
+	PlaySessionID string `json:"PlaySessionId,omitempty"`
 }
 
 type JFSessionResponseCapabilities struct {
@@ -722,6 +888,56 @@ type JFSessionResponseCapabilities struct {
 	SupportedCommands            []string `json:"SupportedCommands"`
 	SupportsMediaControl         bool     `json:"SupportsMediaControl"`
 	SupportsPersistentIdentifier bool     `json:"SupportsPersistentIdentifier"`
+	IconURL                      string   `json:"IconUrl,omitempty"`
+	AppStoreURL                  string   `json:"AppStoreUrl,omitempty"`
+}
+
+// JFPlayRequest is sent to a client to start playback of items.
+type JFPlayRequest struct {
+	ItemIDs            []string `json:"ItemIds"`
+	StartPositionTicks int64    `json:"StartPositionTicks,omitempty"`
+	PlayCommand        string   `json:"PlayCommand"`
+	ControllingUserID  string   `json:"ControllingUserId"`
+}
+
+// JFItemUpdateRequest holds the fields of an item that can be changed in the metadata editor.
+type JFItemUpdateRequest struct {
+	// ForcedSortName overrides the name the item is sorted on, empty removes the override.
+	ForcedSortName string `json:"ForcedSortName"`
+	// LockData locks all fields, nil leaves the locks unchanged.
+	LockData *bool `json:"LockData"`
+	// LockedFields are the fields to lock, e.g. "Name", nil leaves the locks unchanged.
+	LockedFields []string `json:"LockedFields"`
+	// Name, Overview, Genres, Studios, OfficialRating and ProductionLocations are stored as value of locked fields.
+	Name                string      `json:"Name"`
+	Overview            string      `json:"Overview"`
+	Genres              []string    `json:"Genres"`
+	Studios             []JFStudios `json:"Studios"`
+	OfficialRating      string      `json:"OfficialRating"`
+	ProductionLocations []string    `json:"ProductionLocations"`
+}
+
+// JFPlayQueue is the server tracked play queue of a session.
+type JFPlayQueue struct {
+	ItemIDs        []string `json:"ItemIds"`
+	CurrentIndex   int      `json:"CurrentIndex"`
+	NowPlayingItem *JFItem  `json:"NowPlayingItem,omitempty"`
+	RepeatMode     string   `json:"RepeatMode"`
+	PlaybackOrder  string   `json:"PlaybackOrder"`
+}
+
+// JFPlaystateRequest is sent to a client to change playback, e.g. Pause.
+type JFPlaystateRequest struct {
+	Command           string `json:"Command"`
+	SeekPositionTicks int64  `json:"SeekPositionTicks,omitempty"`
+	ControllingUserID string `json:"ControllingUserId"`
+}
+
+// JFGeneralCommand is sent to a client to execute a command, e.g. ToggleMute.
+type JFGeneralCommand struct {
+	Name              string            `json:"Name"`
+	ControllingUserID string            `json:"ControllingUserId"`
+	Arguments         map[string]string `json:"Arguments"`
 }
 
 type JFDeviceInfoResponse struct {
@@ -735,6 +951,8 @@ type JFDeviceItem struct {
 	Name             string                        `json:"Name"`
 	AppName          string                        `json:"AppName"`
 	AppVersion       string                        `json:"AppVersion"`
+	DeviceType       string                        `json:"DeviceType,omitempty"`
+	IconURL          string                        `json:"IconUrl,omitempty"`
 	DateLastActivity time.Time                     `json:"DateLastActivity"`
 	LastUserID       string                        `json:"LastUserId"`
 	LastUserName     string                        `json:"LastUserName"`
@@ -769,6 +987,10 @@ type JFCreatePlaylistResponse struct {
 	Id string `json:"Id"`
 }
 
+type JFCreateBoxSetResponse struct {
+	ID string `json:"Id"`
+}
+
 type JFGetPlaylistResponse struct {
 	OpenAccess bool     `json:"OpenAccess"`
 	Shares     []string `json:"Shares"`
@@ -804,6 +1026,7 @@ type JFResponseItemRemoteImagesImage struct {
 	ProviderName    string  `json:"ProviderName"`
 	RatingType      string  `json:"RatingType"`
 	Type            string  `json:"Type"`
+	ThumbnailURL    string  `json:"ThumbnailUrl,omitempty"`
 	URL             string  `json:"Url"`
 	VoteCount       int     `json:"VoteCount,omitempty"`
 	Width           int     `json:"Width,omitempty"`
@@ -814,6 +1037,69 @@ type JFResponseItemRemoteImagesProviders []struct {
 	SupportedImages []string `json:"SupportedImages"`
 }
 
+type JFRemoteSubtitleInfo struct {
+	ThreeLetterISOLanguageName string     `json:"ThreeLetterISOLanguageName"`
+	ID                         string     `json:"Id"`
+	ProviderName               string     `json:"ProviderName"`
+	Name                       string     `json:"Name"`
+	Format                     string     `json:"Format"`
+	Author                     string     `json:"Author,omitempty"`
+	Comment                    string     `json:"Comment,omitempty"`
+	DateCreated                *time.Time `json:"DateCreated,omitempty"`
+	CommunityRating            float64    `json:"CommunityRating,omitempty"`
+	FrameRate                  float64    `json:"FrameRate,omitempty"`
+	DownloadCount              int        `json:"DownloadCount"`
+	IsHashMatch                bool       `json:"IsHashMatch"`
+	AiTranslated               bool       `json:"AiTranslated"`
+	MachineTranslated          bool       `json:"MachineTranslated"`
+	Forced                     bool       `json:"Forced"`
+	HearingImpaired            bool       `json:"HearingImpaired"`
+}
+
+type JFRemoteSearchQuery struct {
+	SearchInfo struct {
+		Name        string            `json:"Name"`
+		Year        int               `json:"Year"`
+		ProviderIds map[string]string `json:"ProviderIds"`
+	} `json:"SearchInfo"`
+	ItemID                   string `json:"ItemId"`
+	SearchProviderName       string `json:"SearchProviderName"`
+	IncludeDisabledProviders bool   `json:"IncludeDisabledProviders"`
+}
+
+type JFRemoteSearchResult struct {
+	Name               string            `json:"Name"`
+	ProviderIds        map[string]string `json:"ProviderIds"`
+	ProductionYear     int               `json:"ProductionYear,omitempty"`
+	PremiereDate       *time.Time        `json:"PremiereDate,omitempty"`
+	ImageURL           string            `json:"ImageUrl,omitempty"`
+	SearchProviderName string            `json:"SearchProviderName"`
+	Overview           string            `json:"Overview,omitempty"`
+}
+
+type JFLyrics struct {
+	Metadata JFLyricMetadata `json:"Metadata"`
+	Lyrics   []JFLyricLine   `json:"Lyrics"`
+}
+
+type JFLyricMetadata struct {
+	Artist   string `json:"Artist,omitempty"`
+	Album    string `json:"Album,omitempty"`
+	Title    string `json:"Title,omitempty"`
+	Author   string `json:"Author,omitempty"`
+	Length   *int64 `json:"Length,omitempty"`
+	By       string `json:"By,omitempty"`
+	Offset   *int64 `json:"Offset,omitempty"`
+	Creator  string `json:"Creator,omitempty"`
+	Version  string `json:"Version,omitempty"`
+	IsSynced bool   `json:"IsSynced"`
+}
+
+type JFLyricLine struct {
+	Text  string `json:"Text"`
+	Start *int64 `json:"Start,omitempty"`
+}
+
 type JFScheduledTasksResponse struct {
 	Name                string                           `json:"Name"`
 	State               string                           `json:"State"`
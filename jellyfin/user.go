@@ -195,6 +195,9 @@ func (j *Jellyfin) usersConfigurationHandler(w http.ResponseWriter, r *http.Requ
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 	vars := mux.Vars(r)
 	userID := vars["userid"]
 	// Only allow if requester is an administrator or the user themselves
@@ -264,6 +267,9 @@ func (j *Jellyfin) usersPasswordHandler(w http.ResponseWriter, r *http.Request)
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 	queryparams := r.URL.Query()
 	userID := queryparams.Get("userId")
 	// Only allow if requester is an administrator or the user themselves
@@ -357,14 +363,21 @@ func (j *Jellyfin) makeJFUser(ctx context.Context, user *model.User) JFUser {
 func makeJFUserConfiguration(user *model.User) JFUserConfiguration {
 	return JFUserConfiguration{
 		CastReceiverId:             "F007D354",
+		DisplayMissingEpisodes:     user.Properties.DisplayMissingEpisodes,
 		GroupedFolders:             []string{},
 		LatestItemsExcludes:        []string{},
 		MyMediaExcludes:            user.Properties.MyMediaExcludes,
 		OrderedViews:               user.Properties.OrderedViews,
-		SubtitleMode:               "Default",
-		PlayDefaultAudioTrack:      true,
+		PinnedViews:                user.Properties.PinnedViews,
+		AudioLanguagePreference:    user.Properties.AudioLanguagePreference,
+		SubtitleLanguagePreference: user.Properties.SubtitleLanguagePreference,
+		SubtitleMode:               user.Properties.SubtitleMode,
+		PlayDefaultAudioTrack:      user.Properties.PlayDefaultAudioTrack,
 		RememberAudioSelections:    true,
 		RememberSubtitleSelections: true,
+		MinResumePct:               &user.Properties.MinResumePercentage,
+		MaxResumePct:               &user.Properties.MaxResumePercentage,
+		MinResumeDurationSeconds:   &user.Properties.MinResumeDurationSeconds,
 	}
 }
 
@@ -372,10 +385,33 @@ func makeJFUserConfiguration(user *model.User) JFUserConfiguration {
 func parseJFUserConfiguration(config JFUserConfiguration, props *model.UserProperties) {
 	props.MyMediaExcludes = config.MyMediaExcludes
 	props.OrderedViews = config.OrderedViews
+	if config.PinnedViews != nil {
+		props.PinnedViews = config.PinnedViews
+	}
+	props.DisplayMissingEpisodes = config.DisplayMissingEpisodes
+	props.PlayDefaultAudioTrack = config.PlayDefaultAudioTrack
+	props.AudioLanguagePreference = config.AudioLanguagePreference
+	props.SubtitleLanguagePreference = config.SubtitleLanguagePreference
+	if config.SubtitleMode != "" {
+		props.SubtitleMode = config.SubtitleMode
+	}
+	if config.MinResumePct != nil {
+		props.MinResumePercentage = max(0, min(*config.MinResumePct, 100))
+	}
+	if config.MaxResumePct != nil {
+		props.MaxResumePercentage = max(0, min(*config.MaxResumePct, 100))
+	}
+	if config.MinResumeDurationSeconds != nil {
+		props.MinResumeDurationSeconds = max(0, *config.MinResumeDurationSeconds)
+	}
 }
 
 // makeJFUserPolicy creates a JFUserPolicy from the user properties
 func makeJFUserPolicy(user *model.User) JFUserPolicy {
+	var maxParentalRating *int
+	if user.Properties.MaxParentalRating >= 0 {
+		maxParentalRating = &user.Properties.MaxParentalRating
+	}
 	return JFUserPolicy{
 		AccessSchedules:                  []string{},
 		AllowedTags:                      user.Properties.AllowTags,
@@ -391,6 +427,7 @@ func makeJFUserPolicy(user *model.User) JFUserPolicy {
 		EnableMediaPlayback:              true,
 		EnableRemoteAccess:               true,
 		EnableAllDevices:                 true,
+		EnableAllChannels:                false, // Channels are not supported
 		EnableAllFolders:                 user.Properties.EnableAllFolders,
 		AuthenticationProviderID:         "DefaultAuthenticationProvider",
 		PasswordResetProviderID:          "DefaultPasswordResetProvider",
@@ -398,6 +435,13 @@ func makeJFUserPolicy(user *model.User) JFUserPolicy {
 		IsAdministrator:                  user.Properties.Admin,
 		IsDisabled:                       user.Properties.Disabled,
 		IsHidden:                         user.Properties.IsHidden,
+		MaxParentalRating:                maxParentalRating,
+		MaxActiveSessions:                user.Properties.MaxActiveSessions,
+		RemoteClientBitrateLimit:         user.Properties.RemoteClientBitrateLimit,
+		// The server does not transcode or remux, items are always played directly
+		EnableVideoPlaybackTranscoding: false,
+		EnableAudioPlaybackTranscoding: false,
+		EnablePlaybackRemuxing:         false,
 	}
 }
 
@@ -411,6 +455,12 @@ func parseJFUserPolicy(policy JFUserPolicy, props *model.UserProperties) {
 	props.Admin = policy.IsAdministrator
 	props.Disabled = policy.IsDisabled
 	props.IsHidden = policy.IsHidden
+	props.MaxActiveSessions = max(0, policy.MaxActiveSessions)
+	props.RemoteClientBitrateLimit = max(0, policy.RemoteClientBitrateLimit)
+	props.MaxParentalRating = -1
+	if policy.MaxParentalRating != nil {
+		props.MaxParentalRating = *policy.MaxParentalRating
+	}
 }
 
 // createUser creates a new user in the database
@@ -425,9 +475,12 @@ func (j *Jellyfin) createUser(context context.Context, username, password string
 		Password: string(hashedPassword),
 		Created:  time.Now().UTC(),
 		Properties: model.UserProperties{
-			IsHidden:         true,
-			EnableAllFolders: true,
-			EnableDownloads:  true,
+			IsHidden:              true,
+			EnableAllFolders:      true,
+			EnableDownloads:       true,
+			MaxParentalRating:     -1,
+			PlayDefaultAudioTrack: true,
+			SubtitleMode:          "Default",
 		},
 	}
 	if err = j.repo.UpsertUser(context, modelUser); err != nil {
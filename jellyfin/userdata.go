@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -49,11 +50,14 @@ func (j *Jellyfin) usersPlayedItemsPostHandler(w http.ResponseWriter, r *http.Re
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
 
-	if err := j.userDataUpdate(r.Context(), reqCtx.User.ID, itemID, 0, true); err != nil {
+	if err := j.userDataUpdate(r.Context(), reqCtx.User, itemID, 0, true); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
@@ -69,11 +73,14 @@ func (j *Jellyfin) usersPlayedItemsDeleteHandler(w http.ResponseWriter, r *http.
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
 
-	if err := j.userDataUpdate(r.Context(), reqCtx.User.ID, itemID, 0, false); err != nil {
+	if err := j.userDataUpdate(r.Context(), reqCtx.User, itemID, 0, false); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
@@ -94,10 +101,12 @@ func (j *Jellyfin) sessionsPlayingHandler(w http.ResponseWriter, r *http.Request
 	}
 	// log.Printf("\nsessionsPlayingHandler UserID: %s, ItemId: %s, Progress: %d seconds\n\n",
 	// 	reqCtx.User.ID, request.ItemId, request.PositionTicks/TicsToSeconds)
-	if err := j.userDataUpdate(r.Context(), reqCtx.User.ID, request.ItemId, request.PositionTicks, false); err != nil {
+	if err := j.userDataUpdate(r.Context(), reqCtx.User, request.ItemId, request.PositionTicks, false); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
+	j.updatePlaySession(reqCtx, request, false)
+	j.logPlayback(r.Context(), reqCtx, request.ItemId, false)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -115,10 +124,11 @@ func (j *Jellyfin) sessionsPlayingProgressHandler(w http.ResponseWriter, r *http
 	}
 	// log.Printf("\nsessionsPlayingProgressHandler UserID: %s, ItemId: %s, Progress: %d seconds\n\n",
 	// 	reqCtx.User.ID, request.ItemId, request.PositionTicks/TicsToSeconds)
-	if err := j.userDataUpdate(r.Context(), reqCtx.User.ID, request.ItemId, request.PositionTicks, false); err != nil {
+	if err := j.userDataUpdate(r.Context(), reqCtx.User, request.ItemId, request.PositionTicks, false); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
+	j.updatePlaySession(reqCtx, request, false)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -136,14 +146,26 @@ func (j *Jellyfin) sessionsPlayingStoppedHandler(w http.ResponseWriter, r *http.
 	}
 	// log.Printf("\nsessionsPlayingStoppedHandler UserID: %s, ItemId: %s, Progress: %d seconds, canSeek: %t\n\n",
 	// 	reqCtx.User.ID, request.ItemId, request.PositionTicks/TicsToSeconds, request.CanSeek)
-	if err := j.userDataUpdate(r.Context(), reqCtx.User.ID, request.ItemId, request.PositionTicks, false); err != nil {
+	if err := j.userDataUpdate(r.Context(), reqCtx.User, request.ItemId, request.PositionTicks, false); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
+	j.updatePlaySession(reqCtx, request, true)
+	j.logPlayback(r.Context(), reqCtx, request.ItemId, true)
+	// Progress updates are kept in memory, store the final position right away
+	if err := j.repo.Flush(r.Context()); err != nil {
+		log.Printf("Failed to store play state: %s", err)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (j *Jellyfin) userDataUpdate(ctx context.Context, userID, itemID string, positionTicks int64, markAsWatched bool) (err error) {
+// userDataUpdate stores the playback position of an item, or marks it as played. Play states
+// of guests are not stored.
+func (j *Jellyfin) userDataUpdate(ctx context.Context, user *model.User, itemID string, positionTicks int64, markAsWatched bool) (err error) {
+	if user.Properties.Guest {
+		return nil
+	}
+	userID := user.ID
 	var duration int64
 	if _, item := j.collections.GetItemByID(trimPrefix(itemID)); item != nil {
 		duration = int64(item.Duration().Seconds())
@@ -151,33 +173,80 @@ func (j *Jellyfin) userDataUpdate(ctx context.Context, userID, itemID string, po
 	// log.Printf("userDataUpdate userID: %s, itemID: %s, Progress: %d sec, Duration: %d sec\n",
 	// 	userID, itemID, positionTicks/TicsToSeconds, duration)
 
-	// If we don't have a duration, we assume 1 hour
-	if duration == 0 {
-		duration = 60 * 60
-	}
-
 	playstate, err := j.repo.GetUserData(ctx, userID, trimPrefix(itemID))
 	if err != nil {
 		playstate = &model.UserData{
 			Timestamp: time.Now().UTC(),
 		}
 	}
+	j.resumeThresholds(user).setPlaybackPosition(playstate, duration, positionTicks, markAsWatched)
+
+	if err := j.repo.UpdateUserData(ctx, userID, trimPrefix(itemID), playstate); err != nil {
+		return err
+	}
+	j.notifyUserDataChanged(userID, itemID, playstate)
+	return nil
+}
 
-	position := positionTicks / TicsToSeconds
-	playedPercentage := int(100 * position / duration)
+// POST /UserItems/Sync
+//
+// usersItemsSyncHandler stores a batch of play states, e.g. from a client that played
+// downloaded items while offline. Per item the play state with the most recent
+// timestamp wins. Returns the resulting user data of each item.
+func (j *Jellyfin) usersItemsSyncHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
-	// Mark as watched in case > 98% of the item is played
-	if markAsWatched || playedPercentage >= 98 {
-		playstate.Position = 0
-		playstate.PlayedPercentage = 0
-		playstate.Played = true
-	} else {
-		playstate.Position = position
-		playstate.PlayedPercentage = playedPercentage
-		playstate.Played = false
+	var request []JFUserDataSyncItem
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror(w, ErrInvalidJSONPayload, http.StatusBadRequest)
+		return
 	}
 
-	return j.repo.UpdateUserData(ctx, userID, trimPrefix(itemID), playstate)
+	thresholds := j.resumeThresholds(reqCtx.User)
+	response := make([]JFUserData, 0, len(request))
+	for _, update := range request {
+		if update.ItemID == "" {
+			continue
+		}
+		itemID := trimPrefix(update.ItemID)
+		var duration int64
+		if _, item := j.collections.GetItemByID(itemID); item != nil {
+			duration = int64(item.Duration().Seconds())
+		}
+
+		playstate, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, itemID)
+		if err != nil {
+			playstate = &model.UserData{}
+		}
+		thresholds.setPlaybackPosition(playstate, duration, update.PlaybackPositionTicks, update.Played)
+		if update.IsFavorite != nil {
+			playstate.Favorite = *update.IsFavorite
+		}
+		playstate.Timestamp = update.LastPlayedDate.UTC()
+		if update.LastPlayedDate.IsZero() || update.LastPlayedDate.After(time.Now()) {
+			playstate.Timestamp = time.Now().UTC()
+		}
+
+		if _, err := j.repo.UpdateUserDataIfNewer(r.Context(), reqCtx.User.ID, itemID, playstate); err != nil {
+			apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
+			return
+		}
+		// Return the stored play state, which is newer in case of a conflict
+		if current, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, itemID); err == nil {
+			playstate = current
+		}
+		j.notifyUserDataChanged(reqCtx.User.ID, update.ItemID, playstate)
+		userData := j.makeJFUserData(reqCtx.User.ID, update.ItemID, playstate)
+		userData.ItemID = update.ItemID
+		response = append(response, *userData)
+	}
+	serveJSON(response, w)
 }
 
 // POST /UserFavoriteItems/{item}
@@ -190,6 +259,9 @@ func (j *Jellyfin) userFavoriteItemsPostHandler(w http.ResponseWriter, r *http.R
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
@@ -201,10 +273,11 @@ func (j *Jellyfin) userFavoriteItemsPostHandler(w http.ResponseWriter, r *http.R
 
 	playstate.Favorite = true
 
-	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, itemID, playstate); err != nil {
+	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID), playstate); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
+	j.notifyUserDataChanged(reqCtx.User.ID, itemID, playstate)
 	userData := j.makeJFUserData(reqCtx.User.ID, itemID, playstate)
 	serveJSON(userData, w)
 }
@@ -217,6 +290,9 @@ func (j *Jellyfin) userFavoriteItemsDeleteHandler(w http.ResponseWriter, r *http
 	if reqCtx == nil {
 		return
 	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
 
 	vars := mux.Vars(r)
 	itemID := vars["itemid"]
@@ -228,14 +304,106 @@ func (j *Jellyfin) userFavoriteItemsDeleteHandler(w http.ResponseWriter, r *http
 
 	playstate.Favorite = false
 
-	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, itemID, playstate); err != nil {
+	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID), playstate); err != nil {
 		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
 		return
 	}
+	j.notifyUserDataChanged(reqCtx.User.ID, itemID, playstate)
 	userData := j.makeJFUserData(reqCtx.User.ID, itemID, playstate)
 	serveJSON(userData, w)
 }
 
+// POST /UserItems/{item}/UserData
+//
+// usersItemUserDataPostHandler updates favorite, likes and rating of an item, e.g. {"Rating": 8.5}.
+// A rating of 0 removes the rating.
+func (j *Jellyfin) usersItemUserDataPostHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	var update JFUserDataUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		apierror(w, ErrInvalidJSONPayload, http.StatusBadRequest)
+		return
+	}
+	if update.Rating != nil && (*update.Rating < 0 || *update.Rating > 10) {
+		apierror(w, "Rating must be between 0 and 10", http.StatusBadRequest)
+		return
+	}
+
+	itemID := mux.Vars(r)["itemid"]
+	playstate, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID))
+	if err != nil {
+		playstate = &model.UserData{}
+	}
+	if update.IsFavorite != nil {
+		playstate.Favorite = *update.IsFavorite
+	}
+	if update.Likes != nil {
+		playstate.Likes = update.Likes
+	}
+	if update.Rating != nil {
+		playstate.Rating = *update.Rating
+	}
+	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID), playstate); err != nil {
+		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
+		return
+	}
+	j.notifyUserDataChanged(reqCtx.User.ID, itemID, playstate)
+	serveJSON(j.makeJFUserData(reqCtx.User.ID, itemID, playstate), w)
+}
+
+// POST /UserItems/{item}/Rating
+// POST /Users/{user}/Items/{item}/Rating
+//
+// Supported query params:
+// - likes, true if the user likes the item, false if not
+//
+// userItemRatingPostHandler records whether the user likes an item.
+func (j *Jellyfin) userItemRatingPostHandler(w http.ResponseWriter, r *http.Request) {
+	likes, err := strconv.ParseBool(r.URL.Query().Get("likes"))
+	if err != nil {
+		apierror(w, "likes must be true or false", http.StatusBadRequest)
+		return
+	}
+	j.userItemRatingUpdate(w, r, &likes)
+}
+
+// DELETE /UserItems/{item}/Rating
+// DELETE /Users/{user}/Items/{item}/Rating
+//
+// userItemRatingDeleteHandler removes whether the user likes an item.
+func (j *Jellyfin) userItemRatingDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	j.userItemRatingUpdate(w, r, nil)
+}
+
+// userItemRatingUpdate stores the likes of an item and returns the updated user data.
+func (j *Jellyfin) userItemRatingUpdate(w http.ResponseWriter, r *http.Request, likes *bool) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+	if guestDenied(w, reqCtx.User) {
+		return
+	}
+	itemID := mux.Vars(r)["itemid"]
+	playstate, err := j.repo.GetUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID))
+	if err != nil {
+		playstate = &model.UserData{}
+	}
+	playstate.Likes = likes
+	if err := j.repo.UpdateUserData(r.Context(), reqCtx.User.ID, trimPrefix(itemID), playstate); err != nil {
+		apierror(w, ErrFailedToUpdateUserData, http.StatusInternalServerError)
+		return
+	}
+	j.notifyUserDataChanged(reqCtx.User.ID, itemID, playstate)
+	serveJSON(j.makeJFUserData(reqCtx.User.ID, itemID, playstate), w)
+}
+
 // makeJFUserData creates a JFUserData object, and populates from Userdata if provided
 func (j *Jellyfin) makeJFUserData(userID, itemID string, p *model.UserData) (response *JFUserData) {
 	response = &JFUserData{
@@ -248,6 +416,10 @@ func (j *Jellyfin) makeJFUserData(userID, itemID string, p *model.UserData) (res
 		response.PlaybackPositionTicks = p.Position * TicsToSeconds
 		response.PlayedPercentage = p.PlayedPercentage
 		response.Played = p.Played
+		response.Likes = p.Likes
+		if p.Rating != 0 {
+			response.Rating = &p.Rating
+		}
 	}
 	return
 }
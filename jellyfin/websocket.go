@@ -0,0 +1,356 @@
+package jellyfin
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erikbos/jellofin-server/database/model"
+)
+
+const (
+	// socketKeepAlive is the keep alive interval in seconds we ask clients to use.
+	socketKeepAlive = 60
+	// socketReadTimeout closes connections of clients that stopped sending keep alives.
+	socketReadTimeout = 2 * socketKeepAlive * time.Second
+	// socketWriteTimeout is the maximum time writing a message to a client may take.
+	socketWriteTimeout = 10 * time.Second
+	// socketMaxFrameSize is the maximum size of a frame we accept from a client.
+	socketMaxFrameSize = 64 * 1024
+	// socketSendQueue is the number of messages queued for a client, a client that falls
+	// further behind is disconnected.
+	socketSendQueue = 32
+	// socketAcceptGUID is used to calculate the websocket handshake response, see RFC 6455.
+	socketAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// websocket frame opcodes
+const (
+	opcodeText  = 0x1
+	opcodeClose = 0x8
+	opcodePing  = 0x9
+	opcodePong  = 0xa
+)
+
+// socketConn is a websocket connection of a client.
+type socketConn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	userID   string
+	deviceID string
+	// writeMu serializes frames written by the write loop and the read loop
+	writeMu sync.Mutex
+	// send holds the messages queued for the write loop
+	send chan []byte
+	// done is closed when the connection is unregistered
+	done chan struct{}
+}
+
+// JFSocketMessage is a message exchanged over the websocket connection.
+type JFSocketMessage struct {
+	MessageType string `json:"MessageType"`
+	MessageID   string `json:"MessageId,omitempty"`
+	Data        any    `json:"Data,omitempty"`
+}
+
+// JFUserDataChangeInfo is the payload of a UserDataChanged message.
+type JFUserDataChangeInfo struct {
+	UserID       string       `json:"UserId"`
+	UserDataList []JFUserData `json:"UserDataList"`
+}
+
+// /socket
+//
+// socketHandler upgrades the request to a websocket connection, used to notify
+// clients of changes made by other sessions of the same user.
+func (j *Jellyfin) socketHandler(w http.ResponseWriter, r *http.Request) {
+	reqCtx := j.getRequestCtx(w, r)
+	if reqCtx == nil {
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		apierror(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		log.Printf("socketHandler: hijack failed: %s", err)
+		apierror(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	accept := sha1.Sum([]byte(key + socketAcceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return
+	}
+
+	s := &socketConn{
+		conn:     conn,
+		reader:   rw.Reader,
+		userID:   reqCtx.User.ID,
+		deviceID: reqCtx.Token.DeviceId,
+		send:     make(chan []byte, socketSendQueue),
+		done:     make(chan struct{}),
+	}
+	j.addSocket(s)
+	defer j.removeSocket(s)
+	go s.writeLoop()
+
+	// Ask client to send keep alives so we can detect dead connections
+	s.writeMessage(JFSocketMessage{MessageType: "ForceKeepAlive", MessageID: randomID(), Data: socketKeepAlive})
+	s.readLoop()
+}
+
+// readLoop reads messages from the client until the connection is closed.
+func (s *socketConn) readLoop() {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+		opcode, payload, err := s.readFrame()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("socket of user %s on device %s: %s", s.userID, s.deviceID, err)
+			}
+			return
+		}
+		switch opcode {
+		case opcodeClose:
+			s.writeFrame(opcodeClose, nil)
+			return
+		case opcodePing:
+			s.writeFrame(opcodePong, payload)
+		case opcodeText:
+			var msg JFSocketMessage
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				continue
+			}
+			if msg.MessageType == "KeepAlive" {
+				s.writeMessage(JFSocketMessage{MessageType: "KeepAlive", MessageID: randomID()})
+			}
+		}
+	}
+}
+
+// readFrame reads a single frame sent by the client. Fragmented messages are not
+// supported, as clients only send small messages.
+func (s *socketConn) readFrame() (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(s.reader, header[:]); err != nil {
+		return
+	}
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(s.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(s.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > socketMaxFrameSize {
+		return 0, nil, errors.New("frame too large")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(s.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(s.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// writeFrame writes a single unfragmented frame to the client.
+func (s *socketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// writeMessage sends a JSON encoded message to the client.
+func (s *socketConn) writeMessage(msg JFSocketMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.writeFrame(opcodeText, payload)
+}
+
+// sendMessage queues a JSON encoded message for the client, without waiting for it
+// to be written. Clients that do not keep up with their messages are disconnected.
+func (s *socketConn) sendMessage(msg JFSocketMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case s.send <- payload:
+	case <-s.done:
+	default:
+		log.Printf("socket of user %s on device %s: send queue full, disconnecting", s.userID, s.deviceID)
+		// Closing the connection ends its read loop, which unregisters it
+		s.conn.Close()
+	}
+}
+
+// writeLoop writes queued messages to the client until the connection is unregistered.
+func (s *socketConn) writeLoop() {
+	for {
+		select {
+		case payload := <-s.send:
+			if err := s.writeFrame(opcodeText, payload); err != nil {
+				s.conn.Close()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// addSocket registers a websocket connection.
+func (j *Jellyfin) addSocket(s *socketConn) {
+	j.socketsMu.Lock()
+	defer j.socketsMu.Unlock()
+	if j.sockets == nil {
+		j.sockets = make(map[*socketConn]struct{})
+	}
+	j.sockets[s] = struct{}{}
+}
+
+// removeSocket unregisters and closes a websocket connection.
+func (j *Jellyfin) removeSocket(s *socketConn) {
+	j.socketsMu.Lock()
+	delete(j.sockets, s)
+	j.socketsMu.Unlock()
+	close(s.done)
+	s.conn.Close()
+}
+
+// userSockets returns the websocket connections of a user.
+func (j *Jellyfin) userSockets(userID string) []*socketConn {
+	j.socketsMu.Lock()
+	defer j.socketsMu.Unlock()
+	var sockets []*socketConn
+	for s := range j.sockets {
+		if s.userID == userID {
+			sockets = append(sockets, s)
+		}
+	}
+	return sockets
+}
+
+// deviceSockets returns the websocket connections of all clients.
+func (j *Jellyfin) deviceSockets() []*socketConn {
+	j.socketsMu.Lock()
+	defer j.socketsMu.Unlock()
+	sockets := make([]*socketConn, 0, len(j.sockets))
+	for s := range j.sockets {
+		sockets = append(sockets, s)
+	}
+	return sockets
+}
+
+// deviceConnected returns true if a device has a websocket connection.
+func (j *Jellyfin) deviceConnected(deviceID string) bool {
+	j.socketsMu.Lock()
+	defer j.socketsMu.Unlock()
+	for s := range j.sockets {
+		if s.deviceID == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyUserDataChanged sends the new user data of an item to all connected
+// sessions of the user, so clients can update watched and favorite state immediately.
+func (j *Jellyfin) notifyUserDataChanged(userID, itemID string, playstate *model.UserData) {
+	sockets := j.userSockets(userID)
+	if len(sockets) == 0 {
+		return
+	}
+	userData := j.makeJFUserData(userID, itemID, playstate)
+	userData.ItemID = itemID
+	msg := JFSocketMessage{
+		MessageType: "UserDataChanged",
+		MessageID:   randomID(),
+		Data: JFUserDataChangeInfo{
+			UserID:       userID,
+			UserDataList: []JFUserData{*userData},
+		},
+	}
+	for _, s := range sockets {
+		s.sendMessage(msg)
+	}
+}
+
+// randomID returns a random 32 character hex ID.
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// headerContains returns true if a comma separated header contains value, case insensitive.
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -160,46 +160,77 @@ func (n *Normalizer) normalizeQueryParameters(rawQuery string) string {
 
 // These are the query parameters we rename
 var queryParameters = map[string]string{
+	"anyprovideridequals":     "anyProviderIdEquals",
 	"api_key":                 "api_key",
 	"apikey":                  "apiKey",
 	"appearsinitemid":         "appearsInItemId",
+	"categorylimit":           "categoryLimit",
 	"code":                    "code",
+	"disablefirstepisode":     "disableFirstEpisode",
+	"enablerewatching":        "enableRewatching",
 	"excludeitemids":          "excludeItemIds",
+	"excludelocationtypes":    "excludeLocationTypes",
+	"fillheight":              "fillHeight",
+	"fillwidth":               "fillWidth",
 	"filters":                 "filters",
 	"genreids":                "genreIds",
 	"genres":                  "genres",
+	"hasuserid":               "hasUserId",
 	"id":                      "id",
 	"ids":                     "ids",
+	"imdbid":                  "imdbId",
 	"includehidden":           "includeHidden",
 	"indexnumber":             "indexNumber",
 	"is4k":                    "is4K",
 	"isfavorite":              "isFavorite",
 	"ishd":                    "isHd",
+	"ismissing":               "isMissing",
 	"isplayed":                "isPlayed",
+	"itemids":                 "itemIds",
+	"itemlimit":               "itemLimit",
 	"limit":                   "limit",
+	"maxofficialrating":       "maxOfficialRating",
 	"maxpremieredate":         "maxPremiereDate",
 	"mediatypes":              "mediaTypes",
 	"mincommunityrating":      "minCommunityRating",
 	"mincriticrating":         "minCriticRating",
+	"maxdate":                 "maxDate",
+	"maxheight":               "maxHeight",
+	"maxwidth":                "maxWidth",
+	"mindate":                 "minDate",
+	"minofficialrating":       "minOfficialRating",
 	"minpremieredate":         "minPremiereDate",
 	"name":                    "name",
 	"namelessthan":            "nameLessThan",
 	"namestartswith":          "nameStartsWith",
 	"namestartswithorgreater": "nameStartsWithOrGreater",
+	"nextupdatecutoff":        "nextUpDateCutoff",
 	"officialratings":         "officialRatings",
 	"parentid":                "parentId",
 	"parentindexnumber":       "parentIndexNumber",
 	"personids":               "personIds",
+	"persontypes":             "personTypes",
+	"playablemediatypes":      "playableMediaTypes",
+	"playcommand":             "playCommand",
+	"playsessionid":           "playSessionId",
+	"productionlocations":     "productionLocations",
 	"recursive":               "recursive",
 	"searchterm":              "searchTerm",
 	"seasonid":                "seasonId",
+	"seekpositionticks":       "seekPositionTicks",
 	"seriesid":                "seriesId",
 	"sortby":                  "sortBy",
 	"sortorder":               "sortOrder",
 	"startindex":              "startIndex",
+	"startpositionticks":      "startPositionTicks",
+	"starttimeticks":          "startTimeTicks",
 	"studioids":               "studioIds",
 	"studios":                 "studios",
+	"supportedcommands":       "supportedCommands",
+	"supportsmediacontrol":    "supportsMediaControl",
 	"tag":                     "tag",
+	"tmdbid":                  "tmdbId",
+	"tvdbid":                  "tvdbId",
 	"userid":                  "userId",
 	"years":                   "years",
 }
@@ -8,7 +8,6 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -102,7 +101,7 @@ func (n *Notflix) collectionsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	cc := []Collection{}
 	for _, c := range n.collections.GetCollections() {
-		cc = append(cc, copyCollection(c))
+		cc = append(cc, copyCollection(*c))
 	}
 	serveJSON(cc, w)
 }
@@ -131,16 +130,8 @@ func (n *Notflix) itemsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var lastVideo time.Time
-	for i := range c.Items {
-		switch s := c.Items[i].(type) {
-		case *collection.Show:
-			if s.LastVideo().After(lastVideo) {
-				lastVideo = s.LastVideo()
-			}
-		}
-	}
-	if !lastVideo.IsZero() && checkEtagObj(w, r, lastVideo) {
+	// LastUpdate changes when items are added, removed or changed
+	if !c.LastUpdate.IsZero() && checkEtagObj(w, r, c.LastUpdate) {
 		return
 	}
 	if r.Method == "HEAD" {
@@ -237,7 +228,22 @@ func (n *Notflix) dataHandler(w http.ResponseWriter, r *http.Request) {
 	// 	http.Error(w, "404 Not Found", http.StatusNotFound)
 	// 	return
 	// }
-	fn := path.Clean(path.Join(c.GetDataDir(), "/", vars["path"]))
+	if n.collections.CollectionOffline(c) {
+		w.Header().Set("retry-after", "60")
+		http.Error(w, "503 Storage temporarily offline", http.StatusServiceUnavailable)
+		return
+	}
+	fn := c.ResolvePath(vars["path"])
+	// Only serve files found by the scanner
+	if fn == "" || !n.collections.Registered(fn) {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if n.collections.Offline(fn) {
+		w.Header().Set("retry-after", "60")
+		http.Error(w, "503 Storage temporarily offline", http.StatusServiceUnavailable)
+		return
+	}
 
 	var err error
 	var file http.File
@@ -252,11 +258,11 @@ func (n *Notflix) dataHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		file, err = n.imageresizer.OpenFile(w, r, fn, 0)
 	}
-	defer file.Close()
 	if err != nil {
 		http.Error(w, "404 Not Found", http.StatusNotFound)
 		return
 	}
+	defer file.Close()
 
 	fi, _ := file.Stat()
 	if !fi.Mode().IsRegular() {
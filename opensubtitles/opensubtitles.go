@@ -0,0 +1,312 @@
+// Package opensubtitles is a minimal client for the OpenSubtitles.com REST API.
+//
+// It is used to search subtitles of movies and episodes and to download them.
+package opensubtitles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBaseURL is the OpenSubtitles API endpoint.
+	defaultBaseURL = "https://api.opensubtitles.com/api/v1"
+	// userAgent identifies us to the API, it is required.
+	userAgent = "Jellofin v1.0"
+	// maxSubtitleSize is the maximum size of a downloaded subtitle file.
+	maxSubtitleSize = 10 * 1024 * 1024
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+// Client is an OpenSubtitles API client.
+type Client struct {
+	apiKey     string
+	username   string
+	password   string
+	baseURL    string
+	httpClient *http.Client
+	// token is the session token of the user, it increases the download quota.
+	token   string
+	tokenMu sync.Mutex
+}
+
+// Query describes the video to search subtitles for.
+type Query struct {
+	// Languages are two letter language codes, e.g. "en".
+	Languages []string
+	// ProviderIDs of the movie or episode, e.g. {"imdb": "tt0111161"}.
+	ProviderIDs map[string]string
+	// ParentProviderIDs of the show, when searching subtitles of an episode.
+	ParentProviderIDs map[string]string
+	// Title of the movie or show, used if no provider ID is available.
+	Title string
+	// SeasonNumber and EpisodeNumber of an episode.
+	SeasonNumber  int
+	EpisodeNumber int
+}
+
+// Subtitle is a subtitle search result.
+type Subtitle struct {
+	// FileID is the ID to use for downloading the subtitle.
+	FileID string
+	// Language is the two letter language code.
+	Language string
+	// Name is the release name the subtitle was made for.
+	Name     string
+	Comment  string
+	Uploader string
+	Uploaded time.Time
+	Rating   float64
+	FPS      float64
+	// DownloadCount is the number of times the subtitle was downloaded.
+	DownloadCount     int
+	HearingImpaired   bool
+	ForeignPartsOnly  bool
+	AITranslated      bool
+	MachineTranslated bool
+}
+
+// New creates an OpenSubtitles API client. Username and password are optional,
+// logging in raises the daily download quota.
+func New(apiKey, username, password string) *Client {
+	return &Client{
+		apiKey:   apiKey,
+		username: username,
+		password: password,
+		baseURL:  defaultBaseURL,
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// SearchSubtitles returns the subtitles available for a movie or episode, most downloaded first.
+func (c *Client) SearchSubtitles(ctx context.Context, q Query) ([]Subtitle, error) {
+	params := url.Values{"order_by": {"download_count"}}
+	if len(q.Languages) > 0 {
+		params.Set("languages", strings.ToLower(strings.Join(q.Languages, ",")))
+	}
+	if q.SeasonNumber > 0 || q.EpisodeNumber > 0 {
+		params.Set("season_number", strconv.Itoa(q.SeasonNumber))
+		params.Set("episode_number", strconv.Itoa(q.EpisodeNumber))
+	}
+	switch {
+	case imdbID(q.ProviderIDs) != "":
+		params.Set("imdb_id", imdbID(q.ProviderIDs))
+	case q.ProviderIDs["tmdb"] != "":
+		params.Set("tmdb_id", q.ProviderIDs["tmdb"])
+	case imdbID(q.ParentProviderIDs) != "":
+		params.Set("parent_imdb_id", imdbID(q.ParentProviderIDs))
+	case q.ParentProviderIDs["tmdb"] != "":
+		params.Set("parent_tmdb_id", q.ParentProviderIDs["tmdb"])
+	case q.Title != "":
+		params.Set("query", strings.ToLower(q.Title))
+	default:
+		return nil, ErrNotFound
+	}
+
+	var result struct {
+		Data []struct {
+			Attributes struct {
+				Language          string  `json:"language"`
+				DownloadCount     int     `json:"download_count"`
+				HearingImpaired   bool    `json:"hearing_impaired"`
+				ForeignPartsOnly  bool    `json:"foreign_parts_only"`
+				AITranslated      bool    `json:"ai_translated"`
+				MachineTranslated bool    `json:"machine_translated"`
+				FPS               float64 `json:"fps"`
+				Ratings           float64 `json:"ratings"`
+				UploadDate        string  `json:"upload_date"`
+				Release           string  `json:"release"`
+				Comments          string  `json:"comments"`
+				Uploader          struct {
+					Name string `json:"name"`
+				} `json:"uploader"`
+				Files []struct {
+					FileID   int    `json:"file_id"`
+					FileName string `json:"file_name"`
+				} `json:"files"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	// Parameters have to be sorted, the API redirects otherwise. Encode sorts by key.
+	if err := c.do(ctx, http.MethodGet, "/subtitles?"+params.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+
+	var subtitles []Subtitle
+	for _, d := range result.Data {
+		a := d.Attributes
+		// Subtitles split over multiple files (CDs) cannot be used with a single video file
+		if len(a.Files) != 1 {
+			continue
+		}
+		s := Subtitle{
+			FileID:            strconv.Itoa(a.Files[0].FileID),
+			Language:          a.Language,
+			Name:              a.Release,
+			Comment:           a.Comments,
+			Uploader:          a.Uploader.Name,
+			Rating:            a.Ratings,
+			FPS:               a.FPS,
+			DownloadCount:     a.DownloadCount,
+			HearingImpaired:   a.HearingImpaired,
+			ForeignPartsOnly:  a.ForeignPartsOnly,
+			AITranslated:      a.AITranslated,
+			MachineTranslated: a.MachineTranslated,
+		}
+		if s.Name == "" {
+			s.Name = a.Files[0].FileName
+		}
+		if uploaded, err := time.Parse(time.RFC3339, a.UploadDate); err == nil {
+			s.Uploaded = uploaded
+		}
+		subtitles = append(subtitles, s)
+	}
+	return subtitles, nil
+}
+
+// DownloadSubtitle returns the contents of a subtitle in SRT format.
+func (c *Client) DownloadSubtitle(ctx context.Context, fileID string) ([]byte, error) {
+	id, err := strconv.Atoi(fileID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	request := map[string]any{
+		"file_id":    id,
+		"sub_format": "srt",
+	}
+	var result struct {
+		Link string `json:"link"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/download", request, &result); err != nil {
+		return nil, err
+	}
+	if result.Link == "" {
+		return nil, ErrNotFound
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.Link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", userAgent)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensubtitles download: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSubtitleSize))
+}
+
+// login returns the session token of the user, empty if no user is configured.
+func (c *Client) login(ctx context.Context) (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token != "" {
+		return c.token, nil
+	}
+	request := map[string]string{
+		"username": c.username,
+		"password": c.password,
+	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := c.request(ctx, http.MethodPost, "/login", request, "", &result); err != nil {
+		return "", fmt.Errorf("opensubtitles login: %w", err)
+	}
+	c.token = result.Token
+	return c.token, nil
+}
+
+// do calls an API endpoint as the configured user.
+func (c *Client) do(ctx context.Context, method, path string, body, v any) error {
+	token, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.request(ctx, method, path, body, token, v)
+	// Session tokens expire, login again once
+	if errors.Is(err, errUnauthorized) && token != "" {
+		c.tokenMu.Lock()
+		c.token = ""
+		c.tokenMu.Unlock()
+		if token, err = c.login(ctx); err != nil {
+			return err
+		}
+		err = c.request(ctx, method, path, body, token, v)
+	}
+	return err
+}
+
+var errUnauthorized = errors.New("unauthorized")
+
+// request calls an API endpoint and decodes the response into v.
+func (c *Client) request(ctx context.Context, method, path string, body any, token string, v any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("api-key", c.apiKey)
+	req.Header.Set("user-agent", userAgent)
+	if body != nil {
+		req.Header.Set("content-type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("authorization", "Bearer "+token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return errUnauthorized
+	default:
+		return fmt.Errorf("opensubtitles %s: %s", strings.SplitN(path, "?", 2)[0], resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// imdbID returns the numeric IMDb ID, the API does not accept the "tt" prefix.
+func imdbID(providerIDs map[string]string) string {
+	id := strings.TrimPrefix(providerIDs["imdb"], "tt")
+	if _, err := strconv.Atoi(id); err != nil {
+		return ""
+	}
+	return strings.TrimLeft(id, "0")
+}
@@ -0,0 +1,173 @@
+// Package parentalrating normalizes regional content rating strings such as
+// "PG-13", "FSK 16", "15+" or "TV-MA" onto a comparable numeric scale.
+//
+// The scale is the minimum viewer age, so "PG-13" maps to 13 and "FSK 16" to 16.
+// This allows ratings from different countries to be compared for parental
+// filtering.
+package parentalrating
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Rating is a rating name with its normalized score.
+type Rating struct {
+	// Name is the rating as displayed, e.g. "PG-13".
+	Name string
+	// Score is the minimum viewer age for this rating.
+	Score int
+}
+
+// Ratings holds the rating normalization table.
+type Ratings struct {
+	// ratings is map of normalized rating name to rating.
+	ratings map[string]Rating
+}
+
+// defaultRatings is the built-in normalization table. Ratings that are only
+// unique per country use a country prefix, e.g. "UK:PG".
+var defaultRatings = []Rating{
+	// United States, MPA
+	{"G", 0},
+	{"PG", 10},
+	{"PG-13", 13},
+	{"R", 17},
+	{"NC-17", 18},
+	{"X", 18},
+	// United States, TV parental guidelines
+	{"TV-Y", 0},
+	{"TV-G", 0},
+	{"TV-Y7", 7},
+	{"TV-Y7-FV", 7},
+	{"TV-PG", 10},
+	{"TV-14", 14},
+	{"TV-MA", 17},
+	// Germany, FSK
+	{"FSK 0", 0},
+	{"FSK 6", 6},
+	{"FSK 12", 12},
+	{"FSK 16", 16},
+	{"FSK 18", 18},
+	// United Kingdom, BBFC
+	{"U", 0},
+	{"UK:PG", 8},
+	{"12A", 12},
+	{"R18", 18},
+	// Netherlands, Kijkwijzer
+	{"AL", 0},
+}
+
+// isAgeRating matches ratings that are an age, e.g. "16", "15+" or "FSK16".
+var isAgeRating = regexp.MustCompile(`^(?:fsk|ab)?\s*-?\s*(\d{1,2})\s*\+?$`)
+
+// New creates a rating normalization table with the built-in ratings.
+// If mappingFile is set the ratings in it are added to the table, overriding
+// built-in ratings with the same name. The file is YAML map of rating name to score:
+//
+//	"PG-13": 13
+//	"FSK 16": 16
+func New(mappingFile string) (*Ratings, error) {
+	r := &Ratings{
+		ratings: make(map[string]Rating),
+	}
+	for _, rating := range defaultRatings {
+		r.add(rating)
+	}
+	if mappingFile == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]int
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", mappingFile, err)
+	}
+	for name, score := range mapping {
+		r.add(Rating{Name: name, Score: score})
+	}
+	return r, nil
+}
+
+// add adds a rating to the table.
+func (r *Ratings) add(rating Rating) {
+	r.ratings[normalize(rating.Name)] = rating
+}
+
+// Score returns the normalized score of a rating string. Returns false if the
+// rating is unknown or unrated.
+func (r *Ratings) Score(rating string) (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+	key := normalize(rating)
+	if key == "" {
+		return 0, false
+	}
+	if rating, ok := r.ratings[key]; ok {
+		return rating.Score, true
+	}
+	// Try again without country prefix, e.g. "US:PG-13" -> "PG-13"
+	if i := strings.Index(key, ":"); i >= 0 {
+		key = normalize(key[i+1:])
+		if rating, ok := r.ratings[key]; ok {
+			return rating.Score, true
+		}
+	}
+	// Last resort: ratings that are just an age, e.g. "16+" or "FSK16"
+	if m := isAgeRating.FindStringSubmatch(key); m != nil {
+		if age, err := strconv.Atoi(m[1]); err == nil {
+			return age, true
+		}
+	}
+	return 0, false
+}
+
+// Equal returns true if two rating strings have the same normalized score.
+func (r *Ratings) Equal(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	scoreA, okA := r.Score(a)
+	scoreB, okB := r.Score(b)
+	return okA && okB && scoreA == scoreB
+}
+
+// Ratings returns all ratings in the table, ordered by score.
+func (r *Ratings) Ratings() []Rating {
+	if r == nil {
+		return []Rating{}
+	}
+	ratings := make([]Rating, 0, len(r.ratings))
+	for _, rating := range r.ratings {
+		ratings = append(ratings, rating)
+	}
+	sort.Slice(ratings, func(i, j int) bool {
+		if ratings[i].Score != ratings[j].Score {
+			return ratings[i].Score < ratings[j].Score
+		}
+		return ratings[i].Name < ratings[j].Name
+	})
+	return ratings
+}
+
+// normalize returns the lookup key for a rating string.
+func normalize(rating string) string {
+	rating = strings.ToLower(strings.TrimSpace(rating))
+	// Kodi scrapers often prefix the MPA rating, e.g. "Rated PG-13"
+	rating = strings.TrimPrefix(rating, "rated ")
+	// "FSK16", "FSK-16" and "FSK 16" are the same rating
+	if rest, ok := strings.CutPrefix(rating, "fsk"); ok {
+		rating = "fsk " + strings.TrimLeft(rest, " -")
+	}
+	return strings.TrimSpace(rating)
+}
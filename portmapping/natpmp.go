@@ -0,0 +1,136 @@
+package portmapping
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// natpmpPort is the UDP port routers answer NAT-PMP requests on.
+	natpmpPort = 5351
+	// natpmpTries is the number of times a request is sent, the timeout doubles each time.
+	natpmpTries = 4
+	// natpmpTimeout is the time the router gets to answer the first request.
+	natpmpTimeout = 250 * time.Millisecond
+)
+
+// NAT-PMP operations, responses have 128 added
+const (
+	natpmpOpExternalAddress = 0
+	natpmpOpMapTCP          = 2
+)
+
+// natpmpGateway is a router supporting NAT-PMP (RFC 6886).
+type natpmpGateway struct {
+	addr *net.UDPAddr
+}
+
+// discoverNATPMP checks if the default gateway supports NAT-PMP.
+func discoverNATPMP() (gateway, error) {
+	ip, err := defaultGateway()
+	if err != nil {
+		return nil, fmt.Errorf("nat-pmp: %w", err)
+	}
+	gw := &natpmpGateway{addr: &net.UDPAddr{IP: ip, Port: natpmpPort}}
+	if _, err := gw.externalIP(); err != nil {
+		return nil, fmt.Errorf("nat-pmp: %w", err)
+	}
+	return gw, nil
+}
+
+// defaultGateway returns the IPv4 address of the default gateway, read from the routing table of Linux.
+func defaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, errors.New("default gateway unknown")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Columns are interface, destination and gateway, addresses are hex in host byte order
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		return net.IPv4(b[3], b[2], b[1], b[0]), nil
+	}
+	return nil, errors.New("no default gateway")
+}
+
+func (g *natpmpGateway) name() string {
+	return "NAT-PMP"
+}
+
+func (g *natpmpGateway) externalIP() (net.IP, error) {
+	resp, err := g.request([]byte{0, natpmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+func (g *natpmpGateway) addMapping(internalPort, externalPort int, lease time.Duration) (int, error) {
+	req := make([]byte, 12)
+	req[1] = natpmpOpMapTCP
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:], uint32(lease.Seconds()))
+	resp, err := g.request(req, 16)
+	if err != nil {
+		return 0, err
+	}
+	// The router can assign another external port than requested
+	return int(binary.BigEndian.Uint16(resp[10:])), nil
+}
+
+func (g *natpmpGateway) deleteMapping(internalPort, externalPort int) error {
+	// A mapping with lifetime and external port 0 removes the mapping of the internal port
+	req := make([]byte, 12)
+	req[1] = natpmpOpMapTCP
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	_, err := g.request(req, 16)
+	return err
+}
+
+// request sends a request to the router, retrying with increasing timeouts, and
+// returns its response of size bytes.
+func (g *natpmpGateway) request(req []byte, size int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, g.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp := make([]byte, 16)
+	timeout := natpmpTimeout
+	for range natpmpTries {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		n, err := conn.Read(resp)
+		timeout *= 2
+		if err != nil {
+			continue
+		}
+		if n < size || resp[1] != req[1]+128 {
+			continue
+		}
+		if result := binary.BigEndian.Uint16(resp[2:]); result != 0 {
+			return nil, fmt.Errorf("result code %d", result)
+		}
+		return resp[:size], nil
+	}
+	return nil, errors.New("no response")
+}
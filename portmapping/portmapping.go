@@ -0,0 +1,182 @@
+// Package portmapping asks the router to forward a port to the server, so clients can
+// reach it from outside the local network without manual router configuration.
+//
+// It uses UPnP IGD (Internet Gateway Device) and falls back to NAT-PMP. The mapping is
+// renewed before its lease expires and removed when the server stops.
+package portmapping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLease is the lifetime of a port mapping requested from the router.
+	defaultLease = time.Hour
+	// retryInterval is the time between attempts when no router could map the port.
+	retryInterval = 5 * time.Minute
+	// mappingDescription is shown in the port forwarding list of the router.
+	mappingDescription = "Jellofin"
+)
+
+// Options holds the ports to map.
+type Options struct {
+	// InternalPort is the port the server listens on
+	InternalPort int
+	// ExternalPort is the port to request on the router, defaults to InternalPort
+	ExternalPort int
+	// Lease is the lifetime of the mapping, it is renewed halfway. Defaults to 1 hour
+	Lease time.Duration
+}
+
+// gateway is a router protocol that can forward ports.
+type gateway interface {
+	// name returns the name of the protocol, used in logging
+	name() string
+	// externalIP returns the public IP address of the router
+	externalIP() (net.IP, error)
+	// addMapping forwards an external TCP port to the internal port of this host,
+	// it returns the external port the router assigned
+	addMapping(internalPort, externalPort int, lease time.Duration) (int, error)
+	// deleteMapping removes the forward of an external TCP port
+	deleteMapping(internalPort, externalPort int) error
+}
+
+// PortMapper keeps a port mapping on the router.
+type PortMapper struct {
+	internalPort int
+	externalPort int
+	lease        time.Duration
+
+	mu sync.Mutex
+	// gateway is the router holding the mapping, nil if there is none
+	gateway gateway
+	// address is the external IP address and port of the mapping
+	address string
+}
+
+// New creates a PortMapper.
+func New(o *Options) *PortMapper {
+	p := &PortMapper{
+		internalPort: o.InternalPort,
+		externalPort: o.ExternalPort,
+		lease:        o.Lease,
+	}
+	if p.externalPort == 0 {
+		p.externalPort = p.internalPort
+	}
+	if p.lease <= 0 {
+		p.lease = defaultLease
+	}
+	return p
+}
+
+// Start maps the port and keeps renewing it in the background, until ctx is cancelled.
+func (p *PortMapper) Start(ctx context.Context) {
+	go func() {
+		for {
+			interval := p.lease / 2
+			if err := p.refresh(); err != nil {
+				log.Printf("Port mapping failed: %s", err)
+				interval = retryInterval
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// ExternalAddress returns the external IP address and port of the mapping,
+// e.g. "203.0.113.5:8096". It is empty as long as there is no mapping.
+func (p *PortMapper) ExternalAddress() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.address
+}
+
+// Stop removes the port mapping from the router.
+func (p *PortMapper) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gateway == nil {
+		return
+	}
+	if err := p.gateway.deleteMapping(p.internalPort, p.externalPort); err != nil {
+		log.Printf("Port mapping %d not removed: %s", p.externalPort, err)
+	}
+	p.gateway = nil
+	p.address = ""
+}
+
+// refresh requests or renews the mapping, finding a router first if needed.
+func (p *PortMapper) refresh() error {
+	p.mu.Lock()
+	gw := p.gateway
+	p.mu.Unlock()
+	if gw != nil {
+		if err := p.addMapping(gw); err == nil {
+			return nil
+		}
+		// The router may have restarted or been replaced, look for it again
+		p.setMapping(nil, p.externalPort, "")
+	}
+
+	var errs []error
+	for _, discover := range []func() (gateway, error){discoverUPnP, discoverNATPMP} {
+		gw, err := discover()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := p.addMapping(gw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", gw.name(), err))
+			continue
+		}
+		log.Printf("Port mapping via %s: %s forwarded to port %d", gw.name(), p.ExternalAddress(), p.internalPort)
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// addMapping requests the mapping from a router and looks up its external address.
+func (p *PortMapper) addMapping(gw gateway) error {
+	port, err := gw.addMapping(p.internalPort, p.externalPort, p.lease)
+	if err != nil {
+		return err
+	}
+	ip, err := gw.externalIP()
+	if err != nil {
+		return err
+	}
+	p.setMapping(gw, port, net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	return nil
+}
+
+// setMapping stores the router holding the mapping and its external port and address.
+func (p *PortMapper) setMapping(gw gateway, port int, address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gateway = gw
+	p.externalPort = port
+	p.address = address
+}
+
+// localIP returns the IP address of the interface used to reach the router.
+func localIP(router net.IP) (net.IP, error) {
+	// Connecting a UDP socket does not send packets, it only selects the route
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: router, Port: 1})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
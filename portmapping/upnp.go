@@ -0,0 +1,308 @@
+package portmapping
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ssdpTimeout is the time routers get to answer a search.
+	ssdpTimeout = 3 * time.Second
+	// soapTimeout is the maximum time of a request to the router.
+	soapTimeout = 5 * time.Second
+	// upnpOnlyPermanentLeases is the error of routers that do not support mapping lifetimes.
+	upnpOnlyPermanentLeases = 725
+)
+
+var ssdpAddr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// upnpServiceTypes are the services of a router that forward ports, in order of preference.
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpGateway is a router supporting UPnP IGD.
+type upnpGateway struct {
+	// controlURL is the URL SOAP requests are posted to
+	controlURL string
+	// serviceType is the port forwarding service of the router
+	serviceType string
+	// internalIP is the IP address of this host on the network of the router
+	internalIP net.IP
+	client     *http.Client
+}
+
+// upnpDescription is the device description of a router.
+type upnpDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+// upnpDevice is a device of a router, it can contain embedded devices.
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+// upnpService is a service of a device.
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// upnpError is an error returned by a router.
+type upnpError struct {
+	code        int
+	description string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("upnp error %d: %s", e.code, e.description)
+}
+
+// discoverUPnP searches the local network for a router supporting UPnP IGD.
+func discoverUPnP() (gateway, error) {
+	locations, err := ssdpSearch()
+	if err != nil {
+		return nil, fmt.Errorf("upnp: %w", err)
+	}
+	client := &http.Client{Timeout: soapTimeout}
+	for _, location := range locations {
+		if gw, err := newUPnPGateway(client, location); err == nil {
+			return gw, nil
+		}
+	}
+	return nil, errors.New("upnp: no router found")
+}
+
+// ssdpSearch returns the description URLs of the routers answering an SSDP search.
+func ssdpSearch() ([]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	for _, st := range upnpServiceTypes {
+		request := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"ST: " + st + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(request), ssdpAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	var locations []string
+	seen := make(map[string]bool)
+	conn.SetReadDeadline(time.Now().Add(ssdpTimeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// Read deadline reached
+			break
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+// newUPnPGateway reads the description of a router and finds its port forwarding service.
+func newUPnPGateway(client *http.Client, location string) (*upnpGateway, error) {
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var desc upnpDescription
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if desc.URLBase != "" {
+		if u, err := url.Parse(desc.URLBase); err == nil {
+			base = u
+		}
+	}
+
+	for _, serviceType := range upnpServiceTypes {
+		service, ok := findUPnPService(desc.Device, serviceType)
+		if !ok {
+			continue
+		}
+		control, err := base.Parse(service.ControlURL)
+		if err != nil {
+			return nil, err
+		}
+		routerIP, err := net.ResolveIPAddr("ip4", control.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		internalIP, err := localIP(routerIP.IP)
+		if err != nil {
+			return nil, err
+		}
+		return &upnpGateway{
+			controlURL:  control.String(),
+			serviceType: serviceType,
+			internalIP:  internalIP,
+			client:      client,
+		}, nil
+	}
+	return nil, errors.New("no port forwarding service")
+}
+
+// findUPnPService returns the service of a type of a device or its embedded devices.
+func findUPnPService(device upnpDevice, serviceType string) (upnpService, bool) {
+	for _, s := range device.Services {
+		if s.ServiceType == serviceType {
+			return s, true
+		}
+	}
+	for _, d := range device.Devices {
+		if s, ok := findUPnPService(d, serviceType); ok {
+			return s, true
+		}
+	}
+	return upnpService{}, false
+}
+
+func (g *upnpGateway) name() string {
+	return "UPnP"
+}
+
+func (g *upnpGateway) externalIP() (net.IP, error) {
+	values, err := g.call("GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(values["NewExternalIPAddress"])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external address %q", values["NewExternalIPAddress"])
+	}
+	return ip, nil
+}
+
+func (g *upnpGateway) addMapping(internalPort, externalPort int, lease time.Duration) (int, error) {
+	args := func(lease time.Duration) [][2]string {
+		return [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(externalPort)},
+			{"NewProtocol", "TCP"},
+			{"NewInternalPort", strconv.Itoa(internalPort)},
+			{"NewInternalClient", g.internalIP.String()},
+			{"NewEnabled", "1"},
+			{"NewPortMappingDescription", mappingDescription},
+			{"NewLeaseDuration", strconv.Itoa(int(lease.Seconds()))},
+		}
+	}
+	_, err := g.call("AddPortMapping", args(lease))
+	// Some routers only accept mappings without expiry, they are removed on shutdown
+	var uerr *upnpError
+	if errors.As(err, &uerr) && uerr.code == upnpOnlyPermanentLeases {
+		_, err = g.call("AddPortMapping", args(0))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return externalPort, nil
+}
+
+func (g *upnpGateway) deleteMapping(internalPort, externalPort int) error {
+	_, err := g.call("DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", "TCP"},
+	})
+	return err
+}
+
+// call invokes a SOAP action of the port forwarding service, it returns the values of the response.
+func (g *upnpGateway) call(action string, args [][2]string) (map[string]string, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + g.serviceType + `">`)
+	for _, arg := range args {
+		body.WriteString("<" + arg[0] + ">")
+		xml.EscapeText(&body, []byte(arg[1]))
+		body.WriteString("</" + arg[0] + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+
+	req, err := http.NewRequest(http.MethodPost, g.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+g.serviceType+"#"+action+`"`)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	values, err := soapValues(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if code, err := strconv.Atoi(values["errorCode"]); err == nil {
+			return nil, &upnpError{code: code, description: values["errorDescription"]}
+		}
+		return nil, fmt.Errorf("%s: unexpected status %s", action, resp.Status)
+	}
+	return values, nil
+}
+
+// soapValues returns the text of the elements of a SOAP response by name, e.g. "NewExternalIPAddress".
+func soapValues(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(r)
+	var name string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name = t.Name.Local
+		case xml.CharData:
+			if name != "" {
+				values[name] += strings.TrimSpace(string(t))
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+}
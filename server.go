@@ -8,9 +8,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,72 +21,47 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/erikbos/jellofin-server/collection"
+	"github.com/erikbos/jellofin-server/collection/metadata"
 	"github.com/erikbos/jellofin-server/database"
 	"github.com/erikbos/jellofin-server/database/sqlite"
+	"github.com/erikbos/jellofin-server/discovery"
 	"github.com/erikbos/jellofin-server/imageresize"
 	"github.com/erikbos/jellofin-server/jellyfin"
 	"github.com/erikbos/jellofin-server/muxnormalizer"
 	"github.com/erikbos/jellofin-server/notflix"
+	"github.com/erikbos/jellofin-server/opensubtitles"
+	"github.com/erikbos/jellofin-server/parentalrating"
+	"github.com/erikbos/jellofin-server/portmapping"
+	"github.com/erikbos/jellofin-server/tmdb"
 )
 
-type configFile struct {
-	Listen struct {
-		Address string
-		Port    string
-		TlsCert string
-		TlsKey  string
-		IPACL   string
-	}
-	Appdir   string
-	Cachedir string
-	Dbdir    string
-	Database struct {
-		Sqlite sqlite.ConfigFile `yaml:"sqlite"`
-	} `yaml:"database"`
-	Logfile     string
-	Collections []struct {
-		ID        string
-		Name      string
-		Type      string
-		Directory string
-		BaseUrl   string
-		HlsServer string
-	}
-	Jellyfin struct {
-		ServerID           string
-		ServerName         string
-		AutoRegister       bool
-		QuickConnect       bool
-		ImageQualityPoster int
-	}
-}
-
 func main() {
 	const configFileNameKey = "config"
 
-	// Set up viper for config file and command line flags
-	viper.SetConfigType("yaml")
-	viper.SetDefault("listen.port", "8096")
-	viper.SetDefault("logfile", "/dev/stdout")
-
+	// Config file can be set using command line flag or environment variable
 	pflag.String("config", "jellofin-server.yaml", "Path to configuration file.")
 	viper.BindPFlag(configFileNameKey, pflag.Lookup("config"))
+	viper.BindEnv(configFileNameKey, envPrefix+"_CONFIG")
+	restore := pflag.String("restore", "", "Restore the database from a backup file and exit.")
 	pflag.Parse()
 
 	// Read config file
 	cf := viper.GetString(configFileNameKey)
 	log.Printf("Using config file %s", cf)
-	viper.SetConfigFile(cf)
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %v", err)
+	config, err := loadConfig(cf)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
 	}
-	var config configFile
-	if err := viper.Unmarshal(&config); err != nil {
-		log.Fatalf("Unable to decode config into struct: %v", err)
+
+	if *restore != "" {
+		if err := restoreDatabase(config, *restore); err != nil {
+			log.Fatalf("Error restoring database: %v", err)
+		}
+		return
 	}
 
 	// Set up logging
-	logfile := viper.GetString("logfile")
+	logfile := config.Logfile
 	log.Printf("Setting logfile to %s", logfile)
 	switch logfile {
 	case "none":
@@ -97,8 +75,11 @@ func main() {
 		log.SetOutput(f)
 	}
 
+	if err := prepareStateDirectories(config); err != nil {
+		log.Fatalf("Error preparing directories: %v", err)
+	}
+
 	log.Printf("dbinit")
-	var err error
 	var repo database.Repository
 	// Legacy support for Dbdir
 	if config.Dbdir != "" {
@@ -108,7 +89,11 @@ func main() {
 	}
 	if config.Database.Sqlite.Filename != "" {
 		repo, err = database.New("sqlite", sqlite.ConfigFile{
-			Filename: config.Database.Sqlite.Filename,
+			Filename:       config.Database.Sqlite.Filename,
+			QueryTimeout:   config.Database.Sqlite.QueryTimeout,
+			BackupDir:      config.Database.Sqlite.BackupDir,
+			BackupInterval: config.Database.Sqlite.BackupInterval,
+			BackupCount:    config.Database.Sqlite.BackupCount,
 		})
 	}
 	if err != nil {
@@ -116,24 +101,61 @@ func main() {
 	}
 	repo.StartBackgroundJobs(context.Background())
 
+	if config.Genremapping != "" {
+		if err := metadata.LoadGenreMapping(config.Genremapping); err != nil {
+			log.Fatalf("error loading genre mapping: %v", err)
+		}
+	}
+
+	// Missing episodes can only be determined using an external episode guide
+	var episodeGuide collection.EpisodeGuide
+	var personProvider jellyfin.PersonProvider
+	var remoteImageProvider jellyfin.RemoteImageProvider
+	var movieSearchProvider jellyfin.MovieSearchProvider
+	if config.Tmdb.ApiKey != "" {
+		tmdbClient := tmdb.New(config.Tmdb.ApiKey)
+		episodeGuide = tmdbClient
+		remoteImageProvider = tmdbClient
+		movieSearchProvider = tmdbClient
+		if config.Tmdb.Persons {
+			personProvider = tmdbClient
+		}
+	}
+
 	// Initialize collection and add them to the collection manager
 	collection := collection.New(&collection.Options{
-		Repo: repo,
+		Repo:              repo,
+		SimilarWeights:    config.Similar,
+		ScanWorkers:       config.Scanworkers,
+		MetadataCacheSize: config.Metadatacachesize,
+		ThumbnailDir:      config.Thumbnaildir,
+		Ffmpeg:            config.Ffmpeg,
+		EpisodeGuide:      episodeGuide,
+		SortArticles:      config.Sortarticles,
+		SnapshotFile:      snapshotFile(config),
 	})
 	for _, coll := range config.Collections {
 		collection.AddCollection(
 			coll.Name,
 			coll.ID,
 			coll.Type,
-			coll.Directory,
+			collectionDirectories(coll.Directory, coll.Directories),
 			coll.BaseUrl,
 			coll.HlsServer,
+			coll.Exclude,
+			coll.SubtitleLanguages,
+			collectionProviders(coll.MetadataProviders, coll.ImageProviders, coll.SubtitleProviders),
+			coll.EpisodeOrder,
+			coll.RefreshIntervalDays,
+			coll.Images,
+			coll.DisplayNames,
 		)
 	}
 
 	resizer := imageresize.New(imageresize.Options{
 		Cachedir: config.Cachedir,
 	})
+	go resizer.CacheEvictor(context.Background(), config.Cachemaxsize*1024*1024, time.Hour)
 	// XXX FIXME
 	// if config.cachedir != "" {
 	// 	go cleanCache(*datadir, config.cachedir, time.Hour)
@@ -142,6 +164,7 @@ func main() {
 	log.Printf("building mux")
 
 	r := mux.NewRouter()
+	r.Use(accessLogRoute)
 
 	n := notflix.New(&notflix.Options{
 		Collections:  collection,
@@ -151,18 +174,82 @@ func main() {
 	})
 	n.RegisterHandlers(r)
 
+	parentalRatings, err := parentalrating.New(config.Jellyfin.ParentalRatings)
+	if err != nil {
+		log.Fatalf("error loading parental ratings: %v", err)
+	}
+
+	// Apply config file changes at runtime
+	reloader := newConfigReloader(cf, collection)
+
+	addrs := listenAddresses(config)
+	_, port, _ := net.SplitHostPort(addrs[0])
+
+	// Subtitles can only be searched and downloaded using an online subtitle database
+	var subtitleProvider jellyfin.SubtitleProvider
+	if config.OpenSubtitles.ApiKey != "" {
+		subtitleProvider = opensubtitles.New(config.OpenSubtitles.ApiKey, config.OpenSubtitles.Username, config.OpenSubtitles.Password)
+	}
+
+	// Ask the router to forward a port, so clients outside the local network can connect
+	var externalAddress func() string
+	var portMapper *portmapping.PortMapper
+	if config.Jellyfin.PortMapping.Enabled {
+		portNumber, _ := strconv.Atoi(port)
+		portMapper = portmapping.New(&portmapping.Options{
+			InternalPort: portNumber,
+			ExternalPort: config.Jellyfin.PortMapping.ExternalPort,
+		})
+		portMapper.Start(context.Background())
+		externalAddress = portMapper.ExternalAddress
+	}
+
 	j := jellyfin.New(&jellyfin.Options{
 		Collections:        collection,
 		Repo:               repo,
 		Imageresizer:       resizer,
-		ServerPort:         config.Listen.Port,
+		ParentalRatings:    parentalRatings,
+		ServerPort:         port,
 		ServerID:           config.Jellyfin.ServerID,
 		ServerName:         config.Jellyfin.ServerName,
 		AutoRegister:       config.Jellyfin.AutoRegister,
 		QuickConnect:       config.Jellyfin.QuickConnect,
 		ImageQualityPoster: config.Jellyfin.ImageQualityPoster,
+		ImageWidthBuckets:  config.Jellyfin.ImageWidthBuckets,
+		CompressionLevel:   config.Jellyfin.CompressionLevel,
+		CompressionLevels:  config.Jellyfin.CompressionLevels,
+		CompressionMinSize: config.Jellyfin.CompressionMinSize,
+		SessionIdleTimeout: config.Jellyfin.SessionIdleTimeout,
+		CORS:               config.Jellyfin.Cors,
+		RequestTimeout:     config.Jellyfin.RequestTimeout,
+		Paths: jellyfin.SystemPaths{
+			ProgramData: programDataDir(config),
+			Web:         config.Appdir,
+			Cache:       config.Cachedir,
+			Log:         logfile,
+			Metadata:    config.Thumbnaildir,
+		},
+		Branding:         config.Jellyfin.Branding,
+		Resume:           config.Jellyfin.Resume,
+		ReloadConfig:     reloader.reload,
+		GenreMappingFile: config.Genremapping,
+		SmartCollections: config.Jellyfin.SmartCollections,
+		DeleteFiles:      config.Jellyfin.DeleteFiles,
+		SubtitleProvider: subtitleProvider,
+		PersonProvider:   personProvider,
+		RequestUser:      setAccessLogUser,
+
+		RemoteImageProvider: remoteImageProvider,
+		MovieSearchProvider: movieSearchProvider,
+		MaxItems:            config.Jellyfin.MaxItems,
+		DedupeItems:         config.Jellyfin.DedupeItems,
+		IntroDetection:      config.Jellyfin.IntroDetection,
+		Notifications:       config.Jellyfin.Notifications,
+		ExternalAddress:     externalAddress,
 	})
 	j.RegisterHandlers(r)
+	reloader.jellyfin = j
+	go reloader.watch()
 
 	r.Path("/robots.txt").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -173,37 +260,114 @@ func main() {
 
 	collection.Init()
 	go collection.Background(context.Background())
+	go j.ImagePregenerateBackground(context.Background())
+	go j.PersonRefreshBackground(context.Background())
+	go j.MetadataRefreshBackground(context.Background())
+	go j.IntroDetectionBackground(context.Background())
 
-	addr := net.JoinHostPort(config.Listen.Address, config.Listen.Port)
+	// Store the scanned items on shutdown, so they are available right after the next start
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		log.Printf("Shutting down")
+		if err := collection.SaveSnapshot(); err != nil {
+			log.Printf("Failed to store library snapshot: %s", err)
+		}
+		if portMapper != nil {
+			portMapper.Stop()
+		}
+		if err := repo.Flush(context.Background()); err != nil {
+			log.Printf("Failed to store play state: %s", err)
+		}
+		os.Exit(0)
+	}()
 
 	// Add muxnormalizer middleware to canonicalize request paths and query parameters
 	canon, err := muxnormalizer.New(r)
 	if err != nil {
 		log.Fatal(err)
 	}
-	server := HttpLog(IPACLmiddleware(config.Listen.IPACL, canon.Middleware(r)))
+	server := HttpLog(IPACLmiddleware(config.Listen.IPACL, j.CORSMiddleware(canon.Middleware(r))), AccessLogOptions{
+		Format:  config.Accesslog.Format,
+		Exclude: config.Accesslog.Exclude,
+	})
 
+	var tlsConfig *tls.Config
 	if config.Listen.TlsCert != "" && config.Listen.TlsKey != "" {
 		kpr, err := NewKeypairReloader(config.Listen.TlsCert, config.Listen.TlsKey)
 		if err != nil {
 			log.Fatalf("error loading keypair: %v", err)
 		}
+		tlsConfig = &tls.Config{
+			// Streamyfin's websocket connection still uses TLS1.2..
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: kpr.GetCertificateFunc(),
+		}
+	}
+
+	// Let clients on the local network find us
+	if config.Jellyfin.Discovery.Enabled {
+		portNumber, _ := strconv.Atoi(port)
+		d := discovery.New(&discovery.Options{
+			ServerID:   j.ServerID(),
+			ServerName: j.ServerName,
+			Port:       portNumber,
+			TLS:        tlsConfig != nil,
+			MDNS:       config.Jellyfin.Discovery.MDNS,
+		})
+		if err := d.Start(context.Background()); err != nil {
+			log.Printf("Discovery disabled: %s", err)
+		}
+	}
 
+	// Serve on all listen addresses, stop if one of them fails
+	errs := make(chan error, len(addrs))
+	for _, addr := range addrs {
 		srv := &http.Server{
-			Addr:    addr,
-			Handler: server,
-			TLSConfig: &tls.Config{
-				// Streamyfin's websocket connection still uses TLS1.2..
-				MinVersion:     tls.VersionTLS12,
-				GetCertificate: kpr.GetCertificateFunc(),
-			},
+			Addr:      addr,
+			Handler:   server,
+			TLSConfig: tlsConfig,
 		}
-		log.Printf("Serving HTTPS on %s", addr)
-		log.Fatal(srv.ListenAndServeTLS("", ""))
-	} else {
-		log.Printf("Serving HTTP on %s", addr)
-		log.Fatal(http.ListenAndServe(addr, server))
+		go func() {
+			if tlsConfig != nil {
+				log.Printf("Serving HTTPS on %s", addr)
+				errs <- srv.ListenAndServeTLS("", "")
+			} else {
+				log.Printf("Serving HTTP on %s", addr)
+				errs <- srv.ListenAndServe()
+			}
+		}()
+	}
+	log.Fatal(<-errs)
+}
+
+// listenAddresses returns the addresses to listen on, e.g. "0.0.0.0:8096" and "[::]:8096".
+// Entries of listen.addresses without port use listen.port.
+func listenAddresses(config configFile) []string {
+	if len(config.Listen.Addresses) == 0 {
+		return []string{net.JoinHostPort(config.Listen.Address, config.Listen.Port)}
+	}
+	addrs := make([]string, 0, len(config.Listen.Addresses))
+	for _, a := range config.Listen.Addresses {
+		a = strings.TrimSpace(a)
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			a = net.JoinHostPort(strings.Trim(a, "[]"), config.Listen.Port)
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// programDataDir returns the directory holding the database.
+func programDataDir(config configFile) string {
+	if config.Datadir != "" {
+		return config.Datadir
+	}
+	if config.Database.Sqlite.Filename != "" {
+		return path.Dir(config.Database.Sqlite.Filename)
 	}
+	return config.Dbdir
 }
 
 type keypairReloader struct {
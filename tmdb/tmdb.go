@@ -0,0 +1,470 @@
+// Package tmdb is a minimal client for The Movie Database (TMDB) API.
+//
+// It is used to retrieve information that is not available in local
+// metadata, such as the complete list of episodes of a show.
+package tmdb
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBaseURL is the TMDB API endpoint.
+	defaultBaseURL = "https://api.themoviedb.org/3"
+	// imageBaseURL is the endpoint of images in their original size.
+	imageBaseURL = "https://image.tmdb.org/t/p/original"
+	// thumbnailBaseURL is the endpoint of images resized for previews.
+	thumbnailBaseURL = "https://image.tmdb.org/t/p/w300"
+	// maxImageSize is the maximum size of a downloaded image.
+	maxImageSize = 40 * 1024 * 1024
+	// cacheTTL is how long API responses are cached.
+	cacheTTL = 24 * time.Hour
+)
+
+var (
+	ErrNoProviderID = errors.New("no tmdb, tvdb or imdb id available")
+	ErrNotFound     = errors.New("not found")
+)
+
+// Client is a TMDB API client.
+type Client struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	// cache holds API responses by request path.
+	cache   map[string]cacheEntry
+	cacheMu sync.Mutex
+}
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// Episode is an episode of a show.
+type Episode struct {
+	// SeasonNumber is the season number, 0 is used for specials.
+	SeasonNumber int
+	// EpisodeNumber is the episode number within the season.
+	EpisodeNumber int
+	// Name is the title of the episode.
+	Name string
+	// Overview is the plot of the episode.
+	Overview string
+	// AirDate is the date the episode was first aired, zero if unknown.
+	AirDate time.Time
+}
+
+// Person is an actor, director or writer.
+type Person struct {
+	// ID is the TMDB ID of the person.
+	ID string
+	// Name is the name of the person.
+	Name string
+	// Biography is a short biography of the person.
+	Biography string
+	// Birthday is the date of birth, zero if unknown.
+	Birthday time.Time
+	// PlaceOfBirth is the birthplace of the person.
+	PlaceOfBirth string
+	// ProfilePath is the path of the profile image, e.g. "/3E4x5doNuuu6i9Mef6HPrlZjNb1.jpg", empty if none.
+	ProfilePath string
+}
+
+// Media types of the images API.
+const (
+	MediaTypeMovie = "movie"
+	MediaTypeTV    = "tv"
+)
+
+// Image is an image of a movie or show.
+type Image struct {
+	// Type is "poster", "backdrop" or "logo".
+	Type string
+	// FilePath is the path of the image, e.g. "/3E4x5doNuuu6i9Mef6HPrlZjNb1.jpg".
+	FilePath string
+	// Width and Height are the size of the image in pixels.
+	Width  int
+	Height int
+	// Language is the ISO 639-1 code of the language of text in the image, empty if it has no text.
+	Language string
+	// VoteAverage is the average rating of the image from 0 to 10.
+	VoteAverage float64
+	// VoteCount is the number of ratings of the image.
+	VoteCount int
+}
+
+// Movie is a movie found by SearchMovies.
+type Movie struct {
+	// ID is the TMDB ID of the movie.
+	ID string
+	// IMDbID is the IMDb ID of the movie, only set if the movie was looked up by id.
+	IMDbID string
+	// Title is the title of the movie.
+	Title string
+	// OriginalTitle is the title in the original language of the movie.
+	OriginalTitle string
+	// Overview is the plot of the movie.
+	Overview string
+	// ReleaseDate is the date the movie was first released, zero if unknown.
+	ReleaseDate time.Time
+	// PosterPath is the path of the poster, empty if none.
+	PosterPath string
+}
+
+// New creates a TMDB API client. apiKey can be either an API key (v3) or a
+// read access token (v4).
+func New(apiKey string) *Client {
+	return &Client{
+		apiKey:  apiKey,
+		baseURL: defaultBaseURL,
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+// ShowEpisodes returns all episodes of a show. The show is looked up using
+// its provider IDs, e.g. {"tmdb": "1399"} or {"tvdb": "121361"}.
+func (c *Client) ShowEpisodes(ctx context.Context, providerIDs map[string]string) ([]Episode, error) {
+	showID, err := c.findID(ctx, MediaTypeTV, providerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var show struct {
+		Seasons []struct {
+			SeasonNumber int `json:"season_number"`
+		} `json:"seasons"`
+	}
+	if err := c.get(ctx, "/tv/"+showID, nil, &show); err != nil {
+		return nil, err
+	}
+
+	var episodes []Episode
+	for _, s := range show.Seasons {
+		var season struct {
+			Episodes []struct {
+				SeasonNumber  int    `json:"season_number"`
+				EpisodeNumber int    `json:"episode_number"`
+				Name          string `json:"name"`
+				Overview      string `json:"overview"`
+				AirDate       string `json:"air_date"`
+			} `json:"episodes"`
+		}
+		if err := c.get(ctx, fmt.Sprintf("/tv/%s/season/%d", showID, s.SeasonNumber), nil, &season); err != nil {
+			return nil, err
+		}
+		for _, e := range season.Episodes {
+			episode := Episode{
+				SeasonNumber:  e.SeasonNumber,
+				EpisodeNumber: e.EpisodeNumber,
+				Name:          e.Name,
+				Overview:      e.Overview,
+			}
+			if airDate, err := time.Parse(time.DateOnly, e.AirDate); err == nil {
+				episode.AirDate = airDate
+			}
+			episodes = append(episodes, episode)
+		}
+	}
+	return episodes, nil
+}
+
+// SearchPerson returns the details of the most popular person with a name. Returns
+// ErrNotFound if no person with the name exists.
+func (c *Client) SearchPerson(ctx context.Context, name string) (Person, error) {
+	var result struct {
+		Results []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}
+	if err := c.get(ctx, "/search/person", url.Values{"query": {name}}, &result); err != nil {
+		return Person{}, err
+	}
+	// Results are ordered by popularity, prefer an exact match of the name
+	index := -1
+	for i, r := range result.Results {
+		if strings.EqualFold(r.Name, name) {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return Person{}, ErrNotFound
+	}
+
+	var details struct {
+		Name         string `json:"name"`
+		Biography    string `json:"biography"`
+		Birthday     string `json:"birthday"`
+		PlaceOfBirth string `json:"place_of_birth"`
+		ProfilePath  string `json:"profile_path"`
+	}
+	personID := strconv.Itoa(result.Results[index].ID)
+	if err := c.get(ctx, "/person/"+personID, nil, &details); err != nil {
+		return Person{}, err
+	}
+	person := Person{
+		ID:           personID,
+		Name:         details.Name,
+		Biography:    details.Biography,
+		PlaceOfBirth: details.PlaceOfBirth,
+		ProfilePath:  details.ProfilePath,
+	}
+	if birthday, err := time.Parse(time.DateOnly, details.Birthday); err == nil {
+		person.Birthday = birthday
+	}
+	return person, nil
+}
+
+// SearchMovies returns the movies matching a title ordered by popularity, a year of 0
+// matches all years. If providerIDs holds a tmdb or imdb id the movie with that id is returned.
+func (c *Client) SearchMovies(ctx context.Context, title string, year int, providerIDs map[string]string) ([]Movie, error) {
+	type movie struct {
+		ID            int    `json:"id"`
+		IMDbID        string `json:"imdb_id"`
+		Title         string `json:"title"`
+		OriginalTitle string `json:"original_title"`
+		Overview      string `json:"overview"`
+		ReleaseDate   string `json:"release_date"`
+		PosterPath    string `json:"poster_path"`
+	}
+	var results []movie
+	id, err := c.findID(ctx, MediaTypeMovie, providerIDs)
+	switch {
+	case err == nil:
+		var details movie
+		if err := c.get(ctx, "/movie/"+url.PathEscape(id), nil, &details); err != nil {
+			return nil, err
+		}
+		results = append(results, details)
+	case errors.Is(err, ErrNoProviderID):
+		if strings.TrimSpace(title) == "" {
+			return nil, ErrNotFound
+		}
+		var result struct {
+			Results []movie `json:"results"`
+		}
+		params := url.Values{"query": {title}}
+		if year > 0 {
+			params.Set("year", strconv.Itoa(year))
+		}
+		if err := c.get(ctx, "/search/movie", params, &result); err != nil {
+			return nil, err
+		}
+		results = result.Results
+	default:
+		return nil, err
+	}
+
+	movies := make([]Movie, 0, len(results))
+	for _, r := range results {
+		m := Movie{
+			ID:            strconv.Itoa(r.ID),
+			IMDbID:        r.IMDbID,
+			Title:         r.Title,
+			OriginalTitle: r.OriginalTitle,
+			Overview:      r.Overview,
+			PosterPath:    r.PosterPath,
+		}
+		if releaseDate, err := time.Parse(time.DateOnly, r.ReleaseDate); err == nil {
+			m.ReleaseDate = releaseDate
+		}
+		movies = append(movies, m)
+	}
+	return movies, nil
+}
+
+// Images returns the posters, backdrops and logos of a movie or show, ordered by rating.
+// mediaType is MediaTypeMovie or MediaTypeTV, the item is looked up using its provider IDs.
+func (c *Client) Images(ctx context.Context, mediaType string, providerIDs map[string]string) ([]Image, error) {
+	id, err := c.findID(ctx, mediaType, providerIDs)
+	if err != nil {
+		return nil, err
+	}
+	type image struct {
+		FilePath    string  `json:"file_path"`
+		Width       int     `json:"width"`
+		Height      int     `json:"height"`
+		Language    string  `json:"iso_639_1"`
+		VoteAverage float64 `json:"vote_average"`
+		VoteCount   int     `json:"vote_count"`
+	}
+	var result struct {
+		Posters   []image `json:"posters"`
+		Backdrops []image `json:"backdrops"`
+		Logos     []image `json:"logos"`
+	}
+	if err := c.get(ctx, "/"+mediaType+"/"+id+"/images", nil, &result); err != nil {
+		return nil, err
+	}
+	var images []Image
+	add := func(imageType string, list []image) {
+		for _, i := range list {
+			images = append(images, Image{
+				Type:        imageType,
+				FilePath:    i.FilePath,
+				Width:       i.Width,
+				Height:      i.Height,
+				Language:    i.Language,
+				VoteAverage: i.VoteAverage,
+				VoteCount:   i.VoteCount,
+			})
+		}
+	}
+	add("poster", result.Posters)
+	add("backdrop", result.Backdrops)
+	add("logo", result.Logos)
+	slices.SortStableFunc(images, func(a, b Image) int {
+		return cmp.Or(cmp.Compare(b.VoteAverage, a.VoteAverage), cmp.Compare(b.VoteCount, a.VoteCount))
+	})
+	return images, nil
+}
+
+// DownloadImage downloads an image, only URLs of the TMDB image server are allowed.
+func (c *Client) DownloadImage(ctx context.Context, imageURL string) ([]byte, error) {
+	if !strings.HasPrefix(imageURL, "https://image.tmdb.org/t/p/") {
+		return nil, fmt.Errorf("not a tmdb image: %s", imageURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("tmdb image %s: %s", imageURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
+}
+
+// ImageURL returns the URL of an image path, e.g. the profile path of a person.
+func ImageURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	return imageBaseURL + path
+}
+
+// ThumbnailURL returns the URL of a small version of an image path, to be used as preview.
+func ThumbnailURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	return thumbnailBaseURL + path
+}
+
+// findID returns the TMDB ID of a movie or show, other provider IDs are resolved using the find API.
+func (c *Client) findID(ctx context.Context, mediaType string, providerIDs map[string]string) (string, error) {
+	for _, provider := range []string{"tmdb", "themoviedb"} {
+		if id := providerIDs[provider]; id != "" {
+			return id, nil
+		}
+	}
+	for _, provider := range []string{"tvdb", "imdb"} {
+		id := providerIDs[provider]
+		if id == "" || (provider == "tvdb" && mediaType != MediaTypeTV) {
+			continue
+		}
+		var result struct {
+			MovieResults []struct {
+				ID int `json:"id"`
+			} `json:"movie_results"`
+			TVResults []struct {
+				ID int `json:"id"`
+			} `json:"tv_results"`
+		}
+		params := url.Values{"external_source": {provider + "_id"}}
+		if err := c.get(ctx, "/find/"+url.PathEscape(id), params, &result); err != nil {
+			return "", err
+		}
+		if mediaType == MediaTypeMovie && len(result.MovieResults) > 0 {
+			return strconv.Itoa(result.MovieResults[0].ID), nil
+		}
+		if mediaType == MediaTypeTV && len(result.TVResults) > 0 {
+			return strconv.Itoa(result.TVResults[0].ID), nil
+		}
+	}
+	return "", ErrNoProviderID
+}
+
+// get retrieves an API resource and decodes it into v, responses are cached.
+func (c *Client) get(ctx context.Context, path string, params url.Values, v any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	// v4 read access tokens are JWTs and are sent as bearer token
+	bearer := strings.HasPrefix(c.apiKey, "eyJ")
+	if !bearer {
+		params.Set("api_key", c.apiKey)
+	}
+	requestURL := c.baseURL + path + "?" + params.Encode()
+
+	c.cacheMu.Lock()
+	entry, ok := c.cache[requestURL]
+	c.cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return json.Unmarshal(entry.data, v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("accept", "application/json")
+	if bearer {
+		req.Header.Set("authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("tmdb %s: %s", path, resp.Status)
+	}
+
+	var data json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+
+	c.cacheMu.Lock()
+	now := time.Now()
+	for k, e := range c.cache {
+		if now.After(e.expires) {
+			delete(c.cache, k)
+		}
+	}
+	c.cache[requestURL] = cacheEntry{data: data, expires: now.Add(cacheTTL)}
+	c.cacheMu.Unlock()
+
+	return json.Unmarshal(data, v)
+}